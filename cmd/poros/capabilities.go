@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/enrich"
+	"github.com/KilimcininKorOglu/poros/internal/netif"
+	"github.com/KilimcininKorOglu/poros/internal/probe"
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+	"github.com/spf13/cobra"
+)
+
+// capabilityLookupIP is looked up to check that the enrichment providers
+// answer; every provider has data for it.
+const capabilityLookupIP = "8.8.8.8"
+
+// capabilityTimeout bounds each enrichment provider check.
+const capabilityTimeout = 3 * time.Second
+
+// capabilityReport is the document printed by poros capabilities. Its
+// keys are stable: fields are only ever added.
+type capabilityReport struct {
+	Version    string             `json:"version"`
+	OS         string             `json:"os"`
+	Arch       string             `json:"arch"`
+	RawSockets bool               `json:"raw_sockets"` // CAP_NET_RAW, root or Administrator
+	Methods    []methodCapability `json:"methods"`
+	IPv6       ipv6Capability     `json:"ipv6"`
+	MaxMind    maxmindCapability  `json:"maxmind"`
+	Enrichment enrichCapabilities `json:"enrichment"`
+}
+
+// methodCapability reports whether a probe method works for one address
+// family.
+type methodCapability struct {
+	Method    string `json:"method"`
+	Family    string `json:"family"` // "ipv4" or "ipv6"
+	Available bool   `json:"available"`
+	Socket    string `json:"socket,omitempty"` // "raw" or "dgram"
+	// IntermediateHops is false where only the destination answers, as
+	// with unprivileged ICMP datagram sockets on Linux
+	IntermediateHops bool   `json:"intermediate_hops"`
+	Error            string `json:"error,omitempty"`
+}
+
+// ipv6Capability reports whether the host can reach the internet over
+// IPv6.
+type ipv6Capability struct {
+	Available bool   `json:"available"`
+	Source    string `json:"source,omitempty"` // address probes would be sent from
+	Error     string `json:"error,omitempty"`
+}
+
+// maxmindCapability reports which MaxMind databases are loaded.
+type maxmindCapability struct {
+	Configured bool   `json:"configured"`
+	ASN        bool   `json:"asn"`
+	City       bool   `json:"city"`
+	ASNPath    string `json:"asn_path,omitempty"`
+	CityPath   string `json:"city_path,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// enrichCapabilities reports which online enrichment providers answer.
+// Checked is false, and Providers empty, with --no-network.
+type enrichCapabilities struct {
+	Checked   bool                 `json:"checked"`
+	Providers []providerCapability `json:"providers"`
+}
+
+// providerCapability is the outcome of one lookup against a provider.
+type providerCapability struct {
+	Name      string  `json:"name"` // "rdns", "asn" or "geoip"
+	Reachable bool    `json:"reachable"`
+	LatencyMs float64 `json:"latency_ms,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// capabilityChecks are the host checks behind the report; tests replace
+// them.
+type capabilityChecks struct {
+	// method opens and closes the sockets of a probe method, returning
+	// the socket kind
+	method func(method trace.ProbeMethod, ipv6 bool) (string, error)
+	// sourceAddr returns the address used to reach the internet
+	sourceAddr func(ipv6 bool) (net.IP, error)
+	// maxmind reports the configured MaxMind databases
+	maxmind func() maxmindCapability
+	// providers maps provider names to a lookup of capabilityLookupIP
+	providers map[string]func(ctx context.Context) error
+	// hopsVisible reports whether a socket kind sees intermediate hops
+	hopsVisible func(socket string) bool
+}
+
+// providerNames orders the enrichment providers in the report.
+var providerNames = []string{"rdns", "asn", "geoip"}
+
+// systemCapabilityChecks returns the checks run against this host, with
+// the enrichment settings of the config file.
+func systemCapabilityChecks() capabilityChecks {
+	ip := net.ParseIP(capabilityLookupIP)
+	geoConfig := enrich.IPAPIConfig{Timeout: capabilityTimeout}
+	if cfg != nil {
+		geoConfig.BaseURL = cfg.Defaults.Enrichment.IPAPIURL
+		geoConfig.APIKey = cfg.Defaults.Enrichment.IPAPIKey
+		geoConfig.HTTPClient, _ = cfg.Network.HTTPClient(version)
+	}
+
+	return capabilityChecks{
+		method:     trace.CheckMethod,
+		sourceAddr: netif.SourceAddr,
+		maxmind:    checkMaxMind,
+		providers: map[string]func(ctx context.Context) error{
+			"rdns": func(ctx context.Context) error {
+				_, err := enrich.NewRDNSResolver(enrich.RDNSConfig{Timeout: capabilityTimeout}).Lookup(ctx, ip)
+				return err
+			},
+			"asn": func(ctx context.Context) error {
+				_, err := enrich.NewTeamCymruASN(enrich.TeamCymruConfig{Timeout: capabilityTimeout}).Lookup(ctx, ip)
+				return err
+			},
+			"geoip": func(ctx context.Context) error {
+				_, err := enrich.NewIPAPIGeo(geoConfig).Lookup(ctx, ip)
+				return err
+			},
+		},
+		hopsVisible: probe.SeesIntermediateHops,
+	}
+}
+
+// checkMaxMind opens the MaxMind databases of the config file, as a trace
+// would, but never downloads them.
+func checkMaxMind() maxmindCapability {
+	if cfg == nil || !cfg.MaxMind.Configured() {
+		return maxmindCapability{}
+	}
+	result := maxmindCapability{
+		Configured: true,
+		ASNPath:    cfg.MaxMind.ASNDBPath(),
+		CityPath:   cfg.MaxMind.CityDBPath(),
+	}
+	db, err := enrich.NewMaxMindDB(enrich.MaxMindDBConfig{ASNDBPath: result.ASNPath, GeoDBPath: result.CityPath})
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer db.Close()
+	result.ASN = db.HasASN()
+	result.City = db.HasGeo()
+	return result
+}
+
+// buildCapabilities runs checks and assembles the report. Provider
+// lookups run concurrently and are skipped with noNetwork.
+func buildCapabilities(ctx context.Context, checks capabilityChecks, noNetwork bool) capabilityReport {
+	report := capabilityReport{
+		Version: version,
+		OS:      runtime.GOOS,
+		Arch:    runtime.GOARCH,
+	}
+
+	for _, ipv6 := range []bool{false, true} {
+		family := "ipv4"
+		if ipv6 {
+			family = "ipv6"
+		}
+		for _, method := range trace.ProbeMethods {
+			m := methodCapability{Method: method.String(), Family: family}
+			socket, err := checks.method(method, ipv6)
+			if err != nil {
+				m.Error = err.Error()
+			} else {
+				m.Available = true
+				m.Socket = socket
+				m.IntermediateHops = checks.hopsVisible(socket)
+			}
+			// Only the ICMP prober falls back to an unprivileged socket
+			if method == trace.ProbeICMP && !ipv6 {
+				report.RawSockets = m.Available && socket == probe.SocketRaw
+			}
+			report.Methods = append(report.Methods, m)
+		}
+	}
+
+	if source, err := checks.sourceAddr(true); err != nil {
+		report.IPv6.Error = err.Error()
+	} else if !source.IsGlobalUnicast() || source.To4() != nil {
+		report.IPv6.Error = fmt.Sprintf("no global IPv6 address (source %s)", source)
+	} else {
+		report.IPv6.Available = true
+		report.IPv6.Source = source.String()
+	}
+
+	report.MaxMind = checks.maxmind()
+
+	report.Enrichment.Providers = []providerCapability{}
+	if noNetwork {
+		return report
+	}
+	report.Enrichment.Checked = true
+	report.Enrichment.Providers = make([]providerCapability, len(providerNames))
+	var wg sync.WaitGroup
+	for i, name := range providerNames {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, capabilityTimeout)
+			defer cancel()
+
+			p := providerCapability{Name: name}
+			start := time.Now()
+			if err := checks.providers[name](ctx); err != nil {
+				p.Error = err.Error()
+			} else {
+				p.Reachable = true
+				p.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+			}
+			report.Enrichment.Providers[i] = p
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+// writeCapabilities writes the report as indented JSON.
+func writeCapabilities(w io.Writer, report capabilityReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+var capabilitiesNoNetwork bool
+
+var capabilitiesCmd = &cobra.Command{
+	Use:   "capabilities",
+	Short: "Report which probe methods and enrichment sources work on this host",
+	Long: `Report, as JSON, what poros can do on this host: which probe methods can
+open their sockets for IPv4 and IPv6, whether raw sockets are permitted,
+whether the host has IPv6 connectivity, which MaxMind databases load, and
+which online enrichment providers answer. Sockets are opened and closed
+again; no probes are sent. --no-network skips the provider lookups.
+
+The document's keys are stable, for orchestration tools that decide per
+host which measurements to schedule.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		probe.SilenceDgramWarning()
+		report := buildCapabilities(cmd.Context(), systemCapabilityChecks(), capabilitiesNoNetwork)
+		return writeCapabilities(os.Stdout, report)
+	},
+}
+
+func init() {
+	// JSON is the only format; the flag matches interfaces --json
+	capabilitiesCmd.Flags().BoolP("json", "j", true, "Output in JSON format")
+	capabilitiesCmd.Flags().BoolVar(&capabilitiesNoNetwork, "no-network", false, "Skip the enrichment provider lookups")
+}
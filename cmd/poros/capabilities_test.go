@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/KilimcininKorOglu/poros/internal/probe"
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+)
+
+// stubCapabilityChecks describes an unprivileged Linux host: ICMP falls
+// back to a datagram socket, everything else needs raw sockets.
+func stubCapabilityChecks() capabilityChecks {
+	return capabilityChecks{
+		method: func(method trace.ProbeMethod, ipv6 bool) (string, error) {
+			if method == trace.ProbeICMP {
+				return probe.SocketDgram, nil
+			}
+			return "", probe.ErrPermissionDenied
+		},
+		sourceAddr: func(ipv6 bool) (net.IP, error) {
+			return net.ParseIP("2001:db8::7"), nil
+		},
+		maxmind: func() maxmindCapability {
+			return maxmindCapability{Configured: true, ASN: true, ASNPath: "/db/GeoLite2-ASN.mmdb"}
+		},
+		providers: map[string]func(ctx context.Context) error{
+			"rdns":  func(ctx context.Context) error { return nil },
+			"asn":   func(ctx context.Context) error { return errors.New("no TXT record") },
+			"geoip": func(ctx context.Context) error { return nil },
+		},
+		hopsVisible: func(socket string) bool { return socket != probe.SocketDgram },
+	}
+}
+
+func TestBuildCapabilities(t *testing.T) {
+	report := buildCapabilities(context.Background(), stubCapabilityChecks(), false)
+
+	if report.RawSockets {
+		t.Error("raw_sockets should be false when ICMP falls back to a datagram socket")
+	}
+	if len(report.Methods) != 2*len(trace.ProbeMethods) {
+		t.Fatalf("%d method entries, want one per method and family", len(report.Methods))
+	}
+	icmp, udp6 := report.Methods[0], report.Methods[len(trace.ProbeMethods)+1]
+	if icmp.Method != "icmp" || icmp.Family != "ipv4" || !icmp.Available || icmp.Socket != probe.SocketDgram || icmp.IntermediateHops {
+		t.Errorf("icmp/ipv4 = %+v, want available on a dgram socket without intermediate hops", icmp)
+	}
+	if udp6.Method != "udp" || udp6.Family != "ipv6" || udp6.Available || udp6.Error == "" {
+		t.Errorf("udp/ipv6 = %+v, want unavailable with an error", udp6)
+	}
+
+	if !report.IPv6.Available || report.IPv6.Source != "2001:db8::7" {
+		t.Errorf("ipv6 = %+v, want available from 2001:db8::7", report.IPv6)
+	}
+	if !report.MaxMind.ASN || report.MaxMind.City {
+		t.Errorf("maxmind = %+v, want the ASN database only", report.MaxMind)
+	}
+
+	if !report.Enrichment.Checked {
+		t.Error("providers should be checked without --no-network")
+	}
+	var names []string
+	for _, p := range report.Enrichment.Providers {
+		names = append(names, p.Name)
+		if reachable := p.Name != "asn"; p.Reachable != reachable || (p.Error == "") != reachable {
+			t.Errorf("provider %+v, want reachable = %v", p, reachable)
+		}
+	}
+	if !reflect.DeepEqual(names, providerNames) {
+		t.Errorf("providers = %v, want %v", names, providerNames)
+	}
+}
+
+func TestBuildCapabilities_NoNetwork(t *testing.T) {
+	checks := stubCapabilityChecks()
+	for name := range checks.providers {
+		checks.providers[name] = func(ctx context.Context) error {
+			t.Errorf("provider %s checked with --no-network", name)
+			return nil
+		}
+	}
+	checks.sourceAddr = func(ipv6 bool) (net.IP, error) {
+		return net.ParseIP("fe80::1"), nil
+	}
+
+	report := buildCapabilities(context.Background(), checks, true)
+	if report.Enrichment.Checked || len(report.Enrichment.Providers) != 0 {
+		t.Errorf("enrichment = %+v, want unchecked", report.Enrichment)
+	}
+	if report.IPv6.Available || report.IPv6.Error == "" {
+		t.Errorf("ipv6 = %+v, a link-local source is no IPv6 connectivity", report.IPv6)
+	}
+}
+
+func TestWriteCapabilities_StableKeys(t *testing.T) {
+	checks := stubCapabilityChecks()
+	checks.method = func(trace.ProbeMethod, bool) (string, error) { return probe.SocketRaw, nil }
+	report := buildCapabilities(context.Background(), checks, true)
+	if !report.RawSockets {
+		t.Error("raw_sockets should be true when ICMP opens a raw socket")
+	}
+
+	var buf bytes.Buffer
+	if err := writeCapabilities(&buf, report); err != nil {
+		t.Fatalf("writeCapabilities() error = %v", err)
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("output is not a JSON object: %v", err)
+	}
+	var keys []string
+	for key := range doc {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	want := []string{"arch", "enrichment", "ipv6", "maxmind", "methods", "os", "raw_sockets", "version"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+
+	// Keys are present even when false or empty
+	for _, key := range []string{`"providers": []`, `"checked": false`, `"intermediate_hops": true`} {
+		if !bytes.Contains(buf.Bytes(), []byte(key)) {
+			t.Errorf("output lacks %s", key)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/netip"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+	"github.com/spf13/cobra"
+)
+
+// maxCIDRSamples caps --scan-cidr: a scan traces the first usable address
+// plus at most this many more, one full trace each.
+const maxCIDRSamples = 15
+
+// Prefixes broader than these are rejected rather than sampled; a few
+// addresses say little about a network that large.
+const (
+	minCIDRBits4 = 8
+	minCIDRBits6 = 32
+)
+
+// parseCIDRTarget reports whether target is written as a network rather
+// than a host. Host names never contain a slash, so any target with one
+// is taken as CIDR notation and must be a valid, not too broad prefix.
+func parseCIDRTarget(target string) (netip.Prefix, bool, error) {
+	s := strings.TrimSpace(target)
+	if !strings.Contains(s, "/") {
+		return netip.Prefix{}, false, nil
+	}
+
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, true, fmt.Errorf("%w %q: not a host name, IP address or CIDR prefix", trace.ErrInvalidTarget, target)
+	}
+	prefix = prefix.Masked()
+
+	minBits := minCIDRBits4
+	if prefix.Addr().Is6() {
+		minBits = minCIDRBits6
+	}
+	if prefix.Bits() < minBits {
+		return netip.Prefix{}, true, fmt.Errorf("%w %s: prefixes broader than /%d cannot be traced", trace.ErrInvalidTarget, prefix, minBits)
+	}
+	return prefix, true, nil
+}
+
+// cidrTargetError explains what to do with a network given as target
+// without --scan-cidr.
+func cidrTargetError(prefix netip.Prefix) error {
+	first := cidrSamples(prefix, 0)[0]
+	return fmt.Errorf("%w %s: this is a network, not a host; trace one of its addresses, e.g. %s, "+
+		"or sample the network with --scan-cidr N to trace its first usable address and up to N more (at most %d)",
+		trace.ErrInvalidTarget, prefix, first, maxCIDRSamples)
+}
+
+// cidrSamples returns the first usable address of prefix followed by up
+// to n more, evenly spaced up to the last usable address. The network
+// address, and for IPv4 the broadcast address, are not usable unless the
+// prefix is too small to have others (/31 and /32, /127 and /128).
+func cidrSamples(prefix netip.Prefix, n int) []netip.Addr {
+	prefix = prefix.Masked()
+	size := len(prefix.Addr().AsSlice())
+	hostBits := size*8 - prefix.Bits()
+
+	first := new(big.Int).SetBytes(prefix.Addr().AsSlice())
+	count := new(big.Int).Lsh(big.NewInt(1), uint(hostBits))
+	if hostBits >= 2 {
+		// Skip the network address (the subnet-router anycast address in
+		// IPv6) and the IPv4 broadcast address
+		first.Add(first, big.NewInt(1))
+		count.Sub(count, big.NewInt(1))
+		if prefix.Addr().Is4() {
+			count.Sub(count, big.NewInt(1))
+		}
+	}
+
+	samples := []netip.Addr{bigToAddr(first, size)}
+	if n <= 0 {
+		return samples
+	}
+
+	// Sample i of n sits at (count-1)*i/n past the first, so the last
+	// sample is the last usable address
+	span := new(big.Int).Sub(count, big.NewInt(1))
+	for i := 1; i <= n; i++ {
+		offset := new(big.Int).Mul(span, big.NewInt(int64(i)))
+		offset.Quo(offset, big.NewInt(int64(n)))
+		addr := bigToAddr(offset.Add(offset, first), size)
+		if addr != samples[len(samples)-1] {
+			samples = append(samples, addr)
+		}
+	}
+	return samples
+}
+
+// bigToAddr converts v to an IPv4 (size 4) or IPv6 (size 16) address.
+func bigToAddr(v *big.Int, size int) netip.Addr {
+	buf := make([]byte, size)
+	v.FillBytes(buf)
+	addr, _ := netip.AddrFromSlice(buf)
+	return addr
+}
+
+// runCIDRScan traces the sampled addresses of the CIDR target in args one
+// after another, as if each had been given as the target. A failed trace
+// does not stop the scan; the first error is returned once all addresses
+// are traced.
+func runCIDRScan(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return errScanCIDRTarget
+	}
+	target := args[0]
+	prefix, isCIDR, err := parseCIDRTarget(target)
+	if err != nil {
+		return writeErrorDocument(trace.WithStage(trace.StageResolve, target, err))
+	}
+	if !isCIDR {
+		return writeErrorDocument(trace.WithStage(trace.StageConfig, target, errScanCIDRTarget))
+	}
+	if scanCIDR < 0 || scanCIDR > maxCIDRSamples {
+		err := fmt.Errorf("invalid --scan-cidr %d: must be between 0 and %d", scanCIDR, maxCIDRSamples)
+		return writeErrorDocument(trace.WithStage(trace.StageConfig, target, err))
+	}
+	for _, mode := range []struct {
+		set  bool
+		flag string
+	}{
+		{tuiMode, "tui"},
+		{htmlReport(), "html"},
+		{baseline != "", "baseline"},
+		{junitOutput != "", "junit"},
+		{multiMethod != "", "multi-method"},
+	} {
+		if mode.set {
+			err := fmt.Errorf("--scan-cidr cannot be combined with --%s", mode.flag)
+			return writeErrorDocument(trace.WithStage(trace.StageConfig, target, err))
+		}
+	}
+
+	// Stop the whole scan on Ctrl+C, not just the running trace
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	cmd.SetContext(ctx)
+
+	samples := cidrSamples(prefix, scanCIDR)
+	fmt.Fprintf(os.Stderr, "Warning: --scan-cidr traces %d addresses of %s one after another, up to %d probes in total\n",
+		len(samples), prefix, len(samples)*maxHops*probeCount)
+
+	var firstErr error
+	failed := 0
+	for i, addr := range samples {
+		if ctx.Err() != nil {
+			return errors.New("scan interrupted")
+		}
+		fmt.Fprintf(os.Stderr, "\n[%d/%d] %s\n", i+1, len(samples), addr)
+		if err := writeErrorDocument(executeTrace(cmd, []string{addr.String()})); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	if firstErr != nil {
+		return fmt.Errorf("%d of %d traces of %s failed, first: %w", failed, len(samples), prefix, firstErr)
+	}
+	return nil
+}
+
+// errScanCIDRTarget is returned for --scan-cidr without a CIDR target.
+var errScanCIDRTarget = errors.New("--scan-cidr requires a CIDR target, e.g. 203.0.113.0/24")
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+)
+
+func TestParseCIDRTarget(t *testing.T) {
+	tests := []struct {
+		target  string
+		want    string // "" = not a CIDR target
+		wantErr bool
+	}{
+		{"example.com", "", false},
+		{"203.0.113.7", "", false},
+		{"2001:db8::1", "", false},
+		{"203.0.113.0/24", "203.0.113.0/24", false},
+		{" 203.0.113.7/24 ", "203.0.113.0/24", false}, // host bits are masked
+		{"10.0.0.0/8", "10.0.0.0/8", false},
+		{"2001:db8::/48", "2001:db8::/48", false},
+		{"2001:db8::/32", "2001:db8::/32", false},
+		{"0.0.0.0/0", "", true},
+		{"::/0", "", true},
+		{"10.0.0.0/7", "", true},
+		{"2001:db8::/31", "", true},
+		{"203.0.113.0/33", "", true},
+		{"203.0.113.0/-1", "", true},
+		{"203.0.113.0/", "", true},
+		{"/24", "", true},
+		{"example.com/24", "", true},
+		{"fe80::1%eth0/64", "", true},
+		{"203.0.113.0/24/8", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			prefix, isCIDR, err := parseCIDRTarget(tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCIDRTarget() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !isCIDR || !errors.Is(err, trace.ErrInvalidTarget) {
+					t.Errorf("a bad prefix should be a CIDR target error, got isCIDR=%v err=%v", isCIDR, err)
+				}
+				return
+			}
+			if isCIDR != (tt.want != "") {
+				t.Fatalf("isCIDR = %v, want %v", isCIDR, tt.want != "")
+			}
+			if isCIDR && prefix.String() != tt.want {
+				t.Errorf("prefix = %s, want %s", prefix, tt.want)
+			}
+		})
+	}
+}
+
+func TestCIDRSamples(t *testing.T) {
+	tests := []struct {
+		prefix string
+		n      int
+		want   []string
+	}{
+		{"203.0.113.0/24", 0, []string{"203.0.113.1"}},
+		{"203.0.113.0/24", 1, []string{"203.0.113.1", "203.0.113.254"}},
+		{"203.0.113.0/24", 3, []string{"203.0.113.1", "203.0.113.85", "203.0.113.169", "203.0.113.254"}},
+		{"203.0.113.0/30", 15, []string{"203.0.113.1", "203.0.113.2"}},
+		{"203.0.113.0/31", 15, []string{"203.0.113.0", "203.0.113.1"}},
+		{"203.0.113.9/32", 15, []string{"203.0.113.9"}},
+		{"10.0.0.0/8", 2, []string{"10.0.0.1", "10.127.255.255", "10.255.255.254"}},
+		{"2001:db8::/64", 1, []string{"2001:db8::1", "2001:db8::ffff:ffff:ffff:ffff"}},
+		{"2001:db8::/126", 15, []string{"2001:db8::1", "2001:db8::2", "2001:db8::3"}},
+		{"2001:db8::/127", 15, []string{"2001:db8::", "2001:db8::1"}},
+		{"2001:db8::/32", 1, []string{"2001:db8::1", "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			var got []string
+			for _, addr := range cidrSamples(netip.MustParsePrefix(tt.prefix), tt.n) {
+				got = append(got, addr.String())
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("cidrSamples(%s, %d) = %v, want %v", tt.prefix, tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCIDRSamples_Capped(t *testing.T) {
+	samples := cidrSamples(netip.MustParsePrefix("10.0.0.0/8"), maxCIDRSamples)
+	if len(samples) != maxCIDRSamples+1 {
+		t.Fatalf("%d samples, want %d", len(samples), maxCIDRSamples+1)
+	}
+	for i := 1; i < len(samples); i++ {
+		if !samples[i-1].Less(samples[i]) {
+			t.Errorf("samples not ascending: %s before %s", samples[i-1], samples[i])
+		}
+	}
+}
+
+func TestCIDRTargetError(t *testing.T) {
+	err := cidrTargetError(netip.MustParsePrefix("203.0.113.0/24"))
+	if !errors.Is(err, trace.ErrInvalidTarget) {
+		t.Errorf("error should wrap ErrInvalidTarget: %v", err)
+	}
+	for _, want := range []string{"203.0.113.0/24", "not a host", "203.0.113.1", "--scan-cidr"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q should mention %q", err, want)
+		}
+	}
+}
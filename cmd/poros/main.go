@@ -4,6 +4,9 @@ package main
 import (
 	"fmt"
 	"os"
+
+	"github.com/KilimcininKorOglu/poros/internal/output"
+	"github.com/KilimcininKorOglu/poros/internal/term"
 )
 
 // Version information (set via ldflags during build)
@@ -17,8 +20,13 @@ func main() {
 	// Set version info for CLI
 	SetVersion(version, commit, date)
 
+	// Consoles that cannot show ANSI escape sequences get plain output
+	if !output.EnableVirtualTerminal(os.Stdout) || !output.EnableVirtualTerminal(os.Stderr) {
+		term.DisableColors()
+	}
+
 	if err := Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(ExitCode(err))
 	}
 }
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/KilimcininKorOglu/poros/internal/launch"
+	"github.com/KilimcininKorOglu/poros/internal/output"
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+	"github.com/spf13/cobra"
+)
+
+// parseMultiMethod parses --multi-method and rejects flags that only
+// make sense for a single trace. It returns nil without --multi-method.
+func parseMultiMethod(cmd *cobra.Command) ([]trace.ProbeMethod, error) {
+	if multiMethod == "" {
+		return nil, nil
+	}
+	methods, err := trace.ParseProbeMethods(multiMethod)
+	if err != nil {
+		return nil, fmt.Errorf("--multi-method: %w", err)
+	}
+
+	for _, flag := range []string{"icmp", "udp", "tcp", "paris", "tui", "csv", "verbose", "baseline", "html-template", "junit",
+		"assert-complete", "assert-max-hops", "assert-max-rtt", "assert-max-loss"} {
+		if cmd.Flags().Changed(flag) {
+			return nil, fmt.Errorf("--multi-method cannot be combined with --%s", flag)
+		}
+	}
+	return methods, nil
+}
+
+// runMultiMethod traces target once per probe method with one tracer,
+// and writes the combined result: to stdout as text or JSON, and as an
+// HTML report with --html.
+func runMultiMethod(cmd *cobra.Command, target string, aliases []string, methods []trace.ProbeMethod,
+	traceConfig *trace.Config, outputConfig output.Config, anonymizer *trace.Anonymizer) error {
+	format := output.FormatText
+	if jsonOutput {
+		format = output.FormatJSON
+	}
+	writer := output.NewWriter(format, outputConfig)
+
+	// The tracer's own prober serves the first method
+	traceConfig.ProbeMethod = methods[0]
+	traceConfig.Paris = methods[0] == trace.ProbeParis
+	tracer, err := trace.New(traceConfig)
+	if err != nil {
+		return trace.WithStage(trace.StageSocket, target, fmt.Errorf("failed to create tracer: %w", err))
+	}
+	defer tracer.Close()
+
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if !jsonOutput {
+		fmt.Fprintf(os.Stderr, "Tracing %s with %d probe methods, one after another...\n", target, len(methods))
+	}
+	result, err := tracer.TraceMethods(ctx, target, methods)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return errors.New("trace interrupted")
+		}
+		return fmt.Errorf("trace failed: %w", err)
+	}
+	for _, r := range result.Results {
+		r.Aliases = aliases
+		if anonymizer != nil {
+			anonymizer.Result(r)
+		}
+	}
+	if anonymizer != nil {
+		result.Target = anonymizer.Target(result.Target)
+	}
+
+	if err := writer.WriteMulti(result); err != nil {
+		return trace.WithStage(trace.StageOutput, target, err)
+	}
+
+	if !htmlReport() {
+		return nil
+	}
+	htmlFormatter := output.NewHTMLFormatter(outputConfig)
+	path := htmlPath(target, result.Results[0].Timestamp, htmlFormatter.FileExtension())
+	data, err := htmlFormatter.FormatMulti(result)
+	if err == nil {
+		err = os.WriteFile(path, data, 0644)
+	}
+	if err != nil {
+		return trace.WithStage(trace.StageOutput, target, fmt.Errorf("failed to write HTML report: %w", err))
+	}
+	fmt.Fprintf(os.Stderr, "\nHTML report saved to: %s\n", path)
+
+	if openReport {
+		if err := launch.Open(path); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not open report: %v\n", err)
+		}
+	}
+	return nil
+}
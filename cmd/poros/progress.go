@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/term"
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+)
+
+// statusInterval is how often the status line is redrawn, so the elapsed
+// time keeps moving while a slow hop is probed.
+const statusInterval = 200 * time.Millisecond
+
+// statusLine is a transient progress line at the bottom of the terminal,
+// e.g. "probing hop 14/30, 2 timeouts, 6.2 s elapsed". It is rewritten in
+// place with \r and cleared before anything else is printed, so it never
+// mixes with the hop lines written to stdout on the same terminal.
+type statusLine struct {
+	out      io.Writer // the terminal, usually stderr
+	lastHop  int
+	now      func() time.Time
+	interval time.Duration
+
+	mu       sync.Mutex
+	start    time.Time
+	progress trace.Progress
+	next     int // hop being probed
+	timeouts int
+	shown    int // width of the line on screen; 0 = cleared
+	stopped  bool
+	done     chan struct{}
+}
+
+// newStatusLine creates a status line on out for a trace probing hops
+// firstHop to lastHop. Call Start to show it.
+func newStatusLine(out io.Writer, firstHop, lastHop int) *statusLine {
+	return &statusLine{
+		out:      out,
+		lastHop:  lastHop,
+		next:     firstHop,
+		now:      time.Now,
+		interval: statusInterval,
+		done:     make(chan struct{}),
+	}
+}
+
+// statusEnabled reports whether a status line should be shown: only on
+// an interactive terminal, and not for TUI, --watch or machine-readable output.
+func statusEnabled() bool {
+	if tuiMode || watchMode || jsonOutput || csvOutput || debug {
+		return false
+	}
+	return term.Capabilities().Stderr.TTY
+}
+
+// Start shows the status line and redraws it until Stop.
+func (s *statusLine) Start() {
+	s.mu.Lock()
+	s.start = s.now()
+	s.draw()
+	s.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.mu.Lock()
+				s.draw()
+				s.mu.Unlock()
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// Progress records a progress snapshot; it is a trace.Config.OnProgress
+// callback. The line is redrawn on the next tick.
+func (s *statusLine) Progress(p trace.Progress) {
+	s.mu.Lock()
+	s.progress = p
+	s.mu.Unlock()
+}
+
+// Hop clears the status line, calls print to write hop, and draws the
+// line again below it with the hop counted.
+func (s *statusLine) Hop(hop *trace.Hop, print func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clear()
+	print()
+
+	s.next = hop.Number + 1
+	if !hop.Responded && !hop.Unprobed {
+		s.timeouts++
+	}
+	s.draw()
+}
+
+// Skipped clears the status line, calls print to write the skipped
+// range, and draws the line again below it.
+func (s *statusLine) Skipped(skipped *trace.SkippedHops, print func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clear()
+	print()
+	s.next = skipped.LastHop + 1
+	s.draw()
+}
+
+// Stop clears the status line for good, before the final output. It may
+// be called more than once.
+func (s *statusLine) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		return
+	}
+	s.stopped = true
+	close(s.done)
+	s.clear()
+}
+
+// draw rewrites the line in place, padding over a longer previous one.
+// The caller holds mu.
+func (s *statusLine) draw() {
+	if s.stopped {
+		return
+	}
+	text := formatStatus(s.progress, s.next, s.lastHop, s.timeouts, s.now().Sub(s.start))
+	pad := max(s.shown-len(text), 0)
+	fmt.Fprintf(s.out, "\r%s%s\r%s", text, strings.Repeat(" ", pad), text)
+	s.shown = len(text)
+}
+
+// clear blanks the line and returns the cursor to its start. The caller
+// holds mu.
+func (s *statusLine) clear() {
+	if s.shown == 0 {
+		return
+	}
+	fmt.Fprintf(s.out, "\r%s\r", strings.Repeat(" ", s.shown))
+	s.shown = 0
+}
+
+// formatStatus returns the status line text for a trace probing hop of
+// lastHop, with timeouts unresponsive hops so far, after elapsed.
+func formatStatus(p trace.Progress, hop, lastHop, timeouts int, elapsed time.Duration) string {
+	var status string
+	switch p.Phase {
+	case trace.PhaseIdle, trace.PhaseResolving:
+		status = "resolving target"
+	case trace.PhaseEnriching:
+		status = "looking up hop details"
+	case trace.PhaseDone:
+		status = "done"
+	default:
+		// After the last hop, only destination verification is left
+		status = fmt.Sprintf("probing hop %d/%d", min(hop, lastHop), lastHop)
+		if timeouts == 1 {
+			status += ", 1 timeout"
+		} else if timeouts > 1 {
+			status += fmt.Sprintf(", %d timeouts", timeouts)
+		}
+	}
+	return fmt.Sprintf("%s, %.1f s elapsed", status, elapsed.Seconds())
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+)
+
+func TestFormatStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		phase    trace.Phase
+		hop      int
+		timeouts int
+		want     string
+	}{
+		{"resolving", trace.PhaseResolving, 1, 0, "resolving target, 6.2 s elapsed"},
+		{"probing", trace.PhaseProbing, 14, 0, "probing hop 14/30, 6.2 s elapsed"},
+		{"one timeout", trace.PhaseProbing, 14, 1, "probing hop 14/30, 1 timeout, 6.2 s elapsed"},
+		{"timeouts", trace.PhaseProbing, 14, 2, "probing hop 14/30, 2 timeouts, 6.2 s elapsed"},
+		{"verifying", trace.PhaseProbing, 31, 0, "probing hop 30/30, 6.2 s elapsed"},
+		{"enriching", trace.PhaseEnriching, 31, 3, "looking up hop details, 6.2 s elapsed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := formatStatus(trace.Progress{Phase: tt.phase}, tt.hop, 30, tt.timeouts, 6180*time.Millisecond)
+			if got != tt.want {
+				t.Errorf("formatStatus() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// newTestStatusLine returns a status line writing to out with a clock
+// that only moves when told to.
+func newTestStatusLine(out *bytes.Buffer) (*statusLine, *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := newStatusLine(out, 1, 30)
+	s.now = func() time.Time { return now }
+	s.interval = time.Hour // redraw only when the test does
+	return s, &now
+}
+
+func TestStatusLine_ClearsAroundHops(t *testing.T) {
+	var term bytes.Buffer
+	s, now := newTestStatusLine(&term)
+	s.Start()
+	defer s.Stop()
+	s.Progress(trace.Progress{Phase: trace.PhaseProbing})
+
+	*now = now.Add(1500 * time.Millisecond)
+	term.Reset()
+	s.Hop(&trace.Hop{Number: 1}, func() {
+		// The hop line is printed on a cleared line
+		want := "\r" + strings.Repeat(" ", len("resolving target, 0.0 s elapsed")) + "\r"
+		if term.String() != want {
+			t.Errorf("before hop: terminal has %q, want the line cleared with %q", term.String(), want)
+		}
+		term.WriteString("  1  *\n")
+	})
+
+	after := term.String()
+	hopLine := strings.Index(after, "  1  *\n")
+	if hopLine < 0 {
+		t.Fatalf("hop line missing: %q", after)
+	}
+	if redraw := after[hopLine+len("  1  *\n"):]; !strings.HasSuffix(redraw, "probing hop 2/30, 1 timeout, 1.5 s elapsed") {
+		t.Errorf("after hop: redraw = %q, want the line redrawn with the timeout counted", redraw)
+	}
+
+	s.Skipped(&trace.SkippedHops{FirstHop: 2, LastHop: 4}, func() {})
+	if !strings.HasSuffix(term.String(), "probing hop 5/30, 1 timeout, 1.5 s elapsed") {
+		t.Errorf("after skipped hops: terminal ends with %q", term.String())
+	}
+}
+
+func TestStatusLine_StopClears(t *testing.T) {
+	var term bytes.Buffer
+	s, _ := newTestStatusLine(&term)
+	s.Start()
+
+	term.Reset()
+	s.Stop()
+	want := "\r" + strings.Repeat(" ", len("resolving target, 0.0 s elapsed")) + "\r"
+	if term.String() != want {
+		t.Errorf("Stop() wrote %q, want %q", term.String(), want)
+	}
+
+	// Nothing is drawn after Stop, and stopping again is harmless
+	term.Reset()
+	s.Stop()
+	s.Hop(&trace.Hop{Number: 1, Responded: true}, func() { term.WriteString("  1  192.0.2.1\n") })
+	if term.String() != "  1  192.0.2.1\n" {
+		t.Errorf("after Stop() the terminal got %q, want only the hop line", term.String())
+	}
+}
+
+func TestStatusLine_PadsShorterLine(t *testing.T) {
+	var term bytes.Buffer
+	s, _ := newTestStatusLine(&term)
+	s.Start()
+	defer s.Stop()
+
+	// A shorter line overwrites the rest of a longer one with spaces
+	s.mu.Lock()
+	s.progress = trace.Progress{Phase: trace.PhaseProbing}
+	s.timeouts = 12
+	s.draw()
+	long := s.shown
+	s.timeouts = 0
+	term.Reset()
+	s.draw()
+	s.mu.Unlock()
+
+	short := "probing hop 1/30, 0.0 s elapsed"
+	want := "\r" + short + strings.Repeat(" ", long-len(short)) + "\r" + short
+	if term.String() != want {
+		t.Errorf("draw() = %q, want %q", term.String(), want)
+	}
+}
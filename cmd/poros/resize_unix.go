@@ -0,0 +1,38 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/KilimcininKorOglu/poros/internal/output"
+)
+
+// watchResize re-measures size whenever the terminal reports a resize
+// (SIGWINCH). The returned function stops watching.
+func watchResize(size *output.TerminalSize) func() {
+	if size == nil {
+		return func() {}
+	}
+
+	sig := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(sig, syscall.SIGWINCH)
+	go func() {
+		for {
+			select {
+			case <-sig:
+				size.Refresh()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sig)
+		close(done)
+	}
+}
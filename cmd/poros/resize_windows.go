@@ -0,0 +1,39 @@
+//go:build windows
+
+package main
+
+import (
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/output"
+)
+
+// resizePollInterval is how often the console size is re-measured. Windows
+// consoles have no resize signal.
+const resizePollInterval = 500 * time.Millisecond
+
+// watchResize re-measures size periodically. The returned function stops
+// watching.
+func watchResize(size *output.TerminalSize) func() {
+	if size == nil {
+		return func() {}
+	}
+
+	ticker := time.NewTicker(resizePollInterval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				size.Refresh()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
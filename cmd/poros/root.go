@@ -3,18 +3,28 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/KilimcininKorOglu/poros/internal/config"
+	"github.com/KilimcininKorOglu/poros/internal/diag"
 	"github.com/KilimcininKorOglu/poros/internal/enrich"
+	"github.com/KilimcininKorOglu/poros/internal/launch"
+	"github.com/KilimcininKorOglu/poros/internal/metrics"
+	"github.com/KilimcininKorOglu/poros/internal/netif"
 	"github.com/KilimcininKorOglu/poros/internal/output"
+	"github.com/KilimcininKorOglu/poros/internal/term"
 	"github.com/KilimcininKorOglu/poros/internal/trace"
 	"github.com/KilimcininKorOglu/poros/internal/tui"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 )
 
 var (
@@ -25,28 +35,91 @@ var (
 	useParis    bool
 	maxHops     int
 	probeCount  int
+	destQueries int
+	rttOutlier  string
 	timeout     time.Duration
 	firstHop    int
+	lastHop     int
+	verifyDest  int
+	appProbe    bool
+	maxPackets  int
 	sequential  bool
+	parallelQs  bool
+	concurrency int
+	skipPrivate bool
 	forceIPv4   bool
 	forceIPv6   bool
+	forceTrace  bool
 	ifaceName   string
 	sourceIP    string
+	nat64Prefix string
+	resolveMode string
+	viaRouters  []string
 	destPort    int
+	icmpID      string
+	seqStart    string
+	flowID      string
+	tagSpecs    []string
+	csvTags     bool
+	probeTimes  bool
+	anonymize   bool
+	roundCoords bool
 	verbose     bool
 	jsonOutput  bool
 	csvOutput   bool
 	htmlOutput  string
+	htmlAuto    bool
+	htmlTmpl    string
+	openReport  bool
+	baseline    string
+	junitOutput string
 	tuiMode     bool
 	noEnrich    bool
 	noRDNS      bool
 	noASN       bool
 	noGeoIP     bool
+	hideASN     bool
+	hideGeo     bool
+	hideHost    bool
+	enrichTO    time.Duration
+	maxmindDir  string
 	noColor     bool
+	asnDetail   bool
+	countryName bool
+	collapseTO  bool
+	hopSummary  bool
+	multiMethod string
+	watchMode   bool
+	watchEvery  time.Duration
+	scanCIDR    int
+	rttWarn     float64
+	rttCrit     float64
+	lossWarn    float64
+	lossCrit    float64
+	timeFormat  string
+	useUTC      bool
+	units       string
+	locale      string
+	columns     string
+	showStats   bool
+	debug       bool
+
+	// Runtime diagnostics
+	pprofListen      string
+	pprofAllowRemote bool
+	metricsListen    string
+	metricsBuckets   string
+
+	// Assertions
+	assertComplete bool
+	assertMaxHops  int
+	assertMaxRTT   float64
+	assertMaxLoss  float64
 
 	// Config file
-	cfgFile string
-	cfg     *config.Config
+	cfgFiles      []string
+	lenientConfig bool
+	cfg           *config.Config
 )
 
 var rootCmd = &cobra.Command{
@@ -73,6 +146,7 @@ Examples:
   poros -v google.com           Verbose table output
   poros --json google.com       JSON output
   poros --tui google.com        Interactive TUI mode
+  poros mtr google.com          Trace continuously, like mtr
   poros config --init           Create default config file
   poros                         Interactive mode (prompts for target)`,
 	Args:              cobra.MaximumNArgs(1),
@@ -82,7 +156,8 @@ Examples:
 
 func init() {
 	// Config file flag
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "Config file (default: ~/.config/poros/config.yaml)")
+	rootCmd.PersistentFlags().StringArrayVar(&cfgFiles, "config", nil, "Config file, repeatable; later files override earlier ones (default: ~/.config/poros/config.yaml)")
+	rootCmd.PersistentFlags().BoolVar(&lenientConfig, "lenient-config", false, "Clamp out-of-range max_hops, queries and first_hop in the config file with a warning instead of failing")
 
 	// Probe method flags
 	rootCmd.Flags().BoolVarP(&useICMP, "icmp", "I", false, "Use ICMP Echo probes (default)")
@@ -93,34 +168,101 @@ func init() {
 	// Trace parameters
 	rootCmd.Flags().IntVarP(&maxHops, "max-hops", "m", 0, "Maximum number of hops")
 	rootCmd.Flags().IntVarP(&probeCount, "queries", "q", 0, "Number of probes per hop")
+	rootCmd.Flags().IntVar(&destQueries, "dest-queries", 0, "Number of probes for the destination hop (0 = same as --queries)")
+	rootCmd.Flags().StringVar(&rttOutlier, "rtt-outlier", "", "Outlier handling of hop RTT statistics: none, winsorize (clip at p95) or drop (beyond 1.5×IQR)")
 	rootCmd.Flags().DurationVarP(&timeout, "timeout", "w", 0, "Probe timeout")
 	rootCmd.Flags().IntVarP(&firstHop, "first-hop", "f", 0, "Start from specified hop")
+	rootCmd.Flags().IntVar(&lastHop, "last-hop", 0, "Stop after the specified hop without tracing to the destination")
+	rootCmd.Flags().IntVar(&verifyDest, "verify-dest", 0, "Send N extra probes to the destination to measure end-host loss")
+	rootCmd.Flags().BoolVar(&appProbe, "app-probe", false, "After a TCP trace reaches the destination, time a full connect (and TLS handshake on port 443)")
+	rootCmd.Flags().IntVar(&maxPackets, "max-packets", 0, "Stop the trace after sending N probe packets (0 = unlimited)")
 	rootCmd.Flags().BoolVar(&sequential, "sequential", false, "Use sequential mode (slower but reliable)")
+	rootCmd.Flags().BoolVar(&parallelQs, "parallel-queries", false, "Send the probes of each hop at once in sequential mode (UDP, TCP, Paris)")
+	rootCmd.Flags().IntVar(&concurrency, "concurrency", 0, "Maximum probes in flight in concurrent mode (1-512, default 30)")
+	rootCmd.Flags().BoolVar(&skipPrivate, "skip-private-prefix", false, "Fast-forward through leading private/CGNAT hops with one probe each; a silent hop ends it early (implies --sequential)")
 
 	// Network settings
 	rootCmd.Flags().BoolVarP(&forceIPv4, "ipv4", "4", false, "Use IPv4 only")
 	rootCmd.Flags().BoolVarP(&forceIPv6, "ipv6", "6", false, "Use IPv6 only")
+	rootCmd.Flags().BoolVar(&forceTrace, "force", false, "Trace with -6 even when no global IPv6 connectivity is detected, e.g. link-local targets")
 	rootCmd.Flags().StringVarP(&ifaceName, "interface", "i", "", "Network interface to use")
 	rootCmd.Flags().StringVarP(&sourceIP, "source", "s", "", "Source IP address")
+	rootCmd.Flags().StringVar(&nat64Prefix, "nat64-prefix", "", "NAT64 prefix for IPv4 targets on IPv6-only networks (default: discover via DNS64)")
+	rootCmd.Flags().StringVar(&resolveMode, "resolve", "", "When to re-resolve a hostname target across --watch rounds: every, once or ttl (default: once); ttl falls back to the Go resolver, which reports no TTL, so the target is resolved every round")
+	rootCmd.Flags().StringSliceVar(&viaRouters, "via", nil, "Loose source route IPv4 ICMP/UDP probes through these routers (LSRR, up to 8, lab use)")
 	rootCmd.Flags().IntVarP(&destPort, "port", "p", 0, "Destination port (UDP/TCP)")
+	rootCmd.Flags().StringVar(&icmpID, "icmp-id", "", "ICMP Echo identifier, decimal or 0x hex (default: process ID)")
+	rootCmd.Flags().StringVar(&seqStart, "seq-start", "", "Sequence number of the first probe (default: 1)")
+	rootCmd.Flags().StringVar(&flowID, "flow-id", "", "Paris flow identifier, decimal or 0x hex (default: random)")
+	rootCmd.Flags().StringVar(&multiMethod, "multi-method", "", "Trace once per probe method and compare hop by hop, e.g. icmp,udp,tcp")
+	rootCmd.Flags().BoolVar(&watchMode, "watch", false, "Trace in rounds until Ctrl+C, accumulating per-hop statistics like mtr")
+	rootCmd.Flags().DurationVar(&watchEvery, "interval", trace.DefaultLoopInterval, "Pause between --watch rounds")
+	rootCmd.Flags().IntVar(&scanCIDR, "scan-cidr", 0, "For a CIDR target, trace its first usable address and up to N more, evenly spaced (max 15)")
+	rootCmd.Flags().StringArrayVar(&tagSpecs, "tag", nil, "Record a key=value tag in the result (repeatable)")
 
 	// Output flags
 	rootCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Show detailed table output")
 	rootCmd.Flags().BoolVarP(&jsonOutput, "json", "j", false, "Output in JSON format")
 	rootCmd.Flags().BoolVar(&csvOutput, "csv", false, "Output in CSV format")
+	rootCmd.Flags().BoolVar(&csvTags, "csv-tags", false, "Add a tag_<key> column per --tag to CSV output")
+	rootCmd.Flags().BoolVar(&probeTimes, "probe-times", false, "Add each probe's send time to JSON output")
+	rootCmd.Flags().BoolVar(&collapseTO, "collapse-timeouts", false, "Show runs of 3+ unresponsive hops as one line in text and table output")
+	rootCmd.Flags().BoolVar(&hopSummary, "summary-per-hop", false, "End each text hop line with its average RTT, jitter and loss (always with more than 3 queries)")
+	rootCmd.Flags().BoolVar(&anonymize, "anonymize", false, "Replace private hop addresses with placeholders and drop local host details for sharing")
+	rootCmd.Flags().BoolVar(&roundCoords, "round-coords", false, "Round GeoIP coordinates to one decimal (implies --anonymize)")
 	rootCmd.Flags().StringVar(&htmlOutput, "html", "", "Generate HTML report to file")
+	rootCmd.Flags().BoolVar(&htmlAuto, "html-auto", false, "Generate HTML report named after the target and time")
+	rootCmd.Flags().StringVar(&htmlTmpl, "html-template", "", "Render the HTML report with a custom template file")
+	rootCmd.Flags().BoolVar(&openReport, "open", false, "Open the HTML report in the default browser")
+	rootCmd.Flags().StringVar(&baseline, "baseline", "", "Compare the HTML report against an earlier --json result file")
+	rootCmd.Flags().StringVar(&junitOutput, "junit", "", "Write assertion results as JUnit XML to file; without --assert-* flags it asserts the destination is reached")
 	rootCmd.Flags().BoolVarP(&tuiMode, "tui", "t", false, "Interactive TUI mode")
 	rootCmd.Flags().BoolVar(&noColor, "no-color", false, "Disable colored output")
+	rootCmd.Flags().BoolVar(&hideASN, "hide-asn", false, "Leave ASN data out of text, table and TUI output; JSON, CSV and HTML keep it")
+	rootCmd.Flags().BoolVar(&hideGeo, "hide-geo", false, "Leave GeoIP data out of table and TUI output; JSON, CSV and HTML keep it")
+	rootCmd.Flags().BoolVar(&hideHost, "hide-hostname", false, "Leave hostnames out of text, table and TUI output; JSON, CSV and HTML keep them")
+	rootCmd.Flags().Float64Var(&rttWarn, "rtt-warn", output.DefaultRTTWarnMs, "RTT in ms at which latency is shown as a warning")
+	rootCmd.Flags().Float64Var(&rttCrit, "rtt-crit", output.DefaultRTTCritMs, "RTT in ms at which latency is shown as critical")
+	rootCmd.Flags().Float64Var(&lossWarn, "loss-warn", output.DefaultLossWarnPercent, "Packet loss % above which hops are shown as a warning")
+	rootCmd.Flags().Float64Var(&lossCrit, "loss-crit", output.DefaultLossCritPercent, "Packet loss % above which hops are shown as critical")
+	rootCmd.Flags().StringVar(&timeFormat, "time-format", "", "Timestamp format: rfc3339, unix, local or a Go layout")
+	rootCmd.Flags().BoolVar(&useUTC, "utc", false, "Show timestamps in UTC")
+	rootCmd.Flags().StringVar(&units, "units", "", "RTT unit of text, table and HTML output: ms, us or auto")
+	rootCmd.Flags().StringVar(&locale, "locale", "", "Locale for decimal separators in text, table and HTML output, e.g. de-DE")
+	rootCmd.Flags().StringVar(&columns, "columns", "", "Verbose table columns, e.g. hop,ip,asn,last,avg,loss")
+	rootCmd.Flags().BoolVar(&showStats, "stats", false, "Print probe statistics to stderr after the trace")
+	rootCmd.Flags().BoolVar(&debug, "debug", false, "Print debugging diagnostics to stderr (implies --stats)")
+	rootCmd.Flags().StringVar(&pprofListen, "pprof-listen", "", "Serve pprof and /debug/poros state on ADDR, e.g. 127.0.0.1:6060")
+	rootCmd.Flags().BoolVar(&pprofAllowRemote, "pprof-allow-remote", false, "Allow a --pprof-listen address reachable from other hosts")
+	rootCmd.Flags().StringVar(&metricsListen, "metrics-listen", "", "Serve the per-hop RTT histograms of --watch rounds at /metrics on ADDR, e.g. :9464 (a single trace records none)")
+	rootCmd.Flags().StringVar(&metricsBuckets, "metrics-buckets", "", "RTT histogram bucket bounds in ms for --metrics-listen (default 1,2,5,10,20,50,100,200,500,1000)")
 
 	// Enrichment flags
 	rootCmd.Flags().BoolVar(&noEnrich, "no-enrich", false, "Disable all enrichment")
 	rootCmd.Flags().BoolVar(&noRDNS, "no-rdns", false, "Disable reverse DNS lookups")
 	rootCmd.Flags().BoolVar(&noASN, "no-asn", false, "Disable ASN lookups")
 	rootCmd.Flags().BoolVar(&noGeoIP, "no-geoip", false, "Disable GeoIP lookups")
+	rootCmd.Flags().DurationVar(&enrichTO, "enrich-timeout", 0, "Timeout for each rDNS, ASN and GeoIP lookup (default per provider)")
+	rootCmd.Flags().BoolVar(&asnDetail, "asn-detail", false, "Show AS country and announced prefix in text output")
+	rootCmd.Flags().BoolVar(&countryName, "country-names", false, "Show full country names instead of ISO codes in table and TUI locations")
+	rootCmd.Flags().StringVar(&maxmindDir, "maxmind-dir", "", "Use the GeoLite2 .mmdb files in DIR for ASN/GeoIP (no license key needed)")
+
+	// Assertion flags
+	rootCmd.Flags().BoolVar(&assertComplete, "assert-complete", false, "Fail if the destination is not reached")
+	rootCmd.Flags().IntVar(&assertMaxHops, "assert-max-hops", 0, "Fail if the destination is more than N hops away")
+	rootCmd.Flags().Float64Var(&assertMaxRTT, "assert-max-rtt", 0, "Fail if the RTT to the destination exceeds MS milliseconds")
+	rootCmd.Flags().Float64Var(&assertMaxLoss, "assert-max-loss", 0, "Fail if packet loss at the destination exceeds PCT percent (0 fails on any loss)")
 
 	// Add subcommands
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(interfacesCmd)
+	rootCmd.AddCommand(capabilitiesCmd)
+	rootCmd.AddCommand(selfUpdateCmd)
+
+	// poros mtr takes the flags of a trace
+	mtrCmd.Flags().AddFlagSet(rootCmd.Flags())
+	rootCmd.AddCommand(mtrCmd)
 }
 
 // loadConfig loads configuration from file and applies defaults
@@ -128,9 +270,9 @@ func init() {
 func loadConfig(cmd *cobra.Command, args []string) error {
 	var err error
 
-	if cfgFile != "" {
-		// Custom config file specified
-		cfg, err = config.LoadFrom(cfgFile)
+	if len(cfgFiles) > 0 {
+		// Custom config files specified
+		cfg, err = config.LoadFiles(cfgFiles...)
 		if err != nil {
 			return fmt.Errorf("failed to load config: %w", err)
 		}
@@ -149,110 +291,148 @@ func loadConfig(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	// Apply config defaults if flags not explicitly set
-	applyConfigDefaults(cmd)
+	if lenientConfig {
+		for _, warning := range cfg.ClampTraceParams() {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
 
+	// Apply config defaults if flags not explicitly set
+	if err := applyConfigDefaults(cmd); err != nil {
+		return err
+	}
+	applyColors(noColor)
 	return nil
 }
 
+// applyColors settles whether output is colored once --no-color and the
+// config are known. The term package decides for the formatters and the
+// TUI; the prompts printed here follow the same decision for stdout.
+func applyColors(noColor bool) {
+	if noColor {
+		term.DisableColors()
+	}
+	color.NoColor = !term.Capabilities().Stdout.Colors.Enabled()
+}
+
 // applyConfigDefaults applies config file values for unset flags
-func applyConfigDefaults(cmd *cobra.Command) {
+func applyConfigDefaults(cmd *cobra.Command) error {
 	if cfg == nil {
-		return
+		return nil
 	}
 
 	defaults := cfg.Defaults
+	changed := cmd.Flags().Changed
 
 	// Output mode from config (if no flag set)
-	if !cmd.Flags().Changed("tui") && defaults.TUI {
-		tuiMode = true
+	config.ApplyDefault(&tuiMode, defaults.TUI, changed("tui"))
+	config.ApplyDefault(&verbose, defaults.Verbose, changed("verbose"))
+	config.ApplyDefault(&jsonOutput, defaults.JSON, changed("json"))
+	config.ApplyDefault(&csvOutput, defaults.CSV, changed("csv"))
+	config.ApplyDefault(&noColor, defaults.NoColor, changed("no-color"))
+
+	// Display settings from config
+	config.ApplyDefault(&asnDetail, defaults.ASNDetail, changed("asn-detail"))
+	config.ApplyDefault(&countryName, defaults.CountryNames, changed("country-names"))
+	config.ApplyDefault(&collapseTO, defaults.CollapseTimeouts, changed("collapse-timeouts"))
+	config.ApplyDefault(&hideASN, defaults.HideASN, changed("hide-asn"))
+	config.ApplyDefault(&hideGeo, defaults.HideGeo, changed("hide-geo"))
+	config.ApplyDefault(&hideHost, defaults.HideHostname, changed("hide-hostname"))
+	config.ApplyDefault(&rttWarn, defaults.RTTWarn, changed("rtt-warn"))
+	config.ApplyDefault(&rttCrit, defaults.RTTCrit, changed("rtt-crit"))
+	config.ApplyDefault(&lossWarn, defaults.LossWarn, changed("loss-warn"))
+	config.ApplyDefault(&lossCrit, defaults.LossCrit, changed("loss-crit"))
+	if !changed("time-format") && defaults.TimeFormat != "" {
+		timeFormat = defaults.TimeFormat
 	}
-	if !cmd.Flags().Changed("verbose") && defaults.Verbose {
-		verbose = true
+	config.ApplyDefault(&useUTC, defaults.UTC, changed("utc"))
+	if !changed("units") && defaults.Units != "" {
+		units = defaults.Units
 	}
-	if !cmd.Flags().Changed("json") && defaults.JSON {
-		jsonOutput = true
+	if !changed("locale") && defaults.Locale != "" {
+		locale = defaults.Locale
 	}
-	if !cmd.Flags().Changed("csv") && defaults.CSV {
-		csvOutput = true
+	if !changed("columns") && defaults.Columns != "" {
+		columns = defaults.Columns
 	}
-	if !cmd.Flags().Changed("no-color") && defaults.NoColor {
-		noColor = true
+	if !changed("html-template") && defaults.HTMLTemplate != "" {
+		htmlTmpl = defaults.HTMLTemplate
 	}
 
 	// Probe method from config
-	if !cmd.Flags().Changed("paris") && defaults.Paris {
-		useParis = true
-	}
-	if !cmd.Flags().Changed("icmp") && !cmd.Flags().Changed("udp") && !cmd.Flags().Changed("tcp") {
-		switch defaults.ProbeMethod {
-		case "udp":
+	config.ApplyDefault(&useParis, defaults.Paris, changed("paris"))
+	if !changed("icmp") && !changed("udp") && !changed("tcp") && !changed("paris") && defaults.ProbeMethod != "" {
+		method, err := trace.ParseProbeMethod(defaults.ProbeMethod)
+		if err != nil {
+			return fmt.Errorf("config: %w", err)
+		}
+		switch method {
+		case trace.ProbeUDP:
 			useUDP = true
-		case "tcp":
+		case trace.ProbeTCP:
 			useTCP = true
+		case trace.ProbeParis:
+			useParis = true
 		}
 	}
 
-	// Trace parameters from config
-	if !cmd.Flags().Changed("max-hops") {
-		if defaults.MaxHops > 0 {
-			maxHops = defaults.MaxHops
-		} else {
-			maxHops = 30
-		}
+	// Trace parameters from config, falling back to the built-in defaults
+	if !changed("max-hops") {
+		maxHops = config.PositiveOr(defaults.MaxHops, config.DefaultMaxHops)
 	}
-	if !cmd.Flags().Changed("queries") {
-		if defaults.Queries > 0 {
-			probeCount = defaults.Queries
-		} else {
-			probeCount = 3
-		}
+	if !changed("queries") {
+		probeCount = config.PositiveOr(defaults.Queries, config.DefaultQueries)
 	}
-	if !cmd.Flags().Changed("timeout") {
-		if defaults.Timeout > 0 {
-			timeout = defaults.Timeout
-		} else {
-			timeout = 3 * time.Second
-		}
+	if !changed("timeout") {
+		timeout = config.PositiveOr(defaults.Timeout, config.DefaultTimeout)
 	}
-	if !cmd.Flags().Changed("first-hop") {
-		if defaults.FirstHop > 0 {
-			firstHop = defaults.FirstHop
-		} else {
-			firstHop = 1
-		}
+	if !changed("first-hop") {
+		firstHop = config.PositiveOr(defaults.FirstHop, config.DefaultFirstHop)
+	}
+	config.ApplyDefault(&sequential, defaults.Sequential, changed("sequential"))
+	config.ApplyDefault(&parallelQs, defaults.ParallelQueries, changed("parallel-queries"))
+	config.ApplyDefault(&destQueries, defaults.DestQueries, changed("dest-queries"))
+	if !changed("rtt-outlier") && defaults.RTTOutlier != "" {
+		rttOutlier = defaults.RTTOutlier
 	}
-	if !cmd.Flags().Changed("sequential") && defaults.Sequential {
-		sequential = true
+	config.ApplyDefault(&verifyDest, defaults.VerifyDest, changed("verify-dest"))
+	config.ApplyDefault(&maxPackets, defaults.MaxPackets, changed("max-packets"))
+	if !changed("concurrency") {
+		concurrency = config.PositiveOr(defaults.Concurrency, trace.DefaultConcurrency)
 	}
+	config.ApplyDefault(&skipPrivate, defaults.SkipPrivatePrefix, changed("skip-private-prefix"))
 
 	// Network settings from config
-	if !cmd.Flags().Changed("ipv4") && defaults.IPv4 {
-		forceIPv4 = true
+	config.ApplyDefault(&forceIPv4, defaults.IPv4, changed("ipv4"))
+	config.ApplyDefault(&forceIPv6, defaults.IPv6, changed("ipv6"))
+	if !changed("nat64-prefix") && defaults.NAT64Prefix != "" {
+		nat64Prefix = defaults.NAT64Prefix
 	}
-	if !cmd.Flags().Changed("ipv6") && defaults.IPv6 {
-		forceIPv6 = true
+	if !changed("resolve") && defaults.Resolve != "" {
+		resolveMode = defaults.Resolve
 	}
-	if !cmd.Flags().Changed("port") {
-		if defaults.Port > 0 {
-			destPort = defaults.Port
-		} else {
-			destPort = 33434
-		}
+	if !changed("port") {
+		destPort = config.PositiveOr(defaults.Port, 33434)
 	}
 
-	// Enrichment from config
-	if !defaults.Enrichment.Enabled {
-		noEnrich = true
-	}
-	if !cmd.Flags().Changed("no-rdns") && !defaults.Enrichment.RDNS {
-		noRDNS = true
-	}
-	if !cmd.Flags().Changed("no-asn") && !defaults.Enrichment.ASN {
-		noASN = true
-	}
-	if !cmd.Flags().Changed("no-geoip") && !defaults.Enrichment.GeoIP {
-		noGeoIP = true
+	// Enrichment from config; only switches the file sets are applied, so
+	// "enabled: false" no longer implies the individual no-* flags
+	enrichment := defaults.Enrichment
+	applyDisabled(&noEnrich, enrichment.Enabled, changed("no-enrich"))
+	applyDisabled(&noRDNS, enrichment.RDNS, changed("no-rdns"))
+	applyDisabled(&noASN, enrichment.ASN, changed("no-asn"))
+	applyDisabled(&noGeoIP, enrichment.GeoIP, changed("no-geoip"))
+	return nil
+}
+
+// applyDisabled sets a --no-* flag from an enrichment switch in the config.
+func applyDisabled(noFlag *bool, enabled *bool, flagChanged bool) {
+	if enabled != nil {
+		disabled := !*enabled
+		config.ApplyDefault(noFlag, &disabled, flagChanged)
 	}
 }
 
@@ -274,11 +454,38 @@ var configCmd = &cobra.Command{
 
 Commands:
   poros config --init     Create default config file
-  poros config --show     Show current configuration
-  poros config --path     Show config file path`,
+  poros config --show     Show the effective configuration and its files
+  poros config --path     Show config file path
+
+A config file can set "include: base.yaml" to override a shared base
+file, and --config can be repeated; later files override earlier ones.`,
 	RunE: runConfig,
 }
 
+var interfacesJSON bool
+
+var interfacesCmd = &cobra.Command{
+	Use:   "interfaces",
+	Short: "List network interfaces usable with --interface",
+	Long: `List the network interfaces that are up, with their addresses and MTU.
+Interfaces that look like they carry the default route are marked with *.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ifaces, err := netif.System()
+		if err != nil {
+			return fmt.Errorf("failed to list interfaces: %w", err)
+		}
+		if interfacesJSON {
+			return netif.WriteJSON(os.Stdout, ifaces)
+		}
+		return netif.WriteText(os.Stdout, ifaces)
+	},
+}
+
+func init() {
+	interfacesCmd.Flags().BoolVarP(&interfacesJSON, "json", "j", false, "Output in JSON format")
+}
+
 var (
 	configInit bool
 	configShow bool
@@ -318,7 +525,19 @@ func runConfig(cmd *cobra.Command, args []string) error {
 	}
 
 	if configShow {
-		fmt.Println(config.GenerateExample())
+		if len(cfg.Sources) == 0 {
+			fmt.Println("# No config file loaded; built-in defaults")
+		} else {
+			fmt.Println("# Effective configuration, merged from (later files override earlier):")
+			for i, source := range cfg.Sources {
+				fmt.Printf("#   %d. %s\n", i+1, source)
+			}
+		}
+		data, err := yaml.Marshal(cfg)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(data))
 		return nil
 	}
 
@@ -327,7 +546,59 @@ func runConfig(cmd *cobra.Command, args []string) error {
 }
 
 func runTrace(cmd *cobra.Command, args []string) error {
+	if err := checkWatchFlags(cmd); err != nil {
+		return err
+	}
+	if htmlOutput != "" && htmlAuto {
+		return fmt.Errorf("--html-auto cannot be combined with --html")
+	}
+	if cmd.Flags().Changed("scan-cidr") {
+		return runCIDRScan(cmd, args)
+	}
+	return writeErrorDocument(executeTrace(cmd, args))
+}
+
+// writeErrorDocument writes a failed trace's error as a JSON document in
+// machine-readable modes, and returns err.
+func writeErrorDocument(err error) error {
+	var se *trace.StageError
+	if !errors.As(err, &se) {
+		return err
+	}
+
+	// Machine-readable modes always leave a parseable document on stdout
+	if jsonOutput || csvOutput {
+		if data, ferr := output.NewJSONFormatter(output.Config{}).FormatError(err); ferr == nil {
+			fmt.Fprintln(os.Stdout, string(data))
+		}
+	}
+	return err
+}
+
+// outputFormat returns the output format selected by the flags.
+func outputFormat() output.Format {
+	switch {
+	case jsonOutput:
+		return output.FormatJSON
+	case csvOutput:
+		return output.FormatCSV
+	case verbose:
+		return output.FormatVerbose
+	}
+	return output.FormatText
+}
+
+// executeTrace runs a trace. Errors are annotated with the stage that
+// failed until the result has been written; later errors, such as failed
+// assertions, are returned as-is.
+func executeTrace(cmd *cobra.Command, args []string) (retErr error) {
 	var target string
+	stage := trace.StageConfig
+	defer func() {
+		if stage != "" {
+			retErr = trace.WithStage(stage, target, retErr)
+		}
+	}()
 
 	// If no target provided, prompt for it interactively
 	if len(args) == 0 {
@@ -340,10 +611,80 @@ func runTrace(cmd *cobra.Command, args []string) error {
 		target = args[0]
 	}
 
-	// Check for aliases
-	if cfg != nil && cfg.Aliases != nil {
-		if alias, ok := cfg.Aliases[target]; ok {
-			target = alias
+	if prefix, isCIDR, err := parseCIDRTarget(target); isCIDR {
+		stage = trace.StageResolve
+		if err != nil {
+			return err
+		}
+		return cidrTargetError(prefix)
+	}
+
+	chain, err := resolveTarget(target)
+	if err != nil {
+		if errors.Is(err, trace.ErrInvalidTarget) {
+			stage = trace.StageResolve
+		}
+		return err
+	}
+	target = chain[len(chain)-1]
+	aliases := chain[:len(chain)-1]
+
+	if rttWarn <= 0 || rttCrit <= 0 || rttWarn >= rttCrit {
+		return fmt.Errorf("invalid RTT thresholds: --rtt-warn (%.2f) must be positive and below --rtt-crit (%.2f)", rttWarn, rttCrit)
+	}
+	if lossWarn < 0 || lossCrit <= 0 || lossWarn >= lossCrit {
+		return fmt.Errorf("invalid loss thresholds: --loss-warn (%.1f) must be non-negative and below --loss-crit (%.1f)", lossWarn, lossCrit)
+	}
+	if assertMaxLoss < 0 || assertMaxLoss > 100 {
+		return fmt.Errorf("invalid --assert-max-loss %.1f: must be between 0 and 100", assertMaxLoss)
+	}
+	if concurrency < 1 || concurrency > trace.MaxConcurrencyLimit {
+		return fmt.Errorf("invalid --concurrency %d: must be between 1 and %d", concurrency, trace.MaxConcurrencyLimit)
+	}
+	if err := output.ValidateTimeFormat(timeFormat); err != nil {
+		return err
+	}
+	if useUTC && strings.EqualFold(timeFormat, output.TimeFormatLocal) {
+		return fmt.Errorf("--utc cannot be combined with --time-format local")
+	}
+	if err := output.ValidateUnits(units); err != nil {
+		return err
+	}
+	if err := output.ValidateLocale(locale); err != nil {
+		return err
+	}
+	var tableColumns []string
+	if columns != "" {
+		var err error
+		if tableColumns, err = output.ParseTableColumns(columns); err != nil {
+			return fmt.Errorf("invalid --columns: %w", err)
+		}
+	}
+	outlierPolicy, err := trace.ParseOutlierPolicy(rttOutlier)
+	if err != nil {
+		return fmt.Errorf("invalid --rtt-outlier: %w", err)
+	}
+	resolvePolicy, err := watchResolvePolicy()
+	if err != nil {
+		return err
+	}
+	methods, err := parseMultiMethod(cmd)
+	if err != nil {
+		return err
+	}
+
+	// Load the HTML report baseline before tracing, so a bad file fails fast
+	var baselineResult *trace.TraceResult
+	if baseline != "" {
+		if !htmlReport() {
+			return fmt.Errorf("--baseline requires --html")
+		}
+		data, err := os.ReadFile(baseline)
+		if err != nil {
+			return fmt.Errorf("failed to read baseline: %w", err)
+		}
+		if baselineResult, err = output.ParseJSONResult(data); err != nil {
+			return fmt.Errorf("failed to read baseline %s: %w", baseline, err)
 		}
 	}
 
@@ -351,22 +692,106 @@ func runTrace(cmd *cobra.Command, args []string) error {
 	traceConfig := trace.DefaultConfig()
 	traceConfig.MaxHops = maxHops
 	traceConfig.ProbeCount = probeCount
+	traceConfig.DestProbeCount = destQueries
+	traceConfig.RTTOutlier = outlierPolicy
 	traceConfig.Timeout = timeout
 	traceConfig.FirstHop = firstHop
+	traceConfig.LastHop = lastHop
+	traceConfig.VerifyDest = verifyDest
+	traceConfig.AppProbe = appProbe
+	traceConfig.MaxPackets = maxPackets
 	traceConfig.Sequential = sequential
+	traceConfig.ParallelQueries = parallelQs
+	traceConfig.MaxConcurrency = concurrency
+	traceConfig.SkipPrivatePrefix = skipPrivate
 	traceConfig.IPv4 = forceIPv4
 	traceConfig.IPv6 = forceIPv6
 	traceConfig.DestPort = destPort
+	if ifaceName != "" {
+		if _, err := netif.Lookup(netif.System, ifaceName); err != nil {
+			return fmt.Errorf("%w; run \"poros interfaces\" to list them", err)
+		}
+	}
+	traceConfig.Interface = ifaceName
+	if _, zone, ok := strings.Cut(target, "%"); ok {
+		traceConfig.Zone = zone
+	}
+	traceConfig.Version = version
+	if sourceIP != "" {
+		ip := net.ParseIP(sourceIP)
+		if ip == nil {
+			return fmt.Errorf("invalid source IP: %s", sourceIP)
+		}
+		traceConfig.SourceIP = ip
+	}
+	for _, via := range viaRouters {
+		ip := net.ParseIP(via)
+		if ip == nil {
+			return fmt.Errorf("invalid --via router: %s", via)
+		}
+		traceConfig.Via = append(traceConfig.Via, ip)
+	}
+	if nat64Prefix != "" {
+		prefix, err := trace.ParseNAT64Prefix(nat64Prefix)
+		if err != nil {
+			return err
+		}
+		traceConfig.NAT64Prefix = prefix
+	}
+	traceConfig.ResolvePolicy = resolvePolicy
+	for _, id := range []struct {
+		flag  string
+		value string
+		dst   *int
+	}{
+		{"--icmp-id", icmpID, &traceConfig.ICMPID},
+		{"--seq-start", seqStart, &traceConfig.SeqStart},
+		{"--flow-id", flowID, &traceConfig.FlowID},
+	} {
+		if id.value == "" {
+			continue
+		}
+		v, err := trace.ParsePacketID(id.value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", id.flag, err)
+		}
+		*id.dst = v
+	}
+	tags, err := trace.ParseTags(tagSpecs)
+	if err != nil {
+		return fmt.Errorf("--tag: %w", err)
+	}
+	if cfg != nil {
+		tags = trace.MergeTags(cfg.Defaults.Tags, tags)
+	}
+	traceConfig.Tags = tags
 
 	// Configure enrichment
-	traceConfig.EnableEnrichment = !noEnrich
-	traceConfig.EnableRDNS = !noRDNS && !noEnrich
-	traceConfig.EnableASN = !noASN && !noEnrich
-	traceConfig.EnableGeoIP = !noGeoIP && !noEnrich
+	configureEnrichment(traceConfig)
+	if cfg != nil {
+		enrichment := cfg.Defaults.Enrichment
+		traceConfig.RDNSTimeout = config.Or(enrichment.RDNSTimeout, 0)
+		traceConfig.ASNTimeout = config.Or(enrichment.ASNTimeout, 0)
+		traceConfig.GeoIPTimeout = config.Or(enrichment.GeoIPTimeout, 0)
+		traceConfig.EnrichCacheSize = config.Or(enrichment.CacheSize, 0)
+		traceConfig.VerifyRDNS = config.Or(enrichment.RDNSVerify, false)
+		traceConfig.IPAPIKey = enrichment.IPAPIKey
+		traceConfig.IPAPIURL = enrichment.IPAPIURL
+		// Validated with the config file
+		traceConfig.HTTPClient, _ = cfg.Network.HTTPClient(version)
+	}
+	if enrichTO != 0 {
+		traceConfig.RDNSTimeout = enrichTO
+		traceConfig.ASNTimeout = enrichTO
+		traceConfig.GeoIPTimeout = enrichTO
+	}
 
-	// Initialize MaxMind if enabled in config
-	if cfg != nil && cfg.MaxMind.Enabled && cfg.MaxMind.LicenseKey != "" {
-		maxmindDB, err := initMaxMind(cfg)
+	// Initialize MaxMind if enabled in config or pointed at database files
+	if cfg != nil && maxmindDir != "" {
+		cfg.MaxMind.DBDir = maxmindDir
+	}
+	if cfg != nil && cfg.MaxMind.Configured() {
+		maxmindDB, err := initMaxMind(cfg, traceConfig.HTTPClient)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: MaxMind initialization failed: %v\n", err)
 			fmt.Fprintf(os.Stderr, "Falling back to online APIs...\n\n")
@@ -374,6 +799,10 @@ func runTrace(cmd *cobra.Command, args []string) error {
 			traceConfig.MaxMindDB = maxmindDB
 		}
 	}
+	if plaintextGeoIP(traceConfig) && config.FirstNotice("plaintext-geoip") {
+		fmt.Fprintf(os.Stderr, "Note: GeoIP lookups send hop addresses to ip-api.com over plain HTTP.\n")
+		fmt.Fprintf(os.Stderr, "Set enrichment.ipapi_key (HTTPS), use --maxmind-dir, or pass --no-geoip to avoid this.\n\n")
+	}
 
 	// Set probe method
 	if useParis {
@@ -387,35 +816,168 @@ func runTrace(cmd *cobra.Command, args []string) error {
 		traceConfig.ProbeMethod = trace.ProbeICMP
 	}
 
+	if err := traceConfig.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	if forceIPv6 && !forceTrace {
+		if err := netif.CheckIPv6(netif.System); err != nil {
+			stage = trace.StageSocket
+			return fmt.Errorf("%w (use --force to trace anyway, e.g. a link-local fe80::1%%eth0 target)", err)
+		}
+	}
+
+	// Runtime diagnostics for long-running sessions
+	if pprofListen != "" {
+		traceConfig.Registry = trace.NewRegistry()
+		server, err := diag.Start(pprofListen, pprofAllowRemote, traceConfig.Registry)
+		if err != nil {
+			return err
+		}
+		defer server.Close()
+		if debug {
+			fmt.Fprintf(os.Stderr, "Diagnostics listening on http://%s/debug/poros\n", server.Addr())
+		}
+	}
+
+	// The RTTs of --watch rounds feed the per-hop histograms; a single
+	// trace has no rounds and records none
+	if metricsListen != "" {
+		var bounds []float64
+		if metricsBuckets != "" {
+			if bounds, err = metrics.ParseBuckets(metricsBuckets); err != nil {
+				return fmt.Errorf("invalid --metrics-buckets: %w", err)
+			}
+		}
+		hops := metrics.NewHopHistograms(bounds)
+		traceConfig.OnRound = func(round trace.Round) { hops.Observe(round.Result) }
+		server, err := diag.StartMetrics(metricsListen, hops)
+		if err != nil {
+			return err
+		}
+		defer server.Close()
+		if debug {
+			fmt.Fprintf(os.Stderr, "Metrics listening on http://%s/metrics\n", server.Addr())
+		}
+	} else if metricsBuckets != "" {
+		return fmt.Errorf("--metrics-buckets requires --metrics-listen")
+	}
+
 	// Configure output
-	outputConfig := output.Config{
-		Colors:     !noColor,
-		NoHostname: false,
-		NoASN:      noASN,
-		NoGeoIP:    noGeoIP,
+	outputConfig := newOutputConfig(tableColumns)
+
+	// Load the report template before tracing, so a broken one fails fast
+	var htmlFormatter *output.HTMLFormatter
+	if htmlReport() && len(methods) == 0 {
+		if htmlFormatter, err = newHTMLFormatter(outputConfig); err != nil {
+			return err
+		}
+	} else if cmd.Flags().Changed("html-template") && !htmlReport() {
+		return fmt.Errorf("--html-template requires --html")
+	}
+
+	// Scrub results for sharing before any formatter sees them
+	var anonymizer *trace.Anonymizer
+	if anonymize || roundCoords {
+		anonymizer = trace.NewAnonymizer(trace.AnonymizeOptions{RoundCoordinates: roundCoords})
+	}
+
+	if len(methods) > 0 {
+		stage = trace.StageTrace
+		return runMultiMethod(cmd, target, aliases, methods, traceConfig, outputConfig, anonymizer)
 	}
 
 	// If TUI mode requested, run TUI
 	if tuiMode {
-		return tui.Run(target, traceConfig)
+		if anonymizer != nil {
+			return fmt.Errorf("--anonymize is not supported in TUI mode")
+		}
+		stage = trace.StageTrace
+		if !watchMode {
+			_, err := tui.Run(target, traceConfig, outputConfig, 0)
+			return err
+		}
+		result, err := tui.Run(target, traceConfig, outputConfig, watchEvery)
+		if err != nil || result == nil {
+			return err
+		}
+		// The final report of a --watch session, in the selected format
+		stage = trace.StageOutput
+		result.Aliases = aliases
+		if err := output.NewWriter(outputFormat(), outputConfig).Write(result); err != nil {
+			return err
+		}
+		stage = ""
+		return checkAssertions(cmd, result, outputConfig)
+	}
+
+	format := outputFormat()
+	writer := output.NewWriter(format, outputConfig)
+
+	// A --watch trace redraws its total after each round instead
+	var view *watchView
+	if watchMode {
+		view = newWatchView(writer, format, outputConfig)
+		if view != nil {
+			traceConfig.OnRound = chainRound(traceConfig.OnRound, view.Round)
+		} else if format != output.FormatJSON && format != output.FormatCSV {
+			fmt.Fprintf(os.Stderr, "Watching %s every %s, press Ctrl+C to stop and print the report...\n", target, watchEvery)
+		}
 	}
 
-	// For streaming text output, set up OnHop callback
-	var textFormatter *output.TextFormatter
-	if !jsonOutput && !csvOutput {
-		textFormatter = output.NewTextFormatter(outputConfig)
+	// Stream hops as they arrive. The verbose table is only rendered at
+	// the end, so text lines are streamed as progress until then.
+	stream := writer
+	if format == output.FormatVerbose {
+		stream = output.NewWriter(output.FormatText, outputConfig)
+	}
+	// Show a transient status line on an interactive terminal; it is
+	// cleared around every streamed line and before the summary
+	var status *statusLine
+	if statusEnabled() {
+		last := traceConfig.MaxHops
+		if traceConfig.LastHop > 0 {
+			last = traceConfig.LastHop
+		}
+		status = newStatusLine(os.Stderr, traceConfig.FirstHop, last)
+		traceConfig.OnProgress = status.Progress
+		defer status.Stop()
+	}
+
+	if stream.Streaming() && !watchMode {
 		traceConfig.OnHop = func(hop *trace.Hop) {
-			fmt.Print(textFormatter.FormatHop(hop))
-			os.Stdout.Sync() // Flush immediately
+			if anonymizer != nil {
+				scrubbed := *hop
+				anonymizer.Hop(&scrubbed)
+				hop = &scrubbed
+			}
+			if status != nil {
+				status.Hop(hop, func() { stream.WriteHop(hop) })
+				return
+			}
+			stream.WriteHop(hop)
+		}
+		traceConfig.OnSkip = func(skipped *trace.SkippedHops) {
+			if status != nil {
+				status.Skipped(skipped, func() { stream.WriteSkipped(skipped) })
+				return
+			}
+			stream.WriteSkipped(skipped)
 		}
 	}
 
-	// Create tracer
-	tracer, err := trace.New(traceConfig)
-	if err != nil {
-		return fmt.Errorf("failed to create tracer: %w", err)
+	// Fit streamed lines to the terminal as it is resized
+	stopResize := watchResize(stream.TerminalSize())
+	defer stopResize()
+
+	// Create tracer; a --watch trace runs its rounds on a session instead
+	stage = trace.StageSocket
+	var tracer *trace.Tracer
+	if !watchMode {
+		if tracer, err = trace.New(traceConfig); err != nil {
+			return fmt.Errorf("failed to create tracer: %w", err)
+		}
+		defer tracer.Close()
 	}
-	defer tracer.Close()
 
 	// Run trace
 	ctx := cmd.Context()
@@ -423,55 +985,231 @@ func runTrace(cmd *cobra.Command, args []string) error {
 		ctx = context.Background()
 	}
 
-	// Show header for text output
-	if !jsonOutput && !csvOutput {
-		fmt.Printf("traceroute to %s, %d hops max\n\n", target, maxHops)
+	// Cancel probing and enrichment promptly on Ctrl+C
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	stage = trace.StageOutput
+	header := chain
+	if anonymizer != nil {
+		header = append([]string(nil), chain...)
+		header[len(header)-1] = anonymizer.Target(target)
+	}
+	switch {
+	case watchMode:
+		// The report is written once the rounds stop
+	case lastHop > 0:
+		err = stream.WritePartialHeader(config.FormatAliasChain(header), firstHop, lastHop)
+	default:
+		err = stream.WriteHeader(config.FormatAliasChain(header), maxHops)
+	}
+	if err != nil {
+		return err
 	}
 
-	result, err := tracer.Trace(ctx, target)
+	stage = trace.StageTrace
+	if status != nil {
+		status.Start()
+	}
+	var result *trace.TraceResult
+	if watchMode {
+		result, err = watchTrace(ctx, traceConfig, target)
+	} else {
+		result, err = tracer.Trace(ctx, target)
+	}
+	if status != nil {
+		status.Stop()
+	}
 	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return errors.New("trace interrupted")
+		}
 		return fmt.Errorf("trace failed: %w", err)
 	}
+	result.Aliases = aliases
+	if anonymizer != nil {
+		anonymizer.Result(result)
+		if baselineResult != nil {
+			anonymizer.Result(baselineResult)
+		}
+	}
 
-	// For JSON/CSV, output the full result at once
-	if jsonOutput || csvOutput {
-		var format output.Format
-		if jsonOutput {
-			format = output.FormatJSON
-		} else {
-			format = output.FormatCSV
+	// Streaming formats only need the summary; others, and a --watch
+	// trace that streamed no hops, write the full result
+	stage = trace.StageOutput
+	if watchMode {
+		if view != nil {
+			view.Clear()
 		}
-		writer := output.NewWriter(format, outputConfig)
-		if err := writer.Write(result); err != nil {
-			return err
+		err = writer.Write(result)
+	} else {
+		err = writer.WriteSummary(result)
+	}
+	if err != nil {
+		return err
+	}
+	stage = ""
+
+	if showStats || debug {
+		fmt.Fprintf(os.Stderr, "\n%s", output.FormatProbeStats(result.ProbeStats))
+	}
+	if debug {
+		for _, hop := range result.Hops {
+			if hop.SendErrors > 0 {
+				fmt.Fprintf(os.Stderr, "debug: hop %d: %d of %d probes failed to send (%s)\n",
+					hop.Number, hop.SendErrors, len(hop.RTTs), hop.SendError)
+			}
 		}
-	} else if verbose {
-		// Verbose table output (not streaming)
-		writer := output.NewWriter(output.FormatVerbose, outputConfig)
-		if err := writer.Write(result); err != nil {
-			return err
+	}
+
+	// Generate HTML report if requested (--open implies --html-auto)
+	if htmlFormatter != nil {
+		htmlFormatter.SetBaseline(baselineResult)
+		path := htmlPath(target, result.Timestamp, htmlFormatter.FileExtension())
+		if err := output.WriteToFile(result, path, htmlFormatter); err != nil {
+			return fmt.Errorf("failed to write HTML report: %w", err)
 		}
-	} else {
-		// Text output - summary only (hops already printed via OnHop)
-		fmt.Println()
-		if result.Completed {
-			fmt.Printf("Trace complete. %d hops, %.2f ms total\n",
-				result.Summary.TotalHops, result.Summary.TotalTimeMs)
-		} else {
-			fmt.Printf("Trace incomplete after %d hops\n", result.Summary.TotalHops)
+		fmt.Fprintf(os.Stderr, "\nHTML report saved to: %s\n", path)
+
+		if openReport {
+			if err := launch.Open(path); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: could not open report: %v\n", err)
+			}
 		}
 	}
 
-	// Generate HTML report if requested
+	return checkAssertions(cmd, result, outputConfig)
+}
+
+// configureEnrichment sets which enrichment the trace collects. The
+// --no-* flags skip the lookups, which also leaves nothing to show; the
+// --hide-* flags only change the display (see newOutputConfig).
+func configureEnrichment(traceConfig *trace.Config) {
+	traceConfig.EnableEnrichment = !noEnrich
+	traceConfig.EnableRDNS = !noRDNS && !noEnrich
+	traceConfig.EnableASN = !noASN && !noEnrich
+	traceConfig.EnableGeoIP = !noGeoIP && !noEnrich
+}
+
+// newOutputConfig returns the output configuration of the flags. ASN and
+// GeoIP data are hidden from human-readable output with their --no-* or
+// --hide-* flag, hostnames with --hide-hostname; machine-readable output
+// keeps whatever was collected.
+func newOutputConfig(tableColumns []string) output.Config {
+	return output.Config{
+		Colors:     !noColor,
+		NoHostname: hideHost,
+		NoASN:      noASN || hideASN,
+		NoGeoIP:    noGeoIP || hideGeo,
+		ASNDetail:  asnDetail,
+		RTTWarnMs:  rttWarn,
+		RTTCritMs:  rttCrit,
+
+		LossWarnPercent: lossWarn,
+		LossCritPercent: lossCrit,
+		TimeFormat:      timeFormat,
+		UTC:             useUTC,
+		Units:           units,
+		Locale:          locale,
+		Columns:         tableColumns,
+		CSVTags:         csvTags,
+		ProbeTimes:      probeTimes,
+
+		CollapseTimeouts: collapseTO,
+		SummaryPerHop:    hopSummary,
+		CountryNames:     countryName,
+	}
+}
+
+// htmlReport reports whether an HTML report was requested with --html,
+// --html-auto or --open.
+func htmlReport() bool {
+	return htmlOutput != "" || htmlAuto || openReport
+}
+
+// htmlPath returns the file the HTML report is written to: the --html
+// file, or else a name from target and timestamp.
+func htmlPath(target string, timestamp time.Time, ext string) string {
 	if htmlOutput != "" {
-		htmlFormatter := output.NewHTMLFormatter(outputConfig)
-		if err := output.WriteToFile(result, htmlOutput, htmlFormatter); err != nil {
-			return fmt.Errorf("failed to write HTML report: %w", err)
+		return htmlOutput
+	}
+	return output.DefaultFilename(target, timestamp, ext)
+}
+
+// newHTMLFormatter returns the HTML report formatter, rendering with
+// --html-template if it is set.
+func newHTMLFormatter(outputConfig output.Config) (*output.HTMLFormatter, error) {
+	if htmlTmpl == "" {
+		return output.NewHTMLFormatter(outputConfig), nil
+	}
+	return output.NewHTMLFormatterFromFile(outputConfig, htmlTmpl)
+}
+
+// checkAssertions evaluates the assertion flags against the result,
+// writes the JUnit report if requested, and returns an error on failure
+// so that the process exits with a non-zero status. --assert-max-loss
+// applies once set, so that 0 fails on any loss.
+func checkAssertions(cmd *cobra.Command, result *trace.TraceResult, outputConfig output.Config) error {
+	assertions := output.Assertions{
+		Complete: assertComplete,
+		MaxHops:  assertMaxHops,
+		MaxRTTMs: assertMaxRTT,
+	}
+	if cmd.Flags().Changed("assert-max-loss") {
+		maxLoss := assertMaxLoss
+		assertions.MaxLossPercent = &maxLoss
+	}
+
+	if junitOutput == "" && assertions.IsEmpty() {
+		return nil
+	}
+
+	junitFormatter := output.NewJUnitFormatter(outputConfig, assertions)
+	if junitOutput != "" {
+		if err := output.WriteToFile(result, junitOutput, junitFormatter); err != nil {
+			return fmt.Errorf("failed to write JUnit report: %w", err)
 		}
-		fmt.Fprintf(os.Stderr, "\nHTML report saved to: %s\n", htmlOutput)
+		fmt.Fprintf(os.Stderr, "JUnit report saved to: %s\n", junitOutput)
 	}
 
-	return nil
+	if assertions.IsEmpty() {
+		assertions.Complete = true
+	}
+
+	results := output.EvaluateAssertions(result, assertions)
+	failures := output.CountFailures(results)
+	if failures == 0 {
+		return nil
+	}
+
+	for _, r := range results {
+		if !r.Passed {
+			fmt.Fprintf(os.Stderr, "Assertion %s failed: %s (%s)\n", r.Name, r.Message, r.Actual)
+		}
+	}
+	return fmt.Errorf("%d of %d assertions failed", failures, len(results))
+}
+
+// resolveTarget normalizes a target and follows aliases, including chains
+// of aliases. The returned chain ends with the normalized host to trace.
+func resolveTarget(target string) ([]string, error) {
+	target, err := trace.NormalizeTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	chain, err := cfg.ResolveAlias(target)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(chain); n > 1 {
+		host, err := trace.NormalizeTarget(chain[n-1])
+		if err != nil {
+			return nil, fmt.Errorf("alias %s: %w", chain[n-2], err)
+		}
+		chain[n-1] = host
+	}
+	return chain, nil
 }
 
 // promptForTarget displays an interactive prompt for the user to enter a target
@@ -495,10 +1233,14 @@ func promptForTarget() (string, error) {
 	fmt.Println()
 
 	// Show aliases if any
-	if cfg != nil && len(cfg.Aliases) > 0 {
+	if names := cfg.AliasNames(); len(names) > 0 {
 		fmt.Println("  Aliases:")
-		for alias, target := range cfg.Aliases {
-			yellow.Printf("    • %s → %s\n", alias, target)
+		for _, name := range names {
+			if chain, err := cfg.ResolveAlias(name); err != nil {
+				color.Red("    • %s: %v", name, err)
+			} else {
+				yellow.Printf("    • %s\n", config.FormatAliasChain(chain))
+			}
 		}
 		fmt.Println()
 	}
@@ -508,7 +1250,6 @@ func promptForTarget() (string, error) {
 
 	for {
 		green.Print("  Enter target (IP or hostname): ")
-		os.Stdout.Sync() // Flush stdout
 
 		input, err := reader.ReadString('\n')
 		if err != nil {
@@ -535,24 +1276,45 @@ func promptForTarget() (string, error) {
 			os.Exit(0)
 		}
 
+		if _, err := resolveTarget(target); err != nil {
+			color.Red("  ✗ %v. Please try again.", err)
+			fmt.Println()
+			continue
+		}
+
 		fmt.Println()
 		return target, nil
 	}
 }
 
+// plaintextGeoIP reports whether the trace will send GeoIP lookups
+// unencrypted: the online service is used and its endpoint is http://.
+func plaintextGeoIP(traceConfig *trace.Config) bool {
+	if !traceConfig.EnableGeoIP {
+		return false
+	}
+	if db, ok := traceConfig.MaxMindDB.(*enrich.MaxMindDB); ok && db.HasGeo() {
+		return false
+	}
+	return enrich.IsPlaintextURL(enrich.IPAPIURL(traceConfig.IPAPIURL, traceConfig.IPAPIKey))
+}
+
 // initMaxMind initializes MaxMind database, downloading if necessary.
-func initMaxMind(cfg *config.Config) (*enrich.MaxMindDB, error) {
-	if !cfg.MaxMind.Enabled || cfg.MaxMind.LicenseKey == "" {
+// Without a license key only existing database files are used; nothing is
+// downloaded or updated.
+func initMaxMind(cfg *config.Config, httpClient *enrich.HTTPClientFactory) (*enrich.MaxMindDB, error) {
+	if !cfg.MaxMind.Configured() {
 		return nil, nil
 	}
 
-	asnPath := config.GetASNDBPath()
-	geoPath := config.GetGeoDBPath()
+	asnPath := cfg.MaxMind.ASNDBPath()
+	geoPath := cfg.MaxMind.CityDBPath()
 
 	maxmindConfig := enrich.MaxMindDBConfig{
 		LicenseKey: cfg.MaxMind.LicenseKey,
 		ASNDBPath:  asnPath,
 		GeoDBPath:  geoPath,
+		HTTPClient: httpClient,
 	}
 
 	// Try to open existing databases
@@ -561,6 +1323,20 @@ func initMaxMind(cfg *config.Config) (*enrich.MaxMindDB, error) {
 		return nil, err
 	}
 
+	if db.Offline() {
+		if !db.HasASN() {
+			fmt.Fprintf(os.Stderr, "Warning: MaxMind ASN database not found at %s\n", asnPath)
+		}
+		if !db.HasGeo() {
+			fmt.Fprintf(os.Stderr, "Warning: MaxMind City database not found at %s\n", geoPath)
+		}
+		if !db.HasASN() && !db.HasGeo() {
+			db.Close()
+			return nil, nil
+		}
+		return db, nil
+	}
+
 	// Check if we need to update
 	if cfg.MaxMind.UpdateHours > 0 {
 		maxAge := time.Duration(cfg.MaxMind.UpdateHours) * time.Hour
@@ -599,6 +1375,16 @@ func Execute() error {
 	return rootCmd.Execute()
 }
 
+// ExitCode returns the process exit status for an error returned by
+// Execute: the failed stage's code, or 1 for unclassified errors.
+func ExitCode(err error) int {
+	var se *trace.StageError
+	if errors.As(err, &se) {
+		return se.Stage.ExitCode()
+	}
+	return 1
+}
+
 // SetVersion sets version information for the CLI.
 func SetVersion(v, c, d string) {
 	version = v
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/output"
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+	"github.com/KilimcininKorOglu/poros/internal/trace/tracetest"
+	"github.com/spf13/pflag"
+)
+
+// parseRootFlags parses args into the root command's flags, restoring
+// every flag once the test ends.
+func parseRootFlags(t *testing.T, args ...string) {
+	t.Helper()
+	t.Cleanup(func() {
+		reset := func(f *pflag.Flag) {
+			if !f.Changed {
+				return
+			}
+			if sv, ok := f.Value.(pflag.SliceValue); ok {
+				_ = sv.Replace(nil)
+			} else {
+				_ = f.Value.Set(f.DefValue)
+			}
+			f.Changed = false
+		}
+		rootCmd.Flags().VisitAll(reset)
+		rootCmd.PersistentFlags().VisitAll(reset)
+		cfg = nil
+	})
+	if err := rootCmd.ParseFlags(args); err != nil {
+		t.Fatalf("ParseFlags(%v) error = %v", args, err)
+	}
+}
+
+func TestLoadConfig_TraceParams(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+		return path
+	}
+	invalid := write("invalid.yaml", "defaults:\n  max_hops: 300\n")
+	valid := write("valid.yaml", "defaults:\n  max_hops: 20\n")
+
+	t.Run("from the config file", func(t *testing.T) {
+		parseRootFlags(t, "--config", invalid)
+		err := loadConfig(rootCmd, nil)
+		if !errors.Is(err, trace.ErrInvalidMaxHops) {
+			t.Fatalf("loadConfig() = %v, want ErrInvalidMaxHops", err)
+		}
+		if want := invalid + ": defaults.max_hops: 300"; !strings.Contains(err.Error(), want) {
+			t.Errorf("loadConfig() = %q, want it to name %q", err, want)
+		}
+	})
+
+	t.Run("lenient", func(t *testing.T) {
+		parseRootFlags(t, "--config", invalid, "--lenient-config")
+		if err := loadConfig(rootCmd, nil); err != nil {
+			t.Fatalf("loadConfig() = %v", err)
+		}
+		if maxHops != trace.MaxHopsLimit {
+			t.Errorf("maxHops = %d, want %d", maxHops, trace.MaxHopsLimit)
+		}
+	})
+
+	// A flag overrides the file and is left to the tracer, whose error
+	// names no file
+	t.Run("from a flag", func(t *testing.T) {
+		parseRootFlags(t, "--config", valid, "--max-hops", "300")
+		if err := loadConfig(rootCmd, nil); err != nil {
+			t.Fatalf("loadConfig() = %v", err)
+		}
+		config := trace.DefaultConfig()
+		config.MaxHops = maxHops
+		err := config.Validate()
+		if !errors.Is(err, trace.ErrInvalidMaxHops) {
+			t.Fatalf("Validate() = %v, want ErrInvalidMaxHops", err)
+		}
+		if strings.Contains(err.Error(), valid) || strings.Contains(err.Error(), "defaults.") {
+			t.Errorf("Validate() = %q, want no config file in a flag's error", err)
+		}
+	})
+}
+
+func TestLoadConfig_Resolve(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolve.yaml")
+	if err := os.WriteFile(path, []byte("defaults:\n  resolve: ttl\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"from the config file", nil, "ttl"},
+		{"from a flag", []string{"--resolve", "once"}, "once"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parseRootFlags(t, append([]string{"--config", path}, tt.args...)...)
+			if err := loadConfig(rootCmd, nil); err != nil {
+				t.Fatalf("loadConfig() = %v", err)
+			}
+			if resolveMode != tt.want {
+				t.Errorf("resolveMode = %q, want %q", resolveMode, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTMLFlags(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"file", []string{"--html", "report.html", "example.com"}, "report.html"},
+		{"file with =", []string{"--html=report.html", "example.com"}, "report.html"},
+		{"auto", []string{"--html-auto", "example.com"}, "poros-example.com-20240501-123000.html"},
+		{"open", []string{"--open", "example.com"}, "poros-example.com-20240501-123000.html"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parseRootFlags(t, tt.args...)
+			args := rootCmd.Flags().Args()
+			if err := rootCmd.Args(rootCmd, args); err != nil {
+				t.Fatalf("Args(%v) error = %v", args, err)
+			}
+			if len(args) != 1 || args[0] != "example.com" {
+				t.Errorf("args = %v, want [example.com]", args)
+			}
+			if !htmlReport() {
+				t.Error("an HTML report should be requested")
+			}
+			if got := htmlPath("example.com", ts, "html"); got != tt.want {
+				t.Errorf("htmlPath() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("file and auto", func(t *testing.T) {
+		parseRootFlags(t, "--html", "report.html", "--html-auto")
+		err := runTrace(rootCmd, []string{"example.com"})
+		if err == nil || !strings.Contains(err.Error(), "--html-auto cannot be combined with --html") {
+			t.Errorf("runTrace() = %v, want a --html-auto conflict", err)
+		}
+	})
+}
+
+func TestDisplayFlags(t *testing.T) {
+	result := tracetest.Result("example.com").
+		Resolved("93.184.216.34").
+		Hop(1, "192.168.1.1", 1.2).Hostname("router.lan").
+		Hop(2, "93.184.216.34", 12.5).Hostname("edge.example.net").ASN(15133, "Edgecast").
+		With(func(hop *trace.Hop) {
+			hop.Geo = &trace.GeoInfo{Country: "United States", CountryCode: "US", City: "Norwell"}
+		}).
+		Completed(true).
+		Build()
+	format := func(t *testing.T, format output.Format, config output.Config) string {
+		t.Helper()
+		data, err := output.NewFormatter(format, config).Format(result)
+		if err != nil {
+			t.Fatalf("Format(%s) error = %v", format, err)
+		}
+		return string(data)
+	}
+	hidden := []string{"15133", "Edgecast", "Norwell", "edge.example.net"}
+
+	t.Run("hide", func(t *testing.T) {
+		parseRootFlags(t, "--hide-asn", "--hide-geo", "--hide-hostname")
+		traceConfig := trace.DefaultConfig()
+		configureEnrichment(traceConfig)
+		if !traceConfig.EnableASN || !traceConfig.EnableGeoIP || !traceConfig.EnableRDNS {
+			t.Errorf("--hide-* should keep the lookups enabled, got %+v", traceConfig)
+		}
+
+		config := newOutputConfig(nil)
+		config.Colors = false
+		jsonOut := format(t, output.FormatJSON, config)
+		for _, want := range []string{`"number": 15133`, `"city": "Norwell"`, `"hostname": "edge.example.net"`} {
+			if !strings.Contains(jsonOut, want) {
+				t.Errorf("JSON should keep %s:\n%s", want, jsonOut)
+			}
+		}
+		for _, f := range []output.Format{output.FormatVerbose, output.FormatText} {
+			out := format(t, f, config)
+			for _, s := range hidden {
+				if strings.Contains(out, s) {
+					t.Errorf("%s output should hide %q:\n%s", f, s, out)
+				}
+			}
+		}
+	})
+
+	// The --no-* flags still skip the lookup and hide the column
+	t.Run("no-asn", func(t *testing.T) {
+		parseRootFlags(t, "--no-asn")
+		traceConfig := trace.DefaultConfig()
+		configureEnrichment(traceConfig)
+		if traceConfig.EnableASN || !traceConfig.EnableGeoIP {
+			t.Error("--no-asn should disable ASN lookups only")
+		}
+		if table := format(t, output.FormatVerbose, newOutputConfig(nil)); strings.Contains(table, "ORGANIZATION") {
+			t.Errorf("--no-asn table should drop the ASN columns:\n%s", table)
+		}
+	})
+}
+
+func TestCheckAssertions_MaxLossZero(t *testing.T) {
+	result := tracetest.Result("example.com").
+		Resolved("93.184.216.34").
+		Hop(1, "93.184.216.34", 12.5, -1, 12.7).
+		Completed(true).
+		Build()
+
+	parseRootFlags(t)
+	if err := checkAssertions(rootCmd, result, output.Config{}); err != nil {
+		t.Errorf("checkAssertions() without assertion flags = %v, want nil", err)
+	}
+
+	parseRootFlags(t, "--assert-max-loss", "0")
+	if err := checkAssertions(rootCmd, result, output.Config{}); err == nil {
+		t.Error("checkAssertions() with --assert-max-loss 0 should fail on loss at the destination")
+	}
+}
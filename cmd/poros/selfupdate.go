@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/KilimcininKorOglu/poros/internal/update"
+	"github.com/spf13/cobra"
+)
+
+var (
+	selfUpdateCheck bool
+	selfUpdateYes   bool
+)
+
+var selfUpdateCmd = &cobra.Command{
+	Use:   "self-update",
+	Short: "Update poros to the latest release",
+	Long: `Check GitHub for the latest poros release and, if it is newer than this
+binary, replace this binary with it. The archive for this OS and
+architecture is verified against the release's checksums.txt before the
+executable is swapped; on any failure the current binary stays in place.
+Requests go through the proxy set under network in the config file.
+
+  poros self-update --check   Only report whether an update is available
+  poros self-update --yes     Update without asking for confirmation`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		exe, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("cannot locate the running executable: %w", err)
+		}
+		if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+			exe = resolved
+		}
+
+		client := &update.Client{}
+		if cfg != nil {
+			// Validated with the config file
+			client.HTTPClient, _ = cfg.Network.HTTPClient(version)
+		}
+		ctx := cmd.Context()
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		return runSelfUpdate(ctx, client, exe, os.Stdin, os.Stdout)
+	},
+}
+
+func init() {
+	selfUpdateCmd.Flags().BoolVar(&selfUpdateCheck, "check", false, "Only report whether a newer release is available")
+	selfUpdateCmd.Flags().BoolVarP(&selfUpdateYes, "yes", "y", false, "Replace the binary without asking for confirmation")
+}
+
+// runSelfUpdate updates the executable at exe to the latest release of
+// client, asking on in for confirmation unless --yes is set.
+func runSelfUpdate(ctx context.Context, client *update.Client, exe string, in io.Reader, out io.Writer) error {
+	update.RemoveOld(exe)
+
+	release, err := client.Latest(ctx)
+	if err != nil {
+		return err
+	}
+	if !update.Newer(release.Version, version) {
+		fmt.Fprintf(out, "poros %s is up to date (latest release %s)\n", version, release.Version)
+		return nil
+	}
+
+	fmt.Fprintf(out, "Update available: %s -> %s\n", version, release.Version)
+	if selfUpdateCheck {
+		return nil
+	}
+
+	if !selfUpdateYes {
+		fmt.Fprintf(out, "Replace %s? [y/N] ", exe)
+		answer, _ := bufio.NewReader(in).ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+		default:
+			fmt.Fprintln(out, "Update cancelled")
+			return nil
+		}
+	}
+
+	if err := client.Install(ctx, release, runtime.GOOS, runtime.GOARCH, exe); err != nil {
+		return fmt.Errorf("update failed, %s is unchanged: %w", exe, err)
+	}
+	fmt.Fprintf(out, "Updated %s to %s\n", exe, release.Version)
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/KilimcininKorOglu/poros/internal/update"
+)
+
+// releaseArchive packs binary as the release archive for this platform.
+func releaseArchive(t *testing.T, binary []byte) []byte {
+	t.Helper()
+	name := update.BinaryName(runtime.GOOS, runtime.GOARCH)
+	var buf bytes.Buffer
+	if runtime.GOOS == "windows" {
+		zw := zip.NewWriter(&buf)
+		w, _ := zw.Create(name)
+		w.Write(binary)
+		zw.Close()
+		return buf.Bytes()
+	}
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(binary)), Typeflag: tar.TypeReg})
+	tw.Write(binary)
+	tw.Close()
+	gzw.Close()
+	return buf.Bytes()
+}
+
+// startReleaseServer serves release v2.0.0 with an archive of binary for
+// this platform and its checksum file, counting archive downloads.
+func startReleaseServer(t *testing.T, binary []byte) (*update.Client, *int) {
+	t.Helper()
+	archiveName := update.AssetName(runtime.GOOS, runtime.GOARCH)
+	archive := releaseArchive(t, binary)
+	sum := sha256.Sum256(archive)
+	files := map[string][]byte{
+		archiveName:           archive,
+		update.ChecksumsAsset: []byte(hex.EncodeToString(sum[:]) + "  " + archiveName + "\n"),
+	}
+
+	downloads := 0
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/"+update.Repo+"/releases/latest" {
+			release := update.Release{Version: "v2.0.0"}
+			for name := range files {
+				release.Assets = append(release.Assets, update.Asset{Name: name, URL: server.URL + "/download/" + name})
+			}
+			json.NewEncoder(w).Encode(release)
+			return
+		}
+		name := filepath.Base(r.URL.Path)
+		if name == archiveName {
+			downloads++
+		}
+		w.Write(files[name])
+	}))
+	t.Cleanup(server.Close)
+	return &update.Client{APIURL: server.URL}, &downloads
+}
+
+func TestRunSelfUpdate(t *testing.T) {
+	tests := []struct {
+		name      string
+		version   string
+		check     bool
+		yes       bool
+		input     string
+		want      string // in the output
+		updated   bool
+		downloads int
+	}{
+		{name: "up to date", version: "v2.0.0", want: "is up to date"},
+		{name: "check only", version: "v1.0.0", check: true, want: "Update available: v1.0.0 -> v2.0.0"},
+		{name: "declined", version: "v1.0.0", input: "n\n", want: "Update cancelled"},
+		{name: "confirmed", version: "v1.0.0", input: "y\n", want: "Updated", updated: true, downloads: 1},
+		{name: "yes", version: "dev", yes: true, want: "Updated", updated: true, downloads: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldVersion := version
+			version, selfUpdateCheck, selfUpdateYes = tt.version, tt.check, tt.yes
+			t.Cleanup(func() {
+				version, selfUpdateCheck, selfUpdateYes = oldVersion, false, false
+			})
+
+			client, downloads := startReleaseServer(t, []byte("new binary"))
+			exe := filepath.Join(t.TempDir(), "poros")
+			os.WriteFile(exe, []byte("old binary"), 0755)
+
+			var out bytes.Buffer
+			if err := runSelfUpdate(context.Background(), client, exe, strings.NewReader(tt.input), &out); err != nil {
+				t.Fatalf("runSelfUpdate() error = %v", err)
+			}
+			if !strings.Contains(out.String(), tt.want) {
+				t.Errorf("output = %q, want %q", out.String(), tt.want)
+			}
+
+			got, _ := os.ReadFile(exe)
+			if updated := string(got) == "new binary"; updated != tt.updated {
+				t.Errorf("executable holds %q, updated = %v, want %v", got, updated, tt.updated)
+			}
+			if *downloads != tt.downloads {
+				t.Errorf("%d archive downloads, want %d", *downloads, tt.downloads)
+			}
+		})
+	}
+}
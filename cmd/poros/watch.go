@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/KilimcininKorOglu/poros/internal/output"
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+	"github.com/spf13/cobra"
+)
+
+var mtrCmd = &cobra.Command{
+	Use:   "mtr [flags] <target>",
+	Short: "Trace continuously and accumulate per-hop statistics, like mtr",
+	Long: `Probe the path to target in rounds until interrupted, like mtr, and
+accumulate the statistics of every hop over all rounds: probes sent and
+received, loss, last, average, best and worst RTT and standard deviation.
+
+This is the same as poros --watch and takes the same flags. On a terminal
+the table is redrawn after every round; press Ctrl+C to stop and print
+the final report in the selected format (text, --verbose, --json, --csv).
+
+  poros mtr google.com                 Redraw the table every second
+  poros mtr --interval 5s google.com   Pause 5 seconds between rounds
+  poros mtr --tui google.com           Watch in the interactive TUI`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		watchMode = true
+		return runTrace(cmd, args)
+	},
+}
+
+// checkWatchFlags rejects flags that cannot be combined with --watch.
+func checkWatchFlags(cmd *cobra.Command) error {
+	if !watchMode {
+		return nil
+	}
+	for _, flag := range []string{"multi-method", "scan-cidr"} {
+		if cmd.Flags().Changed(flag) {
+			return fmt.Errorf("--watch cannot be combined with --%s", flag)
+		}
+	}
+	if watchEvery <= 0 {
+		return fmt.Errorf("invalid --interval %s: must be positive", watchEvery)
+	}
+	return nil
+}
+
+// chainRound returns an OnRound callback calling first, if set, and then
+// next.
+func chainRound(first, next func(trace.Round)) func(trace.Round) {
+	if first == nil {
+		return next
+	}
+	return func(round trace.Round) {
+		first(round)
+		next(round)
+	}
+}
+
+// watchTrace traces target in --watch rounds on a trace.Session until
+// ctx is done and returns their total. The OnProgress and OnRound
+// callbacks of config report through the session.
+func watchTrace(ctx context.Context, config *trace.Config, target string) (*trace.TraceResult, error) {
+	session, err := trace.NewSession(config, 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tracer: %w", err)
+	}
+	defer session.Close()
+
+	if onProgress := config.OnProgress; onProgress != nil {
+		session.OnProgress(func(_ string, p trace.Progress) { onProgress(p) })
+	}
+	if onRound := config.OnRound; onRound != nil {
+		session.OnRound(func(_ string, round trace.Round) { onRound(round) })
+	}
+	var result *trace.TraceResult
+	done := make(chan struct{})
+	session.OnComplete(func(_ string, total *trace.TraceResult) {
+		result = total
+		close(done)
+	})
+	session.OnError(func(_ string, traceErr error) {
+		err = traceErr
+		close(done)
+	})
+
+	if _, err := session.StartLoop(ctx, target, watchEvery); err != nil {
+		return nil, fmt.Errorf("failed to create tracer: %w", err)
+	}
+	<-done
+	return result, err
+}
+
+// watchResolvePolicy returns the --resolve policy. Unless it is set, a
+// --watch trace keeps the address its target first resolved to, as mtr
+// does, so every round probes the same path.
+func watchResolvePolicy() (trace.ResolvePolicy, error) {
+	if resolveMode == "" && watchMode {
+		return trace.ResolveOnce, nil
+	}
+	policy, err := trace.ParseResolvePolicy(resolveMode)
+	if err != nil {
+		return policy, fmt.Errorf("invalid --resolve: %w", err)
+	}
+	return policy, nil
+}
+
+// clearScreen moves the cursor home and clears the terminal.
+const clearScreen = "\x1b[H\x1b[2J"
+
+// watchView redraws the total of the --watch rounds in place, as the
+// verbose table, on an interactive terminal.
+type watchView struct {
+	out   io.Writer
+	table *output.Writer
+}
+
+// newWatchView returns the live view for a --watch trace written by
+// writer, or nil if stdout is not a terminal or the selected format is
+// machine-readable; only the final report is written then.
+func newWatchView(writer *output.Writer, format output.Format, config output.Config) *watchView {
+	if !writer.IsTTY() || format == output.FormatJSON || format == output.FormatCSV {
+		return nil
+	}
+	return &watchView{
+		out:   os.Stdout,
+		table: output.NewWriter(output.FormatVerbose, config),
+	}
+}
+
+// Round redraws the view with the total of the rounds so far.
+func (v *watchView) Round(round trace.Round) {
+	fmt.Fprint(v.out, clearScreen)
+	v.table.Write(round.Total)
+	fmt.Fprintf(v.out, "\nRound %d, press Ctrl+C to stop\n", round.Number)
+}
+
+// Clear clears the view before the final report is written.
+func (v *watchView) Clear() {
+	fmt.Fprint(v.out, clearScreen)
+}
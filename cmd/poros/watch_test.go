@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+)
+
+func TestMtrCmd_SharesTraceFlags(t *testing.T) {
+	parseRootFlags(t)
+	if err := mtrCmd.ParseFlags([]string{"--interval", "5s", "-m", "12", "--json"}); err != nil {
+		t.Fatalf("ParseFlags() error = %v", err)
+	}
+	if watchEvery != 5*time.Second || maxHops != 12 || !jsonOutput {
+		t.Errorf("interval %s, max hops %d, json %v; want the root flags set", watchEvery, maxHops, jsonOutput)
+	}
+	if !mtrCmd.Flags().Changed("max-hops") {
+		t.Error("a flag set on poros mtr should count as changed for the config defaults")
+	}
+}
+
+func TestCheckWatchFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"multi-method", []string{"--watch", "--multi-method", "icmp,udp"}, "--watch cannot be combined with --multi-method"},
+		{"interval", []string{"--watch", "--interval", "0s"}, "invalid --interval 0s"},
+		{"scan-cidr", []string{"--watch", "--scan-cidr", "2"}, "--watch cannot be combined with --scan-cidr"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parseRootFlags(t, tt.args...)
+			err := checkWatchFlags(rootCmd)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("checkWatchFlags() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestWatchResolvePolicy(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want trace.ResolvePolicy
+	}{
+		{"single trace", nil, trace.ResolveEveryCycle},
+		{"watch", []string{"--watch"}, trace.ResolveOnce},
+		{"watch every", []string{"--watch", "--resolve", "every"}, trace.ResolveEveryCycle},
+		{"watch ttl", []string{"--watch", "--resolve", "ttl"}, trace.ResolveTTL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parseRootFlags(t, tt.args...)
+			got, err := watchResolvePolicy()
+			if err != nil || got != tt.want {
+				t.Errorf("watchResolvePolicy() = %v, %v; want %v", got, err, tt.want)
+			}
+		})
+	}
+
+	parseRootFlags(t, "--resolve", "sometimes")
+	if _, err := watchResolvePolicy(); !errors.Is(err, trace.ErrUnknownResolvePolicy) {
+		t.Errorf("watchResolvePolicy() error = %v, want ErrUnknownResolvePolicy", err)
+	}
+}
+
+func TestChainRound(t *testing.T) {
+	var calls []string
+	first := func(trace.Round) { calls = append(calls, "first") }
+	next := func(trace.Round) { calls = append(calls, "next") }
+
+	chainRound(first, next)(trace.Round{})
+	chainRound(nil, next)(trace.Round{})
+	if got := strings.Join(calls, ","); got != "first,next,next" {
+		t.Errorf("calls = %s, want first,next,next", got)
+	}
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MaxAliasDepth is the longest alias chain that is followed.
+const MaxAliasDepth = 8
+
+// ErrAliasCycle indicates aliases that refer back to themselves.
+var ErrAliasCycle = errors.New("alias cycle")
+
+// ErrAliasTooDeep indicates an alias chain longer than MaxAliasDepth.
+var ErrAliasTooDeep = errors.New("alias chain too deep")
+
+// ResolveAlias follows aliases from name until it reaches a target that is
+// not an alias. It returns the whole chain, name first and the final target
+// last; a name that is not an alias yields a chain of one. Alias names are
+// case sensitive.
+func (c *Config) ResolveAlias(name string) ([]string, error) {
+	chain := []string{name}
+	if c == nil {
+		return chain, nil
+	}
+
+	seen := map[string]bool{name: true}
+	for {
+		next, ok := c.Aliases[chain[len(chain)-1]]
+		if !ok {
+			return chain, nil
+		}
+		chain = append(chain, next)
+		if seen[next] {
+			return nil, fmt.Errorf("%w: %s", ErrAliasCycle, FormatAliasChain(chain))
+		}
+		if len(chain) > MaxAliasDepth+1 {
+			return nil, fmt.Errorf("%w: %s resolves through more than %d aliases", ErrAliasTooDeep, name, MaxAliasDepth)
+		}
+		seen[next] = true
+	}
+}
+
+// AliasNames returns the configured alias names in sorted order.
+func (c *Config) AliasNames() []string {
+	if c == nil {
+		return nil
+	}
+	names := make([]string, 0, len(c.Aliases))
+	for name := range c.Aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FormatAliasChain joins an alias chain for display, e.g.
+// "prod → prod-eu → 203.0.113.7".
+func FormatAliasChain(chain []string) string {
+	return strings.Join(chain, " → ")
+}
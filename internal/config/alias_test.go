@@ -0,0 +1,96 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestResolveAlias(t *testing.T) {
+	cfg := &Config{Aliases: map[string]string{
+		"prod":    "prod-eu",
+		"prod-eu": "203.0.113.7",
+		"dns":     "8.8.8.8",
+		"a":       "b",
+		"b":       "a",
+		"self":    "self",
+		"Prod":    "198.51.100.1",
+	}}
+
+	tests := []struct {
+		name    string
+		want    []string
+		wantErr error
+	}{
+		{"prod", []string{"prod", "prod-eu", "203.0.113.7"}, nil},
+		{"dns", []string{"dns", "8.8.8.8"}, nil},
+		{"example.com", []string{"example.com"}, nil},
+		{"Prod", []string{"Prod", "198.51.100.1"}, nil},
+		{"PROD", []string{"PROD"}, nil},
+		{"a", nil, ErrAliasCycle},
+		{"self", nil, ErrAliasCycle},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cfg.ResolveAlias(tt.name)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ResolveAlias(%q) error = %v, want %v", tt.name, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ResolveAlias(%q) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveAlias_CycleMessage(t *testing.T) {
+	cfg := &Config{Aliases: map[string]string{"a": "b", "b": "a"}}
+
+	_, err := cfg.ResolveAlias("a")
+	if err == nil || err.Error() != "alias cycle: a → b → a" {
+		t.Errorf("ResolveAlias() error = %v, want alias cycle: a → b → a", err)
+	}
+}
+
+func TestResolveAlias_Depth(t *testing.T) {
+	aliases := make(map[string]string)
+	for i := 0; i < MaxAliasDepth; i++ {
+		aliases[fmt.Sprintf("a%d", i)] = fmt.Sprintf("a%d", i+1)
+	}
+	cfg := &Config{Aliases: aliases}
+
+	chain, err := cfg.ResolveAlias("a0")
+	if err != nil {
+		t.Fatalf("ResolveAlias() error = %v at the depth limit", err)
+	}
+	if len(chain) != MaxAliasDepth+1 || chain[len(chain)-1] != fmt.Sprintf("a%d", MaxAliasDepth) {
+		t.Errorf("ResolveAlias() = %v", chain)
+	}
+
+	aliases[fmt.Sprintf("a%d", MaxAliasDepth)] = "end"
+	if _, err := cfg.ResolveAlias("a0"); !errors.Is(err, ErrAliasTooDeep) {
+		t.Errorf("ResolveAlias() error = %v, want ErrAliasTooDeep", err)
+	}
+}
+
+func TestResolveAlias_NilConfig(t *testing.T) {
+	var cfg *Config
+
+	chain, err := cfg.ResolveAlias("example.com")
+	if err != nil || !reflect.DeepEqual(chain, []string{"example.com"}) {
+		t.Errorf("ResolveAlias() = %v, %v", chain, err)
+	}
+	if names := cfg.AliasNames(); names != nil {
+		t.Errorf("AliasNames() = %v, want nil", names)
+	}
+}
+
+func TestAliasNames(t *testing.T) {
+	cfg := &Config{Aliases: map[string]string{"b": "1", "a": "2", "C": "3"}}
+
+	if got, want := cfg.AliasNames(), []string{"C", "a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AliasNames() = %v, want %v", got, want)
+	}
+}
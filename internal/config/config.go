@@ -6,94 +6,300 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"time"
 
+	"github.com/KilimcininKorOglu/poros/internal/enrich"
+	"github.com/KilimcininKorOglu/poros/internal/trace"
 	"gopkg.in/yaml.v3"
 )
 
 // Config represents the Poros configuration file structure.
 type Config struct {
+	// Include names a base config file that this file overrides. A
+	// relative path is relative to the including file.
+	Include string `yaml:"include,omitempty"`
+
+	// Sources lists the files merged into this configuration, in the
+	// order they were applied (later files override earlier ones)
+	Sources []string `yaml:"-"`
+
+	// origins maps each key set under defaults, e.g. "max_hops", to the
+	// file that set it last, for errors that point at the right file
+	origins map[string]string
+
 	// Defaults are applied when flags are not specified
 	Defaults Defaults `yaml:"defaults"`
 
 	// MaxMind GeoLite2 database settings
 	MaxMind MaxMindConfig `yaml:"maxmind"`
 
+	// Outbound network settings of enrichment and database downloads
+	Network NetworkConfig `yaml:"network"`
+
 	// Aliases for common targets
 	Aliases map[string]string `yaml:"aliases,omitempty"`
 }
 
-// Defaults holds default values for trace parameters.
+// Defaults holds default values for trace parameters. Pointer fields are
+// nil when no config file sets the key, so an explicit false or 0 can be
+// told apart from an omitted key; use Or or PositiveOr to read them.
 type Defaults struct {
 	// Output mode
-	TUI     bool `yaml:"tui"`
-	Verbose bool `yaml:"verbose"`
-	JSON    bool `yaml:"json"`
-	CSV     bool `yaml:"csv"`
-	NoColor bool `yaml:"no_color"`
+	TUI     *bool `yaml:"tui,omitempty"`
+	Verbose *bool `yaml:"verbose,omitempty"`
+	JSON    *bool `yaml:"json,omitempty"`
+	CSV     *bool `yaml:"csv,omitempty"`
+	NoColor *bool `yaml:"no_color,omitempty"`
+
+	// Show AS country and announced prefix in text output
+	ASNDetail *bool `yaml:"asn_detail,omitempty"`
+
+	// Show full country names instead of ISO codes in table and TUI locations
+	CountryNames *bool `yaml:"country_names,omitempty"`
+
+	// Leave collected ASN, GeoIP and hostname data out of text, table and
+	// TUI output; JSON, CSV and HTML keep it
+	HideASN      *bool `yaml:"hide_asn,omitempty"`
+	HideGeo      *bool `yaml:"hide_geo,omitempty"`
+	HideHostname *bool `yaml:"hide_hostname,omitempty"`
+
+	// Show runs of unresponsive hops as one line in text and table output
+	CollapseTimeouts *bool `yaml:"collapse_timeouts,omitempty"`
+
+	// RTT coloring thresholds in milliseconds
+	RTTWarn *float64 `yaml:"rtt_warn,omitempty"`
+	RTTCrit *float64 `yaml:"rtt_crit,omitempty"`
+
+	// Packet loss coloring thresholds in percent
+	LossWarn *float64 `yaml:"loss_warn,omitempty"`
+	LossCrit *float64 `yaml:"loss_crit,omitempty"`
+
+	// Timestamp format (rfc3339, unix, local or Go layout) and UTC conversion
+	TimeFormat string `yaml:"time_format"`
+	UTC        *bool  `yaml:"utc,omitempty"`
+
+	// RTT unit (ms, us, auto) and number locale of human-readable output
+	Units  string `yaml:"units"`
+	Locale string `yaml:"locale"`
+
+	// Verbose table columns, comma-separated (empty = default set)
+	Columns string `yaml:"columns"`
+
+	// HTML report template file (empty = built-in report)
+	HTMLTemplate string `yaml:"html_template"`
 
 	// Probe method: icmp, udp, tcp, paris
 	ProbeMethod string `yaml:"probe_method"`
-	Paris       bool   `yaml:"paris"`
+	Paris       *bool  `yaml:"paris,omitempty"`
 
-	// Trace parameters
-	MaxHops    int           `yaml:"max_hops"`
-	Queries    int           `yaml:"queries"`
-	Timeout    time.Duration `yaml:"timeout"`
-	FirstHop   int           `yaml:"first_hop"`
-	Sequential bool          `yaml:"sequential"`
+	// Trace parameters (0 = built-in default)
+	MaxHops    *int           `yaml:"max_hops,omitempty"`
+	Queries    *int           `yaml:"queries,omitempty"`
+	Timeout    *time.Duration `yaml:"timeout,omitempty"`
+	FirstHop   *int           `yaml:"first_hop,omitempty"`
+	Sequential *bool          `yaml:"sequential,omitempty"`
+
+	// Send the probes of a hop at once in sequential mode (not ICMP)
+	ParallelQueries *bool `yaml:"parallel_queries,omitempty"`
+
+	// Maximum probes in flight in concurrent mode (1-512)
+	Concurrency *int `yaml:"concurrency,omitempty"`
+
+	// Probes for the destination hop (0 = same as queries)
+	DestQueries *int `yaml:"dest_queries,omitempty"`
+
+	// Outlier handling of hop RTT statistics: none, winsorize or drop
+	RTTOutlier string `yaml:"rtt_outlier"`
+
+	// Extra end-host probes sent after the trace (0 = disabled)
+	VerifyDest *int `yaml:"verify_dest,omitempty"`
+
+	// Probe packets sent per trace at most (0 = unlimited)
+	MaxPackets *int `yaml:"max_packets,omitempty"`
+
+	// Fast-forward through leading private/CGNAT hops (VPN, CGNAT)
+	SkipPrivatePrefix *bool `yaml:"skip_private_prefix,omitempty"`
 
 	// Network
-	IPv4 bool `yaml:"ipv4"`
-	IPv6 bool `yaml:"ipv6"`
-	Port int  `yaml:"port"`
+	IPv4 *bool `yaml:"ipv4,omitempty"`
+	IPv6 *bool `yaml:"ipv6,omitempty"`
+	Port *int  `yaml:"port,omitempty"`
+
+	// NAT64 prefix for IPv4 targets on IPv6-only networks ("" = discover)
+	NAT64Prefix string `yaml:"nat64_prefix"`
+
+	// When to re-resolve a hostname target across --watch rounds: once,
+	// every or ttl ("" = once)
+	Resolve string `yaml:"resolve"`
+
+	// Tags recorded in every result; --tag values override the same key
+	Tags map[string]string `yaml:"tags,omitempty"`
 
 	// Enrichment
 	Enrichment EnrichmentConfig `yaml:"enrichment"`
 }
 
-// EnrichmentConfig holds enrichment settings.
+// EnrichmentConfig holds enrichment settings. Unset switches are enabled.
 type EnrichmentConfig struct {
-	Enabled bool `yaml:"enabled"`
-	RDNS    bool `yaml:"rdns"`
-	ASN     bool `yaml:"asn"`
-	GeoIP   bool `yaml:"geoip"`
+	Enabled *bool `yaml:"enabled,omitempty"`
+	RDNS    *bool `yaml:"rdns,omitempty"`
+	ASN     *bool `yaml:"asn,omitempty"`
+	GeoIP   *bool `yaml:"geoip,omitempty"`
+
+	// RDNSVerify forward-confirms PTR names, flagging mismatches; unlike
+	// the switches above it is off unless set
+	RDNSVerify *bool `yaml:"rdns_verify,omitempty"`
+
+	// Per-lookup timeouts and entries per provider cache (0 = built-in default)
+	RDNSTimeout  *time.Duration `yaml:"rdns_timeout,omitempty"`
+	ASNTimeout   *time.Duration `yaml:"asn_timeout,omitempty"`
+	GeoIPTimeout *time.Duration `yaml:"geoip_timeout,omitempty"`
+	CacheSize    *int           `yaml:"cache_size,omitempty"`
+
+	// ip-api.com pro key (switches to the HTTPS endpoint) and endpoint
+	// prefix of an ip-api.com compatible service
+	IPAPIKey string `yaml:"ipapi_key"`
+	IPAPIURL string `yaml:"ipapi_url"`
+}
+
+// Built-in values for trace parameters no flag or config file sets.
+const (
+	DefaultMaxHops     = 30
+	DefaultQueries     = 3
+	DefaultTimeout     = 3 * time.Second
+	DefaultFirstHop    = 1
+	DefaultConcurrency = 30
+)
+
+// Or returns the value p points to, or def if the key was not set.
+func Or[T any](p *T, def T) T {
+	if p == nil {
+		return def
+	}
+	return *p
+}
+
+// PositiveOr is like Or but also returns def for values of 0 or less,
+// for keys where 0 means "use the built-in default".
+func PositiveOr[T int | time.Duration](p *T, def T) T {
+	if p == nil || *p <= 0 {
+		return def
+	}
+	return *p
+}
+
+// ApplyDefault sets *dst to the config value unless the flag was given on
+// the command line or the key was not set in any config file. It reports
+// whether *dst was changed.
+func ApplyDefault[T any](dst *T, value *T, flagChanged bool) bool {
+	if flagChanged || value == nil {
+		return false
+	}
+	*dst = *value
+	return true
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// NetworkConfig holds settings for the HTTP requests poros makes itself:
+// ip-api.com lookups and MaxMind database downloads.
+type NetworkConfig struct {
+	// HTTPProxy is the proxy URL for those requests, e.g.
+	// http://proxy.example.com:3128 (empty = HTTP_PROXY, HTTPS_PROXY and
+	// NO_PROXY from the environment)
+	HTTPProxy string `yaml:"http_proxy"`
+}
+
+// HTTPClient returns the factory of HTTP clients for these settings,
+// identifying poros at version in the User-Agent header.
+func (n NetworkConfig) HTTPClient(version string) (*enrich.HTTPClientFactory, error) {
+	factory := &enrich.HTTPClientFactory{UserAgent: enrich.UserAgent(version)}
+	if n.HTTPProxy != "" {
+		proxy, err := enrich.ParseProxyURL(n.HTTPProxy)
+		if err != nil {
+			return nil, fmt.Errorf("network.http_proxy: %w", err)
+		}
+		factory.Proxy = proxy
+	}
+	return factory, nil
 }
 
 // MaxMindConfig holds MaxMind GeoLite2 database settings.
 type MaxMindConfig struct {
 	Enabled     bool   `yaml:"enabled"`      // Enable MaxMind databases
-	LicenseKey  string `yaml:"license_key"`  // MaxMind license key (free registration)
+	LicenseKey  string `yaml:"license_key"`  // MaxMind license key (empty = use existing files only)
 	UpdateHours int    `yaml:"update_hours"` // Auto-update interval in hours (0 = no auto-update)
+	DBDir       string `yaml:"db_dir"`       // Directory holding the .mmdb files (default: config directory)
+	ASNDB       string `yaml:"asn_db"`       // Explicit GeoLite2-ASN path (overrides db_dir)
+	CityDB      string `yaml:"city_db"`      // Explicit GeoLite2-City path (overrides db_dir)
+}
+
+// Configured reports whether MaxMind databases should be used: enabled
+// explicitly, or database files pointed at.
+func (m MaxMindConfig) Configured() bool {
+	return m.Enabled || m.DBDir != "" || m.ASNDB != "" || m.CityDB != ""
+}
+
+// ASNDBPath returns the GeoLite2-ASN database path.
+func (m MaxMindConfig) ASNDBPath() string {
+	return m.dbPath(m.ASNDB, asnDBName)
+}
+
+// CityDBPath returns the GeoLite2-City database path.
+func (m MaxMindConfig) CityDBPath() string {
+	return m.dbPath(m.CityDB, cityDBName)
 }
 
-// DefaultConfig returns a Config with default values.
+func (m MaxMindConfig) dbPath(explicit, name string) string {
+	if explicit != "" {
+		return explicit
+	}
+	if m.DBDir != "" {
+		return filepath.Join(m.DBDir, name)
+	}
+	return GetMaxMindDBPath(name)
+}
+
+// DefaultConfig returns a Config with every default set explicitly, as
+// written by `poros config --init`.
 func DefaultConfig() *Config {
-	return &Config{
-		Defaults: Defaults{
-			TUI:         false,
-			Verbose:     false,
-			JSON:        false,
-			CSV:         false,
-			NoColor:     false,
-			ProbeMethod: "icmp",
-			Paris:       false,
-			MaxHops:     30,
-			Queries:     3,
-			Timeout:     3 * time.Second,
-			FirstHop:    1,
-			Sequential:  false,
-			IPv4:        false,
-			IPv6:        false,
-			Port:        0, // 0 means use default for probe method
-			Enrichment: EnrichmentConfig{
-				Enabled: true,
-				RDNS:    true,
-				ASN:     true,
-				GeoIP:   true,
-			},
+	config := emptyConfig()
+	config.Defaults = Defaults{
+		TUI:         ptr(false),
+		Verbose:     ptr(false),
+		JSON:        ptr(false),
+		CSV:         ptr(false),
+		NoColor:     ptr(false),
+		ProbeMethod: "icmp",
+		Paris:       ptr(false),
+		MaxHops:     ptr(DefaultMaxHops),
+		Queries:     ptr(DefaultQueries),
+		Timeout:     ptr(DefaultTimeout),
+		FirstHop:    ptr(DefaultFirstHop),
+		Sequential:  ptr(false),
+		Concurrency: ptr(DefaultConcurrency),
+		IPv4:        ptr(false),
+		IPv6:        ptr(false),
+		Port:        ptr(0), // 0 means use default for probe method
+		Enrichment: EnrichmentConfig{
+			Enabled: ptr(true),
+			RDNS:    ptr(true),
+			ASN:     ptr(true),
+			GeoIP:   ptr(true),
 		},
+	}
+	return config
+}
+
+// emptyConfig returns a Config with no defaults set, the base that config
+// files are merged onto.
+func emptyConfig() *Config {
+	return &Config{
 		Aliases: make(map[string]string),
+		origins: make(map[string]string),
 		MaxMind: MaxMindConfig{
 			Enabled:     false,
 			LicenseKey:  "",
@@ -105,6 +311,9 @@ func DefaultConfig() *Config {
 // ErrConfigNotFound is returned when no config file exists.
 var ErrConfigNotFound = fmt.Errorf("config file not found")
 
+// ErrIncludeCycle is returned when config files include each other.
+var ErrIncludeCycle = fmt.Errorf("config include cycle")
+
 // Load reads configuration from the default config file locations.
 // It searches in order:
 //  1. ./poros.yaml (current directory)
@@ -125,19 +334,111 @@ func Load() (*Config, error) {
 	return nil, ErrConfigNotFound
 }
 
-// LoadFrom reads configuration from a specific file path.
+// LoadFrom reads configuration from a specific file path, merged over the
+// file it includes, if any.
 func LoadFrom(path string) (*Config, error) {
+	return LoadFiles(path)
+}
+
+// LoadFiles reads and merges several configuration files; later files
+// override earlier ones. A file's include is merged just before the file
+// itself, and a file already merged is not applied again.
+//
+// Each file only overrides the keys it sets, so an explicit "false" wins
+// over an earlier "true" while an absent key keeps the earlier value.
+// Maps such as aliases are merged key by key.
+func LoadFiles(paths ...string) (*Config, error) {
+	config := emptyConfig()
+	for _, path := range paths {
+		if err := config.merge(path, nil); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// merge applies one file, after the files it includes. including holds
+// the files whose includes are being resolved, to detect cycles.
+func (c *Config) merge(path string, including []string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	for i, p := range including {
+		if p == abs {
+			chain := append(append([]string(nil), including[i:]...), abs)
+			return fmt.Errorf("%w: %s", ErrIncludeCycle, strings.Join(chain, " -> "))
+		}
+	}
+	for _, p := range c.Sources {
+		if p == abs {
+			return nil
+		}
+	}
+
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	config := DefaultConfig()
-	if err := yaml.Unmarshal(data, config); err != nil {
-		return nil, err
+	var head struct {
+		Include string `yaml:"include"`
+	}
+	if err := yaml.Unmarshal(data, &head); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if head.Include != "" {
+		include := head.Include
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(abs), include)
+		}
+		if err := c.merge(include, append(including, abs)); err != nil {
+			return fmt.Errorf("%s: include: %w", path, err)
+		}
 	}
 
-	return config, nil
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	var keys struct {
+		Defaults map[string]yaml.Node `yaml:"defaults"`
+	}
+	if yaml.Unmarshal(data, &keys) == nil {
+		for key := range keys.Defaults {
+			c.origins[key] = abs
+		}
+	}
+	c.Include = ""
+	c.Sources = append(c.Sources, abs)
+	return nil
+}
+
+// Validate checks values that Load cannot check while parsing, such as
+// the probe method name and the ranges of trace parameters. Errors name
+// the key and the file that set it.
+func (c *Config) Validate() error {
+	if c.Defaults.ProbeMethod != "" {
+		if _, err := trace.ParseProbeMethod(c.Defaults.ProbeMethod); err != nil {
+			return fmt.Errorf("%s: %w", c.keyName("probe_method"), err)
+		}
+	}
+	if c.Defaults.RTTOutlier != "" {
+		if _, err := trace.ParseOutlierPolicy(c.Defaults.RTTOutlier); err != nil {
+			return fmt.Errorf("%s: %w", c.keyName("rtt_outlier"), err)
+		}
+	}
+	if c.Defaults.Resolve != "" {
+		if _, err := trace.ParseResolvePolicy(c.Defaults.Resolve); err != nil {
+			return fmt.Errorf("%s: %w", c.keyName("resolve"), err)
+		}
+	}
+	if _, err := c.checkTraceParams(false); err != nil {
+		return err
+	}
+	if _, err := c.Network.HTTPClient(""); err != nil {
+		return err
+	}
+	return nil
 }
 
 // Save writes the configuration to the default user config path.
@@ -237,14 +538,38 @@ func GetMaxMindDBPath(dbName string) string {
 	return filepath.Join(dir, dbName)
 }
 
+// FirstNotice reports whether the one-time notice called name is due and
+// records it as shown, so it is not printed on later runs. Without a
+// writable config directory the notice is always due.
+func FirstNotice(name string) bool {
+	dir := GetConfigDir()
+	if dir == "" {
+		return true
+	}
+	marker := filepath.Join(dir, ".notice-"+name)
+	if _, err := os.Stat(marker); err == nil {
+		return false
+	}
+	if err := os.MkdirAll(dir, 0755); err == nil {
+		_ = os.WriteFile(marker, nil, 0644)
+	}
+	return true
+}
+
+// Default MaxMind database file names.
+const (
+	asnDBName  = "GeoLite2-ASN.mmdb"
+	cityDBName = "GeoLite2-City.mmdb"
+)
+
 // GetASNDBPath returns the path for the GeoLite2-ASN database.
 func GetASNDBPath() string {
-	return GetMaxMindDBPath("GeoLite2-ASN.mmdb")
+	return GetMaxMindDBPath(asnDBName)
 }
 
 // GetGeoDBPath returns the path for the GeoLite2-City database.
 func GetGeoDBPath() string {
-	return GetMaxMindDBPath("GeoLite2-City.mmdb")
+	return GetMaxMindDBPath(cityDBName)
 }
 
 // GenerateExample generates an example configuration file content.
@@ -254,6 +579,12 @@ func GenerateExample() string {
 #           %APPDATA%\poros\config.yaml (Windows)
 #           ./poros.yaml (current directory)
 
+# Optional base config that this file overrides key by key (aliases are
+# merged). Relative paths are relative to this file.
+# include: /etc/poros/base.yaml
+
+# Keys left out use the built-in default; an explicit value, even false or
+# 0, overrides any included file.
 defaults:
   # Output mode (only one should be true)
   tui: false              # Interactive TUI mode
@@ -261,6 +592,22 @@ defaults:
   json: false             # JSON output
   csv: false              # CSV output
   no_color: false         # Disable colors
+  asn_detail: false       # Show AS country and prefix in text output
+  country_names: false    # Full country names in table and TUI locations
+  hide_asn: false         # Collect ASN data but leave it out of text/table/TUI
+  hide_geo: false         # Collect GeoIP data but leave it out of table/TUI
+  hide_hostname: false    # Resolve hostnames but leave them out of text/table/TUI
+  collapse_timeouts: false # One line per run of 3+ unresponsive hops
+  rtt_warn: 50            # RTT (ms) shown as warning
+  rtt_crit: 150           # RTT (ms) shown as critical
+  loss_warn: 0            # Loss (%) above which hops are shown as warning
+  loss_crit: 10           # Loss (%) above which hops are shown as critical
+  time_format: ""         # rfc3339, unix, local or Go layout (empty = default)
+  utc: false              # Show timestamps in UTC
+  units: ""               # RTT unit: ms, us or auto (empty = ms)
+  locale: ""              # Decimal separator locale, e.g. de-DE (empty = dot)
+  columns: ""             # Verbose table columns, e.g. hop,ip,asn,last,avg,loss
+  html_template: ""       # Custom HTML report template (empty = built-in)
 
   # Probe method: icmp, udp, tcp
   probe_method: icmp
@@ -269,33 +616,62 @@ defaults:
   # Trace parameters
   max_hops: 30            # Maximum number of hops
   queries: 3              # Probes per hop
+  dest_queries: 0         # Probes for the destination hop (0 = same as queries)
+  rtt_outlier: none       # RTT outliers in hop stats: none, winsorize, drop
   timeout: 3s             # Probe timeout
   first_hop: 1            # Starting hop
   sequential: false       # Use sequential mode
+  parallel_queries: false # Send a hop's probes at once in sequential mode
+  concurrency: 30         # Probes in flight in concurrent mode (1-512)
+  verify_dest: 0          # Extra probes to the destination for end-host loss
+  max_packets: 0          # Probe packets per trace at most (0 = unlimited)
+  skip_private_prefix: false  # Collapse leading private/CGNAT hops
 
   # Network settings
   ipv4: false             # Force IPv4
   ipv6: false             # Force IPv6
   port: 0                 # Destination port (0 = default)
+  nat64_prefix: ""        # e.g. 64:ff9b::/96 (empty = discover via DNS64)
+  resolve: ""             # Re-resolve the target in --watch: once (default), every, ttl
+
+  # Tags recorded in every result (--tag key=value adds or overrides)
+  # tags:
+  #   site: fra1
 
   # Enrichment settings
   enrichment:
     enabled: true         # Master switch for all enrichment
     rdns: true            # Reverse DNS lookups
+    rdns_verify: false    # Flag hostnames that do not resolve back to the hop IP
     asn: true             # ASN lookups
     geoip: true           # GeoIP lookups
+    rdns_timeout: 2s      # Per-lookup timeouts (--enrich-timeout sets all three)
+    asn_timeout: 3s
+    geoip_timeout: 5s
+    cache_size: 1000      # Entries per lookup cache
+    ipapi_key: ""         # ip-api.com pro key; lookups then use HTTPS
+    ipapi_url: ""         # ip-api.com compatible endpoint, e.g. https://geo.example.net/json/
 
 # MaxMind GeoLite2 database settings (optional)
 # Get free license key: https://www.maxmind.com/en/geolite2/signup
 maxmind:
   enabled: false          # Enable MaxMind databases (faster, offline)
-  license_key: ""         # Your MaxMind license key
+  license_key: ""         # Your MaxMind license key (empty = only use existing files)
   update_hours: 24        # Auto-update interval (0 = no auto-update)
+  db_dir: ""              # Directory with pre-downloaded .mmdb files (e.g. /opt/geoip)
+  asn_db: ""              # Explicit GeoLite2-ASN.mmdb path (overrides db_dir)
+  city_db: ""             # Explicit GeoLite2-City.mmdb path (overrides db_dir)
+
+# Outbound HTTP requests (ip-api.com lookups, MaxMind downloads)
+network:
+  http_proxy: ""          # e.g. http://proxy.example.com:3128 (empty = HTTP(S)_PROXY env)
 
-# Target aliases (optional)
+# Target aliases (optional); an alias may point to another alias
 aliases:
   dns: 8.8.8.8
   cf: 1.1.1.1
   google: google.com
+  prod-eu: 203.0.113.7
+  prod: prod-eu
 `
 }
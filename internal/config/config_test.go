@@ -0,0 +1,407 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/enrich"
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMaxMindConfig_Paths(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   MaxMindConfig
+		wantASN  string
+		wantCity string
+	}{
+		{
+			name:     "db_dir",
+			config:   MaxMindConfig{DBDir: "/opt/geoip"},
+			wantASN:  filepath.Join("/opt/geoip", "GeoLite2-ASN.mmdb"),
+			wantCity: filepath.Join("/opt/geoip", "GeoLite2-City.mmdb"),
+		},
+		{
+			name:     "explicit paths override db_dir",
+			config:   MaxMindConfig{DBDir: "/opt/geoip", ASNDB: "/srv/asn.mmdb", CityDB: "/srv/city.mmdb"},
+			wantASN:  "/srv/asn.mmdb",
+			wantCity: "/srv/city.mmdb",
+		},
+		{
+			name:     "default",
+			config:   MaxMindConfig{Enabled: true},
+			wantASN:  GetASNDBPath(),
+			wantCity: GetGeoDBPath(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.ASNDBPath(); got != tt.wantASN {
+				t.Errorf("ASNDBPath() = %q, want %q", got, tt.wantASN)
+			}
+			if got := tt.config.CityDBPath(); got != tt.wantCity {
+				t.Errorf("CityDBPath() = %q, want %q", got, tt.wantCity)
+			}
+		})
+	}
+}
+
+func TestMaxMindConfig_Configured(t *testing.T) {
+	tests := []struct {
+		config MaxMindConfig
+		want   bool
+	}{
+		{MaxMindConfig{}, false},
+		{MaxMindConfig{LicenseKey: "key"}, false},
+		{MaxMindConfig{Enabled: true}, true},
+		{MaxMindConfig{DBDir: "/opt/geoip"}, true},
+		{MaxMindConfig{ASNDB: "/srv/asn.mmdb"}, true},
+		{MaxMindConfig{CityDB: "/srv/city.mmdb"}, true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.config.Configured(); got != tt.want {
+			t.Errorf("%+v.Configured() = %v, want %v", tt.config, got, tt.want)
+		}
+	}
+}
+
+func TestMaxMindConfig_YAML(t *testing.T) {
+	var cfg Config
+	data := "maxmind:\n  db_dir: /opt/geoip\n  asn_db: /srv/asn.mmdb\n"
+	if err := yaml.Unmarshal([]byte(data), &cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if cfg.MaxMind.DBDir != "/opt/geoip" || cfg.MaxMind.ASNDB != "/srv/asn.mmdb" || cfg.MaxMind.LicenseKey != "" {
+		t.Errorf("MaxMind = %+v", cfg.MaxMind)
+	}
+
+	data = "defaults:\n  tags:\n    site: fra1\n    team: neteng\n"
+	if err := yaml.Unmarshal([]byte(data), &cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if want := map[string]string{"site": "fra1", "team": "neteng"}; !reflect.DeepEqual(cfg.Defaults.Tags, want) {
+		t.Errorf("Defaults.Tags = %v, want %v", cfg.Defaults.Tags, want)
+	}
+
+	var example Config
+	if err := yaml.Unmarshal([]byte(GenerateExample()), &example); err != nil {
+		t.Fatalf("example config does not parse: %v", err)
+	}
+	if got := Or(example.Defaults.Enrichment.ASNTimeout, 0); got != 3*time.Second {
+		t.Errorf("example enrichment.asn_timeout = %v, want 3s", got)
+	}
+}
+
+// writeConfigs writes the named config files into a temp directory and
+// returns the directory.
+func writeConfigs(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+	}
+	return dir
+}
+
+func TestLoadFiles_Include(t *testing.T) {
+	dir := writeConfigs(t, map[string]string{
+		"base.yaml": `
+defaults:
+  tui: true
+  sequential: true
+  max_hops: 20
+  port: 80
+  enrichment:
+    rdns: false
+aliases:
+  dns: 8.8.8.8
+  prod: 203.0.113.7
+maxmind:
+  enabled: true
+  license_key: base-key
+`,
+		"host.yaml": `
+include: base.yaml
+defaults:
+  tui: false
+  port: 443
+aliases:
+  prod: 198.51.100.1
+  gw: 10.0.0.1
+`,
+	})
+
+	cfg, err := LoadFrom(filepath.Join(dir, "host.yaml"))
+	if err != nil {
+		t.Fatalf("LoadFrom() error = %v", err)
+	}
+	d := cfg.Defaults
+
+	// Explicit false in the including file overrides true in the base
+	if d.TUI == nil || *d.TUI {
+		t.Error("tui: false should override the base's true")
+	}
+	// Keys the including file does not set keep the base's values
+	if !Or(d.Sequential, false) || Or(d.MaxHops, 0) != 20 || Or(d.Enrichment.RDNS, true) {
+		t.Errorf("sequential/max_hops/rdns = %v/%v/%v, want base values true/20/false", d.Sequential, d.MaxHops, d.Enrichment.RDNS)
+	}
+	// Keys neither file sets stay unset
+	if d.Queries != nil || d.Enrichment.ASN != nil {
+		t.Errorf("queries/asn = %v/%v, want unset", d.Queries, d.Enrichment.ASN)
+	}
+	if Or(d.Port, 0) != 443 {
+		t.Errorf("port = %v, want 443", d.Port)
+	}
+	if !cfg.MaxMind.Enabled || cfg.MaxMind.LicenseKey != "base-key" || cfg.MaxMind.UpdateHours != 24 {
+		t.Errorf("maxmind = %+v, want base settings over defaults", cfg.MaxMind)
+	}
+
+	want := map[string]string{"dns": "8.8.8.8", "prod": "198.51.100.1", "gw": "10.0.0.1"}
+	if !reflect.DeepEqual(cfg.Aliases, want) {
+		t.Errorf("aliases = %v, want %v", cfg.Aliases, want)
+	}
+
+	wantSources := []string{filepath.Join(dir, "base.yaml"), filepath.Join(dir, "host.yaml")}
+	if !reflect.DeepEqual(cfg.Sources, wantSources) {
+		t.Errorf("Sources = %v, want %v", cfg.Sources, wantSources)
+	}
+	if cfg.Include != "" {
+		t.Errorf("Include = %q, want cleared after merging", cfg.Include)
+	}
+}
+
+func TestLoadFiles_Precedence(t *testing.T) {
+	dir := writeConfigs(t, map[string]string{
+		"base.yaml":  "defaults:\n  max_hops: 20\n  json: true\naliases:\n  a: 1.1.1.1\n",
+		"one.yaml":   "include: base.yaml\ndefaults:\n  max_hops: 25\naliases:\n  b: 2.2.2.2\n",
+		"two.yaml":   "include: base.yaml\ndefaults:\n  json: false\naliases:\n  a: 9.9.9.9\n",
+		"three.yaml": "defaults:\n  max_hops: 40\n",
+	})
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	cfg, err := LoadFiles(path("one.yaml"), path("two.yaml"), path("three.yaml"))
+	if err != nil {
+		t.Fatalf("LoadFiles() error = %v", err)
+	}
+
+	// The shared base is applied once, so it does not undo one.yaml
+	if Or(cfg.Defaults.MaxHops, 0) != 40 || Or(cfg.Defaults.JSON, true) {
+		t.Errorf("max_hops/json = %v/%v, want 40/false", cfg.Defaults.MaxHops, cfg.Defaults.JSON)
+	}
+	if want := map[string]string{"a": "9.9.9.9", "b": "2.2.2.2"}; !reflect.DeepEqual(cfg.Aliases, want) {
+		t.Errorf("aliases = %v, want %v", cfg.Aliases, want)
+	}
+	wantSources := []string{path("base.yaml"), path("one.yaml"), path("two.yaml"), path("three.yaml")}
+	if !reflect.DeepEqual(cfg.Sources, wantSources) {
+		t.Errorf("Sources = %v, want %v", cfg.Sources, wantSources)
+	}
+
+	cfg, err = LoadFiles(path("three.yaml"), path("one.yaml"))
+	if err != nil {
+		t.Fatalf("LoadFiles() error = %v", err)
+	}
+	if Or(cfg.Defaults.MaxHops, 0) != 25 {
+		t.Errorf("max_hops = %v, want 25 from the later file", cfg.Defaults.MaxHops)
+	}
+}
+
+func TestLoadFiles_IncludeCycle(t *testing.T) {
+	dir := writeConfigs(t, map[string]string{
+		"a.yaml":    "include: b.yaml\n",
+		"b.yaml":    "include: c.yaml\n",
+		"c.yaml":    "include: a.yaml\n",
+		"self.yaml": "include: ./self.yaml\n",
+	})
+
+	for _, name := range []string{"a.yaml", "self.yaml"} {
+		_, err := LoadFrom(filepath.Join(dir, name))
+		if !errors.Is(err, ErrIncludeCycle) {
+			t.Errorf("LoadFrom(%s) error = %v, want ErrIncludeCycle", name, err)
+		}
+	}
+}
+
+func TestLoadFiles_Errors(t *testing.T) {
+	dir := writeConfigs(t, map[string]string{
+		"missing.yaml": "include: nowhere.yaml\n",
+		"bad.yaml":     "defaults: [\n",
+		"badbase.yaml": "include: bad.yaml\n",
+	})
+
+	for _, name := range []string{"missing.yaml", "bad.yaml", "badbase.yaml", "absent.yaml"} {
+		if _, err := LoadFrom(filepath.Join(dir, name)); err == nil {
+			t.Errorf("LoadFrom(%s) should fail", name)
+		}
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	for _, method := range []string{"", "icmp", "udp", "tcp", "paris", "UDP"} {
+		cfg := DefaultConfig()
+		cfg.Defaults.ProbeMethod = method
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("Validate() with probe_method %q = %v", method, err)
+		}
+	}
+
+	cfg := DefaultConfig()
+	cfg.Defaults.ProbeMethod = "tpc"
+	if err := cfg.Validate(); !errors.Is(err, trace.ErrUnknownProbeMethod) {
+		t.Errorf("Validate() with probe_method \"tpc\" = %v, want ErrUnknownProbeMethod", err)
+	}
+
+	cfg = DefaultConfig()
+	cfg.Defaults.RTTOutlier = "trim"
+	if err := cfg.Validate(); !errors.Is(err, trace.ErrUnknownOutlierPolicy) {
+		t.Errorf("Validate() with rtt_outlier \"trim\" = %v, want ErrUnknownOutlierPolicy", err)
+	}
+
+	cfg = DefaultConfig()
+	cfg.Defaults.Resolve = "sometimes"
+	if err := cfg.Validate(); !errors.Is(err, trace.ErrUnknownResolvePolicy) {
+		t.Errorf("Validate() with resolve \"sometimes\" = %v, want ErrUnknownResolvePolicy", err)
+	}
+
+	cfg = DefaultConfig()
+	cfg.Network.HTTPProxy = "proxy.example.com:3128"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "network.http_proxy") {
+		t.Errorf("Validate() with http_proxy without scheme = %v, want a network.http_proxy error", err)
+	}
+}
+
+func TestNetworkConfig_HTTPClient(t *testing.T) {
+	factory, err := NetworkConfig{}.HTTPClient("1.2.3")
+	if err != nil || factory.Proxy != nil || factory.UserAgent != enrich.UserAgent("1.2.3") {
+		t.Errorf("HTTPClient() = %+v, %v, want the environment's proxy and the version's User-Agent", factory, err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal([]byte("network:\n  http_proxy: http://proxy.example.com:3128\n"), &cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	factory, err = cfg.Network.HTTPClient("1.2.3")
+	if err != nil || factory.Proxy == nil || factory.Proxy.Host != "proxy.example.com:3128" {
+		t.Errorf("HTTPClient() = %+v, %v, want the configured proxy", factory, err)
+	}
+}
+
+func TestDefaults_Getters(t *testing.T) {
+	var d Defaults
+	if Or(d.TUI, false) || !Or(d.Enrichment.RDNS, true) {
+		t.Error("unset booleans should fall back to the default")
+	}
+	if got := PositiveOr(d.MaxHops, DefaultMaxHops); got != DefaultMaxHops {
+		t.Errorf("PositiveOr(nil) = %d, want %d", got, DefaultMaxHops)
+	}
+
+	d.TUI = ptr(false)
+	d.Enrichment.RDNS = ptr(false)
+	d.MaxHops = ptr(0)
+	d.Timeout = ptr(5 * time.Second)
+	if Or(d.TUI, true) || Or(d.Enrichment.RDNS, true) {
+		t.Error("an explicit false should not fall back to the default")
+	}
+	if got := PositiveOr(d.MaxHops, DefaultMaxHops); got != DefaultMaxHops {
+		t.Errorf("PositiveOr(0) = %d, want built-in %d", got, DefaultMaxHops)
+	}
+	if got := PositiveOr(d.Timeout, DefaultTimeout); got != 5*time.Second {
+		t.Errorf("PositiveOr(5s) = %v, want 5s", got)
+	}
+}
+
+// TestApplyDefault_Precedence covers flag versus config file precedence:
+// a flag given on the command line always wins, otherwise a key set in a
+// config file (even to false) wins over the flag's built-in default.
+func TestApplyDefault_Precedence(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string // defaults section of the config file
+		flag     *bool  // --sequential value, nil if not given
+		wantSeq  bool
+		wantRDNS bool // rdns enabled after applying --no-rdns semantics
+	}{
+		{"unset anywhere", "  port: 80\n", nil, false, true},
+		{"set in file only", "  sequential: true\n  enrichment:\n    rdns: false\n", nil, true, false},
+		{"explicit false in file", "  sequential: false\n  enrichment:\n    enabled: false\n", nil, false, true},
+		{"set by flag only", "  port: 80\n", ptr(true), true, true},
+		{"set in both", "  sequential: false\n", ptr(true), true, true},
+		{"flag false over file true", "  sequential: true\n", ptr(false), false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeConfigs(t, map[string]string{"config.yaml": "defaults:\n" + tt.file})
+			cfg, err := LoadFrom(filepath.Join(dir, "config.yaml"))
+			if err != nil {
+				t.Fatalf("LoadFrom() error = %v", err)
+			}
+
+			// Mirror the CLI: flags hold their parsed value, then the
+			// config fills in those not given
+			sequential := Or(tt.flag, false)
+			ApplyDefault(&sequential, cfg.Defaults.Sequential, tt.flag != nil)
+			if sequential != tt.wantSeq {
+				t.Errorf("sequential = %v, want %v", sequential, tt.wantSeq)
+			}
+
+			// enabled: false must not imply rdns: false
+			if rdns := Or(cfg.Defaults.Enrichment.RDNS, true); rdns != tt.wantRDNS {
+				t.Errorf("rdns = %v, want %v", rdns, tt.wantRDNS)
+			}
+		})
+	}
+}
+
+// TestDefaults_Thresholds checks that every coloring threshold tells an
+// explicit 0 apart from an omitted key.
+func TestDefaults_Thresholds(t *testing.T) {
+	dir := writeConfigs(t, map[string]string{"config.yaml": "defaults:\n  rtt_crit: 200\n  loss_warn: 0\n"})
+	cfg, err := LoadFrom(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("LoadFrom() error = %v", err)
+	}
+
+	d := cfg.Defaults
+	if d.RTTWarn != nil || d.LossCrit != nil {
+		t.Errorf("rtt_warn/loss_crit = %v/%v, want unset", d.RTTWarn, d.LossCrit)
+	}
+	if d.RTTCrit == nil || *d.RTTCrit != 200 {
+		t.Errorf("rtt_crit = %v, want 200", d.RTTCrit)
+	}
+	if d.LossWarn == nil || *d.LossWarn != 0 {
+		t.Errorf("loss_warn = %v, want explicit 0", d.LossWarn)
+	}
+
+	lossCrit := 10.0
+	ApplyDefault(&lossCrit, d.LossCrit, false)
+	rttCrit := 150.0
+	ApplyDefault(&rttCrit, d.RTTCrit, false)
+	if lossCrit != 10 || rttCrit != 200 {
+		t.Errorf("loss_crit/rtt_crit applied = %v/%v, want 10/200", lossCrit, rttCrit)
+	}
+}
+
+func TestFirstNotice(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("APPDATA", os.Getenv("XDG_CONFIG_HOME"))
+
+	if !FirstNotice("plaintext-geoip") {
+		t.Error("FirstNotice() = false on the first run, want true")
+	}
+	if FirstNotice("plaintext-geoip") {
+		t.Error("FirstNotice() = true on a later run, want false")
+	}
+	if !FirstNotice("other") {
+		t.Error("FirstNotice() should track each notice separately")
+	}
+}
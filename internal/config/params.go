@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+)
+
+// traceParam is a trace parameter under defaults with the range that
+// trace.Config.Validate accepts for it. 0 means the built-in default and
+// is always accepted.
+type traceParam struct {
+	key      string
+	value    *int
+	min, max int
+	err      error
+}
+
+// traceParams lists the range-checked trace parameters. first_hop may not
+// exceed max_hops when the file sets both, so max_hops comes first and is
+// checked (or clamped) before first_hop's range is taken from it.
+func (c *Config) traceParams() []traceParam {
+	d := &c.Defaults
+	return []traceParam{
+		{key: "max_hops", value: d.MaxHops, min: 1, max: trace.MaxHopsLimit, err: trace.ErrInvalidMaxHops},
+		{key: "queries", value: d.Queries, min: 1, max: trace.MaxProbeCount, err: trace.ErrInvalidProbeCount},
+		{key: "first_hop", value: d.FirstHop, min: 1, max: trace.MaxHopsLimit, err: trace.ErrInvalidFirstHop},
+	}
+}
+
+// ClampTraceParams replaces out-of-range max_hops, queries and first_hop
+// values with the nearest accepted value, for --lenient-config. It
+// returns a warning per value changed.
+func (c *Config) ClampTraceParams() []string {
+	warnings, _ := c.checkTraceParams(true)
+	return warnings
+}
+
+// checkTraceParams returns an error for the first out-of-range trace
+// parameter, or with clamp set, clamps every one and describes each
+// change in a warning.
+func (c *Config) checkTraceParams(clamp bool) ([]string, error) {
+	var warnings []string
+	for _, p := range c.traceParams() {
+		if p.value == nil || *p.value == 0 {
+			continue
+		}
+		if p.key == "first_hop" {
+			if maxHops := c.Defaults.MaxHops; maxHops != nil && *maxHops > 0 {
+				p.max = *maxHops
+			}
+		}
+		v := *p.value
+		if v >= p.min && v <= p.max {
+			continue
+		}
+		if !clamp {
+			return nil, fmt.Errorf("%s: %d: %w", c.keyName(p.key), v, p.err)
+		}
+		*p.value = min(max(v, p.min), p.max)
+		warnings = append(warnings, fmt.Sprintf("%s: %d is out of range (%d-%d), using %d",
+			c.keyName(p.key), v, p.min, p.max, *p.value))
+	}
+	return warnings, nil
+}
+
+// keyName returns the dotted name of a key under defaults, prefixed by
+// the file that set it if it came from a file, e.g.
+// "/home/user/.config/poros/config.yaml: defaults.max_hops".
+func (c *Config) keyName(key string) string {
+	name := "defaults." + key
+	if path := c.origins[key]; path != "" {
+		return path + ": " + name
+	}
+	return name
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+)
+
+func TestConfig_ValidateTraceParams(t *testing.T) {
+	tests := []struct {
+		name     string
+		defaults string
+		want     error  // nil = valid
+		key      string // key named in the error
+	}{
+		{name: "in range", defaults: "max_hops: 64\n  queries: 10\n  first_hop: 64"},
+		{name: "zero means default", defaults: "max_hops: 0\n  queries: 0\n  first_hop: 0"},
+		{name: "max_hops too high", defaults: "max_hops: 300", want: trace.ErrInvalidMaxHops, key: "defaults.max_hops"},
+		{name: "queries negative", defaults: "queries: -1", want: trace.ErrInvalidProbeCount, key: "defaults.queries"},
+		{name: "queries too high", defaults: "queries: 11", want: trace.ErrInvalidProbeCount, key: "defaults.queries"},
+		{name: "first_hop beyond max_hops", defaults: "max_hops: 10\n  first_hop: 12", want: trace.ErrInvalidFirstHop, key: "defaults.first_hop"},
+		{name: "first_hop alone", defaults: "first_hop: 40"},
+		{name: "first_hop too high", defaults: "first_hop: 256", want: trace.ErrInvalidFirstHop, key: "defaults.first_hop"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeConfigs(t, map[string]string{"config.yaml": "defaults:\n  " + tt.defaults + "\n"})
+			path := filepath.Join(dir, "config.yaml")
+			cfg, err := LoadFrom(path)
+			if err != nil {
+				t.Fatalf("LoadFrom() error = %v", err)
+			}
+
+			err = cfg.Validate()
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Validate() = %v, want %v", err, tt.want)
+			}
+			if prefix := path + ": " + tt.key + ": "; !strings.HasPrefix(err.Error(), prefix) {
+				t.Errorf("Validate() = %q, want it to start with %q", err, prefix)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateTraceParams_Origin(t *testing.T) {
+	dir := writeConfigs(t, map[string]string{
+		"base.yaml": "defaults:\n  queries: 20\n",
+		"host.yaml": "include: base.yaml\ndefaults:\n  max_hops: 40\n",
+	})
+	cfg, err := LoadFrom(filepath.Join(dir, "host.yaml"))
+	if err != nil {
+		t.Fatalf("LoadFrom() error = %v", err)
+	}
+
+	// The error points at the included file that set the key
+	want := filepath.Join(dir, "base.yaml") + ": defaults.queries: 20: "
+	if err := cfg.Validate(); err == nil || !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("Validate() = %v, want it to start with %q", err, want)
+	}
+
+	// A value set in code rather than a file is named by key alone
+	cfg = DefaultConfig()
+	cfg.Defaults.MaxHops = ptr(300)
+	if err := cfg.Validate(); err == nil || !strings.HasPrefix(err.Error(), "defaults.max_hops: 300: ") {
+		t.Errorf("Validate() = %v, want a defaults.max_hops error without a file", err)
+	}
+}
+
+func TestConfig_ClampTraceParams(t *testing.T) {
+	dir := writeConfigs(t, map[string]string{
+		"config.yaml": "defaults:\n  max_hops: 300\n  queries: -2\n  first_hop: 280\n",
+	})
+	path := filepath.Join(dir, "config.yaml")
+	cfg, err := LoadFrom(path)
+	if err != nil {
+		t.Fatalf("LoadFrom() error = %v", err)
+	}
+
+	warnings := cfg.ClampTraceParams()
+	want := []string{
+		path + ": defaults.max_hops: 300 is out of range (1-255), using 255",
+		path + ": defaults.queries: -2 is out of range (1-10), using 1",
+		path + ": defaults.first_hop: 280 is out of range (1-255), using 255",
+	}
+	if strings.Join(warnings, "\n") != strings.Join(want, "\n") {
+		t.Errorf("ClampTraceParams() =\n%s\nwant\n%s", strings.Join(warnings, "\n"), strings.Join(want, "\n"))
+	}
+	d := cfg.Defaults
+	if *d.MaxHops != 255 || *d.Queries != 1 || *d.FirstHop != 255 {
+		t.Errorf("max_hops/queries/first_hop = %d/%d/%d, want 255/1/255", *d.MaxHops, *d.Queries, *d.FirstHop)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() after clamping = %v", err)
+	}
+	if warnings := cfg.ClampTraceParams(); len(warnings) != 0 {
+		t.Errorf("ClampTraceParams() again = %v, want no warnings", warnings)
+	}
+
+	// first_hop is clamped to max_hops once that is in range
+	cfg = DefaultConfig()
+	cfg.Defaults.MaxHops, cfg.Defaults.FirstHop = ptr(20), ptr(25)
+	if warnings := cfg.ClampTraceParams(); len(warnings) != 1 || *cfg.Defaults.FirstHop != 20 {
+		t.Errorf("first_hop = %d with warnings %v, want 20 and one warning", *cfg.Defaults.FirstHop, warnings)
+	}
+}
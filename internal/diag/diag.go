@@ -0,0 +1,153 @@
+// Package diag serves runtime diagnostics for long-running poros
+// processes: the net/http/pprof profiles and a JSON dump of internal
+// state at /debug/poros, and on a separate server the per-hop RTT
+// histograms at /metrics.
+package diag
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/metrics"
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+)
+
+// ErrRemoteListen is returned for a listen address that is reachable
+// from other hosts when remote access was not allowed.
+var ErrRemoteListen = errors.New("diagnostics listen address is not a loopback address")
+
+// State is the document served at /debug/poros.
+type State struct {
+	Time       time.Time           `json:"time"`
+	Uptime     float64             `json:"uptime_seconds"`
+	GoVersion  string              `json:"go_version"`
+	Goroutines int                 `json:"goroutines"`
+	Tracers    []trace.TracerState `json:"tracers"`
+}
+
+// Snapshot assembles the current state of the process and the tracers
+// in registry, which may be nil.
+func Snapshot(registry *trace.Registry, started time.Time) State {
+	state := State{
+		Time:       time.Now(),
+		Uptime:     time.Since(started).Seconds(),
+		GoVersion:  runtime.Version(),
+		Goroutines: runtime.NumGoroutine(),
+		Tracers:    []trace.TracerState{},
+	}
+	if registry != nil {
+		state.Tracers = registry.States()
+	}
+	return state
+}
+
+// NewHandler returns a handler serving the pprof profiles under
+// /debug/pprof/ and the state of the tracers in registry at /debug/poros.
+func NewHandler(registry *trace.Registry, started time.Time) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/poros", func(w http.ResponseWriter, r *http.Request) {
+		data, err := json.MarshalIndent(Snapshot(registry, started), "", "  ")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(append(data, '\n'))
+	})
+	return mux
+}
+
+// NewMetricsHandler returns a handler serving hops in the Prometheus text
+// format at /metrics, and nothing else.
+func NewMetricsHandler(hops *metrics.HopHistograms) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		hops.WriteTo(w)
+	})
+	return mux
+}
+
+// ValidateListen checks a host:port listen address. Unless allowRemote is
+// set, the host must be a loopback address or "localhost"; an empty host
+// listens on every interface and is refused.
+func ValidateListen(addr string, allowRemote bool) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid diagnostics listen address %q: %w", addr, err)
+	}
+	if allowRemote || host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrRemoteListen, addr)
+}
+
+// Server is a running diagnostics or metrics HTTP server.
+type Server struct {
+	listener net.Listener
+	server   *http.Server
+}
+
+// Start validates addr and serves diagnostics on it in the background.
+func Start(addr string, allowRemote bool, registry *trace.Registry) (*Server, error) {
+	if err := ValidateListen(addr, allowRemote); err != nil {
+		return nil, err
+	}
+	return serve(addr, NewHandler(registry, time.Now()), "diagnostics")
+}
+
+// StartMetrics serves hops at /metrics on addr in the background. Unlike
+// Start it accepts any address, for a scraper on another host: only the
+// histograms are served.
+func StartMetrics(addr string, hops *metrics.HopHistograms) (*Server, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, fmt.Errorf("invalid metrics listen address %q: %w", addr, err)
+	}
+	return serve(addr, NewMetricsHandler(hops), "metrics")
+}
+
+// serve listens on addr and serves handler in the background; name
+// describes the server in errors.
+func serve(addr string, handler http.Handler, name string) (*Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start %s server: %w", name, err)
+	}
+
+	s := &Server{
+		listener: listener,
+		server: &http.Server{
+			Handler:           handler,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+	}
+	go s.server.Serve(listener)
+	return s, nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Close stops the server, giving in-flight requests a moment to finish.
+func (s *Server) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	return s.server.Shutdown(ctx)
+}
@@ -0,0 +1,146 @@
+package diag
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/metrics"
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+	"github.com/KilimcininKorOglu/poros/internal/trace/tracetest"
+)
+
+func TestValidateListen(t *testing.T) {
+	tests := []struct {
+		addr        string
+		allowRemote bool
+		wantErr     error
+	}{
+		{"127.0.0.1:6060", false, nil},
+		{"[::1]:6060", false, nil},
+		{"localhost:6060", false, nil},
+		{":6060", false, ErrRemoteListen},
+		{"0.0.0.0:6060", false, ErrRemoteListen},
+		{"192.0.2.1:6060", false, ErrRemoteListen},
+		{"0.0.0.0:6060", true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			err := ValidateListen(tt.addr, tt.allowRemote)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ValidateListen(%q, %v) error = %v, want %v", tt.addr, tt.allowRemote, err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := ValidateListen("6060", false); err == nil {
+		t.Error("ValidateListen() should reject an address without a port")
+	}
+}
+
+func TestHandler_State(t *testing.T) {
+	handler := NewHandler(trace.NewRegistry(), time.Now().Add(-time.Minute))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/poros", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("GET /debug/poros = %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	var state State
+	if err := json.Unmarshal(rec.Body.Bytes(), &state); err != nil {
+		t.Fatalf("invalid state JSON: %v", err)
+	}
+	if state.Goroutines == 0 || state.Uptime < 60 || state.GoVersion == "" || state.Tracers == nil {
+		t.Errorf("state = %+v", state)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "goroutine") {
+		t.Errorf("GET /debug/pprof/ = %d", rec.Code)
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	result := tracetest.Result("example.com").Hop(1, "192.0.2.1", 4, 12).Build()
+	hops := metrics.NewHopHistograms(nil)
+	hops.Observe(result)
+	handler := NewMetricsHandler(hops)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("GET /metrics = %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	want := `poros_hop_rtt_ms_count{target="example.com",hop="1",ip="192.0.2.1"} 2`
+	if !strings.Contains(rec.Body.String(), want) {
+		t.Errorf("GET /metrics should contain %s:\n%s", want, rec.Body.String())
+	}
+
+	// The profiles stay on the diagnostics server only
+	for _, path := range []string{"/debug/pprof/", "/debug/poros"} {
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s on the metrics handler = %d, want 404", path, rec.Code)
+		}
+	}
+	rec = httptest.NewRecorder()
+	NewHandler(nil, time.Now()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET /metrics on the diagnostics handler = %d, want 404", rec.Code)
+	}
+}
+
+func TestStart(t *testing.T) {
+	if _, err := Start("0.0.0.0:0", false, nil); !errors.Is(err, ErrRemoteListen) {
+		t.Fatalf("Start() on all interfaces error = %v, want ErrRemoteListen", err)
+	}
+
+	server, err := Start("127.0.0.1:0", false, nil)
+	if err != nil {
+		t.Skipf("cannot listen on loopback: %v", err)
+	}
+	defer server.Close()
+
+	resp, err := http.Get("http://" + server.Addr().String() + "/debug/poros")
+	if err != nil {
+		t.Fatalf("GET /debug/poros error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	var state State
+	if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+		t.Fatalf("invalid state JSON: %v", err)
+	}
+	if len(state.Tracers) != 0 {
+		t.Errorf("Tracers = %+v without a registry", state.Tracers)
+	}
+}
+
+func TestStartMetrics(t *testing.T) {
+	if _, err := StartMetrics("9464", nil); err == nil {
+		t.Fatal("StartMetrics() should reject an address without a port")
+	}
+
+	server, err := StartMetrics("127.0.0.1:0", metrics.NewHopHistograms(nil))
+	if err != nil {
+		t.Skipf("cannot listen on loopback: %v", err)
+	}
+	defer server.Close()
+
+	resp, err := http.Get("http://" + server.Addr().String() + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /metrics = %d", resp.StatusCode)
+	}
+}
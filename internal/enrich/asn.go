@@ -6,14 +6,18 @@ import (
 	"net"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 // ASNInfo contains ASN information for an IP address.
 type ASNInfo struct {
-	Number  int
-	Org     string
-	Country string
+	Number     int
+	Org        string
+	Country    string
+	Prefix     string // Announced prefix covering the IP (e.g., "8.8.8.0/24")
+	AltNumbers []int  // Additional origin ASNs for multi-origin prefixes
+	Source     string // Provider that supplied the data, e.g. SourceCymru
 }
 
 // ASNLookup defines the interface for ASN lookups.
@@ -26,23 +30,43 @@ type ASNLookup interface {
 // This is a free service that doesn't require any database files.
 // See: https://www.team-cymru.com/ip-asn-mapping
 type TeamCymruASN struct {
-	timeout time.Duration
-	cache   *Cache
+	timeout   time.Duration
+	cache     *Cache
+	nameCache *Cache // AS number -> AS name
+	resolver  TXTResolver
+
+	// In-flight AS name lookups, shared by concurrent callers
+	mu       sync.Mutex
+	inflight map[int]*nameCall
+}
+
+// TXTResolver performs DNS TXT lookups. *net.Resolver satisfies it.
+type TXTResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// nameCall is an AS name lookup that may be awaited by several callers.
+type nameCall struct {
+	done chan struct{}
+	name string
 }
 
 // TeamCymruConfig holds configuration for Team Cymru ASN lookups.
 type TeamCymruConfig struct {
-	Timeout   time.Duration
-	CacheSize int
-	CacheTTL  time.Duration
+	Timeout      time.Duration
+	CacheSize    int
+	CacheTTL     time.Duration
+	NameCacheTTL time.Duration // AS names repeat across hops and traces
+	Resolver     TXTResolver   // nil = system resolver
 }
 
 // DefaultTeamCymruConfig returns default configuration.
 func DefaultTeamCymruConfig() TeamCymruConfig {
 	return TeamCymruConfig{
-		Timeout:   3 * time.Second,
-		CacheSize: 1000,
-		CacheTTL:  1 * time.Hour, // ASN data changes infrequently
+		Timeout:      3 * time.Second,
+		CacheSize:    1000,
+		CacheTTL:     1 * time.Hour, // ASN data changes infrequently
+		NameCacheTTL: 24 * time.Hour,
 	}
 }
 
@@ -52,14 +76,27 @@ func NewTeamCymruASN(config TeamCymruConfig) *TeamCymruASN {
 		config.Timeout = 3 * time.Second
 	}
 
-	var cache *Cache
+	if config.NameCacheTTL == 0 {
+		config.NameCacheTTL = 24 * time.Hour
+	}
+
+	var cache, nameCache *Cache
 	if config.CacheSize > 0 {
 		cache = NewCache(config.CacheSize, config.CacheTTL)
+		nameCache = NewCache(config.CacheSize, config.NameCacheTTL)
+	}
+
+	resolver := config.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
 	}
 
 	return &TeamCymruASN{
-		timeout: config.Timeout,
-		cache:   cache,
+		timeout:   config.Timeout,
+		cache:     cache,
+		nameCache: nameCache,
+		resolver:  resolver,
+		inflight:  make(map[int]*nameCall),
 	}
 }
 
@@ -67,6 +104,17 @@ func NewTeamCymruASN(config TeamCymruConfig) *TeamCymruASN {
 // For IPv4: query <reversed-ip>.origin.asn.cymru.com
 // For IPv6: query <nibble-reversed>.origin6.asn.cymru.com
 func (t *TeamCymruASN) Lookup(ctx context.Context, ip net.IP) (*ASNInfo, error) {
+	return t.lookup(ctx, ip, false)
+}
+
+// LookupFresh is like Lookup but ignores a cached failed lookup for ip,
+// so a transient DNS failure can be retried within the cache TTL.
+func (t *TeamCymruASN) LookupFresh(ctx context.Context, ip net.IP) (*ASNInfo, error) {
+	return t.lookup(ctx, ip, true)
+}
+
+// lookup implements Lookup; fresh skips cached failures.
+func (t *TeamCymruASN) lookup(ctx context.Context, ip net.IP, fresh bool) (*ASNInfo, error) {
 	if ip == nil {
 		return nil, nil
 	}
@@ -80,11 +128,11 @@ func (t *TeamCymruASN) Lookup(ctx context.Context, ip net.IP) (*ASNInfo, error)
 
 	// Check cache
 	if t.cache != nil {
-		if cached, ok := t.cache.Get(ipStr); ok {
+		if cached, ok := t.cache.Get(ipStr); ok && (cached != nil || !fresh) {
 			if cached == nil {
 				return nil, nil
 			}
-			return cached.(*ASNInfo), nil
+			return t.withCachedName(cached.(*ASNInfo)), nil
 		}
 	}
 
@@ -104,7 +152,7 @@ func (t *TeamCymruASN) Lookup(ctx context.Context, ip net.IP) (*ASNInfo, error)
 	defer cancel()
 
 	// Query TXT record
-	records, err := net.DefaultResolver.LookupTXT(lookupCtx, query)
+	records, err := t.resolver.LookupTXT(lookupCtx, query)
 	if err != nil {
 		// Cache negative result
 		if t.cache != nil {
@@ -121,7 +169,7 @@ func (t *TeamCymruASN) Lookup(ctx context.Context, ip net.IP) (*ASNInfo, error)
 	}
 
 	// Parse response: "ASN | IP/Prefix | Country | Registry | Date"
-	info := parseTeamCymruResponse(records[0])
+	info := parseTeamCymruRecords(records)
 	if info == nil {
 		if t.cache != nil {
 			t.cache.Set(ipStr, nil)
@@ -131,7 +179,7 @@ func (t *TeamCymruASN) Lookup(ctx context.Context, ip net.IP) (*ASNInfo, error)
 
 	// Get AS name if we have an ASN
 	if info.Number > 0 {
-		info.Org = t.lookupASName(ctx, info.Number)
+		info.Org = t.asName(ctx, info.Number)
 	}
 
 	// Cache result
@@ -142,6 +190,88 @@ func (t *TeamCymruASN) Lookup(ctx context.Context, ip net.IP) (*ASNInfo, error)
 	return info, nil
 }
 
+// asName returns the name of an AS, from the name cache if possible.
+// Concurrent requests for the same AS share one DNS query. If the name
+// does not arrive within the timeout, an empty name is returned and the
+// query keeps running in the background to fill the cache for later hops.
+func (t *TeamCymruASN) asName(ctx context.Context, asn int) string {
+	if name, ok := t.cachedName(asn); ok {
+		return name
+	}
+
+	call := t.startNameLookup(asn)
+
+	timer := time.NewTimer(t.timeout)
+	defer timer.Stop()
+
+	select {
+	case <-call.done:
+		return call.name
+	case <-ctx.Done():
+		return ""
+	case <-timer.C:
+		return ""
+	}
+}
+
+// startNameLookup starts an AS name lookup or joins one already in flight.
+func (t *TeamCymruASN) startNameLookup(asn int) *nameCall {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if call, ok := t.inflight[asn]; ok {
+		return call
+	}
+
+	call := &nameCall{done: make(chan struct{})}
+	t.inflight[asn] = call
+
+	go func() {
+		// Detached from the caller so one cancelled hop does not
+		// abort a lookup other hops are waiting on
+		ctx, cancel := context.WithTimeout(context.Background(), t.timeout)
+		defer cancel()
+
+		call.name = t.lookupASName(ctx, asn)
+		if call.name != "" && t.nameCache != nil {
+			t.nameCache.Set(strconv.Itoa(asn), call.name)
+		}
+
+		t.mu.Lock()
+		delete(t.inflight, asn)
+		t.mu.Unlock()
+		close(call.done)
+	}()
+
+	return call
+}
+
+// cachedName returns the cached name of an AS.
+func (t *TeamCymruASN) cachedName(asn int) (string, bool) {
+	if t.nameCache == nil {
+		return "", false
+	}
+	if name, ok := t.nameCache.Get(strconv.Itoa(asn)); ok {
+		return name.(string), true
+	}
+	return "", false
+}
+
+// withCachedName fills in a missing AS name from the name cache.
+// Cached entries are shared, so a copy is returned instead of mutating them.
+func (t *TeamCymruASN) withCachedName(info *ASNInfo) *ASNInfo {
+	if info.Org != "" || info.Number == 0 {
+		return info
+	}
+	name, ok := t.cachedName(info.Number)
+	if !ok {
+		return info
+	}
+	filled := *info
+	filled.Org = name
+	return &filled
+}
+
 // lookupASName queries Team Cymru for the AS name.
 func (t *TeamCymruASN) lookupASName(ctx context.Context, asn int) string {
 	query := fmt.Sprintf("AS%d.asn.cymru.com", asn)
@@ -149,7 +279,7 @@ func (t *TeamCymruASN) lookupASName(ctx context.Context, asn int) string {
 	lookupCtx, cancel := context.WithTimeout(ctx, t.timeout)
 	defer cancel()
 
-	records, err := net.DefaultResolver.LookupTXT(lookupCtx, query)
+	records, err := t.resolver.LookupTXT(lookupCtx, query)
 	if err != nil || len(records) == 0 {
 		return ""
 	}
@@ -163,34 +293,86 @@ func (t *TeamCymruASN) lookupASName(ctx context.Context, asn int) string {
 	return ""
 }
 
+// CacheStats implements CacheReporter.
+func (t *TeamCymruASN) CacheStats() map[string]CacheStats {
+	stats := make(map[string]CacheStats)
+	addCacheStats(stats, "asn", t.cache)
+	addCacheStats(stats, "asn_names", t.nameCache)
+	return stats
+}
+
 // Close releases resources.
 func (t *TeamCymruASN) Close() error {
 	if t.cache != nil {
 		t.cache.Clear()
 	}
+	if t.nameCache != nil {
+		t.nameCache.Clear()
+	}
 	return nil
 }
 
 // parseTeamCymruResponse parses the TXT record response.
 func parseTeamCymruResponse(txt string) *ASNInfo {
 	// Format: "ASN | IP/Prefix | Country | Registry | Date"
+	// Multi-origin prefixes list several ASNs: "15169 36040 | ..."
 	parts := strings.Split(txt, "|")
 	if len(parts) < 3 {
 		return nil
 	}
 
-	asnStr := strings.TrimSpace(parts[0])
-	country := strings.TrimSpace(parts[2])
-
-	asn, err := strconv.Atoi(asnStr)
-	if err != nil {
+	origins := strings.Fields(parts[0])
+	if len(origins) == 0 {
 		return nil
 	}
 
-	return &ASNInfo{
-		Number:  asn,
-		Country: country,
+	numbers := make([]int, 0, len(origins))
+	for _, origin := range origins {
+		asn, err := strconv.Atoi(origin)
+		if err != nil {
+			return nil
+		}
+		numbers = append(numbers, asn)
+	}
+
+	info := &ASNInfo{
+		Number:  numbers[0],
+		Country: strings.TrimSpace(parts[2]),
+		Prefix:  strings.TrimSpace(parts[1]),
+		Source:  SourceCymru,
+	}
+	if len(numbers) > 1 {
+		info.AltNumbers = numbers[1:]
+	}
+
+	return info
+}
+
+// parseTeamCymruRecords parses all TXT records returned for an IP and
+// picks the one with the most specific prefix. Cymru returns one record
+// per covering prefix when an address is announced more than once.
+func parseTeamCymruRecords(records []string) *ASNInfo {
+	var best *ASNInfo
+	bestLen := -1
+
+	for _, record := range records {
+		info := parseTeamCymruResponse(record)
+		if info == nil {
+			continue
+		}
+
+		prefixLen := -1
+		if _, network, err := net.ParseCIDR(info.Prefix); err == nil {
+			prefixLen, _ = network.Mask.Size()
+		}
+
+		if best == nil || prefixLen > bestLen {
+			best = info
+			bestLen = prefixLen
+		}
 	}
+
+	return best
 }
 
 // reverseIPv6Nibbles reverses the nibbles of an IPv6 address for DNS lookup.
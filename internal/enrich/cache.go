@@ -2,22 +2,49 @@ package enrich
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// cacheEntry represents a single cache entry with expiration.
+// cacheEntry represents a single cache entry with expiration. Entries
+// form a doubly-linked list in order of use, most recent first.
 type cacheEntry struct {
+	key       string
 	value     interface{}
 	expiresAt time.Time
+
+	prev, next *cacheEntry
 }
 
-// Cache is a simple thread-safe LRU-like cache with TTL.
+// Cache is a thread-safe LRU cache with TTL. When full, adding an entry
+// evicts the least recently used one in constant time.
 type Cache struct {
-	data     map[string]cacheEntry
-	maxSize  int
-	ttl      time.Duration
-	mu       sync.RWMutex
-	accesses map[string]time.Time // Track access times for eviction
+	data    map[string]*cacheEntry
+	maxSize int
+	ttl     time.Duration
+	mu      sync.RWMutex
+
+	// lru is the sentinel of the use list: lru.next is the most and
+	// lru.prev the least recently used entry
+	lru cacheEntry
+
+	// Lookup outcomes, for diagnostics
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// CacheStats is a snapshot of a cache's size and lookup counters.
+type CacheStats struct {
+	Entries  int    `json:"entries"`
+	Capacity int    `json:"capacity"`
+	Hits     uint64 `json:"hits"`
+	Misses   uint64 `json:"misses"`
+}
+
+// CacheReporter is implemented by lookups that cache their results.
+type CacheReporter interface {
+	// CacheStats returns the stats of each cache by name
+	CacheStats() map[string]CacheStats
 }
 
 // NewCache creates a new cache with the specified size and TTL.
@@ -29,56 +56,45 @@ func NewCache(maxSize int, ttl time.Duration) *Cache {
 		ttl = 5 * time.Minute
 	}
 
-	return &Cache{
-		data:     make(map[string]cacheEntry),
-		maxSize:  maxSize,
-		ttl:      ttl,
-		accesses: make(map[string]time.Time),
+	c := &Cache{
+		data:    make(map[string]*cacheEntry),
+		maxSize: maxSize,
+		ttl:     ttl,
 	}
+	c.lru.next, c.lru.prev = &c.lru, &c.lru
+	return c
 }
 
 // Get retrieves a value from the cache.
 func (c *Cache) Get(key string) (interface{}, bool) {
-	c.mu.RLock()
+	c.mu.Lock()
 	entry, ok := c.data[key]
-	c.mu.RUnlock()
-
 	if !ok {
+		c.mu.Unlock()
+		c.misses.Add(1)
 		return nil, false
 	}
 
 	// Check expiration
 	if time.Now().After(entry.expiresAt) {
-		c.mu.Lock()
-		delete(c.data, key)
-		delete(c.accesses, key)
+		c.remove(entry)
 		c.mu.Unlock()
+		c.misses.Add(1)
 		return nil, false
 	}
 
-	// Update access time
-	c.mu.Lock()
-	c.accesses[key] = time.Now()
+	c.unlink(entry)
+	c.pushFront(entry)
+	value := entry.value
 	c.mu.Unlock()
 
-	return entry.value, true
+	c.hits.Add(1)
+	return value, true
 }
 
 // Set stores a value in the cache.
 func (c *Cache) Set(key string, value interface{}) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	// Evict if at capacity
-	if len(c.data) >= c.maxSize {
-		c.evictOldest()
-	}
-
-	c.data[key] = cacheEntry{
-		value:     value,
-		expiresAt: time.Now().Add(c.ttl),
-	}
-	c.accesses[key] = time.Now()
+	c.SetWithTTL(key, value, c.ttl)
 }
 
 // SetWithTTL stores a value with a custom TTL.
@@ -86,31 +102,40 @@ func (c *Cache) SetWithTTL(key string, value interface{}, ttl time.Duration) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	expiresAt := time.Now().Add(ttl)
+	if entry, ok := c.data[key]; ok {
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.unlink(entry)
+		c.pushFront(entry)
+		return
+	}
+
+	// Evict if at capacity
 	if len(c.data) >= c.maxSize {
 		c.evictOldest()
 	}
 
-	c.data[key] = cacheEntry{
-		value:     value,
-		expiresAt: time.Now().Add(ttl),
-	}
-	c.accesses[key] = time.Now()
+	entry := &cacheEntry{key: key, value: value, expiresAt: expiresAt}
+	c.data[key] = entry
+	c.pushFront(entry)
 }
 
 // Delete removes a key from the cache.
 func (c *Cache) Delete(key string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	delete(c.data, key)
-	delete(c.accesses, key)
+	if entry, ok := c.data[key]; ok {
+		c.remove(entry)
+	}
 }
 
 // Clear removes all entries from the cache.
 func (c *Cache) Clear() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.data = make(map[string]cacheEntry)
-	c.accesses = make(map[string]time.Time)
+	c.data = make(map[string]*cacheEntry)
+	c.lru.next, c.lru.prev = &c.lru, &c.lru
 }
 
 // Size returns the current number of entries in the cache.
@@ -120,25 +145,53 @@ func (c *Cache) Size() int {
 	return len(c.data)
 }
 
-// evictOldest removes the least recently accessed entry.
+// Stats returns the cache's size and lookup counters.
+func (c *Cache) Stats() CacheStats {
+	return CacheStats{
+		Entries:  c.Size(),
+		Capacity: c.maxSize,
+		Hits:     c.hits.Load(),
+		Misses:   c.misses.Load(),
+	}
+}
+
+// addCacheStats adds the stats of c to stats under name, if c is in use.
+func addCacheStats(stats map[string]CacheStats, name string, c *Cache) {
+	if c != nil {
+		stats[name] = c.Stats()
+	}
+}
+
+// evictOldest removes the least recently used entry.
 // Must be called with lock held.
 func (c *Cache) evictOldest() {
-	var oldestKey string
-	var oldestTime time.Time
-
-	first := true
-	for key, accessTime := range c.accesses {
-		if first || accessTime.Before(oldestTime) {
-			oldestKey = key
-			oldestTime = accessTime
-			first = false
-		}
+	if oldest := c.lru.prev; oldest != &c.lru {
+		c.remove(oldest)
 	}
+}
 
-	if oldestKey != "" {
-		delete(c.data, oldestKey)
-		delete(c.accesses, oldestKey)
-	}
+// pushFront inserts entry as the most recently used one.
+// Must be called with lock held.
+func (c *Cache) pushFront(entry *cacheEntry) {
+	entry.prev = &c.lru
+	entry.next = c.lru.next
+	c.lru.next.prev = entry
+	c.lru.next = entry
+}
+
+// unlink takes entry out of the use list.
+// Must be called with lock held.
+func (c *Cache) unlink(entry *cacheEntry) {
+	entry.prev.next = entry.next
+	entry.next.prev = entry.prev
+	entry.prev, entry.next = nil, nil
+}
+
+// remove deletes entry from the cache.
+// Must be called with lock held.
+func (c *Cache) remove(entry *cacheEntry) {
+	c.unlink(entry)
+	delete(c.data, entry.key)
 }
 
 // Cleanup removes expired entries.
@@ -147,10 +200,9 @@ func (c *Cache) Cleanup() {
 	defer c.mu.Unlock()
 
 	now := time.Now()
-	for key, entry := range c.data {
+	for _, entry := range c.data {
 		if now.After(entry.expiresAt) {
-			delete(c.data, key)
-			delete(c.accesses, key)
+			c.remove(entry)
 		}
 	}
 }
@@ -0,0 +1,203 @@
+package enrich
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewCache(3, time.Minute)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+
+	// Using a makes b the least recently used entry
+	cache.Get("a")
+	cache.Set("d", 4)
+	if _, ok := cache.Get("b"); ok {
+		t.Error("b should have been evicted")
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if _, ok := cache.Get(key); !ok {
+			t.Errorf("%s should still be cached", key)
+		}
+	}
+
+	// Updating an entry refreshes it without evicting another
+	cache.Set("a", 10)
+	if cache.Size() != 3 {
+		t.Errorf("Size() = %d after an update, want 3", cache.Size())
+	}
+	cache.Set("e", 5) // evicts c, used before d and a
+	if _, ok := cache.Get("c"); ok {
+		t.Error("c should have been evicted")
+	}
+	if v, _ := cache.Get("a"); v != 10 {
+		t.Errorf("Get(a) = %v, want the updated value 10", v)
+	}
+}
+
+func TestCache_DeleteAndCleanup(t *testing.T) {
+	cache := NewCache(4, time.Minute)
+	cache.Set("keep", 1)
+	cache.SetWithTTL("expired", 2, -time.Second)
+	cache.Set("gone", 3)
+
+	cache.Delete("gone")
+	cache.Delete("missing")
+	cache.Cleanup()
+	if cache.Size() != 1 {
+		t.Fatalf("Size() = %d, want 1", cache.Size())
+	}
+
+	// The use list stays consistent: the remaining slots fill up and
+	// evict in order
+	for i := range 4 {
+		cache.Set(fmt.Sprint(i), i)
+	}
+	if _, ok := cache.Get("keep"); ok {
+		t.Error("keep should have been evicted as the least recently used entry")
+	}
+	if cache.Size() != 4 {
+		t.Errorf("Size() = %d, want 4", cache.Size())
+	}
+
+	cache.Clear()
+	cache.Set("after", 1)
+	if v, ok := cache.Get("after"); !ok || v != 1 {
+		t.Errorf("Get(after) = %v, %v after Clear()", v, ok)
+	}
+}
+
+func TestCache_Concurrent(t *testing.T) {
+	cache := NewCache(64, time.Minute)
+
+	var wg sync.WaitGroup
+	for w := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 2000 {
+				key := fmt.Sprint((w*31 + i) % 200)
+				switch i % 7 {
+				case 0:
+					cache.SetWithTTL(key, i, time.Millisecond)
+				case 1:
+					cache.Delete(key)
+				case 2:
+					cache.Cleanup()
+				case 3:
+					cache.Size()
+				default:
+					cache.Set(key, i)
+					cache.Get(key)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := cache.Size(); n > 64 {
+		t.Errorf("Size() = %d, exceeds capacity 64", n)
+	}
+	// Walking the use list visits every entry exactly once
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+	seen := 0
+	for e := cache.lru.next; e != &cache.lru; e = e.next {
+		if cache.data[e.key] != e {
+			t.Fatalf("use list entry %q is not in the map", e.key)
+		}
+		seen++
+	}
+	if seen != len(cache.data) {
+		t.Errorf("use list has %d entries, map has %d", seen, len(cache.data))
+	}
+}
+
+// mapCache is the previous cache design, kept to benchmark against: a
+// second map of access times, scanned in full to find the entry to evict.
+type mapCache struct {
+	data     map[string]cacheEntry
+	accesses map[string]time.Time
+	maxSize  int
+	ttl      time.Duration
+	mu       sync.RWMutex
+}
+
+func newMapCache(maxSize int, ttl time.Duration) *mapCache {
+	return &mapCache{
+		data:     make(map[string]cacheEntry),
+		accesses: make(map[string]time.Time),
+		maxSize:  maxSize,
+		ttl:      ttl,
+	}
+}
+
+func (c *mapCache) Get(key string) (interface{}, bool) {
+	c.mu.RLock()
+	entry, ok := c.data[key]
+	c.mu.RUnlock()
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	c.mu.Lock()
+	c.accesses[key] = time.Now()
+	c.mu.Unlock()
+	return entry.value, true
+}
+
+func (c *mapCache) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.data) >= c.maxSize {
+		var oldestKey string
+		var oldestTime time.Time
+		first := true
+		for key, accessTime := range c.accesses {
+			if first || accessTime.Before(oldestTime) {
+				oldestKey, oldestTime, first = key, accessTime, false
+			}
+		}
+		delete(c.data, oldestKey)
+		delete(c.accesses, oldestKey)
+	}
+	c.data[key] = cacheEntry{value: value, expiresAt: time.Now().Add(c.ttl)}
+	c.accesses[key] = time.Now()
+}
+
+// benchCache is the part of the cache API the benchmarks use.
+type benchCache interface {
+	Get(key string) (interface{}, bool)
+	Set(key string, value interface{})
+}
+
+// benchmarkFullCache fills a 10k entry cache, then inserts new keys,
+// each evicting an entry, interleaved with hits on recent keys.
+func benchmarkFullCache(b *testing.B, cache benchCache) {
+	const size = 10000
+	keys := make([]string, size+b.N)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("198.51.%d.%d", i/256%256, i%256)
+	}
+	for _, key := range keys[:size] {
+		cache.Set(key, key)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Set(keys[size+i], i)
+		cache.Get(keys[size+i-i%100])
+	}
+}
+
+func BenchmarkCache_Full10k(b *testing.B) {
+	benchmarkFullCache(b, NewCache(10000, time.Hour))
+}
+
+func BenchmarkCache_Full10k_MapScan(b *testing.B) {
+	benchmarkFullCache(b, newMapCache(10000, time.Hour))
+}
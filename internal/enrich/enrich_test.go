@@ -2,7 +2,14 @@ package enrich
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -39,6 +46,25 @@ func TestCache(t *testing.T) {
 	}
 }
 
+func TestCacheStats(t *testing.T) {
+	cache := NewCache(10, time.Minute)
+	cache.Set("key1", "value1")
+	cache.Get("key1")
+	cache.Get("key1")
+	cache.Get("missing")
+
+	want := CacheStats{Entries: 1, Capacity: 10, Hits: 2, Misses: 1}
+	if got := cache.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+
+	enricher := NewEnricher(EnricherConfig{EnableRDNS: true})
+	defer enricher.Close()
+	if _, ok := enricher.CacheStats()["rdns"]; !ok {
+		t.Errorf("CacheStats() = %v, want an rdns cache", enricher.CacheStats())
+	}
+}
+
 func TestCacheExpiration(t *testing.T) {
 	cache := NewCache(10, 50*time.Millisecond)
 
@@ -204,6 +230,78 @@ func TestIPAPIGeo_PublicIP(t *testing.T) {
 	}
 }
 
+func TestIPAPIGeo_CannedResponses(t *testing.T) {
+	responses := map[string]string{
+		"203.0.113.10": `{"status":"success","country":"Germany","countryCode":"DE","region":"HE","regionName":"Hesse","city":"Frankfurt am Main","lat":50.11,"lon":8.68,"isp":"Example Cloud GmbH","org":"Example Cloud","as":"AS64500 Example Cloud GmbH","hosting":true,"proxy":false,"mobile":false}`,
+		"203.0.113.20": `{"status":"success","country":"Turkey","countryCode":"TR","city":"Istanbul","isp":"","org":"Example Mobile","mobile":true,"proxy":true}`,
+		"203.0.113.30": `{"status":"success","country":"United States","countryCode":"US","city":"Ashburn"}`,
+	}
+
+	var requests int
+	var fields string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fields = r.URL.Query().Get("fields")
+		fmt.Fprint(w, responses[strings.TrimPrefix(r.URL.Path, "/")])
+	}))
+	defer server.Close()
+
+	config := DefaultIPAPIConfig()
+	config.BaseURL = server.URL + "/"
+	geo := NewIPAPIGeo(config)
+	defer geo.Close()
+
+	tests := []struct {
+		ip      string
+		isp     string
+		hosting bool
+		proxy   bool
+		mobile  bool
+	}{
+		{"203.0.113.10", "Example Cloud GmbH", true, false, false},
+		{"203.0.113.20", "Example Mobile", false, true, true}, // falls back to org
+		{"203.0.113.30", "", false, false, false},             // older response shape
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			info, err := geo.Lookup(ctx, net.ParseIP(tt.ip))
+			if err != nil {
+				t.Fatalf("Lookup() error = %v", err)
+			}
+			if info.ISP != tt.isp {
+				t.Errorf("ISP = %q, want %q", info.ISP, tt.isp)
+			}
+			if info.Source != SourceIPAPI {
+				t.Errorf("Source = %q, want %q", info.Source, SourceIPAPI)
+			}
+			if info.Hosting != tt.hosting || info.Proxy != tt.proxy || info.Mobile != tt.mobile {
+				t.Errorf("flags = hosting:%v proxy:%v mobile:%v, want %v %v %v",
+					info.Hosting, info.Proxy, info.Mobile, tt.hosting, tt.proxy, tt.mobile)
+			}
+		})
+	}
+
+	for _, f := range []string{"isp", "org", "hosting", "proxy", "mobile", "region", "regionName"} {
+		if !strings.Contains(fields, f) {
+			t.Errorf("fields parameter %q does not request %q", fields, f)
+		}
+	}
+
+	// Cached entries keep the new fields without another request
+	info, _ := geo.Lookup(ctx, net.ParseIP("203.0.113.10"))
+	if requests != len(tests) {
+		t.Errorf("requests = %d, want %d (cache miss)", requests, len(tests))
+	}
+	if info == nil || !info.Hosting || info.ISP != "Example Cloud GmbH" {
+		t.Errorf("cached info = %+v, want hosting flag and ISP", info)
+	}
+	if info == nil || info.Region != "Hesse" || info.RegionCode != "HE" {
+		t.Errorf("cached info = %+v, want region Hesse (HE)", info)
+	}
+}
+
 func TestEnricher(t *testing.T) {
 	config := DefaultEnricherConfig()
 	enricher := NewEnricher(config)
@@ -293,6 +391,72 @@ func TestReverseIPv6Nibbles(t *testing.T) {
 	}
 }
 
+func TestIPAPIGeo_HTTPSWithKey(t *testing.T) {
+	var key, fields string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key = r.URL.Query().Get("key")
+		fields = r.URL.Query().Get("fields")
+		fmt.Fprint(w, `{"status":"success","country":"Germany","countryCode":"DE","city":"Berlin"}`)
+	}))
+	defer server.Close()
+
+	config := DefaultIPAPIConfig()
+	config.BaseURL = server.URL + "/json" // trailing slash is added
+	config.APIKey = "s3cret"
+	config.Client = server.Client()
+	geo := NewIPAPIGeo(config)
+	defer geo.Close()
+
+	info, err := geo.Lookup(context.Background(), net.ParseIP("203.0.113.40"))
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if info == nil || info.City != "Berlin" {
+		t.Errorf("Lookup() = %+v, want Berlin", info)
+	}
+	if key != "s3cret" {
+		t.Errorf("key parameter = %q, want s3cret", key)
+	}
+	if !strings.Contains(fields, "countryCode") {
+		t.Errorf("fields parameter = %q, want the requested fields", fields)
+	}
+}
+
+func TestIPAPIURL(t *testing.T) {
+	tests := []struct {
+		baseURL, apiKey string
+		want            string
+		plaintext       bool
+	}{
+		{"", "", IPAPIFreeURL, true},
+		{"", "key", IPAPIProURL, false},
+		{"https://geo.example.net/json", "", "https://geo.example.net/json/", false},
+		{"http://10.0.0.5:8080/json/", "key", "http://10.0.0.5:8080/json/", true},
+	}
+	for _, tt := range tests {
+		got := IPAPIURL(tt.baseURL, tt.apiKey)
+		if got != tt.want {
+			t.Errorf("IPAPIURL(%q, %q) = %q, want %q", tt.baseURL, tt.apiKey, got, tt.want)
+		}
+		if IsPlaintextURL(got) != tt.plaintext {
+			t.Errorf("IsPlaintextURL(%q) = %v, want %v", got, !tt.plaintext, tt.plaintext)
+		}
+	}
+}
+
+func TestValidateIPAPIURL(t *testing.T) {
+	for _, valid := range []string{"https://pro.ip-api.com/json/", "http://10.0.0.5:8080/json"} {
+		if err := ValidateIPAPIURL(valid); err != nil {
+			t.Errorf("ValidateIPAPIURL(%q) error = %v", valid, err)
+		}
+	}
+	for _, invalid := range []string{"ip-api.com/json/", "ftp://example.net/", "https:///json/", "https://example.net/json?lang=de", "://"} {
+		if err := ValidateIPAPIURL(invalid); err == nil {
+			t.Errorf("ValidateIPAPIURL(%q) should fail", invalid)
+		}
+	}
+}
+
 func TestParseTeamCymruResponse(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -300,7 +464,15 @@ func TestParseTeamCymruResponse(t *testing.T) {
 	}{
 		{
 			"15169 | 8.8.8.0/24 | US | arin | 2014-03-14",
-			&ASNInfo{Number: 15169, Country: "US"},
+			&ASNInfo{Number: 15169, Country: "US", Prefix: "8.8.8.0/24"},
+		},
+		{
+			"15169 13335 | 8.8.8.0/24 | US | arin | 2014-03-14",
+			&ASNInfo{Number: 15169, Country: "US", Prefix: "8.8.8.0/24"},
+		},
+		{
+			"1299 | 62.115.0.0/16 | SE | ripencc | 2003-02-25",
+			&ASNInfo{Number: 1299, Country: "SE", Prefix: "62.115.0.0/16"},
 		},
 		{
 			"invalid",
@@ -333,5 +505,586 @@ func TestParseTeamCymruResponse(t *testing.T) {
 		if result.Country != tt.expected.Country {
 			t.Errorf("Country = %q, want %q", result.Country, tt.expected.Country)
 		}
+		if result.Prefix != tt.expected.Prefix {
+			t.Errorf("Prefix = %q, want %q", result.Prefix, tt.expected.Prefix)
+		}
+		if result.Source != SourceCymru {
+			t.Errorf("Source = %q, want %q", result.Source, SourceCymru)
+		}
+	}
+}
+
+func TestEnricherProviders(t *testing.T) {
+	enricher := NewEnricher(EnricherConfig{EnableRDNS: true, EnableASN: true})
+	defer enricher.Close()
+
+	providers := enricher.Providers()
+	if providers["rdns"] != "online" || providers["asn"] != "online" {
+		t.Errorf("Providers() = %v, want rdns and asn online", providers)
+	}
+	if _, ok := providers["geoip"]; ok {
+		t.Error("Providers() should not list disabled GeoIP")
+	}
+}
+
+func TestEnricherProviderConfig(t *testing.T) {
+	enricher := NewEnricher(EnricherConfig{
+		EnableRDNS:   true,
+		EnableASN:    true,
+		EnableGeoIP:  true,
+		RDNSTimeout:  250,
+		ASNTimeout:   500,
+		GeoIPTimeout: 750,
+		CacheSize:    42,
+	})
+	defer enricher.Close()
+
+	asn := enricher.asn.(*TeamCymruASN)
+	geo := enricher.geo.(*IPAPIGeo)
+	if enricher.rdns.timeout != 250*time.Millisecond {
+		t.Errorf("rDNS timeout = %v, want 250ms", enricher.rdns.timeout)
+	}
+	if asn.timeout != 500*time.Millisecond {
+		t.Errorf("ASN timeout = %v, want 500ms", asn.timeout)
+	}
+	if geo.timeout != 750*time.Millisecond {
+		t.Errorf("GeoIP timeout = %v, want 750ms", geo.timeout)
+	}
+	for name, cache := range map[string]*Cache{"rdns": enricher.rdns.cache, "asn": asn.cache, "geoip": geo.cache} {
+		if cache.maxSize != 42 {
+			t.Errorf("%s cache size = %d, want 42", name, cache.maxSize)
+		}
+	}
+
+	// Zero values keep the provider defaults
+	enricher = NewEnricher(EnricherConfig{EnableRDNS: true})
+	defer enricher.Close()
+	if want := DefaultRDNSConfig().Timeout; enricher.rdns.timeout != want {
+		t.Errorf("default rDNS timeout = %v, want %v", enricher.rdns.timeout, want)
+	}
+}
+
+func TestParseTeamCymruResponse_MultiOrigin(t *testing.T) {
+	info := parseTeamCymruResponse("15169 36040 396982 | 8.8.8.0/24 | US | arin | 2014-03-14")
+	if info == nil {
+		t.Fatal("multi-origin response should parse")
+	}
+	if info.Number != 15169 {
+		t.Errorf("Number = %d, want primary origin 15169", info.Number)
+	}
+	if len(info.AltNumbers) != 2 || info.AltNumbers[0] != 36040 || info.AltNumbers[1] != 396982 {
+		t.Errorf("AltNumbers = %v, want [36040 396982]", info.AltNumbers)
+	}
+
+	single := parseTeamCymruResponse("15169 | 8.8.8.0/24 | US | arin | 2014-03-14")
+	if single.AltNumbers != nil {
+		t.Errorf("single-origin AltNumbers = %v, want nil", single.AltNumbers)
+	}
+
+	if parseTeamCymruResponse("15169 AS36040 | 8.8.8.0/24 | US | arin |") != nil {
+		t.Error("malformed origin list should be rejected")
+	}
+}
+
+func TestParseTeamCymruRecords(t *testing.T) {
+	tests := []struct {
+		name       string
+		records    []string
+		wantNumber int
+		wantPrefix string
+	}{
+		{
+			name: "most specific prefix wins",
+			records: []string{
+				"3356 | 4.0.0.0/9 | US | arin | 1992-12-01",
+				"3356 1299 | 4.69.0.0/16 | US | arin | 1992-12-01",
+				"209 | 4.69.184.0/24 | US | arin | 2007-03-01",
+			},
+			wantNumber: 209,
+			wantPrefix: "4.69.184.0/24",
+		},
+		{
+			name: "order independent",
+			records: []string{
+				"13335 | 1.1.1.0/24 | AU | apnic | 2011-08-11",
+				"13335 | 1.0.0.0/8 | AU | apnic | 2011-08-11",
+			},
+			wantNumber: 13335,
+			wantPrefix: "1.1.1.0/24",
+		},
+		{
+			name: "ipv6",
+			records: []string{
+				"15169 | 2001:4860::/32 | US | arin | 2005-03-14",
+				"15169 | 2001:4860:4860::/48 | US | arin | 2005-03-14",
+			},
+			wantNumber: 15169,
+			wantPrefix: "2001:4860:4860::/48",
+		},
+		{
+			name:       "skips unparseable records",
+			records:    []string{"garbage", "15169 | 8.8.8.0/24 | US | arin | 2014-03-14"},
+			wantNumber: 15169,
+			wantPrefix: "8.8.8.0/24",
+		},
+		{
+			name:    "nothing usable",
+			records: []string{"garbage", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := parseTeamCymruRecords(tt.records)
+			if tt.wantNumber == 0 {
+				if info != nil {
+					t.Errorf("parseTeamCymruRecords() = %+v, want nil", info)
+				}
+				return
+			}
+			if info == nil {
+				t.Fatal("parseTeamCymruRecords() = nil")
+			}
+			if info.Number != tt.wantNumber || info.Prefix != tt.wantPrefix {
+				t.Errorf("got AS%d %s, want AS%d %s", info.Number, info.Prefix, tt.wantNumber, tt.wantPrefix)
+			}
+		})
+	}
+}
+
+// fakeTXTResolver answers Team Cymru queries from a table and counts them.
+type fakeTXTResolver struct {
+	mu      sync.Mutex
+	delay   time.Duration
+	origins map[string]int // reversed-IP query -> ASN
+	queries map[string]int
+}
+
+func newFakeTXTResolver(delay time.Duration) *fakeTXTResolver {
+	return &fakeTXTResolver{
+		delay:   delay,
+		origins: make(map[string]int),
+		queries: make(map[string]int),
+	}
+}
+
+func (r *fakeTXTResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	r.mu.Lock()
+	r.queries[name]++
+	asn, isOrigin := r.origins[name]
+	r.mu.Unlock()
+
+	select {
+	case <-time.After(r.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if isOrigin {
+		return []string{fmt.Sprintf("%d | 198.51.0.0/16 | US | arin | 2001-01-01", asn)}, nil
+	}
+
+	var n int
+	if _, err := fmt.Sscanf(name, "AS%d.asn.cymru.com", &n); err == nil {
+		return []string{fmt.Sprintf("%d | US | arin | 2001-01-01 | NET-%d, US", n, n)}, nil
+	}
+	return nil, fmt.Errorf("no such record: %s", name)
+}
+
+// count returns the number of origin and AS-name queries issued.
+func (r *fakeTXTResolver) count() (origin, name int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for q, c := range r.queries {
+		if strings.HasSuffix(q, ".origin.asn.cymru.com") {
+			origin += c
+		} else {
+			name += c
+		}
+	}
+	return origin, name
+}
+
+// addHops registers n public IPs spread over the given number of ASNs.
+func (r *fakeTXTResolver) addHops(n, asns int) []net.IP {
+	ips := make([]net.IP, 0, n)
+	for i := 0; i < n; i++ {
+		ip := net.IPv4(203, 0, 113, byte(i+1))
+		r.origins[fmt.Sprintf("%d.113.0.203.origin.asn.cymru.com", i+1)] = 64500 + i%asns
+		ips = append(ips, ip)
+	}
+	return ips
+}
+
+func TestTeamCymruASN_NameCoalescing(t *testing.T) {
+	resolver := newFakeTXTResolver(10 * time.Millisecond)
+	ips := resolver.addHops(25, 5)
+
+	config := DefaultTeamCymruConfig()
+	config.Resolver = resolver
+	asn := NewTeamCymruASN(config)
+	defer asn.Close()
+
+	var wg sync.WaitGroup
+	for _, ip := range ips {
+		wg.Add(1)
+		go func(ip net.IP) {
+			defer wg.Done()
+			info, err := asn.Lookup(context.Background(), ip)
+			if err != nil || info == nil {
+				t.Errorf("Lookup(%s) = %v, %v", ip, info, err)
+				return
+			}
+			if want := fmt.Sprintf("NET-%d, US", info.Number); info.Org != want {
+				t.Errorf("Lookup(%s).Org = %q, want %q", ip, info.Org, want)
+			}
+		}(ip)
+	}
+	wg.Wait()
+
+	origin, name := resolver.count()
+	if origin != 25 {
+		t.Errorf("origin queries = %d, want 25", origin)
+	}
+	if name != 5 {
+		t.Errorf("AS name queries = %d, want 5 (one per distinct AS)", name)
+	}
+
+	// A second trace over the same hops is served entirely from cache
+	for _, ip := range ips {
+		asn.Lookup(context.Background(), ip)
+	}
+	if o, n := resolver.count(); o != origin || n != name {
+		t.Errorf("repeat lookups issued queries: origin %d->%d, name %d->%d", origin, o, name, n)
+	}
+}
+
+func TestTeamCymruASN_NameTimeout(t *testing.T) {
+	resolver := newFakeTXTResolver(0)
+	ips := resolver.addHops(1, 1)
+
+	config := DefaultTeamCymruConfig()
+	config.Resolver = resolver
+	asn := NewTeamCymruASN(config)
+	defer asn.Close()
+
+	// Simulate a name lookup that is already running slowly
+	call := &nameCall{done: make(chan struct{})}
+	asn.inflight[64500] = call
+	asn.timeout = 20 * time.Millisecond
+
+	info, _ := asn.Lookup(context.Background(), ips[0])
+	if info == nil || info.Org != "" {
+		t.Fatalf("Lookup() = %+v, want numeric result without name", info)
+	}
+
+	// Once the background lookup lands, cached results pick up the name
+	asn.nameCache.Set("64500", "LATE-NET")
+	info, _ = asn.Lookup(context.Background(), ips[0])
+	if info.Org != "LATE-NET" {
+		t.Errorf("Org = %q, want name filled from cache", info.Org)
+	}
+	close(call.done)
+}
+
+func BenchmarkTeamCymruASN_Trace(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		resolver := newFakeTXTResolver(time.Millisecond)
+		ips := resolver.addHops(25, 5)
+
+		config := DefaultTeamCymruConfig()
+		config.Resolver = resolver
+		asn := NewTeamCymruASN(config)
+
+		var wg sync.WaitGroup
+		for _, ip := range ips {
+			wg.Add(1)
+			go func(ip net.IP) {
+				defer wg.Done()
+				asn.Lookup(context.Background(), ip)
+			}(ip)
+		}
+		wg.Wait()
+		asn.Close()
+
+		origin, name := resolver.count()
+		b.ReportMetric(float64(origin+name), "queries/trace")
+	}
+}
+
+// slowASN is an ASN provider that ignores its context and answers late.
+type slowASN struct{ delay time.Duration }
+
+func (s slowASN) Lookup(ctx context.Context, ip net.IP) (*ASNInfo, error) {
+	time.Sleep(s.delay)
+	return &ASNInfo{Number: 64500, Source: SourceCymru}, nil
+}
+
+func (s slowASN) Close() error { return nil }
+
+// fastGeo is a GeoIP provider that answers immediately.
+type fastGeo struct{}
+
+func (fastGeo) Lookup(ctx context.Context, ip net.IP) (*GeoInfo, error) {
+	return &GeoInfo{CountryCode: "US"}, nil
+}
+
+func (fastGeo) Close() error { return nil }
+
+func TestEnrichIPs_Cancellation(t *testing.T) {
+	enricher := &Enricher{
+		config: EnricherConfig{EnableASN: true, EnableGeoIP: true},
+		asn:    slowASN{delay: 2 * time.Second},
+		geo:    fastGeo{},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	results := enricher.EnrichIPs(ctx, []net.IP{net.ParseIP("8.8.8.8"), net.ParseIP("1.1.1.1")})
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("EnrichIPs took %v after cancellation, want prompt return", elapsed)
+	}
+
+	for ip, result := range results {
+		if result.ASN != nil {
+			t.Errorf("%s: slow ASN result should not be present", ip)
+		}
+		if result.Geo == nil {
+			t.Errorf("%s: completed GeoIP result should be kept", ip)
+		}
+	}
+}
+
+func TestEnrichIPs_TotalTimeout(t *testing.T) {
+	enricher := &Enricher{
+		config: EnricherConfig{EnableASN: true, TotalTimeout: 50 * time.Millisecond},
+		asn:    slowASN{delay: 2 * time.Second},
+	}
+
+	start := time.Now()
+	enricher.EnrichIPs(context.Background(), []net.IP{net.ParseIP("8.8.8.8")})
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("EnrichIPs took %v, want it bounded by TotalTimeout", elapsed)
+	}
+}
+
+func TestEnrichIPs_Complete(t *testing.T) {
+	enricher := &Enricher{
+		config: EnricherConfig{EnableASN: true},
+		asn:    slowASN{delay: 10 * time.Millisecond},
+	}
+
+	results := enricher.EnrichIPs(context.Background(), []net.IP{net.ParseIP("8.8.8.8")})
+	if r := results["8.8.8.8"]; r == nil || r.ASN == nil {
+		t.Errorf("EnrichIPs() = %v, want ASN result within budget", results)
+	}
+}
+
+// countingASN is an ASN provider that counts its lookups.
+type countingASN struct{ calls atomic.Int64 }
+
+func (c *countingASN) Lookup(ctx context.Context, ip net.IP) (*ASNInfo, error) {
+	c.calls.Add(1)
+	return &ASNInfo{Number: 64500, Source: SourceCymru}, nil
+}
+
+func (c *countingASN) Close() error { return nil }
+
+func TestEnricher_ResultCache(t *testing.T) {
+	asn := &countingASN{}
+	enricher := &Enricher{
+		config:  EnricherConfig{EnableASN: true},
+		asn:     asn,
+		results: EnricherConfig{}.newResultCache(),
+	}
+
+	ips := make([]net.IP, 15)
+	for i := range ips {
+		ips[i] = net.IPv4(203, 0, 113, byte(i+1))
+	}
+
+	// First cycle fills the cache, the next ones are served from it
+	const cycles = 10
+	for cycle := 0; cycle < cycles; cycle++ {
+		var wg sync.WaitGroup
+		for _, ip := range ips {
+			wg.Add(1)
+			go func(ip net.IP) {
+				defer wg.Done()
+				if r := enricher.EnrichIP(context.Background(), ip); r == nil || r.ASN == nil || r.ASN.Number != 64500 {
+					t.Errorf("EnrichIP(%s) = %+v, want ASN 64500", ip, r)
+				}
+			}(ip)
+		}
+		wg.Wait()
+	}
+
+	if calls := asn.calls.Load(); calls != int64(len(ips)) {
+		t.Errorf("provider lookups = %d, want %d (one per IP)", calls, len(ips))
+	}
+	stats := enricher.CacheStats()["results"]
+	if want := (CacheStats{Entries: 15, Capacity: 1000, Hits: 135, Misses: 15}); stats != want {
+		t.Errorf("results cache stats = %+v, want %+v", stats, want)
+	}
+
+	// Changing a returned result does not affect the cached one
+	r := enricher.EnrichIP(context.Background(), ips[0])
+	r.Hostname = "changed"
+	if r := enricher.EnrichIP(context.Background(), ips[0]); r.Hostname != "" {
+		t.Errorf("cached Hostname = %q, want it unchanged", r.Hostname)
+	}
+
+	enricher.Close()
+	if n := enricher.results.Size(); n != 0 {
+		t.Errorf("results cache holds %d entries after Close, want 0", n)
+	}
+}
+
+func TestEnricher_ResultCachePartial(t *testing.T) {
+	enricher := &Enricher{
+		config:  EnricherConfig{EnableASN: true},
+		asn:     slowASN{delay: 200 * time.Millisecond},
+		results: EnricherConfig{}.newResultCache(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if r := enricher.EnrichIP(ctx, net.ParseIP("8.8.8.8")); r.ASN != nil {
+		t.Fatalf("EnrichIP() = %+v, want a partial result without ASN", r)
+	}
+	if n := enricher.results.Size(); n != 0 {
+		t.Errorf("results cache holds %d entries, want partial results left out", n)
+	}
+}
+
+// failingASN is an ASN provider whose lookups fail with err, or find no
+// data if err is nil.
+type failingASN struct{ err error }
+
+func (f failingASN) Lookup(ctx context.Context, ip net.IP) (*ASNInfo, error) { return nil, f.err }
+func (f failingASN) Close() error                                            { return nil }
+
+func TestEnricher_ResultCacheFailed(t *testing.T) {
+	tests := []struct {
+		name    string
+		asn     ASNLookup
+		ip      string
+		wantTTL time.Duration
+	}{
+		{"provider error", failingASN{errors.New("connection refused")}, "203.0.113.1", FailedResultTTL},
+		{"no data", failingASN{}, "203.0.113.2", FailedResultTTL},
+		{"private address", failingASN{}, "192.168.1.1", DefaultResultTTL},
+		{"complete", &countingASN{}, "203.0.113.3", DefaultResultTTL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enricher := &Enricher{
+				config:  EnricherConfig{EnableASN: true},
+				asn:     tt.asn,
+				results: EnricherConfig{}.newResultCache(),
+			}
+			enricher.EnrichIP(context.Background(), net.ParseIP(tt.ip))
+
+			entry, ok := enricher.results.data[tt.ip]
+			if !ok {
+				t.Fatalf("no cached result for %s", tt.ip)
+			}
+			if ttl := time.Until(entry.expiresAt); ttl > tt.wantTTL || ttl < tt.wantTTL-time.Second {
+				t.Errorf("result cached for %s, want %s", ttl.Round(time.Second), tt.wantTTL)
+			}
+		})
+	}
+}
+
+func TestTeamCymruASN_LookupFresh(t *testing.T) {
+	resolver := newFakeTXTResolver(0)
+	config := DefaultTeamCymruConfig()
+	config.Resolver = resolver
+	asn := NewTeamCymruASN(config)
+	defer asn.Close()
+
+	// The first lookup fails and is cached as a failure
+	ip := net.ParseIP("203.0.113.1")
+	if info, _ := asn.Lookup(context.Background(), ip); info != nil {
+		t.Fatalf("Lookup() = %+v before the record exists", info)
+	}
+	resolver.mu.Lock()
+	resolver.addHops(1, 1)
+	resolver.mu.Unlock()
+
+	if info, _ := asn.Lookup(context.Background(), ip); info != nil {
+		t.Errorf("Lookup() = %+v, want the cached failure", info)
+	}
+	if origin, _ := resolver.count(); origin != 1 {
+		t.Errorf("origin queries = %d, want 1 with the failure cached", origin)
+	}
+
+	info, _ := asn.LookupFresh(context.Background(), ip)
+	if info == nil || info.Number != 64500 {
+		t.Fatalf("LookupFresh() = %+v, want AS64500", info)
+	}
+
+	// The fresh answer replaces the cached failure; hits are not redone
+	asn.LookupFresh(context.Background(), ip)
+	if info, _ := asn.Lookup(context.Background(), ip); info == nil || info.Number != 64500 {
+		t.Errorf("Lookup() after LookupFresh = %+v, want AS64500", info)
+	}
+	if origin, _ := resolver.count(); origin != 2 {
+		t.Errorf("origin queries = %d, want 2", origin)
+	}
+}
+
+func TestEnricher_RetryMissing(t *testing.T) {
+	resolver := newFakeTXTResolver(0)
+	config := DefaultTeamCymruConfig()
+	config.Resolver = resolver
+	enricher := &Enricher{
+		config:  EnricherConfig{EnableASN: true, EnableGeoIP: true},
+		asn:     NewTeamCymruASN(config),
+		geo:     fastGeo{},
+		results: EnricherConfig{}.newResultCache(),
+	}
+	defer enricher.Close()
+
+	// 203.0.113.1 answers only from the second query on, 203.0.113.2
+	// never, and the private hop is not looked up at all
+	recovering, failing, private := net.ParseIP("203.0.113.1"), net.ParseIP("203.0.113.2"), net.ParseIP("192.168.1.1")
+	results := enricher.EnrichIPs(context.Background(), []net.IP{recovering, failing, private})
+	for ip, r := range results {
+		if r.ASN != nil {
+			t.Fatalf("%s: ASN = %+v on the first pass", ip, r.ASN)
+		}
+	}
+	resolver.mu.Lock()
+	resolver.addHops(1, 1)
+	resolver.mu.Unlock()
+
+	retried, recovered := enricher.RetryMissing(context.Background(), results, time.Millisecond)
+	if retried != 2 || recovered != 1 {
+		t.Errorf("RetryMissing() = %d retried, %d recovered, want 2 and 1", retried, recovered)
+	}
+	if r := results[recovering.String()]; r.ASN == nil || r.ASN.Number != 64500 || r.Geo == nil {
+		t.Errorf("recovered result = %+v, want AS64500 and the first pass's GeoIP", r)
+	}
+	if r := results[failing.String()]; r.ASN != nil {
+		t.Errorf("failing result = %+v, want no ASN", r)
+	}
+
+	// The recovered result replaces the cached one
+	if r := enricher.EnrichIP(context.Background(), recovering); r.ASN == nil {
+		t.Errorf("cached result = %+v, want the recovered ASN", r)
+	}
+
+	// Nothing left to retry returns at once, without waiting
+	start := time.Now()
+	if retried, _ := enricher.RetryMissing(context.Background(), map[string]*EnrichmentResult{
+		recovering.String(): results[recovering.String()],
+	}, time.Hour); retried != 0 {
+		t.Errorf("RetryMissing() retried %d complete results", retried)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("RetryMissing() took %v with nothing to retry", elapsed)
 	}
 }
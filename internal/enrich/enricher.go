@@ -2,10 +2,31 @@ package enrich
 
 import (
 	"context"
+	"errors"
 	"net"
 	"sync"
+	"time"
 )
 
+// Sources recorded in ASNInfo.Source and GeoInfo.Source.
+const (
+	SourceMaxMind = "maxmind" // Local GeoLite2 databases
+	SourceCymru   = "cymru"   // Team Cymru IP-to-ASN DNS service
+	SourceIPAPI   = "ip-api"  // ip-api.com GeoIP service
+)
+
+// DefaultTotalTimeout bounds the time spent enriching all hops of a trace.
+const DefaultTotalTimeout = 10 * time.Second
+
+// DefaultResultTTL is how long a complete EnrichmentResult is reused for
+// the same IP.
+const DefaultResultTTL = 5 * time.Minute
+
+// FailedResultTTL is how long a result is reused when a provider failed
+// or left out ASN or GeoIP data it may still supply, so a transient
+// failure is retried soon instead of sticking for DefaultResultTTL.
+const FailedResultTTL = 15 * time.Second
+
 // Enricher performs IP enrichment with rDNS, ASN, and GeoIP data.
 type Enricher struct {
 	config   EnricherConfig
@@ -13,6 +34,7 @@ type Enricher struct {
 	asn      ASNLookup
 	geo      GeoLookup
 	maxmind  *MaxMindDB // Optional MaxMind database for offline/faster lookups
+	results  *Cache     // IP -> complete *EnrichmentResult; nil disables
 }
 
 // EnricherConfig holds configuration for the enricher.
@@ -21,13 +43,86 @@ type EnricherConfig struct {
 	EnableASN   bool
 	EnableGeoIP bool
 
-	// Timeouts
+	// VerifyRDNS forward-confirms PTR names, setting
+	// EnrichmentResult.HostnameVerified
+	VerifyRDNS bool
+
+	// Per-lookup timeouts (0 = provider default)
 	RDNSTimeout  int // milliseconds
 	ASNTimeout   int // milliseconds
 	GeoIPTimeout int // milliseconds
 
-	// Cache settings
+	// TotalTimeout bounds a whole EnrichIPs call (0 = DefaultTotalTimeout).
+	// Results that complete within the budget are returned.
+	TotalTimeout time.Duration
+
+	// Cache settings: entries per provider cache (0 = provider default)
 	CacheSize int
+
+	// ip-api.com endpoint prefix and pro key (see IPAPIURL)
+	IPAPIURL string
+	IPAPIKey string
+
+	// HTTPClient creates the clients of online HTTP providers (nil =
+	// direct connections or the environment's proxy)
+	HTTPClient *HTTPClientFactory
+
+	// ResultTTL is how long a complete result is reused for the same IP
+	// before the providers are asked again (0 = DefaultResultTTL)
+	ResultTTL time.Duration
+}
+
+// rdnsConfig returns the rDNS resolver configuration.
+func (c EnricherConfig) rdnsConfig() RDNSConfig {
+	config := DefaultRDNSConfig()
+	config.Verify = c.VerifyRDNS
+	if c.RDNSTimeout > 0 {
+		config.Timeout = millis(c.RDNSTimeout)
+	}
+	if c.CacheSize > 0 {
+		config.CacheSize = c.CacheSize
+	}
+	return config
+}
+
+// asnConfig returns the Team Cymru ASN lookup configuration.
+func (c EnricherConfig) asnConfig() TeamCymruConfig {
+	config := DefaultTeamCymruConfig()
+	if c.ASNTimeout > 0 {
+		config.Timeout = millis(c.ASNTimeout)
+	}
+	if c.CacheSize > 0 {
+		config.CacheSize = c.CacheSize
+	}
+	return config
+}
+
+// geoConfig returns the ip-api.com GeoIP lookup configuration.
+func (c EnricherConfig) geoConfig() IPAPIConfig {
+	config := DefaultIPAPIConfig()
+	config.BaseURL = c.IPAPIURL
+	config.APIKey = c.IPAPIKey
+	config.HTTPClient = c.HTTPClient
+	if c.GeoIPTimeout > 0 {
+		config.Timeout = millis(c.GeoIPTimeout)
+	}
+	if c.CacheSize > 0 {
+		config.CacheSize = c.CacheSize
+	}
+	return config
+}
+
+// newResultCache returns the cache of complete results.
+func (c EnricherConfig) newResultCache() *Cache {
+	ttl := c.ResultTTL
+	if ttl <= 0 {
+		ttl = DefaultResultTTL
+	}
+	return NewCache(c.CacheSize, ttl)
+}
+
+func millis(ms int) time.Duration {
+	return time.Duration(ms) * time.Millisecond
 }
 
 // DefaultEnricherConfig returns default enricher configuration.
@@ -39,6 +134,7 @@ func DefaultEnricherConfig() EnricherConfig {
 		RDNSTimeout:  2000,
 		ASNTimeout:   3000,
 		GeoIPTimeout: 5000,
+		TotalTimeout: DefaultTotalTimeout,
 		CacheSize:    1000,
 	}
 }
@@ -46,19 +142,20 @@ func DefaultEnricherConfig() EnricherConfig {
 // NewEnricher creates a new enricher with the given configuration.
 func NewEnricher(config EnricherConfig) *Enricher {
 	e := &Enricher{
-		config: config,
+		config:  config,
+		results: config.newResultCache(),
 	}
 
 	if config.EnableRDNS {
-		e.rdns = NewRDNSResolver(DefaultRDNSConfig())
+		e.rdns = NewRDNSResolver(config.rdnsConfig())
 	}
 
 	if config.EnableASN {
-		e.asn = NewTeamCymruASN(DefaultTeamCymruConfig())
+		e.asn = NewTeamCymruASN(config.asnConfig())
 	}
 
 	if config.EnableGeoIP {
-		e.geo = NewIPAPIGeo(DefaultIPAPIConfig())
+		e.geo = NewIPAPIGeo(config.geoConfig())
 	}
 
 	return e
@@ -71,22 +168,23 @@ func NewEnricherWithMaxMind(config EnricherConfig, maxmindDB *MaxMindDB) *Enrich
 	e := &Enricher{
 		config:  config,
 		maxmind: maxmindDB,
+		results: config.newResultCache(),
 	}
 
 	if config.EnableRDNS {
-		e.rdns = NewRDNSResolver(DefaultRDNSConfig())
+		e.rdns = NewRDNSResolver(config.rdnsConfig())
 	}
 
 	// Only create API lookups if MaxMind doesn't have the data
 	if config.EnableASN {
 		if maxmindDB == nil || !maxmindDB.HasASN() {
-			e.asn = NewTeamCymruASN(DefaultTeamCymruConfig())
+			e.asn = NewTeamCymruASN(config.asnConfig())
 		}
 	}
 
 	if config.EnableGeoIP {
 		if maxmindDB == nil || !maxmindDB.HasGeo() {
-			e.geo = NewIPAPIGeo(DefaultIPAPIConfig())
+			e.geo = NewIPAPIGeo(config.geoConfig())
 		}
 	}
 
@@ -98,15 +196,36 @@ type EnrichmentResult struct {
 	Hostname string
 	ASN      *ASNInfo
 	Geo      *GeoInfo
+
+	// HostnameVerified reports whether Hostname resolves back to the IP,
+	// if it was checked (see EnricherConfig.VerifyRDNS)
+	HostnameVerified *bool
 }
 
 // EnrichIP enriches a single IP with additional information.
+// If ctx is done before all providers answer, the fields filled so far
+// are returned without waiting for the remaining providers.
+//
+// Complete results are cached per IP, so repeated calls for the same IP
+// (hops shared across cycles of continuous mode) skip the providers.
+// Partial results are not cached, and results a provider failed on are
+// kept only for FailedResultTTL.
 func (e *Enricher) EnrichIP(ctx context.Context, ip net.IP) *EnrichmentResult {
 	if ip == nil {
 		return nil
 	}
 
+	key := ip.String()
+	if e.results != nil {
+		if cached, ok := e.results.Get(key); ok {
+			// Hand out a copy so callers cannot change the cached entry
+			result := *cached.(*EnrichmentResult)
+			return &result
+		}
+	}
+
 	result := &EnrichmentResult{}
+	var failed bool // a provider returned an error
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 
@@ -116,8 +235,15 @@ func (e *Enricher) EnrichIP(ctx context.Context, ip net.IP) *EnrichmentResult {
 		go func() {
 			defer wg.Done()
 			hostname, _ := e.rdns.Lookup(ctx, ip)
+			var verified *bool
+			if e.rdns.verify && hostname != "" {
+				if ok, known := e.rdns.Verify(ctx, ip, hostname); known {
+					verified = &ok
+				}
+			}
 			mu.Lock()
 			result.Hostname = hostname
+			result.HostnameVerified = verified
 			mu.Unlock()
 		}()
 	}
@@ -128,19 +254,21 @@ func (e *Enricher) EnrichIP(ctx context.Context, ip net.IP) *EnrichmentResult {
 		go func() {
 			defer wg.Done()
 			var asn *ASNInfo
+			var err error
 
 			// Try MaxMind first
-			if e.maxmind != nil && e.maxmind.HasASN() {
-				asn, _ = e.maxmind.LookupASN(ip)
+			if e.maxmind != nil {
+				asn, err = e.maxmind.LookupASN(ip)
 			}
 
 			// Fall back to API if MaxMind didn't have data
-			if asn == nil && e.asn != nil {
-				asn, _ = e.asn.Lookup(ctx, ip)
+			if asn == nil && e.asn != nil && fallBack(err) {
+				asn, err = e.asn.Lookup(ctx, ip)
 			}
 
 			mu.Lock()
 			result.ASN = asn
+			failed = failed || (err != nil && !errors.Is(err, ErrDatabaseNotLoaded))
 			mu.Unlock()
 		}()
 	}
@@ -151,34 +279,81 @@ func (e *Enricher) EnrichIP(ctx context.Context, ip net.IP) *EnrichmentResult {
 		go func() {
 			defer wg.Done()
 			var geo *GeoInfo
+			var err error
 
 			// Try MaxMind first
-			if e.maxmind != nil && e.maxmind.HasGeo() {
-				geo, _ = e.maxmind.LookupGeo(ip)
+			if e.maxmind != nil {
+				geo, err = e.maxmind.LookupGeo(ip)
 			}
 
 			// Fall back to API if MaxMind didn't have data
-			if geo == nil && e.geo != nil {
-				geo, _ = e.geo.Lookup(ctx, ip)
+			if geo == nil && e.geo != nil && fallBack(err) {
+				geo, err = e.geo.Lookup(ctx, ip)
 			}
 
 			mu.Lock()
 			result.Geo = geo
+			failed = failed || (err != nil && !errors.Is(err, ErrDatabaseNotLoaded))
 			mu.Unlock()
 		}()
 	}
 
-	wg.Wait()
+	if !waitOrDone(ctx, &wg) {
+		// Providers still running may write to result; hand out a copy
+		mu.Lock()
+		partial := *result
+		mu.Unlock()
+		return &partial
+	}
+
+	e.cacheResult(key, result, failed)
 	return result
 }
 
+// cacheResult caches a complete result for the IP key. If a provider
+// failed, or online providers may still supply missing ASN or GeoIP data,
+// it is kept only for FailedResultTTL (or ResultTTL, if shorter).
+func (e *Enricher) cacheResult(key string, result *EnrichmentResult, failed bool) {
+	if e.results == nil {
+		return
+	}
+	cached := *result
+	if !failed && (isPrivateIP(net.ParseIP(key)) || (!e.retryASN(result) && !e.retryGeo(result))) {
+		e.results.Set(key, &cached)
+		return
+	}
+	ttl := FailedResultTTL
+	if e.config.ResultTTL > 0 {
+		ttl = min(ttl, e.config.ResultTTL)
+	}
+	e.results.SetWithTTL(key, &cached, ttl)
+}
+
+// fallBack reports whether to ask the online provider after a MaxMind
+// lookup returned no data with err. It is asked when the database has no
+// record or is not loaded, but not when the lookup itself failed, since
+// an online lookup of the same address would most likely fail too.
+func fallBack(err error) bool {
+	return err == nil || errors.Is(err, ErrDatabaseNotLoaded)
+}
+
 // EnrichIPs enriches multiple IPs concurrently and returns a map of results.
+// It returns when every IP is done, ctx is cancelled, or the total budget
+// runs out, whichever comes first; in the latter cases the map holds the
+// results completed so far.
 func (e *Enricher) EnrichIPs(ctx context.Context, ips []net.IP) map[string]*EnrichmentResult {
 	results := make(map[string]*EnrichmentResult)
 	if len(ips) == 0 {
 		return results
 	}
 
+	budget := e.config.TotalTimeout
+	if budget <= 0 {
+		budget = DefaultTotalTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, budget)
+	defer cancel()
+
 	var mu sync.Mutex
 	var wg sync.WaitGroup
 	sem := make(chan struct{}, 10) // Limit concurrency
@@ -201,8 +376,12 @@ func (e *Enricher) EnrichIPs(ctx context.Context, ips []net.IP) map[string]*Enri
 		go func(ip net.IP) {
 			defer wg.Done()
 
-			sem <- struct{}{}
-			defer func() { <-sem }()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				return
+			}
 
 			result := e.EnrichIP(ctx, ip)
 
@@ -212,12 +391,235 @@ func (e *Enricher) EnrichIPs(ctx context.Context, ips []net.IP) map[string]*Enri
 		}(ip)
 	}
 
-	wg.Wait()
+	if !waitOrDone(ctx, &wg) {
+		// Workers still running may write to results; hand out a copy
+		mu.Lock()
+		partial := make(map[string]*EnrichmentResult, len(results))
+		for k, v := range results {
+			partial[k] = v
+		}
+		mu.Unlock()
+		return partial
+	}
 	return results
 }
 
+// DefaultRetryDelay is how long to wait before retrying failed lookups,
+// giving a rate-limited or briefly unreachable service time to recover.
+const DefaultRetryDelay = time.Second
+
+// freshASNLookup is implemented by ASN providers that can bypass their
+// cache of failed lookups (see TeamCymruASN.LookupFresh).
+type freshASNLookup interface {
+	LookupFresh(ctx context.Context, ip net.IP) (*ASNInfo, error)
+}
+
+// freshGeoLookup is implemented by GeoIP providers that can bypass their
+// cache of failed lookups (see IPAPIGeo.LookupFresh).
+type freshGeoLookup interface {
+	LookupFresh(ctx context.Context, ip net.IP) (*GeoInfo, error)
+}
+
+// RetryMissing retries, once, the online ASN and GeoIP lookups that came
+// back empty in results (as returned by EnrichIPs) for public IPs. After
+// waiting delay, the providers are asked again past their caches of
+// failed lookups, within the same total budget as EnrichIPs.
+//
+// Recovered data is filled into results in place and replaces the cached
+// result for the IP. It returns the number of IPs retried and the number
+// that gained ASN or GeoIP data.
+func (e *Enricher) RetryMissing(ctx context.Context, results map[string]*EnrichmentResult, delay time.Duration) (retried, recovered int) {
+	var pending []string
+	for key, result := range results {
+		if result != nil && !isPrivateIP(net.ParseIP(key)) && (e.retryASN(result) || e.retryGeo(result)) {
+			pending = append(pending, key)
+		}
+	}
+	if len(pending) == 0 {
+		return 0, 0
+	}
+
+	select {
+	case <-time.After(delay):
+	case <-ctx.Done():
+		return 0, 0
+	}
+
+	budget := e.config.TotalTimeout
+	if budget <= 0 {
+		budget = DefaultTotalTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, budget)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, 10) // Limit concurrency
+	retries := make(map[string]*EnrichmentResult, len(pending))
+
+	for _, key := range pending {
+		wg.Add(1)
+		go func(key string, result EnrichmentResult) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				return
+			}
+
+			ip := net.ParseIP(key)
+			if e.retryASN(&result) {
+				result.ASN = e.lookupASNFresh(ctx, ip)
+			}
+			if e.retryGeo(&result) {
+				result.Geo = e.lookupGeoFresh(ctx, ip)
+			}
+
+			mu.Lock()
+			retries[key] = &result
+			mu.Unlock()
+		}(key, *results[key])
+	}
+
+	waitOrDone(ctx, &wg)
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, key := range pending {
+		retry, ok := retries[key]
+		if !ok {
+			continue
+		}
+		result := results[key]
+		gained := false
+		if result.ASN == nil && retry.ASN != nil {
+			result.ASN = retry.ASN
+			gained = true
+		}
+		if result.Geo == nil && retry.Geo != nil {
+			result.Geo = retry.Geo
+			gained = true
+		}
+		if !gained {
+			continue
+		}
+		recovered++
+		e.cacheResult(key, result, false)
+	}
+	return len(pending), recovered
+}
+
+// retryASN reports whether result lacks ASN data an online provider may
+// still supply.
+func (e *Enricher) retryASN(result *EnrichmentResult) bool {
+	return result.ASN == nil && e.config.EnableASN && e.asn != nil
+}
+
+// retryGeo reports whether result lacks GeoIP data an online provider may
+// still supply.
+func (e *Enricher) retryGeo(result *EnrichmentResult) bool {
+	return result.Geo == nil && e.config.EnableGeoIP && e.geo != nil
+}
+
+// lookupASNFresh looks up ip with the online ASN provider, bypassing its
+// cache of failed lookups when it has one.
+func (e *Enricher) lookupASNFresh(ctx context.Context, ip net.IP) *ASNInfo {
+	var asn *ASNInfo
+	if fresh, ok := e.asn.(freshASNLookup); ok {
+		asn, _ = fresh.LookupFresh(ctx, ip)
+	} else {
+		asn, _ = e.asn.Lookup(ctx, ip)
+	}
+	return asn
+}
+
+// lookupGeoFresh looks up ip with the online GeoIP provider, bypassing
+// its cache of failed lookups when it has one.
+func (e *Enricher) lookupGeoFresh(ctx context.Context, ip net.IP) *GeoInfo {
+	var geo *GeoInfo
+	if fresh, ok := e.geo.(freshGeoLookup); ok {
+		geo, _ = fresh.LookupFresh(ctx, ip)
+	} else {
+		geo, _ = e.geo.Lookup(ctx, ip)
+	}
+	return geo
+}
+
+// waitOrDone waits for wg and reports whether it finished before ctx was done.
+func waitOrDone(ctx context.Context, wg *sync.WaitGroup) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Providers reports the data source of each enabled provider as
+// "online" (network services) or "offline" (local MaxMind databases).
+func (e *Enricher) Providers() map[string]string {
+	providers := make(map[string]string)
+
+	if e.config.EnableRDNS && e.rdns != nil {
+		providers["rdns"] = "online"
+	}
+
+	if e.config.EnableASN {
+		if e.maxmind != nil && e.maxmind.HasASN() {
+			providers["asn"] = "offline"
+		} else if e.asn != nil {
+			providers["asn"] = "online"
+		}
+	}
+
+	if e.config.EnableGeoIP {
+		if e.maxmind != nil && e.maxmind.HasGeo() {
+			providers["geoip"] = "offline"
+		} else if e.geo != nil {
+			providers["geoip"] = "online"
+		}
+	}
+
+	return providers
+}
+
+// CacheStats implements CacheReporter, combining the caches of every
+// provider that has one.
+func (e *Enricher) CacheStats() map[string]CacheStats {
+	var reporters []CacheReporter
+	if e.rdns != nil {
+		reporters = append(reporters, e.rdns)
+	}
+	if r, ok := e.asn.(CacheReporter); ok {
+		reporters = append(reporters, r)
+	}
+	if r, ok := e.geo.(CacheReporter); ok {
+		reporters = append(reporters, r)
+	}
+
+	stats := make(map[string]CacheStats)
+	addCacheStats(stats, "results", e.results)
+	for _, r := range reporters {
+		for name, s := range r.CacheStats() {
+			stats[name] = s
+		}
+	}
+	return stats
+}
+
 // Close releases resources held by the enricher.
 func (e *Enricher) Close() error {
+	if e.results != nil {
+		e.results.Clear()
+	}
 	if e.rdns != nil {
 		e.rdns.Close()
 	}
@@ -7,6 +7,8 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 )
 
@@ -15,10 +17,16 @@ type GeoInfo struct {
 	Country     string
 	CountryCode string
 	City        string
-	Region      string
+	Region      string // state or province, e.g. "Virginia"
+	RegionCode  string // its code, e.g. "VA"
 	Latitude    float64
 	Longitude   float64
 	Timezone    string
+	ISP         string
+	Hosting     bool   // Data center / cloud provider
+	Proxy       bool   // Proxy, VPN or Tor exit
+	Mobile      bool   // Mobile carrier
+	Source      string // Provider that supplied the data, e.g. SourceIPAPI
 }
 
 // GeoLookup defines the interface for GeoIP lookups.
@@ -33,13 +41,32 @@ type IPAPIGeo struct {
 	client  *http.Client
 	timeout time.Duration
 	cache   *Cache
+	baseURL string
+	apiKey  string
 }
 
+// ipAPIFields lists the response fields requested from ip-api.com.
+const ipAPIFields = "status,message,country,countryCode,region,regionName,city,lat,lon,timezone,isp,org,as,hosting,proxy,mobile"
+
+// ip-api.com endpoints. The free endpoint only speaks plain HTTP; the
+// pro endpoint requires an API key.
+const (
+	IPAPIFreeURL = "http://ip-api.com/json/"
+	IPAPIProURL  = "https://pro.ip-api.com/json/"
+)
+
 // IPAPIConfig holds configuration for ip-api.com lookups.
 type IPAPIConfig struct {
 	Timeout   time.Duration
 	CacheSize int
 	CacheTTL  time.Duration
+	BaseURL   string       // Endpoint prefix; the IP is appended (empty = see IPAPIURL)
+	APIKey    string       // ip-api.com pro key, sent as the key parameter
+	Client    *http.Client // nil = one from HTTPClient with Timeout
+
+	// HTTPClient creates the client when Client is nil (nil = direct
+	// connections or the environment's proxy)
+	HTTPClient *HTTPClientFactory
 }
 
 // DefaultIPAPIConfig returns default configuration.
@@ -51,6 +78,48 @@ func DefaultIPAPIConfig() IPAPIConfig {
 	}
 }
 
+// IPAPIURL returns the endpoint prefix used for baseURL and apiKey: the
+// given URL with a trailing slash, or the pro HTTPS endpoint when only a
+// key is set, or the free plain HTTP endpoint.
+func IPAPIURL(baseURL, apiKey string) string {
+	switch {
+	case baseURL != "":
+		if !strings.HasSuffix(baseURL, "/") {
+			baseURL += "/"
+		}
+		return baseURL
+	case apiKey != "":
+		return IPAPIProURL
+	default:
+		return IPAPIFreeURL
+	}
+}
+
+// ValidateIPAPIURL checks that raw is an absolute http or https URL
+// without query or fragment, to which the IP can be appended.
+func ValidateIPAPIURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%q: scheme must be http or https", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%q: missing host", raw)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("%q: must not have a query or fragment", raw)
+	}
+	return nil
+}
+
+// IsPlaintextURL reports whether lookups to the endpoint prefix are sent
+// unencrypted.
+func IsPlaintextURL(baseURL string) bool {
+	return strings.HasPrefix(strings.ToLower(baseURL), "http://")
+}
+
 // NewIPAPIGeo creates a new ip-api.com GeoIP resolver.
 func NewIPAPIGeo(config IPAPIConfig) *IPAPIGeo {
 	if config.Timeout == 0 {
@@ -62,12 +131,17 @@ func NewIPAPIGeo(config IPAPIConfig) *IPAPIGeo {
 		cache = NewCache(config.CacheSize, config.CacheTTL)
 	}
 
+	client := config.Client
+	if client == nil {
+		client = config.HTTPClient.Client(config.Timeout)
+	}
+
 	return &IPAPIGeo{
-		client: &http.Client{
-			Timeout: config.Timeout,
-		},
+		client:  client,
 		timeout: config.Timeout,
 		cache:   cache,
+		baseURL: IPAPIURL(config.BaseURL, config.APIKey),
+		apiKey:  config.APIKey,
 	}
 }
 
@@ -82,11 +156,28 @@ type ipAPIResponse struct {
 	Lat         float64 `json:"lat"`
 	Lon         float64 `json:"lon"`
 	Timezone    string  `json:"timezone"`
+	ISP         string  `json:"isp"`
+	Org         string  `json:"org"`
+	AS          string  `json:"as"`
+	Hosting     bool    `json:"hosting"`
+	Proxy       bool    `json:"proxy"`
+	Mobile      bool    `json:"mobile"`
 	Message     string  `json:"message"`
 }
 
 // Lookup performs a GeoIP lookup using ip-api.com.
 func (g *IPAPIGeo) Lookup(ctx context.Context, ip net.IP) (*GeoInfo, error) {
+	return g.lookup(ctx, ip, false)
+}
+
+// LookupFresh is like Lookup but ignores a cached failed lookup for ip,
+// so a transient HTTP failure can be retried within the cache TTL.
+func (g *IPAPIGeo) LookupFresh(ctx context.Context, ip net.IP) (*GeoInfo, error) {
+	return g.lookup(ctx, ip, true)
+}
+
+// lookup implements Lookup; fresh skips cached failures.
+func (g *IPAPIGeo) lookup(ctx context.Context, ip net.IP, fresh bool) (*GeoInfo, error) {
 	if ip == nil {
 		return nil, nil
 	}
@@ -100,7 +191,7 @@ func (g *IPAPIGeo) Lookup(ctx context.Context, ip net.IP) (*GeoInfo, error) {
 
 	// Check cache
 	if g.cache != nil {
-		if cached, ok := g.cache.Get(ipStr); ok {
+		if cached, ok := g.cache.Get(ipStr); ok && (cached != nil || !fresh) {
 			if cached == nil {
 				return nil, nil
 			}
@@ -109,9 +200,13 @@ func (g *IPAPIGeo) Lookup(ctx context.Context, ip net.IP) (*GeoInfo, error) {
 	}
 
 	// Build request
-	url := fmt.Sprintf("http://ip-api.com/json/%s?fields=status,message,country,countryCode,region,regionName,city,lat,lon,timezone", ipStr)
+	query := url.Values{"fields": {ipAPIFields}}
+	if g.apiKey != "" {
+		query.Set("key", g.apiKey)
+	}
+	reqURL := g.baseURL + ipStr + "?" + query.Encode()
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -152,9 +247,18 @@ func (g *IPAPIGeo) Lookup(ctx context.Context, ip net.IP) (*GeoInfo, error) {
 		CountryCode: apiResp.CountryCode,
 		City:        apiResp.City,
 		Region:      apiResp.RegionName,
+		RegionCode:  apiResp.Region,
 		Latitude:    apiResp.Lat,
 		Longitude:   apiResp.Lon,
 		Timezone:    apiResp.Timezone,
+		ISP:         apiResp.ISP,
+		Hosting:     apiResp.Hosting,
+		Proxy:       apiResp.Proxy,
+		Mobile:      apiResp.Mobile,
+		Source:      SourceIPAPI,
+	}
+	if info.ISP == "" {
+		info.ISP = apiResp.Org
 	}
 
 	// Cache result
@@ -165,6 +269,13 @@ func (g *IPAPIGeo) Lookup(ctx context.Context, ip net.IP) (*GeoInfo, error) {
 	return info, nil
 }
 
+// CacheStats implements CacheReporter.
+func (g *IPAPIGeo) CacheStats() map[string]CacheStats {
+	stats := make(map[string]CacheStats)
+	addCacheStats(stats, "geoip", g.cache)
+	return stats
+}
+
 // Close releases resources.
 func (g *IPAPIGeo) Close() error {
 	if g.cache != nil {
@@ -0,0 +1,132 @@
+package enrich
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// projectURL identifies poros in the User-Agent header.
+const projectURL = "https://github.com/KilimcininKorOglu/poros"
+
+// DefaultUserAgent is sent with enrichment requests when no user agent is
+// configured.
+const DefaultUserAgent = "poros (+" + projectURL + ")"
+
+// UserAgent returns the User-Agent header value identifying poros at the
+// given version, e.g. "poros/1.2.0 (+https://github.com/...)".
+func UserAgent(version string) string {
+	if version == "" {
+		return DefaultUserAgent
+	}
+	return "poros/" + version + " (+" + projectURL + ")"
+}
+
+// HTTPClientFactory creates the HTTP clients of the online enrichment
+// providers (ip-api.com) and the MaxMind downloader, so that every
+// outbound request shares one proxy, TLS and User-Agent setup. A nil
+// factory creates clients with the defaults described on each field.
+type HTTPClientFactory struct {
+	// Proxy routes requests through an HTTP, HTTPS or SOCKS5 proxy
+	// (nil = HTTP_PROXY, HTTPS_PROXY and NO_PROXY from the environment)
+	Proxy *url.URL
+
+	// TLSConfig is used for HTTPS requests (nil = Go defaults)
+	TLSConfig *tls.Config
+
+	// UserAgent is sent with every request (empty = DefaultUserAgent)
+	UserAgent string
+
+	// Transport, if set, carries the requests instead of a transport
+	// built from Proxy and TLSConfig, e.g. to record them in tests
+	Transport http.RoundTripper
+}
+
+// Timeouts of the transport built by HTTPClientFactory. The total time of
+// a request is bounded by the timeout passed to Client.
+const (
+	httpDialTimeout         = 10 * time.Second
+	httpTLSHandshakeTimeout = 10 * time.Second
+	httpIdleConnTimeout     = 90 * time.Second
+)
+
+// Client returns a client whose requests, including reading the response
+// body, time out after timeout (0 = no limit).
+func (f *HTTPClientFactory) Client(timeout time.Duration) *http.Client {
+	var factory HTTPClientFactory
+	if f != nil {
+		factory = *f
+	}
+
+	transport := factory.Transport
+	if transport == nil {
+		transport = factory.transport()
+	}
+	userAgent := factory.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &userAgentTransport{base: transport, userAgent: userAgent},
+	}
+}
+
+// transport builds the transport for Proxy and TLSConfig.
+func (f HTTPClientFactory) transport() *http.Transport {
+	proxy := http.ProxyFromEnvironment
+	if f.Proxy != nil {
+		proxy = http.ProxyURL(f.Proxy)
+	}
+	dialer := &net.Dialer{Timeout: httpDialTimeout, KeepAlive: 30 * time.Second}
+	return &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       f.TLSConfig,
+		TLSHandshakeTimeout:   httpTLSHandshakeTimeout,
+		IdleConnTimeout:       httpIdleConnTimeout,
+		MaxIdleConns:          10,
+		ExpectContinueTimeout: time.Second,
+		ForceAttemptHTTP2:     true,
+	}
+}
+
+// userAgentTransport sets the User-Agent header of requests that have
+// none.
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		// A RoundTripper must not change the caller's request
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// ParseProxyURL parses an HTTP proxy setting such as
+// "http://proxy.example.com:3128". The scheme must be http, https or
+// socks5, and a host is required.
+func ParseProxyURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("%q: scheme must be http, https or socks5", raw)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("%q: missing host", raw)
+	}
+	return u, nil
+}
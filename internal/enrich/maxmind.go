@@ -5,6 +5,7 @@ import (
 	"archive/tar"
 	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -25,6 +26,7 @@ type MaxMindDB struct {
 	licenseKey string
 	asnPath    string
 	geoPath    string
+	httpClient *HTTPClientFactory
 	mu         sync.RWMutex
 }
 
@@ -35,6 +37,10 @@ type MaxMindDBConfig struct {
 	GeoDBPath   string // Path to GeoLite2-City.mmdb
 	AutoUpdate  bool   // Enable auto-update check
 	UpdateHours int    // Hours between update checks
+
+	// HTTPClient creates the download client (nil = direct connections
+	// or the environment's proxy)
+	HTTPClient *HTTPClientFactory
 }
 
 // MaxMind ASN record structure
@@ -70,6 +76,7 @@ func NewMaxMindDB(config MaxMindDBConfig) (*MaxMindDB, error) {
 		licenseKey: config.LicenseKey,
 		asnPath:    config.ASNDBPath,
 		geoPath:    config.GeoDBPath,
+		httpClient: config.HTTPClient,
 	}
 
 	// Try to open ASN database
@@ -114,17 +121,25 @@ func (db *MaxMindDB) HasGeo() bool {
 	return db.geoDB != nil
 }
 
-// LookupASN looks up ASN information for an IP address.
+// ErrDatabaseNotLoaded is returned by lookups in a database that is not
+// loaded; the data may still be available from an online provider.
+var ErrDatabaseNotLoaded = errors.New("database not loaded")
+
+// LookupASN looks up ASN information for an IP address. Private and
+// other non-routable addresses have no ASN data and are not looked up.
 func (db *MaxMindDB) LookupASN(ip net.IP) (*ASNInfo, error) {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
 	if db.asnDB == nil {
-		return nil, fmt.Errorf("ASN database not loaded")
+		return nil, fmt.Errorf("ASN %w", ErrDatabaseNotLoaded)
+	}
+	if isPrivateIP(ip) {
+		return nil, nil
 	}
 
 	var record maxmindASNRecord
-	err := db.asnDB.Lookup(ip, &record)
+	network, _, err := db.asnDB.LookupNetwork(ip, &record)
 	if err != nil {
 		return nil, err
 	}
@@ -139,20 +154,31 @@ func (db *MaxMindDB) LookupASN(ip net.IP) (*ASNInfo, error) {
 		country = record.AutonomousSystemOrganization[idx+2:]
 	}
 
-	return &ASNInfo{
+	info := &ASNInfo{
 		Number:  int(record.AutonomousSystemNumber),
 		Org:     record.AutonomousSystemOrganization,
 		Country: country,
-	}, nil
+		Source:  SourceMaxMind,
+	}
+	if network != nil {
+		info.Prefix = network.String()
+	}
+
+	return info, nil
 }
 
-// LookupGeo looks up geographic information for an IP address.
+// LookupGeo looks up geographic information for an IP address. Private
+// and other non-routable addresses are not looked up, so a stale custom
+// database cannot place them.
 func (db *MaxMindDB) LookupGeo(ip net.IP) (*GeoInfo, error) {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
 	if db.geoDB == nil {
-		return nil, fmt.Errorf("GeoIP database not loaded")
+		return nil, fmt.Errorf("GeoIP %w", ErrDatabaseNotLoaded)
+	}
+	if isPrivateIP(ip) {
+		return nil, nil
 	}
 
 	var record maxmindCityRecord
@@ -166,6 +192,7 @@ func (db *MaxMindDB) LookupGeo(ip net.IP) (*GeoInfo, error) {
 		Latitude:    record.Location.Latitude,
 		Longitude:   record.Location.Longitude,
 		Timezone:    record.Location.TimeZone,
+		Source:      SourceMaxMind,
 	}
 
 	// Get English names
@@ -176,6 +203,7 @@ func (db *MaxMindDB) LookupGeo(ip net.IP) (*GeoInfo, error) {
 		info.City = name
 	}
 	if len(record.Subdivisions) > 0 {
+		info.RegionCode = record.Subdivisions[0].ISOCode
 		if name, ok := record.Subdivisions[0].Names["en"]; ok {
 			info.Region = name
 		}
@@ -209,8 +237,19 @@ func (db *MaxMindDB) Close() error {
 	return nil
 }
 
-// NeedsUpdate checks if databases need to be updated.
+// Offline reports whether no license key is configured. Offline databases
+// are used as they are and never downloaded or updated.
+func (db *MaxMindDB) Offline() bool {
+	return db.licenseKey == ""
+}
+
+// NeedsUpdate checks if databases need to be updated. It is always false
+// for offline databases.
 func (db *MaxMindDB) NeedsUpdate(maxAge time.Duration) bool {
+	if db.Offline() {
+		return false
+	}
+
 	// Check ASN database age
 	if db.asnPath != "" {
 		if info, err := os.Stat(db.asnPath); err != nil || time.Since(info.ModTime()) > maxAge {
@@ -264,8 +303,7 @@ func (db *MaxMindDB) downloadDatabase(ctx context.Context, edition, destPath str
 		return err
 	}
 
-	client := &http.Client{Timeout: 5 * time.Minute}
-	resp, err := client.Do(req)
+	resp, err := db.httpClient.Client(5 * time.Minute).Do(req)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,430 @@
+package enrich
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// mmdbNetwork maps an IPv4 prefix to a record in a test database.
+type mmdbNetwork struct {
+	cidr   string
+	record map[string]any
+}
+
+// writeTestMMDB writes a minimal IPv4 MaxMind DB (24-bit records) holding
+// the given networks, so MaxMind lookups can be tested without the real
+// GeoLite2 files.
+func writeTestMMDB(t *testing.T, path, dbType string, networks []mmdbNetwork) {
+	t.Helper()
+
+	const (
+		empty = -1
+		data  = -2 // data record k is stored as data-k
+	)
+
+	// Build the search tree, one node per bit of each prefix
+	nodes := [][2]int{{empty, empty}}
+	var section []byte
+	var offsets []int
+	for k, n := range networks {
+		_, ipnet, err := net.ParseCIDR(n.cidr)
+		if err != nil {
+			t.Fatalf("ParseCIDR(%q) error = %v", n.cidr, err)
+		}
+		ip := ipnet.IP.To4()
+		bits, _ := ipnet.Mask.Size()
+
+		offsets = append(offsets, len(section))
+		section = append(section, mmdbEncode(n.record)...)
+
+		node := 0
+		for i := 0; i < bits; i++ {
+			bit := int(ip[i/8]>>(7-uint(i%8))) & 1
+			if i == bits-1 {
+				nodes[node][bit] = data - k
+				break
+			}
+			if nodes[node][bit] == empty {
+				nodes = append(nodes, [2]int{empty, empty})
+				nodes[node][bit] = len(nodes) - 1
+			}
+			node = nodes[node][bit]
+		}
+	}
+
+	nodeCount := len(nodes)
+	var file []byte
+	for _, node := range nodes {
+		for _, rec := range node {
+			value := rec
+			switch {
+			case rec == empty:
+				value = nodeCount
+			case rec <= data:
+				value = nodeCount + 16 + offsets[data-rec]
+			}
+			file = append(file, byte(value>>16), byte(value>>8), byte(value))
+		}
+	}
+	file = append(file, make([]byte, 16)...)
+	file = append(file, section...)
+
+	file = append(file, "\xab\xcd\xefMaxMind.com"...)
+	file = append(file, mmdbEncode(map[string]any{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(time.Now().Unix()),
+		"database_type":               dbType,
+		"description":                 map[string]any{"en": "poros test database"},
+		"ip_version":                  uint16(4),
+		"languages":                   []any{"en"},
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(24),
+	})...)
+
+	if err := os.WriteFile(path, file, 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+}
+
+// mmdbEncode encodes a value in the MaxMind DB data section format.
+func mmdbEncode(v any) []byte {
+	switch v := v.(type) {
+	case string:
+		return append(mmdbControl(2, len(v)), v...)
+	case float64:
+		b := mmdbControl(3, 8)
+		return binary.BigEndian.AppendUint64(b, math.Float64bits(v))
+	case uint16:
+		return mmdbUint(5, uint64(v))
+	case uint32:
+		return mmdbUint(6, uint64(v))
+	case uint64:
+		return mmdbUint(9, v)
+	case []any:
+		b := mmdbControl(11, len(v))
+		for _, item := range v {
+			b = append(b, mmdbEncode(item)...)
+		}
+		return b
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		b := mmdbControl(7, len(v))
+		for _, key := range keys {
+			b = append(b, mmdbEncode(key)...)
+			b = append(b, mmdbEncode(v[key])...)
+		}
+		return b
+	}
+	panic("mmdbEncode: unsupported type")
+}
+
+// mmdbUint encodes an unsigned integer in the fewest bytes.
+func mmdbUint(typ int, v uint64) []byte {
+	var digits []byte
+	for ; v > 0; v >>= 8 {
+		digits = append([]byte{byte(v)}, digits...)
+	}
+	return append(mmdbControl(typ, len(digits)), digits...)
+}
+
+// mmdbControl encodes a control byte with its extended type and size.
+func mmdbControl(typ, size int) []byte {
+	var b []byte
+	if typ <= 7 {
+		b = []byte{byte(typ << 5)}
+	} else {
+		b = []byte{0, byte(typ - 7)}
+	}
+	switch {
+	case size < 29:
+		b[0] |= byte(size)
+	case size < 29+256:
+		b[0] |= 29
+		b = append(b, byte(size-29))
+	default:
+		b[0] |= 30
+		b = binary.BigEndian.AppendUint16(b, uint16(size-285))
+	}
+	return b
+}
+
+// writeTestGeoLite writes ASN and City fixtures for 8.8.8.0/24 into dir.
+func writeTestGeoLite(t *testing.T, dir string) (asnPath, cityPath string) {
+	t.Helper()
+
+	asnPath = filepath.Join(dir, "GeoLite2-ASN.mmdb")
+	writeTestMMDB(t, asnPath, "GeoLite2-ASN", []mmdbNetwork{
+		{"8.8.8.0/24", map[string]any{
+			"autonomous_system_number":       uint32(15169),
+			"autonomous_system_organization": "GOOGLE, US",
+		}},
+		{"1.1.1.0/24", map[string]any{
+			"autonomous_system_number":       uint32(13335),
+			"autonomous_system_organization": "CLOUDFLARENET",
+		}},
+	})
+
+	cityPath = filepath.Join(dir, "GeoLite2-City.mmdb")
+	writeTestMMDB(t, cityPath, "GeoLite2-City", []mmdbNetwork{
+		{"8.8.8.0/24", map[string]any{
+			"city":    map[string]any{"names": map[string]any{"en": "Mountain View"}},
+			"country": map[string]any{"iso_code": "US", "names": map[string]any{"en": "United States"}},
+			"location": map[string]any{
+				"latitude":  37.386,
+				"longitude": -122.0838,
+				"time_zone": "America/Los_Angeles",
+			},
+			"subdivisions": []any{map[string]any{"iso_code": "CA", "names": map[string]any{"en": "California"}}},
+		}},
+	})
+	return asnPath, cityPath
+}
+
+func TestMaxMindDB_OfflineLookups(t *testing.T) {
+	asnPath, cityPath := writeTestGeoLite(t, t.TempDir())
+
+	db, err := NewMaxMindDB(MaxMindDBConfig{ASNDBPath: asnPath, GeoDBPath: cityPath})
+	if err != nil {
+		t.Fatalf("NewMaxMindDB() error = %v", err)
+	}
+	defer db.Close()
+
+	if !db.Offline() || !db.HasASN() || !db.HasGeo() {
+		t.Fatalf("Offline/HasASN/HasGeo = %v/%v/%v, want all true", db.Offline(), db.HasASN(), db.HasGeo())
+	}
+
+	asn, err := db.LookupASN(net.ParseIP("8.8.8.8"))
+	if err != nil {
+		t.Fatalf("LookupASN() error = %v", err)
+	}
+	if asn == nil || asn.Number != 15169 || asn.Org != "GOOGLE, US" || asn.Country != "US" || asn.Prefix != "8.8.8.0/24" ||
+		asn.Source != SourceMaxMind {
+		t.Errorf("LookupASN(8.8.8.8) = %+v", asn)
+	}
+	if asn, err := db.LookupASN(net.ParseIP("1.1.1.1")); err != nil || asn == nil || asn.Number != 13335 {
+		t.Errorf("LookupASN(1.1.1.1) = %+v, %v", asn, err)
+	}
+	if asn, err := db.LookupASN(net.ParseIP("9.9.9.9")); err != nil || asn != nil {
+		t.Errorf("LookupASN(9.9.9.9) = %+v, %v; want no data", asn, err)
+	}
+
+	geo, err := db.LookupGeo(net.ParseIP("8.8.8.8"))
+	if err != nil {
+		t.Fatalf("LookupGeo() error = %v", err)
+	}
+	if geo.CountryCode != "US" || geo.Country != "United States" || geo.City != "Mountain View" ||
+		geo.Region != "California" || geo.RegionCode != "CA" || geo.Timezone != "America/Los_Angeles" || geo.Latitude != 37.386 ||
+		geo.Source != SourceMaxMind {
+		t.Errorf("LookupGeo(8.8.8.8) = %+v", geo)
+	}
+}
+
+func TestMaxMindDB_OfflineNeverUpdates(t *testing.T) {
+	asnPath, cityPath := writeTestGeoLite(t, t.TempDir())
+
+	// Files far older than any update interval
+	old := time.Now().Add(-365 * 24 * time.Hour)
+	for _, path := range []string{asnPath, cityPath} {
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatalf("Chtimes() error = %v", err)
+		}
+	}
+
+	db, err := NewMaxMindDB(MaxMindDBConfig{ASNDBPath: asnPath, GeoDBPath: cityPath})
+	if err != nil {
+		t.Fatalf("NewMaxMindDB() error = %v", err)
+	}
+	defer db.Close()
+
+	if db.NeedsUpdate(time.Hour) {
+		t.Error("NeedsUpdate() = true without a license key")
+	}
+	if err := db.UpdateIfNeeded(context.Background(), time.Hour); err != nil {
+		t.Errorf("UpdateIfNeeded() error = %v, want no download attempt", err)
+	}
+
+	online, err := NewMaxMindDB(MaxMindDBConfig{LicenseKey: "key", ASNDBPath: asnPath, GeoDBPath: cityPath})
+	if err != nil {
+		t.Fatalf("NewMaxMindDB() error = %v", err)
+	}
+	defer online.Close()
+	if online.Offline() || !online.NeedsUpdate(time.Hour) {
+		t.Error("databases with a license key should be updated when stale")
+	}
+}
+
+func TestMaxMindDB_MissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	db, err := NewMaxMindDB(MaxMindDBConfig{
+		ASNDBPath: filepath.Join(dir, "GeoLite2-ASN.mmdb"),
+		GeoDBPath: filepath.Join(dir, "GeoLite2-City.mmdb"),
+	})
+	if err != nil {
+		t.Fatalf("NewMaxMindDB() error = %v, want missing files tolerated", err)
+	}
+	defer db.Close()
+
+	if db.HasASN() || db.HasGeo() {
+		t.Error("no database should be loaded from an empty directory")
+	}
+}
+
+func TestEnricher_OfflineMaxMind(t *testing.T) {
+	asnPath, cityPath := writeTestGeoLite(t, t.TempDir())
+
+	db, err := NewMaxMindDB(MaxMindDBConfig{ASNDBPath: asnPath, GeoDBPath: cityPath})
+	if err != nil {
+		t.Fatalf("NewMaxMindDB() error = %v", err)
+	}
+	defer db.Close()
+
+	config := DefaultEnricherConfig()
+	config.EnableRDNS = false
+	e := NewEnricherWithMaxMind(config, db)
+
+	if e.asn != nil || e.geo != nil {
+		t.Error("online providers should not be created when MaxMind has the data")
+	}
+	if providers := e.Providers(); providers["asn"] != "offline" || providers["geoip"] != "offline" {
+		t.Errorf("Providers() = %v, want asn and geoip offline", providers)
+	}
+
+	result := e.EnrichIP(context.Background(), net.ParseIP("8.8.8.8"))
+	if result == nil || result.ASN == nil || result.ASN.Number != 15169 || result.Geo == nil || result.Geo.City != "Mountain View" {
+		t.Errorf("EnrichIP(8.8.8.8) = %+v", result)
+	}
+}
+
+func TestEnricher_SourceFallback(t *testing.T) {
+	asnPath, cityPath := writeTestGeoLite(t, t.TempDir())
+
+	db, err := NewMaxMindDB(MaxMindDBConfig{ASNDBPath: asnPath, GeoDBPath: cityPath})
+	if err != nil {
+		t.Fatalf("NewMaxMindDB() error = %v", err)
+	}
+	defer db.Close()
+
+	// MaxMind answers first; the online provider fills in what it lacks
+	e := &Enricher{
+		config:  EnricherConfig{EnableASN: true},
+		maxmind: db,
+		asn:     slowASN{},
+	}
+
+	if result := e.EnrichIP(context.Background(), net.ParseIP("8.8.8.8")); result.ASN == nil || result.ASN.Source != SourceMaxMind {
+		t.Errorf("EnrichIP(8.8.8.8).ASN = %+v, want source %q", result.ASN, SourceMaxMind)
+	}
+	if result := e.EnrichIP(context.Background(), net.ParseIP("9.9.9.9")); result.ASN == nil || result.ASN.Source != SourceCymru {
+		t.Errorf("EnrichIP(9.9.9.9).ASN = %+v, want fallback source %q", result.ASN, SourceCymru)
+	}
+}
+
+func TestMaxMindDB_PrivateIPs(t *testing.T) {
+	// A stale custom database that places private ranges somewhere
+	dir := t.TempDir()
+	asnPath := filepath.Join(dir, "GeoLite2-ASN.mmdb")
+	writeTestMMDB(t, asnPath, "GeoLite2-ASN", []mmdbNetwork{
+		{"10.0.0.0/8", map[string]any{"autonomous_system_number": uint32(64512), "autonomous_system_organization": "BOGUS"}},
+		{"8.8.8.0/24", map[string]any{"autonomous_system_number": uint32(15169), "autonomous_system_organization": "GOOGLE, US"}},
+	})
+	cityPath := filepath.Join(dir, "GeoLite2-City.mmdb")
+	writeTestMMDB(t, cityPath, "GeoLite2-City", []mmdbNetwork{
+		{"192.168.0.0/16", map[string]any{"country": map[string]any{"iso_code": "AQ"}}},
+		{"8.8.8.0/24", map[string]any{"country": map[string]any{"iso_code": "US"}}},
+	})
+
+	db, err := NewMaxMindDB(MaxMindDBConfig{ASNDBPath: asnPath, GeoDBPath: cityPath})
+	if err != nil {
+		t.Fatalf("NewMaxMindDB() error = %v", err)
+	}
+	defer db.Close()
+
+	if asn, err := db.LookupASN(net.ParseIP("10.1.2.3")); asn != nil || err != nil {
+		t.Errorf("LookupASN(10.1.2.3) = %+v, %v; want nil, nil for a private address", asn, err)
+	}
+	if geo, err := db.LookupGeo(net.ParseIP("192.168.1.1")); geo != nil || err != nil {
+		t.Errorf("LookupGeo(192.168.1.1) = %+v, %v; want nil, nil for a private address", geo, err)
+	}
+	if asn, err := db.LookupASN(net.ParseIP("8.8.8.8")); err != nil || asn == nil || asn.Number != 15169 {
+		t.Errorf("LookupASN(8.8.8.8) = %+v, %v; public addresses should still be looked up", asn, err)
+	}
+	if geo, err := db.LookupGeo(net.ParseIP("8.8.8.8")); err != nil || geo == nil || geo.CountryCode != "US" {
+		t.Errorf("LookupGeo(8.8.8.8) = %+v, %v; public addresses should still be looked up", geo, err)
+	}
+}
+
+func TestMaxMindDB_NotLoaded(t *testing.T) {
+	db, err := NewMaxMindDB(MaxMindDBConfig{ASNDBPath: filepath.Join(t.TempDir(), "GeoLite2-ASN.mmdb")})
+	if err != nil {
+		t.Fatalf("NewMaxMindDB() error = %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.LookupASN(net.ParseIP("8.8.8.8")); !errors.Is(err, ErrDatabaseNotLoaded) {
+		t.Errorf("LookupASN() error = %v, want ErrDatabaseNotLoaded", err)
+	}
+	if _, err := db.LookupGeo(net.ParseIP("10.0.0.1")); !errors.Is(err, ErrDatabaseNotLoaded) {
+		t.Errorf("LookupGeo() error = %v, want ErrDatabaseNotLoaded even for a private address", err)
+	}
+}
+
+func TestEnricher_MaxMindFallbackDecision(t *testing.T) {
+	asnPath, cityPath := writeTestGeoLite(t, t.TempDir())
+	full, err := NewMaxMindDB(MaxMindDBConfig{ASNDBPath: asnPath, GeoDBPath: cityPath})
+	if err != nil {
+		t.Fatalf("NewMaxMindDB() error = %v", err)
+	}
+	defer full.Close()
+	cityOnly, err := NewMaxMindDB(MaxMindDBConfig{GeoDBPath: cityPath})
+	if err != nil {
+		t.Fatalf("NewMaxMindDB() error = %v", err)
+	}
+	defer cityOnly.Close()
+
+	// The test databases are IPv4-only, so looking up an IPv6 address fails
+	v6 := net.ParseIP("2001:4860:4860::8888")
+	if _, err := full.LookupASN(v6); err == nil || errors.Is(err, ErrDatabaseNotLoaded) {
+		t.Fatalf("LookupASN(%s) error = %v, want a failed lookup", v6, err)
+	}
+
+	tests := []struct {
+		name       string
+		db         *MaxMindDB
+		ip         string
+		wantSource string // "" = no ASN
+	}{
+		{"found in database", full, "8.8.8.8", SourceMaxMind},
+		{"no record", full, "9.9.9.9", SourceCymru},
+		{"database not loaded", cityOnly, "8.8.8.8", SourceCymru},
+		{"lookup failed", full, v6.String(), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Enricher{
+				config:  EnricherConfig{EnableASN: true},
+				maxmind: tt.db,
+				asn:     slowASN{},
+			}
+			result := e.EnrichIP(context.Background(), net.ParseIP(tt.ip))
+			switch {
+			case tt.wantSource == "" && result.ASN != nil:
+				t.Errorf("EnrichIP(%s).ASN = %+v, want no online fallback", tt.ip, result.ASN)
+			case tt.wantSource != "" && (result.ASN == nil || result.ASN.Source != tt.wantSource):
+				t.Errorf("EnrichIP(%s).ASN = %+v, want source %q", tt.ip, result.ASN, tt.wantSource)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package enrich
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// System files naming DNS servers and static host names on Unix-like
+// systems.
+const (
+	resolvConf = "/etc/resolv.conf"
+	hostsFile  = "/etc/hosts"
+)
+
+// errNoAnswer is returned for replies without a usable answer, e.g.
+// SERVFAIL or a truncated reply.
+var errNoAnswer = errors.New("no usable PTR answer")
+
+// ptrAnswer is the outcome of a PTR query with the time it may be cached.
+type ptrAnswer struct {
+	name string        // without the trailing dot; "" for NXDOMAIN
+	ttl  time.Duration // 0 = not cacheable, unknownTTL = not stated
+}
+
+// unknownTTL marks negative answers without an SOA record, whose TTL is
+// not stated.
+const unknownTTL time.Duration = -1
+
+// systemNameservers returns the nameservers of /etc/resolv.conf as
+// host:port addresses, or nil if there are none, e.g. on Windows.
+func systemNameservers() []string {
+	f, err := os.Open(resolvConf)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var servers []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "nameserver" {
+			continue
+		}
+		// Drop an IPv6 zone, which the dialer does not accept for all
+		// address forms
+		addr, _, _ := strings.Cut(fields[1], "%")
+		if net.ParseIP(addr) != nil {
+			servers = append(servers, net.JoinHostPort(addr, "53"))
+		}
+	}
+	return servers
+}
+
+// hostsNames maps the addresses of an /etc/hosts file to their first
+// name, which the system resolver would return before asking DNS.
+func hostsNames(path string) map[string]string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	names := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		ip := net.ParseIP(fields[0])
+		if ip == nil {
+			continue
+		}
+		if _, ok := names[ip.String()]; !ok {
+			names[ip.String()] = strings.TrimSuffix(fields[1], ".")
+		}
+	}
+	return names
+}
+
+// reverseName returns the in-addr.arpa or ip6.arpa name of ip.
+func reverseName(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa.", ip4[3], ip4[2], ip4[1], ip4[0])
+	}
+
+	const hexDigits = "0123456789abcdef"
+	ip16 := ip.To16()
+	var b strings.Builder
+	for i := len(ip16) - 1; i >= 0; i-- {
+		b.WriteByte(hexDigits[ip16[i]&0x0f])
+		b.WriteByte('.')
+		b.WriteByte(hexDigits[ip16[i]>>4])
+		b.WriteByte('.')
+	}
+	b.WriteString("ip6.arpa.")
+	return b.String()
+}
+
+// queryPTR asks server for the PTR record of ip over UDP. A positive
+// answer is cacheable for the lowest TTL of its PTR records; NXDOMAIN for
+// the SOA minimum of the authority section, capped by the SOA record's
+// own TTL (RFC 2308).
+func queryPTR(ctx context.Context, server string, ip net.IP) (ptrAnswer, error) {
+	name, err := dnsmessage.NewName(reverseName(ip))
+	if err != nil {
+		return ptrAnswer{}, err
+	}
+
+	id := uint16(rand.IntN(1 << 16))
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET}},
+	}
+	packet, err := query.Pack()
+	if err != nil {
+		return ptrAnswer{}, err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", server)
+	if err != nil {
+		return ptrAnswer{}, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write(packet); err != nil {
+		return ptrAnswer{}, err
+	}
+
+	buf := make([]byte, 1232) // EDNS-safe UDP payload size
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return ptrAnswer{}, err
+		}
+
+		var reply dnsmessage.Message
+		if err := reply.Unpack(buf[:n]); err != nil || reply.ID != id || !reply.Response {
+			continue // stray or malformed datagram
+		}
+		return parsePTRReply(&reply)
+	}
+}
+
+// parsePTRReply extracts the answer to a PTR query. PTR records are taken
+// from any owner name, since classless delegation (RFC 2317) answers
+// through a CNAME.
+func parsePTRReply(reply *dnsmessage.Message) (ptrAnswer, error) {
+	if reply.Truncated {
+		return ptrAnswer{}, errNoAnswer
+	}
+
+	switch reply.RCode {
+	case dnsmessage.RCodeSuccess:
+		var answer ptrAnswer
+		for _, rr := range reply.Answers {
+			ptr, ok := rr.Body.(*dnsmessage.PTRResource)
+			if !ok || rr.Header.Type != dnsmessage.TypePTR {
+				continue
+			}
+			ttl := time.Duration(rr.Header.TTL) * time.Second
+			if answer.name == "" {
+				answer.name = strings.TrimSuffix(ptr.PTR.String(), ".")
+				answer.ttl = ttl
+			} else {
+				answer.ttl = min(answer.ttl, ttl)
+			}
+		}
+		if answer.name == "" {
+			// NODATA: the name exists without a PTR record
+			return negativeAnswer(reply)
+		}
+		return answer, nil
+
+	case dnsmessage.RCodeNameError:
+		return negativeAnswer(reply)
+
+	default:
+		return ptrAnswer{}, errNoAnswer
+	}
+}
+
+// negativeAnswer returns an empty answer cacheable for the negative TTL
+// of the reply's SOA record, or for unknownTTL without one.
+func negativeAnswer(reply *dnsmessage.Message) (ptrAnswer, error) {
+	for _, rr := range reply.Authorities {
+		if soa, ok := rr.Body.(*dnsmessage.SOAResource); ok {
+			ttl := min(soa.MinTTL, rr.Header.TTL)
+			return ptrAnswer{ttl: time.Duration(ttl) * time.Second}, nil
+		}
+	}
+	return ptrAnswer{ttl: unknownTTL}, nil
+}
@@ -0,0 +1,319 @@
+package enrich
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// stubReply describes how the stub DNS server answers a reverse name.
+type stubReply struct {
+	rcode  dnsmessage.RCode
+	ptr    string // PTR target; "" = no answer record
+	ttl    uint32
+	soaTTL uint32 // with soaMin, adds an SOA to the authority section
+	soaMin uint32
+}
+
+// startStubDNS serves replies over UDP on the loopback address and
+// returns its address and a counter of queries received.
+func startStubDNS(t *testing.T, replies map[string]stubReply) (string, *atomic.Int64) {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on loopback: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	queries := new(atomic.Int64)
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var query dnsmessage.Message
+			if err := query.Unpack(buf[:n]); err != nil || len(query.Questions) != 1 {
+				continue
+			}
+			queries.Add(1)
+
+			q := query.Questions[0]
+			r := replies[q.Name.String()]
+			reply := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: query.ID, Response: true, RCode: r.rcode},
+				Questions: query.Questions,
+			}
+			if r.ptr != "" {
+				reply.Answers = append(reply.Answers, dnsmessage.Resource{
+					Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET, TTL: r.ttl},
+					Body:   &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName(r.ptr)},
+				})
+			}
+			if r.soaMin > 0 {
+				reply.Authorities = append(reply.Authorities, dnsmessage.Resource{
+					Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName("in-addr.arpa."), Type: dnsmessage.TypeSOA, Class: dnsmessage.ClassINET, TTL: r.soaTTL},
+					Body: &dnsmessage.SOAResource{
+						NS:     dnsmessage.MustNewName("ns.example."),
+						MBox:   dnsmessage.MustNewName("hostmaster.example."),
+						MinTTL: r.soaMin,
+					},
+				})
+			}
+			packet, err := reply.Pack()
+			if err != nil {
+				continue
+			}
+			conn.WriteTo(packet, addr)
+		}
+	}()
+
+	return conn.LocalAddr().String(), queries
+}
+
+// cacheExpiry returns how long key stays cached.
+func cacheExpiry(c *Cache, key string) time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return time.Until(c.data[key].expiresAt)
+}
+
+func TestRDNSResolver_RecordTTL(t *testing.T) {
+	server, queries := startStubDNS(t, map[string]stubReply{
+		"1.100.51.198.in-addr.arpa.": {ptr: "short.example.", ttl: 30},
+		"2.100.51.198.in-addr.arpa.": {ptr: "stable.example.", ttl: 86400},
+		"3.100.51.198.in-addr.arpa.": {rcode: dnsmessage.RCodeNameError, soaTTL: 3600, soaMin: 60},
+		"4.100.51.198.in-addr.arpa.": {rcode: dnsmessage.RCodeNameError, soaTTL: 20, soaMin: 600},
+		"5.100.51.198.in-addr.arpa.": {rcode: dnsmessage.RCodeNameError},
+		"6.100.51.198.in-addr.arpa.": {ptr: "volatile.example.", ttl: 0},
+	})
+
+	config := DefaultRDNSConfig()
+	config.CacheTTL = time.Hour
+	config.Servers = []string{server}
+	resolver := NewRDNSResolver(config)
+	defer resolver.Close()
+
+	tests := []struct {
+		ip       string
+		wantName string
+		wantTTL  time.Duration // 0 = not cached
+	}{
+		{"198.51.100.1", "short.example", 30 * time.Second},
+		{"198.51.100.2", "stable.example", time.Hour}, // capped
+		{"198.51.100.3", "", time.Minute},             // SOA minimum
+		{"198.51.100.4", "", 20 * time.Second},        // SOA record TTL
+		{"198.51.100.5", "", time.Hour},               // no SOA: flat TTL
+		{"198.51.100.6", "volatile.example", 0},       // TTL 0
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			name, err := resolver.Lookup(context.Background(), net.ParseIP(tt.ip))
+			if err != nil {
+				t.Fatalf("Lookup() error = %v", err)
+			}
+			if name != tt.wantName {
+				t.Errorf("Lookup() = %q, want %q", name, tt.wantName)
+			}
+
+			if tt.wantTTL == 0 {
+				if _, ok := resolver.cache.Get(tt.ip); ok {
+					t.Error("answer with TTL 0 should not be cached")
+				}
+				return
+			}
+			got := cacheExpiry(resolver.cache, tt.ip)
+			if got > tt.wantTTL || got < tt.wantTTL-5*time.Second {
+				t.Errorf("cached for %v, want %v", got.Round(time.Second), tt.wantTTL)
+			}
+		})
+	}
+
+	// Cached answers are not asked again
+	before := queries.Load()
+	resolver.Lookup(context.Background(), net.ParseIP("198.51.100.1"))
+	if queries.Load() != before {
+		t.Error("cached answer should not be queried again")
+	}
+}
+
+func TestRDNSResolver_ServerFailureFallsBack(t *testing.T) {
+	server, _ := startStubDNS(t, map[string]stubReply{
+		"1.100.51.198.in-addr.arpa.": {rcode: dnsmessage.RCodeServerFailure},
+	})
+
+	config := DefaultRDNSConfig()
+	config.Timeout = time.Second
+	config.Servers = []string{server}
+	resolver := NewRDNSResolver(config)
+	defer resolver.Close()
+
+	answer, ok := resolver.queryServers(context.Background(), net.ParseIP("198.51.100.1"))
+	if ok {
+		t.Fatalf("SERVFAIL should not be a usable answer, got %+v", answer)
+	}
+}
+
+func TestReverseName(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"192.0.2.1", "1.2.0.192.in-addr.arpa."},
+		{"2001:db8::1", "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa."},
+	}
+	for _, tt := range tests {
+		if got := reverseName(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("reverseName(%s) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestHostsNames(t *testing.T) {
+	dir := t.TempDir()
+	hosts := filepath.Join(dir, "hosts")
+	os.WriteFile(hosts, []byte("127.0.0.1 localhost\n# comment\n192.0.2.7 router.lan router # gateway\n"), 0644)
+
+	names := hostsNames(hosts)
+	if names["192.0.2.7"] != "router.lan" || names["127.0.0.1"] != "localhost" {
+		t.Errorf("hostsNames() = %v", names)
+	}
+	if hostsNames(filepath.Join(dir, "missing")) != nil {
+		t.Error("hostsNames() of a missing file should be nil")
+	}
+}
+
+// fakeForward answers forward lookups from a map; names it lacks do not
+// exist, and names mapped to nil fail with a timeout.
+type fakeForward struct {
+	addrs map[string][]string
+	calls atomic.Int64
+}
+
+func (f *fakeForward) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	f.calls.Add(1)
+	addrs, ok := f.addrs[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	if addrs == nil {
+		return nil, &net.DNSError{Err: "i/o timeout", Name: host, IsTimeout: true}
+	}
+	var answers []net.IPAddr
+	for _, addr := range addrs {
+		answers = append(answers, net.IPAddr{IP: net.ParseIP(addr)})
+	}
+	return answers, nil
+}
+
+func TestRDNSResolver_Verify(t *testing.T) {
+	forward := &fakeForward{addrs: map[string][]string{
+		"core1.example.net": {"192.0.2.1", "2001:db8::1"},
+		"spoofed.example":   {"198.51.100.99"},
+		"slow.example":      nil,
+	}}
+	config := DefaultRDNSConfig()
+	config.Servers = []string{}
+	config.Verify = true
+	config.Forward = forward
+	resolver := NewRDNSResolver(config)
+	defer resolver.Close()
+
+	tests := []struct {
+		name         string
+		ip           string
+		hostname     string
+		wantVerified bool
+		wantOK       bool
+	}{
+		{"verified", "192.0.2.1", "core1.example.net", true, true},
+		{"verified IPv6", "2001:db8::1", "Core1.Example.NET", true, true},
+		{"mismatch", "192.0.2.1", "spoofed.example", false, true},
+		{"NXDOMAIN", "192.0.2.1", "stale.example", false, true},
+		{"timeout", "192.0.2.1", "slow.example", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verified, ok := resolver.Verify(context.Background(), net.ParseIP(tt.ip), tt.hostname)
+			if verified != tt.wantVerified || ok != tt.wantOK {
+				t.Errorf("Verify(%s, %s) = %v, %v, want %v, %v", tt.ip, tt.hostname, verified, ok, tt.wantVerified, tt.wantOK)
+			}
+		})
+	}
+
+	// Answers and NXDOMAIN are cached per name; failures are asked again
+	before := forward.calls.Load()
+	for _, name := range []string{"core1.example.net", "stale.example", "slow.example"} {
+		resolver.Verify(context.Background(), net.ParseIP("192.0.2.1"), name)
+	}
+	if calls := forward.calls.Load() - before; calls != 1 {
+		t.Errorf("forward lookups after caching = %d, want 1 (the failed name)", calls)
+	}
+	if _, ok := resolver.CacheStats()["rdns-forward"]; !ok {
+		t.Error("CacheStats() should report the forward cache")
+	}
+}
+
+func TestEnricher_VerifyRDNS(t *testing.T) {
+	forward := &fakeForward{addrs: map[string][]string{
+		"router.lan":      {"192.0.2.7"},
+		"spoofed.example": {"198.51.100.99"},
+	}}
+
+	tests := []struct {
+		ip     string
+		verify bool
+		want   *bool
+	}{
+		{"192.0.2.7", true, ptr(true)},
+		{"192.0.2.8", true, ptr(false)},
+		{"192.0.2.9", true, ptr(false)}, // NXDOMAIN on the forward lookup
+		{"192.0.2.8", false, nil},
+		{"192.0.2.10", true, nil}, // no hostname
+	}
+	for _, tt := range tests {
+		config := DefaultRDNSConfig()
+		config.Servers = []string{}
+		config.Verify = tt.verify
+		config.Forward = forward
+		rdns := NewRDNSResolver(config)
+		rdns.hosts = map[string]string{
+			"192.0.2.7":  "router.lan",
+			"192.0.2.8":  "spoofed.example",
+			"192.0.2.9":  "stale.example",
+			"192.0.2.10": "",
+		}
+		enricher := &Enricher{config: EnricherConfig{EnableRDNS: true}, rdns: rdns}
+
+		result := enricher.EnrichIP(context.Background(), net.ParseIP(tt.ip))
+		got := result.HostnameVerified
+		if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("EnrichIP(%s) with verify %v: HostnameVerified = %v, want %v", tt.ip, tt.verify, fmtBool(got), fmtBool(tt.want))
+		}
+		if tt.ip != "192.0.2.10" && result.Hostname == "" {
+			t.Errorf("EnrichIP(%s) dropped the hostname", tt.ip)
+		}
+		rdns.Close()
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func fmtBool(b *bool) string {
+	if b == nil {
+		return "unset"
+	}
+	if *b {
+		return "true"
+	}
+	return "false"
+}
@@ -4,25 +4,54 @@ package enrich
 
 import (
 	"context"
+	"errors"
 	"net"
 	"strings"
 	"sync"
 	"time"
 )
 
-// RDNSResolver performs reverse DNS lookups.
+// RDNSResolver performs reverse DNS lookups. Names are cached for the
+// TTL of their PTR record, NXDOMAIN for the SOA minimum, both capped at
+// the configured cache TTL.
 type RDNSResolver struct {
-	timeout time.Duration
-	cache   *Cache
-	mu      sync.RWMutex
+	timeout  time.Duration
+	cache    *Cache
+	cacheTTL time.Duration
+	servers  []string
+	hosts    map[string]string // names from /etc/hosts, looked up first
+	mu       sync.RWMutex
+
+	// Forward confirmation of PTR names (see Verify)
+	verify       bool
+	forward      HostResolver
+	forwardCache *Cache // lowercase name -> []net.IP; nil for NXDOMAIN
+}
+
+// HostResolver looks up the addresses of a host name. *net.Resolver
+// implements it.
+type HostResolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
 }
 
 // RDNSConfig holds configuration for the rDNS resolver.
 type RDNSConfig struct {
 	Timeout    time.Duration
 	CacheSize  int
-	CacheTTL   time.Duration
+	CacheTTL   time.Duration // upper bound on the record TTL
 	MaxRetries int
+
+	// Servers are the DNS servers queried directly, as host:port, so the
+	// record TTL is known (nil = /etc/hosts, then the nameservers of
+	// /etc/resolv.conf). Without any, or when they fail, the system
+	// resolver is used and answers are cached for CacheTTL.
+	Servers []string
+
+	// Verify forward-confirms each PTR name found by the enricher (see
+	// RDNSResolver.Verify), resolving it with Forward (nil = the system
+	// resolver)
+	Verify  bool
+	Forward HostResolver
 }
 
 // DefaultRDNSConfig returns default rDNS configuration.
@@ -40,15 +69,39 @@ func NewRDNSResolver(config RDNSConfig) *RDNSResolver {
 	if config.Timeout == 0 {
 		config.Timeout = 2 * time.Second
 	}
+	if config.CacheTTL <= 0 {
+		config.CacheTTL = 5 * time.Minute
+	}
 
-	var cache *Cache
+	var cache, forwardCache *Cache
 	if config.CacheSize > 0 {
 		cache = NewCache(config.CacheSize, config.CacheTTL)
+		if config.Verify {
+			forwardCache = NewCache(config.CacheSize, config.CacheTTL)
+		}
+	}
+	forward := config.Forward
+	if forward == nil {
+		forward = net.DefaultResolver
+	}
+
+	servers := config.Servers
+	var hosts map[string]string
+	if servers == nil {
+		servers = systemNameservers()
+		hosts = hostsNames(hostsFile)
 	}
 
 	return &RDNSResolver{
-		timeout: config.Timeout,
-		cache:   cache,
+		timeout:  config.Timeout,
+		cache:    cache,
+		cacheTTL: config.CacheTTL,
+		servers:  servers,
+		hosts:    hosts,
+
+		verify:       config.Verify,
+		forward:      forward,
+		forwardCache: forwardCache,
 	}
 }
 
@@ -67,10 +120,24 @@ func (r *RDNSResolver) Lookup(ctx context.Context, ip net.IP) (string, error) {
 		}
 	}
 
+	// Static names win, as with the system resolver
+	if hostname, ok := r.hosts[ipStr]; ok {
+		if r.cache != nil {
+			r.cache.Set(ipStr, hostname)
+		}
+		return hostname, nil
+	}
+
 	// Create context with timeout
 	lookupCtx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
 
+	// Ask the nameservers directly to learn the record TTL
+	if answer, ok := r.queryServers(lookupCtx, ip); ok {
+		r.cacheAnswer(ipStr, answer)
+		return answer.name, nil
+	}
+
 	// Perform lookup
 	names, err := net.DefaultResolver.LookupAddr(lookupCtx, ipStr)
 	if err != nil {
@@ -95,6 +162,82 @@ func (r *RDNSResolver) Lookup(ctx context.Context, ip net.IP) (string, error) {
 	return hostname, nil
 }
 
+// queryServers asks the configured nameservers in turn for the PTR
+// record of ip. It reports false if none gave a usable answer.
+func (r *RDNSResolver) queryServers(ctx context.Context, ip net.IP) (ptrAnswer, bool) {
+	for _, server := range r.servers {
+		if ctx.Err() != nil {
+			break
+		}
+		answer, err := queryPTR(ctx, server, ip)
+		if err == nil {
+			return answer, true
+		}
+	}
+	return ptrAnswer{}, false
+}
+
+// cacheAnswer caches answer for its TTL, capped at the cache TTL. Answers
+// without a stated TTL are cached for the cache TTL, and answers with a
+// zero TTL not at all.
+func (r *RDNSResolver) cacheAnswer(key string, answer ptrAnswer) {
+	switch {
+	case r.cache == nil || answer.ttl == 0:
+	case answer.ttl == unknownTTL:
+		r.cache.Set(key, answer.name)
+	default:
+		r.cache.SetWithTTL(key, answer.name, min(answer.ttl, r.cacheTTL))
+	}
+}
+
+// Verify forward-confirms hostname, the PTR name of ip: it resolves the
+// name back to its addresses and reports whether ip is among them. A name
+// that does not exist (NXDOMAIN) is not verified. ok is false if the
+// forward lookup failed otherwise, e.g. timed out, so nothing is known.
+//
+// Answers, NXDOMAIN included, are cached per name for the cache TTL, so
+// routers sharing a name cost one lookup; each lookup is bounded by the
+// resolver's timeout.
+func (r *RDNSResolver) Verify(ctx context.Context, ip net.IP, hostname string) (verified, ok bool) {
+	if ip == nil || hostname == "" {
+		return false, false
+	}
+
+	key := strings.ToLower(hostname)
+	var addrs []net.IP
+	cached := false
+	if r.forwardCache != nil {
+		var v interface{}
+		if v, cached = r.forwardCache.Get(key); cached {
+			addrs = v.([]net.IP)
+		}
+	}
+
+	if !cached {
+		lookupCtx, cancel := context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+
+		answers, err := r.forward.LookupIPAddr(lookupCtx, hostname)
+		var dnsErr *net.DNSError
+		if err != nil && !(errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+			return false, false
+		}
+		for _, answer := range answers {
+			addrs = append(addrs, answer.IP)
+		}
+		if r.forwardCache != nil {
+			r.forwardCache.Set(key, addrs)
+		}
+	}
+
+	for _, addr := range addrs {
+		if addr.Equal(ip) {
+			return true, true
+		}
+	}
+	return false, true
+}
+
 // LookupBatch performs reverse DNS lookups for multiple IPs concurrently.
 func (r *RDNSResolver) LookupBatch(ctx context.Context, ips []net.IP) map[string]string {
 	results := make(map[string]string)
@@ -128,10 +271,21 @@ func (r *RDNSResolver) LookupBatch(ctx context.Context, ips []net.IP) map[string
 	return results
 }
 
+// CacheStats implements CacheReporter.
+func (r *RDNSResolver) CacheStats() map[string]CacheStats {
+	stats := make(map[string]CacheStats)
+	addCacheStats(stats, "rdns", r.cache)
+	addCacheStats(stats, "rdns-forward", r.forwardCache)
+	return stats
+}
+
 // Close releases resources held by the resolver.
 func (r *RDNSResolver) Close() error {
 	if r.cache != nil {
 		r.cache.Clear()
 	}
+	if r.forwardCache != nil {
+		r.forwardCache.Clear()
+	}
 	return nil
 }
@@ -0,0 +1,37 @@
+// Package launch opens files and URLs in the desktop's default application.
+package launch
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Open opens target (a file path or URL) with the default application,
+// e.g. an HTML report in the default browser. It returns once the
+// launcher has started and does not wait for the application to exit.
+func Open(target string) error {
+	if !isURL(target) {
+		abs, err := filepath.Abs(target)
+		if err != nil {
+			return err
+		}
+		target = abs
+	}
+
+	cmd := command(target)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to run %s: %w", cmd.Path, err)
+	}
+
+	// Reap the launcher process in the background
+	go cmd.Wait()
+	return nil
+}
+
+// isURL reports whether target looks like a URL rather than a file path.
+func isURL(target string) bool {
+	return strings.HasPrefix(target, "http://") ||
+		strings.HasPrefix(target, "https://") ||
+		strings.HasPrefix(target, "file://")
+}
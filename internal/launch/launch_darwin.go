@@ -0,0 +1,10 @@
+//go:build darwin
+
+package launch
+
+import "os/exec"
+
+// command returns the command that opens target on macOS.
+func command(target string) *exec.Cmd {
+	return exec.Command("open", target)
+}
@@ -0,0 +1,30 @@
+package launch
+
+import "testing"
+
+func TestIsURL(t *testing.T) {
+	tests := []struct {
+		target string
+		want   bool
+	}{
+		{"https://example.com/report.html", true},
+		{"http://localhost:8080", true},
+		{"file:///tmp/report.html", true},
+		{"report.html", false},
+		{"/tmp/poros-example.com.html", false},
+		{`C:\reports\poros.html`, false},
+	}
+
+	for _, tt := range tests {
+		if got := isURL(tt.target); got != tt.want {
+			t.Errorf("isURL(%q) = %v, want %v", tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestCommand(t *testing.T) {
+	cmd := command("/tmp/report.html")
+	if len(cmd.Args) < 2 || cmd.Args[len(cmd.Args)-1] != "/tmp/report.html" {
+		t.Errorf("command() args = %v, want target as last argument", cmd.Args)
+	}
+}
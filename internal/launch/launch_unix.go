@@ -0,0 +1,11 @@
+//go:build !darwin && !windows
+
+package launch
+
+import "os/exec"
+
+// command returns the command that opens target on Linux and other
+// freedesktop.org systems.
+func command(target string) *exec.Cmd {
+	return exec.Command("xdg-open", target)
+}
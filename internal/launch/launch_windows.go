@@ -0,0 +1,10 @@
+//go:build windows
+
+package launch
+
+import "os/exec"
+
+// command returns the command that opens target on Windows.
+func command(target string) *exec.Cmd {
+	return exec.Command("rundll32", "url.dll,FileProtocolHandler", target)
+}
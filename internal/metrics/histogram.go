@@ -0,0 +1,91 @@
+// Package metrics accumulates per-hop RTT histograms and writes them in
+// the Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultBuckets are the default RTT bucket upper bounds in milliseconds.
+var DefaultBuckets = []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000}
+
+// ParseBuckets parses a comma-separated list of bucket upper bounds such
+// as "1,5,10,50". Bounds must be positive and strictly increasing.
+func ParseBuckets(spec string) ([]float64, error) {
+	var bounds []float64
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		bound, err := strconv.ParseFloat(part, 64)
+		if err != nil || math.IsNaN(bound) || math.IsInf(bound, 0) {
+			return nil, fmt.Errorf("invalid bucket bound %q", part)
+		}
+		if bound <= 0 {
+			return nil, fmt.Errorf("bucket bound %q must be positive", part)
+		}
+		if n := len(bounds); n > 0 && bound <= bounds[n-1] {
+			return nil, fmt.Errorf("bucket bounds must be strictly increasing (%g after %g)", bound, bounds[n-1])
+		}
+		bounds = append(bounds, bound)
+	}
+	if len(bounds) == 0 {
+		return nil, fmt.Errorf("no bucket bounds given")
+	}
+	return bounds, nil
+}
+
+// Histogram counts observations in fixed buckets. Counts are kept per
+// bucket and made cumulative on exposition.
+type Histogram struct {
+	bounds []float64
+	counts []uint64 // one per bound, plus +Inf
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram creates a histogram with the given upper bounds, which must
+// be sorted (DefaultBuckets if empty).
+func NewHistogram(bounds []float64) *Histogram {
+	if len(bounds) == 0 {
+		bounds = DefaultBuckets
+	}
+	return &Histogram{
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+// Observe records one value. Negative values (lost probes) are ignored.
+func (h *Histogram) Observe(v float64) {
+	if v < 0 || math.IsNaN(v) {
+		return
+	}
+	i := sort.SearchFloat64s(h.bounds, v) // first bound >= v
+	h.counts[i]++
+	h.sum += v
+	h.count++
+}
+
+// Count returns the number of observations.
+func (h *Histogram) Count() uint64 { return h.count }
+
+// Sum returns the sum of all observations.
+func (h *Histogram) Sum() float64 { return h.sum }
+
+// Cumulative returns the cumulative count for each bound, followed by the
+// +Inf bucket (equal to Count).
+func (h *Histogram) Cumulative() []uint64 {
+	cumulative := make([]uint64, len(h.counts))
+	var total uint64
+	for i, c := range h.counts {
+		total += c
+		cumulative[i] = total
+	}
+	return cumulative
+}
@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"testing"
+)
+
+func TestParseBuckets(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []float64
+		wantErr bool
+	}{
+		{"1,2,5", []float64{1, 2, 5}, false},
+		{" 0.5, 10 ,250 ", []float64{0.5, 10, 250}, false},
+		{"", nil, true},
+		{"1,x", nil, true},
+		{"0,1", nil, true},
+		{"5,2", nil, true},
+		{"1,1", nil, true},
+		{"1,Inf", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseBuckets(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseBuckets(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseBuckets(%q) = %v, want %v", tt.spec, got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("ParseBuckets(%q) = %v, want %v", tt.spec, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestHistogram_Observe(t *testing.T) {
+	h := NewHistogram([]float64{1, 5, 10})
+	for _, v := range []float64{0.5, 1, 3, 5, 7, 50, -1} {
+		h.Observe(v)
+	}
+
+	// Bounds are inclusive upper limits; -1 (lost probe) is ignored
+	want := []uint64{2, 4, 5, 6}
+	got := h.Cumulative()
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Cumulative() = %v, want %v", got, want)
+		}
+	}
+	if h.Count() != 6 || h.Sum() != 66.5 {
+		t.Errorf("count/sum = %d/%g, want 6/66.5", h.Count(), h.Sum())
+	}
+
+	if len(NewHistogram(nil).Cumulative()) != len(DefaultBuckets)+1 {
+		t.Error("an empty bound list should use DefaultBuckets")
+	}
+}
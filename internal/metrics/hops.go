@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+)
+
+// HopRTTMetric is the name of the per-hop RTT histogram.
+const HopRTTMetric = "poros_hop_rtt_ms"
+
+// hopKey identifies one histogram series.
+type hopKey struct {
+	target string
+	hop    int
+	ip     string
+	tags   string // preformatted result tag labels, e.g. `,site="fra1"`
+}
+
+// builtinLabels are the labels every series has; result tags with the
+// same key are not exported.
+var builtinLabels = map[string]bool{"target": true, "hop": true, "ip": true, "le": true}
+
+// HopHistograms accumulates per-hop RTT histograms across repeated
+// traces. Series are never reset; they live as long as the process.
+// It is safe for concurrent use, so a trace loop can observe rounds while
+// the histograms are being served.
+type HopHistograms struct {
+	mu     sync.Mutex
+	bounds []float64
+	series map[hopKey]*Histogram
+}
+
+// NewHopHistograms creates per-hop histograms with the given bucket upper
+// bounds (DefaultBuckets if empty).
+func NewHopHistograms(bounds []float64) *HopHistograms {
+	if len(bounds) == 0 {
+		bounds = DefaultBuckets
+	}
+	return &HopHistograms{
+		bounds: bounds,
+		series: make(map[hopKey]*Histogram),
+	}
+}
+
+// Observe feeds every RTT sample of a trace result into the histograms.
+// A hop answered by a different router starts a new series, as do results
+// with different tags, which are exported as extra labels.
+func (h *HopHistograms) Observe(result *trace.TraceResult) {
+	tags := tagLabels(result.Meta)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := range result.Hops {
+		hop := &result.Hops[i]
+		if !hop.Responded || hop.IP == nil {
+			continue
+		}
+
+		key := hopKey{target: result.Target, hop: hop.Number, ip: hop.IP.String(), tags: tags}
+		hist, ok := h.series[key]
+		if !ok {
+			hist = NewHistogram(h.bounds)
+			h.series[key] = hist
+		}
+		for _, rtt := range hop.RTTs {
+			hist.Observe(rtt)
+		}
+	}
+}
+
+// WriteTo writes the histograms in the Prometheus text exposition format.
+func (h *HopHistograms) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# HELP %s Round-trip time per hop in milliseconds.\n", HopRTTMetric)
+	fmt.Fprintf(&b, "# TYPE %s histogram\n", HopRTTMetric)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range h.keys() {
+		hist := h.series[key]
+		labels := fmt.Sprintf(`target="%s",hop="%d",ip="%s"%s`,
+			escapeLabelValue(key.target), key.hop, escapeLabelValue(key.ip), key.tags)
+
+		cumulative := hist.Cumulative()
+		for i, bound := range h.bounds {
+			fmt.Fprintf(&b, "%s_bucket{%s,le=\"%s\"} %d\n",
+				HopRTTMetric, labels, formatFloat(bound), cumulative[i])
+		}
+		fmt.Fprintf(&b, "%s_bucket{%s,le=\"+Inf\"} %d\n", HopRTTMetric, labels, hist.Count())
+		fmt.Fprintf(&b, "%s_sum{%s} %s\n", HopRTTMetric, labels, formatFloat(hist.Sum()))
+		fmt.Fprintf(&b, "%s_count{%s} %d\n", HopRTTMetric, labels, hist.Count())
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// keys returns the series keys in a stable order. h.mu must be held.
+func (h *HopHistograms) keys() []hopKey {
+	keys := make([]hopKey, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.target != b.target {
+			return a.target < b.target
+		}
+		if a.hop != b.hop {
+			return a.hop < b.hop
+		}
+		if a.ip != b.ip {
+			return a.ip < b.ip
+		}
+		return a.tags < b.tags
+	})
+	return keys
+}
+
+// tagLabels formats the result tags as label pairs in key order.
+func tagLabels(meta *trace.Meta) string {
+	if meta == nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, key := range trace.TagKeys(meta.Tags) {
+		if builtinLabels[key] {
+			continue
+		}
+		fmt.Fprintf(&b, `,%s="%s"`, key, escapeLabelValue(meta.Tags[key]))
+	}
+	return b.String()
+}
+
+// labelEscaper escapes label values as required by the text format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+// formatFloat formats a sample value without exponent for typical RTTs.
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+)
+
+func cycleResult(target string, rtts ...float64) *trace.TraceResult {
+	return &trace.TraceResult{
+		Target: target,
+		Hops: []trace.Hop{
+			{Number: 1, IP: net.ParseIP("192.168.1.1"), RTTs: rtts, Responded: true},
+			{Number: 2, RTTs: []float64{-1, -1, -1}},
+		},
+	}
+}
+
+// parseSeries collects sample values from an exposition, keyed by the
+// full series name including labels.
+func parseSeries(t *testing.T, exposition string) (names []string, values map[string]float64) {
+	t.Helper()
+	values = make(map[string]float64)
+	scanner := bufio.NewScanner(strings.NewReader(exposition))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndex(line, " ")
+		v, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			t.Fatalf("bad sample line %q", line)
+		}
+		names = append(names, line[:i])
+		values[line[:i]] = v
+	}
+	return names, values
+}
+
+func TestHopHistograms_Exposition(t *testing.T) {
+	h := NewHopHistograms([]float64{1, 5, 10})
+	h.Observe(cycleResult("example.com", 0.8, 4, -1))
+	h.Observe(cycleResult("example.com", 12, 5, 3)) // accumulates across cycles
+
+	var buf bytes.Buffer
+	if _, err := h.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	out := buf.String()
+
+	if !strings.HasPrefix(out, "# HELP poros_hop_rtt_ms ") || !strings.Contains(out, "# TYPE poros_hop_rtt_ms histogram\n") {
+		t.Errorf("missing HELP/TYPE lines:\n%s", out)
+	}
+	if strings.Contains(out, `hop="2"`) {
+		t.Error("unresponsive hops should not get a series")
+	}
+
+	names, values := parseSeries(t, out)
+	labels := `target="example.com",hop="1",ip="192.168.1.1"`
+	var prev float64
+	for _, le := range []string{"1", "5", "10", "+Inf"} {
+		v, ok := values[`poros_hop_rtt_ms_bucket{`+labels+`,le="`+le+`"}`]
+		if !ok {
+			t.Fatalf("missing bucket le=%s:\n%s", le, out)
+		}
+		if v < prev {
+			t.Errorf("bucket le=%s = %g, below previous bucket %g", le, v, prev)
+		}
+		prev = v
+	}
+	if values[`poros_hop_rtt_ms_bucket{`+labels+`,le="5"}`] != 4 {
+		t.Errorf("le=5 bucket = %g, want 4", values[`poros_hop_rtt_ms_bucket{`+labels+`,le="5"}`])
+	}
+	if c := values["poros_hop_rtt_ms_count{"+labels+"}"]; c != 5 || c != prev {
+		t.Errorf("count = %g, want 5 and equal to the +Inf bucket (%g)", c, prev)
+	}
+	if s := values["poros_hop_rtt_ms_sum{"+labels+"}"]; s != 24.8 {
+		t.Errorf("sum = %g, want 24.8", s)
+	}
+	if len(names) != 6 {
+		t.Errorf("got %d samples, want 4 buckets + sum + count", len(names))
+	}
+}
+
+func TestHopHistograms_LabelEscaping(t *testing.T) {
+	h := NewHopHistograms(nil)
+	h.Observe(cycleResult("we\"ird\\host\nname", 3))
+
+	var buf bytes.Buffer
+	h.WriteTo(&buf)
+
+	want := `target="we\"ird\\host\nname"`
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("label value not escaped, want %s in:\n%s", want, buf.String())
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		if !strings.HasPrefix(line, "poros_hop_rtt_ms") && !strings.HasPrefix(line, "#") {
+			t.Errorf("raw newline leaked into the exposition: %q", line)
+		}
+	}
+}
+
+func TestHopHistograms_NewRouterNewSeries(t *testing.T) {
+	h := NewHopHistograms(nil)
+	h.Observe(cycleResult("example.com", 1))
+
+	changed := cycleResult("example.com", 2)
+	changed.Hops[0].IP = net.ParseIP("192.168.1.254")
+	h.Observe(changed)
+
+	var buf bytes.Buffer
+	h.WriteTo(&buf)
+	if strings.Count(buf.String(), "poros_hop_rtt_ms_count{") != 2 {
+		t.Errorf("a path change should start a new series:\n%s", buf.String())
+	}
+}
+
+func TestHopHistograms_TagLabels(t *testing.T) {
+	h := NewHopHistograms([]float64{10})
+	result := cycleResult("example.com", 1)
+	result.Meta = &trace.Meta{Tags: map[string]string{"site": "fra1", "ticket": `NET "1"`, "ip": "ignored"}}
+	h.Observe(result)
+
+	var buf bytes.Buffer
+	h.WriteTo(&buf)
+	want := `poros_hop_rtt_ms_count{target="example.com",hop="1",ip="192.168.1.1",site="fra1",ticket="NET \"1\""} 1`
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("exposition should contain %s:\n%s", want, buf.String())
+	}
+	if strings.Contains(buf.String(), "ignored") {
+		t.Error("tags clashing with built-in labels should be dropped")
+	}
+
+	// Results with different tags are separate series
+	h.Observe(cycleResult("example.com", 2))
+	buf.Reset()
+	h.WriteTo(&buf)
+	if strings.Count(buf.String(), "poros_hop_rtt_ms_count{") != 2 {
+		t.Errorf("untagged result should start a new series:\n%s", buf.String())
+	}
+}
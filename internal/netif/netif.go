@@ -0,0 +1,243 @@
+// Package netif lists the network interfaces poros can send probes from
+// and resolves the names given to --interface.
+package netif
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// ErrUnknownInterface indicates an interface name that the host does not
+// have, or that is down.
+var ErrUnknownInterface = errors.New("unknown interface")
+
+// ErrNoIPv6 indicates that IPv6 was requested on a host without a global
+// IPv6 address.
+var ErrNoIPv6 = errors.New("IPv6 requested but no global IPv6 connectivity detected on this host")
+
+// Interface describes a network interface that is up.
+type Interface struct {
+	Name  string   `json:"name"`
+	Index int      `json:"index"`
+	MTU   int      `json:"mtu"`
+	Addrs []string `json:"addrs"` // addresses in CIDR notation
+	// DefaultRoute is set for interfaces that look like they carry the
+	// default route; detection is best-effort
+	DefaultRoute bool `json:"default_route"`
+	Loopback     bool `json:"loopback,omitempty"`
+}
+
+// Source returns the interfaces of a host.
+type Source func() ([]Interface, error)
+
+// System returns the interfaces of this host that are up, in index order.
+func System() ([]Interface, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	defaults := defaultRouteInterfaces()
+	var list []Interface
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		info := Interface{
+			Name:         iface.Name,
+			Index:        iface.Index,
+			MTU:          iface.MTU,
+			Addrs:        []string{},
+			DefaultRoute: defaults[iface.Name],
+			Loopback:     iface.Flags&net.FlagLoopback != 0,
+		}
+		if addrs, err := iface.Addrs(); err == nil {
+			for _, addr := range addrs {
+				info.Addrs = append(info.Addrs, addr.String())
+			}
+		}
+		list = append(list, info)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Index < list[j].Index })
+	return list, nil
+}
+
+// CheckIPv6 fails with ErrNoIPv6 unless an interface that is up, other
+// than loopback, has a global unicast IPv6 address. The error names the
+// interfaces with only link-local IPv6 addresses, e.g. "interfaces with
+// v6: eth0 (link-local only)", or "none". A host whose interfaces cannot
+// be listed passes, so the trace itself reports what fails.
+func CheckIPv6(src Source) error {
+	ifaces, err := src()
+	if err != nil {
+		return nil
+	}
+
+	var linkLocal []string
+	for _, iface := range ifaces {
+		if iface.Loopback {
+			continue
+		}
+		hasV6 := false
+		for _, addr := range iface.Addrs {
+			ip := parseAddr(addr)
+			if ip == nil || ip.To4() != nil {
+				continue
+			}
+			if ip.IsGlobalUnicast() {
+				return nil
+			}
+			hasV6 = true
+		}
+		if hasV6 {
+			linkLocal = append(linkLocal, iface.Name)
+		}
+	}
+
+	with := "none"
+	if len(linkLocal) > 0 {
+		with = strings.Join(linkLocal, ", ") + " (link-local only)"
+	}
+	return fmt.Errorf("%w; interfaces with v6: %s", ErrNoIPv6, with)
+}
+
+// parseAddr parses an interface address in CIDR notation or as a bare
+// IP address, returning nil if it is neither.
+func parseAddr(addr string) net.IP {
+	if ip, _, err := net.ParseCIDR(addr); err == nil {
+		return ip
+	}
+	return net.ParseIP(addr)
+}
+
+// Lookup returns the interface called name. An unknown name fails with
+// ErrUnknownInterface, naming the closest matches if there are any.
+func Lookup(src Source, name string) (*Interface, error) {
+	ifaces, err := src()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(ifaces))
+	for i := range ifaces {
+		if ifaces[i].Name == name {
+			return &ifaces[i], nil
+		}
+		names[i] = ifaces[i].Name
+	}
+
+	if matches := Suggest(name, names); len(matches) > 0 {
+		return nil, fmt.Errorf("%w %q (did you mean %s?)", ErrUnknownInterface, name, strings.Join(matches, ", "))
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownInterface, name)
+}
+
+// maxSuggestions is the most close matches Suggest returns.
+const maxSuggestions = 3
+
+// Suggest returns the names that are close to name, closest first: names
+// that differ only in case, that contain name or are contained in it, or
+// that are a few edits away.
+func Suggest(name string, names []string) []string {
+	type match struct {
+		name     string
+		distance int
+	}
+
+	lower := strings.ToLower(name)
+	maxDistance := max(2, len(name)/3)
+
+	var matches []match
+	for _, candidate := range names {
+		c := strings.ToLower(candidate)
+		switch {
+		case c == lower:
+			matches = append(matches, match{candidate, 0})
+		case lower != "" && (strings.Contains(c, lower) || strings.Contains(lower, c)):
+			matches = append(matches, match{candidate, 1 + abs(len(c)-len(lower))})
+		default:
+			if d := editDistance(lower, c); d <= maxDistance {
+				matches = append(matches, match{candidate, d})
+			}
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].distance < matches[j].distance })
+	if len(matches) > maxSuggestions {
+		matches = matches[:maxSuggestions]
+	}
+	suggestions := make([]string, len(matches))
+	for i, m := range matches {
+		suggestions[i] = m.name
+	}
+	return suggestions
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// WriteText writes ifaces as a table, marking default-route candidates
+// with an asterisk.
+func WriteText(w io.Writer, ifaces []Interface) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  NAME\tINDEX\tMTU\tADDRESSES")
+	for _, iface := range ifaces {
+		mark := " "
+		if iface.DefaultRoute {
+			mark = "*"
+		}
+		addrs := strings.Join(iface.Addrs, ", ")
+		if addrs == "" {
+			addrs = "-"
+		}
+		fmt.Fprintf(tw, "%s %s\t%d\t%d\t%s\n", mark, iface.Name, iface.Index, iface.MTU, addrs)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w, "\n* likely default route")
+	return err
+}
+
+// WriteJSON writes ifaces as a JSON array.
+func WriteJSON(w io.Writer, ifaces []Interface) error {
+	if ifaces == nil {
+		ifaces = []Interface{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(ifaces)
+}
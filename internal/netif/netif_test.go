@@ -0,0 +1,211 @@
+package netif
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func fakeSource() ([]Interface, error) {
+	return []Interface{
+		{Name: "lo", Index: 1, MTU: 65536, Addrs: []string{"127.0.0.1/8", "::1/128"}, Loopback: true},
+		{Name: "eth0", Index: 2, MTU: 1500, Addrs: []string{"192.0.2.10/24"}, DefaultRoute: true},
+		{Name: "eth1", Index: 3, MTU: 1500, Addrs: []string{}},
+		{Name: "wlan0", Index: 4, MTU: 1500, Addrs: []string{"198.51.100.7/24"}},
+		{Name: "Ethernet 2", Index: 5, MTU: 1500, Addrs: []string{"203.0.113.4/24"}},
+	}, nil
+}
+
+func TestLookup(t *testing.T) {
+	iface, err := Lookup(fakeSource, "eth0")
+	if err != nil || iface.Index != 2 {
+		t.Fatalf("Lookup(eth0) = %+v, %v", iface, err)
+	}
+
+	_, err = Lookup(fakeSource, "eht0")
+	if !errors.Is(err, ErrUnknownInterface) {
+		t.Fatalf("Lookup(eht0) error = %v, want ErrUnknownInterface", err)
+	}
+	if !strings.Contains(err.Error(), "did you mean eth0?") {
+		t.Errorf("Lookup(eht0) error = %q, want suggestions", err)
+	}
+
+	_, err = Lookup(fakeSource, "bond7")
+	if !errors.Is(err, ErrUnknownInterface) || strings.Contains(err.Error(), "did you mean") {
+		t.Errorf("Lookup(bond7) error = %v, want no suggestions", err)
+	}
+
+	failing := func() ([]Interface, error) { return nil, errors.New("no access") }
+	if _, err := Lookup(failing, "eth0"); err == nil || errors.Is(err, ErrUnknownInterface) {
+		t.Errorf("Lookup() with failing source = %v", err)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	ifaces, _ := fakeSource()
+	names := make([]string, len(ifaces))
+	for i, iface := range ifaces {
+		names[i] = iface.Name
+	}
+
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"ETH0", []string{"eth0", "eth1"}},
+		{"eth", []string{"eth0", "eth1", "Ethernet 2"}},
+		{"wlan", []string{"wlan0"}},
+		{"ethernet 2", []string{"Ethernet 2"}},
+		{"Ethernet", []string{"Ethernet 2"}},
+		{"docker0", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Suggest(tt.name, names)
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Suggest(%q) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"eth0", "eth0", 0},
+		{"eth0", "eth1", 1},
+		{"eht0", "eth0", 2},
+		{"", "lo", 2},
+		{"kitten", "sitting", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	ifaces, _ := fakeSource()
+	var buf strings.Builder
+	if err := WriteText(&buf, ifaces); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{"NAME", "127.0.0.1/8, ::1/128", "65536", "* likely default route"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	for _, line := range strings.Split(out, "\n") {
+		switch {
+		case strings.Contains(line, "eth0"):
+			if !strings.HasPrefix(line, "* eth0") {
+				t.Errorf("default route not marked: %q", line)
+			}
+		case strings.Contains(line, "eth1"):
+			if !strings.HasPrefix(line, "  eth1") || !strings.HasSuffix(strings.TrimSpace(line), "-") {
+				t.Errorf("eth1 line = %q", line)
+			}
+		}
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	ifaces, _ := fakeSource()
+	var buf strings.Builder
+	if err := WriteJSON(&buf, ifaces); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []Interface
+	if err := json.Unmarshal([]byte(buf.String()), &got); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	if !reflect.DeepEqual(got, ifaces) {
+		t.Errorf("round trip = %+v, want %+v", got, ifaces)
+	}
+
+	buf.Reset()
+	if err := WriteJSON(&buf, nil); err != nil || strings.TrimSpace(buf.String()) != "[]" {
+		t.Errorf("WriteJSON(nil) = %q, %v", buf.String(), err)
+	}
+}
+
+func TestSystem(t *testing.T) {
+	ifaces, err := System()
+	if err != nil {
+		t.Skipf("cannot list interfaces: %v", err)
+	}
+	for i := 1; i < len(ifaces); i++ {
+		if ifaces[i-1].Index > ifaces[i].Index {
+			t.Errorf("interfaces not in index order: %+v", ifaces)
+		}
+	}
+}
+
+func TestCheckIPv6(t *testing.T) {
+	tests := []struct {
+		name   string
+		ifaces []Interface
+		want   string // substring of the error, "" for none
+	}{
+		{
+			name: "global address",
+			ifaces: []Interface{
+				{Name: "eth0", Addrs: []string{"192.0.2.10/24", "fe80::1/64", "2001:db8::10/64"}},
+			},
+		},
+		{
+			name: "link-local only",
+			ifaces: []Interface{
+				{Name: "lo", Addrs: []string{"::1/128"}, Loopback: true},
+				{Name: "eth0", Addrs: []string{"192.0.2.10/24", "fe80::1/64"}},
+				{Name: "wlan0", Addrs: []string{"198.51.100.7/24"}},
+				{Name: "eth1", Addrs: []string{"fe80::2"}},
+			},
+			want: "interfaces with v6: eth0, eth1 (link-local only)",
+		},
+		{
+			name:   "IPv4 only",
+			ifaces: []Interface{{Name: "eth0", Addrs: []string{"192.0.2.10/24"}}},
+			want:   "interfaces with v6: none",
+		},
+		{
+			name:   "loopback global address ignored",
+			ifaces: []Interface{{Name: "lo", Addrs: []string{"2001:db8::1/128"}, Loopback: true}},
+			want:   "interfaces with v6: none",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := func() ([]Interface, error) { return tt.ifaces, nil }
+			err := CheckIPv6(src)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("CheckIPv6() = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrNoIPv6) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("CheckIPv6() = %v, want ErrNoIPv6 with %q", err, tt.want)
+			}
+		})
+	}
+
+	failing := func() ([]Interface, error) { return nil, errors.New("no access") }
+	if err := CheckIPv6(failing); err != nil {
+		t.Errorf("CheckIPv6() with failing source = %v, want nil", err)
+	}
+}
@@ -0,0 +1,64 @@
+package netif
+
+import "net"
+
+// Documentation addresses (RFC 5737, RFC 3849) that no local network
+// uses, so the kernel routes them through the default route. Connecting a
+// UDP socket selects a route without sending any packets.
+var routeProbes = []string{"192.0.2.1:53", "[2001:db8::1]:53"}
+
+// SourceAddr returns the address the kernel would send from to reach the
+// internet over IPv6 or IPv4. It fails if there is no route.
+func SourceAddr(ipv6 bool) (net.IP, error) {
+	network, addr := "udp4", routeProbes[0]
+	if ipv6 {
+		network, addr = "udp6", routeProbes[1]
+	}
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
+
+// preferredInterfaces returns the interfaces holding the source addresses
+// the kernel would use to reach the internet.
+func preferredInterfaces() map[string]bool {
+	names := make(map[string]bool)
+	for _, addr := range routeProbes {
+		conn, err := net.Dial("udp", addr)
+		if err != nil {
+			continue
+		}
+		local, ok := conn.LocalAddr().(*net.UDPAddr)
+		conn.Close()
+		if !ok {
+			continue
+		}
+		if name := interfaceFor(local.IP); name != "" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// interfaceFor returns the name of the interface that holds ip.
+func interfaceFor(ip net.IP) string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+				return iface.Name
+			}
+		}
+	}
+	return ""
+}
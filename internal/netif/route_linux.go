@@ -0,0 +1,44 @@
+package netif
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// defaultRouteInterfaces returns the interfaces the kernel routing tables
+// hold a default route for, falling back to the interfaces of the
+// preferred source addresses.
+func defaultRouteInterfaces() map[string]bool {
+	names := make(map[string]bool)
+
+	// Columns: Iface Destination Gateway ...; destination 0 is the default
+	if f, err := os.Open("/proc/net/route"); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) > 1 && fields[1] == "00000000" {
+				names[fields[0]] = true
+			}
+		}
+		f.Close()
+	}
+
+	// Columns: Destination PrefixLen Source SrcPrefixLen NextHop Metric
+	// RefCnt Use Flags Iface; a ::/0 route other than "lo" is the default
+	if f, err := os.Open("/proc/net/ipv6_route"); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) == 10 && fields[0] == strings.Repeat("0", 32) && fields[1] == "00" && fields[9] != "lo" {
+				names[fields[9]] = true
+			}
+		}
+		f.Close()
+	}
+
+	if len(names) == 0 {
+		return preferredInterfaces()
+	}
+	return names
+}
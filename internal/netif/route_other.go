@@ -0,0 +1,10 @@
+//go:build !linux
+
+package netif
+
+// defaultRouteInterfaces returns the interfaces of the preferred source
+// addresses. Other systems offer no routing table that can be read
+// without privileges or cgo.
+func defaultRouteInterfaces() map[string]bool {
+	return preferredInterfaces()
+}
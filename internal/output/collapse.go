@@ -0,0 +1,48 @@
+package output
+
+import (
+	"fmt"
+
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+)
+
+// minTimeoutRun is the shortest run of unresponsive hops that
+// Config.CollapseTimeouts replaces with a single line.
+const minTimeoutRun = 3
+
+// silentHop reports whether hop is a plain timeout that may be collapsed.
+// Hops that were never probed or failed to send keep their own line.
+func silentHop(hop *trace.Hop) bool {
+	return !hop.Responded && !hop.Unprobed && !hop.SendFailed()
+}
+
+// groupHops splits hops into groups of one hop each, except that with
+// collapse set, runs of at least minTimeoutRun silent hops form a single
+// group.
+func groupHops(hops []trace.Hop, collapse bool) [][]trace.Hop {
+	var groups [][]trace.Hop
+	for i := 0; i < len(hops); {
+		end := i + 1
+		if collapse && silentHop(&hops[i]) {
+			for end < len(hops) && silentHop(&hops[end]) {
+				end++
+			}
+			if end-i < minTimeoutRun {
+				end = i + 1
+			}
+		}
+		groups = append(groups, hops[i:end])
+		i = end
+	}
+	return groups
+}
+
+// timeoutRunRange labels a run of hops, e.g. "9–20".
+func timeoutRunRange(run []trace.Hop) string {
+	return fmt.Sprintf("%d–%d", run[0].Number, run[len(run)-1].Number)
+}
+
+// timeoutRunLabel describes a run of hops, e.g. "12 hops, no response".
+func timeoutRunLabel(run []trace.Hop) string {
+	return fmt.Sprintf("%d hops, no response", len(run))
+}
@@ -0,0 +1,189 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+	"github.com/KilimcininKorOglu/poros/internal/trace/tracetest"
+)
+
+// timeoutTrace builds a trace of n hops where the hops in silent did not
+// respond.
+func timeoutTrace(n int, silent ...int) *trace.TraceResult {
+	quiet := make(map[int]bool)
+	for _, s := range silent {
+		quiet[s] = true
+	}
+
+	b := tracetest.Result("example.com")
+	for i := 1; i <= n; i++ {
+		if quiet[i] {
+			b.TimeoutHop(i)
+		} else {
+			rtt := float64(i)
+			b.Hop(i, fmt.Sprintf("198.51.100.%d", i), rtt, rtt, rtt)
+		}
+	}
+	return b.Build()
+}
+
+func TestGroupHops(t *testing.T) {
+	tests := []struct {
+		name   string
+		result *trace.TraceResult
+		want   []string // hop range of each group
+	}{
+		{"start", timeoutTrace(6, 1, 2, 3), []string{"1-3", "4", "5", "6"}},
+		{"middle", timeoutTrace(8, 3, 4, 5, 6), []string{"1", "2", "3-6", "7", "8"}},
+		{"end", timeoutTrace(5, 3, 4, 5), []string{"1", "2", "3-5"}},
+		{"short run kept", timeoutTrace(5, 2, 3), []string{"1", "2", "3", "4", "5"}},
+		{"all silent", timeoutTrace(4, 1, 2, 3, 4), []string{"1-4"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, group := range groupHops(tt.result.Hops, true) {
+				label := fmt.Sprintf("%d", group[0].Number)
+				if len(group) > 1 {
+					label += fmt.Sprintf("-%d", group[len(group)-1].Number)
+				}
+				got = append(got, label)
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("groupHops() = %v, want %v", got, tt.want)
+			}
+
+			if groups := groupHops(tt.result.Hops, false); len(groups) != len(tt.result.Hops) {
+				t.Errorf("groupHops(collapse=false) = %d groups, want %d", len(groups), len(tt.result.Hops))
+			}
+		})
+	}
+}
+
+func TestGroupHops_KeepsUnprobedAndSendFailed(t *testing.T) {
+	result := timeoutTrace(5, 1, 2, 3, 4, 5)
+	result.Hops[2].Unprobed = true
+	result.Hops[3].SendErrors = 3
+
+	for _, group := range groupHops(result.Hops, true) {
+		if len(group) > 1 {
+			t.Errorf("group %d-%d collapsed, want unprobed and send-failed hops to break runs",
+				group[0].Number, group[len(group)-1].Number)
+		}
+	}
+}
+
+func TestTextFormatter_CollapseTimeouts(t *testing.T) {
+	tests := []struct {
+		name   string
+		result *trace.TraceResult
+		line   string
+		hops   []string // hop numbers still shown on their own line
+	}{
+		{"start", timeoutTrace(5, 1, 2, 3), "1–3  * * * (3 hops, no response)", []string{"  4  ", "  5  "}},
+		{"middle", timeoutTrace(20, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19), "9–19  * * * (11 hops, no response)", []string{"  8  ", " 20  "}},
+		{"end", timeoutTrace(6, 3, 4, 5, 6), "3–6  * * * (4 hops, no response)", []string{"  1  ", "  2  "}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewTextFormatter(Config{CollapseTimeouts: true})
+			data, err := f.Format(tt.result)
+			if err != nil {
+				t.Fatalf("Format() error = %v", err)
+			}
+			output := string(data)
+
+			if !strings.Contains(output, tt.line) {
+				t.Errorf("output missing %q:\n%s", tt.line, output)
+			}
+			for _, hop := range tt.hops {
+				if !strings.Contains(output, "\n"+hop) {
+					t.Errorf("output missing hop line %q:\n%s", hop, output)
+				}
+			}
+
+			// Streaming holds the run back until it ends, giving the same lines
+			stream := NewTextFormatter(Config{CollapseTimeouts: true})
+			var streamed strings.Builder
+			for i := range tt.result.Hops {
+				streamed.WriteString(stream.FormatHop(&tt.result.Hops[i]))
+			}
+			streamed.WriteString(stream.FormatSummary(tt.result))
+			if !strings.Contains(streamed.String(), tt.line) {
+				t.Errorf("streamed output missing %q:\n%s", tt.line, streamed.String())
+			}
+			if n := strings.Count(streamed.String(), "* * *"); n != 1 {
+				t.Errorf("streamed output has %d timeout lines, want 1:\n%s", n, streamed.String())
+			}
+		})
+	}
+}
+
+func TestTextFormatter_CollapseTimeoutsShortRun(t *testing.T) {
+	result := timeoutTrace(4, 2, 3)
+
+	f := NewTextFormatter(Config{CollapseTimeouts: true})
+	var streamed strings.Builder
+	for i := range result.Hops {
+		streamed.WriteString(f.FormatHop(&result.Hops[i]))
+	}
+	output := streamed.String()
+
+	if strings.Contains(output, "no response") {
+		t.Errorf("run of 2 hops collapsed:\n%s", output)
+	}
+	if n := strings.Count(output, "* * *"); n != 2 {
+		t.Errorf("output has %d timeout lines, want 2:\n%s", n, output)
+	}
+	if strings.Index(output, "  2  ") > strings.Index(output, "  4  ") {
+		t.Errorf("buffered hops written after hop 4:\n%s", output)
+	}
+}
+
+func TestFormatters_CollapseTimeouts(t *testing.T) {
+	result := timeoutTrace(6, 2, 3, 4, 5)
+	config := Config{CollapseTimeouts: true}
+
+	data, err := NewTableFormatter(config).Format(result)
+	if err != nil {
+		t.Fatalf("table Format() error = %v", err)
+	}
+	table := string(data)
+	if !strings.Contains(table, "2–5") || !strings.Contains(table, "(4 hops, no response)") {
+		t.Errorf("table missing collapsed row:\n%s", table)
+	}
+
+	noHostname := NewTableFormatter(Config{CollapseTimeouts: true, Columns: []string{"hop", "ip", "avg"}})
+	data, err = noHostname.Format(result)
+	if err != nil {
+		t.Fatalf("table Format() error = %v", err)
+	}
+	if !strings.Contains(string(data), "* (4 hops, no response)") {
+		t.Errorf("table without hostname column missing collapsed row:\n%s", data)
+	}
+
+	// JSON and CSV keep every hop
+	data, err = NewJSONFormatter(config).Format(result)
+	if err != nil {
+		t.Fatalf("JSON Format() error = %v", err)
+	}
+	parsed, err := ParseJSONResult(data)
+	if err != nil {
+		t.Fatalf("ParseJSONResult() error = %v", err)
+	}
+	if len(parsed.Hops) != len(result.Hops) {
+		t.Errorf("JSON has %d hops, want %d", len(parsed.Hops), len(result.Hops))
+	}
+
+	data, err = NewCSVFormatter(config).Format(result)
+	if err != nil {
+		t.Fatalf("CSV Format() error = %v", err)
+	}
+	if strings.Contains(string(data), "no response") {
+		t.Errorf("CSV collapsed hops:\n%s", data)
+	}
+}
@@ -0,0 +1,16 @@
+package output
+
+import "os"
+
+// EnableVirtualTerminal prepares the console behind f to interpret ANSI
+// escape sequences, which older Windows consoles show as garbage unless
+// virtual terminal processing is turned on. It reports false when f is a
+// console that cannot show them, in which case colors should be disabled.
+// Other platforms, and files that are not consoles, need nothing and
+// report true.
+func EnableVirtualTerminal(f *os.File) bool {
+	if f == nil {
+		return true
+	}
+	return enableVirtualTerminal(f)
+}
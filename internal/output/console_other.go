@@ -0,0 +1,11 @@
+//go:build !windows
+
+package output
+
+import "os"
+
+// enableVirtualTerminal is a no-op: terminals outside Windows interpret
+// ANSI escape sequences.
+func enableVirtualTerminal(f *os.File) bool {
+	return true
+}
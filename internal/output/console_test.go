@@ -0,0 +1,54 @@
+package output
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestNoFileSync guards the streaming path against (*os.File).Sync, which
+// fails on console handles and only forces data to disk; writes to an
+// *os.File are unbuffered and reach the terminal without it.
+func TestNoFileSync(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatalf("Glob() error = %v", err)
+	}
+
+	fset := token.NewFileSet()
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatalf("ParseFile(%s) error = %v", name, err)
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			if sel, ok := call.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "Sync" {
+				t.Errorf("%s: Sync call in the output package", fset.Position(call.Pos()))
+			}
+			return true
+		})
+	}
+}
+
+func TestEnableVirtualTerminal_File(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "out.txt"))
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	defer f.Close()
+
+	if !EnableVirtualTerminal(f) || !EnableVirtualTerminal(nil) {
+		t.Error("EnableVirtualTerminal() = false for a file, want true (nothing to enable)")
+	}
+}
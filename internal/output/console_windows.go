@@ -0,0 +1,29 @@
+//go:build windows
+
+package output
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// enableVirtualTerminal turns on virtual terminal processing for the
+// console behind f. Handles that are not consoles are left alone.
+func enableVirtualTerminal(f *os.File) bool {
+	handle := windows.Handle(f.Fd())
+
+	var mode uint32
+	if err := windows.GetConsoleMode(handle, &mode); err != nil {
+		return true // redirected to a file or pipe
+	}
+	if vtMode(mode) == mode {
+		return true
+	}
+	return windows.SetConsoleMode(handle, vtMode(mode)) == nil
+}
+
+// vtMode returns the console mode with virtual terminal processing on.
+func vtMode(mode uint32) uint32 {
+	return mode | windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING
+}
@@ -0,0 +1,20 @@
+//go:build windows
+
+package output
+
+import (
+	"testing"
+
+	"golang.org/x/sys/windows"
+)
+
+func TestVTMode(t *testing.T) {
+	const processed = windows.ENABLE_PROCESSED_OUTPUT | windows.ENABLE_WRAP_AT_EOL_OUTPUT
+	if got := vtMode(processed); got != processed|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING {
+		t.Errorf("vtMode(%#x) = %#x, want virtual terminal processing added", processed, got)
+	}
+	on := uint32(processed | windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING)
+	if got := vtMode(on); got != on {
+		t.Errorf("vtMode(%#x) = %#x, want it unchanged", on, got)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"encoding/csv"
 	"fmt"
+	"slices"
 	"strconv"
+	"time"
 
 	"github.com/KilimcininKorOglu/poros/internal/trace"
 )
@@ -17,10 +19,15 @@ type CSVFormatter struct {
 
 // Default CSV columns
 var defaultCSVColumns = []string{
-	"hop", "ip", "hostname", "asn", "org", "country", "city",
+	"hop", "ip", "hostname", "asn", "org", "country", "country_code",
+	"region", "region_code", "city",
 	"avg_rtt_ms", "min_rtt_ms", "max_rtt_ms", "jitter_ms", "loss_percent",
+	"isp", "hosting", "proxy", "mobile", "timestamp",
 }
 
+// Columns added to the defaults for a --watch total
+var watchCSVColumns = []string{"sent", "received", "stddev_ms"}
+
 // NewCSVFormatter creates a new CSV formatter.
 func NewCSVFormatter(config Config) *CSVFormatter {
 	return &CSVFormatter{
@@ -39,14 +46,35 @@ func (f *CSVFormatter) Format(result *trace.TraceResult) ([]byte, error) {
 	var buf bytes.Buffer
 	writer := csv.NewWriter(&buf)
 
+	// Tag columns repeat the same value on every row
+	var tagKeys []string
+	if f.config.CSVTags && result.Meta != nil {
+		tagKeys = trace.TagKeys(result.Meta.Tags)
+	}
+
+	// A --watch total with the default columns also counts the probes
+	if result.Rounds > 0 && slices.Equal(f.columns, defaultCSVColumns) {
+		copied := *f
+		copied.columns = append(slices.Clip(defaultCSVColumns), watchCSVColumns...)
+		f = &copied
+	}
+
 	// Write header
-	if err := writer.Write(f.columns); err != nil {
+	header := f.columns
+	for _, key := range tagKeys {
+		header = append(header[:len(header):len(header)], "tag_"+key)
+	}
+	if err := writer.Write(header); err != nil {
 		return nil, err
 	}
 
 	// Write data rows
+	timestamp := f.config.FormatTime(result.Timestamp, time.RFC3339)
 	for _, hop := range result.Hops {
-		row := f.formatRow(&hop)
+		row := f.formatRow(&hop, timestamp)
+		for _, key := range tagKeys {
+			row = append(row, result.Meta.Tags[key])
+		}
 		if err := writer.Write(row); err != nil {
 			return nil, err
 		}
@@ -61,10 +89,16 @@ func (f *CSVFormatter) Format(result *trace.TraceResult) ([]byte, error) {
 }
 
 // formatRow formats a single hop as a CSV row.
-func (f *CSVFormatter) formatRow(hop *trace.Hop) []string {
+// The trace timestamp is the same for every row and is passed in
+// preformatted.
+func (f *CSVFormatter) formatRow(hop *trace.Hop, timestamp string) []string {
 	row := make([]string, len(f.columns))
 
 	for i, col := range f.columns {
+		if col == "timestamp" {
+			row[i] = timestamp
+			continue
+		}
 		row[i] = f.getValue(hop, col)
 	}
 
@@ -78,8 +112,8 @@ func (f *CSVFormatter) getValue(hop *trace.Hop, column string) string {
 		return strconv.Itoa(hop.Number)
 
 	case "ip":
-		if hop.IP != nil {
-			return hop.IP.String()
+		if addr := hop.Address(); addr != "" {
+			return addr
 		}
 		return "*"
 
@@ -99,17 +133,48 @@ func (f *CSVFormatter) getValue(hop *trace.Hop, column string) string {
 		return ""
 
 	case "country":
+		if hop.Geo != nil {
+			return hop.Geo.Country
+		}
+		return ""
+
+	case "country_code":
 		if hop.Geo != nil {
 			return hop.Geo.CountryCode
 		}
 		return ""
 
+	case "region":
+		if hop.Geo != nil {
+			return hop.Geo.Region
+		}
+		return ""
+	case "region_code":
+		if hop.Geo != nil {
+			return hop.Geo.RegionCode
+		}
+		return ""
 	case "city":
 		if hop.Geo != nil {
 			return hop.Geo.City
 		}
 		return ""
 
+	case "isp":
+		if hop.Geo != nil {
+			return hop.Geo.ISP
+		}
+		return ""
+
+	case "hosting":
+		return strconv.FormatBool(hop.Geo != nil && hop.Geo.Hosting)
+
+	case "proxy":
+		return strconv.FormatBool(hop.Geo != nil && hop.Geo.Proxy)
+
+	case "mobile":
+		return strconv.FormatBool(hop.Geo != nil && hop.Geo.Mobile)
+
 	case "avg_rtt_ms":
 		return formatFloat(hop.AvgRTT)
 
@@ -125,6 +190,15 @@ func (f *CSVFormatter) getValue(hop *trace.Hop, column string) string {
 	case "loss_percent":
 		return formatFloat(hop.LossPercent)
 
+	case "sent":
+		return strconv.Itoa(hop.Sent)
+
+	case "received":
+		return strconv.Itoa(hop.Received)
+
+	case "stddev_ms":
+		return formatFloat(hop.StdDev)
+
 	case "responded":
 		if hop.Responded {
 			return "true"
@@ -0,0 +1,54 @@
+package output
+
+import (
+	"fmt"
+
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+)
+
+// DirectHop returns the only hop of a completed trace whose destination
+// answered at hop 1: a directly connected neighbour or the local host.
+// Path statistics say nothing more than that hop's RTTs for such traces,
+// so formatters show a short description (see Config.FormatDirect)
+// instead of a one-row listing. It returns nil for any other trace.
+func DirectHop(result *trace.TraceResult) *trace.Hop {
+	if !result.Completed || result.Skipped != nil || len(result.Hops) != 1 {
+		return nil
+	}
+	hop := &result.Hops[0]
+	if hop.Number != 1 || !hop.Responded {
+		return nil
+	}
+	return hop
+}
+
+// FormatDirect describes a destination reached at hop 1, e.g.
+// "Destination is 1 hop away (directly connected), RTT 0.312/0.340/0.401 ms"
+// with the min/avg/max RTT in the configured unit and locale, and the
+// loss if any probe went unanswered.
+func (c Config) FormatDirect(hop *trace.Hop) string {
+	return formatDirect(hop, c.numbers([]trace.Hop{*hop}))
+}
+
+// formatDirect implements FormatDirect with a given number format.
+func formatDirect(hop *trace.Hop, n numberFormat) string {
+	where := "1 hop away (directly connected)"
+	if hop.IP != nil && hop.IP.IsLoopback() {
+		where = "the local host"
+	}
+	s := fmt.Sprintf("Destination is %s, RTT %s/%s/%s %s", where,
+		n.rttValue(hop.MinRTT, 3), n.rttValue(hop.AvgRTT, 3), n.rttValue(hop.MaxRTT, 3), n.unit())
+	if hop.LossPercent > 0 {
+		s += ", " + n.percent(hop.LossPercent, 1) + " loss"
+	}
+	return s
+}
+
+// directAddress returns the address of a direct hop with its hostname,
+// e.g. "192.168.1.1 (router.lan)".
+func directAddress(hop *trace.Hop) string {
+	if hop.Hostname == "" {
+		return hop.Address()
+	}
+	return hop.Address() + " (" + hostnameLabel(hop) + ")"
+}
@@ -0,0 +1,43 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultFilename returns a report filename for target in the current
+// directory, e.g. "poros-example.com-20251218-120000.html".
+func DefaultFilename(target string, t time.Time, ext string) string {
+	return fmt.Sprintf("poros-%s-%s.%s", SanitizeFilename(target), t.Format("20060102-150405"), ext)
+}
+
+// SanitizeFilename makes a target usable as part of a filename on all
+// platforms. URL schemes are dropped, and characters such as ':' and '/'
+// (IPv6 addresses, URLs) are replaced with '_'.
+func SanitizeFilename(s string) string {
+	if i := strings.Index(s, "://"); i >= 0 {
+		s = s[i+3:]
+	}
+
+	var b strings.Builder
+	lastUnderscore := false
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-':
+			b.WriteRune(r)
+			lastUnderscore = false
+		default:
+			if !lastUnderscore {
+				b.WriteRune('_')
+				lastUnderscore = true
+			}
+		}
+	}
+
+	name := strings.Trim(b.String(), "_.")
+	if name == "" {
+		return "trace"
+	}
+	return name
+}
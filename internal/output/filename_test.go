@@ -0,0 +1,40 @@
+package output
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"example.com", "example.com"},
+		{"8.8.8.8", "8.8.8.8"},
+		{"2001:4860:4860::8888", "2001_4860_4860_8888"},
+		{"https://example.com/path?q=1", "example.com_path_q_1"},
+		{"[::1]:443", "1_443"},
+		{`C:\evil\..\name`, "C_evil_.._name"},
+		{"../../etc/passwd", "etc_passwd"},
+		{"", "trace"},
+		{"::", "trace"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := SanitizeFilename(tt.input); got != tt.want {
+				t.Errorf("SanitizeFilename(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultFilename(t *testing.T) {
+	ts := time.Date(2025, 12, 18, 9, 5, 7, 0, time.UTC)
+
+	got := DefaultFilename("2001:db8::1", ts, NewHTMLFormatter(Config{}).FileExtension())
+	if want := "poros-2001_db8_1-20251218-090507.html"; got != want {
+		t.Errorf("DefaultFilename() = %q, want %q", got, want)
+	}
+}
@@ -2,6 +2,9 @@
 package output
 
 import (
+	"net"
+	"strings"
+
 	"github.com/KilimcininKorOglu/poros/internal/trace"
 )
 
@@ -51,29 +54,107 @@ type Formatter interface {
 	FileExtension() string
 }
 
+// StreamFormatter is implemented by formatters that can render a trace
+// incrementally, one hop at a time, as results arrive.
+type StreamFormatter interface {
+	Formatter
+
+	// FormatHeader formats the header written before the first hop.
+	FormatHeader(target string, maxHops int) string
+
+	// FormatHop formats a single hop.
+	FormatHop(hop *trace.Hop) string
+
+	// FormatSkipped formats the collapsed private prefix.
+	FormatSkipped(skipped *trace.SkippedHops) string
+
+	// FormatSummary formats the footer written after the last hop.
+	FormatSummary(result *trace.TraceResult) string
+}
+
+// PartialHeaderFormatter is implemented by stream formatters with a
+// dedicated header for partial-path traces that stop at a given hop.
+type PartialHeaderFormatter interface {
+	FormatPartialHeader(target string, firstHop, lastHop int) string
+}
+
 // Config holds configuration for formatters.
 type Config struct {
 	// Colors enables ANSI color output
 	Colors bool
 
-	// NoHostname disables hostname display
+	// NoHostname, NoASN and NoGeoIP hide hostnames, ASN and GeoIP data
+	// in text, table and TUI output. JSON, CSV and HTML output keep
+	// whatever the trace collected.
 	NoHostname bool
+	NoASN      bool
+	NoGeoIP    bool
 
-	// NoASN disables ASN information display
-	NoASN bool
+	// ASNDetail adds the AS country code and announced prefix to text output
+	ASNDetail bool
 
-	// NoGeoIP disables GeoIP information display
-	NoGeoIP bool
+	// CountryNames shows full country names instead of ISO codes in the
+	// table and TUI locations (see Location)
+	CountryNames bool
 
 	// Width is the terminal width (0 = auto-detect)
 	Width int
+
+	// RTTWarnMs is the latency at which RTTs are shown as warnings (0 = default)
+	RTTWarnMs float64
+
+	// RTTCritMs is the latency at which RTTs are shown as critical (0 = default)
+	RTTCritMs float64
+
+	// LossWarnPercent is the loss above which hops are shown as warnings
+	LossWarnPercent float64
+
+	// LossCritPercent is the loss above which hops are shown as critical (0 = default)
+	LossCritPercent float64
+
+	// TimeFormat selects how timestamps are rendered: rfc3339, unix,
+	// local or a Go layout ("" = each format's own default)
+	TimeFormat string
+
+	// UTC converts timestamps to UTC before formatting
+	UTC bool
+
+	// Units selects the RTT unit of text, table and HTML output: ms, us
+	// or auto (µs when all RTTs are below 1 ms; "" = ms)
+	Units string
+
+	// Locale selects the decimal separator of text, table and HTML
+	// output, e.g. "de-DE" for a decimal comma ("" = dot)
+	Locale string
+
+	// Columns selects and orders the verbose table columns (nil = default)
+	Columns []string
+
+	// CSVTags adds a tag_<key> column per result tag to CSV output
+	CSVTags bool
+
+	// ProbeTimes adds the send time of each probe to JSON output
+	ProbeTimes bool
+
+	// CollapseTimeouts replaces runs of three or more unresponsive hops
+	// with a single line in text and table output
+	CollapseTimeouts bool
+
+	// SummaryPerHop ends each text hop line with the hop's average RTT,
+	// jitter and loss; hops with more than three probes always show them
+	SummaryPerHop bool
 }
 
 // DefaultConfig returns a Config with sensible defaults.
 func DefaultConfig() Config {
 	return Config{
-		Colors: true,
-		Width:  0, // Auto-detect
+		Colors:    true,
+		Width:     0, // Auto-detect
+		RTTWarnMs: DefaultRTTWarnMs,
+		RTTCritMs: DefaultRTTCritMs,
+
+		LossWarnPercent: DefaultLossWarnPercent,
+		LossCritPercent: DefaultLossCritPercent,
 	}
 }
 
@@ -94,3 +175,60 @@ func NewFormatter(format Format, config Config) Formatter {
 		return NewTextFormatter(config)
 	}
 }
+
+// targetLabel returns the target as shown in headers, preceded by the
+// alias chain that led to it, e.g. "prod → prod-eu → 203.0.113.7".
+func targetLabel(result *trace.TraceResult) string {
+	if len(result.Aliases) == 0 {
+		return result.Target
+	}
+	return strings.Join(result.Aliases, " → ") + " → " + result.Target
+}
+
+// ipString returns ip as a string, or "" if it is nil, e.g. because the
+// result was anonymized.
+func ipString(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// unverifiedMark follows a hostname that failed forward confirmation
+// (see trace.Hop.HostnameMismatch).
+const unverifiedMark = "?"
+
+// hostnameLabel returns the hostname of hop as text output shows it,
+// e.g. "core1.example.net?" for a mismatched PTR record.
+func hostnameLabel(hop *trace.Hop) string {
+	if hop.HostnameMismatch() {
+		return hop.Hostname + unverifiedMark
+	}
+	return hop.Hostname
+}
+
+// truncateHostname is truncateString for a hostname label, keeping the
+// trailing unverified mark.
+func truncateHostname(label string, maxLen int) string {
+	if name, ok := strings.CutSuffix(label, unverifiedMark); ok && len(label) > maxLen {
+		return truncateString(name, maxLen-len(unverifiedMark)) + unverifiedMark
+	}
+	return truncateString(label, maxLen)
+}
+
+// resolvedLabel returns the resolved address with the destination's PTR
+// name, e.g. "142.250.185.238, fra16s56-in-f14.1e100.net", or only the
+// name when the target is the address itself, e.g. "dns.google".
+func resolvedLabel(result *trace.TraceResult) string {
+	resolved := ipString(result.ResolvedIP)
+	switch {
+	case resolved == "":
+		return result.Target
+	case result.TargetPTR == "":
+		return resolved
+	case resolved == result.Target:
+		return result.TargetPTR
+	default:
+		return resolved + ", " + result.TargetPTR
+	}
+}
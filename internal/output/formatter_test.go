@@ -1,66 +1,34 @@
 package output
 
 import (
+	"bytes"
+	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/KilimcininKorOglu/poros/internal/probe"
+	"github.com/KilimcininKorOglu/poros/internal/probe/probetest"
 	"github.com/KilimcininKorOglu/poros/internal/trace"
+	"github.com/KilimcininKorOglu/poros/internal/trace/tracetest"
 )
 
 // Helper function to create a sample trace result
 func sampleTraceResult() *trace.TraceResult {
-	return &trace.TraceResult{
-		Target:      "google.com",
-		ResolvedIP:  net.ParseIP("142.250.185.238"),
-		Timestamp:   time.Date(2025, 12, 18, 12, 0, 0, 0, time.UTC),
-		ProbeMethod: "icmp",
-		Completed:   true,
-		Hops: []trace.Hop{
-			{
-				Number:      1,
-				IP:          net.ParseIP("192.168.1.1"),
-				Hostname:    "router.local",
-				RTTs:        []float64{1.234, 1.456, 1.123},
-				AvgRTT:      1.271,
-				MinRTT:      1.123,
-				MaxRTT:      1.456,
-				Jitter:      0.333,
-				LossPercent: 0,
-				Responded:   true,
-			},
-			{
-				Number:      2,
-				IP:          net.ParseIP("10.0.0.1"),
-				Hostname:    "",
-				RTTs:        []float64{5.678, -1, 5.432},
-				AvgRTT:      5.555,
-				MinRTT:      5.432,
-				MaxRTT:      5.678,
-				Jitter:      0.246,
-				LossPercent: 33.33,
-				Responded:   true,
-				ASN: &trace.ASNInfo{
-					Number: 15169,
-					Org:    "Google LLC",
-				},
-			},
-			{
-				Number:      3,
-				RTTs:        []float64{-1, -1, -1},
-				LossPercent: 100,
-				Responded:   false,
-			},
-		},
-		Summary: trace.Summary{
-			TotalHops:         3,
-			TotalTimeMs:       5.555,
-			PacketLossPercent: 44.44,
-		},
-	}
+	return tracetest.Result("google.com").
+		Resolved("142.250.185.238").
+		Hop(1, "192.168.1.1", 1.234, 1.456, 1.123).Hostname("router.local").
+		Hop(2, "10.0.0.1", 5.678, -1, 5.432).ASN(15169, "Google LLC").
+		TimeoutHop(3).
+		Completed(true).
+		Build()
 }
 
 func TestTextFormatter(t *testing.T) {
@@ -137,6 +105,17 @@ func TestTableFormatter(t *testing.T) {
 		t.Error("Output should contain hop IP")
 	}
 
+	// Check per-probe samples, including the timed-out probe
+	if !strings.Contains(output, "RTT SAMPLES") {
+		t.Error("Output should contain RTT SAMPLES column")
+	}
+	if !strings.Contains(output, "5.7 / * / 5.4") {
+		t.Error("Output should contain per-probe RTTs with timeouts as *")
+	}
+	if !strings.Contains(output, "* / * / *") {
+		t.Error("Output should show all-timeout samples for silent hops")
+	}
+
 	// Check summary
 	if !strings.Contains(output, "Total Hops") {
 		t.Error("Output should contain summary")
@@ -293,6 +272,11 @@ func TestHTMLFormatter(t *testing.T) {
 		t.Error("Output should contain hop IP")
 	}
 
+	// Check sample tooltip
+	if !strings.Contains(output, `title="Samples: 5.7 / * / 5.4 ms"`) {
+		t.Error("Output should contain RTT sample tooltip")
+	}
+
 	// Check CSS
 	if !strings.Contains(output, "<style>") {
 		t.Error("Output should contain embedded CSS")
@@ -302,6 +286,339 @@ func TestHTMLFormatter(t *testing.T) {
 	if !strings.Contains(output, "Total Hops") {
 		t.Error("Output should contain summary")
 	}
+
+	// The built-in template passes the checks of custom ones
+	if err := formatter.check(); err != nil {
+		t.Errorf("built-in template check() error = %v", err)
+	}
+}
+
+func TestHTMLFormatterFromFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, source string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	custom := write("brand.tmpl", `<h1>ACME {{.Target}}</h1>
+{{range .Hops}}<p>{{.Number}} {{.IP}} {{.AvgRTT}} {{$.Unit}}</p>
+{{end}}{{with .Meta}}<footer>{{.Version}}</footer>{{end}}
+<small>{{formatTime .Timestamp}}</small>`)
+
+	formatter, err := NewHTMLFormatterFromFile(Config{}, custom)
+	if err != nil {
+		t.Fatalf("NewHTMLFormatterFromFile() error = %v", err)
+	}
+	data, err := formatter.Format(sampleTraceResult())
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	output := string(data)
+	for _, want := range []string{"<h1>ACME google.com</h1>", "<p>1 192.168.1.1 ", " ms</p>"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q:\n%s", want, output)
+		}
+	}
+
+	// Broken templates fail before any trace is formatted
+	tests := []struct {
+		name    string
+		source  string
+		wantErr string
+	}{
+		{"syntax", `{{range .Hops}}`, "unexpected EOF"},
+		{"unknown field", `{{.Target}} {{.Hostname}}`, "Hostname"},
+		{"unknown function", `{{upper .Target}}`, "upper"},
+		{"unguarded baseline", `{{.Baseline.TotalHops}}`, "nil pointer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewHTMLFormatterFromFile(Config{}, write(tt.name+".tmpl", tt.source))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewHTMLFormatterFromFile() error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := NewHTMLFormatterFromFile(Config{}, filepath.Join(dir, "missing.tmpl")); err == nil {
+		t.Error("NewHTMLFormatterFromFile() should fail for a missing file")
+	}
+}
+
+func TestHTMLFormatter_Baseline(t *testing.T) {
+	baseline := sampleTraceResult()
+	baseline.Timestamp = time.Date(2025, 12, 17, 9, 30, 0, 0, time.UTC)
+	baseline.Hops[0].AvgRTT = 0.271
+	baseline.Hops[1].IP = net.ParseIP("10.0.0.9")
+	baseline.Hops[1].LossPercent = 0
+	baseline.Hops = append(baseline.Hops, trace.Hop{Number: 4, IP: net.ParseIP("142.250.185.238"), AvgRTT: 9, Responded: true})
+
+	formatter := NewHTMLFormatter(Config{})
+	formatter.SetBaseline(baseline)
+	data, err := formatter.Format(sampleTraceResult())
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	html := string(data)
+
+	for _, want := range []string{
+		"<th>ΔAvg RTT</th>",
+		`<td class="delta worse">&#43;1.00 ms</td>`, // hop 1 slower
+		`<td class="delta neutral">&#43;0%</td>`,    // hop 1 loss unchanged
+		`<td class="delta worse">&#43;33%</td>`,     // hop 2 loss
+		`<td class="delta neutral">-</td>`,          // hop 3 timed out
+		`<tr class="loss-crit ip-changed">`,
+		"was 10.0.0.9",
+		"Baseline only: hop 4",
+		"2025-12-17 09:30:00 UTC, 4 hops",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("HTML report should contain %q", want)
+		}
+	}
+	if n := strings.Count(html, `class="changed-marker"`); n != 1 {
+		t.Errorf("changed markers = %d, want 1 (hop 2)", n)
+	}
+
+	// Without a baseline the comparison columns are not rendered
+	formatter.SetBaseline(nil)
+	data, _ = formatter.Format(sampleTraceResult())
+	if strings.Contains(string(data), "ΔAvg RTT") || strings.Contains(string(data), `class="changed-marker"`) {
+		t.Error("report without a baseline should not show deltas")
+	}
+}
+
+func TestFormatters_SourceRoute(t *testing.T) {
+	result := sampleTraceResult()
+	result.Via = []net.IP{net.ParseIP("10.0.0.5")}
+	result.Hops[1].SourceRouteRejected = true
+
+	text, err := NewTextFormatter(Config{NoHostname: true}).Format(result)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	lines := strings.Split(string(text), "\n")
+	if !strings.Contains(lines[0], "loose source routed via 10.0.0.5") {
+		t.Errorf("header %q should name the source route", lines[0])
+	}
+	if !strings.Contains(string(text), "  2  10.0.0.1") || !strings.Contains(lines[3], "!S") {
+		t.Errorf("hop 2 should be marked !S:\n%s", text)
+	}
+	if strings.Contains(lines[2], "!S") {
+		t.Errorf("hop 1 should not be marked: %q", lines[2])
+	}
+	if summary := NewTextFormatter(Config{}).FormatSummary(result); !strings.Contains(summary, "loose source routed via 10.0.0.5") {
+		t.Errorf("streamed summary %q should name the source route", summary)
+	}
+
+	data, err := NewJSONFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	parsed, err := ParseJSONResult(data)
+	if err != nil {
+		t.Fatalf("ParseJSONResult() error = %v", err)
+	}
+	if len(parsed.Via) != 1 || !parsed.Via[0].Equal(result.Via[0]) || !parsed.Hops[1].SourceRouteRejected || parsed.Hops[0].SourceRouteRejected {
+		t.Errorf("JSON round trip lost the source route: via %v, hops %+v", parsed.Via, parsed.Hops[:2])
+	}
+}
+
+func TestFormatters_TargetPTR(t *testing.T) {
+	result := sampleTraceResult()
+	result.TargetPTR = "fra16s56-in-f14.1e100.net"
+
+	text, err := NewTextFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if !strings.HasPrefix(string(text), "traceroute to google.com (142.250.185.238, fra16s56-in-f14.1e100.net)") {
+		t.Errorf("header should name the PTR:\n%s", text)
+	}
+	if summary := NewTextFormatter(Config{}).FormatSummary(result); !strings.Contains(summary, "Destination 142.250.185.238 is fra16s56-in-f14.1e100.net") {
+		t.Errorf("streamed summary %q should name the PTR", summary)
+	}
+
+	// An IP literal target shows the PTR in place of the repeated address
+	result.Target = "142.250.185.238"
+	text, _ = NewTextFormatter(Config{}).Format(result)
+	if !strings.HasPrefix(string(text), "traceroute to 142.250.185.238 (fra16s56-in-f14.1e100.net)") {
+		t.Errorf("IP literal header should show only the PTR:\n%s", text)
+	}
+	table, _ := NewTableFormatter(Config{}).Format(result)
+	if !strings.Contains(string(table), "Target: 142.250.185.238 (fra16s56-in-f14.1e100.net)") {
+		t.Errorf("table header should name the PTR:\n%s", table)
+	}
+	html, _ := NewHTMLFormatter(Config{}).Format(result)
+	if !strings.Contains(string(html), "Destination PTR") || !strings.Contains(string(html), "fra16s56-in-f14.1e100.net") {
+		t.Error("HTML report should show the destination PTR")
+	}
+
+	data, err := NewJSONFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	parsed, err := ParseJSONResult(data)
+	if err != nil {
+		t.Fatalf("ParseJSONResult() error = %v", err)
+	}
+	if parsed.TargetPTR != result.TargetPTR {
+		t.Errorf("JSON round trip TargetPTR = %q, want %q", parsed.TargetPTR, result.TargetPTR)
+	}
+
+	result.TargetPTR = ""
+	text, _ = NewTextFormatter(Config{}).Format(result)
+	if !strings.HasPrefix(string(text), "traceroute to 142.250.185.238 (142.250.185.238)") {
+		t.Errorf("header without PTR changed:\n%s", text)
+	}
+}
+
+func TestFormatters_PacketBudget(t *testing.T) {
+	result := sampleTraceResult()
+	result.Completed = false
+	result.StoppedReason = trace.StopPacketBudget
+	result.Hops[2] = trace.Hop{Number: 3, RTTs: []float64{}, Unprobed: true}
+
+	text, err := NewTextFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if !strings.Contains(string(text), "  3  not probed (packet budget exhausted)") {
+		t.Errorf("hop 3 should be shown as not probed:\n%s", text)
+	}
+	if !strings.Contains(string(text), "Trace stopped after 3 hops, packet budget exhausted") {
+		t.Errorf("summary should name the packet budget:\n%s", text)
+	}
+
+	data, err := NewJSONFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if !strings.Contains(string(data), `"stopped_reason": "packet_budget"`) {
+		t.Errorf("JSON should include stopped_reason packet_budget:\n%s", data)
+	}
+	parsed, err := ParseJSONResult(data)
+	if err != nil {
+		t.Fatalf("ParseJSONResult() error = %v", err)
+	}
+	if !parsed.Hops[2].Unprobed || parsed.Hops[1].Unprobed || parsed.StoppedReason != trace.StopPacketBudget {
+		t.Errorf("JSON round trip lost the packet budget: stopped %q, hops %+v", parsed.StoppedReason, parsed.Hops)
+	}
+}
+
+func TestParseJSONResult(t *testing.T) {
+	original := sampleTraceResult()
+	data, err := NewJSONFormatter(Config{}).Format(original)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	result, err := ParseJSONResult(data)
+	if err != nil {
+		t.Fatalf("ParseJSONResult() error = %v", err)
+	}
+	if result.Target != original.Target || !result.ResolvedIP.Equal(original.ResolvedIP) ||
+		!result.Timestamp.Equal(original.Timestamp) || len(result.Hops) != len(original.Hops) {
+		t.Fatalf("ParseJSONResult() = %+v", result)
+	}
+	hop := result.Hops[1]
+	if !hop.IP.Equal(net.ParseIP("10.0.0.1")) || hop.AvgRTT != 5.555 || hop.LossPercent != 33.3 ||
+		hop.ASN == nil || hop.ASN.Number != 15169 || !hop.Responded {
+		t.Errorf("hop 2 = %+v", hop)
+	}
+	if result.Hops[2].Responded || result.Hops[2].IP != nil {
+		t.Errorf("hop 3 = %+v, want a timeout", result.Hops[2])
+	}
+
+	if result.Hops[0].SentAt != nil {
+		t.Errorf("hop 1 SentAt = %v without ProbeTimes", result.Hops[0].SentAt)
+	}
+
+	if _, err := ParseJSONResult([]byte("hop 1 192.0.2.1")); err == nil {
+		t.Error("ParseJSONResult() should reject non-JSON input")
+	}
+}
+
+func TestJSONFormatter_ProbeTimes(t *testing.T) {
+	sent := time.Date(2025, 12, 18, 12, 0, 0, 123456789, time.UTC)
+	result := sampleTraceResult()
+	result.Hops[1].SentAt = []time.Time{sent, {}, sent.Add(time.Second)}
+
+	data, err := NewJSONFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if strings.Contains(string(data), "sent_at") {
+		t.Error("sent_at written without ProbeTimes")
+	}
+
+	data, err = NewJSONFormatter(Config{ProbeTimes: true}).Format(result)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if !strings.Contains(string(data), `"2025-12-18T12:00:00.123456789Z"`) {
+		t.Errorf("JSON missing RFC 3339 send time:\n%s", data)
+	}
+
+	parsed, err := ParseJSONResult(data)
+	if err != nil {
+		t.Fatalf("ParseJSONResult() error = %v", err)
+	}
+	got := parsed.Hops[1].SentAt
+	if len(got) != 3 || !got[0].Equal(sent) || !got[1].IsZero() || !got[2].Equal(sent.Add(time.Second)) {
+		t.Errorf("parsed SentAt = %v", got)
+	}
+	if parsed.Hops[0].SentAt != nil {
+		t.Errorf("hop 1 SentAt = %v, want nil", parsed.Hops[0].SentAt)
+	}
+}
+
+func TestJSONFormatter_FormatError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		stage  string
+		target string
+	}{
+		{"config", trace.WithStage(trace.StageConfig, "", trace.ErrInvalidMaxHops), "config", ""},
+		{"resolve", trace.WithStage(trace.StageResolve, "nx.example", trace.ErrTargetResolution), "resolve", "nx.example"},
+		{"socket", fmt.Errorf("failed to create tracer: %w",
+			trace.WithStage(trace.StageSocket, "example.com", errors.New("operation not permitted"))), "socket", "example.com"},
+		{"trace", errors.New("trace interrupted"), "trace", ""},
+		{"output", trace.WithStage(trace.StageOutput, "example.com", errors.New("broken pipe")), "output", "example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := NewJSONFormatterCompact(Config{}).FormatError(tt.err)
+			if err != nil {
+				t.Fatalf("FormatError() error = %v", err)
+			}
+
+			var doc struct {
+				Error struct {
+					Stage   string `json:"stage"`
+					Message string `json:"message"`
+					Target  string `json:"target"`
+				} `json:"error"`
+				SchemaVersion int `json:"schema_version"`
+			}
+			if err := json.Unmarshal(data, &doc); err != nil {
+				t.Fatalf("invalid JSON %s: %v", data, err)
+			}
+			if doc.Error.Stage != tt.stage || doc.Error.Target != tt.target ||
+				doc.Error.Message != tt.err.Error() || doc.SchemaVersion != JSONSchemaVersion {
+				t.Errorf("FormatError() = %s", data)
+			}
+
+			if _, err := ParseJSONResult(data); err == nil {
+				t.Error("ParseJSONResult() should reject an error document")
+			}
+		})
+	}
 }
 
 func TestHTMLFormatter_RTTClass(t *testing.T) {
@@ -317,7 +634,7 @@ func TestHTMLFormatter_RTTClass(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		result := rttClass(tt.rtt)
+		result := rttClass(tt.rtt, Config{})
 		if result != tt.expected {
 			t.Errorf("rttClass(%v) = %q, want %q", tt.rtt, result, tt.expected)
 		}
@@ -368,3 +685,1353 @@ func TestRoundFloat(t *testing.T) {
 		}
 	}
 }
+
+func TestFormatters_SkippedPrefix(t *testing.T) {
+	result := sampleTraceResult()
+	result.Skipped = &trace.SkippedHops{FirstHop: 1, LastHop: 5, RTT: 4.2}
+
+	text, err := NewTextFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("Text Format() error = %v", err)
+	}
+	if !strings.Contains(string(text), "1-5  private network, 4.20 ms") {
+		t.Errorf("Text output should contain collapsed prefix, got:\n%s", text)
+	}
+
+	table, err := NewTableFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("Table Format() error = %v", err)
+	}
+	if !strings.Contains(string(table), "1-5") || !strings.Contains(string(table), "private network") {
+		t.Errorf("Table output should contain collapsed prefix row, got:\n%s", table)
+	}
+
+	data, err := NewJSONFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("JSON Format() error = %v", err)
+	}
+	var parsed JSONOutput
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("JSON parsing error: %v", err)
+	}
+	if parsed.Skipped == nil {
+		t.Fatal("JSON skipped should be present")
+	}
+	if parsed.Skipped.FirstHop != 1 || parsed.Skipped.LastHop != 5 || parsed.Skipped.RTT != 4.2 {
+		t.Errorf("JSON skipped = %+v, want 1-5 at 4.2 ms", parsed.Skipped)
+	}
+
+	// Omitted when nothing was skipped
+	data, _ = NewJSONFormatter(Config{}).Format(sampleTraceResult())
+	if strings.Contains(string(data), "skipped") {
+		t.Error("JSON should omit skipped when no prefix was skipped")
+	}
+}
+
+func TestFormatters_Meta(t *testing.T) {
+	result := sampleTraceResult()
+	result.Meta = &trace.Meta{
+		Version:    "1.2.3",
+		Hostname:   "laptop-01",
+		OS:         "linux",
+		Arch:       "amd64",
+		SourceIP:   net.ParseIP("10.8.0.5"),
+		Interface:  "tun0",
+		ProbeCount: 3,
+		MaxHops:    30,
+		Enrichment: map[string]string{"rdns": "online", "asn": "offline"},
+		ICMPID:     0xBEEF,
+		SeqStart:   100,
+	}
+
+	data, err := NewJSONFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("JSON Format() error = %v", err)
+	}
+	var parsed JSONOutput
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("JSON parsing error: %v", err)
+	}
+	if parsed.Meta == nil {
+		t.Fatal("JSON meta should be present")
+	}
+	if parsed.Meta.Hostname != "laptop-01" || parsed.Meta.SourceIP != "10.8.0.5" || parsed.Meta.Version != "1.2.3" {
+		t.Errorf("JSON meta = %+v", parsed.Meta)
+	}
+	if parsed.Meta.Enrichment["asn"] != "offline" {
+		t.Errorf("JSON meta enrichment = %v, want asn offline", parsed.Meta.Enrichment)
+	}
+	if parsed.Meta.ICMPID != 0xBEEF || parsed.Meta.SeqStart != 100 || parsed.Meta.FlowID != 0 {
+		t.Errorf("JSON meta packet IDs = %d/%d/%d, want 48879/100/0", parsed.Meta.ICMPID, parsed.Meta.SeqStart, parsed.Meta.FlowID)
+	}
+
+	table, _ := NewTableFormatter(Config{}).Format(result)
+	if !strings.Contains(string(table), "Host: laptop-01 (linux/amd64) | Source: 10.8.0.5 (tun0) | Poros: 1.2.3") {
+		t.Errorf("Table header should contain meta line, got:\n%s", table)
+	}
+
+	html, _ := NewHTMLFormatter(Config{}).Format(result)
+	for _, want := range []string{"laptop-01 linux/amd64", "10.8.0.5 (tun0)", "rdns: online, asn: offline", "1.2.3"} {
+		if !strings.Contains(string(html), want) {
+			t.Errorf("HTML report should contain %q", want)
+		}
+	}
+
+	text, _ := NewTextFormatter(Config{}).Format(result)
+	if strings.Contains(string(text), "laptop-01") {
+		t.Error("Text output should not show meta")
+	}
+
+	// Meta is optional
+	data, _ = NewJSONFormatter(Config{}).Format(sampleTraceResult())
+	if strings.Contains(string(data), `"meta"`) {
+		t.Error("JSON should omit meta when not set")
+	}
+}
+
+func TestTextFormatter_ASNDetail(t *testing.T) {
+	result := sampleTraceResult()
+	result.Hops[1].ASN.Prefix = "8.8.8.0/24"
+	result.Hops[1].ASN.Country = "US"
+
+	plain, err := NewTextFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if strings.Contains(string(plain), "8.8.8.0/24") {
+		t.Error("Prefix should only be shown with ASNDetail")
+	}
+
+	detailed, err := NewTextFormatter(Config{ASNDetail: true}).Format(result)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if !strings.Contains(string(detailed), "US 8.8.8.0/24]") {
+		t.Errorf("Detailed output should contain country and prefix, got:\n%s", detailed)
+	}
+}
+
+func TestFormatters_GeoFlags(t *testing.T) {
+	result := sampleTraceResult()
+	result.Hops[1].Geo = &trace.GeoInfo{
+		CountryCode: "DE",
+		City:        "Frankfurt",
+		ISP:         "Example Cloud GmbH",
+		Hosting:     true,
+		Mobile:      true,
+	}
+
+	table, err := NewTableFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("Table Format() error = %v", err)
+	}
+	if !strings.Contains(string(table), "Frankfurt, DE [host][mob]") {
+		t.Errorf("Table output should tag the location, got:\n%s", table)
+	}
+
+	data, err := NewJSONFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("JSON Format() error = %v", err)
+	}
+	var out JSONOutput
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	geo := out.Hops[1].Geo
+	if geo == nil || geo.ISP != "Example Cloud GmbH" || !geo.Hosting || !geo.Mobile || geo.Proxy {
+		t.Errorf("JSON geo = %+v, want ISP with hosting and mobile flags", geo)
+	}
+
+	csvData, err := NewCSVFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("CSV Format() error = %v", err)
+	}
+	records, err := csv.NewReader(strings.NewReader(string(csvData))).ReadAll()
+	if err != nil {
+		t.Fatalf("CSV parse error = %v", err)
+	}
+	row := make(map[string]string)
+	for i, col := range records[0] {
+		row[col] = records[2][i]
+	}
+	if row["isp"] != "Example Cloud GmbH" || row["hosting"] != "true" || row["proxy"] != "false" {
+		t.Errorf("CSV row = %v, want isp and flag columns", row)
+	}
+
+	html, err := NewHTMLFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("HTML Format() error = %v", err)
+	}
+	if !strings.Contains(string(html), "Example Cloud GmbH") || !strings.Contains(string(html), "[host][mob]") {
+		t.Error("HTML output should show ISP and geo tags")
+	}
+}
+
+func TestFormatters_CountryNames(t *testing.T) {
+	result := sampleTraceResult()
+	result.Hops[1].Geo = &trace.GeoInfo{Country: "Germany", CountryCode: "DE", City: "Frankfurt"}
+
+	for _, tt := range []struct {
+		config Config
+		want   string
+	}{
+		{Config{}, "Frankfurt, DE"},
+		{Config{CountryNames: true}, "Frankfurt, Germany"},
+	} {
+		table, err := NewTableFormatter(tt.config).Format(result)
+		if err != nil {
+			t.Fatalf("Table Format() error = %v", err)
+		}
+		if !strings.Contains(string(table), tt.want) {
+			t.Errorf("Table output with CountryNames=%v should contain %q, got:\n%s", tt.config.CountryNames, tt.want, table)
+		}
+	}
+
+	csvData, err := NewCSVFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("CSV Format() error = %v", err)
+	}
+	records, err := csv.NewReader(strings.NewReader(string(csvData))).ReadAll()
+	if err != nil {
+		t.Fatalf("CSV parse error = %v", err)
+	}
+	row := make(map[string]string)
+	for i, col := range records[0] {
+		row[col] = records[2][i]
+	}
+	if row["country"] != "Germany" || row["country_code"] != "DE" || row["city"] != "Frankfurt" {
+		t.Errorf("CSV row = %v, want the country name and code in their own columns", row)
+	}
+	if row["region"] != "" || row["region_code"] != "" {
+		t.Errorf("CSV row = %v, want empty region columns without region data", row)
+	}
+
+	// The report names the country in full, with the code as a tooltip
+	html, err := NewHTMLFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("HTML Format() error = %v", err)
+	}
+	if !strings.Contains(string(html), `<span title="DE">Frankfurt, Germany</span>`) {
+		t.Error("HTML output should show the country name with its code as a tooltip")
+	}
+}
+
+func TestFormatters_Region(t *testing.T) {
+	result := sampleTraceResult()
+	result.Hops[1].Geo = &trace.GeoInfo{Country: "United States", CountryCode: "US",
+		City: "Ashburn", Region: "Virginia", RegionCode: "VA"}
+
+	table, err := NewTableFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("Table Format() error = %v", err)
+	}
+	if !strings.Contains(string(table), "Ashburn, VA, US") {
+		t.Errorf("Table output should contain the region, got:\n%s", table)
+	}
+
+	// A region that does not fit the column is dropped before truncating
+	result.Hops[1].Geo.City = "Sterling Heights"
+	table, err = NewTableFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("Table Format() error = %v", err)
+	}
+	if !strings.Contains(string(table), "Sterling Heights, US") || strings.Contains(string(table), "...") {
+		t.Errorf("Table output should drop the region that does not fit, got:\n%s", table)
+	}
+	result.Hops[1].Geo.City = "Ashburn"
+
+	csvData, err := NewCSVFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("CSV Format() error = %v", err)
+	}
+	records, err := csv.NewReader(strings.NewReader(string(csvData))).ReadAll()
+	if err != nil {
+		t.Fatalf("CSV parse error = %v", err)
+	}
+	row := make(map[string]string)
+	for i, col := range records[0] {
+		row[col] = records[2][i]
+	}
+	if row["region"] != "Virginia" || row["region_code"] != "VA" {
+		t.Errorf("CSV row = %v, want region Virginia and code VA", row)
+	}
+
+	data, err := NewJSONFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("JSON Format() error = %v", err)
+	}
+	if !strings.Contains(string(data), `"region": "Virginia"`) || !strings.Contains(string(data), `"region_code": "VA"`) {
+		t.Errorf("JSON output should contain the region, got:\n%s", data)
+	}
+	parsed, err := ParseJSONResult(data)
+	if err != nil {
+		t.Fatalf("ParseJSONResult() error = %v", err)
+	}
+	if geo := parsed.Hops[1].Geo; geo == nil || geo.Region != "Virginia" || geo.RegionCode != "VA" {
+		t.Errorf("parsed Geo = %+v, want the region back", geo)
+	}
+
+	html, err := NewHTMLFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("HTML Format() error = %v", err)
+	}
+	if !strings.Contains(string(html), `<span title="US">Ashburn, Virginia, United States</span>`) {
+		t.Error("HTML output should show the region in the location cell")
+	}
+}
+
+func TestFormatters_EnrichmentSource(t *testing.T) {
+	result := sampleTraceResult()
+	result.Hops[1].ASN.Source = "cymru"
+	result.Hops[1].Geo = &trace.GeoInfo{CountryCode: "US", City: "Ashburn", Source: "maxmind"}
+
+	table, err := NewTableFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("Table Format() error = %v", err)
+	}
+	for _, want := range []string{"15169ᶜ", "Ashburn, USᵐ", "Sources:       ᵐ maxmind, ᶜ cymru"} {
+		if !strings.Contains(string(table), want) {
+			t.Errorf("Table output should contain %q, got:\n%s", want, table)
+		}
+	}
+
+	text, err := NewTextFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("Text Format() error = %v", err)
+	}
+	if strings.Contains(string(text), "cymru") || strings.Contains(string(text), "ᶜ") {
+		t.Errorf("Text output should not show enrichment sources, got:\n%s", text)
+	}
+
+	data, err := NewJSONFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("JSON Format() error = %v", err)
+	}
+	parsed, err := ParseJSONResult(data)
+	if err != nil {
+		t.Fatalf("ParseJSONResult() error = %v", err)
+	}
+	if hop := parsed.Hops[1]; hop.ASN.Source != "cymru" || hop.Geo.Source != "maxmind" {
+		t.Errorf("JSON sources = %q/%q, want cymru/maxmind", hop.ASN.Source, hop.Geo.Source)
+	}
+}
+
+func TestFormatters_PerAS(t *testing.T) {
+	result := sampleTraceResult()
+	result.Summary.PerAS = []trace.ASContribution{
+		{ASN: 1299, Org: "Arelion", FirstHop: 1, LastHop: 1, RTTMs: 61.2, DeltaMs: 61.2},
+		{ASN: 15169, Org: "Google LLC", FirstHop: 2, LastHop: 3, RTTMs: 5.5, Clamped: true},
+	}
+
+	table, err := NewTableFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("Table Format() error = %v", err)
+	}
+	for _, want := range []string{"Per-AS Latency:", "AS1299", "+61.20 ms (hop 1)", "+0.00 ms (hops 2-3, RTT inversion)"} {
+		if !strings.Contains(string(table), want) {
+			t.Errorf("Table output should contain %q, got:\n%s", want, table)
+		}
+	}
+
+	html, err := NewHTMLFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("HTML Format() error = %v", err)
+	}
+	if !strings.Contains(string(html), "Per-AS Latency") || !strings.Contains(string(html), "61.20 ms (hop 1)") {
+		t.Error("HTML summary should contain the per-AS breakdown")
+	}
+
+	data, err := NewJSONFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("JSON Format() error = %v", err)
+	}
+	var out JSONOutput
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if len(out.Summary.PerAS) != 2 || out.Summary.PerAS[0].Delta != 61.2 || !out.Summary.PerAS[1].Clamped {
+		t.Errorf("JSON per_as = %+v", out.Summary.PerAS)
+	}
+}
+
+func TestFormatters_TranslatedVia(t *testing.T) {
+	result := sampleTraceResult()
+	result.Target = "8.8.8.8"
+	result.ResolvedIP = net.ParseIP("64:ff9b::808:808")
+	result.TranslatedVia = "NAT64 64:ff9b::/96"
+
+	formatters := map[string]Formatter{
+		"text":  NewTextFormatter(Config{}),
+		"table": NewTableFormatter(Config{}),
+		"json":  NewJSONFormatter(Config{}),
+		"html":  NewHTMLFormatter(Config{}),
+	}
+	for name, f := range formatters {
+		data, err := f.Format(result)
+		if err != nil {
+			t.Fatalf("%s Format() error = %v", name, err)
+		}
+		if !strings.Contains(string(data), "NAT64 64:ff9b::/96") {
+			t.Errorf("%s output should explain the NAT64 translation", name)
+		}
+	}
+
+	summary := NewTextFormatter(Config{}).FormatSummary(result)
+	if !strings.Contains(summary, "traced as 64:ff9b::808:808 via NAT64") {
+		t.Errorf("streaming summary should report the translation, got %q", summary)
+	}
+}
+
+func TestFormatters_ModeAndNotes(t *testing.T) {
+	result := sampleTraceResult()
+	result.Mode = trace.ModeSequential
+	result.ProbeSocket = "raw"
+	result.Notes = []string{"concurrent mode disabled for ICMP probes (shared socket)"}
+
+	table, err := NewTableFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("Table Format() error = %v", err)
+	}
+	for _, want := range []string{"Method: ICMP (raw socket, sequential)", "Notes:", "- concurrent mode disabled"} {
+		if !strings.Contains(string(table), want) {
+			t.Errorf("Table output should contain %q", want)
+		}
+	}
+
+	html, err := NewHTMLFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("HTML Format() error = %v", err)
+	}
+	if !strings.Contains(string(html), `<p class="note">Note: concurrent mode disabled`) {
+		t.Error("HTML footer should contain the notes")
+	}
+
+	data, err := NewJSONFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("JSON Format() error = %v", err)
+	}
+	var out JSONOutput
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if out.Mode != "sequential" || out.ProbeSocket != "raw" || len(out.Notes) != 1 {
+		t.Errorf("JSON mode/socket/notes = %q/%q/%v", out.Mode, out.ProbeSocket, out.Notes)
+	}
+
+	junit, err := NewJUnitFormatter(Config{}, Assertions{}).Format(result)
+	if err != nil {
+		t.Fatalf("JUnit Format() error = %v", err)
+	}
+	if !strings.Contains(string(junit), `<property name="mode" value="sequential">`) {
+		t.Errorf("JUnit properties should include the mode, got:\n%s", junit)
+	}
+}
+
+func TestFormatters_Anonymized(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "test-machine"
+	}
+
+	result := sampleTraceResult()
+	result.Hops[0].Interface = &trace.InterfaceInfo{Name: "eth0", IP: net.ParseIP("192.168.1.1")}
+	result.Redirects = []trace.Redirect{{Router: net.ParseIP("192.168.1.1"), Gateway: net.ParseIP("192.168.1.2"), Count: 1}}
+	result.Notes = []string{
+		"ICMP redirect from 192.168.1.1 suggests gateway 192.168.1.2 (1 probe); routing was not changed",
+		"switched to an IPv6 prober for IPv6 destination fd00::5",
+	}
+	result.Meta = &trace.Meta{Hostname: hostname, SourceIP: net.ParseIP("192.168.1.23"), Interface: "wlan0"}
+	result.AppProbe = &trace.AppProbe{Address: "10.0.0.1:443", Error: "connect: dial tcp 10.0.0.1:443: i/o timeout"}
+	trace.NewAnonymizer(trace.AnonymizeOptions{}).Result(result)
+
+	leaks := []string{"192.168.1.", "10.0.0.1", "fd00::5", "router.local", "wlan0", hostname}
+	for _, format := range []Format{FormatText, FormatVerbose, FormatJSON, FormatCSV, FormatHTML} {
+		data, err := NewFormatter(format, Config{}).Format(result)
+		if err != nil {
+			t.Fatalf("%v Format() error = %v", format, err)
+		}
+		out := string(data)
+		for _, leak := range leaks {
+			if strings.Contains(out, leak) {
+				t.Errorf("%v output contains %q", format, leak)
+			}
+		}
+		if !strings.Contains(out, "private-hop-2") {
+			t.Errorf("%v output missing placeholder:\n%s", format, out)
+		}
+		shows := format != FormatVerbose && format != FormatCSV
+		if shows && !strings.Contains(out, "private-hop-2:443") {
+			t.Errorf("%v output missing the application probe placeholder:\n%s", format, out)
+		}
+	}
+
+	text, _ := NewTextFormatter(Config{}).Format(result)
+	if !strings.Contains(string(text), "Anonymized:") {
+		t.Errorf("text output should note the anonymization:\n%s", text)
+	}
+
+	data, _ := NewJSONFormatter(Config{}).Format(result)
+	parsed, err := ParseJSONResult(data)
+	if err != nil {
+		t.Fatalf("ParseJSONResult() error = %v", err)
+	}
+	if parsed.Hops[1].Placeholder != "private-hop-2" || parsed.Hops[1].IP != nil || !parsed.Anonymized() {
+		t.Errorf("parsed hop 2 = %+v, notes %v", parsed.Hops[1], parsed.Notes)
+	}
+}
+
+func TestFormatters_SendErrors(t *testing.T) {
+	result := sampleTraceResult()
+	result.Hops[2].SendErrors = 3
+	result.Hops[2].SendError = "ENOBUFS"
+
+	text, err := NewTextFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if !strings.Contains(string(text), "  3  send failed (ENOBUFS)") {
+		t.Errorf("text output should report the send failure:\n%s", text)
+	}
+
+	data, err := NewJSONFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("JSON Format() error = %v", err)
+	}
+	parsed, err := ParseJSONResult(data)
+	if err != nil {
+		t.Fatalf("ParseJSONResult() error = %v", err)
+	}
+	if hop := parsed.Hops[2]; hop.SendErrors != 3 || hop.SendError != "ENOBUFS" {
+		t.Errorf("parsed hop 3 send errors = %d (%q)", hop.SendErrors, hop.SendError)
+	}
+}
+
+func TestFormatters_Outliers(t *testing.T) {
+	result := sampleTraceResult()
+	result.Hops[1].Outliers = 1
+	result.Meta = &trace.Meta{RTTOutlier: "drop"}
+
+	data, err := NewJSONFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("JSON Format() error = %v", err)
+	}
+	if !strings.Contains(string(data), `"rtt_outlier": "drop"`) {
+		t.Errorf("JSON output should record the outlier policy:\n%s", data)
+	}
+	parsed, err := ParseJSONResult(data)
+	if err != nil {
+		t.Fatalf("ParseJSONResult() error = %v", err)
+	}
+	if parsed.Hops[1].Outliers != 1 || parsed.Hops[0].Outliers != 0 {
+		t.Errorf("parsed outliers = %d/%d, want 0/1", parsed.Hops[0].Outliers, parsed.Hops[1].Outliers)
+	}
+
+	f := NewTableFormatter(Config{})
+	if err := f.SetColumns([]string{"hop", "outliers"}); err != nil {
+		t.Fatalf("SetColumns() error = %v", err)
+	}
+	table, err := f.Format(result)
+	if err != nil {
+		t.Fatalf("Table Format() error = %v", err)
+	}
+	if !strings.Contains(string(table), "│ 2   │ 1        │") || !strings.Contains(string(table), "│ 1   │ -        │") {
+		t.Errorf("table should count hop 2's outliers:\n%s", table)
+	}
+}
+
+func TestFormatters_WatchTotal(t *testing.T) {
+	result := sampleTraceResult()
+	result.Rounds = 3
+	hop := &result.Hops[1]
+	hop.RTTs = []float64{10, -1, 30}
+	hop.AvgRTT, hop.MinRTT, hop.MaxRTT, hop.LastRTT = 20, 10, 30, 30
+	hop.Sent, hop.Received, hop.StdDev, hop.LossPercent = 3, 2, 10, 100.0/3
+	hop.EWMA = 12.5
+
+	table, err := NewTableFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("Table Format() error = %v", err)
+	}
+	if !strings.Contains(string(table), "SNT") || !strings.Contains(string(table), "STDEV") ||
+		strings.Contains(string(table), "RTT SAMPLES") {
+		t.Errorf("table of a --watch total should show sent and stddev instead of the samples:\n%s", table)
+	}
+	if !strings.Contains(string(table), "│ 10.00 │") || !strings.Contains(string(table), "Rounds:        3") {
+		t.Errorf("table should show hop 2's stddev and the rounds:\n%s", table)
+	}
+
+	text, err := NewTextFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("Text Format() error = %v", err)
+	}
+	if !strings.Contains(string(text), "loss  33% of 3") || !strings.Contains(string(text), "Watched over 3 rounds") {
+		t.Errorf("text should summarize the rounds:\n%s", text)
+	}
+	if strings.Contains(string(text), "10.00 ms") {
+		t.Errorf("text should show only the last sample of a --watch hop:\n%s", text)
+	}
+
+	data, err := NewJSONFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("JSON Format() error = %v", err)
+	}
+	for _, want := range []string{`"rounds": 3`, `"sent": 3`, `"received": 2`, `"stddev_ms": 10`, `"ewma_ms": 12.5`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("JSON should contain %s, got:\n%s", want, data)
+		}
+	}
+	parsed, err := ParseJSONResult(data)
+	if err != nil {
+		t.Fatalf("ParseJSONResult() error = %v", err)
+	}
+	if p := parsed.Hops[1]; parsed.Rounds != 3 || p.Sent != 3 || p.Received != 2 || p.StdDev != 10 || p.EWMA != 12.5 {
+		t.Errorf("parsed rounds %d, hop 2 = %+v; want the totals kept", parsed.Rounds, p)
+	}
+
+	csvData, err := NewCSVFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("CSV Format() error = %v", err)
+	}
+	records, err := csv.NewReader(bytes.NewReader(csvData)).ReadAll()
+	if err != nil {
+		t.Fatalf("CSV parse error = %v", err)
+	}
+	header, row := records[0], records[2]
+	if got := strings.Join(header[len(header)-3:], ","); got != "sent,received,stddev_ms" {
+		t.Errorf("CSV header ends with %q, want the --watch counters", got)
+	}
+	if got := strings.Join(row[len(row)-3:], ","); got != "3,2,10.000" {
+		t.Errorf("CSV hop 2 ends with %q, want 3,2,10.000", got)
+	}
+}
+
+func TestJSONFormatter_PathChanges(t *testing.T) {
+	config := trace.DefaultConfig()
+	config.MaxHops = 3
+	config.ProbeCount = 1
+	config.EnableEnrichment = false
+
+	// Hop 2 answers from another router in round 3
+	prober := probetest.NewScriptedProber().
+		Hop(1, "192.168.1.1", 1, 1, 1).
+		Hop(2, "62.115.1.1", 10, 10).
+		Reply(2, 2, probetest.Reply{IP: "154.54.1.1", RTT: 20 * time.Millisecond}).
+		Hop(3, "203.0.113.5", 30, 30, 30)
+	tracer, err := trace.NewWithProber(config, prober)
+	if err != nil {
+		t.Fatalf("NewWithProber() error = %v", err)
+	}
+	defer tracer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var changed time.Time
+	config.OnRound = func(round trace.Round) {
+		if round.Number == 3 {
+			changed = round.Result.Timestamp
+			cancel()
+		}
+	}
+	total, err := tracer.TraceLoop(ctx, "203.0.113.5", time.Millisecond)
+	if err != nil {
+		t.Fatalf("TraceLoop() error = %v", err)
+	}
+
+	data, err := NewJSONFormatter(Config{}).Format(total)
+	if err != nil {
+		t.Fatalf("JSON Format() error = %v", err)
+	}
+	var doc struct {
+		PathChanges []JSONPathChange `json:"path_changes"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("JSON parse error = %v", err)
+	}
+	want := JSONPathChange{Time: changed.Format(time.RFC3339), Hop: 2, From: "62.115.1.1", To: "154.54.1.1"}
+	if len(doc.PathChanges) != 1 || doc.PathChanges[0] != want {
+		t.Fatalf("path_changes = %+v, want [%+v]", doc.PathChanges, want)
+	}
+
+	parsed, err := ParseJSONResult(data)
+	if err != nil {
+		t.Fatalf("ParseJSONResult() error = %v", err)
+	}
+	if len(parsed.PathChanges) != 1 || parsed.PathChanges[0].To != "154.54.1.1" ||
+		!parsed.PathChanges[0].Time.Equal(changed.Truncate(time.Second)) {
+		t.Errorf("parsed PathChanges = %+v, want the change kept", parsed.PathChanges)
+	}
+}
+
+func TestFormatters_HostnameMismatch(t *testing.T) {
+	result := sampleTraceResult()
+	unverified := false
+	result.Hops[0].HostnameVerified = &unverified
+
+	data, err := NewJSONFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("JSON Format() error = %v", err)
+	}
+	if strings.Count(string(data), `"hostname_verified"`) != 1 || !strings.Contains(string(data), `"hostname_verified": false`) {
+		t.Errorf("JSON output should flag hop 1's hostname only:\n%s", data)
+	}
+	parsed, err := ParseJSONResult(data)
+	if err != nil {
+		t.Fatalf("ParseJSONResult() error = %v", err)
+	}
+	if !parsed.Hops[0].HostnameMismatch() || parsed.Hops[1].HostnameVerified != nil {
+		t.Errorf("parsed HostnameVerified = %v/%v", parsed.Hops[0].HostnameVerified, parsed.Hops[1].HostnameVerified)
+	}
+
+	text, _ := NewTextFormatter(Config{}).Format(result)
+	if !strings.Contains(string(text), "router.local?") {
+		t.Errorf("text output should mark the hostname:\n%s", text)
+	}
+	table, _ := NewTableFormatter(Config{}).Format(result)
+	if !strings.Contains(string(table), "router.local?") {
+		t.Errorf("table output should mark the hostname:\n%s", table)
+	}
+	html, _ := NewHTMLFormatter(Config{}).Format(result)
+	if strings.Count(string(html), `class="unverified-marker"`) != 1 {
+		t.Errorf("HTML report should mark hop 1's hostname once")
+	}
+
+	if got := truncateHostname("very-long-name.example.net?", 12); got != "very-lon...?" {
+		t.Errorf("truncateHostname() = %q, want the mark kept", got)
+	}
+}
+
+func TestFormatters_ProbeStats(t *testing.T) {
+	result := sampleTraceResult()
+	result.ProbeStats = &probe.Stats{
+		Method:          "icmp",
+		Sent:            9,
+		Received:        6,
+		Timeouts:        3,
+		Retransmissions: 2,
+		Discarded:       map[string]uint64{probe.DiscardMismatch: 3, probe.DiscardMalformed: 1},
+	}
+
+	data, err := NewJSONFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("JSON Format() error = %v", err)
+	}
+	var parsed JSONOutput
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if parsed.Diagnostics == nil || parsed.Diagnostics.Probes == nil {
+		t.Fatal("JSON should include the diagnostics section")
+	}
+	if p := parsed.Diagnostics.Probes; p.Sent != 9 || p.Retransmissions != 2 || p.Discarded["mismatch"] != 3 {
+		t.Errorf("diagnostics.probes = %+v", p)
+	}
+	if parsed.Diagnostics.EnrichRetry != nil {
+		t.Errorf("diagnostics.enrich_retry = %+v without a retry", parsed.Diagnostics.EnrichRetry)
+	}
+
+	result.EnrichRetry = &trace.EnrichRetry{Retried: 3, Recovered: 2}
+	data, err = NewJSONFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("JSON Format() error = %v", err)
+	}
+	parsed = JSONOutput{}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if r := parsed.Diagnostics.EnrichRetry; r == nil || r.Retried != 3 || r.Recovered != 2 {
+		t.Errorf("diagnostics.enrich_retry = %+v, want 3 retried, 2 recovered", r)
+	}
+
+	block := FormatProbeStats(result.ProbeStats)
+	for _, want := range []string{
+		"Probe statistics (icmp):",
+		"Sent:             9",
+		"Discarded:        4 (malformed 1, mismatch 3)",
+		"Retransmissions:  2",
+	} {
+		if !strings.Contains(block, want) {
+			t.Errorf("stats block should contain %q, got:\n%s", want, block)
+		}
+	}
+
+	result.ProbeStats = nil
+	result.EnrichRetry = nil
+	data, _ = NewJSONFormatter(Config{}).Format(result)
+	if strings.Contains(string(data), "diagnostics") {
+		t.Error("diagnostics should be omitted without probe stats or retries")
+	}
+}
+
+func TestFormatters_Concurrency(t *testing.T) {
+	result := sampleTraceResult()
+	result.Mode = trace.ModeConcurrent
+	result.ProbeSocket = "raw"
+	result.Concurrency = 16
+
+	if got := formatProbeMethod(result); got != "ICMP (raw socket, concurrent x16)" {
+		t.Errorf("formatProbeMethod() = %q", got)
+	}
+
+	data, err := NewJSONFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("JSON Format() error = %v", err)
+	}
+	if !strings.Contains(string(data), `"concurrency": 16`) {
+		t.Error("JSON should include the effective concurrency")
+	}
+}
+
+func TestFormatters_PartialPath(t *testing.T) {
+	result := sampleTraceResult()
+	result.Completed = false
+	result.StoppedReason = trace.StopLastHop
+	result.Meta = &trace.Meta{FirstHop: 1, LastHop: 3}
+
+	if got := NewTextFormatter(Config{}).FormatSummary(result); !strings.Contains(got, "Partial trace of hops 1-3 complete") {
+		t.Errorf("text summary = %q", got)
+	}
+	if got := NewTextFormatter(Config{}).FormatPartialHeader("google.com", 5, 9); !strings.HasPrefix(got, "traceroute to google.com, hops 5-9 only") {
+		t.Errorf("partial header = %q", got)
+	}
+
+	table, err := NewTableFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("Table Format() error = %v", err)
+	}
+	for _, want := range []string{"Range: hops 1-3 (partial path", "Status:        Partial (hops 1-3)"} {
+		if !strings.Contains(string(table), want) {
+			t.Errorf("table output should contain %q", want)
+		}
+	}
+
+	data, err := NewJSONFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("JSON Format() error = %v", err)
+	}
+	if !strings.Contains(string(data), `"stopped_reason": "last_hop"`) || !strings.Contains(string(data), `"last_hop": 3`) {
+		t.Error("JSON should include stopped_reason and meta.last_hop")
+	}
+
+	result.StoppedReason = ""
+	if partialPathRange(result) != "" {
+		t.Error("a trace without a stop reason is not a partial path")
+	}
+}
+
+func TestFormatters_DestinationPort(t *testing.T) {
+	result := sampleTraceResult()
+	result.Meta = &trace.Meta{DestPort: 443}
+	last := &result.Hops[len(result.Hops)-1]
+	last.IP, last.Responded = net.ParseIP("142.250.185.238"), true
+	result.Hops[0].ResponseKind = probe.ResponseTTLExceeded
+
+	tests := []struct {
+		kind string
+		want string // "" = no port line
+	}{
+		{probe.ResponseSynAck, "Destination port 443: open (SYN-ACK)"},
+		{probe.ResponseRST, "Destination port 443: closed (RST)"},
+		{probe.ResponseEchoReply, ""},
+	}
+	for _, tt := range tests {
+		last.ResponseKind = tt.kind
+		got := NewTextFormatter(Config{}).FormatSummary(result)
+		if (tt.want == "" && strings.Contains(got, "Destination port")) || !strings.Contains(got, tt.want) {
+			t.Errorf("%s: text summary = %q, want %q", tt.kind, got, tt.want)
+		}
+	}
+
+	// JSON keeps the kind of every hop
+	last.ResponseKind = probe.ResponseSynAck
+	data, err := NewJSONFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("JSON Format() error = %v", err)
+	}
+	for _, want := range []string{`"response_kind": "icmp-ttl-exceeded"`, `"response_kind": "tcp-synack"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("JSON should contain %s, got:\n%s", want, data)
+		}
+	}
+	parsed, err := ParseJSONResult(data)
+	if err != nil {
+		t.Fatalf("ParseJSONResult() error = %v", err)
+	}
+	if got := parsed.Hops[len(parsed.Hops)-1].ResponseKind; got != probe.ResponseSynAck {
+		t.Errorf("parsed ResponseKind = %q, want %q", got, probe.ResponseSynAck)
+	}
+}
+
+func TestFormatters_DestinationCheck(t *testing.T) {
+	result := sampleTraceResult()
+	result.Summary.DestinationProbes = 10
+	result.Summary.DestinationLossPercent = 10
+	result.Summary.DestinationAvgRTT = 12.34
+
+	want := "10.0% loss, 12.34 ms avg (10 end-host probes)"
+	if got := NewTextFormatter(Config{}).FormatSummary(result); !strings.Contains(got, "Destination: "+want) {
+		t.Errorf("text summary = %q", got)
+	}
+
+	table, err := NewTableFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("Table Format() error = %v", err)
+	}
+	if !strings.Contains(string(table), "Destination:   "+want) {
+		t.Errorf("table output should contain the destination check, got:\n%s", table)
+	}
+
+	data, err := NewJSONFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("JSON Format() error = %v", err)
+	}
+	if !strings.Contains(string(data), `"destination": {`) || !strings.Contains(string(data), `"loss_percent": 10`) {
+		t.Errorf("JSON summary should include the destination check, got:\n%s", data)
+	}
+
+	html, err := NewHTMLFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("HTML Format() error = %v", err)
+	}
+	if !strings.Contains(string(html), "Destination Loss") {
+		t.Error("HTML summary should include the destination loss")
+	}
+
+	result.Summary.DestinationAvgRTT = 0
+	if got := formatDestinationCheck(result.Summary, numberFormat{}); !strings.Contains(got, "no replies") {
+		t.Errorf("formatDestinationCheck() = %q, want no replies", got)
+	}
+
+	result.Summary = trace.Summary{}
+	if got := NewTextFormatter(Config{}).FormatSummary(result); strings.Contains(got, "Destination:") {
+		t.Error("destination line should be omitted without --verify-dest")
+	}
+}
+
+func TestFormatters_LastRTT(t *testing.T) {
+	result := sampleTraceResult()
+	result.Hops[0].LastRTT = 1.5
+	result.Hops[1].LastRTT = -1
+
+	data, err := NewJSONFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("JSON Format() error = %v", err)
+	}
+	for _, want := range []string{`"last_rtt_ms": 1.5`, `"last_rtt_ms": -1`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("JSON should contain %s", want)
+		}
+	}
+
+	f := NewTableFormatter(Config{})
+	if err := f.SetColumns([]string{"hop", "last"}); err != nil {
+		t.Fatalf("SetColumns() error = %v", err)
+	}
+	rows := [][]string{f.formatHopRow(&result.Hops[0]), f.formatHopRow(&result.Hops[1]), f.formatHopRow(&result.Hops[2])}
+	for i, want := range []string{"1.50", "*", "-"} {
+		if rows[i][1] != want {
+			t.Errorf("hop %d last = %q, want %q", i+1, rows[i][1], want)
+		}
+	}
+}
+
+func TestFormatters_InterfaceInfo(t *testing.T) {
+	result := sampleTraceResult()
+
+	table, err := NewTableFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("Table Format() error = %v", err)
+	}
+	if strings.Contains(string(table), "INTERFACE") {
+		t.Error("interface column should be hidden when no hop reports one")
+	}
+
+	result.Hops[1].Interface = &trace.InterfaceInfo{Role: "incoming", Index: 517, Name: "xe-0/0/1", MTU: 9000}
+
+	f := NewTableFormatter(Config{})
+	table, err = f.Format(result)
+	if err != nil {
+		t.Fatalf("Table Format() error = %v", err)
+	}
+	if !strings.Contains(string(table), "INTERFACE") || !strings.Contains(string(table), "xe-0/0/1 mtu 9000") {
+		t.Errorf("table should show the interface column, got:\n%s", table)
+	}
+	if len(f.columns) != len(f.defaultColumns()) {
+		t.Error("Format should not change the formatter's own columns")
+	}
+
+	custom := NewTableFormatter(Config{Columns: []string{"hop", "ip"}})
+	table, _ = custom.Format(result)
+	if strings.Contains(string(table), "INTERFACE") {
+		t.Error("explicitly chosen columns should not gain the interface column")
+	}
+
+	data, err := NewJSONFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("JSON Format() error = %v", err)
+	}
+	for _, want := range []string{`"interface": {`, `"ifindex": 517`, `"name": "xe-0/0/1"`, `"mtu": 9000`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("JSON should contain %s", want)
+		}
+	}
+}
+
+func TestFormatters_AliasChain(t *testing.T) {
+	result := sampleTraceResult()
+	result.Aliases = []string{"prod", "prod-eu"}
+	chain := "prod → prod-eu → " + result.Target
+
+	for _, f := range []Formatter{NewTextFormatter(Config{}), NewTableFormatter(Config{})} {
+		data, err := f.Format(result)
+		if err != nil {
+			t.Fatalf("Format() error = %v", err)
+		}
+		if !strings.Contains(string(data), chain) {
+			t.Errorf("%T header should show %q:\n%s", f, chain, data)
+		}
+	}
+
+	data, err := NewJSONFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("JSON Format() error = %v", err)
+	}
+	var parsed JSONOutput
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("JSON parsing error: %v", err)
+	}
+	if len(parsed.Aliases) != 2 || parsed.Aliases[1] != "prod-eu" || parsed.Target != result.Target {
+		t.Errorf("JSON aliases/target = %v/%s", parsed.Aliases, parsed.Target)
+	}
+}
+
+func TestFormatters_LastTransitHop(t *testing.T) {
+	result := sampleTraceResult()
+	result.Summary.LastTransitHop = &trace.TransitHop{
+		Number: 2,
+		IP:     net.ParseIP("10.0.0.1"),
+		RTTMs:  12.3456,
+		ASN:    3356,
+		Org:    "LEVEL3",
+	}
+	want := "hop 2, 10.0.0.1 (AS3356 LEVEL3), 12.35 ms"
+
+	for _, f := range []Formatter{NewTextFormatter(Config{}), NewTableFormatter(Config{})} {
+		data, err := f.Format(result)
+		if err != nil {
+			t.Fatalf("Format() error = %v", err)
+		}
+		if !strings.Contains(string(data), want) {
+			t.Errorf("%T summary should contain %q:\n%s", f, want, data)
+		}
+	}
+
+	data, err := NewJSONFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("JSON Format() error = %v", err)
+	}
+	var parsed JSONOutput
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("JSON parsing error: %v", err)
+	}
+	transit := parsed.Summary.LastTransitHop
+	if transit == nil || transit.Hop != 2 || transit.IP != "10.0.0.1" || transit.RTT != 12.346 || transit.ASN != 3356 {
+		t.Errorf("JSON last_transit_hop = %+v", transit)
+	}
+
+	// Omitted without ASN data
+	result.Summary.LastTransitHop = nil
+	data, err = NewJSONFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("JSON Format() error = %v", err)
+	}
+	if strings.Contains(string(data), "last_transit_hop") {
+		t.Error("last_transit_hop should be omitted when unknown")
+	}
+}
+
+func TestFormatters_Tags(t *testing.T) {
+	result := sampleTraceResult()
+	result.Meta = &trace.Meta{Tags: map[string]string{"ticket": "NET-1234", "site": "fra1"}}
+
+	data, err := NewJSONFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("JSON Format() error = %v", err)
+	}
+	var parsed JSONOutput
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("JSON parsing error: %v", err)
+	}
+	if parsed.Meta == nil || parsed.Meta.Tags["site"] != "fra1" || parsed.Meta.Tags["ticket"] != "NET-1234" {
+		t.Errorf("JSON meta = %+v, want tags", parsed.Meta)
+	}
+
+	// CSV tag columns only when requested, sorted by key
+	data, _ = NewCSVFormatter(Config{}).Format(result)
+	if strings.Contains(string(data), "tag_") {
+		t.Error("CSV should not have tag columns unless requested")
+	}
+	data, err = NewCSVFormatter(Config{CSVTags: true}).Format(result)
+	if err != nil {
+		t.Fatalf("CSV Format() error = %v", err)
+	}
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatalf("CSV parsing error: %v", err)
+	}
+	header := rows[0]
+	if n := len(header); n < 2 || header[n-2] != "tag_site" || header[n-1] != "tag_ticket" {
+		t.Errorf("CSV header = %v, want tag_site and tag_ticket last", header)
+	}
+	for _, row := range rows[1:] {
+		if n := len(row); row[n-2] != "fra1" || row[n-1] != "NET-1234" {
+			t.Errorf("CSV row = %v, want tag values", row)
+		}
+	}
+	if len(defaultCSVColumns) != len(header)-2 {
+		t.Error("CSV tag columns should not modify the default column list")
+	}
+
+	html, err := NewHTMLFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("HTML Format() error = %v", err)
+	}
+	if !strings.Contains(string(html), `<span class="tag">site=fra1</span><span class="tag">ticket=NET-1234</span>`) {
+		t.Error("HTML header should show the tags as chips")
+	}
+}
+
+func TestFormatters_Redirects(t *testing.T) {
+	result := sampleTraceResult()
+	result.Redirects = []trace.Redirect{
+		{Router: net.ParseIP("192.168.1.1"), Gateway: net.ParseIP("192.168.1.254"), Count: 3},
+	}
+
+	text, err := NewTextFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	lines := strings.Split(string(text), "\n")
+	if len(lines) < 4 || lines[3] != "     ! ICMP redirect from 192.168.1.1: gateway 192.168.1.254 suggested" {
+		t.Errorf("redirect warning should follow the first hop:\n%s", text)
+	}
+	if summary := NewTextFormatter(Config{}).FormatSummary(result); !strings.Contains(summary, "ICMP redirect from 192.168.1.1 suggested gateway 192.168.1.254") {
+		t.Errorf("streamed summary %q should report the redirect", summary)
+	}
+
+	data, err := NewJSONFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	parsed, err := ParseJSONResult(data)
+	if err != nil {
+		t.Fatalf("ParseJSONResult() error = %v", err)
+	}
+	if len(parsed.Redirects) != 1 || !parsed.Redirects[0].Gateway.Equal(result.Redirects[0].Gateway) || parsed.Redirects[0].Count != 3 {
+		t.Errorf("JSON round trip Redirects = %+v", parsed.Redirects)
+	}
+
+	result.Redirects = nil
+	if summary := NewTextFormatter(Config{}).FormatSummary(result); strings.Contains(summary, "redirect") {
+		t.Errorf("summary without redirects mentions one: %q", summary)
+	}
+}
+
+func TestTextFormatter_SummaryPerHop(t *testing.T) {
+	result := tracetest.Result("example.com").
+		Resolved("93.184.216.34").
+		Hop(1, "192.168.1.1", 13.5, 14.0, 14.6, -1, 14.7).
+		Hop(2, "10.0.0.1", 20.0, 20.0, 20.0, 20.0, 20.0).
+		Hop(3, "10.0.0.2", -1, -1, -1, -1, -1).With(func(hop *trace.Hop) { hop.Responded = false }).
+		Build()
+
+	full, err := NewTextFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	lines := strings.Split(string(full), "\n")[2:5]
+
+	stream := NewTextFormatter(Config{})
+	for i := range result.Hops {
+		if got := stream.FormatHop(&result.Hops[i]); got != lines[i]+"\n" {
+			t.Errorf("FormatHop(%d) = %q, want the Format line %q", i+1, got, lines[i])
+		}
+	}
+
+	tests := []struct {
+		line string
+		want string
+	}{
+		{lines[0], "avg 14.2 ms ±1.2 loss 20%"}, // partial loss
+		{lines[1], "avg 20.0 ms ±0.0 loss 0%"},
+		{lines[2], "* * * loss 100%"}, // total loss
+	}
+	for _, tt := range tests {
+		if got := strings.Join(strings.Fields(tt.line), " "); !strings.HasSuffix(got, tt.want) {
+			t.Errorf("hop line %q should end with %q", tt.line, tt.want)
+		}
+	}
+
+	// Columns line up between hops
+	if a, b := strings.Index(lines[0], "loss"), strings.Index(lines[1], "loss"); a != b {
+		t.Errorf("loss columns at %d and %d:\n%s\n%s", a, b, lines[0], lines[1])
+	}
+
+	// Three probes show the summary only when asked for
+	short := sampleTraceResult()
+	plain, err := NewTextFormatter(Config{}).Format(short)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if strings.Contains(string(plain), "avg") || strings.Contains(string(plain), "loss") {
+		t.Errorf("3-probe output has a per-hop summary:\n%s", plain)
+	}
+	summed := NewTextFormatter(Config{SummaryPerHop: true}).FormatHop(&short.Hops[1])
+	if got := strings.Join(strings.Fields(summed), " "); !strings.HasSuffix(got, "avg 5.6 ms ±0.2 loss 33% [AS15169 Google LLC]") {
+		t.Errorf("SummaryPerHop hop line = %q", summed)
+	}
+}
+
+func TestFormatters_AppProbe(t *testing.T) {
+	result := sampleTraceResult()
+	result.AppProbe = &trace.AppProbe{
+		Address: "142.250.185.238:443", ServerName: "google.com",
+		SYNACKMs: 5.5, ConnectMs: 5.75, TLS: true, TLSHandshakeMs: 12.25, TLSVersion: "TLS 1.3",
+	}
+
+	full, err := NewTextFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	stream := NewTextFormatter(Config{}).FormatSummary(result)
+	for _, want := range []string{
+		"Application 142.250.185.238:443 (SNI google.com)",
+		"SYN → SYN-ACK        5.50 ms",
+		"connect              5.75 ms",
+		"TLS 1.3 handshake   12.25 ms",
+	} {
+		if !strings.Contains(string(full), want) || !strings.Contains(stream, want) {
+			t.Errorf("text output lacks %q:\n%s", want, full)
+		}
+	}
+	if a, s := strings.Index(string(full), "Application"), strings.Index(string(full), "Trace complete"); a < 0 || a > s {
+		t.Errorf("application section should follow the hops and precede the summary:\n%s", full)
+	}
+
+	data, err := NewJSONFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("JSON Format() error = %v", err)
+	}
+	parsed, err := ParseJSONResult(data)
+	if err != nil {
+		t.Fatalf("ParseJSONResult() error = %v", err)
+	}
+	if parsed.AppProbe == nil || *parsed.AppProbe != *result.AppProbe {
+		t.Errorf("JSON round trip AppProbe = %+v, want %+v", parsed.AppProbe, result.AppProbe)
+	}
+
+	html, err := NewHTMLFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("HTML Format() error = %v", err)
+	}
+	if !strings.Contains(string(html), "Application (142.250.185.238:443, SNI google.com)") ||
+		!strings.Contains(string(html), "TLS 1.3 handshake") {
+		t.Error("HTML report should include the application section")
+	}
+
+	// A failed handshake reports the steps reached and the error
+	result.AppProbe = &trace.AppProbe{Address: "142.250.185.238:443", ConnectMs: 5.75, TLS: true,
+		Error: "TLS handshake: EOF"}
+	text := NewTextFormatter(Config{}).FormatSummary(result)
+	if !strings.Contains(text, "failed: TLS handshake: EOF") || strings.Contains(text, "handshake  ") {
+		t.Errorf("failed application probe = %q", text)
+	}
+
+	result.AppProbe = nil
+	if got := NewTextFormatter(Config{}).FormatSummary(result); strings.Contains(got, "Application") {
+		t.Error("application section should be omitted without --app-probe")
+	}
+}
+
+// directTraceResult returns a completed trace whose destination answered
+// at hop 1.
+func directTraceResult(ip string) *trace.TraceResult {
+	return tracetest.Result(ip).
+		Resolved(ip).
+		Hop(1, ip, 0.312, 0.401, 0.307).Hostname("router.lan").
+		Completed(true).
+		Build()
+}
+
+func TestFormatters_DirectlyConnected(t *testing.T) {
+	const direct = "Destination is 1 hop away (directly connected), RTT 0.307/0.340/0.401 ms"
+	result := directTraceResult("192.168.1.1")
+
+	tests := []struct {
+		format  Format
+		with    []string
+		without []string // parts of the multi-hop layout left out
+	}{
+		{FormatText, []string{direct, "192.168.1.1", "router.lan"}, []string{"Trace complete"}},
+		{FormatVerbose, []string{direct, "192.168.1.1 (router.lan)", "Complete"}, []string{"HOSTNAME", "Total Hops", "Packet Loss"}},
+		{FormatHTML, []string{direct, "192.168.1.1", "router.lan", "Complete"}, []string{"<th>Hop</th>", "Total Hops"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format.String(), func(t *testing.T) {
+			data, err := NewFormatter(tt.format, Config{}).Format(result)
+			if err != nil {
+				t.Fatalf("Format() error = %v", err)
+			}
+			out := string(data)
+			for _, want := range tt.with {
+				if !strings.Contains(out, want) {
+					t.Errorf("output should contain %q, got:\n%s", want, out)
+				}
+			}
+			for _, unwanted := range tt.without {
+				if strings.Contains(out, unwanted) {
+					t.Errorf("output should not contain %q, got:\n%s", unwanted, out)
+				}
+			}
+		})
+	}
+
+	// JSON keeps its structure
+	data, err := NewJSONFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("JSON Format() error = %v", err)
+	}
+	parsed, err := ParseJSONResult(data)
+	if err != nil || len(parsed.Hops) != 1 || !parsed.Completed || parsed.Summary.TotalHops != 1 {
+		t.Errorf("JSON round trip = %+v, %v, want the single hop and summary", parsed, err)
+	}
+}
+
+func TestDirectHop(t *testing.T) {
+	if hop := DirectHop(directTraceResult("192.168.1.1")); hop == nil || hop.Number != 1 {
+		t.Errorf("DirectHop() = %v, want hop 1", hop)
+	}
+
+	incomplete := directTraceResult("192.168.1.1")
+	incomplete.Completed = false
+	if DirectHop(incomplete) != nil {
+		t.Error("DirectHop() of an incomplete trace should be nil")
+	}
+	if DirectHop(sampleTraceResult()) != nil {
+		t.Error("DirectHop() of a multi-hop trace should be nil")
+	}
+	partial := directTraceResult("192.168.1.1")
+	partial.Hops[0].Number = 3 // --first-hop 3
+	if DirectHop(partial) != nil {
+		t.Error("DirectHop() of a trace starting past hop 1 should be nil")
+	}
+
+	local := directTraceResult("127.0.0.1")
+	if got := (Config{Units: UnitsAuto}).FormatDirect(&local.Hops[0]); got != "Destination is the local host, RTT 307/340/401 µs" {
+		t.Errorf("FormatDirect() = %q", got)
+	}
+	lossy := directTraceResult("192.168.1.1")
+	lossy.Hops[0].LossPercent = 33.3
+	if got := (Config{Locale: "de-DE"}).FormatDirect(&lossy.Hops[0]); !strings.HasSuffix(got, "RTT 0,307/0,340/0,401 ms, 33,3% loss") {
+		t.Errorf("FormatDirect() = %q, want localized RTTs and the loss", got)
+	}
+}
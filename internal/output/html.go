@@ -2,33 +2,103 @@ package output
 
 import (
 	"bytes"
+	"embed"
 	"fmt"
 	"html/template"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/KilimcininKorOglu/poros/internal/trace"
 )
 
+// Built-in report templates. A report template is executed with
+// *HTMLData and may use the functions of htmlFuncs.
+//
+//go:embed templates/*.html.tmpl
+var templateFS embed.FS
+
+var (
+	reportTemplate = mustReadTemplate("templates/report.html.tmpl")
+	multiTemplate  = template.Must(template.New("multi").Parse(mustReadTemplate("templates/multi.html.tmpl")))
+)
+
+// mustReadTemplate returns a built-in template's source.
+func mustReadTemplate(name string) string {
+	data, err := templateFS.ReadFile(name)
+	if err != nil {
+		panic(err)
+	}
+	return string(data)
+}
+
 // HTMLFormatter formats trace results as an HTML report.
 type HTMLFormatter struct {
 	config   Config
 	template *template.Template
+	baseline *trace.TraceResult
 }
 
 // NewHTMLFormatter creates a new HTML formatter.
 func NewHTMLFormatter(config Config) *HTMLFormatter {
-	tmpl := template.Must(template.New("report").Funcs(template.FuncMap{
-		"formatRTT": formatRTTHTML,
-		"rttClass":  rttClass,
-		"formatTime": func(t time.Time) string {
-			return t.Format("2006-01-02 15:04:05 MST")
-		},
-	}).Parse(htmlTemplate))
-
 	return &HTMLFormatter{
 		config:   config,
-		template: tmpl,
+		template: template.Must(parseReportTemplate("report", reportTemplate, config)),
+	}
+}
+
+// NewHTMLFormatterFromFile creates an HTML formatter that renders the
+// report with the template in path instead of the built-in one, e.g. to
+// add a logo or columns. The template is executed with *HTMLData and may
+// use the functions formatRTT, rttClass and formatTime.
+//
+// The template is checked before it is returned by rendering sample
+// traces with and without metadata and a baseline, so mistakes such as
+// unknown fields fail before a trace is run.
+func NewHTMLFormatterFromFile(config Config, path string) (*HTMLFormatter, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HTML template: %w", err)
+	}
+	tmpl, err := parseReportTemplate(filepath.Base(path), string(source), config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid HTML template: %w", err)
+	}
+
+	f := &HTMLFormatter{config: config, template: tmpl}
+	if err := f.check(); err != nil {
+		return nil, fmt.Errorf("invalid HTML template %s: %w", path, err)
 	}
+	return f, nil
+}
+
+// parseReportTemplate parses a report template with the report functions.
+func parseReportTemplate(name, source string, config Config) (*template.Template, error) {
+	return template.New(name).Funcs(htmlFuncs(config)).Parse(source)
+}
+
+// htmlFuncs returns the functions available to report templates.
+func htmlFuncs(config Config) template.FuncMap {
+	return template.FuncMap{
+		"formatRTT": func(rtt float64) string {
+			return formatRTTHTML(rtt, config.numbers(nil))
+		},
+		"rttClass": func(rtt float64) string {
+			return rttClass(rtt, config)
+		},
+		"formatTime": func(t time.Time) string {
+			return config.FormatTime(t, "2006-01-02 15:04:05 MST")
+		},
+	}
+}
+
+// SetBaseline sets an earlier result of the same trace to compare against.
+// The report then shows per-hop RTT and loss deltas and highlights hops
+// whose address changed; nil removes the comparison.
+func (f *HTMLFormatter) SetBaseline(baseline *trace.TraceResult) {
+	f.baseline = baseline
 }
 
 // Format formats the trace result as an HTML report.
@@ -43,79 +113,229 @@ func (f *HTMLFormatter) Format(result *trace.TraceResult) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-// htmlData holds the data for the HTML template.
-type htmlData struct {
-	Title       string
-	Target      string
-	ResolvedIP  string
-	Timestamp   time.Time
-	ProbeMethod string
-	Completed   bool
-	Hops        []htmlHop
-	Summary     htmlSummary
+// check renders a complete and a bare sample trace, the first compared
+// against itself as a baseline, and discards the output.
+func (f *HTMLFormatter) check() error {
+	full, bare, direct := sampleReports()
+
+	checker := *f
+	checker.baseline = full
+	for _, result := range []*trace.TraceResult{full, bare, direct} {
+		if _, err := checker.Format(result); err != nil {
+			return err
+		}
+		checker.baseline = nil
+	}
+	return nil
+}
+
+// sampleReports returns the traces templates are checked with: one with
+// every optional part filled in, an incomplete one with none, and one
+// whose destination is the first hop.
+func sampleReports() (full, bare, direct *trace.TraceResult) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	router := trace.Hop{Number: 1, IP: net.IPv4(192, 0, 2, 1), Hostname: "router.example",
+		RTTs: []float64{1, 1.2, 1.1}, Responded: true}
+	router.UpdateStats()
+	dest := trace.Hop{Number: 2, IP: net.IPv4(198, 51, 100, 7), Responded: true,
+		RTTs: []float64{10, -1, 12},
+		ASN:  &trace.ASNInfo{Number: 64500, Org: "EXAMPLE"},
+		Geo: &trace.GeoInfo{Country: "Germany", CountryCode: "DE", City: "Frankfurt am Main",
+			Region: "Hesse", RegionCode: "HE", ISP: "Example ISP"}}
+	dest.UpdateStats()
+	silent := trace.Hop{Number: 2, RTTs: []float64{-1, -1, -1}}
+	silent.UpdateStats()
+
+	full = &trace.TraceResult{
+		Target: "example.com", ResolvedIP: dest.IP, TargetPTR: "www.example.com",
+		Timestamp: ts, ProbeMethod: "icmp", Completed: true,
+		Notes: []string{"sample note"},
+		Hops:  []trace.Hop{router, dest},
+		Meta: &trace.Meta{Version: "dev", Hostname: "host", OS: "linux", Arch: "amd64",
+			SourceIP: router.IP, Interface: "eth0",
+			Enrichment: map[string]string{"rdns": "online"}, Tags: map[string]string{"site": "lab"}},
+	}
+	full.Summary = trace.Summarize(full.Hops)
+	full.Summary.DestinationProbes = 3
+	full.Summary.PerAS = []trace.ASContribution{{ASN: 64500, Org: "EXAMPLE", DeltaMs: 9}}
+	full.AppProbe = &trace.AppProbe{Address: "198.51.100.7:443", ServerName: "example.com",
+		SYNACKMs: 11, ConnectMs: 11.5, TLS: true, TLSHandshakeMs: 23, TLSVersion: "TLS 1.3"}
+
+	bare = &trace.TraceResult{
+		Target: "192.0.2.99", Timestamp: ts, ProbeMethod: "udp",
+		Hops: []trace.Hop{router, silent},
+	}
+	bare.Summary = trace.Summarize(bare.Hops)
+
+	direct = &trace.TraceResult{
+		Target: "192.0.2.1", ResolvedIP: router.IP, Timestamp: ts, ProbeMethod: "icmp",
+		Completed: true, Hops: []trace.Hop{router},
+	}
+	direct.Summary = trace.Summarize(direct.Hops)
+	return full, bare, direct
+}
+
+// HTMLData is the data an HTML report template is executed with. Its
+// fields, and those of the types it refers to, are a stable interface for
+// --html-template files. RTTs are preformatted in Unit with the configured
+// decimal separator.
+type HTMLData struct {
+	Title       string    // "Traceroute to <target>"
+	Target      string    // the traced host as given
+	ResolvedIP  string    // "" if anonymized
+	TargetPTR   string    // PTR name of the destination, if any
+	Translated  string    // NAT64 address the target was reached via, if any
+	Notes       []string  // warnings about the trace
+	Timestamp   time.Time // when the trace started
+	ProbeMethod string    // e.g. "ICMP" or "Paris (UDP, flow 0x1234)"
+	Completed   bool      // the destination was reached
+
+	// Direct describes a destination reached at hop 1, e.g. "Destination
+	// is 1 hop away (directly connected), RTT 0.312/0.340/0.401 ms", shown
+	// instead of the hop table and summary ("" for other traces)
+	Direct string
+
+	Hops        []HTMLHop
+	Summary     HTMLSummary
+	AppProbe    *HTMLAppProbe // nil without --app-probe
+	Meta        *HTMLMeta     // nil without run metadata
+	Baseline    *HTMLBaseline // nil without --baseline
 	GeneratedAt time.Time
+
+	// Unit is the RTT unit label, "ms" or "µs"
+	Unit string
+}
+
+// HTMLAppProbe describes the connection made to the destination after a
+// TCP trace.
+type HTMLAppProbe struct {
+	Address    string // "203.0.113.9:443"
+	ServerName string // TLS SNI, if sent
+	Steps      []HTMLAppStep
+	Error      string // the step that failed, if any
+}
+
+// HTMLAppStep is a timed step of the application probe.
+type HTMLAppStep struct {
+	Name string // e.g. "Connect" or "TLS 1.3 handshake"
+	Time string // with the unit
+}
+
+// HTMLBaseline describes the baseline trace a report is compared against.
+type HTMLBaseline struct {
+	Timestamp time.Time
+	TotalHops int
+
+	// Hops present only in the baseline, e.g. "9, 10"
+	OnlyHops string
+}
+
+// HTMLMeta holds run metadata for HTML.
+type HTMLMeta struct {
+	Version    string   // Poros version
+	Host       string   // host name, OS and architecture
+	Source     string   // source address and interface
+	Enrichment string   // enrichment sources, e.g. "rdns: online, asn: offline"
+	Tags       []string // "key=value", sorted by key
 }
 
-// htmlHop represents a hop for HTML rendering.
-type htmlHop struct {
+// HTMLHop represents a hop for HTML rendering. Unresponsive hops have
+// "*" as IP and RTTs, and empty enrichment fields.
+type HTMLHop struct {
 	Number      int
-	IP          string
+	IP          string // address, or a placeholder if anonymized
 	Hostname    string
-	ASN         string
+	Unverified  bool   // Hostname does not resolve back to IP
+	ASN         string // e.g. "AS15169"
 	Org         string
-	Country     string
+	Country     string // ISO country code
 	City        string
+	Location    string // city, region and full country name, e.g. "Ashburn, Virginia, United States"
+	ISP         string
+	GeoTags     string // e.g. "[host][proxy]"
+	Samples     string // every RTT sample, e.g. "1.2 / * / 1.3"
 	AvgRTT      string
 	MinRTT      string
 	MaxRTT      string
 	Jitter      string
-	LossPercent string
+	LossPercent string // e.g. "33%"
 	Responded   bool
-	RTTClass    string
+	RTTClass    string // good, medium, bad or timeout
+	LossClass   string // loss-ok, loss-warn, loss-crit or loss-timeout
+	RowClass    string // CSS classes of the table row
+
+	// Comparison with the baseline (empty without one)
+	DeltaRTT       string
+	DeltaRTTClass  string
+	DeltaLoss      string
+	DeltaLossClass string
+	BaselineIP     string // set when the hop's address changed
 }
 
-// htmlSummary holds summary data for HTML.
-type htmlSummary struct {
+// HTMLSummary holds summary data for HTML.
+type HTMLSummary struct {
 	TotalHops   int
 	Responding  int
-	TotalTime   string
+	TotalTime   string // RTT to the last hop with the unit
 	PacketLoss  string
-	Status      string
-	StatusClass string
+	Status      string // "Complete", "Incomplete" or "Partial (5-9)"
+	StatusClass string // success or warning
+	PerAS       []HTMLASContribution
+
+	// End-host probes sent with --verify-dest (DestProbes 0 = not run)
+	DestProbes int
+	DestLoss   string
+	DestRTT    string
+}
+
+// HTMLASContribution holds per-AS latency for HTML.
+type HTMLASContribution struct {
+	ASN   string // e.g. "AS15169"
+	Org   string
+	Delta string // latency added inside the AS, with the unit
 }
 
 // prepareData converts TraceResult to template data.
-func (f *HTMLFormatter) prepareData(result *trace.TraceResult) *htmlData {
-	data := &htmlData{
+func (f *HTMLFormatter) prepareData(result *trace.TraceResult) *HTMLData {
+	data := &HTMLData{
 		Title:       fmt.Sprintf("Traceroute to %s", result.Target),
 		Target:      result.Target,
-		ResolvedIP:  result.ResolvedIP.String(),
+		ResolvedIP:  ipString(result.ResolvedIP),
+		TargetPTR:   result.TargetPTR,
+		Translated:  result.TranslatedVia,
+		ProbeMethod: formatProbeMethod(result),
+		Notes:       result.Notes,
 		Timestamp:   result.Timestamp,
-		ProbeMethod: result.ProbeMethod,
 		Completed:   result.Completed,
-		Hops:        make([]htmlHop, len(result.Hops)),
+		Hops:        make([]HTMLHop, len(result.Hops)),
 		GeneratedAt: time.Now(),
 	}
+	n := f.config.numbers(result.Hops)
+	data.Unit = n.unit()
+	if hop := DirectHop(result); hop != nil {
+		data.Direct = formatDirect(hop, n)
+	}
 
 	responding := 0
 	for i, hop := range result.Hops {
-		h := htmlHop{
+		h := HTMLHop{
 			Number:    hop.Number,
 			Responded: hop.Responded,
 		}
 
 		if hop.Responded {
 			responding++
-			if hop.IP != nil {
-				h.IP = hop.IP.String()
-			}
+			h.IP = hop.Address()
 			h.Hostname = hop.Hostname
-			h.AvgRTT = formatRTTHTML(hop.AvgRTT)
-			h.MinRTT = formatRTTHTML(hop.MinRTT)
-			h.MaxRTT = formatRTTHTML(hop.MaxRTT)
-			h.Jitter = formatRTTHTML(hop.Jitter)
-			h.LossPercent = fmt.Sprintf("%.0f%%", hop.LossPercent)
-			h.RTTClass = rttClass(hop.AvgRTT)
+			h.Unverified = hop.HostnameMismatch()
+			h.AvgRTT = formatRTTHTML(hop.AvgRTT, n)
+			h.MinRTT = formatRTTHTML(hop.MinRTT, n)
+			h.MaxRTT = formatRTTHTML(hop.MaxRTT, n)
+			h.Jitter = formatRTTHTML(hop.Jitter, n)
+			h.LossPercent = n.percent(hop.LossPercent, 0)
+			h.Samples = formatRTTSamples(hop.RTTs, n)
+			h.RTTClass = rttClass(hop.AvgRTT, f.config)
+			h.LossClass = lossClass(hop.LossPercent, f.config)
 
 			if hop.ASN != nil {
 				h.ASN = fmt.Sprintf("AS%d", hop.ASN.Number)
@@ -125,6 +345,9 @@ func (f *HTMLFormatter) prepareData(result *trace.TraceResult) *htmlData {
 			if hop.Geo != nil {
 				h.Country = hop.Geo.CountryCode
 				h.City = hop.Geo.City
+				h.Location = formatLocation(hop.Geo, true, true)
+				h.ISP = hop.Geo.ISP
+				h.GeoTags = geoTags(hop.Geo)
 			}
 		} else {
 			h.IP = "*"
@@ -133,17 +356,50 @@ func (f *HTMLFormatter) prepareData(result *trace.TraceResult) *htmlData {
 			h.MaxRTT = "*"
 			h.LossPercent = "100%"
 			h.RTTClass = "timeout"
+			h.LossClass = "loss-timeout"
 		}
 
+		if h.LossClass == "loss-crit" {
+			h.RowClass = "loss-crit"
+		}
 		data.Hops[i] = h
 	}
 
+	if f.baseline != nil {
+		f.compareBaseline(data, result, n)
+	}
+
 	// Summary
-	data.Summary = htmlSummary{
+	data.Summary = HTMLSummary{
 		TotalHops:  result.Summary.TotalHops,
 		Responding: responding,
-		TotalTime:  fmt.Sprintf("%.2f ms", result.Summary.TotalTimeMs),
-		PacketLoss: fmt.Sprintf("%.1f%%", result.Summary.PacketLossPercent),
+		TotalTime:  n.rtt(result.Summary.TotalTimeMs),
+		PacketLoss: n.percent(result.Summary.PacketLossPercent, 1),
+	}
+
+	if probes := result.Summary.DestinationProbes; probes > 0 {
+		data.Summary.DestProbes = probes
+		data.Summary.DestLoss = n.percent(result.Summary.DestinationLossPercent, 1)
+		data.Summary.DestRTT = "-"
+		if result.Summary.DestinationAvgRTT > 0 {
+			data.Summary.DestRTT = n.rtt(result.Summary.DestinationAvgRTT)
+		}
+	}
+
+	for _, c := range result.Summary.PerAS {
+		data.Summary.PerAS = append(data.Summary.PerAS, HTMLASContribution{
+			ASN:   fmt.Sprintf("AS%d", c.ASN),
+			Org:   c.Org,
+			Delta: formatASDelta(c, n),
+		})
+	}
+
+	if result.Meta != nil {
+		data.Meta = prepareMeta(result.Meta)
+	}
+
+	if result.AppProbe != nil {
+		data.AppProbe = prepareAppProbe(result.AppProbe, n)
 	}
 
 	if result.Completed {
@@ -152,31 +408,151 @@ func (f *HTMLFormatter) prepareData(result *trace.TraceResult) *htmlData {
 	} else {
 		data.Summary.Status = "Incomplete"
 		data.Summary.StatusClass = "warning"
+		if hops := partialPathRange(result); hops != "" {
+			data.Summary.Status = "Partial (" + hops + ")"
+		}
 	}
 
 	return data
 }
 
+// prepareAppProbe converts the application probe to template data.
+func prepareAppProbe(ap *trace.AppProbe, n numberFormat) *HTMLAppProbe {
+	h := &HTMLAppProbe{Address: ap.Address, ServerName: ap.ServerName, Error: ap.Error}
+	step := func(name string, ms float64) {
+		if ms > 0 {
+			h.Steps = append(h.Steps, HTMLAppStep{Name: name, Time: n.rtt(ms)})
+		}
+	}
+	step("SYN → SYN-ACK", ap.SYNACKMs)
+	step("Connect", ap.ConnectMs)
+	step(ap.TLSVersion+" handshake", ap.TLSHandshakeMs)
+	return h
+}
+
+// compareBaseline adds the baseline deltas to the prepared hops. Hops are
+// matched by number, so traces of different lengths compare the hops they
+// share.
+func (f *HTMLFormatter) compareBaseline(data *HTMLData, result *trace.TraceResult, n numberFormat) {
+	data.Baseline = &HTMLBaseline{
+		Timestamp: f.baseline.Timestamp,
+		TotalHops: len(f.baseline.Hops),
+	}
+
+	index := make(map[int]int, len(result.Hops))
+	for i, hop := range result.Hops {
+		index[hop.Number] = i
+	}
+
+	var only []string
+	for _, d := range trace.DiffHops(f.baseline, result) {
+		if d.Current == nil {
+			only = append(only, fmt.Sprint(d.Number))
+			continue
+		}
+
+		h := &data.Hops[index[d.Number]]
+		switch {
+		case d.Baseline == nil:
+			h.DeltaRTT, h.DeltaLoss = "new", "new"
+			h.DeltaRTTClass, h.DeltaLossClass = "neutral", "neutral"
+		case !d.Compared():
+			h.DeltaRTT, h.DeltaLoss = "-", "-"
+			h.DeltaRTTClass, h.DeltaLossClass = "neutral", "neutral"
+		default:
+			h.DeltaRTT = n.rttDelta(d.AvgRTTDelta)
+			h.DeltaRTTClass = deltaClass(d.AvgRTTDelta, 0.005)
+			h.DeltaLoss = n.signed(d.LossDelta, 0) + "%"
+			h.DeltaLossClass = deltaClass(d.LossDelta, 0.5)
+		}
+
+		if d.IPChanged {
+			h.BaselineIP = d.Baseline.IP.String()
+			h.RowClass = strings.TrimSpace(h.RowClass + " ip-changed")
+		}
+	}
+	data.Baseline.OnlyHops = strings.Join(only, ", ")
+}
+
+// deltaClass returns the CSS class for a change against the baseline;
+// changes smaller than epsilon are shown as unchanged.
+func deltaClass(delta, epsilon float64) string {
+	switch {
+	case delta >= epsilon:
+		return "worse"
+	case delta <= -epsilon:
+		return "better"
+	default:
+		return "neutral"
+	}
+}
+
+// prepareMeta converts run metadata to template data.
+func prepareMeta(meta *trace.Meta) *HTMLMeta {
+	m := &HTMLMeta{
+		Version: meta.Version,
+		Host:    meta.Hostname,
+	}
+
+	if meta.OS != "" {
+		m.Host = strings.TrimSpace(fmt.Sprintf("%s %s/%s", meta.Hostname, meta.OS, meta.Arch))
+	}
+
+	if meta.SourceIP != nil {
+		m.Source = meta.SourceIP.String()
+		if meta.Interface != "" {
+			m.Source += fmt.Sprintf(" (%s)", meta.Interface)
+		}
+	}
+
+	if len(meta.Enrichment) > 0 {
+		providers := make([]string, 0, len(meta.Enrichment))
+		for _, name := range []string{"rdns", "asn", "geoip"} {
+			if mode, ok := meta.Enrichment[name]; ok {
+				providers = append(providers, fmt.Sprintf("%s: %s", name, mode))
+			}
+		}
+		m.Enrichment = strings.Join(providers, ", ")
+	}
+
+	for _, key := range trace.TagKeys(meta.Tags) {
+		m.Tags = append(m.Tags, key+"="+meta.Tags[key])
+	}
+
+	return m
+}
+
 // formatRTTHTML formats RTT for HTML display.
-func formatRTTHTML(rtt float64) string {
+func formatRTTHTML(rtt float64, n numberFormat) string {
 	if rtt <= 0 {
 		return "-"
 	}
-	return fmt.Sprintf("%.2f", rtt)
+	return n.rttValue(rtt, 2)
 }
 
 // rttClass returns CSS class based on RTT value.
-func rttClass(rtt float64) string {
-	if rtt <= 0 {
-		return "neutral"
-	}
-	switch {
-	case rtt < 50:
+func rttClass(rtt float64, config Config) string {
+	switch config.ClassifyRTT(rtt) {
+	case LevelNormal:
 		return "good"
-	case rtt < 150:
+	case LevelWarn:
 		return "medium"
-	default:
+	case LevelCrit:
 		return "bad"
+	default:
+		return "neutral"
+	}
+}
+
+// lossClass returns CSS class based on loss percentage.
+func lossClass(loss float64, config Config) string {
+	switch config.ClassifyLoss(loss) {
+	case LevelWarn:
+		return "loss-warn"
+	case LevelCrit:
+		return "loss-crit"
+	default:
+		return "loss-ok"
 	}
 }
 
@@ -189,295 +565,3 @@ func (f *HTMLFormatter) ContentType() string {
 func (f *HTMLFormatter) FileExtension() string {
 	return "html"
 }
-
-// HTML template
-const htmlTemplate = `<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>{{.Title}} - Poros Report</title>
-    <style>
-        :root {
-            --bg-primary: #1a1b26;
-            --bg-secondary: #24283b;
-            --bg-tertiary: #414868;
-            --text-primary: #c0caf5;
-            --text-secondary: #a9b1d6;
-            --text-muted: #565f89;
-            --accent: #7aa2f7;
-            --success: #9ece6a;
-            --warning: #e0af68;
-            --error: #f7768e;
-            --border: #3b4261;
-        }
-
-        * {
-            margin: 0;
-            padding: 0;
-            box-sizing: border-box;
-        }
-
-        body {
-            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, 'Helvetica Neue', Arial, sans-serif;
-            background: var(--bg-primary);
-            color: var(--text-primary);
-            line-height: 1.6;
-            padding: 2rem;
-        }
-
-        .container {
-            max-width: 1200px;
-            margin: 0 auto;
-        }
-
-        header {
-            text-align: center;
-            margin-bottom: 2rem;
-            padding-bottom: 1rem;
-            border-bottom: 1px solid var(--border);
-        }
-
-        h1 {
-            color: var(--accent);
-            font-size: 2rem;
-            margin-bottom: 0.5rem;
-        }
-
-        .subtitle {
-            color: var(--text-muted);
-            font-size: 0.9rem;
-        }
-
-        .info-grid {
-            display: grid;
-            grid-template-columns: repeat(auto-fit, minmax(200px, 1fr));
-            gap: 1rem;
-            margin-bottom: 2rem;
-        }
-
-        .info-card {
-            background: var(--bg-secondary);
-            padding: 1rem;
-            border-radius: 8px;
-            border: 1px solid var(--border);
-        }
-
-        .info-card label {
-            color: var(--text-muted);
-            font-size: 0.8rem;
-            text-transform: uppercase;
-            letter-spacing: 0.05em;
-        }
-
-        .info-card value {
-            display: block;
-            color: var(--text-primary);
-            font-size: 1.1rem;
-            font-weight: 500;
-            margin-top: 0.25rem;
-        }
-
-        table {
-            width: 100%;
-            border-collapse: collapse;
-            background: var(--bg-secondary);
-            border-radius: 8px;
-            overflow: hidden;
-            margin-bottom: 2rem;
-        }
-
-        th, td {
-            padding: 0.75rem 1rem;
-            text-align: left;
-            border-bottom: 1px solid var(--border);
-        }
-
-        th {
-            background: var(--bg-tertiary);
-            color: var(--text-secondary);
-            font-weight: 600;
-            font-size: 0.85rem;
-            text-transform: uppercase;
-            letter-spacing: 0.05em;
-        }
-
-        tr:last-child td {
-            border-bottom: none;
-        }
-
-        tr:hover {
-            background: var(--bg-tertiary);
-        }
-
-        .hop-num {
-            color: var(--accent);
-            font-weight: 600;
-        }
-
-        .ip {
-            font-family: 'Monaco', 'Menlo', monospace;
-            color: var(--text-primary);
-        }
-
-        .hostname {
-            color: var(--success);
-        }
-
-        .asn {
-            color: var(--warning);
-            font-size: 0.85rem;
-        }
-
-        .geo {
-            color: var(--text-muted);
-            font-size: 0.85rem;
-        }
-
-        .rtt {
-            font-family: 'Monaco', 'Menlo', monospace;
-        }
-
-        .rtt.good { color: var(--success); }
-        .rtt.medium { color: var(--warning); }
-        .rtt.bad { color: var(--error); }
-        .rtt.timeout { color: var(--error); }
-        .rtt.neutral { color: var(--text-muted); }
-
-        .loss {
-            font-size: 0.85rem;
-        }
-
-        .summary {
-            display: grid;
-            grid-template-columns: repeat(auto-fit, minmax(150px, 1fr));
-            gap: 1rem;
-            background: var(--bg-secondary);
-            padding: 1.5rem;
-            border-radius: 8px;
-            border: 1px solid var(--border);
-        }
-
-        .summary-item {
-            text-align: center;
-        }
-
-        .summary-item .value {
-            font-size: 1.5rem;
-            font-weight: 600;
-            color: var(--accent);
-        }
-
-        .summary-item .label {
-            color: var(--text-muted);
-            font-size: 0.8rem;
-            text-transform: uppercase;
-        }
-
-        .status.success { color: var(--success); }
-        .status.warning { color: var(--warning); }
-
-        footer {
-            text-align: center;
-            margin-top: 2rem;
-            padding-top: 1rem;
-            border-top: 1px solid var(--border);
-            color: var(--text-muted);
-            font-size: 0.8rem;
-        }
-
-        @media (max-width: 768px) {
-            body { padding: 1rem; }
-            h1 { font-size: 1.5rem; }
-            th, td { padding: 0.5rem; font-size: 0.85rem; }
-        }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <header>
-            <h1>🔍 {{.Title}}</h1>
-            <p class="subtitle">Generated by Poros Network Path Tracer</p>
-        </header>
-
-        <div class="info-grid">
-            <div class="info-card">
-                <label>Target</label>
-                <value>{{.Target}}</value>
-            </div>
-            <div class="info-card">
-                <label>Resolved IP</label>
-                <value>{{.ResolvedIP}}</value>
-            </div>
-            <div class="info-card">
-                <label>Probe Method</label>
-                <value>{{.ProbeMethod | html}}</value>
-            </div>
-            <div class="info-card">
-                <label>Timestamp</label>
-                <value>{{formatTime .Timestamp}}</value>
-            </div>
-        </div>
-
-        <table>
-            <thead>
-                <tr>
-                    <th>Hop</th>
-                    <th>IP Address</th>
-                    <th>Hostname</th>
-                    <th>ASN</th>
-                    <th>Location</th>
-                    <th>Avg RTT</th>
-                    <th>Min</th>
-                    <th>Max</th>
-                    <th>Loss</th>
-                </tr>
-            </thead>
-            <tbody>
-                {{range .Hops}}
-                <tr>
-                    <td class="hop-num">{{.Number}}</td>
-                    <td class="ip">{{.IP}}</td>
-                    <td class="hostname">{{if .Hostname}}{{.Hostname}}{{else}}-{{end}}</td>
-                    <td class="asn">{{if .ASN}}{{.ASN}}<br><small>{{.Org}}</small>{{else}}-{{end}}</td>
-                    <td class="geo">{{if .City}}{{.City}}, {{end}}{{if .Country}}{{.Country}}{{else}}-{{end}}</td>
-                    <td class="rtt {{.RTTClass}}">{{.AvgRTT}}{{if .Responded}} ms{{end}}</td>
-                    <td class="rtt neutral">{{.MinRTT}}</td>
-                    <td class="rtt neutral">{{.MaxRTT}}</td>
-                    <td class="loss">{{.LossPercent}}</td>
-                </tr>
-                {{end}}
-            </tbody>
-        </table>
-
-        <div class="summary">
-            <div class="summary-item">
-                <div class="value">{{.Summary.TotalHops}}</div>
-                <div class="label">Total Hops</div>
-            </div>
-            <div class="summary-item">
-                <div class="value">{{.Summary.Responding}}</div>
-                <div class="label">Responding</div>
-            </div>
-            <div class="summary-item">
-                <div class="value">{{.Summary.TotalTime}}</div>
-                <div class="label">Total Time</div>
-            </div>
-            <div class="summary-item">
-                <div class="value">{{.Summary.PacketLoss}}</div>
-                <div class="label">Packet Loss</div>
-            </div>
-            <div class="summary-item">
-                <div class="value status {{.Summary.StatusClass}}">{{.Summary.Status}}</div>
-                <div class="label">Status</div>
-            </div>
-        </div>
-
-        <footer>
-            <p>Generated by <strong>Poros</strong> on {{formatTime .GeneratedAt}}</p>
-            <p>https://github.com/KilimcininKorOglu/poros</p>
-        </footer>
-    </div>
-</body>
-</html>
-`
@@ -2,6 +2,11 @@ package output
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"time"
 
 	"github.com/KilimcininKorOglu/poros/internal/trace"
 )
@@ -44,38 +49,217 @@ func (f *JSONFormatter) Format(result *trace.TraceResult) ([]byte, error) {
 	return json.Marshal(output)
 }
 
+// JSONSchemaVersion is the version of the JSON document layout, reported
+// in both results and error documents.
+const JSONSchemaVersion = 2
+
+// JSONErrorDocument is written instead of a result when a run fails, so
+// that automation always receives a parseable document.
+type JSONErrorDocument struct {
+	Error         JSONError `json:"error"`
+	SchemaVersion int       `json:"schema_version"`
+}
+
+// JSONError describes a failed run.
+type JSONError struct {
+	Stage   string `json:"stage"`
+	Message string `json:"message"`
+	Target  string `json:"target,omitempty"`
+}
+
+// FormatError formats err as a JSON error document. The stage and target
+// are taken from a trace.StageError; other errors are reported as trace
+// failures.
+func (f *JSONFormatter) FormatError(err error) ([]byte, error) {
+	doc := JSONErrorDocument{
+		Error: JSONError{
+			Stage:   string(trace.ErrorStage(err)),
+			Message: err.Error(),
+		},
+		SchemaVersion: JSONSchemaVersion,
+	}
+	var se *trace.StageError
+	if errors.As(err, &se) {
+		doc.Error.Target = se.Target
+	}
+
+	if f.pretty {
+		return json.MarshalIndent(doc, "", "  ")
+	}
+	return json.Marshal(doc)
+}
+
 // JSONOutput is the JSON-serializable representation of a trace result.
 type JSONOutput struct {
-	Target      string      `json:"target"`
-	ResolvedIP  string      `json:"resolved_ip"`
-	Timestamp   string      `json:"timestamp"`
-	ProbeMethod string      `json:"probe_method"`
-	Completed   bool        `json:"completed"`
-	Hops        []JSONHop   `json:"hops"`
-	Summary     JSONSummary `json:"summary"`
+	Target      string           `json:"target"`
+	Aliases     []string         `json:"aliases,omitempty"`
+	ResolvedIP  string           `json:"resolved_ip"`
+	TargetPTR   string           `json:"target_ptr,omitempty"`
+	Translated  string           `json:"translated_via,omitempty"`
+	Via         []string         `json:"via,omitempty"`
+	Timestamp   string           `json:"timestamp"`
+	ProbeMethod string           `json:"probe_method"`
+	Mode        string           `json:"mode,omitempty"`
+	Concurrency int              `json:"concurrency,omitempty"`
+	ProbeSocket string           `json:"probe_socket,omitempty"`
+	Notes       []string         `json:"notes,omitempty"`
+	Completed   bool             `json:"completed"`
+	Stopped     string           `json:"stopped_reason,omitempty"`
+	Rounds      int              `json:"rounds,omitempty"`
+	PathChanges []JSONPathChange `json:"path_changes,omitempty"`
+	Skipped     *JSONSkip        `json:"skipped,omitempty"`
+	Hops        []JSONHop        `json:"hops"`
+	Summary     JSONSummary      `json:"summary"`
+	Meta        *JSONMeta        `json:"meta,omitempty"`
+
+	Redirects   []JSONRedirect   `json:"redirects,omitempty"`
+	AppProbe    *JSONAppProbe    `json:"app_probe,omitempty"`
+	Diagnostics *JSONDiagnostics `json:"diagnostics,omitempty"`
+
+	SchemaVersion int `json:"schema_version"`
+}
+
+// JSONDiagnostics holds troubleshooting data about the trace itself.
+type JSONDiagnostics struct {
+	Probes      *JSONProbeStats  `json:"probes,omitempty"`
+	EnrichRetry *JSONEnrichRetry `json:"enrich_retry,omitempty"`
+}
+
+// JSONEnrichRetry represents the enrichment retry counts in JSON format.
+type JSONEnrichRetry struct {
+	Retried   int `json:"retried"`
+	Recovered int `json:"recovered"`
+}
+
+// JSONProbeStats represents prober packet counters in JSON format.
+type JSONProbeStats struct {
+	Method          string            `json:"method,omitempty"`
+	Sent            uint64            `json:"sent"`
+	Received        uint64            `json:"received"`
+	Duplicates      uint64            `json:"duplicates"`
+	Discarded       map[string]uint64 `json:"discarded,omitempty"`
+	Timeouts        uint64            `json:"timeouts"`
+	Retransmissions uint64            `json:"retransmissions"`
+	SocketErrors    uint64            `json:"socket_errors"`
+}
+
+// JSONMeta represents run metadata in JSON format.
+type JSONMeta struct {
+	Version    string            `json:"version,omitempty"`
+	Hostname   string            `json:"hostname,omitempty"`
+	OS         string            `json:"os,omitempty"`
+	Arch       string            `json:"arch,omitempty"`
+	SourceIP   string            `json:"source_ip,omitempty"`
+	Interface  string            `json:"interface,omitempty"`
+	Resolve    string            `json:"resolve_policy,omitempty"`
+	PinnedIP   string            `json:"pinned_ip,omitempty"`
+	RTTOutlier string            `json:"rtt_outlier,omitempty"`
+	ProbeCount int               `json:"probe_count,omitempty"`
+	DestProbes int               `json:"dest_probe_count,omitempty"`
+	MaxHops    int               `json:"max_hops,omitempty"`
+	FirstHop   int               `json:"first_hop,omitempty"`
+	LastHop    int               `json:"last_hop,omitempty"`
+	TimeoutMs  float64           `json:"timeout_ms,omitempty"`
+	DestPort   int               `json:"dest_port,omitempty"`
+	ICMPID     int               `json:"icmp_id,omitempty"`
+	SeqStart   int               `json:"seq_start,omitempty"`
+	FlowID     int               `json:"flow_id,omitempty"`
+	Enrichment map[string]string `json:"enrichment,omitempty"`
+	Tags       map[string]string `json:"tags,omitempty"`
+}
+
+// JSONSkip represents a collapsed range of private hops in JSON format.
+type JSONSkip struct {
+	FirstHop int     `json:"first_hop"`
+	LastHop  int     `json:"last_hop"`
+	RTT      float64 `json:"rtt_ms"`
+}
+
+// JSONRedirect represents an ICMP redirect received during the trace.
+type JSONRedirect struct {
+	Router  string `json:"router"`
+	Gateway string `json:"gateway"`
+	Count   int    `json:"count"`
+}
+
+// JSONPathChange represents a hop seen answering from a new address
+// during --watch.
+type JSONPathChange struct {
+	Time string `json:"time"`
+	Hop  int    `json:"hop"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// JSONAppProbe represents the connection made to the destination after
+// a TCP trace (--app-probe). Times are omitted for steps not reached.
+type JSONAppProbe struct {
+	Address        string  `json:"address"`
+	ServerName     string  `json:"server_name,omitempty"`
+	SYNACKMs       float64 `json:"synack_ms,omitempty"`
+	ConnectMs      float64 `json:"connect_ms,omitempty"`
+	TLS            bool    `json:"tls,omitempty"`
+	TLSHandshakeMs float64 `json:"tls_handshake_ms,omitempty"`
+	TLSVersion     string  `json:"tls_version,omitempty"`
+	Error          string  `json:"error,omitempty"`
 }
 
 // JSONHop represents a single hop in JSON format.
 type JSONHop struct {
-	Hop         int       `json:"hop"`
-	IP          string    `json:"ip,omitempty"`
-	Hostname    string    `json:"hostname,omitempty"`
-	ASN         *JSONASN  `json:"asn,omitempty"`
-	Geo         *JSONGeo  `json:"geo,omitempty"`
-	RTTs        []float64 `json:"rtts"`
-	AvgRTT      float64   `json:"avg_rtt_ms"`
-	MinRTT      float64   `json:"min_rtt_ms"`
-	MaxRTT      float64   `json:"max_rtt_ms"`
-	Jitter      float64   `json:"jitter_ms"`
-	LossPercent float64   `json:"loss_percent"`
-	Responded   bool      `json:"responded"`
+	Hop         int            `json:"hop"`
+	IP          string         `json:"ip,omitempty"`
+	Placeholder string         `json:"placeholder,omitempty"`
+	Hostname    string         `json:"hostname,omitempty"`
+	ASN         *JSONASN       `json:"asn,omitempty"`
+	Geo         *JSONGeo       `json:"geo,omitempty"`
+	Interface   *JSONInterface `json:"interface,omitempty"`
+	RTTs        []float64      `json:"rtts"`
+	SentAt      []string       `json:"sent_at,omitempty"`
+	AvgRTT      float64        `json:"avg_rtt_ms"`
+	MinRTT      float64        `json:"min_rtt_ms"`
+	MaxRTT      float64        `json:"max_rtt_ms"`
+	Jitter      float64        `json:"jitter_ms"`
+	LastRTT     float64        `json:"last_rtt_ms"`
+	LossPercent float64        `json:"loss_percent"`
+	Responded   bool           `json:"responded"`
+
+	// Set by --watch, over all rounds; RTTs then keeps only the last
+	// trace.DefaultRecentSamples
+	Sent     int     `json:"sent,omitempty"`
+	Received int     `json:"received,omitempty"`
+	StdDev   float64 `json:"stddev_ms,omitempty"`
+	EWMA     float64 `json:"ewma_ms,omitempty"`
+
+	ResponseKind        string `json:"response_kind,omitempty"`
+	SourceRouteRejected bool   `json:"source_route_rejected,omitempty"`
+	Unprobed            bool   `json:"unprobed,omitempty"`
+	SendErrors          int    `json:"send_errors,omitempty"`
+	SendError           string `json:"send_error,omitempty"`
+	WeakMatches         int    `json:"weak_matches,omitempty"`
+	Outliers            int    `json:"outliers,omitempty"`
+
+	// HostnameVerified is false for a hostname that does not resolve
+	// back to IP, and absent if it was not checked
+	HostnameVerified *bool `json:"hostname_verified,omitempty"`
 }
 
 // JSONASN represents ASN information in JSON format.
 type JSONASN struct {
-	Number  int    `json:"number"`
-	Org     string `json:"org"`
-	Country string `json:"country,omitempty"`
+	Number     int    `json:"number"`
+	Org        string `json:"org"`
+	Country    string `json:"country,omitempty"`
+	Prefix     string `json:"prefix,omitempty"`
+	AltNumbers []int  `json:"alt_numbers,omitempty"`
+	Source     string `json:"source,omitempty"`
+}
+
+// JSONInterface represents RFC 5837 interface information in JSON format.
+type JSONInterface struct {
+	Role    string `json:"role"`
+	IfIndex int    `json:"ifindex,omitempty"`
+	Name    string `json:"name,omitempty"`
+	MTU     int    `json:"mtu,omitempty"`
+	IP      string `json:"ip,omitempty"`
 }
 
 // JSONGeo represents geographic information in JSON format.
@@ -83,37 +267,182 @@ type JSONGeo struct {
 	Country     string  `json:"country"`
 	CountryCode string  `json:"country_code"`
 	City        string  `json:"city,omitempty"`
+	Region      string  `json:"region,omitempty"`
+	RegionCode  string  `json:"region_code,omitempty"`
 	Latitude    float64 `json:"latitude,omitempty"`
 	Longitude   float64 `json:"longitude,omitempty"`
+	ISP         string  `json:"isp,omitempty"`
+	Hosting     bool    `json:"hosting,omitempty"`
+	Proxy       bool    `json:"proxy,omitempty"`
+	Mobile      bool    `json:"mobile,omitempty"`
+	Source      string  `json:"source,omitempty"`
 }
 
 // JSONSummary represents trace summary in JSON format.
 type JSONSummary struct {
-	TotalHops         int     `json:"total_hops"`
-	TotalTimeMs       float64 `json:"total_time_ms"`
-	PacketLossPercent float64 `json:"packet_loss_percent"`
+	TotalHops         int                  `json:"total_hops"`
+	TotalTimeMs       float64              `json:"total_time_ms"`
+	PacketLossPercent float64              `json:"packet_loss_percent"`
+	PerAS             []JSONASContribution `json:"per_as,omitempty"`
+	LastTransitHop    *JSONTransitHop      `json:"last_transit_hop,omitempty"`
+	Destination       *JSONDestination     `json:"destination,omitempty"`
+}
+
+// JSONTransitHop represents the last hop before the destination AS.
+type JSONTransitHop struct {
+	Hop int     `json:"hop"`
+	IP  string  `json:"ip"`
+	RTT float64 `json:"rtt_ms"`
+	ASN int     `json:"asn"`
+	Org string  `json:"org,omitempty"`
+}
+
+// JSONDestination represents the end-host probes sent with --verify-dest.
+type JSONDestination struct {
+	Probes      int     `json:"probes"`
+	LossPercent float64 `json:"loss_percent"`
+	AvgRTT      float64 `json:"avg_rtt_ms"`
+}
+
+// JSONASContribution represents per-AS latency in JSON format.
+type JSONASContribution struct {
+	ASN      int     `json:"asn"`
+	Org      string  `json:"org,omitempty"`
+	FirstHop int     `json:"first_hop"`
+	LastHop  int     `json:"last_hop"`
+	RTT      float64 `json:"rtt_ms"`
+	Delta    float64 `json:"delta_ms"`
+	Clamped  bool    `json:"clamped,omitempty"`
 }
 
 // toJSONOutput converts a TraceResult to JSONOutput.
 func (f *JSONFormatter) toJSONOutput(result *trace.TraceResult) *JSONOutput {
 	output := &JSONOutput{
 		Target:      result.Target,
-		ResolvedIP:  result.ResolvedIP.String(),
-		Timestamp:   result.Timestamp.Format("2006-01-02T15:04:05Z07:00"),
+		Aliases:     result.Aliases,
+		ResolvedIP:  ipString(result.ResolvedIP),
+		TargetPTR:   result.TargetPTR,
+		Translated:  result.TranslatedVia,
+		Timestamp:   f.config.FormatTime(result.Timestamp, time.RFC3339),
 		ProbeMethod: result.ProbeMethod,
+		Mode:        result.Mode,
+		Concurrency: result.Concurrency,
+		ProbeSocket: result.ProbeSocket,
+		Notes:       result.Notes,
 		Completed:   result.Completed,
+		Stopped:     result.StoppedReason,
+		Rounds:      result.Rounds,
 		Hops:        make([]JSONHop, len(result.Hops)),
 		Summary: JSONSummary{
 			TotalHops:         result.Summary.TotalHops,
 			TotalTimeMs:       roundFloat(result.Summary.TotalTimeMs, 3),
 			PacketLossPercent: roundFloat(result.Summary.PacketLossPercent, 1),
 		},
+		SchemaVersion: JSONSchemaVersion,
+	}
+
+	for _, ip := range result.Via {
+		output.Via = append(output.Via, ip.String())
+	}
+	for _, r := range result.Redirects {
+		output.Redirects = append(output.Redirects, JSONRedirect{
+			Router:  r.Router.String(),
+			Gateway: r.Gateway.String(),
+			Count:   r.Count,
+		})
+	}
+	for _, c := range result.PathChanges {
+		output.PathChanges = append(output.PathChanges, JSONPathChange{
+			Time: f.config.FormatTime(c.Time, time.RFC3339),
+			Hop:  c.Hop,
+			From: c.From,
+			To:   c.To,
+		})
+	}
+
+	if ap := result.AppProbe; ap != nil {
+		output.AppProbe = &JSONAppProbe{
+			Address:        ap.Address,
+			ServerName:     ap.ServerName,
+			SYNACKMs:       roundFloat(ap.SYNACKMs, 3),
+			ConnectMs:      roundFloat(ap.ConnectMs, 3),
+			TLS:            ap.TLS,
+			TLSHandshakeMs: roundFloat(ap.TLSHandshakeMs, 3),
+			TLSVersion:     ap.TLSVersion,
+			Error:          ap.Error,
+		}
+	}
+
+	for _, c := range result.Summary.PerAS {
+		output.Summary.PerAS = append(output.Summary.PerAS, JSONASContribution{
+			ASN:      c.ASN,
+			Org:      c.Org,
+			FirstHop: c.FirstHop,
+			LastHop:  c.LastHop,
+			RTT:      roundFloat(c.RTTMs, 3),
+			Delta:    roundFloat(c.DeltaMs, 3),
+			Clamped:  c.Clamped,
+		})
+	}
+
+	if t := result.Summary.LastTransitHop; t != nil {
+		output.Summary.LastTransitHop = &JSONTransitHop{
+			Hop: t.Number,
+			IP:  t.IP.String(),
+			RTT: roundFloat(t.RTTMs, 3),
+			ASN: t.ASN,
+			Org: t.Org,
+		}
+	}
+
+	if n := result.Summary.DestinationProbes; n > 0 {
+		output.Summary.Destination = &JSONDestination{
+			Probes:      n,
+			LossPercent: roundFloat(result.Summary.DestinationLossPercent, 1),
+			AvgRTT:      roundFloat(result.Summary.DestinationAvgRTT, 3),
+		}
+	}
+
+	if result.Skipped != nil {
+		output.Skipped = &JSONSkip{
+			FirstHop: result.Skipped.FirstHop,
+			LastHop:  result.Skipped.LastHop,
+			RTT:      roundFloat(result.Skipped.RTT, 3),
+		}
 	}
 
 	for i, hop := range result.Hops {
 		output.Hops[i] = f.toJSONHop(&hop)
 	}
 
+	if result.Meta != nil {
+		output.Meta = f.toJSONMeta(result.Meta)
+	}
+
+	if s := result.ProbeStats; s != nil {
+		output.Diagnostics = &JSONDiagnostics{
+			Probes: &JSONProbeStats{
+				Method:          s.Method,
+				Sent:            s.Sent,
+				Received:        s.Received,
+				Duplicates:      s.Duplicates,
+				Discarded:       s.Discarded,
+				Timeouts:        s.Timeouts,
+				Retransmissions: s.Retransmissions,
+				SocketErrors:    s.SocketErrors,
+			},
+		}
+	}
+	if r := result.EnrichRetry; r != nil {
+		if output.Diagnostics == nil {
+			output.Diagnostics = &JSONDiagnostics{}
+		}
+		output.Diagnostics.EnrichRetry = &JSONEnrichRetry{
+			Retried:   r.Retried,
+			Recovered: r.Recovered,
+		}
+	}
+
 	return output
 }
 
@@ -126,23 +455,52 @@ func (f *JSONFormatter) toJSONHop(hop *trace.Hop) JSONHop {
 		MinRTT:      roundFloat(hop.MinRTT, 3),
 		MaxRTT:      roundFloat(hop.MaxRTT, 3),
 		Jitter:      roundFloat(hop.Jitter, 3),
+		LastRTT:     roundFloat(hop.LastRTT, 3),
 		LossPercent: roundFloat(hop.LossPercent, 1),
 		Responded:   hop.Responded,
+
+		Sent:     hop.Sent,
+		Received: hop.Received,
+		StdDev:   roundFloat(hop.StdDev, 3),
+		EWMA:     roundFloat(hop.EWMA, 3),
+
+		ResponseKind:        hop.ResponseKind,
+		SourceRouteRejected: hop.SourceRouteRejected,
+		Unprobed:            hop.Unprobed,
+		SendErrors:          hop.SendErrors,
+		SendError:           hop.SendError,
+		WeakMatches:         hop.WeakMatches,
+		Outliers:            hop.Outliers,
 	}
 
 	if hop.IP != nil {
 		jh.IP = hop.IP.String()
 	}
+	jh.Placeholder = hop.Placeholder
+
+	// Probe send times in RFC 3339 with nanoseconds, "" for lost probes
+	if f.config.ProbeTimes && hop.SentAt != nil {
+		jh.SentAt = make([]string, len(hop.SentAt))
+		for i, t := range hop.SentAt {
+			if !t.IsZero() {
+				jh.SentAt[i] = t.UTC().Format(time.RFC3339Nano)
+			}
+		}
+	}
 
 	if hop.Hostname != "" {
 		jh.Hostname = hop.Hostname
+		jh.HostnameVerified = hop.HostnameVerified
 	}
 
 	if hop.ASN != nil {
 		jh.ASN = &JSONASN{
-			Number:  hop.ASN.Number,
-			Org:     hop.ASN.Org,
-			Country: hop.ASN.Country,
+			Number:     hop.ASN.Number,
+			Org:        hop.ASN.Org,
+			Country:    hop.ASN.Country,
+			Prefix:     hop.ASN.Prefix,
+			AltNumbers: hop.ASN.AltNumbers,
+			Source:     hop.ASN.Source,
 		}
 	}
 
@@ -151,14 +509,209 @@ func (f *JSONFormatter) toJSONHop(hop *trace.Hop) JSONHop {
 			Country:     hop.Geo.Country,
 			CountryCode: hop.Geo.CountryCode,
 			City:        hop.Geo.City,
+			Region:      hop.Geo.Region,
+			RegionCode:  hop.Geo.RegionCode,
 			Latitude:    hop.Geo.Latitude,
 			Longitude:   hop.Geo.Longitude,
+			ISP:         hop.Geo.ISP,
+			Hosting:     hop.Geo.Hosting,
+			Proxy:       hop.Geo.Proxy,
+			Mobile:      hop.Geo.Mobile,
+			Source:      hop.Geo.Source,
+		}
+	}
+
+	if hop.Interface != nil {
+		jh.Interface = &JSONInterface{
+			Role:    hop.Interface.Role,
+			IfIndex: hop.Interface.Index,
+			Name:    hop.Interface.Name,
+			MTU:     hop.Interface.MTU,
+		}
+		if hop.Interface.IP != nil {
+			jh.Interface.IP = hop.Interface.IP.String()
 		}
 	}
 
 	return jh
 }
 
+// toJSONMeta converts trace Meta to JSONMeta.
+func (f *JSONFormatter) toJSONMeta(meta *trace.Meta) *JSONMeta {
+	jm := &JSONMeta{
+		Version:    meta.Version,
+		Hostname:   meta.Hostname,
+		OS:         meta.OS,
+		Arch:       meta.Arch,
+		Interface:  meta.Interface,
+		Resolve:    meta.ResolvePolicy,
+		RTTOutlier: meta.RTTOutlier,
+		ProbeCount: meta.ProbeCount,
+		DestProbes: meta.DestProbes,
+		MaxHops:    meta.MaxHops,
+		FirstHop:   meta.FirstHop,
+		LastHop:    meta.LastHop,
+		TimeoutMs:  roundFloat(meta.TimeoutMs, 3),
+		DestPort:   meta.DestPort,
+		ICMPID:     meta.ICMPID,
+		SeqStart:   meta.SeqStart,
+		FlowID:     meta.FlowID,
+		Enrichment: meta.Enrichment,
+		Tags:       meta.Tags,
+	}
+
+	if meta.PinnedIP != nil {
+		jm.PinnedIP = meta.PinnedIP.String()
+	}
+	if meta.SourceIP != nil {
+		jm.SourceIP = meta.SourceIP.String()
+	}
+
+	return jm
+}
+
+// ParseJSONResult reads a trace result written by the JSON formatter, e.g.
+// a saved --json report used as a comparison baseline. Fields the JSON
+// output does not carry, such as run metadata, are left empty.
+func ParseJSONResult(data []byte) (*trace.TraceResult, error) {
+	var in JSONOutput
+	if err := json.Unmarshal(data, &in); err != nil {
+		return nil, fmt.Errorf("invalid JSON trace result: %w", err)
+	}
+	var failed struct {
+		Error *JSONError `json:"error"`
+	}
+	if json.Unmarshal(data, &failed) == nil && failed.Error != nil {
+		return nil, fmt.Errorf("JSON document records a failed run: %s", failed.Error.Message)
+	}
+
+	result := &trace.TraceResult{
+		Target:        in.Target,
+		Aliases:       in.Aliases,
+		ResolvedIP:    net.ParseIP(in.ResolvedIP),
+		TargetPTR:     in.TargetPTR,
+		TranslatedVia: in.Translated,
+		ProbeMethod:   in.ProbeMethod,
+		Mode:          in.Mode,
+		Notes:         in.Notes,
+		Completed:     in.Completed,
+		StoppedReason: in.Stopped,
+		Rounds:        in.Rounds,
+		Hops:          make([]trace.Hop, len(in.Hops)),
+		Summary: trace.Summary{
+			TotalHops:         in.Summary.TotalHops,
+			TotalTimeMs:       in.Summary.TotalTimeMs,
+			PacketLossPercent: in.Summary.PacketLossPercent,
+		},
+	}
+	for _, s := range in.Via {
+		if ip := net.ParseIP(s); ip != nil {
+			result.Via = append(result.Via, ip)
+		}
+	}
+	for _, r := range in.Redirects {
+		result.Redirects = append(result.Redirects, trace.Redirect{
+			Router:  net.ParseIP(r.Router),
+			Gateway: net.ParseIP(r.Gateway),
+			Count:   r.Count,
+		})
+	}
+
+	for _, c := range in.PathChanges {
+		change := trace.PathChange{Hop: c.Hop, From: c.From, To: c.To}
+		if t, err := time.Parse(time.RFC3339, c.Time); err == nil {
+			change.Time = t
+		}
+		result.PathChanges = append(result.PathChanges, change)
+	}
+
+	if ap := in.AppProbe; ap != nil {
+		result.AppProbe = &trace.AppProbe{
+			Address:        ap.Address,
+			ServerName:     ap.ServerName,
+			SYNACKMs:       ap.SYNACKMs,
+			ConnectMs:      ap.ConnectMs,
+			TLS:            ap.TLS,
+			TLSHandshakeMs: ap.TLSHandshakeMs,
+			TLSVersion:     ap.TLSVersion,
+			Error:          ap.Error,
+		}
+	}
+
+	// Custom --time-format values may not parse; the timestamp is
+	// informational only
+	if ts, err := time.Parse(time.RFC3339, in.Timestamp); err == nil {
+		result.Timestamp = ts
+	}
+
+	for i, jh := range in.Hops {
+		hop := trace.Hop{
+			Number:      jh.Hop,
+			IP:          net.ParseIP(jh.IP),
+			Placeholder: jh.Placeholder,
+			Hostname:    jh.Hostname,
+			RTTs:        jh.RTTs,
+			AvgRTT:      jh.AvgRTT,
+			MinRTT:      jh.MinRTT,
+			MaxRTT:      jh.MaxRTT,
+			Jitter:      jh.Jitter,
+			LastRTT:     jh.LastRTT,
+			LossPercent: jh.LossPercent,
+			Responded:   jh.Responded,
+
+			Sent:     jh.Sent,
+			Received: jh.Received,
+			StdDev:   jh.StdDev,
+			EWMA:     jh.EWMA,
+
+			ResponseKind:        jh.ResponseKind,
+			SourceRouteRejected: jh.SourceRouteRejected,
+			Unprobed:            jh.Unprobed,
+			SendErrors:          jh.SendErrors,
+			SendError:           jh.SendError,
+			WeakMatches:         jh.WeakMatches,
+			Outliers:            jh.Outliers,
+			HostnameVerified:    jh.HostnameVerified,
+		}
+		if jh.ASN != nil {
+			hop.ASN = &trace.ASNInfo{
+				Number:     jh.ASN.Number,
+				Org:        jh.ASN.Org,
+				Country:    jh.ASN.Country,
+				Prefix:     jh.ASN.Prefix,
+				AltNumbers: jh.ASN.AltNumbers,
+				Source:     jh.ASN.Source,
+			}
+		}
+		if jh.Geo != nil {
+			hop.Geo = &trace.GeoInfo{
+				Country:     jh.Geo.Country,
+				CountryCode: jh.Geo.CountryCode,
+				City:        jh.Geo.City,
+				Region:      jh.Geo.Region,
+				RegionCode:  jh.Geo.RegionCode,
+				Latitude:    jh.Geo.Latitude,
+				Longitude:   jh.Geo.Longitude,
+				ISP:         jh.Geo.ISP,
+				Hosting:     jh.Geo.Hosting,
+				Proxy:       jh.Geo.Proxy,
+				Mobile:      jh.Geo.Mobile,
+				Source:      jh.Geo.Source,
+			}
+		}
+		for j, s := range jh.SentAt {
+			t, err := time.Parse(time.RFC3339Nano, s)
+			if s != "" && err != nil {
+				return nil, fmt.Errorf("hop %d: sent_at[%d]: %w", jh.Hop, j, err)
+			}
+			hop.SentAt = append(hop.SentAt, t)
+		}
+		result.Hops[i] = hop
+	}
+
+	return result, nil
+}
+
 // ContentType returns the MIME type for JSON output.
 func (f *JSONFormatter) ContentType() string {
 	return "application/json"
@@ -169,14 +722,9 @@ func (f *JSONFormatter) FileExtension() string {
 	return "json"
 }
 
-// Helper function to round floats
+// Helper function to round floats. Negative values (e.g. -1 for a
+// timeout) round away from zero like positive ones.
 func roundFloat(val float64, precision int) float64 {
-	if precision == 0 {
-		return float64(int(val + 0.5))
-	}
-	p := float64(1)
-	for i := 0; i < precision; i++ {
-		p *= 10
-	}
-	return float64(int(val*p+0.5)) / p
+	p := math.Pow(10, float64(precision))
+	return math.Round(val*p) / p
 }
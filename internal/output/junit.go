@@ -0,0 +1,262 @@
+package output
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+)
+
+// Assertions holds the conditions a trace must satisfy in CI.
+// Zero values, and a nil MaxLossPercent, disable the corresponding check.
+type Assertions struct {
+	// Complete requires the trace to reach the destination
+	Complete bool
+
+	// MaxHops is the maximum number of hops to the destination
+	MaxHops int
+
+	// MaxRTTMs is the maximum round-trip time to the destination in milliseconds
+	MaxRTTMs float64
+
+	// MaxLossPercent is the maximum packet loss at the destination; zero
+	// allows no loss at all
+	MaxLossPercent *float64
+}
+
+// IsEmpty returns true if no assertion is enabled.
+func (a Assertions) IsEmpty() bool {
+	return !a.Complete && a.MaxHops <= 0 && a.MaxRTTMs <= 0 && a.MaxLossPercent == nil
+}
+
+// AssertionResult is the outcome of a single assertion.
+type AssertionResult struct {
+	// Name identifies the assertion (e.g., "max-hops")
+	Name string
+
+	// Passed indicates whether the assertion held
+	Passed bool
+
+	// Message describes the failure (empty when passed)
+	Message string
+
+	// Actual is the offending value (empty when passed)
+	Actual string
+}
+
+// EvaluateAssertions checks a trace result against the given assertions.
+// Results are returned in a stable order: complete, max-hops, max-rtt, max-loss.
+func EvaluateAssertions(result *trace.TraceResult, a Assertions) []AssertionResult {
+	var results []AssertionResult
+
+	if a.Complete {
+		r := AssertionResult{Name: "complete", Passed: result.Completed}
+		if !r.Passed {
+			r.Message = fmt.Sprintf("destination %s was not reached", result.ResolvedIP)
+			r.Actual = fmt.Sprintf("trace stopped after %d hops", result.Summary.TotalHops)
+		}
+		results = append(results, r)
+	}
+
+	if a.MaxHops > 0 {
+		r := AssertionResult{
+			Name:   "max-hops",
+			Passed: result.Completed && result.Summary.TotalHops <= a.MaxHops,
+		}
+		if !r.Passed {
+			if result.Completed {
+				r.Message = fmt.Sprintf("destination is more than %d hops away", a.MaxHops)
+			} else {
+				r.Message = fmt.Sprintf("destination was not reached within %d hops", a.MaxHops)
+			}
+			r.Actual = fmt.Sprintf("%d hops", result.Summary.TotalHops)
+		}
+		results = append(results, r)
+	}
+
+	if a.MaxRTTMs > 0 {
+		rtt := result.Summary.TotalTimeMs
+		r := AssertionResult{
+			Name:   "max-rtt",
+			Passed: result.Completed && rtt <= a.MaxRTTMs,
+		}
+		if !r.Passed {
+			if result.Completed {
+				r.Message = fmt.Sprintf("RTT exceeds %.2f ms", a.MaxRTTMs)
+			} else {
+				r.Message = "destination was not reached"
+			}
+			r.Actual = fmt.Sprintf("%.2f ms", rtt)
+		}
+		results = append(results, r)
+	}
+
+	if a.MaxLossPercent != nil {
+		loss := destinationLoss(result)
+		r := AssertionResult{
+			Name:   "max-loss",
+			Passed: loss <= *a.MaxLossPercent,
+		}
+		if !r.Passed {
+			r.Message = fmt.Sprintf("packet loss exceeds %.1f%%", *a.MaxLossPercent)
+			r.Actual = fmt.Sprintf("%.1f%%", loss)
+		}
+		results = append(results, r)
+	}
+
+	return results
+}
+
+// CountFailures returns the number of failed assertions.
+func CountFailures(results []AssertionResult) int {
+	failures := 0
+	for _, r := range results {
+		if !r.Passed {
+			failures++
+		}
+	}
+	return failures
+}
+
+// destinationLoss returns the packet loss at the destination hop.
+// An unreached destination counts as 100% loss.
+func destinationLoss(result *trace.TraceResult) float64 {
+	if !result.Completed || len(result.Hops) == 0 {
+		return 100
+	}
+	return result.Hops[len(result.Hops)-1].LossPercent
+}
+
+// JUnitFormatter formats assertion results as a JUnit XML report.
+type JUnitFormatter struct {
+	config     Config
+	assertions Assertions
+}
+
+// NewJUnitFormatter creates a new JUnit formatter for the given assertions.
+// If no assertion is enabled, the report asserts that the trace completes.
+func NewJUnitFormatter(config Config, assertions Assertions) *JUnitFormatter {
+	if assertions.IsEmpty() {
+		assertions.Complete = true
+	}
+
+	return &JUnitFormatter{
+		config:     config,
+		assertions: assertions,
+	}
+}
+
+// junitTestSuites is the root element of a JUnit report.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite represents a single trace.
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property"`
+	TestCases  []junitTestCase `xml:"testcase"`
+}
+
+// junitProperty is a name/value pair attached to a suite.
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// junitTestCase represents a single assertion.
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+// junitFailure describes a failed assertion.
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Value   string `xml:",chardata"`
+}
+
+// Format evaluates the assertions and formats them as JUnit XML.
+func (f *JUnitFormatter) Format(result *trace.TraceResult) ([]byte, error) {
+	results := EvaluateAssertions(result, f.assertions)
+	failures := CountFailures(results)
+
+	suite := junitTestSuite{
+		Name:      result.Target,
+		Tests:     len(results),
+		Failures:  failures,
+		Time:      fmt.Sprintf("%.3f", result.Summary.TotalTimeMs/1000),
+		Timestamp: result.Timestamp.Format("2006-01-02T15:04:05"),
+		Properties: []junitProperty{
+			{Name: "resolved_ip", Value: ipString(result.ResolvedIP)},
+			{Name: "probe_method", Value: result.ProbeMethod},
+			{Name: "total_hops", Value: fmt.Sprintf("%d", result.Summary.TotalHops)},
+		},
+	}
+	if result.Mode != "" {
+		suite.Properties = append(suite.Properties, junitProperty{Name: "mode", Value: result.Mode})
+	}
+	if result.ProbeSocket != "" {
+		suite.Properties = append(suite.Properties, junitProperty{Name: "probe_socket", Value: result.ProbeSocket})
+	}
+	for _, note := range result.Notes {
+		suite.Properties = append(suite.Properties, junitProperty{Name: "note", Value: note})
+	}
+
+	for _, r := range results {
+		tc := junitTestCase{
+			Name:      r.Name,
+			ClassName: "poros." + result.Target,
+			Time:      "0",
+		}
+		if !r.Passed {
+			tc.Failure = &junitFailure{
+				Message: r.Message,
+				Type:    "AssertionFailed",
+				Value:   r.Actual,
+			}
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+
+	report := junitTestSuites{
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Suites:   []junitTestSuite{suite},
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+
+	encoder := xml.NewEncoder(&buf)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return nil, fmt.Errorf("failed to encode JUnit report: %w", err)
+	}
+	buf.WriteString("\n")
+
+	return buf.Bytes(), nil
+}
+
+// ContentType returns the MIME type for JUnit output.
+func (f *JUnitFormatter) ContentType() string {
+	return "application/xml"
+}
+
+// FileExtension returns the file extension for JUnit output.
+func (f *JUnitFormatter) FileExtension() string {
+	return "xml"
+}
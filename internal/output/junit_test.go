@@ -0,0 +1,189 @@
+package output
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestEvaluateAssertions(t *testing.T) {
+	result := sampleTraceResult()
+
+	tests := []struct {
+		name       string
+		assertions Assertions
+		wantFailed []string
+	}{
+		{
+			name:       "all pass",
+			assertions: Assertions{Complete: true, MaxHops: 3, MaxRTTMs: 10, MaxLossPercent: lossLimit(100)},
+			wantFailed: nil,
+		},
+		{
+			name:       "too many hops",
+			assertions: Assertions{MaxHops: 2},
+			wantFailed: []string{"max-hops"},
+		},
+		{
+			name:       "rtt over budget",
+			assertions: Assertions{MaxRTTMs: 5},
+			wantFailed: []string{"max-rtt"},
+		},
+		{
+			name:       "loss over budget",
+			assertions: Assertions{MaxLossPercent: lossLimit(50)},
+			wantFailed: []string{"max-loss"},
+		},
+		{
+			name:       "none enabled",
+			assertions: Assertions{},
+			wantFailed: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := EvaluateAssertions(result, tt.assertions)
+
+			var failed []string
+			for _, r := range results {
+				if !r.Passed {
+					failed = append(failed, r.Name)
+					if r.Message == "" || r.Actual == "" {
+						t.Errorf("%s: failure should carry message and actual value", r.Name)
+					}
+				}
+			}
+
+			if strings.Join(failed, ",") != strings.Join(tt.wantFailed, ",") {
+				t.Errorf("failed = %v, want %v", failed, tt.wantFailed)
+			}
+			if CountFailures(results) != len(tt.wantFailed) {
+				t.Errorf("CountFailures() = %d, want %d", CountFailures(results), len(tt.wantFailed))
+			}
+		})
+	}
+}
+
+func TestEvaluateAssertions_Incomplete(t *testing.T) {
+	result := sampleTraceResult()
+	result.Completed = false
+
+	results := EvaluateAssertions(result, Assertions{
+		Complete:       true,
+		MaxHops:        30,
+		MaxRTTMs:       1000,
+		MaxLossPercent: lossLimit(10),
+	})
+
+	if CountFailures(results) != 4 {
+		t.Errorf("CountFailures() = %d, want 4 for unreached destination", CountFailures(results))
+	}
+}
+
+// lossLimit returns a MaxLossPercent of pct.
+func lossLimit(pct float64) *float64 {
+	return &pct
+}
+
+func TestEvaluateAssertions_NoLossAllowed(t *testing.T) {
+	result := sampleTraceResult()
+	result.Hops = result.Hops[:2] // hop 2, which lost 1 of 3 probes, is the destination
+
+	results := EvaluateAssertions(result, Assertions{MaxLossPercent: lossLimit(0)})
+	if len(results) != 1 || results[0].Passed {
+		t.Errorf("max-loss 0 with loss at the destination = %+v, want a failure", results)
+	}
+
+	result.Hops[1].LossPercent = 0
+	results = EvaluateAssertions(result, Assertions{MaxLossPercent: lossLimit(0)})
+	if len(results) != 1 || !results[0].Passed {
+		t.Errorf("max-loss 0 without loss = %+v, want a pass", results)
+	}
+}
+
+func TestJUnitFormatter(t *testing.T) {
+	result := sampleTraceResult()
+	formatter := NewJUnitFormatter(Config{}, Assertions{Complete: true, MaxHops: 2, MaxRTTMs: 100})
+
+	data, err := formatter.Format(result)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	if !strings.HasPrefix(string(data), xml.Header) {
+		t.Error("Output should start with the XML header")
+	}
+
+	var report junitTestSuites
+	if err := xml.Unmarshal(data, &report); err != nil {
+		t.Fatalf("XML parsing error: %v", err)
+	}
+
+	if report.Tests != 3 || report.Failures != 1 {
+		t.Errorf("testsuites tests/failures = %d/%d, want 3/1", report.Tests, report.Failures)
+	}
+	if len(report.Suites) != 1 {
+		t.Fatalf("len(Suites) = %d, want 1", len(report.Suites))
+	}
+
+	suite := report.Suites[0]
+	if suite.Name != "google.com" {
+		t.Errorf("suite name = %q, want %q", suite.Name, "google.com")
+	}
+	if suite.Tests != len(suite.TestCases) {
+		t.Errorf("suite tests = %d, but %d testcases", suite.Tests, len(suite.TestCases))
+	}
+
+	for _, tc := range suite.TestCases {
+		if tc.Name == "" || tc.ClassName == "" || tc.Time == "" {
+			t.Errorf("testcase missing required attributes: %+v", tc)
+		}
+		if tc.Name == "max-hops" {
+			if tc.Failure == nil {
+				t.Fatal("max-hops should fail")
+			}
+			if tc.Failure.Value != "3 hops" {
+				t.Errorf("failure value = %q, want %q", tc.Failure.Value, "3 hops")
+			}
+		} else if tc.Failure != nil {
+			t.Errorf("%s should pass, got failure %q", tc.Name, tc.Failure.Message)
+		}
+	}
+}
+
+func TestJUnitFormatter_DefaultsToComplete(t *testing.T) {
+	formatter := NewJUnitFormatter(Config{}, Assertions{})
+
+	data, err := formatter.Format(sampleTraceResult())
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	if !strings.Contains(string(data), `<testcase name="complete"`) {
+		t.Errorf("Report without assertions should check completion, got:\n%s", data)
+	}
+}
+
+func TestJUnitFormatter_Escaping(t *testing.T) {
+	result := sampleTraceResult()
+	result.Target = `evil<&"'>.example`
+	result.Completed = false
+
+	data, err := NewJUnitFormatter(Config{}, Assertions{Complete: true}).Format(result)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	if strings.Contains(string(data), `evil<&`) {
+		t.Error("Target should be XML-escaped")
+	}
+
+	var report junitTestSuites
+	if err := xml.Unmarshal(data, &report); err != nil {
+		t.Fatalf("XML parsing error: %v", err)
+	}
+	if report.Suites[0].Name != result.Target {
+		t.Errorf("suite name = %q, want %q", report.Suites[0].Name, result.Target)
+	}
+}
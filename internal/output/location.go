@@ -0,0 +1,47 @@
+package output
+
+import (
+	"strings"
+
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+)
+
+// Location renders where a hop is, e.g. "Ashburn, VA, US", or "Ashburn,
+// Virginia, United States" with CountryNames. The table, HTML report and
+// TUI all render locations with it, so further GeoIP fields are added
+// here once. It returns an empty string for a hop without GeoIP data.
+func (c Config) Location(geo *trace.GeoInfo) string {
+	return formatLocation(geo, c.CountryNames, true)
+}
+
+// formatLocation implements Location, naming the region and country in
+// full if names is set, and leaving the region out unless region is set.
+// Either form of the region or country stands in for the other when only
+// one is known.
+func formatLocation(geo *trace.GeoInfo, names, region bool) string {
+	if geo == nil {
+		return ""
+	}
+	country := pickName(geo.Country, geo.CountryCode, names)
+	var state string
+	if region {
+		state = pickName(geo.Region, geo.RegionCode, names)
+	}
+
+	var parts []string
+	for _, part := range []string{geo.City, state, country} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// pickName returns name if names is set, else code, or whichever of the
+// two is known.
+func pickName(name, code string, names bool) string {
+	if code == "" || (names && name != "") {
+		return name
+	}
+	return code
+}
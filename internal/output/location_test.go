@@ -0,0 +1,40 @@
+package output
+
+import (
+	"testing"
+
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+)
+
+func TestConfig_Location(t *testing.T) {
+	tests := []struct {
+		name  string
+		geo   *trace.GeoInfo
+		codes string
+		names string
+	}{
+		{"no geo", nil, "", ""},
+		{"empty", &trace.GeoInfo{}, "", ""},
+		{"city and country", &trace.GeoInfo{Country: "Germany", CountryCode: "DE", City: "Berlin"}, "Berlin, DE", "Berlin, Germany"},
+		{"country only", &trace.GeoInfo{Country: "Türkiye", CountryCode: "TR"}, "TR", "Türkiye"},
+		{"code only", &trace.GeoInfo{CountryCode: "US", City: "Ashburn"}, "Ashburn, US", "Ashburn, US"},
+		{"name only", &trace.GeoInfo{Country: "Japan"}, "Japan", "Japan"},
+		{"city only", &trace.GeoInfo{City: "Paris"}, "Paris", "Paris"},
+		{"region", &trace.GeoInfo{Country: "United States", CountryCode: "US", City: "Ashburn", Region: "Virginia", RegionCode: "VA"},
+			"Ashburn, VA, US", "Ashburn, Virginia, United States"},
+		{"region name only", &trace.GeoInfo{CountryCode: "DE", City: "Frankfurt am Main", Region: "Hesse"},
+			"Frankfurt am Main, Hesse, DE", "Frankfurt am Main, Hesse, DE"},
+		{"region without city", &trace.GeoInfo{CountryCode: "US", RegionCode: "VA"}, "VA, US", "VA, US"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Config{}).Location(tt.geo); got != tt.codes {
+				t.Errorf("Location() = %q, want %q", got, tt.codes)
+			}
+			if got := (Config{CountryNames: true}).Location(tt.geo); got != tt.names {
+				t.Errorf("Location() with CountryNames = %q, want %q", got, tt.names)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+)
+
+// MultiFormatter is implemented by formatters that can render the
+// combined traces of one target run with several probe methods.
+type MultiFormatter interface {
+	// FormatMulti converts a MultiResult to formatted output bytes.
+	FormatMulti(result *trace.MultiResult) ([]byte, error)
+}
+
+// WriteMulti formats and writes a multi-method result. It fails for
+// formatters that cannot combine probe methods.
+func (w *Writer) WriteMulti(result *trace.MultiResult) error {
+	mf, ok := w.Formatter().(MultiFormatter)
+	if !ok {
+		return fmt.Errorf("%s output cannot combine probe methods (use text, json or html)", w.format)
+	}
+
+	data, err := mf.FormatMulti(result)
+	if err != nil {
+		return err
+	}
+	if _, err := w.dest().Write(data); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// multiHops returns the hops of all traces of result, to pick one RTT
+// unit for the whole comparison.
+func multiHops(result *trace.MultiResult) []trace.Hop {
+	var hops []trace.Hop
+	for _, r := range result.Results {
+		hops = append(hops, r.Hops...)
+	}
+	return hops
+}
+
+// multiStatus describes how the trace of one method ended, e.g.
+// "complete, 9 hops, 12.34 ms".
+func multiStatus(result *trace.TraceResult, n numberFormat) string {
+	switch {
+	case result.Completed:
+		return fmt.Sprintf("complete, %d hops, %s", result.Summary.TotalHops, n.rtt(result.Summary.TotalTimeMs))
+	case result.StoppedReason == trace.StopPacketBudget:
+		return fmt.Sprintf("stopped after %d hops, packet budget exhausted", result.Summary.TotalHops)
+	default:
+		return fmt.Sprintf("incomplete after %d hops", result.Summary.TotalHops)
+	}
+}
+
+// Column widths of a multi-method text line.
+const (
+	multiHopWidth  = 5 // "%3d  "
+	multiAddrWidth = 16
+	multiRTTWidth  = 10
+	multiCellWidth = multiAddrWidth + multiRTTWidth
+)
+
+// FormatMulti formats a multi-method result as a table with one column
+// per probe method. Hops that answer some methods but not others are
+// marked with "!".
+func (f *TextFormatter) FormatMulti(result *trace.MultiResult) ([]byte, error) {
+	var buf bytes.Buffer
+	n := f.config.numbers(multiHops(result))
+
+	resolved := result.Target
+	if len(result.Results) > 0 {
+		resolved = resolvedLabel(result.Results[0])
+	}
+	fmt.Fprintf(&buf, "traceroute to %s (%s), %d probe methods\n\n",
+		result.Target, resolved, len(result.Methods))
+
+	header := fmt.Sprintf("%-*s", multiHopWidth, "HOP")
+	for _, method := range result.Methods {
+		header += fmt.Sprintf("%-*s", multiCellWidth+2, strings.ToUpper(method.String()))
+	}
+	header = strings.TrimRight(header, " ")
+	if f.colors != nil {
+		header = f.colors.Header.Sprint(header)
+	}
+	buf.WriteString(header + "\n")
+
+	filtered := false
+	for _, mh := range result.Hops() {
+		hopNum := fmt.Sprintf("%3d  ", mh.Number)
+		if f.colors != nil {
+			hopNum = f.colors.Hop.Sprint(hopNum)
+		}
+
+		cells := make([]string, len(mh.Hops))
+		for i, hop := range mh.Hops {
+			cells[i] = f.formatMultiCell(hop, n)
+		}
+		line := strings.TrimRight(strings.Join(cells, "  "), " ")
+
+		if mh.Filtered() {
+			filtered = true
+			marker := "!"
+			if f.colors != nil {
+				marker = f.colors.Timeout.Sprint(marker)
+			}
+			line += "  " + marker
+		}
+		buf.WriteString(hopNum + line + "\n")
+	}
+
+	if filtered {
+		buf.WriteString("\n! answers some probe methods only, likely filtering by protocol\n")
+	}
+
+	buf.WriteString("\n")
+	for i, r := range result.Results {
+		fmt.Fprintf(&buf, "%-5s %s\n", result.Methods[i].String()+":", multiStatus(r, n))
+	}
+
+	return buf.Bytes(), nil
+}
+
+// formatMultiCell formats the address and average RTT of a hop as one
+// fixed-width cell; hop is nil if the trace has no hop with its number.
+// Padding is kept outside colors so trailing blanks can be trimmed.
+func (f *TextFormatter) formatMultiCell(hop *trace.Hop, n numberFormat) string {
+	pad := strings.Repeat(" ", multiCellWidth-1)
+	switch {
+	case hop == nil:
+		return " " + pad
+	case hop.Unprobed:
+		return "-" + pad
+	case !hop.Responded:
+		timeout := "*"
+		if f.colors != nil {
+			timeout = f.colors.Timeout.Sprint(timeout)
+		}
+		return timeout + pad
+	}
+
+	addr := fmt.Sprintf("%-*s", multiAddrWidth, truncateString(hop.Address(), multiAddrWidth-1))
+	if f.colors != nil {
+		addr = f.colors.IP.Sprint(addr)
+	}
+	return addr + f.colorizeRTT(hop.AvgRTT, n)
+}
+
+// JSONMethodResult is one element of the JSON array written for a
+// multi-method trace.
+type JSONMethodResult struct {
+	Method string      `json:"method"`
+	Result *JSONOutput `json:"result"`
+}
+
+// FormatMulti formats a multi-method result as a JSON array with one
+// element per probe method, in the order they were run.
+func (f *JSONFormatter) FormatMulti(result *trace.MultiResult) ([]byte, error) {
+	output := make([]JSONMethodResult, len(result.Results))
+	for i, r := range result.Results {
+		output[i] = JSONMethodResult{
+			Method: result.Methods[i].String(),
+			Result: f.toJSONOutput(r),
+		}
+	}
+
+	if f.pretty {
+		return json.MarshalIndent(output, "", "  ")
+	}
+	return json.Marshal(output)
+}
+
+// htmlMultiData holds the data for the multi-method HTML template.
+type htmlMultiData struct {
+	Title       string
+	Target      string
+	Resolved    string
+	Unit        string
+	Methods     []string
+	Rows        []htmlMultiRow
+	Statuses    []htmlMultiStatus
+	AnyFiltered bool
+	GeneratedAt string
+}
+
+type htmlMultiRow struct {
+	Number   int
+	Filtered bool
+	Cells    []htmlMultiCell
+}
+
+type htmlMultiCell struct {
+	Missing   bool
+	Responded bool
+	IP        string
+	Hostname  string
+	RTT       string
+	RTTClass  string
+}
+
+type htmlMultiStatus struct {
+	Method string
+	Status string
+}
+
+// FormatMulti formats a multi-method result as an HTML report with one
+// column per probe method, highlighting hops that answer some methods
+// but not others.
+func (f *HTMLFormatter) FormatMulti(result *trace.MultiResult) ([]byte, error) {
+	n := f.config.numbers(multiHops(result))
+	data := &htmlMultiData{
+		Title:       fmt.Sprintf("Probe methods to %s", result.Target),
+		Target:      result.Target,
+		Unit:        n.unit(),
+		GeneratedAt: f.config.FormatTime(time.Now(), "2006-01-02 15:04:05 MST"),
+	}
+	if len(result.Results) > 0 {
+		data.Resolved = resolvedLabel(result.Results[0])
+	}
+
+	for i, r := range result.Results {
+		method := result.Methods[i].String()
+		data.Methods = append(data.Methods, strings.ToUpper(method))
+		data.Statuses = append(data.Statuses, htmlMultiStatus{Method: method, Status: multiStatus(r, n)})
+	}
+
+	for _, mh := range result.Hops() {
+		row := htmlMultiRow{Number: mh.Number, Filtered: mh.Filtered()}
+		data.AnyFiltered = data.AnyFiltered || row.Filtered
+		for _, hop := range mh.Hops {
+			cell := htmlMultiCell{Missing: hop == nil}
+			if hop != nil && hop.Responded {
+				cell.Responded = true
+				cell.IP = hop.Address()
+				cell.Hostname = hop.Hostname
+				cell.RTT = formatRTTHTML(hop.AvgRTT, n)
+				cell.RTTClass = rttClass(hop.AvgRTT, f.config)
+			}
+			row.Cells = append(row.Cells, cell)
+		}
+		data.Rows = append(data.Rows, row)
+	}
+
+	var buf bytes.Buffer
+	if err := multiTemplate.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to execute template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,117 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+	"github.com/KilimcininKorOglu/poros/internal/trace/tracetest"
+)
+
+// multiTrace builds an ICMP and a UDP trace of the same path where hop 2
+// answers only UDP, and the UDP trace has one hop more.
+func multiTrace() *trace.MultiResult {
+	return &trace.MultiResult{
+		Target:  "example.com",
+		Methods: []trace.ProbeMethod{trace.ProbeICMP, trace.ProbeUDP},
+		Results: []*trace.TraceResult{
+			tracetest.Result("example.com").Method("icmp").Completed(true).
+				Hop(1, "192.168.1.1", 1).
+				TimeoutHop(2).
+				Hop(3, "203.0.113.5", 12).
+				Build(),
+			tracetest.Result("example.com").Method("udp").Completed(true).
+				Hop(1, "192.168.1.1", 1.5).
+				Hop(2, "198.51.100.2", 5).
+				Hop(3, "198.51.100.3", 9).
+				Hop(4, "203.0.113.5", 13).
+				Build(),
+		},
+	}
+}
+
+func TestTextFormatter_FormatMulti(t *testing.T) {
+	f := NewTextFormatter(Config{})
+	data, err := f.FormatMulti(multiTrace())
+	if err != nil {
+		t.Fatalf("FormatMulti() error = %v", err)
+	}
+	out := string(data)
+
+	lines := strings.Split(out, "\n")
+	var hop2, hop4 string
+	for _, line := range lines {
+		if strings.HasPrefix(line, "  2  ") {
+			hop2 = line
+		}
+		if strings.HasPrefix(line, "  4  ") {
+			hop4 = line
+		}
+	}
+
+	for _, want := range []string{"HOP  ICMP", "UDP", "icmp: complete, 3 hops", "udp:  complete, 4 hops", "! answers some probe methods only"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if !strings.HasPrefix(hop2, "  2  *") || !strings.Contains(hop2, "198.51.100.2") || !strings.HasSuffix(hop2, "  !") {
+		t.Errorf("hop 2 should time out for ICMP, answer UDP and be marked filtered, got %q", hop2)
+	}
+	if !strings.Contains(hop4, "203.0.113.5") || strings.HasSuffix(hop4, "!") {
+		t.Errorf("hop 4 exists only in the UDP trace and is not filtered, got %q", hop4)
+	}
+}
+
+func TestJSONFormatter_FormatMulti(t *testing.T) {
+	data, err := NewJSONFormatter(Config{}).FormatMulti(multiTrace())
+	if err != nil {
+		t.Fatalf("FormatMulti() error = %v", err)
+	}
+
+	var doc []struct {
+		Method string     `json:"method"`
+		Result JSONOutput `json:"result"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("output is not a JSON array: %v", err)
+	}
+	if len(doc) != 2 || doc[0].Method != "icmp" || doc[1].Method != "udp" {
+		t.Fatalf("methods = %+v, want icmp then udp", doc)
+	}
+	if len(doc[1].Result.Hops) != 4 {
+		t.Errorf("udp result has %d hops, want 4", len(doc[1].Result.Hops))
+	}
+}
+
+func TestHTMLFormatter_FormatMulti(t *testing.T) {
+	data, err := NewHTMLFormatter(Config{}).FormatMulti(multiTrace())
+	if err != nil {
+		t.Fatalf("FormatMulti() error = %v", err)
+	}
+	out := string(data)
+
+	for _, want := range []string{"<th>ICMP", "<th>UDP", `class="filtered"`, "198.51.100.2", "udp: complete"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("HTML missing %q", want)
+		}
+	}
+	if n := strings.Count(out, `class="filtered"`); n != 1 {
+		t.Errorf("%d filtered rows, want 1", n)
+	}
+}
+
+func TestWriter_WriteMulti(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewWriterTo(&buf, FormatJSON, Config{}).WriteMulti(multiTrace()); err != nil {
+		t.Fatalf("WriteMulti(json) error = %v", err)
+	}
+	if !strings.HasPrefix(strings.TrimSpace(buf.String()), "[") {
+		t.Errorf("JSON output should be an array, got %q", buf.String())
+	}
+
+	if err := NewWriterTo(&buf, FormatCSV, Config{}).WriteMulti(multiTrace()); err == nil {
+		t.Error("WriteMulti(csv) should fail")
+	}
+}
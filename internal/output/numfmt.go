@@ -0,0 +1,169 @@
+package output
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+)
+
+// RTT units accepted by Config.Units.
+const (
+	UnitsMs   = "ms"
+	UnitsUs   = "us"
+	UnitsAuto = "auto"
+)
+
+// localePattern matches locale names such as "de", "de-DE" or
+// "de_DE.UTF-8".
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}([-_][A-Za-z0-9]{2,8})*(\.[A-Za-z0-9-]+)?(@[A-Za-z0-9]+)?$`)
+
+// commaLanguages write decimals with a comma.
+var commaLanguages = map[string]bool{
+	"af": true, "az": true, "be": true, "bg": true, "ca": true, "cs": true,
+	"da": true, "de": true, "el": true, "es": true, "et": true, "eu": true,
+	"fi": true, "fr": true, "gl": true, "hr": true, "hu": true, "id": true,
+	"is": true, "it": true, "ka": true, "kk": true, "lt": true, "lv": true,
+	"mk": true, "nb": true, "nl": true, "nn": true, "no": true, "pl": true,
+	"pt": true, "ro": true, "ru": true, "sk": true, "sl": true, "sq": true,
+	"sr": true, "sv": true, "tr": true, "uk": true, "vi": true,
+}
+
+// dotRegions are regions that write decimals with a dot although their
+// language usually uses a comma.
+var dotRegions = map[string]bool{
+	"de-ch": true, "it-ch": true, "es-mx": true, "es-us": true,
+}
+
+// ValidateUnits checks a --units value.
+func ValidateUnits(units string) error {
+	switch strings.ToLower(units) {
+	case "", UnitsMs, UnitsUs, "µs", UnitsAuto:
+		return nil
+	}
+	return fmt.Errorf("invalid units %q: want ms, us or auto", units)
+}
+
+// ValidateLocale checks a --locale value. Any well-formed locale name is
+// accepted; languages without a decimal comma keep the dot.
+func ValidateLocale(locale string) error {
+	switch strings.ToUpper(locale) {
+	case "", "C", "POSIX":
+		return nil
+	}
+	if !localePattern.MatchString(locale) {
+		return fmt.Errorf("invalid locale %q: want a name such as en, de-DE or tr_TR.UTF-8", locale)
+	}
+	return nil
+}
+
+// decimalComma reports whether locale writes decimals with a comma.
+func decimalComma(locale string) bool {
+	name := strings.ToLower(locale)
+	if i := strings.IndexAny(name, ".@"); i >= 0 {
+		name = name[:i]
+	}
+	name = strings.ReplaceAll(name, "_", "-")
+
+	parts := strings.Split(name, "-")
+	if len(parts) > 1 && dotRegions[parts[0]+"-"+parts[len(parts)-1]] {
+		return false
+	}
+	return commaLanguages[parts[0]]
+}
+
+// numberFormat renders numbers in human-facing output: RTTs in the
+// configured unit and decimals with the locale's separator. JSON and CSV
+// output stay machine-canonical and never use it.
+type numberFormat struct {
+	micro bool // RTTs in microseconds instead of milliseconds
+	comma bool // decimal comma instead of dot
+}
+
+// numbers returns the number format for hops. With UnitsAuto, RTTs are
+// shown in microseconds when every RTT sample is below 1 ms.
+func (c Config) numbers(hops []trace.Hop) numberFormat {
+	n := numberFormat{comma: decimalComma(c.Locale)}
+
+	switch strings.ToLower(c.Units) {
+	case UnitsUs, "µs":
+		n.micro = true
+	case UnitsAuto:
+		n.micro = allSubMillisecond(hops)
+	}
+	return n
+}
+
+// allSubMillisecond reports whether hops have RTT samples and all of
+// them are below 1 ms.
+func allSubMillisecond(hops []trace.Hop) bool {
+	samples := 0
+	for _, hop := range hops {
+		for _, rtt := range hop.RTTs {
+			if rtt < 0 {
+				continue
+			}
+			if rtt >= 1 {
+				return false
+			}
+			samples++
+		}
+	}
+	return samples > 0
+}
+
+// unit returns the RTT unit label.
+func (n numberFormat) unit() string {
+	if n.micro {
+		return "µs"
+	}
+	return "ms"
+}
+
+// number formats v with prec decimals, e.g. "12.5" or "12,5".
+func (n numberFormat) number(v float64, prec int) string {
+	return n.localize(fmt.Sprintf("%.*f", prec, v))
+}
+
+// signed is number with an explicit sign, e.g. "+12.5".
+func (n numberFormat) signed(v float64, prec int) string {
+	return n.localize(fmt.Sprintf("%+.*f", prec, v))
+}
+
+// localize replaces the decimal point of a formatted number.
+func (n numberFormat) localize(s string) string {
+	if n.comma {
+		return strings.Replace(s, ".", ",", 1)
+	}
+	return s
+}
+
+// rttValue formats an RTT given in milliseconds as a bare number in the
+// display unit with prec decimals of a millisecond; microseconds need
+// three fewer, e.g. 1.27 ms is "1.27" or "1270".
+func (n numberFormat) rttValue(ms float64, prec int) string {
+	if n.micro {
+		return n.number(ms*1000, max(prec-3, 0))
+	}
+	return n.number(ms, prec)
+}
+
+// rtt formats an RTT given in milliseconds with two decimals and its
+// unit, e.g. "12.34 ms".
+func (n numberFormat) rtt(ms float64) string {
+	return n.rttValue(ms, 2) + " " + n.unit()
+}
+
+// rttDelta formats an RTT change with its sign and unit, e.g. "+1.50 ms".
+func (n numberFormat) rttDelta(ms float64) string {
+	if n.micro {
+		return n.signed(ms*1000, 0) + " µs"
+	}
+	return n.signed(ms, 2) + " ms"
+}
+
+// percent formats a percentage with prec decimals, e.g. "33.3%".
+func (n numberFormat) percent(v float64, prec int) string {
+	return n.number(v, prec) + "%"
+}
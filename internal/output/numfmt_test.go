@@ -0,0 +1,273 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+)
+
+func TestValidateUnits(t *testing.T) {
+	tests := []struct {
+		units   string
+		wantErr bool
+	}{
+		{"", false},
+		{"ms", false},
+		{"us", false},
+		{"µs", false},
+		{"AUTO", false},
+		{"s", true},
+		{"ns", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.units, func(t *testing.T) {
+			err := ValidateUnits(tt.units)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUnits(%q) error = %v, wantErr %v", tt.units, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateLocale(t *testing.T) {
+	tests := []struct {
+		locale  string
+		wantErr bool
+	}{
+		{"", false},
+		{"C", false},
+		{"en", false},
+		{"de-DE", false},
+		{"tr_TR.UTF-8", false},
+		{"sr_RS@latin", false},
+		{"1234", true},
+		{"de DE", true},
+		{"x", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			err := ValidateLocale(tt.locale)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateLocale(%q) error = %v, wantErr %v", tt.locale, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDecimalComma(t *testing.T) {
+	tests := []struct {
+		locale string
+		want   bool
+	}{
+		{"", false},
+		{"C", false},
+		{"en", false},
+		{"en_US.UTF-8", false},
+		{"de", true},
+		{"de-DE", true},
+		{"tr_TR.UTF-8", true},
+		{"fr_FR@euro", true},
+		{"de-CH", false},
+		{"ja-JP", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			if got := decimalComma(tt.locale); got != tt.want {
+				t.Errorf("decimalComma(%q) = %v, want %v", tt.locale, got, tt.want)
+			}
+		})
+	}
+}
+
+// subMillisecondResult returns the sample result with every RTT scaled
+// below 1 ms, as on a LAN path.
+func subMillisecondResult() *trace.TraceResult {
+	result := sampleTraceResult()
+	for i := range result.Hops {
+		hop := &result.Hops[i]
+		for j, rtt := range hop.RTTs {
+			if rtt >= 0 {
+				hop.RTTs[j] = rtt / 10
+			}
+		}
+		hop.AvgRTT /= 10
+		hop.MinRTT /= 10
+		hop.MaxRTT /= 10
+		hop.Jitter /= 10
+		hop.LastRTT /= 10
+	}
+	result.Summary.TotalTimeMs /= 10
+	return result
+}
+
+func TestConfig_Numbers_Auto(t *testing.T) {
+	tests := []struct {
+		name      string
+		hops      []trace.Hop
+		wantMicro bool
+	}{
+		{"no hops", nil, false},
+		{"all timeouts", []trace.Hop{{RTTs: []float64{-1, -1}}}, false},
+		{"all below 1 ms", subMillisecondResult().Hops, true},
+		{"one sample at 1 ms", []trace.Hop{{RTTs: []float64{0.4, 1}}}, false},
+		{"mixed", sampleTraceResult().Hops, false},
+	}
+
+	config := Config{Units: UnitsAuto}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := config.numbers(tt.hops).micro; got != tt.wantMicro {
+				t.Errorf("numbers().micro = %v, want %v", got, tt.wantMicro)
+			}
+		})
+	}
+}
+
+func TestNumberFormat(t *testing.T) {
+	tests := []struct {
+		name      string
+		n         numberFormat
+		wantRTT   string
+		wantDelta string
+		wantLoss  string
+	}{
+		{"ms dot", numberFormat{}, "1.27 ms", "-0.50 ms", "33.3%"},
+		{"ms comma", numberFormat{comma: true}, "1,27 ms", "-0,50 ms", "33,3%"},
+		{"us dot", numberFormat{micro: true}, "1271 µs", "-500 µs", "33.3%"},
+		{"us comma", numberFormat{micro: true, comma: true}, "1271 µs", "-500 µs", "33,3%"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.n.rtt(1.271); got != tt.wantRTT {
+				t.Errorf("rtt() = %q, want %q", got, tt.wantRTT)
+			}
+			if got := tt.n.rttDelta(-0.5); got != tt.wantDelta {
+				t.Errorf("rttDelta() = %q, want %q", got, tt.wantDelta)
+			}
+			if got := tt.n.percent(33.33, 1); got != tt.wantLoss {
+				t.Errorf("percent() = %q, want %q", got, tt.wantLoss)
+			}
+		})
+	}
+}
+
+func TestFormatters_UnitsAndLocale(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		result func() *trace.TraceResult
+
+		// wantText, wantTable and wantHTML must appear in the output
+		wantText  []string
+		wantTable []string
+		wantHTML  []string
+	}{
+		{
+			name:      "ms en",
+			config:    Config{Units: UnitsMs, Locale: "en-US"},
+			result:    sampleTraceResult,
+			wantText:  []string{"   1.23 ms", "5.55 ms total"},
+			wantTable: []string{" 1.27 ", "Total Time:    5.55 ms", "Packet Loss:   44.4%"},
+			wantHTML:  []string{"1.27 ms", "Samples: 1.2 / 1.5 / 1.1 ms"},
+		},
+		{
+			name:      "ms de",
+			config:    Config{Units: UnitsMs, Locale: "de-DE"},
+			result:    sampleTraceResult,
+			wantText:  []string{"   1,23 ms", "5,55 ms total"},
+			wantTable: []string{" 1,27 ", "Total Time:    5,55 ms", "Packet Loss:   44,4%"},
+			wantHTML:  []string{"1,27 ms", "Samples: 1,2 / 1,5 / 1,1 ms"},
+		},
+		{
+			name:      "us en",
+			config:    Config{Units: UnitsUs, Locale: "en"},
+			result:    sampleTraceResult,
+			wantText:  []string{"   1234 µs", "5555 µs total"},
+			wantTable: []string{"AVG (µs)", " 1271 ", "Total Time:    5555 µs"},
+			wantHTML:  []string{"1271 µs", "Samples: 1234 / 1456 / 1123 µs"},
+		},
+		{
+			name:      "us de",
+			config:    Config{Units: UnitsUs, Locale: "de"},
+			result:    sampleTraceResult,
+			wantText:  []string{"   1234 µs", "5555 µs total"},
+			wantTable: []string{"AVG (µs)", " 1271 ", "Packet Loss:   44,4%"},
+			wantHTML:  []string{"1271 µs"},
+		},
+		{
+			name:      "auto with millisecond RTTs",
+			config:    Config{Units: UnitsAuto},
+			result:    sampleTraceResult,
+			wantText:  []string{"   1.23 ms"},
+			wantTable: []string{" 1.27 ", "Total Time:    5.55 ms"},
+			wantHTML:  []string{"1.27 ms"},
+		},
+		{
+			name:      "auto with sub-millisecond RTTs",
+			config:    Config{Units: UnitsAuto, Locale: "de-DE"},
+			result:    subMillisecondResult,
+			wantText:  []string{"    123 µs", "556 µs total"},
+			wantTable: []string{"AVG (µs)", " 127 ", "Packet Loss:   44,4%"},
+			wantHTML:  []string{"127 µs"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := func(format string, data []byte, err error, want []string) {
+				t.Helper()
+				if err != nil {
+					t.Fatalf("%s Format() error = %v", format, err)
+				}
+				for _, s := range want {
+					if !bytes.Contains(data, []byte(s)) {
+						t.Errorf("%s output missing %q:\n%s", format, s, data)
+					}
+				}
+			}
+
+			data, err := NewTextFormatter(tt.config).Format(tt.result())
+			check("text", data, err, tt.wantText)
+			data, err = NewTableFormatter(tt.config).Format(tt.result())
+			check("table", data, err, tt.wantTable)
+			data, err = NewHTMLFormatter(tt.config).Format(tt.result())
+			check("HTML", data, err, tt.wantHTML)
+		})
+	}
+}
+
+func TestFormatters_UnitsAndLocale_MachineOutput(t *testing.T) {
+	localized := Config{Units: UnitsUs, Locale: "de-DE"}
+	result := subMillisecondResult()
+
+	for _, tt := range []struct {
+		name   string
+		format func(Config) ([]byte, error)
+	}{
+		{"json", func(c Config) ([]byte, error) { return NewJSONFormatter(c).Format(result) }},
+		{"csv", func(c Config) ([]byte, error) { return NewCSVFormatter(c).Format(result) }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := tt.format(Config{})
+			if err != nil {
+				t.Fatalf("Format() error = %v", err)
+			}
+			got, err := tt.format(localized)
+			if err != nil {
+				t.Fatalf("Format() error = %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s output changed with units and locale:\n%s", tt.name, got)
+			}
+			if strings.Contains(string(got), "µs") {
+				t.Errorf("%s output contains a display unit", tt.name)
+			}
+		})
+	}
+}
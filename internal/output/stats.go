@@ -0,0 +1,43 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/KilimcininKorOglu/poros/internal/probe"
+)
+
+// FormatProbeStats renders prober counters as a plain-text block for
+// end-of-run diagnostics.
+func FormatProbeStats(stats *probe.Stats) string {
+	if stats == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	if stats.Method != "" {
+		fmt.Fprintf(&sb, "Probe statistics (%s):\n", stats.Method)
+	} else {
+		sb.WriteString("Probe statistics:\n")
+	}
+
+	fmt.Fprintf(&sb, "  Sent:             %d\n", stats.Sent)
+	fmt.Fprintf(&sb, "  Received:         %d\n", stats.Received)
+	fmt.Fprintf(&sb, "  Duplicates:       %d\n", stats.Duplicates)
+
+	discarded := fmt.Sprintf("%d", stats.TotalDiscarded())
+	if reasons := stats.DiscardReasons(); len(reasons) > 0 {
+		parts := make([]string, len(reasons))
+		for i, reason := range reasons {
+			parts[i] = fmt.Sprintf("%s %d", reason, stats.Discarded[reason])
+		}
+		discarded += " (" + strings.Join(parts, ", ") + ")"
+	}
+	fmt.Fprintf(&sb, "  Discarded:        %s\n", discarded)
+
+	fmt.Fprintf(&sb, "  Timeouts:         %d\n", stats.Timeouts)
+	fmt.Fprintf(&sb, "  Retransmissions:  %d\n", stats.Retransmissions)
+	fmt.Fprintf(&sb, "  Socket errors:    %d\n", stats.SocketErrors)
+
+	return sb.String()
+}
@@ -11,8 +11,20 @@ import (
 
 // TableFormatter formats trace results as a detailed table.
 type TableFormatter struct {
-	config Config
-	colors *ColorScheme
+	config  Config
+	colors  *ColorScheme
+	columns []tableColumn
+
+	// customColumns is set when the columns were chosen explicitly;
+	// otherwise the iface column is added for hops that report one
+	customColumns bool
+
+	// nums is the number format of the result being formatted
+	nums numberFormat
+
+	// size reports the terminal width the table is fitted to (nil =
+	// unknown unless Config.Width is set)
+	size SizeProvider
 }
 
 // NewTableFormatter creates a new table formatter.
@@ -22,32 +34,75 @@ func NewTableFormatter(config Config) *TableFormatter {
 		colors = DefaultColorScheme()
 	}
 
-	return &TableFormatter{
+	f := &TableFormatter{
 		config: config,
 		colors: colors,
 	}
+	f.columns = f.defaultColumns()
+	if len(config.Columns) > 0 {
+		// Invalid lists are rejected by ParseTableColumns before this
+		// point; fall back to the defaults if one slips through.
+		_ = f.SetColumns(config.Columns)
+	}
+	return f
+}
+
+// SetSizeProvider implements SizeAware. The width is measured each time a
+// table is formatted.
+func (f *TableFormatter) SetSizeProvider(size SizeProvider) {
+	f.size = size
 }
 
 // Format formats the trace result as a detailed table.
 func (f *TableFormatter) Format(result *trace.TraceResult) ([]byte, error) {
 	var buf bytes.Buffer
 
+	if !f.customColumns && hasInterfaceInfo(result.Hops) {
+		f = f.withColumnAfter("hostname", "iface")
+	}
+	if !f.customColumns && result.Rounds > 0 {
+		f = f.withWatchColumns()
+	}
+	f = f.withNumbers(f.config.numbers(result.Hops))
+
 	// Header information
 	f.writeHeader(&buf, result)
 
+	// A one-row table says less than a sentence
+	if hop := DirectHop(result); hop != nil {
+		f.writeDirect(&buf, result, hop)
+		return buf.Bytes(), nil
+	}
+
 	// Create table
 	table := tablewriter.NewWriter(&buf)
 	f.configureTable(table)
 
-	// Add header row
+	// Add header row. Headers are upper-cased here rather than by the
+	// table so that the unit suffix keeps its case.
 	headers := f.getHeaders()
+	for i, col := range f.columns {
+		headers[i] = strings.ToUpper(headers[i])
+		if col.RTT && f.nums.micro {
+			headers[i] += " (" + f.nums.unit() + ")"
+		}
+	}
 	table.SetHeader(headers)
 
-	// Add data rows
-	for _, hop := range result.Hops {
-		row := f.formatHopRow(&hop)
-		table.Append(row)
+	// Collapsed private prefix, then the hops
+	var rows [][]string
+	if result.Skipped != nil {
+		rows = append(rows, f.formatSkippedRow(result.Skipped))
+	}
+	for _, group := range groupHops(result.Hops, f.config.CollapseTimeouts) {
+		if len(group) > 1 {
+			rows = append(rows, f.formatTimeoutRunRow(group))
+		} else {
+			rows = append(rows, f.formatHopRow(&group[0]))
+		}
 	}
+	f.fitRows(headers, rows)
+	table.AppendBulk(rows)
 
 	table.Render()
 
@@ -59,10 +114,21 @@ func (f *TableFormatter) Format(result *trace.TraceResult) ([]byte, error) {
 
 // writeHeader writes the trace header information.
 func (f *TableFormatter) writeHeader(buf *bytes.Buffer, result *trace.TraceResult) {
-	header := fmt.Sprintf("Target: %s (%s)\n", result.Target, result.ResolvedIP)
-	header += fmt.Sprintf("Method: %s | Time: %s\n\n",
-		strings.ToUpper(result.ProbeMethod),
-		result.Timestamp.Format("2006-01-02 15:04:05"))
+	header := fmt.Sprintf("Target: %s (%s)", targetLabel(result), resolvedLabel(result))
+	if result.TranslatedVia != "" {
+		header += " via " + result.TranslatedVia
+	}
+	header += "\n"
+	header += fmt.Sprintf("Method: %s | Time: %s\n",
+		formatProbeMethod(result),
+		f.config.FormatTime(result.Timestamp, "2006-01-02 15:04:05"))
+	if result.Meta != nil {
+		header += formatMetaLine(result.Meta)
+	}
+	if hops := partialPathRange(result); hops != "" {
+		header += fmt.Sprintf("Range: %s (partial path, destination not probed)\n", hops)
+	}
+	header += "\n"
 
 	if f.colors != nil {
 		header = f.colors.Header.Sprint(header)
@@ -70,12 +136,99 @@ func (f *TableFormatter) writeHeader(buf *bytes.Buffer, result *trace.TraceResul
 	buf.WriteString(header)
 }
 
+// formatProbeMethod formats the probe method with the socket kind and
+// mode when known, e.g. "ICMP (raw socket, sequential)". Concurrent mode
+// includes the probes in flight, e.g. "UDP (raw socket, concurrent x30)".
+func formatProbeMethod(result *trace.TraceResult) string {
+	method := strings.ToUpper(result.ProbeMethod)
+
+	var details []string
+	if result.ProbeSocket != "" {
+		details = append(details, result.ProbeSocket+" socket")
+	}
+	if result.Mode != "" {
+		mode := result.Mode
+		if result.Concurrency > 0 {
+			mode += fmt.Sprintf(" x%d", result.Concurrency)
+		}
+		details = append(details, mode)
+	}
+	if len(details) > 0 {
+		method += " (" + strings.Join(details, ", ") + ")"
+	}
+	return method
+}
+
+// partialPathRange returns the probed range of a partial-path trace, e.g.
+// "hops 5-9", or an empty string if the trace was not limited by LastHop.
+func partialPathRange(result *trace.TraceResult) string {
+	if result.StoppedReason != trace.StopLastHop {
+		return ""
+	}
+
+	first, last := 0, 0
+	if result.Meta != nil {
+		first, last = result.Meta.FirstHop, result.Meta.LastHop
+	}
+	if len(result.Hops) > 0 {
+		if first == 0 {
+			first = result.Hops[0].Number
+		}
+		if last == 0 {
+			last = result.Hops[len(result.Hops)-1].Number
+		}
+	}
+
+	if first == last {
+		return fmt.Sprintf("hop %d", first)
+	}
+	return fmt.Sprintf("hops %d-%d", first, last)
+}
+
+// formatMetaLine formats run metadata as a single header line.
+func formatMetaLine(meta *trace.Meta) string {
+	var parts []string
+
+	if meta.Hostname != "" {
+		host := "Host: " + meta.Hostname
+		if meta.OS != "" {
+			host += fmt.Sprintf(" (%s/%s)", meta.OS, meta.Arch)
+		}
+		parts = append(parts, host)
+	}
+
+	if meta.SourceIP != nil {
+		source := "Source: " + meta.SourceIP.String()
+		if meta.Interface != "" {
+			source += fmt.Sprintf(" (%s)", meta.Interface)
+		}
+		parts = append(parts, source)
+	}
+
+	if meta.ResolvePolicy != "" {
+		dns := "DNS: " + meta.ResolvePolicy
+		if meta.PinnedIP != nil {
+			dns += fmt.Sprintf(" (pinned %s)", meta.PinnedIP)
+		}
+		parts = append(parts, dns)
+	}
+
+	if meta.Version != "" {
+		parts = append(parts, "Poros: "+meta.Version)
+	}
+
+	if len(parts) == 0 {
+		return ""
+	}
+	return strings.Join(parts, " | ") + "\n"
+}
+
 // configureTable sets up the table appearance.
 func (f *TableFormatter) configureTable(table *tablewriter.Table) {
 	table.SetBorder(true)
 	table.SetRowLine(false)
 	table.SetAutoWrapText(false)
-	table.SetAutoFormatHeaders(true)
+	table.SetAutoFormatHeaders(false)
 	table.SetHeaderAlignment(tablewriter.ALIGN_CENTER)
 	table.SetAlignment(tablewriter.ALIGN_LEFT)
 	table.SetCenterSeparator("│")
@@ -85,71 +238,75 @@ func (f *TableFormatter) configureTable(table *tablewriter.Table) {
 	table.SetTablePadding(" ")
 }
 
-// getHeaders returns the column headers.
-func (f *TableFormatter) getHeaders() []string {
-	headers := []string{"Hop", "IP Address", "Hostname"}
+// Narrowest the hostname column is shrunk to when fitting a table.
+const tableHostnameMin = 10
 
-	if !f.config.NoASN {
-		headers = append(headers, "ASN", "Organization")
+// fitRows shrinks the hostname column so the table fits the terminal
+// width measured now. Other columns keep their width; a table that cannot
+// fit is left wider than the terminal.
+func (f *TableFormatter) fitRows(headers []string, rows [][]string) {
+	width := resolveWidth(f.config, f.size)
+	if width <= 0 {
+		return
 	}
 
-	if !f.config.NoGeoIP {
-		headers = append(headers, "Location")
+	host := -1
+	for i, col := range f.columns {
+		if col.Name == "hostname" {
+			host = i
+		}
 	}
-
-	headers = append(headers, "Avg", "Min", "Max", "Loss")
-	return headers
-}
-
-// formatHopRow formats a single hop as a table row.
-func (f *TableFormatter) formatHopRow(hop *trace.Hop) []string {
-	row := []string{
-		fmt.Sprintf("%d", hop.Number),
+	if host < 0 {
+		return
 	}
 
-	// IP and Hostname
-	if !hop.Responded {
-		row = append(row, "*", "-")
-	} else {
-		row = append(row, hop.IP.String(), truncateString(hop.Hostname, 25))
+	// Each cell is padded by a space on both sides plus one separator
+	widths := make([]int, len(headers))
+	for i, h := range headers {
+		widths[i] = tablewriter.DisplayWidth(h)
 	}
-
-	// ASN
-	if !f.config.NoASN {
-		if hop.ASN != nil {
-			row = append(row,
-				fmt.Sprintf("%d", hop.ASN.Number),
-				truncateString(hop.ASN.Org, 20))
-		} else {
-			row = append(row, "-", "-")
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], tablewriter.DisplayWidth(cell))
 		}
 	}
+	total := 1
+	for _, w := range widths {
+		total += w + 3
+	}
+	if total <= width {
+		return
+	}
 
-	// GeoIP
-	if !f.config.NoGeoIP {
-		if hop.Geo != nil {
-			location := hop.Geo.CountryCode
-			if hop.Geo.City != "" {
-				location = fmt.Sprintf("%s, %s", hop.Geo.City, hop.Geo.CountryCode)
-			}
-			row = append(row, truncateString(location, 20))
-		} else {
-			row = append(row, "-")
-		}
+	fit := max(tableHostnameMin, widths[host]-(total-width))
+	for _, row := range rows {
+		row[host] = truncateHostname(row[host], fit)
 	}
+}
 
-	// RTT stats
-	if hop.Responded && hop.AvgRTT > 0 {
-		row = append(row,
-			f.formatRTT(hop.AvgRTT),
-			f.formatRTT(hop.MinRTT),
-			f.formatRTT(hop.MaxRTT),
-			fmt.Sprintf("%.0f%%", hop.LossPercent))
-	} else {
-		row = append(row, "-", "-", "-", "-")
+// withNumbers returns a copy of the formatter using the number format n.
+func (f *TableFormatter) withNumbers(n numberFormat) *TableFormatter {
+	copied := *f
+	copied.nums = n
+	return &copied
+}
+
+// formatRTTSamples renders individual probe RTTs as "12.1 / 13.4 / *",
+// with timed-out probes shown as "*".
+func formatRTTSamples(rtts []float64, n numberFormat) string {
+	if len(rtts) == 0 {
+		return "-"
 	}
 
-	return row
+	samples := make([]string, len(rtts))
+	for i, rtt := range rtts {
+		if rtt < 0 {
+			samples[i] = "*"
+		} else {
+			samples[i] = n.rttValue(rtt, 1)
+		}
+	}
+	return strings.Join(samples, " / ")
 }
 
 // formatRTT formats an RTT value with optional coloring.
@@ -158,16 +315,23 @@ func (f *TableFormatter) formatRTT(rtt float64) string {
 		return "-"
 	}
 
-	str := fmt.Sprintf("%.2f", rtt)
+	str := f.nums.rttValue(rtt, 2)
 
 	if f.colors != nil {
-		switch {
-		case rtt < 50:
-			str = f.colors.RTTLow.Sprint(str)
-		case rtt < 150:
-			str = f.colors.RTTMed.Sprint(str)
-		default:
-			str = f.colors.RTTHigh.Sprint(str)
+		str = f.colors.rttColor(f.config.ClassifyRTT(rtt)).Sprint(str)
+	}
+
+	return str
+}
+
+// formatLoss formats a loss percentage with optional coloring.
+// Loss above the critical threshold is also shown in bold.
+func (f *TableFormatter) formatLoss(loss float64) string {
+	str := f.nums.percent(loss, 0)
+
+	if f.colors != nil {
+		if c := f.colors.lossColor(f.config.ClassifyLoss(loss)); c != nil {
+			str = c.Sprint(str)
 		}
 	}
 
@@ -188,8 +352,17 @@ func (f *TableFormatter) writeSummary(buf *bytes.Buffer, result *trace.TraceResu
 
 	fmt.Fprintf(buf, "  Total Hops:    %d\n", result.Summary.TotalHops)
 	fmt.Fprintf(buf, "  Responding:    %d\n", responding)
-	fmt.Fprintf(buf, "  Total Time:    %.2f ms\n", result.Summary.TotalTimeMs)
-	fmt.Fprintf(buf, "  Packet Loss:   %.1f%%\n", result.Summary.PacketLossPercent)
+	fmt.Fprintf(buf, "  Total Time:    %s\n", f.nums.rtt(result.Summary.TotalTimeMs))
+	fmt.Fprintf(buf, "  Packet Loss:   %s\n", f.nums.percent(result.Summary.PacketLossPercent, 1))
+	if result.Rounds > 0 {
+		fmt.Fprintf(buf, "  Rounds:        %d\n", result.Rounds)
+	}
+	if result.Summary.DestinationProbes > 0 {
+		fmt.Fprintf(buf, "  Destination:   %s\n", formatDestinationCheck(result.Summary, f.nums))
+	}
+	if transit := result.Summary.LastTransitHop; transit != nil && !f.config.NoASN {
+		fmt.Fprintf(buf, "  Last Transit:  %s\n", formatTransitHop(transit, f.nums))
+	}
 
 	if result.Completed {
 		buf.WriteString("  Status:        ")
@@ -202,12 +375,97 @@ func (f *TableFormatter) writeSummary(buf *bytes.Buffer, result *trace.TraceResu
 	} else {
 		buf.WriteString("  Status:        ")
 		status := "Incomplete"
+		if hops := partialPathRange(result); hops != "" {
+			status = "Partial (" + hops + ")"
+		}
 		if f.colors != nil {
 			status = f.colors.RTTHigh.Sprint(status)
 		}
 		buf.WriteString(status)
 		buf.WriteString("\n")
 	}
+
+	if legend := sourceLegend(result.Hops); legend != "" && f.showsSources() {
+		fmt.Fprintf(buf, "  Sources:       %s\n", legend)
+	}
+
+	if len(result.Notes) > 0 {
+		buf.WriteString("\nNotes:\n")
+		for _, note := range result.Notes {
+			fmt.Fprintf(buf, "  - %s\n", note)
+		}
+	}
+
+	if len(result.Summary.PerAS) > 0 && !f.config.NoASN {
+		buf.WriteString("\nPer-AS Latency:\n")
+		for _, c := range result.Summary.PerAS {
+			fmt.Fprintf(buf, "  %-9s %-20s %s\n",
+				fmt.Sprintf("AS%d", c.ASN), truncateString(c.Org, 20), formatASDelta(c, f.nums))
+		}
+	}
+}
+
+// writeDirect writes a trace that reached its destination at hop 1 in
+// place of the table and summary.
+func (f *TableFormatter) writeDirect(buf *bytes.Buffer, result *trace.TraceResult, hop *trace.Hop) {
+	buf.WriteString(formatDirect(hop, f.nums) + "\n")
+	address := hop.Address()
+	if !f.config.NoHostname {
+		address = directAddress(hop)
+	}
+	fmt.Fprintf(buf, "  Address:       %s\n", address)
+	if hop.ASN != nil && !f.config.NoASN {
+		fmt.Fprintf(buf, "  ASN:           %s\n", strings.TrimSpace(fmt.Sprintf("AS%d %s", hop.ASN.Number, hop.ASN.Org)))
+	}
+	if result.Summary.DestinationProbes > 0 {
+		fmt.Fprintf(buf, "  Destination:   %s\n", formatDestinationCheck(result.Summary, f.nums))
+	}
+	status := "Complete"
+	if f.colors != nil {
+		status = f.colors.RTTLow.Sprint(status)
+	}
+	fmt.Fprintf(buf, "  Status:        %s\n", status)
+
+	if len(result.Notes) > 0 {
+		buf.WriteString("\nNotes:\n")
+		for _, note := range result.Notes {
+			fmt.Fprintf(buf, "  - %s\n", note)
+		}
+	}
+}
+
+// formatDestinationCheck formats the end-host probe results, e.g.
+// "0.0% loss, 12.34 ms avg (10 end-host probes)".
+func formatDestinationCheck(summary trace.Summary, n numberFormat) string {
+	rtt := "no replies"
+	if summary.DestinationAvgRTT > 0 {
+		rtt = n.rtt(summary.DestinationAvgRTT) + " avg"
+	}
+	return fmt.Sprintf("%s loss, %s (%d end-host probes)",
+		n.percent(summary.DestinationLossPercent, 1), rtt, summary.DestinationProbes)
+}
+
+// formatTransitHop formats the last hop before the destination AS, e.g.
+// "hop 7, 203.0.113.1 (AS3356 LEVEL3), 12.34 ms".
+func formatTransitHop(hop *trace.TransitHop, n numberFormat) string {
+	as := fmt.Sprintf("AS%d", hop.ASN)
+	if hop.Org != "" {
+		as += " " + hop.Org
+	}
+	return fmt.Sprintf("hop %d, %s (%s), %s", hop.Number, hop.IP, as, n.rtt(hop.RTTMs))
+}
+
+// formatASDelta formats an AS latency contribution, e.g.
+// "+60.20 ms (hops 4-7)". Clamped RTT inversions are marked.
+func formatASDelta(c trace.ASContribution, n numberFormat) string {
+	hops := fmt.Sprintf("hop %d", c.FirstHop)
+	if c.LastHop != c.FirstHop {
+		hops = fmt.Sprintf("hops %d-%d", c.FirstHop, c.LastHop)
+	}
+	if c.Clamped {
+		hops += ", RTT inversion"
+	}
+	return fmt.Sprintf("%s (%s)", n.rttDelta(c.DeltaMs), hops)
 }
 
 // ContentType returns the MIME type for table output.
@@ -219,3 +477,62 @@ func (f *TableFormatter) ContentType() string {
 func (f *TableFormatter) FileExtension() string {
 	return "txt"
 }
+
+// sourceMarkers maps enrichment sources to the marker shown after ASN
+// and location values in the table; see the legend in writeSummary.
+var sourceMarkers = []struct {
+	source string
+	marker string
+}{
+	{"maxmind", "ᵐ"},
+	{"cymru", "ᶜ"},
+	{"ip-api", "ⁱ"},
+}
+
+// sourceMarker returns the marker of an enrichment source, or an empty
+// string for unknown sources.
+func sourceMarker(source string) string {
+	for _, s := range sourceMarkers {
+		if s.source == source {
+			return s.marker
+		}
+	}
+	return ""
+}
+
+// sourceLegend explains the source markers used by the hops, e.g.
+// "ᶜ cymru, ⁱ ip-api", or returns an empty string if none is used.
+func sourceLegend(hops []trace.Hop) string {
+	used := make(map[string]bool)
+	for _, hop := range hops {
+		if hop.ASN != nil {
+			used[hop.ASN.Source] = true
+		}
+		if hop.Geo != nil {
+			used[hop.Geo.Source] = true
+		}
+	}
+	var parts []string
+	for _, s := range sourceMarkers {
+		if used[s.source] {
+			parts = append(parts, s.marker+" "+s.source)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// geoTags returns short markers for hosting, mobile and proxy addresses,
+// e.g. "[host]", or an empty string when no flag is set.
+func geoTags(geo *trace.GeoInfo) string {
+	var tags []string
+	if geo.Hosting {
+		tags = append(tags, "[host]")
+	}
+	if geo.Mobile {
+		tags = append(tags, "[mob]")
+	}
+	if geo.Proxy {
+		tags = append(tags, "[proxy]")
+	}
+	return strings.Join(tags, "")
+}
@@ -0,0 +1,467 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+)
+
+// tableColumn describes a column of the verbose table.
+type tableColumn struct {
+	// Name is the identifier used with --columns
+	Name string
+
+	// Header is the column title
+	Header string
+
+	// Width truncates plain values to this many characters (0 = no limit)
+	Width int
+
+	// RTT marks columns holding RTTs; their header names the unit when
+	// it is not milliseconds
+	RTT bool
+
+	// Value extracts the cell for a hop
+	Value func(f *TableFormatter, hop *trace.Hop) string
+
+	// Skipped extracts the cell for a collapsed private prefix (nil = "-")
+	Skipped func(f *TableFormatter, skipped *trace.SkippedHops) string
+}
+
+// tableColumns is the registry of available table columns. New per-hop
+// fields are exposed by adding an entry here.
+var tableColumns = []tableColumn{
+	{
+		Name:   "hop",
+		Header: "Hop",
+		Value: func(f *TableFormatter, hop *trace.Hop) string {
+			return fmt.Sprintf("%d", hop.Number)
+		},
+		Skipped: func(f *TableFormatter, skipped *trace.SkippedHops) string {
+			return skippedRange(skipped)
+		},
+	},
+	{
+		Name:   "ip",
+		Header: "IP Address",
+		Value: func(f *TableFormatter, hop *trace.Hop) string {
+			if !hop.Responded {
+				return "*"
+			}
+			return hop.Address()
+		},
+		Skipped: func(f *TableFormatter, skipped *trace.SkippedHops) string {
+			return "private network"
+		},
+	},
+	{
+		Name:   "hostname",
+		Header: "Hostname",
+		Width:  25,
+		Value: func(f *TableFormatter, hop *trace.Hop) string {
+			if !hop.Responded {
+				return "-"
+			}
+			return hostnameLabel(hop)
+		},
+	},
+	{
+		Name:   "iface",
+		Header: "Interface",
+		Width:  24,
+		Value: func(f *TableFormatter, hop *trace.Hop) string {
+			if hop.Interface == nil {
+				return "-"
+			}
+			label := hop.Interface.Label()
+			if hop.Interface.MTU > 0 {
+				label += fmt.Sprintf(" mtu %d", hop.Interface.MTU)
+			}
+			return label
+		},
+	},
+	{
+		Name:   "asn",
+		Header: "ASN",
+		Value: func(f *TableFormatter, hop *trace.Hop) string {
+			if hop.ASN == nil {
+				return "-"
+			}
+			return fmt.Sprintf("%d", hop.ASN.Number) + sourceMarker(hop.ASN.Source)
+		},
+	},
+	{
+		Name:   "org",
+		Header: "Organization",
+		Width:  20,
+		Value: func(f *TableFormatter, hop *trace.Hop) string {
+			if hop.ASN == nil {
+				return "-"
+			}
+			return hop.ASN.Org
+		},
+	},
+	{
+		Name:   "location",
+		Header: "Location",
+		Value: func(f *TableFormatter, hop *trace.Hop) string {
+			location := f.config.Location(hop.Geo)
+			if location == "" {
+				return "-"
+			}
+			width := 20
+			if f.config.CountryNames {
+				width = 30
+			}
+			// The region is dropped before the location is truncated
+			if len(location) > width {
+				location = formatLocation(hop.Geo, f.config.CountryNames, false)
+			}
+			location = truncateString(location, width) + sourceMarker(hop.Geo.Source)
+			if tags := geoTags(hop.Geo); tags != "" {
+				location += " " + tags
+			}
+			return location
+		},
+	},
+	{
+		Name:   "isp",
+		Header: "ISP",
+		Width:  20,
+		Value: func(f *TableFormatter, hop *trace.Hop) string {
+			if hop.Geo == nil || hop.Geo.ISP == "" {
+				return "-"
+			}
+			return hop.Geo.ISP
+		},
+	},
+	{
+		Name:   "last",
+		Header: "Last",
+		RTT:    true,
+		Value: func(f *TableFormatter, hop *trace.Hop) string {
+			if !hop.Responded {
+				return "-"
+			}
+			if hop.LastRTT < 0 {
+				return "*"
+			}
+			return f.formatRTT(hop.LastRTT)
+		},
+	},
+	{
+		Name:   "avg",
+		Header: "Avg",
+		RTT:    true,
+		Value: func(f *TableFormatter, hop *trace.Hop) string {
+			return f.hopRTT(hop, hop.AvgRTT)
+		},
+		Skipped: func(f *TableFormatter, skipped *trace.SkippedHops) string {
+			return f.formatRTT(skipped.RTT)
+		},
+	},
+	{
+		Name:   "min",
+		Header: "Min",
+		RTT:    true,
+		Value: func(f *TableFormatter, hop *trace.Hop) string {
+			return f.hopRTT(hop, hop.MinRTT)
+		},
+	},
+	{
+		Name:   "max",
+		Header: "Max",
+		RTT:    true,
+		Value: func(f *TableFormatter, hop *trace.Hop) string {
+			return f.hopRTT(hop, hop.MaxRTT)
+		},
+	},
+	{
+		Name:   "jitter",
+		Header: "Jitter",
+		RTT:    true,
+		Value: func(f *TableFormatter, hop *trace.Hop) string {
+			if !hop.Responded || hop.AvgRTT <= 0 {
+				return "-"
+			}
+			return f.nums.rttValue(hop.Jitter, 2)
+		},
+	},
+	{
+		Name:   "loss",
+		Header: "Loss",
+		Value: func(f *TableFormatter, hop *trace.Hop) string {
+			if !hop.Responded || hop.AvgRTT <= 0 {
+				return "-"
+			}
+			return f.formatLoss(hop.LossPercent)
+		},
+	},
+	{
+		Name:   "sent",
+		Header: "Snt",
+		Value: func(f *TableFormatter, hop *trace.Hop) string {
+			if hop.Sent == 0 {
+				return "-"
+			}
+			return fmt.Sprintf("%d", hop.Sent)
+		},
+	},
+	{
+		Name:   "stddev",
+		Header: "StDev",
+		RTT:    true,
+		Value: func(f *TableFormatter, hop *trace.Hop) string {
+			if hop.Received < 2 {
+				return "-"
+			}
+			return f.nums.rttValue(hop.StdDev, 2)
+		},
+	},
+	{
+		Name:   "outliers",
+		Header: "Outliers",
+		Value: func(f *TableFormatter, hop *trace.Hop) string {
+			if hop.Outliers == 0 {
+				return "-"
+			}
+			return fmt.Sprintf("%d", hop.Outliers)
+		},
+	},
+	{
+		Name:   "samples",
+		Header: "RTT Samples",
+		RTT:    true,
+		Value: func(f *TableFormatter, hop *trace.Hop) string {
+			return formatRTTSamples(hop.RTTs, f.nums)
+		},
+	},
+}
+
+// defaultTableColumns is the column set used when none is configured.
+// The hostname column is dropped with NoHostname, asn and org with NoASN,
+// location with NoGeoIP.
+var defaultTableColumns = []string{
+	"hop", "ip", "hostname", "asn", "org", "location",
+	"last", "avg", "min", "max", "loss", "samples",
+}
+
+// TableColumnNames returns the names of all available table columns.
+func TableColumnNames() []string {
+	names := make([]string, len(tableColumns))
+	for i, col := range tableColumns {
+		names[i] = col.Name
+	}
+	return names
+}
+
+// lookupTableColumn finds a registered column by name.
+func lookupTableColumn(name string) (tableColumn, bool) {
+	for _, col := range tableColumns {
+		if col.Name == name {
+			return col, true
+		}
+	}
+	return tableColumn{}, false
+}
+
+// ParseTableColumns parses a comma-separated column list such as
+// "hop,ip,asn,avg,loss". Names are case-insensitive; unknown and
+// duplicate names are rejected.
+func ParseTableColumns(spec string) ([]string, error) {
+	var names []string
+	seen := make(map[string]bool)
+
+	for _, part := range strings.Split(spec, ",") {
+		name := strings.ToLower(strings.TrimSpace(part))
+		if name == "" {
+			continue
+		}
+		if _, ok := lookupTableColumn(name); !ok {
+			return nil, fmt.Errorf("unknown column %q (available: %s)",
+				name, strings.Join(TableColumnNames(), ", "))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("column %q listed more than once", name)
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no columns given (available: %s)",
+			strings.Join(TableColumnNames(), ", "))
+	}
+	return names, nil
+}
+
+// SetColumns selects and orders the table columns. Unknown names are
+// rejected and the current columns are kept.
+func (f *TableFormatter) SetColumns(names []string) error {
+	columns := make([]tableColumn, 0, len(names))
+	for _, name := range names {
+		col, ok := lookupTableColumn(strings.ToLower(name))
+		if !ok {
+			return fmt.Errorf("unknown column %q (available: %s)",
+				name, strings.Join(TableColumnNames(), ", "))
+		}
+		columns = append(columns, col)
+	}
+	f.columns = columns
+	f.customColumns = true
+	return nil
+}
+
+// withColumnAfter returns a copy of the formatter with the named column
+// inserted after another one (or appended if that one is not shown).
+func (f *TableFormatter) withColumnAfter(after, name string) *TableFormatter {
+	col, _ := lookupTableColumn(name)
+
+	columns := make([]tableColumn, 0, len(f.columns)+1)
+	inserted := false
+	for _, c := range f.columns {
+		columns = append(columns, c)
+		if c.Name == after {
+			columns = append(columns, col)
+			inserted = true
+		}
+	}
+	if !inserted {
+		columns = append(columns, col)
+	}
+
+	copied := *f
+	copied.columns = columns
+	return &copied
+}
+
+// withWatchColumns returns a copy of the formatter laid out like mtr for
+// a --watch total: the probes sent follow the loss, the standard
+// deviation follows the worst RTT, and the RTT samples, by now one per
+// round, are dropped.
+func (f *TableFormatter) withWatchColumns() *TableFormatter {
+	f = f.withColumnAfter("max", "stddev").withColumnAfter("loss", "sent")
+
+	columns := make([]tableColumn, 0, len(f.columns))
+	for _, c := range f.columns {
+		if c.Name != "samples" {
+			columns = append(columns, c)
+		}
+	}
+	f.columns = columns
+	return f
+}
+
+// hasInterfaceInfo reports whether any hop carries RFC 5837 interface
+// information.
+func hasInterfaceInfo(hops []trace.Hop) bool {
+	for i := range hops {
+		if hops[i].Interface != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultColumns returns the default column set for the configuration.
+func (f *TableFormatter) defaultColumns() []tableColumn {
+	var columns []tableColumn
+	for _, name := range defaultTableColumns {
+		if f.config.NoHostname && name == "hostname" {
+			continue
+		}
+		if f.config.NoASN && (name == "asn" || name == "org") {
+			continue
+		}
+		if f.config.NoGeoIP && name == "location" {
+			continue
+		}
+		col, _ := lookupTableColumn(name)
+		columns = append(columns, col)
+	}
+	return columns
+}
+
+// getHeaders returns the column headers.
+func (f *TableFormatter) getHeaders() []string {
+	headers := make([]string, len(f.columns))
+	for i, col := range f.columns {
+		headers[i] = col.Header
+	}
+	return headers
+}
+
+// showsSources reports whether a column carrying a source marker is shown.
+func (f *TableFormatter) showsSources() bool {
+	for _, col := range f.columns {
+		if col.Name == "asn" || col.Name == "location" {
+			return true
+		}
+	}
+	return false
+}
+
+// formatHopRow formats a single hop as a table row.
+func (f *TableFormatter) formatHopRow(hop *trace.Hop) []string {
+	row := make([]string, len(f.columns))
+	for i, col := range f.columns {
+		value := col.Value(f, hop)
+		if col.Width > 0 {
+			value = truncateString(value, col.Width)
+		}
+		row[i] = value
+	}
+	return row
+}
+
+// formatSkippedRow formats the collapsed private prefix as a single row.
+func (f *TableFormatter) formatSkippedRow(skipped *trace.SkippedHops) []string {
+	row := make([]string, len(f.columns))
+	for i, col := range f.columns {
+		if col.Skipped != nil {
+			row[i] = col.Skipped(f, skipped)
+		} else {
+			row[i] = "-"
+		}
+	}
+	return row
+}
+
+// formatTimeoutRunRow formats a run of unresponsive hops as a single row.
+// The run is described in the hostname column, or next to the "*" in the
+// ip column if the hostname is not shown.
+func (f *TableFormatter) formatTimeoutRunRow(run []trace.Hop) []string {
+	hasHostname := false
+	for _, col := range f.columns {
+		if col.Name == "hostname" {
+			hasHostname = true
+		}
+	}
+
+	label := "(" + timeoutRunLabel(run) + ")"
+	row := make([]string, len(f.columns))
+	for i, col := range f.columns {
+		switch {
+		case col.Name == "hop":
+			row[i] = timeoutRunRange(run)
+		case col.Name == "ip" && hasHostname:
+			row[i] = "*"
+		case col.Name == "ip":
+			row[i] = "* " + label
+		case col.Name == "hostname":
+			row[i] = label
+		default:
+			row[i] = "-"
+		}
+	}
+	return row
+}
+
+// hopRTT formats an RTT statistic of a hop, or "-" if it has none.
+func (f *TableFormatter) hopRTT(hop *trace.Hop, rtt float64) string {
+	if !hop.Responded || hop.AvgRTT <= 0 {
+		return "-"
+	}
+	return f.formatRTT(rtt)
+}
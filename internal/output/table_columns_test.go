@@ -0,0 +1,127 @@
+package output
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTableColumns_Extractors(t *testing.T) {
+	result := sampleTraceResult()
+	f := NewTableFormatter(Config{})
+
+	tests := []struct {
+		column string
+		hop    int
+		want   string
+	}{
+		{"hop", 1, "2"},
+		{"ip", 1, result.Hops[1].IP.String()},
+		{"ip", 2, "*"},
+		{"hostname", 2, "-"},
+		{"asn", 1, "15169"},
+		{"asn", 0, "-"},
+		{"avg", 2, "-"},
+		{"loss", 2, "-"},
+		{"samples", 1, "5.7 / * / 5.4"},
+		{"samples", 2, "* / * / *"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.column, func(t *testing.T) {
+			col, ok := lookupTableColumn(tt.column)
+			if !ok {
+				t.Fatalf("column %q not registered", tt.column)
+			}
+			if got := col.Value(f, &result.Hops[tt.hop]); got != tt.want {
+				t.Errorf("%s(hop %d) = %q, want %q", tt.column, tt.hop+1, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTableColumns_Registry(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, col := range tableColumns {
+		if col.Name == "" || col.Header == "" || col.Value == nil {
+			t.Errorf("column %+v is incomplete", col.Name)
+		}
+		if seen[col.Name] {
+			t.Errorf("column %q registered twice", col.Name)
+		}
+		seen[col.Name] = true
+	}
+	for _, name := range defaultTableColumns {
+		if !seen[name] {
+			t.Errorf("default column %q is not registered", name)
+		}
+	}
+}
+
+func TestTableColumns_Default(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   string
+	}{
+		{"all", Config{}, "Hop|IP Address|Hostname|ASN|Organization|Location|Last|Avg|Min|Max|Loss|RTT Samples"},
+		{"no ASN", Config{NoASN: true}, "Hop|IP Address|Hostname|Location|Last|Avg|Min|Max|Loss|RTT Samples"},
+		{"no GeoIP", Config{NoGeoIP: true}, "Hop|IP Address|Hostname|ASN|Organization|Last|Avg|Min|Max|Loss|RTT Samples"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := strings.Join(NewTableFormatter(tt.config).getHeaders(), "|")
+			if got != tt.want {
+				t.Errorf("headers = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTableColumns(t *testing.T) {
+	names, err := ParseTableColumns(" Hop, ip,asn ,avg,loss")
+	if err != nil {
+		t.Fatalf("ParseTableColumns() error = %v", err)
+	}
+	if got := strings.Join(names, ","); got != "hop,ip,asn,avg,loss" {
+		t.Errorf("ParseTableColumns() = %s", got)
+	}
+
+	for _, spec := range []string{"hop,latency", "hop,hop", " , "} {
+		if _, err := ParseTableColumns(spec); err == nil {
+			t.Errorf("ParseTableColumns(%q) should fail", spec)
+		}
+	}
+
+	_, err = ParseTableColumns("hop,latency")
+	if err == nil || !strings.Contains(err.Error(), `"latency"`) || !strings.Contains(err.Error(), "avg") {
+		t.Errorf("error should name the bad column and list available ones, got %v", err)
+	}
+}
+
+func TestTableFormatter_Columns(t *testing.T) {
+	result := sampleTraceResult()
+	f := NewTableFormatter(Config{Columns: []string{"ip", "hop", "avg"}})
+
+	if got := strings.Join(f.getHeaders(), "|"); got != "IP Address|Hop|Avg" {
+		t.Errorf("headers = %s", got)
+	}
+	if got := f.formatHopRow(&result.Hops[0]); len(got) != 3 || got[1] != "1" {
+		t.Errorf("row = %v, want 3 cells in configured order", got)
+	}
+
+	data, err := f.Format(result)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if strings.Contains(string(data), "HOSTNAME") {
+		t.Error("unselected columns should not be rendered")
+	}
+
+	if err := f.SetColumns([]string{"hop", "bogus"}); err == nil {
+		t.Error("SetColumns() should reject unknown columns")
+	}
+	if len(f.columns) != 3 {
+		t.Error("failed SetColumns() should keep the current columns")
+	}
+}
@@ -0,0 +1,68 @@
+package output
+
+import (
+	"os"
+	"sync/atomic"
+
+	"github.com/KilimcininKorOglu/poros/internal/term"
+)
+
+// SizeProvider reports the width of the terminal output is written to.
+// Formatters query it each time they format, so output written after a
+// resize uses the new width.
+type SizeProvider interface {
+	// Width returns the terminal width in columns (0 = unknown)
+	Width() int
+}
+
+// FixedSize is a SizeProvider with a constant width.
+type FixedSize int
+
+// Width implements SizeProvider.
+func (s FixedSize) Width() int { return int(s) }
+
+// TerminalSize is a SizeProvider for a terminal. The width is measured
+// when created and again on Refresh, e.g. when the terminal reports a
+// resize; it is safe for concurrent use.
+type TerminalSize struct {
+	f     *os.File
+	width atomic.Int64
+}
+
+// NewTerminalSize creates a SizeProvider measuring the terminal behind f.
+func NewTerminalSize(f *os.File) *TerminalSize {
+	s := &TerminalSize{f: f}
+	s.Refresh()
+	return s
+}
+
+// Width implements SizeProvider.
+func (s *TerminalSize) Width() int {
+	return int(s.width.Load())
+}
+
+// Refresh measures the terminal again. The last width is kept if the
+// terminal cannot be measured.
+func (s *TerminalSize) Refresh() {
+	if width, _ := term.Size(s.f); width > 0 {
+		s.width.Store(int64(width))
+	}
+}
+
+// SizeAware is implemented by formatters that fit their output to the
+// terminal width.
+type SizeAware interface {
+	SetSizeProvider(size SizeProvider)
+}
+
+// resolveWidth returns the output width: the configured width, else the
+// one reported by size, else 0 (unknown).
+func resolveWidth(config Config, size SizeProvider) int {
+	if config.Width > 0 {
+		return config.Width
+	}
+	if size != nil {
+		return size.Width()
+	}
+	return 0
+}
@@ -0,0 +1,123 @@
+package output
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+)
+
+// resizableSize is a SizeProvider whose width tests change mid-trace.
+type resizableSize struct{ width int }
+
+func (s *resizableSize) Width() int { return s.width }
+
+func TestTextFormatter_HostnameWidth(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		size   SizeProvider
+		want   int
+	}{
+		{"unknown", Config{}, nil, textHostnameWidth},
+		{"unmeasured", Config{}, FixedSize(0), textHostnameWidth},
+		{"narrow", Config{}, FixedSize(80), textHostnameMin},
+		{"medium", Config{}, FixedSize(110), 110 - 5 - 16 - 30 - 22},
+		{"wide", Config{}, FixedSize(300), textHostnameMax},
+		{"no asn", Config{NoASN: true}, FixedSize(100), 100 - 5 - 16 - 30},
+		{"configured", Config{Width: 110}, FixedSize(300), 110 - 5 - 16 - 30 - 22},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewTextFormatter(tt.config)
+			if tt.size != nil {
+				f.SetSizeProvider(tt.size)
+			}
+			if got := f.hostnameWidth(3); got != tt.want {
+				t.Errorf("hostnameWidth(3) = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTextFormatter_ReflowsOnResize(t *testing.T) {
+	size := &resizableSize{width: 80}
+	f := NewTextFormatter(Config{})
+	f.SetSizeProvider(size)
+
+	hop := &trace.Hop{
+		Number:    1,
+		Hostname:  "a-rather-long-hostname.core1.example.net",
+		RTTs:      []float64{1.2, 1.3, 1.1},
+		Responded: true,
+	}
+
+	narrow := f.FormatHop(hop)
+	if strings.Contains(narrow, hop.Hostname) {
+		t.Errorf("hostname not truncated at width 80: %q", narrow)
+	}
+
+	size.width = 200
+	wide := f.FormatHop(hop)
+	if !strings.Contains(wide, hop.Hostname) {
+		t.Errorf("hostname truncated after resize to 200: %q", wide)
+	}
+}
+
+func TestTableFormatter_FitsTerminal(t *testing.T) {
+	result := sampleTraceResult()
+	result.Hops[0].Hostname = "a-rather-long-hostname.core1.example.net"
+
+	tableWidth := func(out string) int {
+		widest := 0
+		for _, line := range strings.Split(out, "\n") {
+			if strings.HasPrefix(line, "│") {
+				widest = max(widest, len([]rune(line)))
+			}
+		}
+		return widest
+	}
+
+	f := NewTableFormatter(Config{})
+	data, err := f.Format(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full := tableWidth(string(data))
+
+	f.SetSizeProvider(FixedSize(full - 8))
+	data, err = f.Format(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tableWidth(string(data)); got > full-8 {
+		t.Errorf("table width = %d, want at most %d", got, full-8)
+	}
+
+	f.SetSizeProvider(FixedSize(full + 20))
+	data, err = f.Format(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tableWidth(string(data)); got != full {
+		t.Errorf("table width = %d, want unchanged %d", got, full)
+	}
+}
+
+func TestWriter_TerminalSize(t *testing.T) {
+	var buf strings.Builder
+	if w := NewWriterTo(&buf, FormatText, DefaultConfig()); w.TerminalSize() != nil {
+		t.Error("TerminalSize() != nil for a non-terminal writer")
+	}
+
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if w := NewWriterTo(f, FormatText, DefaultConfig()); w.TerminalSize() != nil {
+		t.Error("TerminalSize() != nil for a regular file")
+	}
+}
@@ -3,7 +3,11 @@ package output
 import (
 	"bytes"
 	"fmt"
+	"net"
+	"strings"
+	"sync"
 
+	"github.com/KilimcininKorOglu/poros/internal/probe"
 	"github.com/KilimcininKorOglu/poros/internal/trace"
 	"github.com/fatih/color"
 )
@@ -12,6 +16,12 @@ import (
 type TextFormatter struct {
 	config Config
 	colors *ColorScheme
+	size   SizeProvider // nil = fixed column widths unless Config.Width
+
+	// silent holds streamed unresponsive hops that may still become part
+	// of a collapsed run (Config.CollapseTimeouts)
+	mu     sync.Mutex
+	silent []trace.Hop
 }
 
 // NewTextFormatter creates a new text formatter.
@@ -27,42 +37,325 @@ func NewTextFormatter(config Config) *TextFormatter {
 	}
 }
 
+// SetSizeProvider implements SizeAware. The hostname column is fitted to
+// the width reported when each hop is formatted.
+func (f *TextFormatter) SetSizeProvider(size SizeProvider) {
+	f.size = size
+}
+
+// Column widths of a text hop line; the hostname column fills the
+// terminal width that the other columns leave.
+const (
+	textHopWidth      = 5  // "%3d  "
+	textIPWidth       = 16 // address, padded
+	textRTTWidth      = 10 // per probe
+	textASNWidth      = 22 // room kept for "  [AS15169] GOOGLE"
+	textSummaryWidth  = 32 // "  avg   14.2 ms ±1.1    loss  20%"
+	textHostnameWidth = 30 // when the terminal width is unknown
+	textHostnameMin   = 12
+	textHostnameMax   = 60
+)
+
+// summaryProbes is the number of probes per hop above which hop lines
+// end with their average, jitter and loss even without SummaryPerHop.
+const summaryProbes = 3
+
+// showSummary reports whether the hop line for a hop with the given
+// number of probes ends with a summary.
+func (f *TextFormatter) showSummary(probes int) bool {
+	return f.config.SummaryPerHop || probes > summaryProbes
+}
+
+// hostnameWidth returns the width of the hostname column, including its
+// two trailing spaces, for a hop line with the given number of probes.
+func (f *TextFormatter) hostnameWidth(probes int) int {
+	width := resolveWidth(f.config, f.size)
+	if width <= 0 {
+		return textHostnameWidth
+	}
+
+	rest := textHopWidth + textIPWidth + probes*textRTTWidth
+	if f.showSummary(probes) {
+		rest += textSummaryWidth
+	}
+	if !f.config.NoASN {
+		rest += textASNWidth
+	}
+	return max(textHostnameMin, min(width-rest, textHostnameMax))
+}
+
 // Format formats the trace result as classic traceroute text output.
 func (f *TextFormatter) Format(result *trace.TraceResult) ([]byte, error) {
 	var buf bytes.Buffer
 
+	n := f.config.numbers(result.Hops)
+
 	// Header
+	resolved := resolvedLabel(result)
+	if result.TranslatedVia != "" {
+		resolved += " via " + result.TranslatedVia
+	}
+	if len(result.Via) > 0 {
+		resolved += ", loose source routed via " + formatVia(result.Via)
+	}
 	fmt.Fprintf(&buf, "traceroute to %s (%s), %d hops max\n\n",
-		result.Target, result.ResolvedIP, len(result.Hops)+5)
+		targetLabel(result), resolved, len(result.Hops)+5)
+
+	// Collapsed private prefix
+	if result.Skipped != nil {
+		f.formatSkipped(&buf, result.Skipped, n)
+	}
 
-	// Hops
-	for _, hop := range result.Hops {
-		f.formatHop(&buf, &hop)
+	// Hops, with redirects under the first one since they come from the
+	// local router
+	for i, group := range groupHops(result.Hops, f.config.CollapseTimeouts) {
+		if len(group) > 1 {
+			f.formatTimeoutRun(&buf, group)
+		} else {
+			f.formatHop(&buf, &group[0], n)
+		}
+		if i == 0 {
+			f.formatRedirects(&buf, result.Redirects)
+		}
 	}
 
 	// Summary
-	buf.WriteString("\n")
-	if result.Completed {
-		fmt.Fprintf(&buf, "Trace complete. %d hops, %.2f ms total\n",
-			result.Summary.TotalHops, result.Summary.TotalTimeMs)
+	buf.WriteString(f.formatSummary(result, n))
+
+	return buf.Bytes(), nil
+}
+
+// FormatHeader formats the line printed before a streamed trace starts,
+// when the target has not been resolved yet.
+func (f *TextFormatter) FormatHeader(target string, maxHops int) string {
+	return fmt.Sprintf("traceroute to %s, %d hops max\n\n", target, maxHops)
+}
+
+// FormatPartialHeader formats the header of a partial-path trace that
+// only probes hops firstHop through lastHop.
+func (f *TextFormatter) FormatPartialHeader(target string, firstHop, lastHop int) string {
+	return fmt.Sprintf("traceroute to %s, hops %d-%d only (partial path)\n\n", target, firstHop, lastHop)
+}
+
+// FormatSummary formats the closing summary line. When streaming, the
+// header was written before the target was resolved, so the destination's
+// PTR name and an address translation are reported here.
+func (f *TextFormatter) FormatSummary(result *trace.TraceResult) string {
+	summary := f.flushSilent() + f.formatSummary(result, f.config.numbers(result.Hops))
+	if result.TargetPTR != "" {
+		summary += fmt.Sprintf("Destination %s is %s\n", result.ResolvedIP, result.TargetPTR)
+	}
+	for _, r := range result.Redirects {
+		summary += fmt.Sprintf("ICMP redirect from %s suggested gateway %s; routing was not changed\n", r.Router, r.Gateway)
+	}
+	if result.TranslatedVia != "" {
+		summary += fmt.Sprintf("Target %s traced as %s via %s\n", result.Target, result.ResolvedIP, result.TranslatedVia)
+	}
+	if len(result.Via) > 0 {
+		summary += fmt.Sprintf("Probes were loose source routed via %s; hops show the source-routed path\n", formatVia(result.Via))
+	}
+	return summary
+}
+
+// formatAppProbe formats the application section: one line per step of
+// the connection made to the destination after the trace.
+func (f *TextFormatter) formatAppProbe(ap *trace.AppProbe, n numberFormat) string {
+	header := "\nApplication " + ap.Address
+	if ap.ServerName != "" {
+		header += " (SNI " + ap.ServerName + ")"
+	}
+	if f.colors != nil {
+		header = f.colors.Header.Sprint(header)
+	}
+
+	lines := []string{header}
+	step := func(label string, ms float64) {
+		lines = append(lines, fmt.Sprintf("  %-18s%s", label, f.colorizeRTT(ms, n)))
+	}
+	if ap.SYNACKMs > 0 {
+		step("SYN → SYN-ACK", ap.SYNACKMs)
+	}
+	if ap.ConnectMs > 0 {
+		step("connect", ap.ConnectMs)
+	}
+	if ap.TLSHandshakeMs > 0 {
+		step(ap.TLSVersion+" handshake", ap.TLSHandshakeMs)
+	}
+	if ap.Error != "" {
+		failed := "  failed: " + ap.Error
+		if f.colors != nil {
+			failed = f.colors.Timeout.Sprint(failed)
+		}
+		lines = append(lines, failed)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// formatRedirects writes a warning line for each ICMP redirect.
+func (f *TextFormatter) formatRedirects(buf *bytes.Buffer, redirects []trace.Redirect) {
+	for _, r := range redirects {
+		line := fmt.Sprintf("     ! ICMP redirect from %s: gateway %s suggested", r.Router, r.Gateway)
+		if f.colors != nil {
+			line = f.colors.RTTMed.Sprint(line)
+		}
+		buf.WriteString(line + "\n")
+	}
+}
+
+// formatVia lists the routers of a loose source route.
+func formatVia(via []net.IP) string {
+	parts := make([]string, len(via))
+	for i, ip := range via {
+		parts[i] = ip.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatSummary formats the trace complete/incomplete line, after the
+// application probe section if there is one.
+func (f *TextFormatter) formatSummary(result *trace.TraceResult, n numberFormat) string {
+	var summary string
+	if result.AppProbe != nil {
+		summary = f.formatAppProbe(result.AppProbe, n)
+	}
+	if hop := DirectHop(result); hop != nil {
+		summary += "\n" + formatDirect(hop, n) + "\n"
+	} else if result.Completed {
+		summary += fmt.Sprintf("\nTrace complete. %d hops, %s total\n",
+			result.Summary.TotalHops, n.rtt(result.Summary.TotalTimeMs))
+	} else if hops := partialPathRange(result); hops != "" {
+		summary += fmt.Sprintf("\nPartial trace of %s complete, destination not probed\n", hops)
+	} else if result.StoppedReason == trace.StopPacketBudget {
+		summary += fmt.Sprintf("\nTrace stopped after %d hops, packet budget exhausted\n", result.Summary.TotalHops)
 	} else {
-		fmt.Fprintf(&buf, "Trace incomplete after %d hops\n",
-			result.Summary.TotalHops)
+		summary += fmt.Sprintf("\nTrace incomplete after %d hops\n", result.Summary.TotalHops)
 	}
 
-	return buf.Bytes(), nil
+	if result.Rounds > 0 {
+		summary += fmt.Sprintf("Watched over %d rounds\n", result.Rounds)
+	}
+	if port := formatDestinationPort(result); port != "" {
+		summary += port + "\n"
+	}
+	if result.Summary.DestinationProbes > 0 {
+		summary += "Destination: " + formatDestinationCheck(result.Summary, n) + "\n"
+	}
+	if transit := result.Summary.LastTransitHop; transit != nil && !f.config.NoASN {
+		summary += "Last transit hop: " + formatTransitHop(transit, n) + "\n"
+	}
+	if result.Anonymized() {
+		summary += "Anonymized: private addresses replaced by placeholders, local host details removed\n"
+	}
+	return summary
+}
+
+// formatDestinationPort describes the destination port from the TCP
+// reply of the final hop, e.g. "Destination port 443: open (SYN-ACK)",
+// or returns "" if the trace did not end in one.
+func formatDestinationPort(result *trace.TraceResult) string {
+	if len(result.Hops) == 0 {
+		return ""
+	}
+	var state string
+	switch result.Hops[len(result.Hops)-1].ResponseKind {
+	case probe.ResponseSynAck:
+		state = "open (SYN-ACK)"
+	case probe.ResponseRST:
+		state = "closed (RST)"
+	default:
+		return ""
+	}
+	if result.Meta != nil && result.Meta.DestPort > 0 {
+		return fmt.Sprintf("Destination port %d: %s", result.Meta.DestPort, state)
+	}
+	return "Destination port: " + state
 }
 
 // FormatHop formats a single hop and returns it as a string.
-// This can be used for streaming output.
+// This can be used for streaming output. Later hops are not known yet,
+// so UnitsAuto is decided for this hop alone.
+//
+// With Config.CollapseTimeouts, unresponsive hops are held back until the
+// run they belong to ends, so the run may be written as a single line.
 func (f *TextFormatter) FormatHop(hop *trace.Hop) string {
+	if f.config.CollapseTimeouts && silentHop(hop) {
+		f.mu.Lock()
+		f.silent = append(f.silent, *hop)
+		f.mu.Unlock()
+		return ""
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(f.flushSilent())
+	f.formatHop(&buf, hop, f.config.numbers([]trace.Hop{*hop}))
+	return buf.String()
+}
+
+// FormatSkipped formats the collapsed private prefix line.
+// This can be used for streaming output.
+func (f *TextFormatter) FormatSkipped(skipped *trace.SkippedHops) string {
+	var buf bytes.Buffer
+	f.formatSkipped(&buf, skipped, f.config.numbers(nil))
+	return buf.String()
+}
+
+// formatTimeoutRun formats a run of unresponsive hops on one line.
+func (f *TextFormatter) formatTimeoutRun(buf *bytes.Buffer, run []trace.Hop) {
+	hopRange := fmt.Sprintf("%3s  ", timeoutRunRange(run))
+	label := fmt.Sprintf("* * * (%s)", timeoutRunLabel(run))
+	if f.colors != nil {
+		hopRange = f.colors.Hop.Sprint(hopRange)
+		label = f.colors.Timeout.Sprint(label)
+	}
+	buf.WriteString(hopRange)
+	buf.WriteString(label)
+	buf.WriteString("\n")
+}
+
+// flushSilent formats the buffered unresponsive hops, as one collapsed
+// line if they form a long enough run.
+func (f *TextFormatter) flushSilent() string {
+	f.mu.Lock()
+	silent := f.silent
+	f.silent = nil
+	f.mu.Unlock()
+
 	var buf bytes.Buffer
-	f.formatHop(&buf, hop)
+	if len(silent) >= minTimeoutRun {
+		f.formatTimeoutRun(&buf, silent)
+		return buf.String()
+	}
+	for i := range silent {
+		f.formatHop(&buf, &silent[i], f.config.numbers(silent))
+	}
 	return buf.String()
 }
 
+// formatSkipped formats a collapsed range of private hops on one line.
+func (f *TextFormatter) formatSkipped(buf *bytes.Buffer, skipped *trace.SkippedHops, n numberFormat) {
+	hopRange := fmt.Sprintf("%3s  ", skippedRange(skipped))
+	if f.colors != nil {
+		hopRange = f.colors.Hop.Sprint(hopRange)
+	}
+	buf.WriteString(hopRange)
+
+	label := "private network"
+	if f.colors != nil {
+		label = f.colors.Hostname.Sprint(label)
+	}
+	buf.WriteString(label)
+	buf.WriteString(", ")
+
+	if f.colors != nil {
+		buf.WriteString(f.colorizeRTT(skipped.RTT, n))
+	} else {
+		buf.WriteString(n.rtt(skipped.RTT))
+	}
+	buf.WriteString("\n")
+}
+
 // formatHop formats a single hop line.
-func (f *TextFormatter) formatHop(buf *bytes.Buffer, hop *trace.Hop) {
+func (f *TextFormatter) formatHop(buf *bytes.Buffer, hop *trace.Hop, n numberFormat) {
 	// Hop number - fixed width
 	hopNum := fmt.Sprintf("%3d  ", hop.Number)
 	if f.colors != nil {
@@ -73,37 +366,54 @@ func (f *TextFormatter) formatHop(buf *bytes.Buffer, hop *trace.Hop) {
 	// No response
 	if !hop.Responded {
 		timeout := "* * *"
+		if hop.Unprobed {
+			timeout = "not probed (packet budget exhausted)"
+		} else if hop.SendFailed() {
+			timeout = fmt.Sprintf("send failed (%s)", hop.SendError)
+		}
 		if f.colors != nil {
 			timeout = f.colors.Timeout.Sprint(timeout)
 		}
 		buf.WriteString(timeout)
+		if !hop.Unprobed && !hop.SendFailed() && len(hop.RTTs) > 0 && (hop.Sent > 0 || f.showSummary(len(hop.RTTs))) {
+			buf.WriteString("  ")
+			buf.WriteString(f.formatLoss(hop.LossPercent, n))
+		}
 		buf.WriteString("\n")
 		return
 	}
 
 	// IP address - fixed width 16 chars
-	ipStr := hop.IP.String()
+	ipStr := hop.Address()
 	ipFormatted := fmt.Sprintf("%-16s", ipStr)
 	if f.colors != nil {
 		ipFormatted = f.colors.IP.Sprint(ipFormatted)
 	}
 	buf.WriteString(ipFormatted)
 
-	// Hostname (if available and not disabled) - fixed width 30 chars
+	// A --watch total shows its last sample and the statistics of all
+	// rounds instead of one sample per round
+	rtts, summary := hop.RTTs, f.showSummary(len(hop.RTTs))
+	if hop.Sent > 0 && len(rtts) > 0 {
+		rtts, summary = rtts[len(rtts)-1:], true
+	}
+
+	// Hostname (if available and not disabled), fitted to the terminal
 	if !f.config.NoHostname {
+		width := f.hostnameWidth(len(rtts))
 		hostname := ""
 		if hop.Hostname != "" {
-			hostname = truncateString(hop.Hostname, 28)
+			hostname = truncateHostname(hostnameLabel(hop), width-2)
 		}
-		hostnameFormatted := fmt.Sprintf("%-30s", hostname)
+		hostnameFormatted := fmt.Sprintf("%-*s", width, hostname)
 		if f.colors != nil && hostname != "" {
-			hostnameFormatted = f.colors.Hostname.Sprint(fmt.Sprintf("%-30s", hostname))
+			hostnameFormatted = f.colors.Hostname.Sprint(hostnameFormatted)
 		}
 		buf.WriteString(hostnameFormatted)
 	}
 
 	// RTT values - fixed width 10 chars each
-	for _, rtt := range hop.RTTs {
+	for _, rtt := range rtts {
 		if rtt < 0 {
 			timeout := fmt.Sprintf("%10s", "*")
 			if f.colors != nil {
@@ -111,17 +421,28 @@ func (f *TextFormatter) formatHop(buf *bytes.Buffer, hop *trace.Hop) {
 			}
 			buf.WriteString(timeout)
 		} else {
-			rttStr := fmt.Sprintf("%7.2f ms", rtt)
-			if f.colors != nil {
-				rttStr = f.colorizeRTT(rtt)
-			}
+			rttStr := f.colorizeRTT(rtt, n)
 			buf.WriteString(rttStr)
 		}
 	}
 
+	// Average, jitter and loss after the samples
+	if summary {
+		buf.WriteString(f.formatSummaryColumns(hop, n))
+	}
+
+	// Source route refused here (traceroute's !S)
+	if hop.SourceRouteRejected {
+		marker := "  !S"
+		if f.colors != nil {
+			marker = f.colors.Timeout.Sprint(marker)
+		}
+		buf.WriteString(marker)
+	}
+
 	// ASN info (if available and not disabled)
 	if hop.ASN != nil && !f.config.NoASN {
-		asnStr := fmt.Sprintf("  [AS%d %s]", hop.ASN.Number, truncateString(hop.ASN.Org, 15))
+		asnStr := "  " + f.formatASN(hop.ASN)
 		if f.colors != nil {
 			asnStr = f.colors.ASN.Sprint(asnStr)
 		}
@@ -131,21 +452,63 @@ func (f *TextFormatter) formatHop(buf *bytes.Buffer, hop *trace.Hop) {
 	buf.WriteString("\n")
 }
 
-// colorizeRTT returns a colored RTT string based on latency thresholds.
-func (f *TextFormatter) colorizeRTT(rtt float64) string {
-	str := fmt.Sprintf("%7.2f ms", rtt)
+// formatASN returns the bracketed ASN label for a hop. With ASNDetail
+// the AS country code and announced prefix are appended.
+func (f *TextFormatter) formatASN(asn *trace.ASNInfo) string {
+	parts := []string{fmt.Sprintf("AS%d", asn.Number)}
+	if asn.Org != "" {
+		parts = append(parts, truncateString(asn.Org, 15))
+	}
+
+	if f.config.ASNDetail {
+		if asn.Country != "" {
+			parts = append(parts, asn.Country)
+		}
+		if asn.Prefix != "" {
+			parts = append(parts, asn.Prefix)
+		}
+	}
+
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+// colorizeRTT returns an RTT string, colored based on latency thresholds.
+func (f *TextFormatter) colorizeRTT(rtt float64, n numberFormat) string {
+	str := fmt.Sprintf("%7s %s", n.rttValue(rtt, 2), n.unit())
 	if f.colors == nil {
 		return str
 	}
 
-	switch {
-	case rtt < 50:
-		return f.colors.RTTLow.Sprint(str)
-	case rtt < 150:
-		return f.colors.RTTMed.Sprint(str)
-	default:
-		return f.colors.RTTHigh.Sprint(str)
+	return f.colors.rttColor(f.config.ClassifyRTT(rtt)).Sprint(str)
+}
+
+// formatSummaryColumns returns the average, jitter and loss of a
+// responding hop, e.g. "  avg   14.2 ms ±1.1    loss  20%", padded so
+// the columns of consecutive hop lines align. A --watch total adds the
+// probes sent, e.g. "loss  20% of 60".
+func (f *TextFormatter) formatSummaryColumns(hop *trace.Hop, n numberFormat) string {
+	avg := fmt.Sprintf("%6s %s", n.rttValue(hop.AvgRTT, 1), n.unit())
+	if f.colors != nil {
+		avg = f.colors.rttColor(f.config.ClassifyRTT(hop.AvgRTT)).Sprint(avg)
+	}
+	jitter := fmt.Sprintf("±%-6s", n.rttValue(hop.Jitter, 1))
+	columns := "  avg " + avg + " " + jitter + f.formatLoss(hop.LossPercent, n)
+	if hop.Sent > 0 {
+		columns += fmt.Sprintf(" of %d", hop.Sent)
 	}
+	return columns
+}
+
+// formatLoss returns a hop's loss, e.g. "loss  20%", colored against the
+// loss thresholds.
+func (f *TextFormatter) formatLoss(loss float64, n numberFormat) string {
+	str := fmt.Sprintf("loss %4s", n.percent(loss, 0))
+	if f.colors != nil {
+		if c := f.colors.lossColor(f.config.ClassifyLoss(loss)); c != nil {
+			str = c.Sprint(str)
+		}
+	}
+	return str
 }
 
 // ContentType returns the MIME type for text output.
@@ -163,9 +526,11 @@ type ColorScheme struct {
 	Hop      *color.Color
 	IP       *color.Color
 	Hostname *color.Color
-	RTTLow   *color.Color // < 50ms
-	RTTMed   *color.Color // 50-150ms
-	RTTHigh  *color.Color // > 150ms
+	RTTLow   *color.Color // below warning threshold
+	RTTMed   *color.Color // between warning and critical thresholds
+	RTTHigh  *color.Color // above critical threshold
+	LossWarn *color.Color // loss above warning threshold
+	LossCrit *color.Color // loss above critical threshold
 	Timeout  *color.Color
 	ASN      *color.Color
 	Geo      *color.Color
@@ -181,6 +546,8 @@ func DefaultColorScheme() *ColorScheme {
 		RTTLow:   color.New(color.FgGreen),
 		RTTMed:   color.New(color.FgYellow),
 		RTTHigh:  color.New(color.FgRed),
+		LossWarn: color.New(color.FgYellow),
+		LossCrit: color.New(color.FgRed, color.Bold),
 		Timeout:  color.New(color.FgRed, color.Bold),
 		ASN:      color.New(color.FgMagenta),
 		Geo:      color.New(color.FgBlue),
@@ -188,8 +555,41 @@ func DefaultColorScheme() *ColorScheme {
 	}
 }
 
+// rttColor returns the color for an RTT classification level.
+func (c *ColorScheme) rttColor(level Level) *color.Color {
+	switch level {
+	case LevelWarn:
+		return c.RTTMed
+	case LevelCrit:
+		return c.RTTHigh
+	default:
+		return c.RTTLow
+	}
+}
+
+// lossColor returns the color for a loss classification level,
+// or nil if the loss should not be highlighted.
+func (c *ColorScheme) lossColor(level Level) *color.Color {
+	switch level {
+	case LevelWarn:
+		return c.LossWarn
+	case LevelCrit:
+		return c.LossCrit
+	default:
+		return nil
+	}
+}
+
 // Helper functions
 
+// skippedRange returns the hop range label for a collapsed prefix (e.g. "1-5").
+func skippedRange(skipped *trace.SkippedHops) string {
+	if skipped.FirstHop == skipped.LastHop {
+		return fmt.Sprintf("%d", skipped.FirstHop)
+	}
+	return fmt.Sprintf("%d-%d", skipped.FirstHop, skipped.LastHop)
+}
+
 func truncateString(s string, maxLen int) string {
 	if len(s) <= maxLen {
 		return s
@@ -0,0 +1,96 @@
+package output
+
+// Default latency thresholds used for RTT coloring.
+const (
+	DefaultRTTWarnMs = 50.0
+	DefaultRTTCritMs = 150.0
+)
+
+// Default packet loss thresholds. Any loss above the warning threshold
+// is a warning; loss above the critical threshold is critical.
+const (
+	DefaultLossWarnPercent = 0.0
+	DefaultLossCritPercent = 10.0
+)
+
+// Level is the severity of a measured value relative to its thresholds.
+type Level int
+
+const (
+	// LevelNone means there is no measurement (e.g., a timed-out hop)
+	LevelNone Level = iota
+	// LevelNormal is below the warning threshold
+	LevelNormal
+	// LevelWarn is at or above the warning threshold
+	LevelWarn
+	// LevelCrit is at or above the critical threshold
+	LevelCrit
+)
+
+// String returns the string representation of the level.
+func (l Level) String() string {
+	switch l {
+	case LevelNormal:
+		return "normal"
+	case LevelWarn:
+		return "warn"
+	case LevelCrit:
+		return "crit"
+	default:
+		return "none"
+	}
+}
+
+// ClassifyRTT classifies an RTT in milliseconds against the given thresholds.
+// Non-positive thresholds fall back to the defaults.
+func ClassifyRTT(rtt, warnMs, critMs float64) Level {
+	if rtt <= 0 {
+		return LevelNone
+	}
+	if warnMs <= 0 {
+		warnMs = DefaultRTTWarnMs
+	}
+	if critMs <= 0 {
+		critMs = DefaultRTTCritMs
+	}
+
+	switch {
+	case rtt < warnMs:
+		return LevelNormal
+	case rtt < critMs:
+		return LevelWarn
+	default:
+		return LevelCrit
+	}
+}
+
+// ClassifyRTT classifies an RTT using the thresholds from the config.
+func (c Config) ClassifyRTT(rtt float64) Level {
+	return ClassifyRTT(rtt, c.RTTWarnMs, c.RTTCritMs)
+}
+
+// ClassifyLoss classifies a loss percentage against the given thresholds.
+// Loss strictly above a threshold reaches that level. A non-positive
+// critical threshold falls back to the default.
+func ClassifyLoss(loss, warnPercent, critPercent float64) Level {
+	if warnPercent < 0 {
+		warnPercent = DefaultLossWarnPercent
+	}
+	if critPercent <= 0 {
+		critPercent = DefaultLossCritPercent
+	}
+
+	switch {
+	case loss > critPercent:
+		return LevelCrit
+	case loss > warnPercent:
+		return LevelWarn
+	default:
+		return LevelNormal
+	}
+}
+
+// ClassifyLoss classifies a loss percentage using the thresholds from the config.
+func (c Config) ClassifyLoss(loss float64) Level {
+	return ClassifyLoss(loss, c.LossWarnPercent, c.LossCritPercent)
+}
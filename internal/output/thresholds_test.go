@@ -0,0 +1,155 @@
+package output
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestClassifyRTT(t *testing.T) {
+	tests := []struct {
+		name   string
+		rtt    float64
+		warn   float64
+		crit   float64
+		expect Level
+	}{
+		{"no measurement", 0, 50, 150, LevelNone},
+		{"negative", -1, 50, 150, LevelNone},
+		{"normal", 25, 50, 150, LevelNormal},
+		{"warn boundary", 50, 50, 150, LevelWarn},
+		{"warn", 75, 50, 150, LevelWarn},
+		{"crit boundary", 150, 50, 150, LevelCrit},
+		{"defaults", 75, 0, 0, LevelWarn},
+		{"lan", 5, 2, 10, LevelWarn},
+		{"satellite", 700, 600, 1200, LevelWarn},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyRTT(tt.rtt, tt.warn, tt.crit); got != tt.expect {
+				t.Errorf("ClassifyRTT(%v, %v, %v) = %v, want %v", tt.rtt, tt.warn, tt.crit, got, tt.expect)
+			}
+		})
+	}
+}
+
+// forcedColorScheme returns a scheme whose RTT and loss colors are always emitted,
+// so tests can tell them apart without a terminal.
+func forcedColorScheme() *ColorScheme {
+	scheme := DefaultColorScheme()
+	scheme.RTTLow = color.New(color.FgGreen)
+	scheme.RTTMed = color.New(color.FgYellow)
+	scheme.RTTHigh = color.New(color.FgRed)
+	scheme.RTTLow.EnableColor()
+	scheme.RTTMed.EnableColor()
+	scheme.RTTHigh.EnableColor()
+	scheme.LossWarn = color.New(color.FgYellow)
+	scheme.LossCrit = color.New(color.FgRed, color.Bold)
+	scheme.LossWarn.EnableColor()
+	scheme.LossCrit.EnableColor()
+	return scheme
+}
+
+func TestFormatters_RTTThresholds(t *testing.T) {
+	config := Config{RTTWarnMs: 2, RTTCritMs: 10}
+	scheme := forcedColorScheme()
+
+	text := NewTextFormatter(config)
+	text.colors = scheme
+	if got, want := text.colorizeRTT(5, numberFormat{}), scheme.RTTMed.Sprint("   5.00 ms"); got != want {
+		t.Errorf("TextFormatter.colorizeRTT(5) = %q, want %q", got, want)
+	}
+
+	table := NewTableFormatter(config)
+	table.colors = scheme
+	if got, want := table.formatRTT(25), scheme.RTTHigh.Sprint("25.00"); got != want {
+		t.Errorf("TableFormatter.formatRTT(25) = %q, want %q", got, want)
+	}
+
+	if got := rttClass(5, config); got != "medium" {
+		t.Errorf("rttClass(5) = %q, want %q", got, "medium")
+	}
+
+	result := sampleTraceResult()
+	result.Hops[0].AvgRTT = 200
+
+	data, err := NewHTMLFormatter(Config{}).Format(result)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if !strings.Contains(string(data), `class="rtt bad"`) {
+		t.Error("200 ms should be critical with default thresholds")
+	}
+
+	data, err = NewHTMLFormatter(Config{RTTWarnMs: 600, RTTCritMs: 1200}).Format(result)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if strings.Contains(string(data), `class="rtt bad"`) {
+		t.Error("HTML report should use the configured thresholds")
+	}
+}
+
+func TestClassifyLoss(t *testing.T) {
+	tests := []struct {
+		name   string
+		loss   float64
+		warn   float64
+		crit   float64
+		expect Level
+	}{
+		{"no loss", 0, 0, 10, LevelNormal},
+		{"some loss", 5, 0, 10, LevelWarn},
+		{"crit boundary", 10, 0, 10, LevelWarn},
+		{"crit", 33, 0, 10, LevelCrit},
+		{"total loss", 100, 0, 10, LevelCrit},
+		{"default crit", 20, 0, 0, LevelCrit},
+		{"tolerant", 20, 25, 50, LevelNormal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyLoss(tt.loss, tt.warn, tt.crit); got != tt.expect {
+				t.Errorf("ClassifyLoss(%v, %v, %v) = %v, want %v", tt.loss, tt.warn, tt.crit, got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestFormatters_LossThresholds(t *testing.T) {
+	config := DefaultConfig()
+	scheme := forcedColorScheme()
+
+	table := NewTableFormatter(config)
+	table.colors = scheme
+	if got := table.formatLoss(0); got != "0%" {
+		t.Errorf("formatLoss(0) = %q, want plain %q", got, "0%")
+	}
+	if got, want := table.formatLoss(5), scheme.LossWarn.Sprint("5%"); got != want {
+		t.Errorf("formatLoss(5) = %q, want %q", got, want)
+	}
+	if got, want := table.formatLoss(50), scheme.LossCrit.Sprint("50%"); got != want {
+		t.Errorf("formatLoss(50) = %q, want %q", got, want)
+	}
+
+	result := sampleTraceResult()
+	result.Hops[0].LossPercent = 5
+	result.Hops[1].LossPercent = 66.7
+
+	data, err := NewHTMLFormatter(config).Format(result)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	html := string(data)
+
+	for _, class := range []string{`class="loss loss-warn"`, `class="loss loss-crit"`, `<tr class="loss-crit">`} {
+		if !strings.Contains(html, class) {
+			t.Errorf("HTML report should contain %s", class)
+		}
+	}
+	if strings.Count(html, `<tr class="loss-crit">`) != 1 {
+		t.Error("Only the responding hop above the critical threshold should be bold")
+	}
+}
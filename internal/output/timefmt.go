@@ -0,0 +1,56 @@
+package output
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Named timestamp formats accepted by Config.TimeFormat. Any other
+// non-empty value is treated as a Go time layout.
+const (
+	TimeFormatRFC3339 = "rfc3339"
+	TimeFormatUnix    = "unix"
+	TimeFormatLocal   = "local"
+)
+
+// layoutProbe is used to check that a custom layout contains at least one
+// Go reference time element: formatting it changes any such element.
+var layoutProbe = time.Date(2011, 11, 11, 11, 11, 11, 0, time.FixedZone("XYZ", 11*60*60))
+
+// ValidateTimeFormat checks a --time-format value. Custom layouts must
+// contain at least one element of Go's reference time (e.g. "2006").
+func ValidateTimeFormat(format string) error {
+	switch strings.ToLower(format) {
+	case "", TimeFormatRFC3339, TimeFormatUnix, TimeFormatLocal:
+		return nil
+	}
+
+	if layoutProbe.Format(format) == format {
+		return fmt.Errorf("invalid time format %q: want rfc3339, unix, local or a Go layout such as \"2006-01-02 15:04:05\"", format)
+	}
+	return nil
+}
+
+// FormatTime formats t according to TimeFormat and UTC. defaultLayout is
+// the formatter's own layout, used when no time format is configured and
+// for "local".
+func (c Config) FormatTime(t time.Time, defaultLayout string) string {
+	if c.UTC {
+		t = t.UTC()
+	}
+
+	switch strings.ToLower(c.TimeFormat) {
+	case "":
+		return t.Format(defaultLayout)
+	case TimeFormatRFC3339:
+		return t.Format(time.RFC3339)
+	case TimeFormatUnix:
+		return strconv.FormatInt(t.Unix(), 10)
+	case TimeFormatLocal:
+		return t.Local().Format(defaultLayout)
+	default:
+		return t.Format(c.TimeFormat)
+	}
+}
@@ -0,0 +1,109 @@
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateTimeFormat(t *testing.T) {
+	tests := []struct {
+		format  string
+		wantErr bool
+	}{
+		{"", false},
+		{"rfc3339", false},
+		{"UNIX", false},
+		{"local", false},
+		{"2006-01-02 15:04", false},
+		{"Jan _2 15:04:05", false},
+		{"yyyy-mm-dd", true},
+		{"bogus", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			err := ValidateTimeFormat(tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateTimeFormat(%q) error = %v, wantErr %v", tt.format, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_FormatTime(t *testing.T) {
+	zone := time.FixedZone("TRT", 3*60*60)
+	ts := time.Date(2025, 12, 18, 15, 0, 0, 0, zone)
+
+	tests := []struct {
+		name   string
+		config Config
+		want   string
+	}{
+		{"default layout", Config{}, "2025-12-18 15:00:00"},
+		{"rfc3339", Config{TimeFormat: "rfc3339"}, "2025-12-18T15:00:00+03:00"},
+		{"rfc3339 utc", Config{TimeFormat: "rfc3339", UTC: true}, "2025-12-18T12:00:00Z"},
+		{"unix", Config{TimeFormat: "unix"}, "1766059200"},
+		{"utc default layout", Config{UTC: true}, "2025-12-18 12:00:00"},
+		{"custom layout", Config{TimeFormat: "02/01/2006 15h", UTC: true}, "18/12/2025 12h"},
+		{"local", Config{TimeFormat: "local"}, ts.Local().Format("2006-01-02 15:04:05")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.FormatTime(ts, "2006-01-02 15:04:05"); got != tt.want {
+				t.Errorf("FormatTime() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatters_TimeFormat(t *testing.T) {
+	result := sampleTraceResult()
+	result.Timestamp = time.Date(2025, 12, 18, 15, 0, 0, 0, time.FixedZone("TRT", 3*60*60))
+	config := Config{TimeFormat: "unix"}
+
+	data, err := NewJSONFormatter(config).Format(result)
+	if err != nil {
+		t.Fatalf("JSON Format() error = %v", err)
+	}
+	var out JSONOutput
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if out.Timestamp != "1766059200" {
+		t.Errorf("JSON timestamp = %q, want epoch seconds", out.Timestamp)
+	}
+
+	data, err = NewCSVFormatter(config).Format(result)
+	if err != nil {
+		t.Fatalf("CSV Format() error = %v", err)
+	}
+	records, err := csv.NewReader(strings.NewReader(string(data))).ReadAll()
+	if err != nil {
+		t.Fatalf("CSV parse error = %v", err)
+	}
+	last := len(records[0]) - 1
+	if records[0][last] != "timestamp" || records[1][last] != "1766059200" {
+		t.Errorf("CSV timestamp column = %q/%q, want timestamp/1766059200", records[0][last], records[1][last])
+	}
+
+	utc := Config{TimeFormat: "rfc3339", UTC: true}
+	data, err = NewHTMLFormatter(utc).Format(result)
+	if err != nil {
+		t.Fatalf("HTML Format() error = %v", err)
+	}
+	if !strings.Contains(string(data), "2025-12-18T12:00:00Z") {
+		t.Error("HTML should render the timestamp in UTC RFC3339")
+	}
+
+	data, err = NewTableFormatter(utc).Format(result)
+	if err != nil {
+		t.Fatalf("Table Format() error = %v", err)
+	}
+	if !strings.Contains(string(data), "Time: 2025-12-18T12:00:00Z") {
+		t.Error("Table header should render the timestamp in UTC RFC3339")
+	}
+}
@@ -1,76 +1,163 @@
 package output
 
 import (
+	"bufio"
 	"io"
 	"os"
 
+	"github.com/KilimcininKorOglu/poros/internal/term"
 	"github.com/KilimcininKorOglu/poros/internal/trace"
-	"github.com/mattn/go-isatty"
 )
 
 // Writer handles output formatting and writing.
+//
+// The formatter is created lazily on first write so that the color
+// decision is made against the final destination: colors are only kept
+// where term.Detect allows them, normally on a terminal.
 type Writer struct {
+	format    Format
+	config    Config
 	formatter Formatter
+	custom    bool // formatter supplied by the caller, never rebuilt
 	output    io.Writer
+	buffered  *bufio.Writer
 	isTTY     bool
+	colors    bool          // the destination may be colored
+	size      *TerminalSize // terminal output is fitted to; nil if not a TTY
 }
 
-// NewWriter creates a new output writer.
+// NewWriter creates a new output writer that writes to stdout.
 func NewWriter(format Format, config Config) *Writer {
-	// Auto-detect TTY and disable colors if not a terminal
-	isTTY := isTerminal(os.Stdout)
-	if !isTTY {
-		config.Colors = false
-	}
+	return NewWriterTo(os.Stdout, format, config)
+}
 
-	return &Writer{
-		formatter: NewFormatter(format, config),
-		output:    os.Stdout,
-		isTTY:     isTTY,
+// NewWriterTo creates a new output writer for the given destination.
+func NewWriterTo(output io.Writer, format Format, config Config) *Writer {
+	w := &Writer{
+		format: format,
+		config: config,
 	}
+	w.SetOutput(output)
+	return w
 }
 
 // NewWriterWithFormatter creates a writer with a specific formatter.
+// The formatter is used as-is; its color setting is not adjusted.
 func NewWriterWithFormatter(formatter Formatter, output io.Writer) *Writer {
-	isTTY := false
-	if f, ok := output.(*os.File); ok {
-		isTTY = isTerminal(f)
-	}
-
-	return &Writer{
-		formatter: formatter,
-		output:    output,
-		isTTY:     isTTY,
-	}
+	w := &Writer{formatter: formatter, custom: true}
+	w.SetOutput(output)
+	return w
 }
 
 // Write formats and writes the trace result.
 func (w *Writer) Write(result *trace.TraceResult) error {
-	data, err := w.formatter.Format(result)
+	data, err := w.Formatter().Format(result)
 	if err != nil {
 		return err
 	}
 
-	_, err = w.output.Write(data)
-	if err != nil {
+	if _, err := w.dest().Write(data); err != nil {
 		return err
 	}
 
-	// Flush output if it's a file (ensures output is visible immediately)
-	if f, ok := w.output.(*os.File); ok {
-		f.Sync()
+	return w.Flush()
+}
+
+// Streaming reports whether the formatter can write hops incrementally.
+func (w *Writer) Streaming() bool {
+	_, ok := w.Formatter().(StreamFormatter)
+	return ok
+}
+
+// WriteHeader writes the streaming header. It is a no-op for formatters
+// that do not support streaming.
+func (w *Writer) WriteHeader(target string, maxHops int) error {
+	sf, ok := w.Formatter().(StreamFormatter)
+	if !ok {
+		return nil
+	}
+	return w.writeString(sf.FormatHeader(target, maxHops))
+}
+
+// WritePartialHeader writes the streaming header of a partial-path trace,
+// falling back to the regular header if the formatter has no dedicated one.
+func (w *Writer) WritePartialHeader(target string, firstHop, lastHop int) error {
+	if pf, ok := w.Formatter().(PartialHeaderFormatter); ok {
+		return w.writeString(pf.FormatPartialHeader(target, firstHop, lastHop))
 	}
+	return w.WriteHeader(target, lastHop)
+}
+
+// WriteHop writes a single hop as soon as it is available. It is a no-op
+// for formatters that do not support streaming.
+func (w *Writer) WriteHop(hop *trace.Hop) error {
+	sf, ok := w.Formatter().(StreamFormatter)
+	if !ok {
+		return nil
+	}
+	return w.writeString(sf.FormatHop(hop))
+}
 
+// WriteSkipped writes the collapsed private prefix. It is a no-op for
+// formatters that do not support streaming.
+func (w *Writer) WriteSkipped(skipped *trace.SkippedHops) error {
+	sf, ok := w.Formatter().(StreamFormatter)
+	if !ok {
+		return nil
+	}
+	return w.writeString(sf.FormatSkipped(skipped))
+}
+
+// WriteSummary writes the streaming footer once the trace has finished.
+// For formatters that do not support streaming the full result is written.
+func (w *Writer) WriteSummary(result *trace.TraceResult) error {
+	sf, ok := w.Formatter().(StreamFormatter)
+	if !ok {
+		return w.Write(result)
+	}
+	return w.writeString(sf.FormatSummary(result))
+}
+
+// writeString writes s and flushes it so streamed lines appear immediately.
+func (w *Writer) writeString(s string) error {
+	if _, err := io.WriteString(w.dest(), s); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// Flush writes any buffered data to the destination. Files, including
+// stdout, are written unbuffered and need no flushing.
+func (w *Writer) Flush() error {
+	if w.buffered != nil {
+		return w.buffered.Flush()
+	}
 	return nil
 }
 
-// SetOutput changes the output destination.
+// SetOutput changes the output destination. Files are written directly;
+// any other writer is buffered until Flush.
 func (w *Writer) SetOutput(output io.Writer) {
 	w.output = output
+	w.buffered = nil
+	w.isTTY = false
+	w.colors = false
+	w.size = nil
+
 	if f, ok := output.(*os.File); ok {
-		w.isTTY = isTerminal(f)
+		stream := term.Detect(f)
+		w.isTTY = stream.TTY
+		w.colors = stream.Colors.Enabled()
+		if w.isTTY {
+			w.size = NewTerminalSize(f)
+		}
 	} else {
-		w.isTTY = false
+		w.buffered = bufio.NewWriter(output)
+	}
+
+	// Rebuild the formatter for the new destination's TTY state
+	if !w.custom {
+		w.formatter = nil
 	}
 }
 
@@ -81,15 +168,32 @@ func (w *Writer) IsTTY() bool {
 
 // Formatter returns the underlying formatter.
 func (w *Writer) Formatter() Formatter {
+	if w.formatter == nil {
+		config := w.config
+		if !w.colors {
+			config.Colors = false
+		}
+		w.formatter = NewFormatter(w.format, config)
+		if sa, ok := w.formatter.(SizeAware); ok && w.size != nil {
+			sa.SetSizeProvider(w.size)
+		}
+	}
 	return w.formatter
 }
 
-// isTerminal checks if the given file is a terminal.
-func isTerminal(f *os.File) bool {
-	if f == nil {
-		return false
+// TerminalSize returns the size of the terminal written to, or nil if the
+// output is not a terminal. Call Refresh on it when the terminal is
+// resized.
+func (w *Writer) TerminalSize() *TerminalSize {
+	return w.size
+}
+
+// dest returns the writer to format into.
+func (w *Writer) dest() io.Writer {
+	if w.buffered != nil {
+		return w.buffered
 	}
-	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+	return w.output
 }
 
 // WriteToFile writes the trace result to a file.
@@ -0,0 +1,144 @@
+package output
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWriter_AllFormats(t *testing.T) {
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{FormatText, "Trace complete."},
+		{FormatVerbose, "RTT SAMPLES"},
+		{FormatJSON, `"target": "google.com"`},
+		{FormatCSV, "hop,ip,hostname"},
+		{FormatHTML, "<!DOCTYPE html>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			writer := NewWriterTo(&buf, tt.format, DefaultConfig())
+
+			if err := writer.Write(sampleTraceResult()); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+
+			output := buf.String()
+			if !strings.Contains(output, tt.want) {
+				t.Errorf("output should contain %q, got:\n%s", tt.want, output)
+			}
+			if strings.Contains(output, "\x1b[") {
+				t.Error("colors should be disabled for non-terminal destinations")
+			}
+		})
+	}
+}
+
+func TestWriter_Streaming(t *testing.T) {
+	result := sampleTraceResult()
+
+	var buf bytes.Buffer
+	writer := NewWriterTo(&buf, FormatText, Config{})
+	if !writer.Streaming() {
+		t.Fatal("text writer should support streaming")
+	}
+
+	writer.WriteHeader("google.com", 30)
+	writer.WriteHop(&result.Hops[0])
+	if !strings.Contains(buf.String(), "192.168.1.1") {
+		t.Error("WriteHop should flush the hop to the destination immediately")
+	}
+	for i := 1; i < len(result.Hops); i++ {
+		writer.WriteHop(&result.Hops[i])
+	}
+	if err := writer.WriteSummary(result); err != nil {
+		t.Fatalf("WriteSummary() error = %v", err)
+	}
+
+	output := buf.String()
+	if !strings.HasPrefix(output, "traceroute to google.com, 30 hops max") {
+		t.Errorf("output should start with the streaming header, got:\n%s", output)
+	}
+	if !strings.HasSuffix(output, "Trace complete. 3 hops, 5.55 ms total\n") {
+		t.Errorf("output should end with the summary, got:\n%s", output)
+	}
+	if strings.Count(output, "192.168.1.1") != 1 {
+		t.Error("hops should not be written twice")
+	}
+}
+
+func TestWriter_NonStreaming(t *testing.T) {
+	var buf bytes.Buffer
+	writer := NewWriterTo(&buf, FormatJSON, Config{})
+	if writer.Streaming() {
+		t.Fatal("JSON writer should not support streaming")
+	}
+
+	result := sampleTraceResult()
+	writer.WriteHeader("google.com", 30)
+	writer.WriteHop(&result.Hops[0])
+	if buf.Len() != 0 {
+		t.Errorf("streaming calls should be no-ops, got %q", buf.String())
+	}
+
+	if err := writer.WriteSummary(result); err != nil {
+		t.Fatalf("WriteSummary() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), `"hops"`) {
+		t.Error("WriteSummary should write the full result for non-streaming formats")
+	}
+}
+
+func TestWriter_SetOutput(t *testing.T) {
+	var first, second bytes.Buffer
+	writer := NewWriterTo(&first, FormatCSV, Config{})
+	writer.SetOutput(&second)
+
+	if err := writer.Write(sampleTraceResult()); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if first.Len() != 0 || second.Len() == 0 {
+		t.Error("output should go to the most recently set destination")
+	}
+}
+
+func TestWriter_PartialHeader(t *testing.T) {
+	var buf bytes.Buffer
+	writer := NewWriterTo(&buf, FormatText, Config{})
+	if err := writer.WritePartialHeader("google.com", 5, 9); err != nil {
+		t.Fatalf("WritePartialHeader() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "hops 5-9 only") {
+		t.Errorf("header = %q", buf.String())
+	}
+}
+
+func TestWriter_ColorsFollowTerm(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("CLICOLOR_FORCE", "")
+	if w := NewWriterTo(f, FormatText, DefaultConfig()); w.Formatter().(*TextFormatter).colors != nil {
+		t.Error("a regular file should not be colored")
+	}
+
+	t.Setenv("CLICOLOR_FORCE", "1")
+	if w := NewWriterTo(f, FormatText, DefaultConfig()); w.Formatter().(*TextFormatter).colors == nil {
+		t.Error("CLICOLOR_FORCE should color a regular file")
+	}
+
+	// Buffers are never colored
+	var buf strings.Builder
+	if w := NewWriterTo(&buf, FormatText, DefaultConfig()); w.Formatter().(*TextFormatter).colors != nil {
+		t.Error("a buffer should not be colored")
+	}
+}
@@ -0,0 +1,65 @@
+package probe
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// closeState makes a prober's Close idempotent and lets probes tell a
+// closed prober from a failing socket. Sockets are closed but never
+// cleared, so a probe racing Close fails on a closed socket instead of
+// dereferencing nil.
+type closeState struct {
+	once   sync.Once
+	closed atomic.Bool
+	err    error
+}
+
+// close runs fn on the first call and returns its error on every call.
+func (c *closeState) close(fn func() error) error {
+	c.once.Do(func() {
+		c.closed.Store(true)
+		c.err = fn()
+	})
+	return c.err
+}
+
+// isClosed reports whether Close has been called.
+func (c *closeState) isClosed() bool {
+	return c.closed.Load()
+}
+
+// socketError returns the error for a failed socket operation other than
+// a read: ErrSocketClosed if the prober was closed under it, otherwise
+// err, counted as a socket error.
+func (c *closeState) socketError(counters *Counters, err error) error {
+	if c.isClosed() || errors.Is(err, net.ErrClosed) {
+		return ErrSocketClosed
+	}
+	counters.CountSocketError()
+	return err
+}
+
+// sendError is socketError for a failed send, which a live prober
+// reports as a SendError.
+func (c *closeState) sendError(counters *Counters, err error) error {
+	if err = c.socketError(counters, err); err == ErrSocketClosed {
+		return err
+	}
+	return &SendError{Err: err}
+}
+
+// readError maps a failed read to the error a probe returns: ErrTimeout
+// when the deadline expired, ErrSocketClosed when the prober was closed.
+func readError(err error) error {
+	switch {
+	case isTimeoutError(err):
+		return ErrTimeout
+	case errors.Is(err, net.ErrClosed):
+		return ErrSocketClosed
+	}
+	return fmt.Errorf("read error: %w", err)
+}
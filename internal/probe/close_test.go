@@ -0,0 +1,214 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProbers_ProbeAfterClose(t *testing.T) {
+	probers := []Prober{
+		&ICMPProber{},
+		&UDPProber{},
+		&TCPProber{},
+		&ParisProber{},
+	}
+
+	for _, p := range probers {
+		t.Run(p.Name(), func(t *testing.T) {
+			for i := range 2 {
+				if err := p.Close(); err != nil {
+					t.Errorf("Close() #%d error = %v", i+1, err)
+				}
+			}
+			result, err := p.Probe(context.Background(), net.ParseIP("192.0.2.1"), 1)
+			if result != nil || !errors.Is(err, ErrSocketClosed) {
+				t.Errorf("Probe() after Close = %v, %v, want ErrSocketClosed", result, err)
+			}
+			if stats := p.Stats(); stats.Sent != 0 || stats.SocketErrors != 0 {
+				t.Errorf("Stats() after Close = %+v, want no sends or socket errors", stats)
+			}
+		})
+	}
+}
+
+func TestReadError(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on loopback: %v", err)
+	}
+	buf := make([]byte, 16)
+
+	conn.SetReadDeadline(time.Now())
+	_, _, timeoutErr := conn.ReadFrom(buf)
+	conn.Close()
+	_, _, closedErr := conn.ReadFrom(buf)
+
+	tests := []struct {
+		name         string
+		err          error
+		want         error
+		timeouts     uint64
+		socketErrors uint64
+	}{
+		{"deadline", timeoutErr, ErrTimeout, 1, 0},
+		{"closed", closedErr, ErrSocketClosed, 0, 0},
+		{"other", os.ErrPermission, os.ErrPermission, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c Counters
+			c.countReadError(tt.err)
+			if got := readError(tt.err); !errors.Is(got, tt.want) {
+				t.Errorf("readError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+			if stats := c.Stats(); stats.Timeouts != tt.timeouts || stats.SocketErrors != tt.socketErrors {
+				t.Errorf("counted %d timeouts, %d socket errors, want %d, %d",
+					stats.Timeouts, stats.SocketErrors, tt.timeouts, tt.socketErrors)
+			}
+		})
+	}
+}
+
+// TestProbers_CloseWhileReceiving closes each prober while a probe waits
+// for a reply with no deadline set, so only Close can end the wait.
+func TestProbers_CloseWhileReceiving(t *testing.T) {
+	if !canCreateRawSocket() {
+		t.Skip("Skipping: requires elevated privileges")
+	}
+
+	dest := net.ParseIP("192.0.2.1")
+	tests := []struct {
+		name    string
+		open    func() (Prober, error)
+		receive func(ctx context.Context, p Prober) (*Result, error)
+	}{
+		{
+			name: "icmp",
+			open: func() (Prober, error) { return NewICMPProber(ICMPProberConfig{}) },
+			receive: func(ctx context.Context, p Prober) (*Result, error) {
+				icmp := p.(*ICMPProber)
+				return icmp.waitForResponse(ctx, icmp.conn4, 1, dest, 1, time.Now())
+			},
+		},
+		{
+			name: "udp",
+			open: func() (Prober, error) { return NewUDPProber(DefaultUDPProberConfig()) },
+			receive: func(ctx context.Context, p Prober) (*Result, error) {
+				return p.(*UDPProber).receiveResponse(ctx, dest, 33435, time.Now(), 1)
+			},
+		},
+		{
+			name: "tcp",
+			open: func() (Prober, error) { return NewTCPProber(DefaultTCPProberConfig()) },
+			receive: func(ctx context.Context, p Prober) (*Result, error) {
+				tcp := p.(*TCPProber)
+				return tcp.receiveResponse(ctx, dest, tcp.localPort+1, 1, time.Now())
+			},
+		},
+		{
+			name: "paris-icmp",
+			open: func() (Prober, error) {
+				return NewParisProber(ParisProberConfig{Method: MethodICMP, FlowID: 0xBEEF})
+			},
+			receive: func(ctx context.Context, p Prober) (*Result, error) {
+				return p.(*ParisProber).receiveICMPResponse(ctx, dest, 0xBEEF, 1, time.Now())
+			},
+		},
+		{
+			name: "paris-udp",
+			open: func() (Prober, error) {
+				return NewParisProber(ParisProberConfig{Method: MethodUDP, FlowID: 0xBEEF, Port: 33434})
+			},
+			receive: func(ctx context.Context, p Prober) (*Result, error) {
+				return p.(*ParisProber).receiveUDPResponse(ctx, dest, 33434, 1, time.Now())
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := tt.open()
+			if err != nil {
+				t.Fatalf("open prober: %v", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			done := make(chan error, 1)
+			go func() {
+				_, err := tt.receive(ctx, p)
+				done <- err
+			}()
+
+			// Let the receive loop block in its read
+			time.Sleep(50 * time.Millisecond)
+			var wg sync.WaitGroup
+			for range 2 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					p.Close()
+				}()
+			}
+			wg.Wait()
+
+			select {
+			case err := <-done:
+				if !errors.Is(err, ErrSocketClosed) {
+					t.Errorf("receive error = %v, want ErrSocketClosed", err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("receive did not return within 1s of Close")
+			}
+			if stats := p.Stats(); stats.SocketErrors != 0 {
+				t.Errorf("closing counted %d socket errors, want 0", stats.SocketErrors)
+			}
+			if _, err := p.Probe(ctx, dest, 1); !errors.Is(err, ErrSocketClosed) {
+				t.Errorf("Probe() after Close error = %v, want ErrSocketClosed", err)
+			}
+		})
+	}
+}
+
+// TestICMPProber_ProbeRacesClose closes the prober while probes are being
+// sent; run with -race. Every probe must end with a result or an error,
+// never a panic, and probes after Close fail with ErrSocketClosed.
+func TestICMPProber_ProbeRacesClose(t *testing.T) {
+	if !canCreateRawSocket() {
+		t.Skip("Skipping: requires elevated privileges")
+	}
+
+	prober, err := NewICMPProber(ICMPProberConfig{Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("NewICMPProber() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 16 {
+				_, err := prober.Probe(context.Background(), net.ParseIP("127.0.0.1"), 64)
+				if err != nil && !errors.Is(err, ErrSocketClosed) && !errors.Is(err, ErrTimeout) {
+					errs <- fmt.Errorf("worker %d: %w", i, err)
+				}
+			}
+		}()
+	}
+	time.Sleep(5 * time.Millisecond)
+	prober.Close()
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("Probe() racing Close: unexpected error %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package probe
+
+import (
+	"sync"
+
+	"golang.org/x/net/icmp"
+)
+
+// replyTracker remembers which probes have been answered, so a second
+// reply to the same probe is counted as a duplicate instead of being
+// taken for another probe. Some devices send two Time Exceeded messages
+// for one probe. A probe is keyed by what identifies it on the wire: the
+// ICMP sequence number or the port offset of UDP and TCP probes. Keys are
+// reused once the sequence wraps, so sending a probe clears its key.
+type replyTracker struct {
+	mu       sync.Mutex
+	answered map[uint32]bool
+}
+
+// sent clears key for a newly sent probe.
+func (t *replyTracker) sent(key uint32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.answered, key)
+}
+
+// answer marks key as answered.
+func (t *replyTracker) answer(key uint32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.answered == nil {
+		t.answered = make(map[uint32]bool)
+	}
+	t.answered[key] = true
+}
+
+// isAnswered reports whether the probe with key was already answered.
+func (t *replyTracker) isAnswered(key uint32) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.answered[key]
+}
+
+// quotedTransport returns the transport header of the IPv4 datagram
+// quoted by an ICMP error, or nil if msg quotes none.
+func quotedTransport(msg *icmp.Message) (orig, header []byte) {
+	switch body := msg.Body.(type) {
+	case *icmp.TimeExceeded:
+		orig = body.Data
+	case *icmp.DstUnreach:
+		orig = body.Data
+	case *icmp.ParamProb:
+		orig = body.Data
+	default:
+		return nil, nil
+	}
+	if len(orig) < 28 {
+		return nil, nil
+	}
+	ihl := int(orig[0]&0x0f) * 4
+	if ihl < 20 || len(orig) < ihl+8 {
+		return nil, nil
+	}
+	return orig, orig[ihl:]
+}
@@ -0,0 +1,117 @@
+package probe
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// timeExceeded builds a Time Exceeded message quoting an IPv4 datagram
+// to dst whose transport header starts with payload.
+func timeExceeded(dst net.IP, payload []byte) *icmp.Message {
+	header := make([]byte, 20)
+	header[0] = 0x45 // version 4, 20-byte header
+	copy(header[16:20], dst.To4())
+	return &icmp.Message{Type: ipv4.ICMPTypeTimeExceeded,
+		Body: &icmp.TimeExceeded{Data: append(header, payload[:8]...)}}
+}
+
+func TestICMPProber_DuplicateReply(t *testing.T) {
+	p := &ICMPProber{identifier: 0x1234, socket: SocketRaw}
+	dest := net.ParseIP("198.51.100.7")
+	router := &net.IPAddr{IP: net.ParseIP("10.0.0.1")}
+
+	echo := make([]byte, 8)
+	echo[0] = 8 // Echo Request
+	binary.BigEndian.PutUint16(echo[4:6], 0x1234)
+	binary.BigEndian.PutUint16(echo[6:8], 7)
+	data, err := timeExceeded(dest, echo).Marshal(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The router answers probe 7 twice; the second copy arrives while
+	// probe 8 waits and must not become its reply
+	p.replies.sent(7)
+	if result, ok := p.handleReply(data, router, 1, dest, 7, time.Now()); !ok || !result.TTLExpired {
+		t.Fatalf("handleReply() = %+v, %v; want the reply to probe 7", result, ok)
+	}
+	p.replies.sent(8)
+	if result, ok := p.handleReply(data, router, 1, dest, 8, time.Now()); ok {
+		t.Fatalf("handleReply() = %+v; a duplicate must not answer probe 8", result)
+	}
+
+	s := p.Stats()
+	if s.Received != 1 || s.Duplicates != 1 || s.TotalDiscarded() != 0 {
+		t.Errorf("Stats() = %+v, want 1 received and 1 duplicate", s)
+	}
+
+	// Once sequence 7 is reused, a stray reply is a mismatch again
+	p.replies.sent(7)
+	p.handleReply(data, router, 1, dest, 8, time.Now())
+	if s := p.Stats(); s.Duplicates != 1 || s.Discarded[DiscardMismatch] != 1 {
+		t.Errorf("Stats() = %+v, want the reply counted as a mismatch", s)
+	}
+}
+
+func TestUDPProber_DuplicateReply(t *testing.T) {
+	p := &UDPProber{config: UDPProberConfig{BasePort: 33434}}
+	dest := net.ParseIP("198.51.100.7")
+
+	udp := make([]byte, 8)
+	binary.BigEndian.PutUint16(udp[2:4], 33435)
+	msg := timeExceeded(dest, udp)
+
+	p.replies.sent(1)
+	if result, ok := p.handleReply(msg, dest, 33435, 1); !ok || !result.TTLExpired {
+		t.Fatalf("handleReply() = %+v, %v; want the reply to probe 1", result, ok)
+	}
+	p.replies.sent(2)
+	if result, ok := p.handleReply(msg, dest, 33436, 2); ok {
+		t.Fatalf("handleReply() = %+v; a duplicate must not answer probe 2", result)
+	}
+
+	if s := p.Stats(); s.Received != 1 || s.Duplicates != 1 || s.TotalDiscarded() != 0 {
+		t.Errorf("Stats() = %+v, want 1 received and 1 duplicate", s)
+	}
+
+	// Replies quoting another destination are not ours
+	other := timeExceeded(net.ParseIP("203.0.113.1"), udp)
+	p.handleReply(other, dest, 33436, 2)
+	if s := p.Stats(); s.Duplicates != 1 || s.Discarded[DiscardMismatch] != 1 {
+		t.Errorf("Stats() = %+v, want the reply counted as a mismatch", s)
+	}
+}
+
+func TestTCPProber_DuplicateReply(t *testing.T) {
+	p := &TCPProber{config: TCPProberConfig{Port: 443}, localPort: 40000}
+	dest := net.ParseIP("198.51.100.7")
+
+	p.replies.answer(5)
+
+	synAck := make([]byte, 20)
+	binary.BigEndian.PutUint16(synAck[0:2], 443)
+	binary.BigEndian.PutUint16(synAck[2:4], 40005)
+	synAck[13] = 0x12
+	if !p.isDuplicateTCP(synAck) {
+		t.Error("a retransmitted SYN-ACK to an answered probe should be a duplicate")
+	}
+	binary.BigEndian.PutUint16(synAck[2:4], 40006)
+	if p.isDuplicateTCP(synAck) {
+		t.Error("a SYN-ACK to an unanswered probe is not a duplicate")
+	}
+
+	tcp := make([]byte, 8)
+	binary.BigEndian.PutUint16(tcp[0:2], 40005)
+	binary.BigEndian.PutUint16(tcp[2:4], 443)
+	if !p.isDuplicateICMP(timeExceeded(dest, tcp), dest) {
+		t.Error("a second Time Exceeded for an answered probe should be a duplicate")
+	}
+	if p.isDuplicateICMP(timeExceeded(net.ParseIP("203.0.113.1"), tcp), dest) {
+		t.Error("a Time Exceeded quoting another destination is not a duplicate")
+	}
+}
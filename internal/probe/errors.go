@@ -22,11 +22,20 @@ var (
 	// ErrSocketClosed indicates the socket has been closed
 	ErrSocketClosed = errors.New("socket closed")
 
+	// ErrUnknownMethod indicates a probe method name that is not recognized
+	ErrUnknownMethod = errors.New("unknown probe method")
+
 	// ErrInvalidTTL indicates the TTL value is out of range
 	ErrInvalidTTL = errors.New("TTL must be between 1 and 255")
 
 	// ErrNoResponse indicates no response was received (different from timeout)
 	ErrNoResponse = errors.New("no response received")
+
+	// ErrTooManyViaHops indicates a source route longer than MaxViaHops
+	ErrTooManyViaHops = errors.New("loose source route allows at most 8 via hops")
+
+	// ErrViaNotIPv4 indicates a source route hop that is not an IPv4 address
+	ErrViaNotIPv4 = errors.New("loose source route hops must be IPv4 addresses")
 )
 
 // IsTimeout returns true if the error indicates a timeout.
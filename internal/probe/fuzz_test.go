@@ -0,0 +1,272 @@
+package probe
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// The fuzz targets feed the parsers and matchers arbitrary bytes, as a
+// hostile network could: they may reject anything, but must never panic.
+// Run one with e.g. go test -fuzz=FuzzICMPProberReply ./internal/probe.
+// Inputs that once crashed are kept in testdata/fuzz.
+
+var (
+	fuzzDest4 = net.ParseIP("198.51.100.7")
+	fuzzDest6 = net.ParseIP("2001:db8::7")
+)
+
+// Identifiers of the probes the seed packets answer.
+const (
+	fuzzEchoID  = 0x1234
+	fuzzSeq     = 7
+	fuzzUDPPort = 33441 // BasePort 33434 + seq
+	fuzzTCPPort = 50007 // local port 50000 + seq
+)
+
+// seedPacket is a wire-format ICMP message as a probe socket receives it.
+type seedPacket struct {
+	ipv6 bool
+	data []byte
+}
+
+// seedPackets returns the replies real routers and destinations send to
+// each kind of probe: Echo Replies, Time Exceeded and Destination
+// Unreachable quoting ICMP, UDP and TCP probes in full and truncated, a
+// Time Exceeded with MPLS and interface extensions, a source route
+// rejection and a redirect.
+func seedPackets(f *testing.F) []seedPacket {
+	f.Helper()
+
+	echo := make([]byte, 8)
+	echo[0] = 8 // Echo Request
+	binary.BigEndian.PutUint16(echo[4:6], fuzzEchoID)
+	binary.BigEndian.PutUint16(echo[6:8], fuzzSeq)
+
+	udp := make([]byte, 16)
+	binary.BigEndian.PutUint16(udp[0:2], 40000)
+	binary.BigEndian.PutUint16(udp[2:4], fuzzUDPPort)
+	binary.BigEndian.PutUint16(udp[4:6], 40)
+	udp[8], udp[9], udp[10], udp[11] = 0x42, 0x42, 0x00, fuzzSeq
+
+	syn := make([]byte, 20)
+	binary.BigEndian.PutUint16(syn[0:2], fuzzTCPPort)
+	binary.BigEndian.PutUint16(syn[2:4], 443)
+	binary.BigEndian.PutUint32(syn[4:8], fuzzSeq)
+	syn[12], syn[13] = 0x50, 0x02 // 20-byte header, SYN
+
+	echo6 := append([]byte(nil), echo...)
+	echo6[0] = 128 // ICMPv6 Echo Request
+
+	messages := []struct {
+		ipv6 bool
+		msg  icmp.Message
+	}{
+		{false, icmp.Message{Type: ipv4.ICMPTypeEchoReply,
+			Body: &icmp.Echo{ID: fuzzEchoID, Seq: fuzzSeq, Data: TimestampPayload(make([]byte, 48))}}},
+		{false, icmp.Message{Type: ipv4.ICMPTypeTimeExceeded,
+			Body: &icmp.TimeExceeded{Data: quotedDatagram(1, fuzzDest4, echo)[:28]}}},
+		{false, icmp.Message{Type: ipv4.ICMPTypeTimeExceeded,
+			Body: &icmp.TimeExceeded{Data: quotedDatagram(17, fuzzDest4, udp)}}},
+		{false, icmp.Message{Type: ipv4.ICMPTypeTimeExceeded,
+			Body: &icmp.TimeExceeded{Data: quotedDatagram(17, fuzzDest4, udp)[:20]}}},
+		{false, icmp.Message{Type: ipv4.ICMPTypeDestinationUnreachable, Code: 3,
+			Body: &icmp.DstUnreach{Data: quotedDatagram(17, fuzzDest4, udp)}}},
+		{false, icmp.Message{Type: ipv4.ICMPTypeTimeExceeded,
+			Body: &icmp.TimeExceeded{Data: quotedDatagram(6, fuzzDest4, syn)[:28]}}},
+		{false, icmp.Message{Type: ipv4.ICMPTypeTimeExceeded,
+			Body: &icmp.TimeExceeded{Data: quotedDatagram(1, fuzzDest4, echo)[:28],
+				Extensions: []icmp.Extension{mplsObject(), interfaceObject(0, "xe-0/0/1", 517)}}}},
+		{false, icmp.Message{Type: ipv4.ICMPTypeParameterProblem,
+			Body: &icmp.ParamProb{Pointer: 20, Data: quotedPacket(net.ParseIP("10.0.0.5"), echo)}}},
+		{false, *redirectMessage(net.ParseIP("192.168.1.254"), fuzzDest4, echo)},
+		{true, icmp.Message{Type: ipv6.ICMPTypeEchoReply,
+			Body: &icmp.Echo{ID: fuzzEchoID, Seq: fuzzSeq, Data: TimestampPayload(make([]byte, 48))}}},
+		{true, icmp.Message{Type: ipv6.ICMPTypeTimeExceeded,
+			Body: &icmp.TimeExceeded{Data: quotedDatagram6(58, fuzzDest6, echo6)}}},
+		{true, icmp.Message{Type: ipv6.ICMPTypeTimeExceeded,
+			Body: &icmp.TimeExceeded{Data: quotedDatagram6(6, fuzzDest6, syn)}}},
+		{true, icmp.Message{Type: ipv6.ICMPTypeDestinationUnreachable, Code: 4,
+			Body: &icmp.DstUnreach{Data: quotedDatagram6(17, fuzzDest6, udp)}}},
+	}
+
+	seeds := make([]seedPacket, 0, len(messages))
+	for _, m := range messages {
+		data, err := m.msg.Marshal(nil)
+		if err != nil {
+			f.Fatalf("Marshal(%v) error = %v", m.msg.Type, err)
+		}
+		seeds = append(seeds, seedPacket{ipv6: m.ipv6, data: data})
+	}
+	return seeds
+}
+
+// quotedDatagram6 builds an IPv6 datagram to dst carrying transport, as
+// an ICMPv6 error quotes it.
+func quotedDatagram6(next int, dst net.IP, transport []byte) []byte {
+	data := make([]byte, 40, 40+len(transport))
+	data[0] = 0x60 // version 6
+	data[6] = byte(next)
+	copy(data[24:40], dst.To16())
+	return append(data, transport...)
+}
+
+// addSeeds adds every seed packet to the corpus of a target taking the
+// packet and whether it arrived on an ICMPv6 socket.
+func addSeeds(f *testing.F) {
+	for _, seed := range seedPackets(f) {
+		f.Add(seed.data, seed.ipv6)
+	}
+}
+
+// icmpProto returns the protocol number icmp.ParseMessage expects.
+func icmpProto(ipv6 bool) int {
+	if ipv6 {
+		return 58
+	}
+	return 1
+}
+
+func FuzzParseICMPPacket(f *testing.F) {
+	for _, seed := range seedPackets(f) {
+		f.Add(seed.data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		p, err := ParseICMPPacket(data)
+		if err != nil {
+			return
+		}
+		if p.Identifier != binary.BigEndian.Uint16(data[4:6]) || p.Sequence != binary.BigEndian.Uint16(data[6:8]) {
+			t.Errorf("ParseICMPPacket() = %+v, header fields do not match the input", p)
+		}
+		ExtractTimestamp(p.Payload)
+	})
+}
+
+func FuzzMatchQuote(f *testing.F) {
+	for _, seed := range seedPackets(f) {
+		msg, err := icmp.ParseMessage(icmpProto(seed.ipv6), seed.data)
+		if err != nil {
+			f.Fatalf("seed does not parse: %v", err)
+		}
+		if orig, _ := quotedTransport(msg); orig != nil {
+			f.Add(orig)
+		}
+	}
+	f.Add(quotedDatagram6(17, fuzzDest6, make([]byte, 8)))
+	f.Add([]byte{})
+
+	udp := &UDPProber{config: UDPProberConfig{BasePort: 33434, PayloadSize: 32}, id: 0x4242}
+	tcp := &TCPProber{config: TCPProberConfig{Port: 443}, localPort: 50000}
+	matchID := func(id uint16) bool { return id == fuzzEchoID }
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, parsed := parseQuote(data)
+		for _, dest := range []net.IP{fuzzDest4, fuzzDest6} {
+			udp.matchOriginalUDP(data, dest, fuzzUDPPort)
+			tcp.matchOriginalTCP(data, dest, fuzzTCPPort)
+			quality := tcp.quotedProbe(fuzzTCPPort, fuzzSeq).match(data, dest, []net.IP{fuzzDest4})
+			if !parsed && quality != MatchNone {
+				t.Errorf("match() = %v for a quote that does not parse", quality)
+			}
+			matchQuotedEcho(data, dest, nil, matchID, fuzzSeq)
+		}
+	})
+}
+
+func FuzzICMPProberReply(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte, ipv6 bool) {
+		dest := fuzzDest4
+		if ipv6 {
+			dest = fuzzDest6
+		}
+		p := &ICMPProber{identifier: fuzzEchoID, socket: SocketRaw, ipv6: ipv6}
+		peer := &net.IPAddr{IP: net.ParseIP("10.0.0.1")}
+
+		result, ok := p.handleReply(data, peer, icmpProto(ipv6), dest, fuzzSeq, time.Now())
+		if ok != (result != nil) {
+			t.Fatalf("handleReply() = %+v, %v", result, ok)
+		}
+		// A second copy is counted as a duplicate
+		p.handleReply(data, peer, icmpProto(ipv6), dest, fuzzSeq+1, time.Now())
+	})
+}
+
+func FuzzUDPProberReply(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte, ipv6 bool) {
+		dest := fuzzDest4
+		if ipv6 {
+			dest = fuzzDest6
+		}
+		msg, err := icmp.ParseMessage(icmpProto(ipv6), data)
+		if err != nil {
+			return
+		}
+		p := &UDPProber{config: UDPProberConfig{BasePort: 33434, PayloadSize: 32, IPv6: ipv6}, id: 0x4242}
+
+		result, ok := p.handleReply(msg, dest, fuzzUDPPort, fuzzSeq)
+		if ok != (result != nil) {
+			t.Fatalf("handleReply() = %+v, %v", result, ok)
+		}
+		p.handleReply(msg, dest, fuzzUDPPort+1, fuzzSeq+1)
+	})
+}
+
+func FuzzTCPProberReply(f *testing.F) {
+	addSeeds(f)
+	for _, segment := range [][]byte{
+		{0x01, 0xbb, 0xc3, 0x57, 0, 0, 0, 0, 0, 0, 0, 8, 0x50, 0x12, 0xff, 0xff, 0, 0, 0, 0}, // SYN-ACK
+		{0x01, 0xbb, 0xc3, 0x57, 0, 0, 0, 0, 0, 0, 0, 8, 0x50, 0x14, 0, 0, 0, 0, 0, 0},       // RST-ACK
+	} {
+		f.Add(segment, false)
+	}
+	f.Fuzz(func(t *testing.T, data []byte, ipv6 bool) {
+		dest := fuzzDest4
+		if ipv6 {
+			dest = fuzzDest6
+		}
+		p := &TCPProber{config: TCPProberConfig{Port: 443, IPv6: ipv6}, localPort: 50000}
+
+		if result, ok := p.parseICMPResponse(data, dest, fuzzTCPPort, fuzzSeq); ok != (result != nil) {
+			t.Fatalf("parseICMPResponse() = %+v, %v", result, ok)
+		}
+		if msg, err := icmp.ParseMessage(icmpProto(ipv6), data); err == nil {
+			p.isDuplicateICMP(msg, dest)
+		}
+		if result, ok := p.parseTCPResponse(data, dest, fuzzTCPPort); ok != (result != nil) {
+			t.Fatalf("parseTCPResponse() = %+v, %v", result, ok)
+		}
+		p.isDuplicateTCP(data)
+	})
+}
+
+func FuzzParisProberReply(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte, ipv6 bool) {
+		dest := fuzzDest4
+		if ipv6 {
+			dest = fuzzDest6
+		}
+		msg, err := icmp.ParseMessage(icmpProto(ipv6), data)
+		if err != nil {
+			return
+		}
+		p := &ParisProber{config: ParisProberConfig{Port: 33434, IPv6: ipv6}, flowID: fuzzEchoID}
+
+		if result, ok := p.matchICMPResponse(msg, dest, fuzzEchoID, fuzzSeq); ok != (result != nil) {
+			t.Fatalf("matchICMPResponse() = %+v, %v", result, ok)
+		}
+		if result, ok := p.matchUDPResponse(msg, dest, fuzzUDPPort, fuzzSeq); ok != (result != nil) {
+			t.Fatalf("matchUDPResponse() = %+v, %v", result, ok)
+		}
+		interfaceFromMessage(msg)
+		p.countUnmatched(data, icmpProto(ipv6))
+	})
+}
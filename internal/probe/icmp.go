@@ -1,11 +1,17 @@
 package probe
 
 import (
+	"bytes"
 	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
+	"math/rand/v2"
 	"net"
 	"os"
+	"runtime"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -16,19 +22,122 @@ import (
 
 // ICMPProber implements the Prober interface using ICMP Echo requests.
 type ICMPProber struct {
+	Counters
+	RedirectLog
+	closeState
+
 	conn4      *icmp.PacketConn // IPv4 connection
 	conn6      *icmp.PacketConn // IPv6 connection
 	identifier uint16
 	sequence   uint32
 	timeout    time.Duration
 	ipv6       bool
+	zone       string // IPv6 zone of link-local destinations
+	socket     string // SocketRaw or SocketDgram
+
+	// token is carried in every Echo Request payload. Where datagram
+	// sockets share Echo Replies (sharedReplies), it tells ours apart
+	token         [8]byte
+	sharedReplies bool
+
+	// routed sends loose source-routed IPv4 probes; replies still arrive
+	// on conn4 (nil without a source route)
+	routed *net.IPConn
+
+	// via is the loose source route of IPv4 probes, whose hops ICMP
+	// errors may quote as the destination
+	via []net.IP
+
+	// replies tracks answered sequence numbers to spot duplicates
+	replies replyTracker
+
+	// pending counts probes awaiting a reply, for weak matches
+	pending pendingProbes
+}
+
+// listenICMP opens ICMP sockets; replaced in tests.
+var listenICMP = icmp.ListenPacket
+
+// The datagram socket warning is written once per process, and only on
+// platforms where such sockets cannot see Time Exceeded messages.
+var (
+	dgramWarnOnce   sync.Once
+	dgramWarnOutput io.Writer = os.Stderr
+)
+
+// dgramMissesTimeExceeded reports whether unprivileged ICMP datagram
+// sockets fail to deliver Time Exceeded messages. Linux queues them on
+// the socket error queue instead, so every intermediate hop shows "*".
+func dgramMissesTimeExceeded() bool {
+	return runtime.GOOS == "linux"
+}
+
+// SeesIntermediateHops reports whether a prober receiving on the given
+// socket kind gets Time Exceeded messages from the hops before the
+// destination.
+func SeesIntermediateHops(socket string) bool {
+	return socket != SocketDgram || !dgramMissesTimeExceeded()
+}
+
+// SilenceDgramWarning turns off the datagram socket warning for the rest
+// of the process, for output that must stay machine-readable.
+func SilenceDgramWarning() {
+	dgramWarnOnce.Do(func() {})
+}
+
+// dgramSharesEchoReplies reports whether unprivileged ICMP datagram
+// sockets receive the Echo Replies of other sockets too. macOS delivers
+// every Echo Reply to each datagram socket and, depending on the version,
+// rewrites the identifier, so neither the identifier nor the sequence
+// number alone identifies a reply. Linux demultiplexes replies per socket.
+func dgramSharesEchoReplies() bool {
+	return runtime.GOOS == "darwin" || runtime.GOOS == "ios"
+}
+
+// openICMPSocket opens a raw ICMP socket, falling back to an unprivileged
+// datagram ("ping") socket. If both fail, the error names both attempts so
+// the original permission problem is not hidden.
+func openICMPSocket(ipv6 bool) (*icmp.PacketConn, string, error) {
+	rawNetwork, dgramNetwork, address := "ip4:icmp", "udp4", "0.0.0.0"
+	if ipv6 {
+		rawNetwork, dgramNetwork, address = "ip6:ipv6-icmp", "udp6", "::"
+	}
+
+	conn, rawErr := listenICMP(rawNetwork, address)
+	if rawErr == nil {
+		return conn, SocketRaw, nil
+	}
+
+	conn, dgramErr := listenICMP(dgramNetwork, address)
+	if dgramErr == nil {
+		if dgramMissesTimeExceeded() {
+			dgramWarnOnce.Do(func() {
+				fmt.Fprintf(dgramWarnOutput, "Warning: raw ICMP socket unavailable (%v); using an unprivileged datagram socket.\n"+
+					"Intermediate hops will likely show as \"*\" on %s. Run with sudo or grant the capability:\n"+
+					"  sudo setcap cap_net_raw+ep $(which poros)\n", rawErr, runtime.GOOS)
+			})
+		}
+		return conn, SocketDgram, nil
+	}
+
+	err := errors.Join(
+		fmt.Errorf("raw socket (%s): %w", rawNetwork, rawErr),
+		fmt.Errorf("datagram socket (%s): %w", dgramNetwork, dgramErr),
+	)
+	if errors.Is(rawErr, os.ErrPermission) {
+		err = errors.Join(ErrPermissionDenied, err)
+	}
+	return nil, "", err
 }
 
 // ICMPProberConfig holds configuration for the ICMP prober.
 type ICMPProberConfig struct {
 	Timeout    time.Duration
 	IPv6       bool
-	Identifier uint16 // If 0, uses process ID
+	Identifier uint16   // If 0, uses process ID
+	SeqStart   uint16   // Sequence number of the first probe (0 = 1)
+	Via        []net.IP // Loose source route through these IPv4 routers
+	Zone       string   // IPv6 zone of link-local destinations, e.g. "eth0"
 }
 
 // NewICMPProber creates a new ICMP prober.
@@ -44,31 +153,68 @@ func NewICMPProber(config ICMPProberConfig) (*ICMPProber, error) {
 
 	p := &ICMPProber{
 		identifier: identifier,
+		sequence:   initialSequence(config.SeqStart),
 		timeout:    config.Timeout,
 		ipv6:       config.IPv6,
+		zone:       config.Zone,
 	}
 
-	var err error
-	if config.IPv6 {
-		p.conn6, err = icmp.ListenPacket("ip6:ipv6-icmp", "::")
-		if err != nil {
+	conn, socket, err := openICMPSocket(config.IPv6)
+	if err != nil {
+		if config.IPv6 {
 			return nil, fmt.Errorf("failed to create ICMPv6 socket (run as Administrator on Windows, or use sudo on Unix): %w", err)
 		}
+		return nil, fmt.Errorf("failed to create ICMP socket (run as Administrator on Windows, or use sudo on Unix): %w", err)
+	}
+
+	p.socket = socket
+	p.sharedReplies = socket == SocketDgram && dgramSharesEchoReplies()
+	binary.BigEndian.PutUint64(p.token[:], rand.Uint64())
+	if config.IPv6 {
+		p.conn6 = conn
 	} else {
-		p.conn4, err = icmp.ListenPacket("ip4:icmp", "0.0.0.0")
-		if err != nil {
-			return nil, fmt.Errorf("failed to create ICMP socket (run as Administrator on Windows, or use sudo on Unix): %w", err)
+		p.conn4 = conn
+	}
+
+	if len(config.Via) > 0 && !config.IPv6 {
+		if err := p.openRouted(config.Via); err != nil {
+			p.Close()
+			return nil, err
 		}
+		p.via = config.Via
 	}
 
 	return p, nil
 }
 
+// openRouted opens the raw socket that sends probes with the loose source
+// route option set.
+func (p *ICMPProber) openRouted(via []net.IP) error {
+	if p.socket != SocketRaw {
+		return fmt.Errorf("loose source routing requires a raw ICMP socket: %w", ErrPermissionDenied)
+	}
+	options, err := LooseSourceRoute(via)
+	if err != nil {
+		return err
+	}
+	conn, err := listenWithIPOptions("ip4:icmp", "0.0.0.0", options)
+	if err != nil {
+		return fmt.Errorf("failed to create source-routed ICMP socket: %w", err)
+	}
+	p.routed = conn.(*net.IPConn)
+	return nil
+}
+
 // Probe sends an ICMP Echo Request with the given TTL and waits for a response.
 func (p *ICMPProber) Probe(ctx context.Context, dest net.IP, ttl int) (*Result, error) {
 	if ttl < 1 || ttl > 255 {
 		return nil, ErrInvalidTTL
 	}
+	if p.isClosed() {
+		return nil, ErrSocketClosed
+	}
+	done := p.pending.begin()
+	defer done()
 
 	conn := p.conn4
 	proto := 1 // ICMP protocol number
@@ -84,29 +230,23 @@ func (p *ICMPProber) Probe(ctx context.Context, dest net.IP, ttl int) (*Result,
 		return nil, ErrSocketClosed
 	}
 
-	// Set TTL
-	if err := p.setTTL(conn, ttl); err != nil {
-		return nil, err
+	// Source-routed probes leave through their own socket
+	var sender net.PacketConn = conn
+	if conn == p.conn4 && p.routed != nil {
+		sender = p.routed
 	}
 
-	// Build ICMP message
-	seq := uint16(atomic.AddUint32(&p.sequence, 1))
-	payload := TimestampPayload(nil)
-
-	msg := &icmp.Message{
-		Type: icmpType,
-		Code: 0,
-		Body: &icmp.Echo{
-			ID:   int(p.identifier),
-			Seq:  int(seq),
-			Data: payload,
-		},
+	// Set TTL
+	if err := p.setTTL(sender, ttl); err != nil {
+		return nil, p.socketError(&p.Counters, err)
 	}
 
-	msgBytes, err := msg.Marshal(nil)
+	// Build ICMP message
+	seq, msgBytes, err := p.nextEcho(icmpType)
 	if err != nil {
 		return nil, err
 	}
+	p.replies.sent(uint32(seq))
 
 	// Set deadline
 	deadline := time.Now().Add(p.timeout)
@@ -117,27 +257,70 @@ func (p *ICMPProber) Probe(ctx context.Context, dest net.IP, ttl int) (*Result,
 
 	// Send probe
 	sendTime := time.Now()
-	var dst net.Addr
-	if p.ipv6 || dest.To4() == nil {
-		dst = &net.IPAddr{IP: dest}
-	} else {
-		dst = &net.IPAddr{IP: dest}
+	var dst net.Addr = &net.IPAddr{IP: dest, Zone: p.zone}
+	if p.socket == SocketDgram {
+		dst = &net.UDPAddr{IP: dest, Zone: p.zone}
 	}
 
-	if _, err := conn.WriteTo(msgBytes, dst); err != nil {
-		return nil, err
+	if _, err := sender.WriteTo(msgBytes, dst); err != nil {
+		return nil, p.sendError(&p.Counters, err)
 	}
+	p.CountSent()
 
 	// Wait for response
-	return p.waitForResponse(ctx, conn, proto, dest, seq, sendTime)
+	result, err := p.waitForResponse(ctx, conn, proto, dest, seq, sendTime)
+	if result != nil {
+		result.Seq = uint32(seq)
+		result.SentAt = sendTime
+	}
+	return result, err
+}
+
+// nextEcho builds the Echo Request for the next probe and returns its
+// sequence number.
+func (p *ICMPProber) nextEcho(icmpType icmp.Type) (uint16, []byte, error) {
+	seq := uint16(atomic.AddUint32(&p.sequence, 1))
+
+	msg := &icmp.Message{
+		Type: icmpType,
+		Code: 0,
+		Body: &icmp.Echo{
+			ID:   int(p.identifier),
+			Seq:  int(seq),
+			Data: TimestampPayload(p.token[:]),
+		},
+	}
+
+	msgBytes, err := msg.Marshal(nil)
+	return seq, msgBytes, err
+}
+
+// Identifier returns the ICMP Echo identifier used by this prober.
+func (p *ICMPProber) Identifier() uint16 {
+	return p.identifier
 }
 
 // setTTL sets the TTL/Hop Limit for outgoing packets.
-func (p *ICMPProber) setTTL(conn *icmp.PacketConn, ttl int) error {
+func (p *ICMPProber) setTTL(conn net.PacketConn, ttl int) error {
+	if routed, ok := conn.(*net.IPConn); ok {
+		rawConn, err := routed.SyscallConn()
+		if err != nil {
+			return err
+		}
+		var setErr error
+		if err := rawConn.Control(func(fd uintptr) {
+			setErr = setIPv4TTL(fd, ttl)
+		}); err != nil {
+			return err
+		}
+		return setErr
+	}
+
+	c := conn.(*icmp.PacketConn)
 	if p.ipv6 {
-		return conn.IPv6PacketConn().SetHopLimit(ttl)
+		return c.IPv6PacketConn().SetHopLimit(ttl)
 	}
-	return conn.IPv4PacketConn().SetTTL(ttl)
+	return c.IPv4PacketConn().SetTTL(ttl)
 }
 
 // waitForResponse waits for an ICMP response matching our probe.
@@ -155,21 +338,64 @@ func (p *ICMPProber) waitForResponse(ctx context.Context, conn *icmp.PacketConn,
 
 		n, peer, err := conn.ReadFrom(buf)
 		if err != nil {
-			if isTimeoutError(err) {
-				return nil, ErrTimeout
-			}
-			return nil, err
+			p.countReadError(err)
+			return nil, readError(err)
 		}
 
-		// Parse the response
-		result, matched := p.parseResponse(buf[:n], peer, proto, dest, expectedSeq, sendTime)
-		if matched {
+		// Packets for other probes are counted and skipped
+		if result, matched := p.handleReply(buf[:n], peer, proto, dest, expectedSeq, sendTime); matched {
 			return result, nil
 		}
-		// Not our packet, continue waiting
 	}
 }
 
+// handleReply matches a received packet to the probe with expectedSeq
+// and counts it. A further reply to an answered probe is a duplicate.
+func (p *ICMPProber) handleReply(data []byte, peer net.Addr, proto int,
+	dest net.IP, expectedSeq uint16, sendTime time.Time) (*Result, bool) {
+
+	result, matched := p.parseResponse(data, peer, proto, dest, expectedSeq, sendTime)
+	if matched {
+		p.replies.answer(uint32(expectedSeq))
+		p.CountReceived()
+		return result, true
+	}
+
+	msg, err := icmp.ParseMessage(proto, data)
+	switch {
+	case err != nil:
+		p.CountDiscard(DiscardMalformed)
+	case p.isDuplicate(msg):
+		p.CountDuplicate()
+	default:
+		p.CountDiscard(discardReason(msg))
+	}
+	return nil, false
+}
+
+// isDuplicate reports whether msg answers one of our Echo Requests that
+// was already answered.
+func (p *ICMPProber) isDuplicate(msg *icmp.Message) bool {
+	var seq uint16
+	if echo, ok := msg.Body.(*icmp.Echo); ok {
+		if msg.Type != ipv4.ICMPTypeEchoReply && msg.Type != ipv6.ICMPTypeEchoReply {
+			return false
+		}
+		if !p.ownsEcho(echo) {
+			return false
+		}
+		seq = uint16(echo.Seq)
+	} else if _, header := quotedTransport(msg); header != nil && header[0] == 8 {
+		if !p.matchID(binary.BigEndian.Uint16(header[4:6])) {
+			return false
+		}
+		seq = binary.BigEndian.Uint16(header[6:8])
+	} else {
+		return false
+	}
+	return p.replies.isAnswered(uint32(seq))
+}
+
 // parseResponse parses an ICMP response and checks if it matches our probe.
 func (p *ICMPProber) parseResponse(data []byte, peer net.Addr, proto int,
 	dest net.IP, expectedSeq uint16, sendTime time.Time) (*Result, bool) {
@@ -189,116 +415,148 @@ func (p *ICMPProber) parseResponse(data []byte, peer net.Addr, proto int,
 		if !ok {
 			return nil, false
 		}
-		if uint16(echo.ID) != p.identifier || uint16(echo.Seq) != expectedSeq {
+		if !p.ownsEcho(echo) || uint16(echo.Seq) != expectedSeq {
 			return nil, false
 		}
 		return &Result{
-			ResponseIP: peerIP,
-			RTT:        rtt,
-			ICMPType:   int(msg.Type.(ipv4.ICMPType)),
-			ICMPCode:   int(msg.Code),
-			Reached:    true,
-			TTLExpired: false,
+			ResponseIP:   peerIP,
+			RTT:          rtt,
+			ICMPType:     icmpType(msg),
+			ICMPCode:     int(msg.Code),
+			Reached:      true,
+			TTLExpired:   false,
+			MatchQuality: MatchFull,
+			ResponseKind: ResponseEchoReply,
 		}, true
 
 	case ipv4.ICMPTypeTimeExceeded, ipv6.ICMPTypeTimeExceeded:
 		// Time Exceeded - intermediate hop
-		return p.parseTimeExceeded(msg, peerIP, rtt, expectedSeq)
+		result, ok := p.parseTimeExceeded(msg, peerIP, dest, rtt, expectedSeq)
+		if ok {
+			result.Interface = interfaceFromMessage(msg)
+		}
+		return result, ok
+
+	case ipv4.ICMPTypeParameterProblem:
+		return p.parseSourceRouteRejection(msg, peerIP, dest, rtt, expectedSeq)
+
+	case ipv4.ICMPTypeRedirect:
+		// Recorded for any of our probes; the probe keeps waiting for
+		// its reply, since the router forwards it anyway
+		p.noteRedirect(msg, peerIP, p.quotesEcho)
+		return nil, false
 
 	case ipv4.ICMPTypeDestinationUnreachable, ipv6.ICMPTypeDestinationUnreachable:
+		if proto == 1 && msg.Code == icmpSourceRouteFailed {
+			return p.parseSourceRouteRejection(msg, peerIP, dest, rtt, expectedSeq)
+		}
+
 		// Destination Unreachable
-		return p.parseUnreachable(msg, peerIP, rtt, expectedSeq)
+		result, ok := p.parseUnreachable(msg, peerIP, dest, rtt, expectedSeq)
+		if ok {
+			result.Interface = interfaceFromMessage(msg)
+		}
+		return result, ok
 	}
 
 	return nil, false
 }
 
 // parseTimeExceeded parses a Time Exceeded message.
-func (p *ICMPProber) parseTimeExceeded(msg *icmp.Message, peerIP net.IP, rtt time.Duration, expectedSeq uint16) (*Result, bool) {
-	// Time Exceeded contains the original IP header + first 8 bytes of original packet
+func (p *ICMPProber) parseTimeExceeded(msg *icmp.Message, peerIP, dest net.IP, rtt time.Duration, expectedSeq uint16) (*Result, bool) {
+	// Time Exceeded quotes the original IP header and at least the
+	// first 8 bytes of the original packet, if the router complies
 	body, ok := msg.Body.(*icmp.TimeExceeded)
 	if !ok {
 		return nil, false
 	}
-
-	// Extract original ICMP header from the payload
-	// IP header is typically 20 bytes, then ICMP header
-	origData := body.Data
-	if len(origData) < 28 { // 20 (IP) + 8 (ICMP header)
-		return nil, false
-	}
-
-	// Find the ICMP header in the original packet
-	// IPv4 header length is in the first byte (lower 4 bits * 4)
-	ipHeaderLen := int(origData[0]&0x0f) * 4
-	if len(origData) < ipHeaderLen+8 {
-		return nil, false
-	}
-
-	icmpHeader := origData[ipHeaderLen:]
-
-	// Check if this is our ICMP Echo Request
-	if icmpHeader[0] != 8 { // ICMP Echo Request type
-		return nil, false
-	}
-
-	// Extract ID and Sequence from original ICMP header
-	origID := binary.BigEndian.Uint16(icmpHeader[4:6])
-	origSeq := binary.BigEndian.Uint16(icmpHeader[6:8])
-
-	if origID != p.identifier || origSeq != expectedSeq {
+	quality, ok := p.matchQuote(body.Data, dest, expectedSeq)
+	if !ok {
 		return nil, false
 	}
 
 	return &Result{
-		ResponseIP: peerIP,
-		RTT:        rtt,
-		ICMPType:   int(msg.Type.(ipv4.ICMPType)),
-		ICMPCode:   int(msg.Code),
-		Reached:    false,
-		TTLExpired: true,
+		ResponseIP:   peerIP,
+		RTT:          rtt,
+		ICMPType:     icmpType(msg),
+		ICMPCode:     int(msg.Code),
+		Reached:      false,
+		TTLExpired:   true,
+		MatchQuality: quality,
+		QuoteLen:     len(body.Data),
+		ResponseKind: ResponseTTLExceeded,
 	}, true
 }
 
 // parseUnreachable parses a Destination Unreachable message.
-func (p *ICMPProber) parseUnreachable(msg *icmp.Message, peerIP net.IP, rtt time.Duration, expectedSeq uint16) (*Result, bool) {
+func (p *ICMPProber) parseUnreachable(msg *icmp.Message, peerIP, dest net.IP, rtt time.Duration, expectedSeq uint16) (*Result, bool) {
 	body, ok := msg.Body.(*icmp.DstUnreach)
 	if !ok {
 		return nil, false
 	}
-
-	origData := body.Data
-	if len(origData) < 28 {
+	quality, ok := p.matchQuote(body.Data, dest, expectedSeq)
+	if !ok {
 		return nil, false
 	}
 
-	ipHeaderLen := int(origData[0]&0x0f) * 4
-	if len(origData) < ipHeaderLen+8 {
-		return nil, false
-	}
+	return &Result{
+		ResponseIP:   peerIP,
+		RTT:          rtt,
+		ICMPType:     icmpType(msg),
+		ICMPCode:     int(msg.Code),
+		Reached:      true, // We reached the destination but it's unreachable
+		TTLExpired:   false,
+		MatchQuality: quality,
+		QuoteLen:     len(body.Data),
+		ResponseKind: ResponseUnreachable,
+	}, true
+}
 
-	icmpHeader := origData[ipHeaderLen:]
-	if icmpHeader[0] != 8 {
+// parseSourceRouteRejection parses an ICMP error refusing the source
+// route of one of our Echo Requests.
+func (p *ICMPProber) parseSourceRouteRejection(msg *icmp.Message, peerIP, dest net.IP, rtt time.Duration, expectedSeq uint16) (*Result, bool) {
+	origData, ok := sourceRouteRejection(msg)
+	if !ok {
 		return nil, false
 	}
-
-	origID := binary.BigEndian.Uint16(icmpHeader[4:6])
-	origSeq := binary.BigEndian.Uint16(icmpHeader[6:8])
-
-	if origID != p.identifier || origSeq != expectedSeq {
+	quality, ok := p.matchQuote(origData, dest, expectedSeq)
+	if !ok {
 		return nil, false
 	}
 
 	return &Result{
-		ResponseIP: peerIP,
-		RTT:        rtt,
-		ICMPType:   int(msg.Type.(ipv4.ICMPType)),
-		ICMPCode:   int(msg.Code),
-		Reached:    true, // We reached the destination but it's unreachable
-		TTLExpired: false,
+		ResponseIP:          peerIP,
+		RTT:                 rtt,
+		ICMPType:            icmpType(msg),
+		ICMPCode:            msg.Code,
+		SourceRouteRejected: true,
+		MatchQuality:        quality,
+		QuoteLen:            len(origData),
+		ResponseKind:        ResponseSourceRouteRejected,
 	}, true
 }
 
+// matchQuote grades the datagram quoted by an ICMP error against the
+// Echo Request with expectedSeq, and reports whether to accept it.
+func (p *ICMPProber) matchQuote(orig []byte, dest net.IP, expectedSeq uint16) (MatchQuality, bool) {
+	quality := matchQuotedEcho(orig, dest, p.via, p.matchID, expectedSeq)
+	return quality, p.pending.accepts(quality)
+}
+
+// quotesEcho reports whether orig, the datagram quoted by an ICMP error,
+// is one of our Echo Requests.
+func (p *ICMPProber) quotesEcho(orig []byte) bool {
+	if len(orig) < 28 {
+		return false
+	}
+	ipHeaderLen := int(orig[0]&0x0f) * 4
+	if ipHeaderLen < 20 || len(orig) < ipHeaderLen+8 {
+		return false
+	}
+	icmpHeader := orig[ipHeaderLen:]
+	return icmpHeader[0] == 8 && p.matchID(binary.BigEndian.Uint16(icmpHeader[4:6]))
+}
+
 // Name returns the probe method name.
 func (p *ICMPProber) Name() string {
 	if p.ipv6 {
@@ -307,25 +565,57 @@ func (p *ICMPProber) Name() string {
 	return "icmp"
 }
 
-// RequiresRoot returns true as ICMP raw sockets typically require elevated privileges.
+// matchID reports whether an echo identifier belongs to this prober. The
+// kernel rewrites the identifier of datagram sockets and only delivers
+// their own replies, so any identifier is accepted there.
+func (p *ICMPProber) matchID(id uint16) bool {
+	return p.socket == SocketDgram || id == p.identifier
+}
+
+// ownsEcho reports whether an Echo Reply answers one of this prober's
+// requests. Where datagram sockets share replies, the identifier may be
+// rewritten and the sequence number may be another socket's, so the
+// reply must echo the prober's payload token instead.
+func (p *ICMPProber) ownsEcho(echo *icmp.Echo) bool {
+	if p.sharedReplies {
+		return len(echo.Data) >= 16 && bytes.Equal(echo.Data[8:16], p.token[:])
+	}
+	return p.matchID(uint16(echo.ID))
+}
+
+// SocketKind returns the kind of socket responses are received on:
+// SocketRaw, or SocketDgram after falling back to an unprivileged socket.
+func (p *ICMPProber) SocketKind() string {
+	return p.socket
+}
+
+// RequiresRoot returns true unless the prober fell back to an unprivileged
+// datagram socket.
 func (p *ICMPProber) RequiresRoot() bool {
-	return true
+	return p.socket != SocketDgram
 }
 
-// Close releases resources held by the prober.
+// Close releases resources held by the prober. Probes waiting for a
+// reply return ErrSocketClosed, as do later probes; closing again is a
+// no-op.
 func (p *ICMPProber) Close() error {
-	var err error
-	if p.conn4 != nil {
-		err = p.conn4.Close()
-		p.conn4 = nil
-	}
-	if p.conn6 != nil {
-		if e := p.conn6.Close(); e != nil && err == nil {
-			err = e
+	return p.close(func() error {
+		var err error
+		if p.conn4 != nil {
+			err = p.conn4.Close()
 		}
-		p.conn6 = nil
-	}
-	return err
+		if p.conn6 != nil {
+			if e := p.conn6.Close(); e != nil && err == nil {
+				err = e
+			}
+		}
+		if p.routed != nil {
+			if e := p.routed.Close(); e != nil && err == nil {
+				err = e
+			}
+		}
+		return err
+	})
 }
 
 // Helper functions
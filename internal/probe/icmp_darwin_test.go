@@ -0,0 +1,67 @@
+//go:build darwin
+
+package probe
+
+import (
+	"context"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+)
+
+// TestICMPProber_UnprivilegedLoopback traces localhost the way a user
+// without sudo does: the raw socket is refused and the prober falls back
+// to the datagram socket, which on macOS sees every Echo Reply.
+func TestICMPProber_UnprivilegedLoopback(t *testing.T) {
+	withListenICMP(t, func(network, address string) (*icmp.PacketConn, error) {
+		if network == "ip4:icmp" {
+			return nil, os.ErrPermission
+		}
+		return icmp.ListenPacket(network, address)
+	})
+
+	// Two probers in one process share the identifier and sequence
+	// numbers, so only the payload token tells their replies apart
+	var probers [2]*ICMPProber
+	for i := range probers {
+		prober, err := NewICMPProber(ICMPProberConfig{Timeout: 2 * time.Second})
+		if err != nil {
+			t.Fatalf("NewICMPProber() error = %v", err)
+		}
+		defer prober.Close()
+		if prober.SocketKind() != SocketDgram {
+			t.Fatalf("SocketKind() = %q, want %q", prober.SocketKind(), SocketDgram)
+		}
+		probers[i] = prober
+	}
+
+	dest := net.ParseIP("127.0.0.1")
+	var wg sync.WaitGroup
+	for _, prober := range probers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 3 {
+				result, err := prober.Probe(context.Background(), dest, 64)
+				if err != nil {
+					t.Errorf("Probe() error = %v", err)
+					return
+				}
+				if !result.Reached || !result.ResponseIP.Equal(dest) {
+					t.Errorf("Probe() = %+v, want an Echo Reply from %s", result, dest)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, prober := range probers {
+		if stats := prober.Stats(); stats.Received != 3 {
+			t.Errorf("prober %d received %d replies, want 3", i, stats.Received)
+		}
+	}
+}
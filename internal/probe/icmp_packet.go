@@ -128,8 +128,10 @@ func ParseICMPPacket(data []byte) (*ICMPPacket, error) {
 	return p, nil
 }
 
-// TimestampPayload creates a payload containing the current timestamp.
-// This is used to calculate RTT when the response is received.
+// TimestampPayload creates a payload containing the current wall-clock
+// timestamp. It only makes probes recognizable in packet captures: the
+// wall clock can step mid-trace, so RTTs are always measured from the
+// monotonic send time the prober keeps in memory, never from the payload.
 func TimestampPayload(extraData []byte) []byte {
 	// 8 bytes for timestamp + extra data
 	payload := make([]byte, 8+len(extraData))
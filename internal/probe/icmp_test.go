@@ -1,12 +1,21 @@
 package probe
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
+	"errors"
 	"net"
 	"os"
 	"runtime"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
 )
 
 func TestNewICMPProber(t *testing.T) {
@@ -46,11 +55,16 @@ func TestICMPProber_ProbeLocalhost(t *testing.T) {
 	defer prober.Close()
 
 	ctx := context.Background()
+	before := time.Now()
 	result, err := prober.Probe(ctx, net.ParseIP("127.0.0.1"), 64)
 	if err != nil {
 		t.Fatalf("Probe() error = %v", err)
 	}
 
+	if result.SentAt.Before(before) || result.SentAt.Add(result.RTT).After(time.Now()) {
+		t.Errorf("SentAt = %v with RTT %v, want within the Probe call", result.SentAt, result.RTT)
+	}
+
 	if !result.Reached {
 		t.Error("Probe to localhost should reach destination")
 	}
@@ -122,3 +136,225 @@ func canCreateRawSocket() bool {
 	// On Unix-like systems, check if running as root
 	return os.Getuid() == 0
 }
+
+// withListenICMP replaces the socket opener for the duration of a test.
+func withListenICMP(t *testing.T, fn func(network, address string) (*icmp.PacketConn, error)) {
+	t.Helper()
+	orig := listenICMP
+	listenICMP = fn
+	t.Cleanup(func() { listenICMP = orig })
+}
+
+func TestNewICMPProber_BothSocketsFail(t *testing.T) {
+	rawErr := &net.OpError{Op: "listen", Net: "ip4:icmp", Err: os.ErrPermission}
+	dgramErr := errors.New("protocol not permitted")
+
+	var attempts []string
+	withListenICMP(t, func(network, address string) (*icmp.PacketConn, error) {
+		attempts = append(attempts, network)
+		if network == "ip4:icmp" {
+			return nil, rawErr
+		}
+		return nil, dgramErr
+	})
+
+	_, err := NewICMPProber(ICMPProberConfig{})
+	if err == nil {
+		t.Fatal("NewICMPProber() should fail when no socket can be opened")
+	}
+	if len(attempts) != 2 || attempts[0] != "ip4:icmp" || attempts[1] != "udp4" {
+		t.Errorf("attempts = %v, want raw then datagram", attempts)
+	}
+	if !errors.Is(err, os.ErrPermission) || !errors.Is(err, dgramErr) {
+		t.Errorf("error should wrap both attempts, got %v", err)
+	}
+	if !IsPermissionError(err) {
+		t.Error("a raw socket permission failure should be reported as ErrPermissionDenied")
+	}
+	for _, want := range []string{"raw socket (ip4:icmp)", "datagram socket (udp4)"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should mention %q, got %v", want, err)
+		}
+	}
+}
+
+func TestNewICMPProber_SocketKind(t *testing.T) {
+	if !canCreateRawSocket() {
+		t.Skip("Skipping: requires elevated privileges")
+	}
+
+	var warnings bytes.Buffer
+	dgramWarnOutput = &warnings
+	t.Cleanup(func() { dgramWarnOutput = os.Stderr })
+
+	tests := []struct {
+		name     string
+		rawFails bool
+		want     string
+	}{
+		{"raw", false, SocketRaw},
+		{"datagram fallback", true, SocketDgram},
+		{"datagram fallback again", true, SocketDgram},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withListenICMP(t, func(network, address string) (*icmp.PacketConn, error) {
+				if network == "ip4:icmp" && tt.rawFails {
+					return nil, os.ErrPermission
+				}
+				// Any working socket will do; only the kind is checked
+				return icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+			})
+
+			prober, err := NewICMPProber(ICMPProberConfig{})
+			if err != nil {
+				t.Fatalf("NewICMPProber() error = %v", err)
+			}
+			defer prober.Close()
+
+			if got := prober.SocketKind(); got != tt.want {
+				t.Errorf("SocketKind() = %q, want %q", got, tt.want)
+			}
+			if prober.RequiresRoot() != (tt.want == SocketRaw) {
+				t.Errorf("RequiresRoot() = %v for a %s socket", prober.RequiresRoot(), tt.want)
+			}
+		})
+	}
+
+	if dgramMissesTimeExceeded() {
+		if n := strings.Count(warnings.String(), "Warning:"); n != 1 {
+			t.Errorf("datagram warning written %d times, want once:\n%s", n, warnings.String())
+		}
+		if !strings.Contains(warnings.String(), "setcap") {
+			t.Error("warning should suggest setcap")
+		}
+	}
+}
+
+func TestInitialSequence(t *testing.T) {
+	for _, start := range []uint16{0, 1, 100, 0xffff} {
+		counter := initialSequence(start)
+		got := uint16(atomic.AddUint32(&counter, 1))
+		want := start
+		if start == 0 {
+			want = 1
+		}
+		if got != want {
+			t.Errorf("first sequence for start %d = %d, want %d", start, got, want)
+		}
+	}
+}
+
+func TestICMPProber_PacketIDs(t *testing.T) {
+	if !canCreateRawSocket() {
+		t.Skip("Skipping: requires elevated privileges")
+	}
+
+	prober, err := NewICMPProber(ICMPProberConfig{Timeout: time.Second, Identifier: 0xBEEF, SeqStart: 100})
+	if err != nil {
+		t.Fatalf("NewICMPProber() error = %v", err)
+	}
+	defer prober.Close()
+
+	if prober.Identifier() != 0xBEEF {
+		t.Errorf("Identifier() = %#x, want 0xbeef", prober.Identifier())
+	}
+
+	for _, wantSeq := range []int{100, 101} {
+		_, data, err := prober.nextEcho(ipv4.ICMPTypeEcho)
+		if err != nil {
+			t.Fatalf("nextEcho() error = %v", err)
+		}
+		msg, err := icmp.ParseMessage(1, data)
+		if err != nil {
+			t.Fatalf("ParseMessage() error = %v", err)
+		}
+		echo := msg.Body.(*icmp.Echo)
+		if echo.ID != 0xBEEF || echo.Seq != wantSeq {
+			t.Errorf("echo ID/seq = %#x/%d, want 0xbeef/%d", echo.ID, echo.Seq, wantSeq)
+		}
+	}
+}
+
+func TestICMPProber_SharedDgramReplies(t *testing.T) {
+	// A datagram socket that sees every Echo Reply, as on macOS
+	p := &ICMPProber{identifier: 0x1234, socket: SocketDgram, sharedReplies: true, sequence: initialSequence(7)}
+	binary.BigEndian.PutUint64(p.token[:], 0x0102030405060708)
+	_, request, err := p.nextEcho(ipv4.ICMPTypeEcho)
+	if err != nil {
+		t.Fatalf("nextEcho() error = %v", err)
+	}
+	sent, _ := icmp.ParseMessage(1, request)
+	payload := sent.Body.(*icmp.Echo).Data
+
+	reply := func(id int, data []byte) []byte {
+		msg := icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: id, Seq: 7, Data: data}}
+		b, err := msg.Marshal(nil)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		return b
+	}
+	other := append([]byte(nil), payload...)
+	other[15] ^= 0xff
+
+	peer := &net.UDPAddr{IP: net.ParseIP("127.0.0.1")}
+	dest := net.ParseIP("127.0.0.1")
+
+	// Another socket's reply with the same sequence number, and even our
+	// identifier, is not ours
+	if result, ok := p.handleReply(reply(0x1234, other), peer, 1, dest, 7, time.Now()); ok {
+		t.Fatalf("handleReply() = %+v; a reply without our token must not match", result)
+	}
+	// Our reply matches with a kernel-rewritten identifier
+	result, ok := p.handleReply(reply(0xabcd, payload), peer, 1, dest, 7, time.Now())
+	if !ok || !result.Reached || !result.ResponseIP.Equal(dest) {
+		t.Fatalf("handleReply() = %+v, %v; want our reply with a rewritten identifier", result, ok)
+	}
+	if p.Stats().Duplicates != 0 {
+		t.Error("another socket's reply must not count as a duplicate")
+	}
+	// A second copy of our reply is a duplicate
+	p.handleReply(reply(0xabcd, payload), peer, 1, dest, 8, time.Now())
+	if p.Stats().Duplicates != 1 {
+		t.Errorf("Duplicates = %d, want 1", p.Stats().Duplicates)
+	}
+}
+
+func TestICMPProber_ParseIPv6Replies(t *testing.T) {
+	p := &ICMPProber{identifier: 0x1234, socket: SocketRaw, ipv6: true}
+	dest := net.ParseIP("2001:db8::7")
+	peer := &net.IPAddr{IP: net.ParseIP("2001:db8::1")}
+
+	echo := make([]byte, 8)
+	echo[0] = 128 // ICMPv6 Echo Request
+	binary.BigEndian.PutUint16(echo[4:6], 0x1234)
+	binary.BigEndian.PutUint16(echo[6:8], 7)
+
+	tests := []struct {
+		name     string
+		msg      icmp.Message
+		icmpType int
+		reached  bool
+	}{
+		{"echo reply", icmp.Message{Type: ipv6.ICMPTypeEchoReply,
+			Body: &icmp.Echo{ID: 0x1234, Seq: 7}}, 129, true},
+		{"time exceeded", icmp.Message{Type: ipv6.ICMPTypeTimeExceeded,
+			Body: &icmp.TimeExceeded{Data: quotedDatagram6(58, dest, echo)}}, 3, false},
+		{"unreachable", icmp.Message{Type: ipv6.ICMPTypeDestinationUnreachable, Code: 4,
+			Body: &icmp.DstUnreach{Data: quotedDatagram6(58, dest, echo)}}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.msg.Marshal(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, ok := p.parseResponse(data, peer, 58, dest, 7, time.Now())
+			if !ok || result.ICMPType != tt.icmpType || result.Reached != tt.reached {
+				t.Fatalf("parseResponse() = %+v, %v; want ICMPv6 type %d, reached %v", result, ok, tt.icmpType, tt.reached)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package probe
+
+import (
+	"net"
+
+	"golang.org/x/net/icmp"
+)
+
+// Interface roles of an RFC 5837 Interface Information Object, taken from
+// the top two bits of its C-Type.
+const (
+	InterfaceRoleIncoming = "incoming"
+	InterfaceRoleSubIP    = "sub-ip"
+	InterfaceRoleOutgoing = "outgoing"
+	InterfaceRoleNextHop  = "next-hop"
+)
+
+var interfaceRoles = [4]string{
+	InterfaceRoleIncoming,
+	InterfaceRoleSubIP,
+	InterfaceRoleOutgoing,
+	InterfaceRoleNextHop,
+}
+
+// InterfaceInfo identifies a router interface from the RFC 5837 Interface
+// Information Object appended to an ICMP error (RFC 4884 extension).
+type InterfaceInfo struct {
+	// Role is the interface's role in handling the probe
+	Role string
+
+	// Index is the ifIndex (0 if not included)
+	Index int
+
+	// Name is the interface name (empty if not included)
+	Name string
+
+	// MTU is the interface MTU (0 if not included)
+	MTU int
+
+	// IP is the interface address (nil if not included)
+	IP net.IP
+}
+
+// interfaceFromMessage extracts interface information from the extensions
+// of an ICMP error message. The object describing the incoming interface
+// is preferred; nil is returned if the message carries none.
+//
+// The extension structure is parsed by icmp.ParseMessage, which drops it
+// entirely if it is malformed, so a truncated or corrupt object never
+// yields partial data. Other objects, such as an MPLS label stack (RFC
+// 4950), may precede or follow the interface object.
+func interfaceFromMessage(msg *icmp.Message) *InterfaceInfo {
+	var exts []icmp.Extension
+	switch body := msg.Body.(type) {
+	case *icmp.TimeExceeded:
+		exts = body.Extensions
+	case *icmp.DstUnreach:
+		exts = body.Extensions
+	case *icmp.ParamProb:
+		exts = body.Extensions
+	}
+
+	var found *InterfaceInfo
+	for _, ext := range exts {
+		obj, ok := ext.(*icmp.InterfaceInfo)
+		if !ok {
+			continue
+		}
+		info := newInterfaceInfo(obj)
+		if info == nil {
+			continue
+		}
+		if info.Role == InterfaceRoleIncoming {
+			return info
+		}
+		if found == nil {
+			found = info
+		}
+	}
+	return found
+}
+
+// newInterfaceInfo converts a parsed Interface Information Object, or
+// returns nil if it identifies nothing.
+func newInterfaceInfo(obj *icmp.InterfaceInfo) *InterfaceInfo {
+	info := &InterfaceInfo{Role: interfaceRoles[(obj.Type>>6)&0x03]}
+	if obj.Interface != nil {
+		info.Index = obj.Interface.Index
+		info.Name = obj.Interface.Name
+		info.MTU = obj.Interface.MTU
+	}
+	if obj.Addr != nil {
+		info.IP = obj.Addr.IP
+	}
+
+	if info.Index == 0 && info.Name == "" && info.MTU == 0 && info.IP == nil {
+		return nil
+	}
+	return info
+}
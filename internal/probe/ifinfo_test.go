@@ -0,0 +1,174 @@
+package probe
+
+import (
+	"math/rand"
+	"net"
+	"testing"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// RFC 5837 C-Type attribute bits: ifIndex, IP address, name, MTU.
+const ifInfoAllAttrs = 0x08 | 0x04 | 0x02 | 0x01
+
+// timeExceededWithExtensions builds a wire-format ICMPv4 Time Exceeded
+// carrying the given RFC 4884 extension objects.
+func timeExceededWithExtensions(t *testing.T, exts ...icmp.Extension) []byte {
+	t.Helper()
+
+	// Original datagram: IPv4 header + ICMP echo header
+	orig := make([]byte, 28)
+	orig[0] = 0x45
+	orig[20] = 8
+
+	msg := icmp.Message{
+		Type: ipv4.ICMPTypeTimeExceeded,
+		Body: &icmp.TimeExceeded{Data: orig, Extensions: exts},
+	}
+	data, err := msg.Marshal(nil)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	return data
+}
+
+func mplsObject() *icmp.MPLSLabelStack {
+	return &icmp.MPLSLabelStack{
+		Class:  1,
+		Type:   1,
+		Labels: []icmp.MPLSLabel{{Label: 16005, TC: 0, S: true, TTL: 1}},
+	}
+}
+
+func interfaceObject(role int, name string, index int) *icmp.InterfaceInfo {
+	return &icmp.InterfaceInfo{
+		Class:     2,
+		Type:      role<<6 | ifInfoAllAttrs,
+		Interface: &net.Interface{Index: index, Name: name, MTU: 9000},
+		Addr:      &net.IPAddr{IP: net.IPv4(192, 0, 2, 1).To4()},
+	}
+}
+
+func parseInterface(t *testing.T, data []byte) *InterfaceInfo {
+	t.Helper()
+	msg, err := icmp.ParseMessage(1, data)
+	if err != nil {
+		t.Fatalf("ParseMessage() error = %v", err)
+	}
+	return interfaceFromMessage(msg)
+}
+
+func TestInterfaceFromMessage(t *testing.T) {
+	tests := []struct {
+		name      string
+		exts      []icmp.Extension
+		wantName  string
+		wantRole  string
+		wantIndex int
+	}{
+		{"no extensions", nil, "", "", 0},
+		{"MPLS only", []icmp.Extension{mplsObject()}, "", "", 0},
+		{
+			name:      "interface only",
+			exts:      []icmp.Extension{interfaceObject(0, "xe-0/0/1", 517)},
+			wantName:  "xe-0/0/1",
+			wantRole:  InterfaceRoleIncoming,
+			wantIndex: 517,
+		},
+		{
+			name:      "MPLS before interface",
+			exts:      []icmp.Extension{mplsObject(), interfaceObject(0, "ae1.100", 12)},
+			wantName:  "ae1.100",
+			wantRole:  InterfaceRoleIncoming,
+			wantIndex: 12,
+		},
+		{
+			name:      "interface before MPLS",
+			exts:      []icmp.Extension{interfaceObject(0, "et-1/0/0", 3), mplsObject()},
+			wantName:  "et-1/0/0",
+			wantRole:  InterfaceRoleIncoming,
+			wantIndex: 3,
+		},
+		{
+			name:      "incoming preferred over outgoing",
+			exts:      []icmp.Extension{interfaceObject(2, "out0", 2), interfaceObject(0, "in0", 1)},
+			wantName:  "in0",
+			wantRole:  InterfaceRoleIncoming,
+			wantIndex: 1,
+		},
+		{
+			name:      "outgoing only",
+			exts:      []icmp.Extension{interfaceObject(2, "out0", 2)},
+			wantName:  "out0",
+			wantRole:  InterfaceRoleOutgoing,
+			wantIndex: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := parseInterface(t, timeExceededWithExtensions(t, tt.exts...))
+			if tt.wantName == "" {
+				if info != nil {
+					t.Errorf("interfaceFromMessage() = %+v, want nil", info)
+				}
+				return
+			}
+			if info == nil {
+				t.Fatal("interfaceFromMessage() = nil")
+			}
+			if info.Name != tt.wantName || info.Role != tt.wantRole || info.Index != tt.wantIndex {
+				t.Errorf("interface = %q role %s ifIndex %d, want %q role %s ifIndex %d",
+					info.Name, info.Role, info.Index, tt.wantName, tt.wantRole, tt.wantIndex)
+			}
+			if info.MTU != 9000 || !info.IP.Equal(net.IPv4(192, 0, 2, 1)) {
+				t.Errorf("MTU/IP = %d/%s, want 9000/192.0.2.1", info.MTU, info.IP)
+			}
+		})
+	}
+}
+
+// TestInterfaceFromMessage_Malformed truncates and corrupts the extension
+// structure. Parsing must never panic, and anything returned must be
+// either the original object or nothing.
+func TestInterfaceFromMessage_Malformed(t *testing.T) {
+	valid := timeExceededWithExtensions(t, mplsObject(), interfaceObject(0, "xe-0/0/1", 517))
+	rng := rand.New(rand.NewSource(5837))
+
+	check := func(data []byte) {
+		defer func() {
+			if r := recover(); r != nil {
+				t.Fatalf("panic on %x: %v", data, r)
+			}
+		}()
+		msg, err := icmp.ParseMessage(1, data)
+		if err != nil {
+			return
+		}
+		if info := interfaceFromMessage(msg); info != nil && info.Role == "" {
+			t.Errorf("interface without a role from %x", data)
+		}
+	}
+
+	// Truncated at every length, then with random tails
+	for n := 0; n <= len(valid); n++ {
+		check(valid[:n])
+		for i := 0; i < 8; i++ {
+			tail := make([]byte, rng.Intn(64))
+			rng.Read(tail)
+			check(append(append([]byte(nil), valid[:n]...), tail...))
+		}
+	}
+
+	// Random byte corruption within the extension structure (which starts
+	// after the 4-byte header and the 128-byte padded datagram)
+	for i := 0; i < 5000; i++ {
+		data := append([]byte(nil), valid...)
+		for j := 0; j < 1+rng.Intn(4); j++ {
+			pos := 4 + 128 + rng.Intn(len(data)-4-128)
+			data[pos] = byte(rng.Intn(256))
+		}
+		check(data)
+	}
+}
@@ -30,6 +30,13 @@ type ParisProberConfig struct {
 	// FlowID is the fixed flow identifier for consistent routing
 	// If 0, a random but consistent ID is generated
 	FlowID uint16
+
+	// SeqStart is the sequence number of the first probe (0 = 1)
+	SeqStart uint16
+
+	// Zone is the IPv6 zone (interface name) of link-local destinations,
+	// e.g. "eth0" for fe80::1%eth0
+	Zone string
 }
 
 // DefaultParisProberConfig returns default Paris prober configuration.
@@ -52,11 +59,17 @@ func DefaultParisProberConfig() ParisProberConfig {
 // - UDP: Using same source/dest port pair and manipulating checksum
 // - TCP: Using same source/dest port pair and sequence number
 type ParisProber struct {
+	Counters
+	closeState
+
 	config   ParisProberConfig
 	icmpConn *icmp.PacketConn
 	udpConn  *net.UDPConn
 	flowID   uint16
 	sequence uint32
+
+	// pending counts probes awaiting a reply, for weak matches
+	pending pendingProbes
 }
 
 // NewParisProber creates a new Paris traceroute prober.
@@ -106,7 +119,7 @@ func NewParisProber(config ParisProberConfig) (*ParisProber, error) {
 		icmpConn: icmpConn,
 		udpConn:  udpConn,
 		flowID:   flowID,
-		sequence: 0,
+		sequence: initialSequence(config.SeqStart),
 	}, nil
 }
 
@@ -115,6 +128,11 @@ func (p *ParisProber) Probe(ctx context.Context, dest net.IP, ttl int) (*Result,
 	if ttl < 1 || ttl > 255 {
 		return nil, ErrInvalidTTL
 	}
+	if p.isClosed() {
+		return nil, ErrSocketClosed
+	}
+	done := p.pending.begin()
+	defer done()
 
 	switch p.config.Method {
 	case MethodICMP:
@@ -137,12 +155,12 @@ func (p *ParisProber) probeICMP(ctx context.Context, dest net.IP, ttl int) (*Res
 	if p.config.IPv6 {
 		pc = p.icmpConn.IPv6PacketConn()
 		if err := pc.(*ipv6.PacketConn).SetHopLimit(ttl); err != nil {
-			return nil, fmt.Errorf("failed to set hop limit: %w", err)
+			return nil, p.socketError(&p.Counters, fmt.Errorf("failed to set hop limit: %w", err))
 		}
 	} else {
 		pc = p.icmpConn.IPv4PacketConn()
 		if err := pc.(*ipv4.PacketConn).SetTTL(ttl); err != nil {
-			return nil, fmt.Errorf("failed to set TTL: %w", err)
+			return nil, p.socketError(&p.Counters, fmt.Errorf("failed to set TTL: %w", err))
 		}
 	}
 
@@ -157,7 +175,7 @@ func (p *ParisProber) probeICMP(ctx context.Context, dest net.IP, ttl int) (*Res
 	// Set read deadline
 	deadline := time.Now().Add(p.config.Timeout)
 	if err := p.icmpConn.SetReadDeadline(deadline); err != nil {
-		return nil, err
+		return nil, p.socketError(&p.Counters, err)
 	}
 
 	// Record send time
@@ -166,17 +184,23 @@ func (p *ParisProber) probeICMP(ctx context.Context, dest net.IP, ttl int) (*Res
 	// Send packet
 	var destAddr net.Addr
 	if p.config.IPv6 {
-		destAddr = &net.IPAddr{IP: dest}
+		destAddr = &net.IPAddr{IP: dest, Zone: p.config.Zone}
 	} else {
 		destAddr = &net.IPAddr{IP: dest}
 	}
 
 	if _, err := p.icmpConn.WriteTo(packet, destAddr); err != nil {
-		return nil, fmt.Errorf("failed to send ICMP: %w", err)
+		return nil, p.sendError(&p.Counters, fmt.Errorf("failed to send ICMP: %w", err))
 	}
+	p.CountSent()
 
 	// Receive response
-	return p.receiveICMPResponse(ctx, dest, id, seq, sendTime)
+	result, err := p.receiveICMPResponse(ctx, dest, id, seq, sendTime)
+	if result != nil {
+		result.Seq = uint32(seq)
+		result.SentAt = sendTime
+	}
+	return result, err
 }
 
 // buildParisICMPPacket creates an ICMP packet with Paris-style constant checksum.
@@ -225,7 +249,7 @@ func (p *ParisProber) buildParisICMPPacket(id, seq uint16) []byte {
 func (p *ParisProber) probeUDP(ctx context.Context, dest net.IP, ttl int) (*Result, error) {
 	// Set TTL on UDP socket
 	if err := p.setUDPTTL(ttl); err != nil {
-		return nil, fmt.Errorf("failed to set TTL: %w", err)
+		return nil, p.socketError(&p.Counters, fmt.Errorf("failed to set TTL: %w", err))
 	}
 
 	// Fixed source port derived from flow ID
@@ -233,18 +257,20 @@ func (p *ParisProber) probeUDP(ctx context.Context, dest net.IP, ttl int) (*Resu
 	destPort := p.config.Port
 
 	// Build payload with flow identifier embedded
-	payload := p.buildParisUDPPayload()
+	seq := atomic.AddUint32(&p.sequence, 1)
+	payload := p.buildParisUDPPayload(seq)
 
 	// Destination address
 	destAddr := &net.UDPAddr{
 		IP:   dest,
 		Port: destPort,
+		Zone: p.config.Zone,
 	}
 
 	// Set read deadline on ICMP listener
 	deadline := time.Now().Add(p.config.Timeout)
 	if err := p.icmpConn.SetReadDeadline(deadline); err != nil {
-		return nil, err
+		return nil, p.socketError(&p.Counters, err)
 	}
 
 	// Record send time
@@ -252,11 +278,17 @@ func (p *ParisProber) probeUDP(ctx context.Context, dest net.IP, ttl int) (*Resu
 
 	// Send UDP packet
 	if _, err := p.udpConn.WriteToUDP(payload, destAddr); err != nil {
-		return nil, fmt.Errorf("failed to send UDP: %w", err)
+		return nil, p.sendError(&p.Counters, fmt.Errorf("failed to send UDP: %w", err))
 	}
+	p.CountSent()
 
 	// Wait for ICMP response
-	return p.receiveUDPResponse(ctx, dest, destPort, sendTime)
+	result, err := p.receiveUDPResponse(ctx, dest, destPort, seq, sendTime)
+	if result != nil {
+		result.Seq = seq
+		result.SentAt = sendTime
+	}
+	return result, err
 }
 
 // setUDPTTL sets the TTL on the UDP socket.
@@ -284,7 +316,7 @@ func (p *ParisProber) setUDPTTL(ttl int) error {
 }
 
 // buildParisUDPPayload creates a UDP payload with embedded flow identifier.
-func (p *ParisProber) buildParisUDPPayload() []byte {
+func (p *ParisProber) buildParisUDPPayload(seq uint32) []byte {
 	// 32-byte payload with flow info
 	payload := make([]byte, 32)
 
@@ -292,7 +324,6 @@ func (p *ParisProber) buildParisUDPPayload() []byte {
 	binary.BigEndian.PutUint16(payload[0:2], p.flowID)
 
 	// Sequence (incrementing)
-	seq := atomic.AddUint32(&p.sequence, 1)
 	binary.BigEndian.PutUint32(payload[2:6], seq)
 
 	// Timestamp
@@ -317,10 +348,8 @@ func (p *ParisProber) receiveICMPResponse(ctx context.Context, dest net.IP, id,
 
 		n, peer, err := p.icmpConn.ReadFrom(buf)
 		if err != nil {
-			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-				return nil, ErrTimeout
-			}
-			return nil, err
+			p.countReadError(err)
+			return nil, readError(err)
 		}
 
 		rtt := time.Since(sendTime)
@@ -335,15 +364,19 @@ func (p *ParisProber) receiveICMPResponse(ctx context.Context, dest net.IP, id,
 
 		msg, err := icmp.ParseMessage(proto, buf[:n])
 		if err != nil {
+			p.CountDiscard(DiscardMalformed)
 			continue
 		}
 
 		result, ok := p.matchICMPResponse(msg, dest, id, seq)
 		if ok {
+			p.CountReceived()
 			result.RTT = rtt
 			result.ResponseIP = parseIP(peer)
+			result.Interface = interfaceFromMessage(msg)
 			return result, nil
 		}
+		p.CountDiscard(discardReason(msg))
 	}
 }
 
@@ -351,45 +384,49 @@ func (p *ParisProber) receiveICMPResponse(ctx context.Context, dest net.IP, id,
 func (p *ParisProber) matchICMPResponse(msg *icmp.Message, dest net.IP, id, seq uint16) (*Result, bool) {
 	result := &Result{}
 
-	if p.config.IPv6 {
-		switch msg.Type {
-		case ipv6.ICMPTypeEchoReply:
-			if echo, ok := msg.Body.(*icmp.Echo); ok {
-				if uint16(echo.ID) == id && uint16(echo.Seq) == seq {
-					result.Reached = true
-					result.ICMPType = msg.Type.(ipv6.ICMPType).Protocol()
-					return result, true
-				}
+	switch msg.Type {
+	case ipv4.ICMPTypeEchoReply, ipv6.ICMPTypeEchoReply:
+		if echo, ok := msg.Body.(*icmp.Echo); ok {
+			if uint16(echo.ID) == id && uint16(echo.Seq) == seq {
+				result.Reached = true
+				result.ResponseKind = ResponseEchoReply
+				result.ICMPType = icmpType(msg)
+				result.MatchQuality = MatchFull
+				return result, true
 			}
-		case ipv6.ICMPTypeTimeExceeded:
-			result.TTLExpired = true
-			result.ICMPType = msg.Type.(ipv6.ICMPType).Protocol()
-			result.ICMPCode = msg.Code
-			return result, true
 		}
-	} else {
-		switch msg.Type {
-		case ipv4.ICMPTypeEchoReply:
-			if echo, ok := msg.Body.(*icmp.Echo); ok {
-				if uint16(echo.ID) == id && uint16(echo.Seq) == seq {
-					result.Reached = true
-					result.ICMPType = msg.Type.(ipv4.ICMPType).Protocol()
-					return result, true
-				}
+	case ipv4.ICMPTypeTimeExceeded, ipv6.ICMPTypeTimeExceeded:
+		if body, ok := msg.Body.(*icmp.TimeExceeded); ok {
+			// The flow ID is a random 16-bit value that other hosts'
+			// pings may share, so the quote must also be ICMP to dest
+			if !quotesICMPTo(body.Data, dest) {
+				return nil, false
+			}
+			matchID := func(quoted uint16) bool { return quoted == id }
+			result.MatchQuality = matchQuotedEcho(body.Data, dest, nil, matchID, seq)
+			result.QuoteLen = len(body.Data)
+			if p.pending.accepts(result.MatchQuality) {
+				result.TTLExpired = true
+				result.ResponseKind = ResponseTTLExceeded
+				result.ICMPType = icmpType(msg)
+				result.ICMPCode = msg.Code
+				return result, true
 			}
-		case ipv4.ICMPTypeTimeExceeded:
-			result.TTLExpired = true
-			result.ICMPType = msg.Type.(ipv4.ICMPType).Protocol()
-			result.ICMPCode = msg.Code
-			return result, true
 		}
 	}
 
 	return nil, false
 }
 
+// quotesICMPTo reports whether an ICMP error quotes an ICMP datagram
+// sent to dest.
+func quotesICMPTo(data []byte, dest net.IP) bool {
+	q, ok := parseQuote(data)
+	return ok && (q.proto == 1 || q.proto == 58) && q.toward(dest, nil)
+}
+
 // receiveUDPResponse waits for ICMP response to UDP probe.
-func (p *ParisProber) receiveUDPResponse(ctx context.Context, dest net.IP, destPort int, sendTime time.Time) (*Result, error) {
+func (p *ParisProber) receiveUDPResponse(ctx context.Context, dest net.IP, destPort int, seq uint32, sendTime time.Time) (*Result, error) {
 	buf := make([]byte, 1500)
 
 	for {
@@ -401,10 +438,8 @@ func (p *ParisProber) receiveUDPResponse(ctx context.Context, dest net.IP, destP
 
 		n, peer, err := p.icmpConn.ReadFrom(buf)
 		if err != nil {
-			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-				return nil, ErrTimeout
-			}
-			return nil, err
+			p.countReadError(err)
+			return nil, readError(err)
 		}
 
 		rtt := time.Since(sendTime)
@@ -418,51 +453,80 @@ func (p *ParisProber) receiveUDPResponse(ctx context.Context, dest net.IP, destP
 
 		msg, err := icmp.ParseMessage(proto, buf[:n])
 		if err != nil {
+			p.CountDiscard(DiscardMalformed)
 			continue
 		}
 
-		result, ok := p.matchUDPResponse(msg, dest, destPort)
+		result, ok := p.matchUDPResponse(msg, dest, destPort, seq)
 		if ok {
+			p.CountReceived()
 			result.RTT = rtt
 			result.ResponseIP = parseIP(peer)
+			result.Interface = interfaceFromMessage(msg)
 			return result, nil
 		}
+		p.CountDiscard(discardReason(msg))
 	}
 }
 
 // matchUDPResponse checks if ICMP message is response to our UDP probe.
-func (p *ParisProber) matchUDPResponse(msg *icmp.Message, dest net.IP, destPort int) (*Result, bool) {
+func (p *ParisProber) matchUDPResponse(msg *icmp.Message, dest net.IP, destPort int, seq uint32) (*Result, bool) {
 	result := &Result{}
 
-	if p.config.IPv6 {
-		switch msg.Type {
-		case ipv6.ICMPTypeTimeExceeded:
-			result.TTLExpired = true
-			result.ICMPType = msg.Type.(ipv6.ICMPType).Protocol()
-			result.ICMPCode = msg.Code
-			return result, true
-		case ipv6.ICMPTypeDestinationUnreachable:
-			result.Reached = true
-			result.ICMPType = msg.Type.(ipv6.ICMPType).Protocol()
-			result.ICMPCode = msg.Code
-			return result, true
+	var orig []byte
+	switch msg.Type {
+	case ipv4.ICMPTypeTimeExceeded, ipv6.ICMPTypeTimeExceeded:
+		body, ok := msg.Body.(*icmp.TimeExceeded)
+		if !ok {
+			return nil, false
 		}
-	} else {
-		switch msg.Type {
-		case ipv4.ICMPTypeTimeExceeded:
-			result.TTLExpired = true
-			result.ICMPType = msg.Type.(ipv4.ICMPType).Protocol()
-			result.ICMPCode = msg.Code
-			return result, true
-		case ipv4.ICMPTypeDestinationUnreachable:
-			result.Reached = true
-			result.ICMPType = msg.Type.(ipv4.ICMPType).Protocol()
-			result.ICMPCode = msg.Code
-			return result, true
+		orig = body.Data
+		result.TTLExpired = true
+		result.ResponseKind = ResponseTTLExceeded
+	case ipv4.ICMPTypeDestinationUnreachable, ipv6.ICMPTypeDestinationUnreachable:
+		body, ok := msg.Body.(*icmp.DstUnreach)
+		if !ok {
+			return nil, false
 		}
+		orig = body.Data
+		result.Reached = true
+		result.ResponseKind = ResponseUnreachable
+	default:
+		return nil, false
 	}
 
-	return nil, false
+	result.MatchQuality = p.quotedUDPProbe(destPort, seq).match(orig, dest, nil)
+	result.QuoteLen = len(orig)
+	if !p.pending.accepts(result.MatchQuality) {
+		return nil, false
+	}
+	result.ICMPType = icmpType(msg)
+	result.ICMPCode = msg.Code
+	return result, true
+}
+
+// quotedUDPProbe describes the UDP probe with seq sent to destPort: its
+// fixed source port, then the flow identifier and sequence number that
+// start the payload.
+func (p *ParisProber) quotedUDPProbe(destPort int, seq uint32) transportProbe {
+	probe := transportProbe{proto: 17, dstPort: destPort, token: make([]byte, 6), tokenAt: 8}
+	if p.udpConn != nil {
+		probe.srcPort = p.udpConn.LocalAddr().(*net.UDPAddr).Port
+	}
+	binary.BigEndian.PutUint16(probe.token[0:2], p.flowID)
+	binary.BigEndian.PutUint32(probe.token[2:6], seq)
+	return probe
+}
+
+// icmpType returns the type number of an ICMPv4 or ICMPv6 message.
+func icmpType(msg *icmp.Message) int {
+	switch t := msg.Type.(type) {
+	case ipv4.ICMPType:
+		return int(t)
+	case ipv6.ICMPType:
+		return int(t)
+	}
+	return 0
 }
 
 // Name returns the probe method name.
@@ -470,31 +534,40 @@ func (p *ParisProber) Name() string {
 	return fmt.Sprintf("paris-%s", p.config.Method)
 }
 
+// SocketKind returns the kind of socket responses are received on.
+func (p *ParisProber) SocketKind() string {
+	return SocketRaw
+}
+
 // RequiresRoot returns true as Paris probing requires raw sockets.
 func (p *ParisProber) RequiresRoot() bool {
 	return true
 }
 
-// Close releases resources held by the prober.
+// Close releases resources held by the prober. Probes waiting for a
+// reply return ErrSocketClosed, as do later probes; closing again is a
+// no-op.
 func (p *ParisProber) Close() error {
-	var errs []error
+	return p.close(func() error {
+		var errs []error
 
-	if p.icmpConn != nil {
-		if err := p.icmpConn.Close(); err != nil {
-			errs = append(errs, err)
+		if p.icmpConn != nil {
+			if err := p.icmpConn.Close(); err != nil {
+				errs = append(errs, err)
+			}
 		}
-	}
 
-	if p.udpConn != nil {
-		if err := p.udpConn.Close(); err != nil {
-			errs = append(errs, err)
+		if p.udpConn != nil {
+			if err := p.udpConn.Close(); err != nil {
+				errs = append(errs, err)
+			}
 		}
-	}
 
-	if len(errs) > 0 {
-		return errs[0]
-	}
-	return nil
+		if len(errs) > 0 {
+			return errs[0]
+		}
+		return nil
+	})
 }
 
 // FlowID returns the flow identifier used by this prober.
@@ -2,9 +2,14 @@ package probe
 
 import (
 	"context"
+	"encoding/binary"
 	"net"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
 )
 
 func TestDefaultParisProberConfig(t *testing.T) {
@@ -141,7 +146,7 @@ func TestParisProber_BuildPayload(t *testing.T) {
 	}
 	defer prober.Close()
 
-	payload := prober.buildParisUDPPayload()
+	payload := prober.buildParisUDPPayload(1)
 
 	if len(payload) != 32 {
 		t.Errorf("Payload length = %d, want 32", len(payload))
@@ -210,3 +215,115 @@ func canCreateRawSocketParis() bool {
 func icmpListenPacket(network, address string) (net.PacketConn, error) {
 	return net.ListenPacket(network, address)
 }
+
+func TestParisProber_PacketIDs(t *testing.T) {
+	if !canCreateRawSocketParis() {
+		t.Skip("Skipping: requires elevated privileges")
+	}
+
+	for _, method := range []Method{MethodICMP, MethodUDP} {
+		prober, err := NewParisProber(ParisProberConfig{
+			Timeout:  time.Second,
+			Method:   method,
+			FlowID:   0xBEEF,
+			SeqStart: 100,
+		})
+		if err != nil {
+			t.Fatalf("NewParisProber() error = %v", err)
+		}
+		defer prober.Close()
+
+		if method == MethodICMP {
+			packet := prober.buildParisICMPPacket(prober.FlowID(), uint16(atomic.AddUint32(&prober.sequence, 1)))
+			if id, seq := binary.BigEndian.Uint16(packet[4:6]), binary.BigEndian.Uint16(packet[6:8]); id != 0xBEEF || seq != 100 {
+				t.Errorf("ICMP ID/seq = %#x/%d, want 0xbeef/100", id, seq)
+			}
+			continue
+		}
+
+		payload := prober.buildParisUDPPayload(atomic.AddUint32(&prober.sequence, 1))
+		if flow, seq := binary.BigEndian.Uint16(payload[0:2]), binary.BigEndian.Uint32(payload[2:6]); flow != 0xBEEF || seq != 100 {
+			t.Errorf("UDP flow/seq = %#x/%d, want 0xbeef/100", flow, seq)
+		}
+	}
+}
+
+func TestParisProber_RejectsForeignQuotes(t *testing.T) {
+	udpConn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Skipf("cannot listen on loopback: %v", err)
+	}
+	defer udpConn.Close()
+	srcPort := udpConn.LocalAddr().(*net.UDPAddr).Port
+
+	p := &ParisProber{config: ParisProberConfig{Port: 33434}, udpConn: udpConn, flowID: 0xBEEF}
+	dest := net.ParseIP("198.51.100.7")
+	other := net.ParseIP("203.0.113.9")
+
+	// udpQuote quotes a UDP probe in full; token is the payload start
+	udpQuote := func(dst net.IP, src, dstPort int, flow uint16, seq uint32) []byte {
+		udp := make([]byte, 14)
+		binary.BigEndian.PutUint16(udp[0:2], uint16(src))
+		binary.BigEndian.PutUint16(udp[2:4], uint16(dstPort))
+		binary.BigEndian.PutUint16(udp[8:10], flow)
+		binary.BigEndian.PutUint32(udp[10:14], seq)
+		return quotedDatagram(17, dst, udp)
+	}
+	echoQuote := func(proto int, dst net.IP, id, seq uint16) []byte {
+		echo := make([]byte, 8)
+		echo[0] = 8 // Echo Request
+		binary.BigEndian.PutUint16(echo[4:6], id)
+		binary.BigEndian.PutUint16(echo[6:8], seq)
+		return quotedDatagram(proto, dst, echo)
+	}
+	timeExceeded := func(data []byte) *icmp.Message {
+		return &icmp.Message{Type: ipv4.ICMPTypeTimeExceeded, Body: &icmp.TimeExceeded{Data: data}}
+	}
+	unreachable := func(data []byte) *icmp.Message {
+		return &icmp.Message{Type: ipv4.ICMPTypeDestinationUnreachable, Code: 3, Body: &icmp.DstUnreach{Data: data}}
+	}
+
+	udpTests := []struct {
+		name  string
+		msg   *icmp.Message
+		match bool
+	}{
+		{"ours", timeExceeded(udpQuote(dest, srcPort, 33434, 0xBEEF, 7)), true},
+		{"ours, port unreachable", unreachable(udpQuote(dest, srcPort, 33434, 0xBEEF, 7)), true},
+		{"another destination", timeExceeded(udpQuote(other, srcPort, 33434, 0xBEEF, 7)), false},
+		{"another destination port", timeExceeded(udpQuote(dest, srcPort, 53, 0xBEEF, 7)), false},
+		{"another source port", timeExceeded(udpQuote(dest, srcPort+1, 33434, 0xBEEF, 7)), false},
+		{"another flow", timeExceeded(udpQuote(dest, srcPort, 33434, 0xCAFE, 7)), false},
+		{"another probe", timeExceeded(udpQuote(dest, srcPort, 33434, 0xBEEF, 8)), false},
+		{"an Echo Request", timeExceeded(echoQuote(1, dest, 0xBEEF, 7)), false},
+	}
+	for _, tt := range udpTests {
+		result, ok := p.matchUDPResponse(tt.msg, dest, 33434, 7)
+		if ok != tt.match {
+			t.Errorf("UDP, %s: matchUDPResponse() = %+v, %v; want match %v", tt.name, result, ok, tt.match)
+		}
+	}
+
+	icmpTests := []struct {
+		name  string
+		msg   *icmp.Message
+		match bool
+	}{
+		{"ours", timeExceeded(echoQuote(1, dest, 0xBEEF, 7)), true},
+		{"another destination", timeExceeded(echoQuote(1, other, 0xBEEF, 7)), false},
+		{"another flow", timeExceeded(echoQuote(1, dest, 0xCAFE, 7)), false},
+		{"another probe", timeExceeded(echoQuote(1, dest, 0xBEEF, 8)), false},
+		{"a UDP datagram", timeExceeded(udpQuote(dest, srcPort, 33434, 0xBEEF, 7)), false},
+		{"not an ICMP datagram", timeExceeded(echoQuote(17, dest, 0xBEEF, 7)), false},
+		{"an Echo Reply of another flow", &icmp.Message{Type: ipv4.ICMPTypeEchoReply,
+			Body: &icmp.Echo{ID: 0xCAFE, Seq: 7}}, false},
+		{"our Echo Reply", &icmp.Message{Type: ipv4.ICMPTypeEchoReply,
+			Body: &icmp.Echo{ID: 0xBEEF, Seq: 7}}, true},
+	}
+	for _, tt := range icmpTests {
+		result, ok := p.matchICMPResponse(tt.msg, dest, 0xBEEF, 7)
+		if ok != tt.match {
+			t.Errorf("ICMP, %s: matchICMPResponse() = %+v, %v; want match %v", tt.name, result, ok, tt.match)
+		}
+	}
+}
@@ -3,7 +3,9 @@ package probe
 
 import (
 	"context"
+	"fmt"
 	"net"
+	"strings"
 	"time"
 )
 
@@ -22,8 +24,39 @@ type Prober interface {
 	// RequiresRoot returns true if this probe method requires root/admin privileges.
 	RequiresRoot() bool
 
-	// Close releases any resources held by the prober.
+	// Close releases any resources held by the prober. It may be called
+	// more than once and concurrently with Probe: probes in flight and
+	// probes sent after it return ErrSocketClosed.
 	Close() error
+
+	// Stats returns a snapshot of the prober's packet counters. Probers
+	// can embed Counters to implement it.
+	Stats() Stats
+}
+
+// Socket kinds reported by SocketReporter.
+const (
+	// SocketRaw is a privileged raw IP/ICMP socket
+	SocketRaw = "raw"
+	// SocketDgram is an unprivileged ICMP datagram ("ping") socket
+	SocketDgram = "dgram"
+)
+
+// SocketReporter is implemented by probers that can report which kind of
+// socket they receive responses on, so results can explain differences
+// in timing between machines.
+type SocketReporter interface {
+	SocketKind() string
+}
+
+// initialSequence returns the sequence counter value that makes the first
+// probe carry start. Probers pre-increment their counter, so start 0
+// keeps the default of numbering probes from 1.
+func initialSequence(start uint16) uint32 {
+	if start == 0 {
+		return 0
+	}
+	return uint32(start) - 1
 }
 
 // Result contains the result of a single probe.
@@ -46,8 +79,53 @@ type Result struct {
 
 	// TTLExpired indicates if the response was a TTL exceeded message
 	TTLExpired bool
+
+	// Interface identifies the responding router's interface when the
+	// ICMP error carried an RFC 5837 object (nil otherwise)
+	Interface *InterfaceInfo
+
+	// Seq is the sequence number the probe was sent with; probes sent
+	// later by the same prober have higher numbers
+	Seq uint32
+
+	// SentAt is when the probe was sent. RTT is measured from this time
+	// on the monotonic clock, so SentAt plus RTT is when the reply arrived.
+	SentAt time.Time
+
+	// SourceRouteRejected indicates the responder refused the probe's
+	// source route option (ICMP Parameter Problem or Source Route Failed)
+	SourceRouteRejected bool
+
+	// MatchQuality says how much of the probe the reply identified.
+	// Weak matches may belong to another probe to the same destination.
+	MatchQuality MatchQuality
+
+	// QuoteLen is the length of the original datagram quoted by an ICMP
+	// error (0 for replies from the destination itself)
+	QuoteLen int
+
+	// ResponseKind says which kind of packet answered the probe, one of
+	// the Response constants
+	ResponseKind string
 }
 
+// Response kinds reported in Result.ResponseKind.
+const (
+	// ResponseTTLExceeded is an ICMP Time Exceeded from a hop on the path
+	ResponseTTLExceeded = "icmp-ttl-exceeded"
+	// ResponseUnreachable is an ICMP Destination Unreachable
+	ResponseUnreachable = "icmp-unreachable"
+	// ResponseSourceRouteRejected is an ICMP error refusing the probe's
+	// source route option
+	ResponseSourceRouteRejected = "icmp-source-route-rejected"
+	// ResponseEchoReply is an ICMP Echo Reply from the destination
+	ResponseEchoReply = "icmp-echo-reply"
+	// ResponseSynAck is a TCP SYN-ACK from an open destination port
+	ResponseSynAck = "tcp-synack"
+	// ResponseRST is a TCP RST from a closed destination port
+	ResponseRST = "tcp-rst"
+)
+
 // Method represents the type of probe to use.
 type Method int
 
@@ -73,3 +151,15 @@ func (m Method) String() string {
 		return "unknown"
 	}
 }
+
+// ParseMethod parses a probe method name as returned by Method.String,
+// ignoring case and surrounding spaces.
+func ParseMethod(s string) (Method, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	for _, m := range []Method{MethodICMP, MethodUDP, MethodTCP} {
+		if m.String() == name {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("%w %q (want icmp, udp or tcp)", ErrUnknownMethod, s)
+}
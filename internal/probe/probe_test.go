@@ -0,0 +1,21 @@
+package probe
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseMethod(t *testing.T) {
+	for _, m := range []Method{MethodICMP, MethodUDP, MethodTCP} {
+		got, err := ParseMethod(m.String())
+		if err != nil || got != m {
+			t.Errorf("ParseMethod(%q) = %v, %v; want %v", m.String(), got, err, m)
+		}
+	}
+
+	for _, name := range []string{"", "tpc", "paris", "unknown"} {
+		if _, err := ParseMethod(name); !errors.Is(err, ErrUnknownMethod) {
+			t.Errorf("ParseMethod(%q) error = %v, want ErrUnknownMethod", name, err)
+		}
+	}
+}
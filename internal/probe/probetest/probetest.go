@@ -0,0 +1,187 @@
+// Package probetest provides a scripted prober for testing code that
+// sends probes, without raw sockets or elevated privileges.
+package probetest
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/probe"
+)
+
+// AnyAttempt scripts the reply to every probe of a TTL that has no reply
+// scripted for its attempt.
+const AnyAttempt = -1
+
+// Reply is the scripted outcome of one probe.
+type Reply struct {
+	// IP is the responding address ("" = the probe times out). A reply
+	// from the probed destination is marked Reached, any other address
+	// TTLExpired.
+	IP string
+
+	// RTT is the round-trip time reported in the result
+	RTT time.Duration
+
+	// Delay is how long Probe blocks before returning, to exercise
+	// timing and cancellation
+	Delay time.Duration
+
+	// Err is returned instead of a result, e.g. a *probe.SendError
+	Err error
+}
+
+// ScriptedProber is a probe.Prober that returns predefined replies keyed
+// by TTL and attempt, where attempt counts the probes already sent to
+// that TTL from 0. Unscripted probes time out. Like a real prober, it
+// fails probes with probe.ErrSocketClosed once closed, including those
+// waiting out a Delay. It is safe for concurrent use.
+type ScriptedProber struct {
+	probe.Counters
+
+	mu      sync.Mutex
+	replies map[key]Reply
+	probes  map[int]int
+	seq     uint32
+	closed  bool
+	done    chan struct{} // closed by Close
+}
+
+type key struct {
+	ttl, attempt int
+}
+
+// NewScriptedProber creates a ScriptedProber on which every probe times
+// out until replies are scripted.
+func NewScriptedProber() *ScriptedProber {
+	return &ScriptedProber{
+		replies: make(map[key]Reply),
+		probes:  make(map[int]int),
+		done:    make(chan struct{}),
+	}
+}
+
+// Reply scripts the reply to the given attempt at ttl, or to all
+// attempts without their own reply if attempt is AnyAttempt.
+func (p *ScriptedProber) Reply(ttl, attempt int, reply Reply) *ScriptedProber {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.replies[key{ttl, attempt}] = reply
+	return p
+}
+
+// Hop scripts ip to answer the probes at ttl with the given RTTs in
+// milliseconds, one per attempt; a negative RTT makes that attempt time
+// out. Without RTTs every attempt is answered after ttl milliseconds.
+func (p *ScriptedProber) Hop(ttl int, ip string, rtts ...float64) *ScriptedProber {
+	if len(rtts) == 0 {
+		return p.Reply(ttl, AnyAttempt, Reply{IP: ip, RTT: time.Duration(ttl) * time.Millisecond})
+	}
+	for attempt, rtt := range rtts {
+		reply := Reply{IP: ip, RTT: time.Duration(rtt * float64(time.Millisecond))}
+		if rtt < 0 {
+			reply = Reply{}
+		}
+		p.Reply(ttl, attempt, reply)
+	}
+	return p
+}
+
+// TimeoutHop scripts every probe at ttl to time out. Unscripted TTLs time
+// out too; this documents the intent and overrides an earlier Hop.
+func (p *ScriptedProber) TimeoutHop(ttl int) *ScriptedProber {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for k := range p.replies {
+		if k.ttl == ttl {
+			delete(p.replies, k)
+		}
+	}
+	return p
+}
+
+// Probes returns the number of probes sent with the given TTL.
+func (p *ScriptedProber) Probes(ttl int) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.probes[ttl]
+}
+
+// Closed reports whether Close was called.
+func (p *ScriptedProber) Closed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed
+}
+
+// Probe returns the reply scripted for the next attempt at ttl.
+func (p *ScriptedProber) Probe(ctx context.Context, dest net.IP, ttl int) (*probe.Result, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, probe.ErrSocketClosed
+	}
+	attempt := p.probes[ttl]
+	p.probes[ttl]++
+	p.seq++
+	seq := p.seq
+	reply, ok := p.replies[key{ttl, attempt}]
+	if !ok {
+		reply, ok = p.replies[key{ttl, AnyAttempt}]
+	}
+	p.mu.Unlock()
+
+	sentAt := time.Now()
+	if reply.Delay > 0 {
+		timer := time.NewTimer(reply.Delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-p.done:
+			timer.Stop()
+			return nil, probe.ErrSocketClosed
+		case <-timer.C:
+		}
+	}
+
+	if reply.Err != nil {
+		return nil, reply.Err
+	}
+	p.CountSent()
+
+	if !ok || reply.IP == "" {
+		p.CountTimeout()
+		return nil, probe.ErrTimeout
+	}
+	p.CountReceived()
+
+	ip := net.ParseIP(reply.IP)
+	return &probe.Result{
+		ResponseIP: ip,
+		RTT:        reply.RTT,
+		Reached:    ip.Equal(dest),
+		TTLExpired: !ip.Equal(dest),
+		Seq:        seq,
+		SentAt:     sentAt,
+	}, nil
+}
+
+// Name returns "scripted".
+func (p *ScriptedProber) Name() string { return "scripted" }
+
+// RequiresRoot returns false.
+func (p *ScriptedProber) RequiresRoot() bool { return false }
+
+// Close marks the prober closed. Closing again is a no-op.
+func (p *ScriptedProber) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.closed {
+		p.closed = true
+		close(p.done)
+	}
+	return nil
+}
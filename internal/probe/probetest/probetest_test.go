@@ -0,0 +1,75 @@
+package probetest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/probe"
+)
+
+func TestScriptedProber(t *testing.T) {
+	dest := net.ParseIP("203.0.113.9")
+	sendErr := &probe.SendError{Err: errors.New("no buffer space")}
+
+	p := NewScriptedProber().
+		Hop(1, "192.168.1.1", 1.5, -1).
+		Hop(2, "203.0.113.9").
+		Reply(3, 0, Reply{Err: sendErr})
+	ctx := context.Background()
+
+	result, err := p.Probe(ctx, dest, 1)
+	if err != nil {
+		t.Fatalf("Probe(1) error = %v", err)
+	}
+	if !result.ResponseIP.Equal(net.ParseIP("192.168.1.1")) || result.RTT != 1500*time.Microsecond || !result.TTLExpired {
+		t.Errorf("Probe(1) = %+v, want TTL expired from 192.168.1.1 after 1.5ms", result)
+	}
+	if _, err := p.Probe(ctx, dest, 1); !errors.Is(err, probe.ErrTimeout) {
+		t.Errorf("second Probe(1) error = %v, want timeout", err)
+	}
+	if _, err := p.Probe(ctx, dest, 1); !errors.Is(err, probe.ErrTimeout) {
+		t.Errorf("unscripted attempt error = %v, want timeout", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		result, err := p.Probe(ctx, dest, 2)
+		if err != nil || !result.Reached || result.RTT != 2*time.Millisecond {
+			t.Errorf("Probe(2) attempt %d = %+v, %v, want destination after 2ms", i, result, err)
+		}
+	}
+
+	if _, err := p.Probe(ctx, dest, 3); err != sendErr {
+		t.Errorf("Probe(3) error = %v, want %v", err, sendErr)
+	}
+	if _, err := p.Probe(ctx, dest, 4); !errors.Is(err, probe.ErrTimeout) {
+		t.Errorf("Probe(4) error = %v, want timeout", err)
+	}
+
+	if got := p.Probes(1); got != 3 {
+		t.Errorf("Probes(1) = %d, want 3", got)
+	}
+	if stats := p.Stats(); stats.Sent != 6 || stats.Received != 3 {
+		t.Errorf("Stats() = %+v, want 6 sent, 3 received", stats)
+	}
+}
+
+func TestScriptedProber_TimeoutHop(t *testing.T) {
+	p := NewScriptedProber().Hop(1, "192.168.1.1").TimeoutHop(1)
+	if _, err := p.Probe(context.Background(), net.ParseIP("203.0.113.9"), 1); !errors.Is(err, probe.ErrTimeout) {
+		t.Errorf("Probe() error = %v, want timeout", err)
+	}
+}
+
+func TestScriptedProber_DelayCancelled(t *testing.T) {
+	p := NewScriptedProber().Reply(1, AnyAttempt, Reply{IP: "192.168.1.1", Delay: time.Minute})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := p.Probe(ctx, net.ParseIP("203.0.113.9"), 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Probe() error = %v, want deadline exceeded", err)
+	}
+}
@@ -0,0 +1,169 @@
+package probe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"sync/atomic"
+)
+
+// MatchQuality says how much of a probe an ICMP error's quote of the
+// original datagram identified. Many routers quote only the IP header and
+// the first 8 bytes of the transport header, some even less.
+type MatchQuality int
+
+const (
+	// MatchNone is the zero value: the reply was not matched by a quote
+	// check, or did not match at all
+	MatchNone MatchQuality = iota
+	// MatchWeak matched only the protocol and destination address; the
+	// reply may belong to another probe to the same destination
+	MatchWeak
+	// MatchPartial matched the ports or identifier but the quote ended
+	// before the probe's payload token
+	MatchPartial
+	// MatchFull matched everything the probe carries to identify it, or
+	// the reply came straight from the destination
+	MatchFull
+)
+
+// String returns the name of the match quality.
+func (q MatchQuality) String() string {
+	switch q {
+	case MatchWeak:
+		return "weak"
+	case MatchPartial:
+		return "partial"
+	case MatchFull:
+		return "full"
+	default:
+		return "none"
+	}
+}
+
+// quote is the original datagram quoted by an ICMP error.
+type quote struct {
+	proto     int    // transport protocol of the original datagram
+	dst       net.IP // its destination
+	transport []byte // what was quoted after the IP header, possibly truncated
+}
+
+// parseQuote parses the IPv4 or IPv6 header at the start of an ICMP
+// error's quote. It fails if even the IP header is truncated.
+func parseQuote(data []byte) (quote, bool) {
+	if len(data) < 20 {
+		return quote{}, false
+	}
+	switch data[0] >> 4 {
+	case 4:
+		ihl := int(data[0]&0x0f) * 4
+		if ihl < 20 || len(data) < ihl {
+			return quote{}, false
+		}
+		return quote{proto: int(data[9]), dst: net.IP(data[16:20]), transport: data[ihl:]}, true
+	case 6:
+		if len(data) < 40 {
+			return quote{}, false
+		}
+		return quote{proto: int(data[6]), dst: net.IP(data[24:40]), transport: data[40:]}, true
+	}
+	return quote{}, false
+}
+
+// toward reports whether the quoted datagram was sent to dest, or to one
+// of the hops of its source route.
+func (q quote) toward(dest net.IP, via []net.IP) bool {
+	return q.dst.Equal(dest) || routedVia(via, q.dst)
+}
+
+// transportProbe describes a UDP or TCP probe for matching quotes.
+type transportProbe struct {
+	proto   int    // 6 (TCP) or 17 (UDP)
+	srcPort int    // 0 = not checked
+	dstPort int    // destination port
+	token   []byte // bytes identifying the probe (nil = not checked)
+	tokenAt int    // offset of token in the transport header and payload
+}
+
+// match grades a quote against the probe: the ports, then the token if
+// enough of the datagram was quoted. Without ports only the protocol and
+// destination are left to match.
+func (t transportProbe) match(data []byte, dest net.IP, via []net.IP) MatchQuality {
+	q, ok := parseQuote(data)
+	if !ok || !q.toward(dest, via) {
+		return MatchNone
+	}
+
+	h := q.transport
+	if len(h) < 4 {
+		if q.proto != t.proto {
+			return MatchNone
+		}
+		return MatchWeak
+	}
+	srcPort := int(binary.BigEndian.Uint16(h[0:2]))
+	dstPort := int(binary.BigEndian.Uint16(h[2:4]))
+	if (t.srcPort != 0 && srcPort != t.srcPort) || dstPort != t.dstPort {
+		return MatchNone
+	}
+
+	end := t.tokenAt + len(t.token)
+	if t.token == nil || len(h) < end {
+		return MatchPartial
+	}
+	if !bytes.Equal(h[t.tokenAt:end], t.token) {
+		return MatchNone
+	}
+	return MatchFull
+}
+
+// matchQuotedEcho grades a quote against the Echo Request with sequence
+// number seq. The identifier and sequence number are in the first 8
+// bytes, so a quote either identifies the probe fully or only weakly.
+func matchQuotedEcho(data []byte, dest net.IP, via []net.IP, matchID func(uint16) bool, seq uint16) MatchQuality {
+	q, ok := parseQuote(data)
+	if !ok {
+		return MatchNone
+	}
+
+	h := q.transport
+	if len(h) < 8 {
+		if (q.proto == 1 || q.proto == 58) && q.toward(dest, via) {
+			return MatchWeak
+		}
+		return MatchNone
+	}
+	if h[0] != 8 && h[0] != 128 { // Echo Request, ICMPv6 Echo Request
+		return MatchNone
+	}
+	if !matchID(binary.BigEndian.Uint16(h[4:6])) || binary.BigEndian.Uint16(h[6:8]) != seq {
+		return MatchNone
+	}
+	return MatchFull
+}
+
+// pendingProbes counts a prober's probes awaiting a reply. A weak match
+// cannot tell probes to the same destination apart, so it is only
+// accepted while no other probe is pending.
+type pendingProbes struct {
+	n atomic.Int32
+}
+
+// begin marks a probe pending until the returned function is called.
+func (p *pendingProbes) begin() func() {
+	p.n.Add(1)
+	return func() { p.n.Add(-1) }
+}
+
+// accepts reports whether a reply matched with quality q may be taken as
+// the reply to the calling probe.
+func (p *pendingProbes) accepts(q MatchQuality) bool {
+	switch q {
+	case MatchFull, MatchPartial:
+		return true
+	case MatchWeak:
+		return p.n.Load() <= 1
+	default:
+		return false
+	}
+}
@@ -0,0 +1,189 @@
+package probe
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// quotedDatagram builds a 68-byte IPv4 datagram to dst carrying transport,
+// as an ICMP error would quote it in full.
+func quotedDatagram(proto int, dst net.IP, transport []byte) []byte {
+	data := make([]byte, 68)
+	data[0] = 0x45 // version 4, 20-byte header
+	data[9] = byte(proto)
+	copy(data[16:20], dst.To4())
+	copy(data[20:], transport)
+	return data
+}
+
+// quoteLengths are the quote sizes seen from real routers: shorter than
+// an IP header, the IP header only, the RFC 792 minimum of 8 transport
+// bytes, and a generous quote.
+var quoteLengths = []int{8, 20, 28, 68}
+
+func TestMatchQuality_TruncatedQuotes(t *testing.T) {
+	dest := net.ParseIP("198.51.100.7")
+	peer := net.ParseIP("10.0.0.1")
+
+	echo := make([]byte, 8)
+	echo[0] = 8 // Echo Request
+	binary.BigEndian.PutUint16(echo[4:6], 0x1234)
+	binary.BigEndian.PutUint16(echo[6:8], 7)
+	echoQuote := quotedDatagram(1, dest, echo)
+
+	udpProber := &UDPProber{config: UDPProberConfig{BasePort: 33434, PayloadSize: 32}, id: 0x4242}
+	udp := make([]byte, 12)
+	binary.BigEndian.PutUint16(udp[2:4], 33441)
+	copy(udp[8:12], udpProber.token(7))
+	udpQuote := quotedDatagram(17, dest, udp)
+
+	tcpProber := &TCPProber{config: TCPProberConfig{Port: 443}}
+	syn := make([]byte, 8)
+	binary.BigEndian.PutUint16(syn[0:2], 50007)
+	binary.BigEndian.PutUint16(syn[2:4], 443)
+	binary.BigEndian.PutUint32(syn[4:8], 7)
+	tcpQuote := quotedDatagram(6, dest, syn)
+
+	paris := &ParisProber{config: ParisProberConfig{Port: 33434}, flowID: 0xBEEF}
+	parisEcho := make([]byte, 8)
+	parisEcho[0] = 8
+	binary.BigEndian.PutUint16(parisEcho[4:6], 0xBEEF)
+	binary.BigEndian.PutUint16(parisEcho[6:8], 7)
+	parisEchoQuote := quotedDatagram(1, dest, parisEcho)
+	parisUDPQuote := quotedDatagram(17, dest, append(make([]byte, 8), paris.quotedUDPProbe(33434, 7).token...))
+	binary.BigEndian.PutUint16(parisUDPQuote[22:24], 33434)
+
+	timeExceeded := func(data []byte) *icmp.Message {
+		return &icmp.Message{Type: ipv4.ICMPTypeTimeExceeded, Body: &icmp.TimeExceeded{Data: data}}
+	}
+
+	tests := []struct {
+		name  string
+		quote []byte
+		match func(data []byte) (*Result, bool)
+		want  []MatchQuality // per quoteLengths
+	}{
+		{
+			name:  "icmp",
+			quote: echoQuote,
+			match: func(data []byte) (*Result, bool) {
+				p := &ICMPProber{identifier: 0x1234, socket: SocketRaw}
+				return p.parseTimeExceeded(timeExceeded(data), peer, dest, time.Millisecond, 7)
+			},
+			want: []MatchQuality{MatchNone, MatchWeak, MatchFull, MatchFull},
+		},
+		{
+			name:  "udp",
+			quote: udpQuote,
+			match: func(data []byte) (*Result, bool) {
+				return udpProber.matchResponse(timeExceeded(data), dest, 33441, 7)
+			},
+			want: []MatchQuality{MatchNone, MatchWeak, MatchPartial, MatchFull},
+		},
+		{
+			name:  "tcp",
+			quote: tcpQuote,
+			match: func(data []byte) (*Result, bool) {
+				raw, err := timeExceeded(data).Marshal(nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return tcpProber.parseICMPResponse(raw, dest, 50007, 7)
+			},
+			want: []MatchQuality{MatchNone, MatchWeak, MatchFull, MatchFull},
+		},
+		{
+			name:  "paris-icmp",
+			quote: parisEchoQuote,
+			match: func(data []byte) (*Result, bool) {
+				return paris.matchICMPResponse(timeExceeded(data), dest, 0xBEEF, 7)
+			},
+			want: []MatchQuality{MatchNone, MatchWeak, MatchFull, MatchFull},
+		},
+		{
+			name:  "paris-udp",
+			quote: parisUDPQuote,
+			match: func(data []byte) (*Result, bool) {
+				return paris.matchUDPResponse(timeExceeded(data), dest, 33434, 7)
+			},
+			want: []MatchQuality{MatchNone, MatchWeak, MatchPartial, MatchFull},
+		},
+	}
+
+	for _, tt := range tests {
+		for i, n := range quoteLengths {
+			result, ok := tt.match(tt.quote[:n])
+			want := tt.want[i]
+			if want == MatchNone {
+				if ok {
+					t.Errorf("%s, %d-byte quote: matched %+v, want no match", tt.name, n, result)
+				}
+				continue
+			}
+			if !ok {
+				t.Errorf("%s, %d-byte quote: no match, want %s", tt.name, n, want)
+				continue
+			}
+			if result.MatchQuality != want || result.QuoteLen != n || !result.TTLExpired {
+				t.Errorf("%s, %d-byte quote: MatchQuality = %s, QuoteLen = %d, want %s, %d",
+					tt.name, n, result.MatchQuality, result.QuoteLen, want, n)
+			}
+		}
+	}
+}
+
+func TestMatchQuality_Mismatch(t *testing.T) {
+	dest := net.ParseIP("198.51.100.7")
+
+	syn := make([]byte, 8)
+	binary.BigEndian.PutUint16(syn[0:2], 50007)
+	binary.BigEndian.PutUint16(syn[2:4], 443)
+	binary.BigEndian.PutUint32(syn[4:8], 8) // another probe's sequence
+	probe := transportProbe{proto: 6, srcPort: 50007, dstPort: 443, token: []byte{0, 0, 0, 7}, tokenAt: 4}
+
+	if got := probe.match(quotedDatagram(6, dest, syn)[:28], dest, nil); got != MatchNone {
+		t.Errorf("match() = %s for another probe's sequence, want none", got)
+	}
+	if got := probe.match(quotedDatagram(6, dest, syn)[:24], dest, nil); got != MatchPartial {
+		t.Errorf("match() = %s for ports only, want partial", got)
+	}
+	if got := probe.match(quotedDatagram(17, dest, nil)[:20], dest, nil); got != MatchNone {
+		t.Errorf("match() = %s for another protocol, want none", got)
+	}
+	other := net.ParseIP("198.51.100.8")
+	if got := probe.match(quotedDatagram(6, other, nil)[:20], dest, nil); got != MatchNone {
+		t.Errorf("match() = %s for another destination, want none", got)
+	}
+}
+
+func TestPendingProbes_WeakMatches(t *testing.T) {
+	var pending pendingProbes
+
+	done := pending.begin()
+	if !pending.accepts(MatchWeak) {
+		t.Error("accepts(weak) = false with a single probe pending")
+	}
+
+	// A second probe could be the one the truncated quote belongs to
+	other := pending.begin()
+	if pending.accepts(MatchWeak) {
+		t.Error("accepts(weak) = true with two probes pending")
+	}
+	if !pending.accepts(MatchPartial) || !pending.accepts(MatchFull) {
+		t.Error("stricter matches must be accepted with several probes pending")
+	}
+	if pending.accepts(MatchNone) {
+		t.Error("accepts(none) = true")
+	}
+
+	other()
+	done()
+	if !pending.accepts(MatchWeak) {
+		t.Error("accepts(weak) = false once the other probe finished")
+	}
+}
@@ -0,0 +1,85 @@
+package probe
+
+import (
+	"net"
+	"sync"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// maxRedirects bounds the redirects a RedirectLog keeps, so a router
+// redirecting every probe of a long-running session cannot grow it
+// without limit.
+const maxRedirects = 256
+
+// Redirect is an ICMP Redirect a router sent for one of our probes. It is
+// only reported; the routing table is never changed.
+type Redirect struct {
+	// Router is the address the redirect came from
+	Router net.IP
+
+	// Gateway is the better first hop the router suggests
+	Gateway net.IP
+
+	// Code is the redirect code: 0 network, 1 host, 2 TOS and network,
+	// 3 TOS and host
+	Code int
+}
+
+// RedirectReporter is implemented by probers that record the ICMP
+// Redirects sent for their probes.
+type RedirectReporter interface {
+	// Redirects returns the redirects recorded so far, oldest first
+	Redirects() []Redirect
+}
+
+// RedirectLog records ICMP Redirects and is safe for concurrent use.
+// Embedding a RedirectLog provides the Redirects method of
+// RedirectReporter. Only the first maxRedirects are kept.
+type RedirectLog struct {
+	mu        sync.Mutex
+	redirects []Redirect
+}
+
+// RecordRedirect records a redirect.
+func (l *RedirectLog) RecordRedirect(r Redirect) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.redirects) < maxRedirects {
+		l.redirects = append(l.redirects, r)
+	}
+}
+
+// Redirects implements RedirectReporter.
+func (l *RedirectLog) Redirects() []Redirect {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Redirect(nil), l.redirects...)
+}
+
+// noteRedirect records msg if it is an ICMP Redirect from router whose
+// quoted datagram ours accepts, and reports whether it did.
+func (l *RedirectLog) noteRedirect(msg *icmp.Message, router net.IP, ours func(orig []byte) bool) bool {
+	gateway, orig, ok := parseRedirect(msg)
+	if !ok || !ours(orig) {
+		return false
+	}
+	l.RecordRedirect(Redirect{Router: router, Gateway: gateway, Code: msg.Code})
+	return true
+}
+
+// parseRedirect returns the suggested gateway and the quoted original
+// datagram of an IPv4 ICMP Redirect. ICMPv6 redirects are part of
+// neighbor discovery and never reach probe sockets.
+func parseRedirect(msg *icmp.Message) (gateway net.IP, orig []byte, ok bool) {
+	if msg.Type != ipv4.ICMPTypeRedirect {
+		return nil, nil, false
+	}
+	body, ok := msg.Body.(*icmp.RawBody)
+	if !ok || len(body.Data) < 4+20 {
+		return nil, nil, false
+	}
+	gateway = net.IPv4(body.Data[0], body.Data[1], body.Data[2], body.Data[3])
+	return gateway, body.Data[4:], true
+}
@@ -0,0 +1,111 @@
+package probe
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// redirectMessage builds an ICMP Redirect suggesting gateway for a quoted
+// datagram to dst, with payload as the first 8 bytes after the IP header.
+func redirectMessage(gateway, dst net.IP, payload []byte) *icmp.Message {
+	header := make([]byte, 20)
+	header[0] = 0x45 // version 4, 20-byte header
+	copy(header[16:20], dst.To4())
+
+	data := append([]byte(nil), gateway.To4()...)
+	data = append(data, header...)
+	data = append(data, payload[:8]...)
+	return &icmp.Message{Type: ipv4.ICMPTypeRedirect, Code: 1, Body: &icmp.RawBody{Data: data}}
+}
+
+func TestParseRedirect(t *testing.T) {
+	gateway := net.ParseIP("192.168.1.254")
+	msg := redirectMessage(gateway, net.ParseIP("198.51.100.7"), make([]byte, 8))
+
+	got, orig, ok := parseRedirect(msg)
+	if !ok || !got.Equal(gateway) || len(orig) != 28 {
+		t.Fatalf("parseRedirect() = %v, %d bytes, %v; want %v and the quoted datagram", got, len(orig), ok, gateway)
+	}
+
+	short := &icmp.Message{Type: ipv4.ICMPTypeRedirect, Body: &icmp.RawBody{Data: gateway.To4()}}
+	if _, _, ok := parseRedirect(short); ok {
+		t.Error("a redirect without a quoted datagram should not parse")
+	}
+	other := &icmp.Message{Type: ipv4.ICMPTypeTimeExceeded, Body: msg.Body}
+	if _, _, ok := parseRedirect(other); ok {
+		t.Error("a Time Exceeded message should not parse as a redirect")
+	}
+}
+
+func TestICMPProber_Redirect(t *testing.T) {
+	p := &ICMPProber{identifier: 0x1234, socket: SocketRaw}
+	router := &net.IPAddr{IP: net.ParseIP("192.168.1.1")}
+	dest := net.ParseIP("198.51.100.7")
+
+	echo := make([]byte, 8)
+	echo[0] = 8 // Echo Request
+	binary.BigEndian.PutUint16(echo[4:6], 0x1234)
+	binary.BigEndian.PutUint16(echo[6:8], 7)
+
+	data, err := redirectMessage(net.ParseIP("192.168.1.254"), dest, echo).Marshal(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result, ok := p.parseResponse(data, router, 1, dest, 7, time.Now()); ok {
+		t.Fatalf("parseResponse() = %+v; a redirect is not a reply", result)
+	}
+	redirects := p.Redirects()
+	if len(redirects) != 1 || !redirects[0].Router.Equal(router.IP) ||
+		!redirects[0].Gateway.Equal(net.ParseIP("192.168.1.254")) || redirects[0].Code != 1 {
+		t.Fatalf("Redirects() = %+v, want one from %s", redirects, router.IP)
+	}
+
+	// Another prober's echo is not ours
+	binary.BigEndian.PutUint16(echo[4:6], 0x4321)
+	data, _ = redirectMessage(net.ParseIP("192.168.1.254"), dest, echo).Marshal(nil)
+	p.parseResponse(data, router, 1, dest, 7, time.Now())
+	if n := len(p.Redirects()); n != 1 {
+		t.Errorf("redirect for another prober recorded, have %d", n)
+	}
+}
+
+func TestUDPProber_Redirect(t *testing.T) {
+	p := &UDPProber{}
+	dest := net.ParseIP("198.51.100.7")
+	router := net.ParseIP("192.168.1.1")
+
+	udp := make([]byte, 8)
+	binary.BigEndian.PutUint16(udp[2:4], 33435)
+	ours := func(orig []byte) bool { return p.matchOriginalUDP(orig, dest, 33435) }
+
+	if !p.noteRedirect(redirectMessage(net.ParseIP("192.168.1.254"), dest, udp), router, ours) {
+		t.Fatal("noteRedirect() = false for a redirect quoting our probe")
+	}
+	if p.noteRedirect(redirectMessage(net.ParseIP("192.168.1.254"), net.ParseIP("203.0.113.1"), udp), router, ours) {
+		t.Error("noteRedirect() = true for a redirect quoting another destination")
+	}
+	if n := len(p.Redirects()); n != 1 {
+		t.Errorf("Redirects() has %d entries, want 1", n)
+	}
+}
+
+func TestRedirectLog_Cap(t *testing.T) {
+	var log RedirectLog
+	for i := 0; i < maxRedirects+10; i++ {
+		log.RecordRedirect(Redirect{Router: net.ParseIP("192.168.1.1")})
+	}
+	if n := len(log.Redirects()); n != maxRedirects {
+		t.Errorf("Redirects() has %d entries, want %d", n, maxRedirects)
+	}
+
+	// The returned slice is a copy
+	log.Redirects()[0].Code = 3
+	if log.Redirects()[0].Code != 0 {
+		t.Error("Redirects() should return a copy")
+	}
+}
@@ -0,0 +1,123 @@
+package probe
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// destUnreachable builds a Port Unreachable message quoting an IPv4
+// datagram to dst whose transport header starts with payload.
+func destUnreachable(dst net.IP, payload []byte) *icmp.Message {
+	te := timeExceeded(dst, payload)
+	return &icmp.Message{Type: ipv4.ICMPTypeDestinationUnreachable, Code: 3,
+		Body: &icmp.DstUnreach{Data: te.Body.(*icmp.TimeExceeded).Data}}
+}
+
+func TestTCPProber_ResponseKind(t *testing.T) {
+	p := &TCPProber{config: TCPProberConfig{Port: 443}, localPort: 40000}
+	dest := net.ParseIP("198.51.100.7")
+
+	segment := func(flags byte) []byte {
+		tcp := make([]byte, 20)
+		binary.BigEndian.PutUint16(tcp[0:2], 443)
+		binary.BigEndian.PutUint16(tcp[2:4], 40005)
+		tcp[13] = flags
+		return tcp
+	}
+	tcpTests := []struct {
+		name  string
+		flags byte
+		want  string
+	}{
+		{"SYN-ACK", 0x12, ResponseSynAck},
+		{"RST", 0x04, ResponseRST},
+		{"RST-ACK", 0x14, ResponseRST},
+	}
+	for _, tt := range tcpTests {
+		result, ok := p.parseTCPResponse(segment(tt.flags), dest, 40005)
+		if !ok || !result.Reached || result.ResponseKind != tt.want {
+			t.Errorf("%s: parseTCPResponse() = %+v, %v; want a reply of kind %s", tt.name, result, ok, tt.want)
+		}
+	}
+	if result, ok := p.parseTCPResponse(segment(0x10), dest, 40005); ok {
+		t.Errorf("a bare ACK should not answer the probe, got %+v", result)
+	}
+
+	quote := make([]byte, 8)
+	binary.BigEndian.PutUint16(quote[0:2], 40005)
+	binary.BigEndian.PutUint16(quote[2:4], 443)
+	binary.BigEndian.PutUint32(quote[4:8], 5)
+	icmpTests := []struct {
+		name string
+		msg  *icmp.Message
+		want string
+	}{
+		{"time exceeded", timeExceeded(dest, quote), ResponseTTLExceeded},
+		{"unreachable", destUnreachable(dest, quote), ResponseUnreachable},
+	}
+	for _, tt := range icmpTests {
+		data, err := tt.msg.Marshal(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, ok := p.parseICMPResponse(data, dest, 40005, 5)
+		if !ok || result.ResponseKind != tt.want {
+			t.Errorf("%s: parseICMPResponse() = %+v, %v; want a reply of kind %s", tt.name, result, ok, tt.want)
+		}
+	}
+}
+
+func TestUDPProber_ResponseKind(t *testing.T) {
+	p := &UDPProber{config: UDPProberConfig{BasePort: 33434}}
+	dest := net.ParseIP("198.51.100.7")
+
+	udp := make([]byte, 8)
+	binary.BigEndian.PutUint16(udp[2:4], 33435)
+	if result, ok := p.matchResponse(timeExceeded(dest, udp), dest, 33435, 1); !ok || result.ResponseKind != ResponseTTLExceeded {
+		t.Errorf("matchResponse(Time Exceeded) = %+v, %v", result, ok)
+	}
+	if result, ok := p.matchResponse(destUnreachable(dest, udp), dest, 33435, 1); !ok || result.ResponseKind != ResponseUnreachable {
+		t.Errorf("matchResponse(Port Unreachable) = %+v, %v", result, ok)
+	}
+}
+
+func TestICMPProber_ResponseKind(t *testing.T) {
+	p := &ICMPProber{identifier: 0x1234, socket: SocketRaw}
+	dest := net.ParseIP("198.51.100.7")
+	peer := &net.IPAddr{IP: dest}
+
+	reply := &icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 0x1234, Seq: 7}}
+	data, err := reply.Marshal(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result, ok := p.parseResponse(data, peer, 1, dest, 7, time.Now()); !ok || result.ResponseKind != ResponseEchoReply {
+		t.Errorf("parseResponse(Echo Reply) = %+v, %v", result, ok)
+	}
+
+	echo := make([]byte, 8)
+	echo[0] = 8 // Echo Request
+	binary.BigEndian.PutUint16(echo[4:6], 0x1234)
+	binary.BigEndian.PutUint16(echo[6:8], 7)
+	data, err = timeExceeded(dest, echo).Marshal(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := &net.IPAddr{IP: net.ParseIP("10.0.0.1")}
+	if result, ok := p.parseResponse(data, router, 1, dest, 7, time.Now()); !ok || result.ResponseKind != ResponseTTLExceeded {
+		t.Errorf("parseResponse(Time Exceeded) = %+v, %v", result, ok)
+	}
+}
+
+func TestParisProber_ResponseKind(t *testing.T) {
+	p := &ParisProber{}
+	reply := &icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 0x4242, Seq: 3}}
+	if result, ok := p.matchICMPResponse(reply, net.ParseIP("198.51.100.7"), 0x4242, 3); !ok || result.ResponseKind != ResponseEchoReply {
+		t.Errorf("matchICMPResponse(Echo Reply) = %+v, %v", result, ok)
+	}
+}
@@ -0,0 +1,67 @@
+package probe
+
+import (
+	"errors"
+	"syscall"
+)
+
+// SendError is returned by Probe when a probe could not be sent at all,
+// as opposed to being sent and not answered. It wraps the socket error.
+type SendError struct {
+	Err error
+}
+
+func (e *SendError) Error() string { return e.Err.Error() }
+
+func (e *SendError) Unwrap() error { return e.Err }
+
+// errnoNames names the system errors a send commonly fails with.
+var errnoNames = map[syscall.Errno]string{
+	syscall.ENOBUFS:      "ENOBUFS",
+	syscall.EAGAIN:       "EAGAIN",
+	syscall.EPERM:        "EPERM",
+	syscall.EACCES:       "EACCES",
+	syscall.ENETUNREACH:  "ENETUNREACH",
+	syscall.EHOSTUNREACH: "EHOSTUNREACH",
+	syscall.ENETDOWN:     "ENETDOWN",
+	syscall.EMSGSIZE:     "EMSGSIZE",
+}
+
+// transientErrnos are send errors that a retry may get past: the kernel
+// ran out of buffers, or routes or firewall connection tracking are
+// changing, e.g. while a VPN reconnects.
+var transientErrnos = map[syscall.Errno]bool{
+	syscall.ENOBUFS:      true,
+	syscall.EAGAIN:       true,
+	syscall.EPERM:        true,
+	syscall.ENETUNREACH:  true,
+	syscall.EHOSTUNREACH: true,
+	syscall.ENETDOWN:     true,
+}
+
+// Errno returns the name of the system error, e.g. "ENOBUFS", falling
+// back to the error text for errors without a known name.
+func (e *SendError) Errno() string {
+	var errno syscall.Errno
+	if errors.As(e.Err, &errno) {
+		if name, ok := errnoNames[errno]; ok {
+			return name
+		}
+	}
+	return e.Err.Error()
+}
+
+// Transient reports whether sending the probe again may succeed.
+func (e *SendError) Transient() bool {
+	var errno syscall.Errno
+	return errors.As(e.Err, &errno) && transientErrnos[errno]
+}
+
+// AsSendError returns the SendError in err's chain, or nil.
+func AsSendError(err error) *SendError {
+	var se *SendError
+	if errors.As(err, &se) {
+		return se
+	}
+	return nil
+}
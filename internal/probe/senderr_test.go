@@ -0,0 +1,53 @@
+package probe
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestSendError(t *testing.T) {
+	tests := []struct {
+		err       error
+		errno     string
+		transient bool
+	}{
+		{syscall.ENOBUFS, "ENOBUFS", true},
+		{syscall.EPERM, "EPERM", true},
+		{syscall.ENETUNREACH, "ENETUNREACH", true},
+		{syscall.EHOSTUNREACH, "EHOSTUNREACH", true},
+		{syscall.EACCES, "EACCES", false},
+		{syscall.EMSGSIZE, "EMSGSIZE", false},
+		{errors.New("socket closed"), "", false}, // no errno: the error text
+	}
+
+	for _, tt := range tests {
+		// Probers wrap the errno in *os.SyscallError and a message
+		wrapped := fmt.Errorf("failed to send: %w", os.NewSyscallError("sendto", tt.err))
+		var err error = &SendError{Err: wrapped}
+
+		se := AsSendError(fmt.Errorf("hop 3: %w", err))
+		if se == nil {
+			t.Fatalf("AsSendError(%v) = nil", err)
+		}
+		want := tt.errno
+		if want == "" {
+			want = wrapped.Error()
+		}
+		if got := se.Errno(); got != want {
+			t.Errorf("Errno() = %q, want %q", got, want)
+		}
+		if got := se.Transient(); got != tt.transient {
+			t.Errorf("%v: Transient() = %v, want %v", tt.err, got, tt.transient)
+		}
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%v: SendError does not unwrap to the error", tt.err)
+		}
+	}
+
+	if AsSendError(ErrTimeout) != nil {
+		t.Error("AsSendError(ErrTimeout) != nil")
+	}
+}
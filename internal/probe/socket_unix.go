@@ -11,6 +11,12 @@ func setIPv4TTL(fd uintptr, ttl int) error {
 	return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TTL, ttl)
 }
 
+// setIPOptions sets the IPv4 header options of outgoing packets on Unix
+// systems.
+func setIPOptions(fd uintptr, options []byte) error {
+	return syscall.SetsockoptString(int(fd), syscall.IPPROTO_IP, syscall.IP_OPTIONS, string(options))
+}
+
 // setIPv6HopLimit sets the hop limit for an IPv6 socket on Unix systems.
 func setIPv6HopLimit(fd uintptr, hopLimit int) error {
 	return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_UNICAST_HOPS, hopLimit)
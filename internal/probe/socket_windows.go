@@ -9,6 +9,7 @@ import (
 
 const (
 	IPPROTO_IP   = 0
+	IP_OPTIONS   = 1
 	IP_TTL       = 4
 	IPPROTO_IPV6 = 41
 	IPV6_UNICAST_HOPS = 4
@@ -19,6 +20,14 @@ func setIPv4TTL(fd uintptr, ttl int) error {
 	return syscall.SetsockoptInt(syscall.Handle(fd), IPPROTO_IP, IP_TTL, ttl)
 }
 
+// setIPOptions sets the IPv4 header options of outgoing packets on Windows.
+func setIPOptions(fd uintptr, options []byte) error {
+	if len(options) == 0 {
+		return syscall.Setsockopt(syscall.Handle(fd), IPPROTO_IP, IP_OPTIONS, nil, 0)
+	}
+	return syscall.Setsockopt(syscall.Handle(fd), IPPROTO_IP, IP_OPTIONS, &options[0], int32(len(options)))
+}
+
 // setIPv6HopLimit sets the hop limit for an IPv6 socket on Windows.
 func setIPv6HopLimit(fd uintptr, hopLimit int) error {
 	return syscall.SetsockoptInt(syscall.Handle(fd), IPPROTO_IPV6, IPV6_UNICAST_HOPS, hopLimit)
@@ -0,0 +1,92 @@
+package probe
+
+import (
+	"context"
+	"net"
+	"syscall"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// MaxViaHops is the longest loose source route that fits in the 40 bytes
+// of IPv4 header options, next to the final destination slot.
+const MaxViaHops = 8
+
+// IPv4 option types used for source routing (RFC 791).
+const (
+	ipOptNOP  = 1
+	ipOptLSRR = 131
+)
+
+// icmpSourceRouteFailed is the Destination Unreachable code sent by
+// routers that will not forward a source-routed packet.
+const icmpSourceRouteFailed = 5
+
+// LooseSourceRoute encodes the IPv4 loose source route option (LSRR) that
+// sends packets through via, in order, before their destination. The last
+// address slot is left empty; the kernel fills in the destination of each
+// packet. A leading NOP keeps the addresses 32-bit aligned.
+func LooseSourceRoute(via []net.IP) ([]byte, error) {
+	if len(via) > MaxViaHops {
+		return nil, ErrTooManyViaHops
+	}
+
+	length := 3 + 4*(len(via)+1)
+	option := make([]byte, 0, 1+length)
+	option = append(option, ipOptNOP, ipOptLSRR, byte(length), 4)
+	for _, ip := range via {
+		ip4 := ip.To4()
+		if ip4 == nil {
+			return nil, ErrViaNotIPv4
+		}
+		option = append(option, ip4...)
+	}
+	return append(option, 0, 0, 0, 0), nil
+}
+
+// listenWithIPOptions opens a socket whose outgoing packets carry options,
+// which are set from the socket's Control hook before it is used.
+func listenWithIPOptions(network, address string, options []byte) (net.PacketConn, error) {
+	lc := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			var setErr error
+			if err := c.Control(func(fd uintptr) {
+				setErr = setIPOptions(fd, options)
+			}); err != nil {
+				return err
+			}
+			return setErr
+		},
+	}
+	return lc.ListenPacket(context.Background(), network, address)
+}
+
+// sourceRouteRejection returns the original packet quoted by an ICMP
+// message that refuses a source-routed probe: a Parameter Problem, or a
+// Destination Unreachable with the Source Route Failed code.
+func sourceRouteRejection(msg *icmp.Message) ([]byte, bool) {
+	switch msg.Type {
+	case ipv4.ICMPTypeParameterProblem:
+		if body, ok := msg.Body.(*icmp.ParamProb); ok {
+			return body.Data, true
+		}
+	case ipv4.ICMPTypeDestinationUnreachable:
+		if body, ok := msg.Body.(*icmp.DstUnreach); ok && msg.Code == icmpSourceRouteFailed {
+			return body.Data, true
+		}
+	}
+	return nil, false
+}
+
+// routedVia reports whether ip is one of the source route's via hops. A
+// source-routed packet is addressed to the next via hop until it reaches
+// the last one, so ICMP errors may quote any of them as the destination.
+func routedVia(via []net.IP, ip net.IP) bool {
+	for _, v := range via {
+		if v.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package probe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+func TestLooseSourceRoute(t *testing.T) {
+	option, err := LooseSourceRoute([]net.IP{net.ParseIP("10.0.0.5"), net.ParseIP("10.0.1.1")})
+	if err != nil {
+		t.Fatalf("LooseSourceRoute() error = %v", err)
+	}
+	want := []byte{
+		ipOptNOP, ipOptLSRR, 15, 4,
+		10, 0, 0, 5,
+		10, 0, 1, 1,
+		0, 0, 0, 0, // destination, filled in by the kernel
+	}
+	if !bytes.Equal(option, want) {
+		t.Errorf("LooseSourceRoute() = %v, want %v", option, want)
+	}
+
+	// The longest route fills the 40 bytes of IPv4 options exactly
+	via := make([]net.IP, MaxViaHops)
+	for i := range via {
+		via[i] = net.IPv4(192, 0, 2, byte(i+1))
+	}
+	option, err = LooseSourceRoute(via)
+	if err != nil {
+		t.Fatalf("LooseSourceRoute(%d hops) error = %v", MaxViaHops, err)
+	}
+	if len(option) != 40 || int(option[2]) != 39 {
+		t.Errorf("option length = %d (LSRR length %d), want 40 (39)", len(option), option[2])
+	}
+
+	if _, err := LooseSourceRoute(append(via, net.IPv4(192, 0, 2, 99))); !errors.Is(err, ErrTooManyViaHops) {
+		t.Errorf("LooseSourceRoute(%d hops) error = %v, want ErrTooManyViaHops", MaxViaHops+1, err)
+	}
+	if _, err := LooseSourceRoute([]net.IP{net.ParseIP("2001:db8::1")}); !errors.Is(err, ErrViaNotIPv4) {
+		t.Errorf("LooseSourceRoute(IPv6) error = %v, want ErrViaNotIPv4", err)
+	}
+}
+
+// quotedPacket builds the original IPv4 header (with an LSRR option) and
+// the first 8 bytes of its payload, as quoted in ICMP errors.
+func quotedPacket(dst net.IP, payload []byte) []byte {
+	header := make([]byte, 24)
+	header[0] = 0x46 // version 4, 24-byte header
+	copy(header[16:20], dst.To4())
+	copy(header[20:], []byte{ipOptNOP, ipOptLSRR, 7, 4})
+	return append(header, payload[:8]...)
+}
+
+func TestUDPProber_SourceRouteRejected(t *testing.T) {
+	dest := net.ParseIP("198.51.100.7")
+	via := net.ParseIP("10.0.0.5")
+	p := &UDPProber{config: UDPProberConfig{Via: []net.IP{via}}}
+
+	udp := make([]byte, 8)
+	binary.BigEndian.PutUint16(udp[2:4], 33435)
+
+	tests := []struct {
+		name string
+		msg  *icmp.Message
+	}{
+		{"parameter problem", &icmp.Message{Type: ipv4.ICMPTypeParameterProblem,
+			Body: &icmp.ParamProb{Pointer: 20, Data: quotedPacket(via, udp)}}},
+		{"source route failed", &icmp.Message{Type: ipv4.ICMPTypeDestinationUnreachable, Code: icmpSourceRouteFailed,
+			Body: &icmp.DstUnreach{Data: quotedPacket(dest, udp)}}},
+	}
+	for _, tt := range tests {
+		result, ok := p.matchResponse(tt.msg, dest, 33435, 1)
+		if !ok || !result.SourceRouteRejected || result.Reached || result.TTLExpired {
+			t.Errorf("%s: matchResponse() = %+v, %v; want a source route rejection", tt.name, result, ok)
+		}
+	}
+
+	// Port unreachable still means the destination was reached
+	msg := &icmp.Message{Type: ipv4.ICMPTypeDestinationUnreachable, Code: 3,
+		Body: &icmp.DstUnreach{Data: quotedPacket(dest, udp)}}
+	if result, ok := p.matchResponse(msg, dest, 33435, 1); !ok || !result.Reached || result.SourceRouteRejected {
+		t.Errorf("port unreachable: matchResponse() = %+v, %v", result, ok)
+	}
+
+	// Packets addressed elsewhere are not ours
+	msg = &icmp.Message{Type: ipv4.ICMPTypeParameterProblem,
+		Body: &icmp.ParamProb{Data: quotedPacket(net.ParseIP("203.0.113.1"), udp)}}
+	if _, ok := p.matchResponse(msg, dest, 33435, 1); ok {
+		t.Error("a rejection quoting another destination should not match")
+	}
+}
+
+func TestICMPProber_SourceRouteRejected(t *testing.T) {
+	p := &ICMPProber{identifier: 0x1234, socket: SocketRaw}
+
+	echo := make([]byte, 8)
+	echo[0] = 8 // Echo Request
+	binary.BigEndian.PutUint16(echo[4:6], 0x1234)
+	binary.BigEndian.PutUint16(echo[6:8], 7)
+
+	msg := &icmp.Message{Type: ipv4.ICMPTypeParameterProblem,
+		Body: &icmp.ParamProb{Pointer: 20, Data: quotedPacket(net.ParseIP("10.0.0.5"), echo)}}
+	data, err := msg.Marshal(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peer := &net.IPAddr{IP: net.ParseIP("10.0.0.1")}
+	result, ok := p.parseResponse(data, peer, 1, net.ParseIP("198.51.100.7"), 7, time.Now())
+	if !ok || !result.SourceRouteRejected || result.Reached || !result.ResponseIP.Equal(peer.IP) ||
+		result.ResponseKind != ResponseSourceRouteRejected {
+		t.Fatalf("parseResponse() = %+v, %v; want a source route rejection from %s", result, ok, peer.IP)
+	}
+
+	if _, ok := p.parseResponse(data, peer, 1, net.ParseIP("198.51.100.7"), 8, time.Now()); ok {
+		t.Error("a rejection of another sequence number should not match")
+	}
+}
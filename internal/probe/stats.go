@@ -0,0 +1,212 @@
+package probe
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// Reasons a received packet was discarded instead of matched to a probe.
+const (
+	// DiscardMalformed is a packet that could not be parsed
+	DiscardMalformed = "malformed"
+	// DiscardUnrelated is a message type that never answers a probe,
+	// such as other hosts' echo requests seen on a raw socket
+	DiscardUnrelated = "unrelated"
+	// DiscardMismatch is a probe response for another probe, e.g. a late
+	// reply or another traceroute running on the same host
+	DiscardMismatch = "mismatch"
+)
+
+// Stats is a snapshot of a prober's packet counters.
+type Stats struct {
+	// Method is the probe method name (filled in by the tracer)
+	Method string `json:"method,omitempty"`
+
+	// Sent is the number of probes sent
+	Sent uint64 `json:"sent"`
+
+	// Received is the number of responses matched to a probe
+	Received uint64 `json:"received"`
+
+	// Duplicates is the number of further replies to a probe that was
+	// already answered; they are dropped
+	Duplicates uint64 `json:"duplicates"`
+
+	// Discarded counts received packets that matched no probe, by reason
+	Discarded map[string]uint64 `json:"discarded,omitempty"`
+
+	// Timeouts is the number of probes that got no response
+	Timeouts uint64 `json:"timeouts"`
+
+	// Retransmissions is the number of probes resent after a timeout
+	Retransmissions uint64 `json:"retransmissions"`
+
+	// SocketErrors is the number of failed socket operations
+	SocketErrors uint64 `json:"socket_errors"`
+}
+
+// TotalDiscarded returns the number of discarded packets over all reasons.
+func (s Stats) TotalDiscarded() uint64 {
+	var total uint64
+	for _, n := range s.Discarded {
+		total += n
+	}
+	return total
+}
+
+// DiscardReasons returns the discard reasons in alphabetical order.
+func (s Stats) DiscardReasons() []string {
+	reasons := make([]string, 0, len(s.Discarded))
+	for reason := range s.Discarded {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	return reasons
+}
+
+// Add returns the sum of s and other. The method of s is kept.
+func (s Stats) Add(other Stats) Stats {
+	sum := Stats{
+		Method:          s.Method,
+		Sent:            s.Sent + other.Sent,
+		Received:        s.Received + other.Received,
+		Duplicates:      s.Duplicates + other.Duplicates,
+		Timeouts:        s.Timeouts + other.Timeouts,
+		Retransmissions: s.Retransmissions + other.Retransmissions,
+		SocketErrors:    s.SocketErrors + other.SocketErrors,
+	}
+	for _, d := range []map[string]uint64{s.Discarded, other.Discarded} {
+		for reason, n := range d {
+			if sum.Discarded == nil {
+				sum.Discarded = make(map[string]uint64)
+			}
+			sum.Discarded[reason] += n
+		}
+	}
+	return sum
+}
+
+// Sub returns the counts accumulated since the snapshot base was taken.
+func (s Stats) Sub(base Stats) Stats {
+	diff := Stats{
+		Method:          s.Method,
+		Sent:            s.Sent - base.Sent,
+		Received:        s.Received - base.Received,
+		Duplicates:      s.Duplicates - base.Duplicates,
+		Timeouts:        s.Timeouts - base.Timeouts,
+		Retransmissions: s.Retransmissions - base.Retransmissions,
+		SocketErrors:    s.SocketErrors - base.SocketErrors,
+	}
+	for reason, n := range s.Discarded {
+		if n -= base.Discarded[reason]; n > 0 {
+			if diff.Discarded == nil {
+				diff.Discarded = make(map[string]uint64)
+			}
+			diff.Discarded[reason] = n
+		}
+	}
+	return diff
+}
+
+// Counters collects prober statistics and is safe for concurrent use.
+// Embedding a Counters provides the Stats method of the Prober interface;
+// a prober that never increments it reports zero counts.
+type Counters struct {
+	sent            atomic.Uint64
+	received        atomic.Uint64
+	duplicates      atomic.Uint64
+	timeouts        atomic.Uint64
+	retransmissions atomic.Uint64
+	socketErrors    atomic.Uint64
+
+	mu        sync.Mutex
+	discarded map[string]uint64
+}
+
+// CountSent records a sent probe.
+func (c *Counters) CountSent() { c.sent.Add(1) }
+
+// CountReceived records a response matched to a probe.
+func (c *Counters) CountReceived() { c.received.Add(1) }
+
+// CountDuplicate records a further reply to an answered probe.
+func (c *Counters) CountDuplicate() { c.duplicates.Add(1) }
+
+// CountTimeout records a probe that got no response.
+func (c *Counters) CountTimeout() { c.timeouts.Add(1) }
+
+// CountRetransmission records a probe resent after a timeout.
+func (c *Counters) CountRetransmission() { c.retransmissions.Add(1) }
+
+// CountSocketError records a failed socket operation.
+func (c *Counters) CountSocketError() { c.socketErrors.Add(1) }
+
+// CountDiscard records a received packet that matched no probe.
+func (c *Counters) CountDiscard(reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.discarded == nil {
+		c.discarded = make(map[string]uint64)
+	}
+	c.discarded[reason]++
+}
+
+// Stats returns a snapshot of the counters.
+func (c *Counters) Stats() Stats {
+	s := Stats{
+		Sent:            c.sent.Load(),
+		Received:        c.received.Load(),
+		Duplicates:      c.duplicates.Load(),
+		Timeouts:        c.timeouts.Load(),
+		Retransmissions: c.retransmissions.Load(),
+		SocketErrors:    c.socketErrors.Load(),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.discarded) > 0 {
+		s.Discarded = make(map[string]uint64, len(c.discarded))
+		for reason, n := range c.discarded {
+			s.Discarded[reason] = n
+		}
+	}
+	return s
+}
+
+// countReadError records a failed read: a deadline expiry as a timeout,
+// anything but a closed socket as a socket error.
+func (c *Counters) countReadError(err error) {
+	switch {
+	case isTimeoutError(err):
+		c.CountTimeout()
+	case !errors.Is(err, net.ErrClosed):
+		c.CountSocketError()
+	}
+}
+
+// discardReason classifies a parsed ICMP message that matched no probe.
+func discardReason(msg *icmp.Message) string {
+	switch msg.Type {
+	case ipv4.ICMPTypeEchoReply, ipv4.ICMPTypeTimeExceeded, ipv4.ICMPTypeDestinationUnreachable,
+		ipv6.ICMPTypeEchoReply, ipv6.ICMPTypeTimeExceeded, ipv6.ICMPTypeDestinationUnreachable:
+		return DiscardMismatch
+	}
+	return DiscardUnrelated
+}
+
+// countUnmatched parses a packet that matched no probe and records why.
+func (c *Counters) countUnmatched(data []byte, proto int) {
+	msg, err := icmp.ParseMessage(proto, data)
+	if err != nil {
+		c.CountDiscard(DiscardMalformed)
+		return
+	}
+	c.CountDiscard(discardReason(msg))
+}
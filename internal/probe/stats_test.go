@@ -0,0 +1,99 @@
+package probe
+
+import (
+	"sync"
+	"testing"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+func TestCounters_Stats(t *testing.T) {
+	var c Counters
+
+	if s := c.Stats(); s.Sent != 0 || s.Discarded != nil {
+		t.Errorf("zero Counters should report zero stats, got %+v", s)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.CountSent()
+			c.CountReceived()
+			c.CountDiscard(DiscardMismatch)
+		}()
+	}
+	wg.Wait()
+	c.CountTimeout()
+	c.CountRetransmission()
+	c.CountSocketError()
+	c.CountDuplicate()
+	c.CountDiscard(DiscardMalformed)
+
+	s := c.Stats()
+	if s.Sent != 10 || s.Received != 10 || s.Duplicates != 1 || s.Timeouts != 1 || s.Retransmissions != 1 || s.SocketErrors != 1 {
+		t.Errorf("Stats() = %+v", s)
+	}
+	if s.Discarded[DiscardMismatch] != 10 || s.Discarded[DiscardMalformed] != 1 || s.TotalDiscarded() != 11 {
+		t.Errorf("Discarded = %v", s.Discarded)
+	}
+	if got := s.DiscardReasons(); len(got) != 2 || got[0] != DiscardMalformed {
+		t.Errorf("DiscardReasons() = %v", got)
+	}
+
+	// Snapshots must not share the discard map
+	s.Discarded[DiscardMismatch] = 0
+	if c.Stats().Discarded[DiscardMismatch] != 10 {
+		t.Error("modifying a snapshot should not affect the counters")
+	}
+}
+
+func TestStats_AddSub(t *testing.T) {
+	base := Stats{Sent: 3, Received: 2, Duplicates: 1, Discarded: map[string]uint64{DiscardMismatch: 1}}
+	later := Stats{Method: "icmp", Sent: 8, Received: 5, Duplicates: 3, Timeouts: 2,
+		Discarded: map[string]uint64{DiscardMismatch: 1, DiscardUnrelated: 4}}
+
+	diff := later.Sub(base)
+	if diff.Method != "icmp" || diff.Sent != 5 || diff.Received != 3 || diff.Duplicates != 2 || diff.Timeouts != 2 {
+		t.Errorf("Sub() = %+v", diff)
+	}
+	if len(diff.Discarded) != 1 || diff.Discarded[DiscardUnrelated] != 4 {
+		t.Errorf("Sub() discarded = %v, want only the new unrelated packets", diff.Discarded)
+	}
+
+	sum := diff.Add(Stats{Retransmissions: 1, Discarded: map[string]uint64{DiscardUnrelated: 1}})
+	if sum.Sent != 5 || sum.Retransmissions != 1 || sum.Discarded[DiscardUnrelated] != 5 {
+		t.Errorf("Add() = %+v", sum)
+	}
+}
+
+func TestCounters_CountUnmatched(t *testing.T) {
+	echo, err := (&icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Body: &icmp.Echo{ID: 1, Seq: 1},
+	}).Marshal(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, err := (&icmp.Message{
+		Type: ipv4.ICMPTypeEchoReply,
+		Body: &icmp.Echo{ID: 1, Seq: 1},
+	}).Marshal(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var c Counters
+	c.countUnmatched(echo, 1)
+	c.countUnmatched(reply, 1)
+	c.countUnmatched([]byte{0x0b}, 1)
+
+	s := c.Stats()
+	for _, reason := range []string{DiscardUnrelated, DiscardMismatch, DiscardMalformed} {
+		if s.Discarded[reason] != 1 {
+			t.Errorf("Discarded[%s] = %d, want 1 (%v)", reason, s.Discarded[reason], s.Discarded)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package probe
 import (
 	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"net"
 	"sync/atomic"
@@ -23,6 +24,13 @@ type TCPProberConfig struct {
 
 	// IPv6 enables IPv6 mode
 	IPv6 bool
+
+	// SeqStart is the sequence number of the first probe (0 = 1)
+	SeqStart uint16
+
+	// Zone is the IPv6 zone (interface name) of link-local destinations,
+	// e.g. "eth0" for fe80::1%eth0
+	Zone string
 }
 
 // DefaultTCPProberConfig returns a default TCP prober configuration.
@@ -39,14 +47,28 @@ func DefaultTCPProberConfig() TCPProberConfig {
 // - ICMP Time Exceeded (intermediate hops)
 // - TCP SYN-ACK or RST (destination reached)
 type TCPProber struct {
+	Counters
+	RedirectLog
+	closeState
+
 	config   TCPProberConfig
 	icmpConn *icmp.PacketConn
 	rawConn  net.PacketConn
 	localIP  net.IP
 	localPort uint16
 	sequence uint32
+
+	// replies tracks answered source port offsets to spot duplicates
+	replies replyTracker
+
+	// pending counts probes awaiting a reply, for weak matches
+	pending pendingProbes
 }
 
+// tcpPortSpan is the number of source ports probes cycle through,
+// starting at the prober's local port.
+const tcpPortSpan = 1000
+
 // NewTCPProber creates a new TCP SYN prober.
 func NewTCPProber(config TCPProberConfig) (*TCPProber, error) {
 	if config.Timeout == 0 {
@@ -90,7 +112,7 @@ func NewTCPProber(config TCPProberConfig) (*TCPProber, error) {
 		rawConn:   rawConn,
 		localIP:   localIP,
 		localPort: uint16(30000 + (time.Now().UnixNano() % 10000)),
-		sequence:  0,
+		sequence:  initialSequence(config.SeqStart),
 	}, nil
 }
 
@@ -99,15 +121,21 @@ func (p *TCPProber) Probe(ctx context.Context, dest net.IP, ttl int) (*Result, e
 	if ttl < 1 || ttl > 255 {
 		return nil, ErrInvalidTTL
 	}
+	if p.isClosed() {
+		return nil, ErrSocketClosed
+	}
+	done := p.pending.begin()
+	defer done()
 
 	// Set TTL on raw socket
 	if err := p.setTTL(ttl); err != nil {
-		return nil, fmt.Errorf("failed to set TTL: %w", err)
+		return nil, p.socketError(&p.Counters, fmt.Errorf("failed to set TTL: %w", err))
 	}
 
 	// Generate unique sequence number
 	seq := atomic.AddUint32(&p.sequence, 1)
-	srcPort := p.localPort + uint16(seq%1000)
+	srcPort := p.localPort + uint16(seq%tcpPortSpan)
+	p.replies.sent(seq % tcpPortSpan)
 
 	// Build TCP SYN packet
 	packet := p.buildSYNPacket(p.localIP, dest, srcPort, uint16(p.config.Port), seq)
@@ -115,10 +143,10 @@ func (p *TCPProber) Probe(ctx context.Context, dest net.IP, ttl int) (*Result, e
 	// Set read deadline
 	deadline := time.Now().Add(p.config.Timeout)
 	if err := p.icmpConn.SetReadDeadline(deadline); err != nil {
-		return nil, fmt.Errorf("failed to set ICMP deadline: %w", err)
+		return nil, p.socketError(&p.Counters, fmt.Errorf("failed to set ICMP deadline: %w", err))
 	}
 	if err := p.rawConn.SetReadDeadline(deadline); err != nil {
-		return nil, fmt.Errorf("failed to set TCP deadline: %w", err)
+		return nil, p.socketError(&p.Counters, fmt.Errorf("failed to set TCP deadline: %w", err))
 	}
 
 	// Record send time
@@ -127,17 +155,23 @@ func (p *TCPProber) Probe(ctx context.Context, dest net.IP, ttl int) (*Result, e
 	// Send TCP SYN packet
 	var destAddr net.Addr
 	if p.config.IPv6 {
-		destAddr = &net.IPAddr{IP: dest}
+		destAddr = &net.IPAddr{IP: dest, Zone: p.config.Zone}
 	} else {
 		destAddr = &net.IPAddr{IP: dest}
 	}
 
 	if _, err := p.rawConn.WriteTo(packet, destAddr); err != nil {
-		return nil, fmt.Errorf("failed to send TCP SYN: %w", err)
+		return nil, p.sendError(&p.Counters, fmt.Errorf("failed to send TCP SYN: %w", err))
 	}
+	p.CountSent()
 
 	// Wait for response (ICMP or TCP)
-	return p.receiveResponse(ctx, dest, srcPort, sendTime)
+	result, err := p.receiveResponse(ctx, dest, srcPort, seq, sendTime)
+	if result != nil {
+		result.Seq = seq
+		result.SentAt = sendTime
+	}
+	return result, err
 }
 
 // setTTL sets the TTL on the raw TCP socket.
@@ -228,10 +262,15 @@ func (p *TCPProber) tcpChecksum(src, dst net.IP, tcpHeader []byte) uint16 {
 }
 
 // receiveResponse waits for ICMP or TCP response.
-func (p *TCPProber) receiveResponse(ctx context.Context, dest net.IP, srcPort uint16, sendTime time.Time) (*Result, error) {
+func (p *TCPProber) receiveResponse(ctx context.Context, dest net.IP, srcPort uint16, seq uint32, sendTime time.Time) (*Result, error) {
 	icmpBuf := make([]byte, 1500)
 	tcpBuf := make([]byte, 1500)
 
+	icmpProto := 1
+	if p.config.IPv6 {
+		icmpProto = 58
+	}
+
 	// Create channels for responses
 	icmpChan := make(chan *Result, 1)
 	tcpChan := make(chan *Result, 1)
@@ -242,22 +281,31 @@ func (p *TCPProber) receiveResponse(ctx context.Context, dest net.IP, srcPort ui
 		for {
 			n, peer, err := p.icmpConn.ReadFrom(icmpBuf)
 			if err != nil {
-				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-					errChan <- ErrTimeout
-					return
-				}
-				errChan <- err
+				p.countReadError(err)
+				errChan <- readError(err)
 				return
 			}
 
 			rtt := time.Since(sendTime)
-			result, ok := p.parseICMPResponse(icmpBuf[:n], dest, srcPort)
+			if msg, err := icmp.ParseMessage(icmpProto, icmpBuf[:n]); err == nil {
+				p.noteRedirect(msg, parseIP(peer), func(orig []byte) bool {
+					return p.matchOriginalTCP(orig, dest, srcPort)
+				})
+			}
+			result, ok := p.parseICMPResponse(icmpBuf[:n], dest, srcPort, seq)
 			if ok {
+				p.replies.answer(uint32(srcPort - p.localPort))
+				p.CountReceived()
 				result.RTT = rtt
 				result.ResponseIP = parseIP(peer)
 				icmpChan <- result
 				return
 			}
+			if msg, err := icmp.ParseMessage(icmpProto, icmpBuf[:n]); err == nil && p.isDuplicateICMP(msg, dest) {
+				p.CountDuplicate()
+				continue
+			}
+			p.countUnmatched(icmpBuf[:n], icmpProto)
 		}
 	}()
 
@@ -266,8 +314,10 @@ func (p *TCPProber) receiveResponse(ctx context.Context, dest net.IP, srcPort ui
 		for {
 			n, peer, err := p.rawConn.ReadFrom(tcpBuf)
 			if err != nil {
-				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-					return
+				// Timeouts are counted by the ICMP listener, and a
+				// closed prober closes both sockets
+				if !isTimeoutError(err) && !errors.Is(err, net.ErrClosed) {
+					p.CountSocketError()
 				}
 				return
 			}
@@ -275,11 +325,19 @@ func (p *TCPProber) receiveResponse(ctx context.Context, dest net.IP, srcPort ui
 			rtt := time.Since(sendTime)
 			result, ok := p.parseTCPResponse(tcpBuf[:n], dest, srcPort)
 			if ok {
+				p.replies.answer(uint32(srcPort - p.localPort))
+				p.CountReceived()
 				result.RTT = rtt
 				result.ResponseIP = parseIP(peer)
 				tcpChan <- result
 				return
 			}
+			if p.isDuplicateTCP(tcpBuf[:n]) {
+				p.CountDuplicate()
+				continue
+			}
+			// Other connections' segments arrive on the raw socket too
+			p.CountDiscard(DiscardUnrelated)
 		}
 	}()
 
@@ -297,7 +355,7 @@ func (p *TCPProber) receiveResponse(ctx context.Context, dest net.IP, srcPort ui
 }
 
 // parseICMPResponse parses an ICMP response for our TCP probe.
-func (p *TCPProber) parseICMPResponse(data []byte, dest net.IP, srcPort uint16) (*Result, bool) {
+func (p *TCPProber) parseICMPResponse(data []byte, dest net.IP, srcPort uint16, seq uint32) (*Result, bool) {
 	var proto int
 	if p.config.IPv6 {
 		proto = 58
@@ -310,14 +368,15 @@ func (p *TCPProber) parseICMPResponse(data []byte, dest net.IP, srcPort uint16)
 		return nil, false
 	}
 
-	result := &Result{}
+	result := &Result{Interface: interfaceFromMessage(msg)}
 
 	if p.config.IPv6 {
 		switch msg.Type {
 		case ipv6.ICMPTypeTimeExceeded:
 			if body, ok := msg.Body.(*icmp.TimeExceeded); ok {
-				if p.matchOriginalTCP(body.Data, dest, srcPort) {
+				if p.matchQuote(body.Data, dest, srcPort, seq, result) {
 					result.TTLExpired = true
+					result.ResponseKind = ResponseTTLExceeded
 					result.ICMPType = msg.Type.(ipv6.ICMPType).Protocol()
 					result.ICMPCode = msg.Code
 					return result, true
@@ -325,8 +384,9 @@ func (p *TCPProber) parseICMPResponse(data []byte, dest net.IP, srcPort uint16)
 			}
 		case ipv6.ICMPTypeDestinationUnreachable:
 			if body, ok := msg.Body.(*icmp.DstUnreach); ok {
-				if p.matchOriginalTCP(body.Data, dest, srcPort) {
+				if p.matchQuote(body.Data, dest, srcPort, seq, result) {
 					result.Reached = true
+					result.ResponseKind = ResponseUnreachable
 					result.ICMPType = msg.Type.(ipv6.ICMPType).Protocol()
 					result.ICMPCode = msg.Code
 					return result, true
@@ -337,8 +397,9 @@ func (p *TCPProber) parseICMPResponse(data []byte, dest net.IP, srcPort uint16)
 		switch msg.Type {
 		case ipv4.ICMPTypeTimeExceeded:
 			if body, ok := msg.Body.(*icmp.TimeExceeded); ok {
-				if p.matchOriginalTCP(body.Data, dest, srcPort) {
+				if p.matchQuote(body.Data, dest, srcPort, seq, result) {
 					result.TTLExpired = true
+					result.ResponseKind = ResponseTTLExceeded
 					result.ICMPType = msg.Type.(ipv4.ICMPType).Protocol()
 					result.ICMPCode = msg.Code
 					return result, true
@@ -346,8 +407,9 @@ func (p *TCPProber) parseICMPResponse(data []byte, dest net.IP, srcPort uint16)
 			}
 		case ipv4.ICMPTypeDestinationUnreachable:
 			if body, ok := msg.Body.(*icmp.DstUnreach); ok {
-				if p.matchOriginalTCP(body.Data, dest, srcPort) {
+				if p.matchQuote(body.Data, dest, srcPort, seq, result) {
 					result.Reached = true
+					result.ResponseKind = ResponseUnreachable
 					result.ICMPType = msg.Type.(ipv4.ICMPType).Protocol()
 					result.ICMPCode = msg.Code
 					return result, true
@@ -359,39 +421,57 @@ func (p *TCPProber) parseICMPResponse(data []byte, dest net.IP, srcPort uint16)
 	return nil, false
 }
 
-// matchOriginalTCP checks if ICMP error contains our original TCP packet.
+// quotedProbe describes the SYN sent from srcPort with sequence number
+// seq, which follows the ports in the TCP header.
+func (p *TCPProber) quotedProbe(srcPort uint16, seq uint32) transportProbe {
+	token := make([]byte, 4)
+	binary.BigEndian.PutUint32(token, seq)
+	return transportProbe{proto: 6, srcPort: int(srcPort), dstPort: p.config.Port, token: token, tokenAt: 4}
+}
+
+// matchQuote grades the datagram quoted by an ICMP error against the SYN
+// sent from srcPort with seq, records the grade in result and reports
+// whether to accept it.
+func (p *TCPProber) matchQuote(data []byte, dest net.IP, srcPort uint16, seq uint32, result *Result) bool {
+	result.MatchQuality = p.quotedProbe(srcPort, seq).match(data, dest, nil)
+	result.QuoteLen = len(data)
+	return p.pending.accepts(result.MatchQuality)
+}
+
+// matchOriginalTCP reports whether the datagram quoted by an ICMP error
+// is one of our SYNs from srcPort, identified at least by its ports.
 func (p *TCPProber) matchOriginalTCP(data []byte, dest net.IP, srcPort uint16) bool {
-	if len(data) < 28 { // IP header + TCP header
-		return false
-	}
+	probe := transportProbe{proto: 6, srcPort: int(srcPort), dstPort: p.config.Port}
+	return probe.match(data, dest, nil) >= MatchPartial
+}
 
-	// Skip IP header
-	ihl := int(data[0]&0x0f) * 4
-	if ihl < 20 || len(data) < ihl+8 {
+// isDuplicateICMP reports whether msg quotes one of our probes to dest
+// that was already answered.
+func (p *TCPProber) isDuplicateICMP(msg *icmp.Message, dest net.IP) bool {
+	orig, header := quotedTransport(msg)
+	if header == nil {
 		return false
 	}
-
-	tcpHeader := data[ihl:]
-
-	// Check source port
-	pktSrcPort := binary.BigEndian.Uint16(tcpHeader[0:2])
-	if pktSrcPort != srcPort {
+	srcPort := binary.BigEndian.Uint16(header[0:2])
+	offset := srcPort - p.localPort
+	if srcPort < p.localPort || offset >= tcpPortSpan || !p.matchOriginalTCP(orig, dest, srcPort) {
 		return false
 	}
+	return p.replies.isAnswered(uint32(offset))
+}
 
-	// Check destination port
-	pktDstPort := binary.BigEndian.Uint16(tcpHeader[2:4])
-	if int(pktDstPort) != p.config.Port {
+// isDuplicateTCP reports whether a TCP segment is a further reply, such
+// as a retransmitted SYN-ACK, to a probe that was already answered.
+func (p *TCPProber) isDuplicateTCP(data []byte) bool {
+	if len(data) < 20 || int(binary.BigEndian.Uint16(data[0:2])) != p.config.Port {
 		return false
 	}
-
-	// Check destination IP
-	destIPInPacket := net.IP(data[16:20])
-	if !destIPInPacket.Equal(dest) {
+	dstPort := binary.BigEndian.Uint16(data[2:4])
+	offset := dstPort - p.localPort
+	if dstPort < p.localPort || offset >= tcpPortSpan {
 		return false
 	}
-
-	return true
+	return p.replies.isAnswered(uint32(offset))
 }
 
 // parseTCPResponse parses a TCP response (SYN-ACK or RST).
@@ -411,18 +491,23 @@ func (p *TCPProber) parseTCPResponse(data []byte, dest net.IP, srcPort uint16) (
 	}
 
 	result := &Result{
-		Reached: true,
+		Reached:      true,
+		MatchQuality: MatchFull,
 	}
 
 	// Check flags
 	synAck := (flags & 0x12) == 0x12 // SYN + ACK
 	rst := (flags & 0x04) == 0x04    // RST
 
-	if synAck || rst {
-		return result, true
+	switch {
+	case rst:
+		result.ResponseKind = ResponseRST
+	case synAck:
+		result.ResponseKind = ResponseSynAck
+	default:
+		return nil, false
 	}
-
-	return nil, false
+	return result, true
 }
 
 // Name returns the probe method name.
@@ -430,31 +515,40 @@ func (p *TCPProber) Name() string {
 	return "tcp"
 }
 
+// SocketKind returns the kind of socket responses are received on.
+func (p *TCPProber) SocketKind() string {
+	return SocketRaw
+}
+
 // RequiresRoot returns true as TCP raw sockets require elevated privileges.
 func (p *TCPProber) RequiresRoot() bool {
 	return true
 }
 
-// Close releases resources held by the prober.
+// Close releases resources held by the prober. Probes waiting for a
+// reply return ErrSocketClosed, as do later probes; closing again is a
+// no-op.
 func (p *TCPProber) Close() error {
-	var errs []error
+	return p.close(func() error {
+		var errs []error
 
-	if p.icmpConn != nil {
-		if err := p.icmpConn.Close(); err != nil {
-			errs = append(errs, err)
+		if p.icmpConn != nil {
+			if err := p.icmpConn.Close(); err != nil {
+				errs = append(errs, err)
+			}
 		}
-	}
 
-	if p.rawConn != nil {
-		if err := p.rawConn.Close(); err != nil {
-			errs = append(errs, err)
+		if p.rawConn != nil {
+			if err := p.rawConn.Close(); err != nil {
+				errs = append(errs, err)
+			}
 		}
-	}
 
-	if len(errs) > 0 {
-		return errs[0]
-	}
-	return nil
+		if len(errs) > 0 {
+			return errs[0]
+		}
+		return nil
+	})
 }
 
 // getOutboundIP gets the preferred outbound IP address.
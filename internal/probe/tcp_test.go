@@ -2,7 +2,9 @@ package probe
 
 import (
 	"context"
+	"encoding/binary"
 	"net"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -167,3 +169,23 @@ func canCreateRawSocketTCP() bool {
 	conn.Close()
 	return true
 }
+
+func TestTCPProber_SeqStart(t *testing.T) {
+	if !canCreateRawSocketTCP() {
+		t.Skip("Skipping: requires elevated privileges")
+	}
+
+	config := DefaultTCPProberConfig()
+	config.SeqStart = 100
+	prober, err := NewTCPProber(config)
+	if err != nil {
+		t.Fatalf("NewTCPProber() error = %v", err)
+	}
+	defer prober.Close()
+
+	seq := atomic.AddUint32(&prober.sequence, 1)
+	packet := prober.buildSYNPacket(net.ParseIP("192.168.1.1"), net.ParseIP("8.8.8.8"), 12345, 80, seq)
+	if got := binary.BigEndian.Uint32(packet[4:8]); got != 100 {
+		t.Errorf("first SYN sequence = %d, want 100", got)
+	}
+}
@@ -26,6 +26,17 @@ type UDPProberConfig struct {
 
 	// PayloadSize is the size of the UDP payload in bytes
 	PayloadSize int
+
+	// SeqStart is the sequence number of the first probe (0 = 1)
+	SeqStart uint16
+
+	// Via loose source routes probes through these IPv4 routers, in
+	// order (IPv4 only, at most MaxViaHops)
+	Via []net.IP
+
+	// Zone is the IPv6 zone (interface name) of link-local destinations,
+	// e.g. "eth0" for fe80::1%eth0
+	Zone string
 }
 
 // DefaultUDPProberConfig returns a default UDP prober configuration.
@@ -42,13 +53,27 @@ func DefaultUDPProberConfig() UDPProberConfig {
 // It sends UDP packets to high-numbered ports and listens for
 // ICMP responses (Time Exceeded or Destination Unreachable).
 type UDPProber struct {
+	Counters
+	RedirectLog
+	closeState
+
 	config   UDPProberConfig
 	icmpConn *icmp.PacketConn
 	udpConn  *net.UDPConn
 	sequence uint32
 	id       uint16
+
+	// replies tracks answered port offsets to spot duplicates
+	replies replyTracker
+
+	// pending counts probes awaiting a reply, for weak matches
+	pending pendingProbes
 }
 
+// udpPortSpan is the number of destination ports probes cycle through,
+// starting at BasePort.
+const udpPortSpan = 100
+
 // NewUDPProber creates a new UDP prober.
 func NewUDPProber(config UDPProberConfig) (*UDPProber, error) {
 	if config.Timeout == 0 {
@@ -76,9 +101,20 @@ func NewUDPProber(config UDPProberConfig) (*UDPProber, error) {
 
 	// Create UDP socket for sending probes
 	var udpConn *net.UDPConn
-	if config.IPv6 {
+	switch {
+	case len(config.Via) > 0 && !config.IPv6:
+		var options []byte
+		if options, err = LooseSourceRoute(config.Via); err != nil {
+			icmpConn.Close()
+			return nil, err
+		}
+		var conn net.PacketConn
+		if conn, err = listenWithIPOptions("udp4", ":0", options); err == nil {
+			udpConn = conn.(*net.UDPConn)
+		}
+	case config.IPv6:
 		udpConn, err = net.ListenUDP("udp6", nil)
-	} else {
+	default:
 		udpConn, err = net.ListenUDP("udp4", nil)
 	}
 	if err != nil {
@@ -90,7 +126,7 @@ func NewUDPProber(config UDPProberConfig) (*UDPProber, error) {
 		config:   config,
 		icmpConn: icmpConn,
 		udpConn:  udpConn,
-		sequence: 0,
+		sequence: initialSequence(config.SeqStart),
 		id:       uint16(udpConn.LocalAddr().(*net.UDPAddr).Port),
 	}, nil
 }
@@ -100,15 +136,21 @@ func (p *UDPProber) Probe(ctx context.Context, dest net.IP, ttl int) (*Result, e
 	if ttl < 1 || ttl > 255 {
 		return nil, ErrInvalidTTL
 	}
+	if p.isClosed() {
+		return nil, ErrSocketClosed
+	}
+	done := p.pending.begin()
+	defer done()
 
 	// Set TTL on the UDP socket
 	if err := p.setTTL(ttl); err != nil {
-		return nil, fmt.Errorf("failed to set TTL: %w", err)
+		return nil, p.socketError(&p.Counters, fmt.Errorf("failed to set TTL: %w", err))
 	}
 
 	// Calculate destination port (increment for each probe)
 	seq := atomic.AddUint32(&p.sequence, 1)
-	destPort := p.config.BasePort + int(seq%100)
+	destPort := p.config.BasePort + int(seq%udpPortSpan)
+	p.replies.sent(seq % udpPortSpan)
 
 	// Build UDP payload with identifier
 	payload := p.buildPayload(seq)
@@ -117,12 +159,13 @@ func (p *UDPProber) Probe(ctx context.Context, dest net.IP, ttl int) (*Result, e
 	destAddr := &net.UDPAddr{
 		IP:   dest,
 		Port: destPort,
+		Zone: p.config.Zone,
 	}
 
 	// Set read deadline
 	deadline := time.Now().Add(p.config.Timeout)
 	if err := p.icmpConn.SetReadDeadline(deadline); err != nil {
-		return nil, fmt.Errorf("failed to set deadline: %w", err)
+		return nil, p.socketError(&p.Counters, fmt.Errorf("failed to set deadline: %w", err))
 	}
 
 	// Record send time
@@ -130,11 +173,17 @@ func (p *UDPProber) Probe(ctx context.Context, dest net.IP, ttl int) (*Result, e
 
 	// Send UDP packet
 	if _, err := p.udpConn.WriteToUDP(payload, destAddr); err != nil {
-		return nil, fmt.Errorf("failed to send UDP packet: %w", err)
+		return nil, p.sendError(&p.Counters, fmt.Errorf("failed to send UDP packet: %w", err))
 	}
+	p.CountSent()
 
 	// Wait for ICMP response
-	return p.receiveResponse(ctx, dest, destPort, sendTime, seq)
+	result, err := p.receiveResponse(ctx, dest, destPort, sendTime, seq)
+	if result != nil {
+		result.Seq = seq
+		result.SentAt = sendTime
+	}
+	return result, err
 }
 
 // setTTL sets the TTL on the UDP socket.
@@ -167,8 +216,7 @@ func (p *UDPProber) buildPayload(seq uint32) []byte {
 
 	// Store identifier and sequence in payload for matching responses
 	if len(payload) >= 8 {
-		binary.BigEndian.PutUint16(payload[0:2], p.id)
-		binary.BigEndian.PutUint16(payload[2:4], uint16(seq))
+		copy(payload[0:4], p.token(seq))
 		binary.BigEndian.PutUint32(payload[4:8], uint32(time.Now().UnixNano()))
 	}
 
@@ -188,10 +236,8 @@ func (p *UDPProber) receiveResponse(ctx context.Context, dest net.IP, destPort i
 
 		n, peer, err := p.icmpConn.ReadFrom(buf)
 		if err != nil {
-			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-				return nil, ErrTimeout
-			}
-			return nil, fmt.Errorf("read error: %w", err)
+			p.countReadError(err)
+			return nil, readError(err)
 		}
 
 		rtt := time.Since(sendTime)
@@ -206,12 +252,16 @@ func (p *UDPProber) receiveResponse(ctx context.Context, dest net.IP, destPort i
 
 		msg, err := icmp.ParseMessage(proto, buf[:n])
 		if err != nil {
-			continue // Ignore malformed packets
+			p.CountDiscard(DiscardMalformed)
+			continue
 		}
 
+		p.noteRedirect(msg, parseIP(peer), func(orig []byte) bool {
+			return p.matchOriginalUDP(orig, dest, destPort)
+		})
+
 		// Check if this response is for our probe
-		result, ok := p.matchResponse(msg, dest, destPort, seq)
-		if ok {
+		if result, ok := p.handleReply(msg, dest, destPort, seq); ok {
 			result.RTT = rtt
 			result.ResponseIP = parseIP(peer)
 			return result, nil
@@ -219,9 +269,42 @@ func (p *UDPProber) receiveResponse(ctx context.Context, dest net.IP, destPort i
 	}
 }
 
+// handleReply matches an ICMP message to the probe sent to destPort and
+// counts it. A further reply to an answered probe is a duplicate.
+func (p *UDPProber) handleReply(msg *icmp.Message, dest net.IP, destPort int, seq uint32) (*Result, bool) {
+	result, ok := p.matchResponse(msg, dest, destPort, seq)
+	if ok {
+		p.replies.answer(uint32(destPort - p.config.BasePort))
+		p.CountReceived()
+		return result, true
+	}
+
+	if p.isDuplicate(msg, dest) {
+		p.CountDuplicate()
+	} else {
+		p.CountDiscard(discardReason(msg))
+	}
+	return nil, false
+}
+
+// isDuplicate reports whether msg quotes one of our probes to dest that
+// was already answered.
+func (p *UDPProber) isDuplicate(msg *icmp.Message, dest net.IP) bool {
+	orig, header := quotedTransport(msg)
+	if header == nil {
+		return false
+	}
+	port := int(binary.BigEndian.Uint16(header[2:4]))
+	offset := port - p.config.BasePort
+	if offset < 0 || offset >= udpPortSpan || !p.matchOriginalUDP(orig, dest, port) {
+		return false
+	}
+	return p.replies.isAnswered(uint32(offset))
+}
+
 // matchResponse checks if an ICMP message is a response to our UDP probe.
 func (p *UDPProber) matchResponse(msg *icmp.Message, dest net.IP, destPort int, seq uint32) (*Result, bool) {
-	result := &Result{}
+	result := &Result{Interface: interfaceFromMessage(msg)}
 
 	if p.config.IPv6 {
 		return p.matchResponseIPv6(msg, dest, destPort, seq, result)
@@ -238,17 +321,29 @@ func (p *UDPProber) matchResponseIPv4(msg *icmp.Message, dest net.IP, destPort i
 	case ipv4.ICMPTypeTimeExceeded:
 		// TTL expired - intermediate hop
 		if body, ok := msg.Body.(*icmp.TimeExceeded); ok {
-			if p.matchOriginalUDP(body.Data, dest, destPort) {
+			if p.matchQuote(body.Data, dest, destPort, seq, result) {
 				result.TTLExpired = true
+				result.ResponseKind = ResponseTTLExceeded
 				return result, true
 			}
 		}
 
-	case ipv4.ICMPTypeDestinationUnreachable:
+	case ipv4.ICMPTypeDestinationUnreachable, ipv4.ICMPTypeParameterProblem:
+		// A router refused the source route
+		if data, ok := sourceRouteRejection(msg); ok {
+			if p.matchQuote(data, dest, destPort, seq, result) {
+				result.SourceRouteRejected = true
+				result.ResponseKind = ResponseSourceRouteRejected
+				return result, true
+			}
+			break
+		}
+
 		// Destination reached (port unreachable)
 		if body, ok := msg.Body.(*icmp.DstUnreach); ok {
-			if p.matchOriginalUDP(body.Data, dest, destPort) {
+			if p.matchQuote(body.Data, dest, destPort, seq, result) {
 				result.Reached = true
+				result.ResponseKind = ResponseUnreachable
 				return result, true
 			}
 		}
@@ -266,8 +361,9 @@ func (p *UDPProber) matchResponseIPv6(msg *icmp.Message, dest net.IP, destPort i
 	case ipv6.ICMPTypeTimeExceeded:
 		// TTL expired - intermediate hop
 		if body, ok := msg.Body.(*icmp.TimeExceeded); ok {
-			if p.matchOriginalUDP(body.Data, dest, destPort) {
+			if p.matchQuote(body.Data, dest, destPort, seq, result) {
 				result.TTLExpired = true
+				result.ResponseKind = ResponseTTLExceeded
 				return result, true
 			}
 		}
@@ -275,8 +371,9 @@ func (p *UDPProber) matchResponseIPv6(msg *icmp.Message, dest net.IP, destPort i
 	case ipv6.ICMPTypeDestinationUnreachable:
 		// Destination reached (port unreachable)
 		if body, ok := msg.Body.(*icmp.DstUnreach); ok {
-			if p.matchOriginalUDP(body.Data, dest, destPort) {
+			if p.matchQuote(body.Data, dest, destPort, seq, result) {
 				result.Reached = true
+				result.ResponseKind = ResponseUnreachable
 				return result, true
 			}
 		}
@@ -285,39 +382,35 @@ func (p *UDPProber) matchResponseIPv6(msg *icmp.Message, dest net.IP, destPort i
 	return nil, false
 }
 
-// matchOriginalUDP checks if the ICMP error contains our original UDP packet.
-func (p *UDPProber) matchOriginalUDP(data []byte, dest net.IP, destPort int) bool {
-	// The ICMP error should contain the original IP header + 8 bytes of UDP
-	// IPv4 header is typically 20 bytes, UDP header is 8 bytes
-
-	if len(data) < 28 { // Minimum: 20 (IP) + 8 (UDP)
-		return false
+// matchQuote grades the datagram quoted by an ICMP error against the
+// probe with seq sent to destPort, records the grade in result and
+// reports whether to accept it.
+func (p *UDPProber) matchQuote(data []byte, dest net.IP, destPort int, seq uint32, result *Result) bool {
+	probe := transportProbe{proto: 17, dstPort: destPort}
+	if p.config.PayloadSize >= 8 {
+		probe.token = p.token(seq)
+		probe.tokenAt = 8
 	}
 
-	// Skip IP header (variable length, check IHL)
-	ihl := int(data[0]&0x0f) * 4
-	if ihl < 20 || len(data) < ihl+8 {
-		return false
-	}
-
-	udpHeader := data[ihl:]
-
-	// Extract source and destination ports from UDP header
-	// srcPort := binary.BigEndian.Uint16(udpHeader[0:2])
-	dstPort := binary.BigEndian.Uint16(udpHeader[2:4])
-
-	// Check if destination port matches
-	if int(dstPort) != destPort {
-		return false
-	}
+	result.MatchQuality = probe.match(data, dest, p.config.Via)
+	result.QuoteLen = len(data)
+	return p.pending.accepts(result.MatchQuality)
+}
 
-	// Check destination IP from IP header
-	destIPInPacket := net.IP(data[16:20])
-	if !destIPInPacket.Equal(dest) {
-		return false
-	}
+// token returns the identifier and sequence number that start the
+// payload of probe seq.
+func (p *UDPProber) token(seq uint32) []byte {
+	token := make([]byte, 4)
+	binary.BigEndian.PutUint16(token[0:2], p.id)
+	binary.BigEndian.PutUint16(token[2:4], uint16(seq))
+	return token
+}
 
-	return true
+// matchOriginalUDP reports whether the datagram quoted by an ICMP error
+// is our probe to destPort, identified at least by its ports.
+func (p *UDPProber) matchOriginalUDP(data []byte, dest net.IP, destPort int) bool {
+	probe := transportProbe{proto: 17, dstPort: destPort}
+	return probe.match(data, dest, p.config.Via) >= MatchPartial
 }
 
 // parseIP extracts net.IP from net.Addr.
@@ -337,29 +430,38 @@ func (p *UDPProber) Name() string {
 	return "udp"
 }
 
+// SocketKind returns the kind of socket responses are received on.
+func (p *UDPProber) SocketKind() string {
+	return SocketRaw
+}
+
 // RequiresRoot returns true as UDP probing requires raw sockets for ICMP.
 func (p *UDPProber) RequiresRoot() bool {
 	return true
 }
 
-// Close releases resources held by the prober.
+// Close releases resources held by the prober. Probes waiting for a
+// reply return ErrSocketClosed, as do later probes; closing again is a
+// no-op.
 func (p *UDPProber) Close() error {
-	var errs []error
+	return p.close(func() error {
+		var errs []error
 
-	if p.icmpConn != nil {
-		if err := p.icmpConn.Close(); err != nil {
-			errs = append(errs, err)
+		if p.icmpConn != nil {
+			if err := p.icmpConn.Close(); err != nil {
+				errs = append(errs, err)
+			}
 		}
-	}
 
-	if p.udpConn != nil {
-		if err := p.udpConn.Close(); err != nil {
-			errs = append(errs, err)
+		if p.udpConn != nil {
+			if err := p.udpConn.Close(); err != nil {
+				errs = append(errs, err)
+			}
 		}
-	}
 
-	if len(errs) > 0 {
-		return errs[0]
-	}
-	return nil
+		if len(errs) > 0 {
+			return errs[0]
+		}
+		return nil
+	})
 }
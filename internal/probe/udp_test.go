@@ -2,9 +2,11 @@ package probe
 
 import (
 	"context"
+	"encoding/binary"
 	"net"
 	"os"
 	"runtime"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -171,3 +173,23 @@ func canCreateRawSocketUDP() bool {
 	}
 	return os.Getuid() == 0
 }
+
+func TestUDPProber_SeqStart(t *testing.T) {
+	if !canCreateRawSocketUDP() {
+		t.Skip("Skipping: requires elevated privileges")
+	}
+
+	config := DefaultUDPProberConfig()
+	config.SeqStart = 100
+	prober, err := NewUDPProber(config)
+	if err != nil {
+		t.Fatalf("NewUDPProber() error = %v", err)
+	}
+	defer prober.Close()
+
+	// The first probe takes the next sequence number, as in Probe
+	payload := prober.buildPayload(atomic.AddUint32(&prober.sequence, 1))
+	if seq := binary.BigEndian.Uint16(payload[2:4]); seq != 100 {
+		t.Errorf("first payload sequence = %d, want 100", seq)
+	}
+}
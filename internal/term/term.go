@@ -0,0 +1,163 @@
+// Package term decides what the terminal poros runs in can show: whether
+// each standard stream is a terminal, how many colors output may use and
+// how large the terminal is. The output formatters, the TUI and the CLI
+// all consult it, so they degrade the same way in CI, tmux or a console
+// without ANSI support.
+package term
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"sync/atomic"
+
+	xterm "github.com/charmbracelet/x/term"
+	"github.com/mattn/go-isatty"
+)
+
+// ColorLevel is the number of colors a stream can show.
+type ColorLevel int
+
+const (
+	ColorNone      ColorLevel = iota // no ANSI colors
+	Color16                          // the basic 16 ANSI colors
+	Color256                         // the xterm 256-color palette
+	ColorTrueColor                   // 24-bit colors
+)
+
+// String returns the level as shown by poros capabilities, e.g. "256".
+func (l ColorLevel) String() string {
+	switch l {
+	case Color16:
+		return "16"
+	case Color256:
+		return "256"
+	case ColorTrueColor:
+		return "truecolor"
+	default:
+		return "none"
+	}
+}
+
+// Enabled reports whether any colors may be used.
+func (l ColorLevel) Enabled() bool {
+	return l > ColorNone
+}
+
+// Stream describes one output stream.
+type Stream struct {
+	TTY    bool       // the stream is a terminal
+	Colors ColorLevel // colors output to the stream may use
+}
+
+// Caps describes the terminal poros runs in.
+type Caps struct {
+	Stdout Stream
+	Stderr Stream
+
+	// Size of the terminal behind stdout, else stderr (0 = unknown)
+	Width  int
+	Height int
+}
+
+// disabled turns colors off everywhere (see DisableColors).
+var disabled atomic.Bool
+
+// DisableColors turns colors off for every stream, whatever the
+// environment says: for --no-color or no_color in the config, and for a
+// console that cannot show ANSI escape sequences.
+func DisableColors() {
+	disabled.Store(true)
+}
+
+// Capabilities returns what stdout and stderr can show and the terminal
+// size. It is measured on each call, so it reflects a resized terminal.
+func Capabilities() Caps {
+	caps := Caps{
+		Stdout: Detect(os.Stdout),
+		Stderr: Detect(os.Stderr),
+	}
+	for _, f := range []*os.File{os.Stdout, os.Stderr} {
+		if caps.Width, caps.Height = Size(f); caps.Width > 0 {
+			break
+		}
+	}
+	return caps
+}
+
+// Detect returns what output written to f can show. A nil f is not a
+// terminal.
+func Detect(f *os.File) Stream {
+	tty := IsTerminal(f)
+	return Stream{
+		TTY:    tty,
+		Colors: resolveColors(disabled.Load(), tty, os.Getenv, runtime.GOOS),
+	}
+}
+
+// IsTerminal reports whether f is a terminal, including Cygwin and MSYS
+// terminals on Windows.
+func IsTerminal(f *os.File) bool {
+	if f == nil {
+		return false
+	}
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+// Size returns the width and height of the terminal behind f, or zeros
+// if it cannot be measured.
+func Size(f *os.File) (width, height int) {
+	if f == nil {
+		return 0, 0
+	}
+	width, height, err := xterm.GetSize(f.Fd())
+	if err != nil || width <= 0 {
+		return 0, 0
+	}
+	return width, height
+}
+
+// resolveColors decides the colors of a stream. In order of precedence:
+//
+//  1. disabled (--no-color, the config, a console without ANSI support)
+//  2. NO_COLOR set to any value (https://no-color.org)
+//  3. CLICOLOR_FORCE set and not "0" colors even pipes and files
+//  4. no colors if the stream is not a terminal, TERM is "dumb" or
+//     CLICOLOR is "0"
+//  5. the level the terminal advertises (see colorLevel)
+func resolveColors(disabled, tty bool, getenv func(string) string, goos string) ColorLevel {
+	if disabled || getenv("NO_COLOR") != "" {
+		return ColorNone
+	}
+	if force := getenv("CLICOLOR_FORCE"); force != "" && force != "0" {
+		return max(colorLevel(getenv, goos), Color16)
+	}
+	if !tty || getenv("TERM") == "dumb" || getenv("CLICOLOR") == "0" {
+		return ColorNone
+	}
+	return colorLevel(getenv, goos)
+}
+
+// colorLevel returns the colors the terminal advertises through
+// COLORTERM and TERM. Windows consoles set neither; they show 24-bit
+// colors once virtual terminal processing is enabled.
+func colorLevel(getenv func(string) string, goos string) ColorLevel {
+	switch strings.ToLower(getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return ColorTrueColor
+	}
+
+	term := getenv("TERM")
+	switch {
+	case strings.Contains(term, "truecolor"), strings.Contains(term, "24bit"), strings.HasSuffix(term, "-direct"):
+		return ColorTrueColor
+	case strings.Contains(term, "256color"):
+		return Color256
+	case term == "dumb":
+		return ColorNone
+	case term == "" && (goos == "windows" || getenv("WT_SESSION") != ""):
+		return ColorTrueColor
+	default:
+		return Color16
+	}
+}
@@ -0,0 +1,88 @@
+package term
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveColors(t *testing.T) {
+	tests := []struct {
+		name     string
+		disabled bool
+		tty      bool
+		env      map[string]string
+		goos     string
+		want     ColorLevel
+	}{
+		{name: "terminal", tty: true, env: map[string]string{"TERM": "xterm"}, want: Color16},
+		{name: "256-color terminal", tty: true, env: map[string]string{"TERM": "screen-256color"}, want: Color256},
+		{name: "COLORTERM truecolor", tty: true, env: map[string]string{"TERM": "xterm-256color", "COLORTERM": "truecolor"}, want: ColorTrueColor},
+		{name: "direct-color TERM", tty: true, env: map[string]string{"TERM": "xterm-direct"}, want: ColorTrueColor},
+		{name: "Windows console", tty: true, goos: "windows", want: ColorTrueColor},
+		{name: "Windows Terminal over SSH", tty: true, env: map[string]string{"WT_SESSION": "1"}, want: ColorTrueColor},
+		{name: "no TERM", tty: true, want: Color16},
+		{name: "pipe", env: map[string]string{"TERM": "xterm-256color"}, want: ColorNone},
+		{name: "dumb terminal", tty: true, env: map[string]string{"TERM": "dumb"}, want: ColorNone},
+		{name: "CLICOLOR=0", tty: true, env: map[string]string{"TERM": "xterm", "CLICOLOR": "0"}, want: ColorNone},
+
+		// CLICOLOR_FORCE beats the terminal checks
+		{name: "CLICOLOR_FORCE on a pipe", env: map[string]string{"TERM": "xterm-256color", "CLICOLOR_FORCE": "1"}, want: Color256},
+		{name: "CLICOLOR_FORCE in a dumb terminal", tty: true, env: map[string]string{"TERM": "dumb", "CLICOLOR_FORCE": "1"}, want: Color16},
+		{name: "CLICOLOR_FORCE beats CLICOLOR=0", env: map[string]string{"CLICOLOR": "0", "CLICOLOR_FORCE": "1"}, want: Color16},
+		{name: "CLICOLOR_FORCE=0 forces nothing", env: map[string]string{"CLICOLOR_FORCE": "0"}, want: ColorNone},
+
+		// NO_COLOR beats CLICOLOR_FORCE
+		{name: "NO_COLOR", tty: true, env: map[string]string{"TERM": "xterm", "NO_COLOR": "1"}, want: ColorNone},
+		{name: "NO_COLOR beats CLICOLOR_FORCE", env: map[string]string{"NO_COLOR": "1", "CLICOLOR_FORCE": "1"}, want: ColorNone},
+		{name: "empty NO_COLOR", tty: true, env: map[string]string{"TERM": "xterm", "NO_COLOR": ""}, want: Color16},
+
+		// --no-color and the config beat everything
+		{name: "disabled", disabled: true, tty: true, env: map[string]string{"TERM": "xterm-256color"}, want: ColorNone},
+		{name: "disabled beats CLICOLOR_FORCE", disabled: true, env: map[string]string{"CLICOLOR_FORCE": "1"}, want: ColorNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(key string) string { return tt.env[key] }
+			goos := tt.goos
+			if goos == "" {
+				goos = "linux"
+			}
+			if got := resolveColors(tt.disabled, tt.tty, getenv, goos); got != tt.want {
+				t.Errorf("resolveColors() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetect(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("CLICOLOR_FORCE", "")
+	if s := Detect(f); s.TTY || s.Colors.Enabled() {
+		t.Errorf("Detect(file) = %+v, want no terminal and no colors", s)
+	}
+	if s := Detect(nil); s.TTY {
+		t.Error("Detect(nil) should not be a terminal")
+	}
+	if w, h := Size(f); w != 0 || h != 0 {
+		t.Errorf("Size(file) = %dx%d, want 0x0", w, h)
+	}
+
+	t.Setenv("CLICOLOR_FORCE", "1")
+	if s := Detect(f); s.TTY || !s.Colors.Enabled() {
+		t.Errorf("Detect(file) with CLICOLOR_FORCE = %+v, want colors", s)
+	}
+
+	DisableColors()
+	defer disabled.Store(false)
+	if s := Detect(f); s.Colors.Enabled() {
+		t.Errorf("Detect(file) after DisableColors = %+v, want no colors", s)
+	}
+}
@@ -0,0 +1,189 @@
+package trace
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"sync"
+)
+
+// NoteAnonymized is added to the notes of a result scrubbed by an
+// Anonymizer, so readers know addresses are missing on purpose.
+const NoteAnonymized = "anonymized: private addresses replaced by placeholders, local host details removed"
+
+// AnonymizeOptions selects optional scrubbing beyond private addresses
+// and local host details.
+type AnonymizeOptions struct {
+	// RoundCoordinates rounds GeoIP coordinates to one decimal (about 11 km)
+	RoundCoordinates bool
+}
+
+// Anonymizer scrubs trace results for public sharing: private, CGNAT,
+// link-local and loopback addresses are replaced by placeholders such as
+// "private-hop-1" and their hostnames dropped, and the local host name,
+// source address and interface are removed.
+//
+// An address gets the same placeholder every time it is seen, so use one
+// Anonymizer per run to keep streamed hops and the final result
+// consistent. It is safe for concurrent use.
+type Anonymizer struct {
+	options AnonymizeOptions
+
+	mu           sync.Mutex
+	placeholders map[string]string
+}
+
+// NewAnonymizer creates an Anonymizer.
+func NewAnonymizer(options AnonymizeOptions) *Anonymizer {
+	return &Anonymizer{
+		options:      options,
+		placeholders: make(map[string]string),
+	}
+}
+
+// isAnonymized reports whether ip is hidden by an Anonymizer.
+func isAnonymized(ip net.IP) bool {
+	return isPrivateOrCGNAT(ip) || ip.IsLoopback()
+}
+
+// placeholder returns the placeholder for ip, numbering addresses in the
+// order they are first seen.
+func (a *Anonymizer) placeholder(ip net.IP) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := ip.String()
+	if p, ok := a.placeholders[key]; ok {
+		return p
+	}
+	p := fmt.Sprintf("private-hop-%d", len(a.placeholders)+1)
+	a.placeholders[key] = p
+	return p
+}
+
+// Target returns target, or its placeholder if it is a private address.
+func (a *Anonymizer) Target(target string) string {
+	if ip := ipLiteral(target); ip != nil && isAnonymized(ip) {
+		return a.placeholder(ip)
+	}
+	return target
+}
+
+// Hop anonymizes hop in place.
+func (a *Anonymizer) Hop(hop *Hop) {
+	if hop.IP != nil && isAnonymized(hop.IP) {
+		hop.Placeholder = a.placeholder(hop.IP)
+		hop.IP = nil
+		hop.Hostname = ""
+		hop.HostnameVerified = nil
+		hop.Interface = nil
+		hop.Geo = nil
+	}
+
+	// Shared details are copied rather than changed in place
+	if hop.Interface != nil && isAnonymized(hop.Interface.IP) {
+		iface := *hop.Interface
+		iface.IP = nil
+		hop.Interface = &iface
+	}
+	if hop.Geo != nil && a.options.RoundCoordinates {
+		geo := *hop.Geo
+		geo.Latitude = math.Round(geo.Latitude*10) / 10
+		geo.Longitude = math.Round(geo.Longitude*10) / 10
+		hop.Geo = &geo
+	}
+}
+
+// Result anonymizes result in place and notes that it was anonymized.
+// Hops already passed to Hop keep their placeholders.
+func (a *Anonymizer) Result(result *TraceResult) {
+	if isAnonymized(result.ResolvedIP) {
+		result.Target = a.placeholder(result.ResolvedIP)
+		result.ResolvedIP = nil
+		result.TargetPTR = ""
+	} else {
+		result.Target = a.Target(result.Target)
+	}
+
+	for i := range result.Hops {
+		a.Hop(&result.Hops[i])
+	}
+
+	via := result.Via[:0:0]
+	for _, ip := range result.Via {
+		if !isAnonymized(ip) {
+			via = append(via, ip)
+		}
+	}
+	result.Via = via
+
+	redirects := result.Redirects[:0:0]
+	for _, r := range result.Redirects {
+		if !isAnonymized(r.Router) && !isAnonymized(r.Gateway) {
+			redirects = append(redirects, r)
+		}
+	}
+	result.Redirects = redirects
+
+	for i := range result.PathChanges {
+		change := &result.PathChanges[i]
+		change.From = a.Target(change.From)
+		change.To = a.Target(change.To)
+	}
+
+	for i, note := range result.Notes {
+		result.Notes[i] = a.note(note)
+	}
+
+	// The application probe connected to the target's address
+	if result.AppProbe != nil {
+		if host, port, err := net.SplitHostPort(result.AppProbe.Address); err == nil {
+			if ip := ipLiteral(host); ip != nil && isAnonymized(ip) {
+				appProbe := *result.AppProbe
+				placeholder := a.placeholder(ip)
+				appProbe.Address = net.JoinHostPort(placeholder, port)
+				appProbe.Error = strings.ReplaceAll(appProbe.Error, host, placeholder)
+				result.AppProbe = &appProbe
+			}
+		}
+	}
+
+	if result.Meta != nil {
+		meta := *result.Meta
+		meta.Hostname = ""
+		meta.SourceIP = nil
+		meta.Interface = ""
+		if isAnonymized(meta.PinnedIP) {
+			meta.PinnedIP = nil
+		}
+		result.Meta = &meta
+	}
+
+	if !result.Anonymized() {
+		result.Notes = append(result.Notes, NoteAnonymized)
+	}
+}
+
+// note replaces the hidden addresses in a result note, such as the
+// routers of a redirect, with their placeholders.
+func (a *Anonymizer) note(note string) string {
+	words := strings.Split(note, " ")
+	for i, word := range words {
+		addr := strings.Trim(word, "(),;:")
+		if ip := ipLiteral(addr); ip != nil && isAnonymized(ip) {
+			words[i] = strings.Replace(word, addr, a.placeholder(ip), 1)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// Anonymized reports whether the result was scrubbed by an Anonymizer.
+func (r *TraceResult) Anonymized() bool {
+	for _, note := range r.Notes {
+		if note == NoteAnonymized {
+			return true
+		}
+	}
+	return false
+}
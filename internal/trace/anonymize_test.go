@@ -0,0 +1,132 @@
+package trace
+
+import (
+	"net"
+	"testing"
+)
+
+func TestAnonymizer_Hop(t *testing.T) {
+	a := NewAnonymizer(AnonymizeOptions{})
+
+	first := Hop{Number: 1, IP: net.ParseIP("192.168.1.1"), Hostname: "router.lan", Responded: true,
+		Interface: &InterfaceInfo{Name: "eth0", IP: net.ParseIP("192.168.1.1")}}
+	cgnat := Hop{Number: 2, IP: net.ParseIP("100.64.0.1"), Responded: true}
+	public := Hop{Number: 3, IP: net.ParseIP("203.0.113.1"), Hostname: "edge.example.net", Responded: true,
+		Interface: &InterfaceInfo{Name: "ge-0/0/1", IP: net.ParseIP("10.1.1.1")}}
+	again := Hop{Number: 4, IP: net.ParseIP("192.168.1.1"), Responded: true}
+
+	for _, hop := range []*Hop{&first, &cgnat, &public, &again} {
+		a.Hop(hop)
+	}
+
+	if first.IP != nil || first.Hostname != "" || first.Interface != nil || first.Placeholder != "private-hop-1" {
+		t.Errorf("private hop = %+v", first)
+	}
+	if cgnat.Placeholder != "private-hop-2" || cgnat.Address() != "private-hop-2" {
+		t.Errorf("CGNAT hop placeholder = %q", cgnat.Placeholder)
+	}
+	if again.Placeholder != "private-hop-1" {
+		t.Errorf("repeated address placeholder = %q, want private-hop-1", again.Placeholder)
+	}
+	if !public.IP.Equal(net.ParseIP("203.0.113.1")) || public.Hostname != "edge.example.net" || public.Placeholder != "" {
+		t.Errorf("public hop changed: %+v", public)
+	}
+	if public.Interface == nil || public.Interface.IP != nil || public.Interface.Name != "ge-0/0/1" {
+		t.Errorf("public hop interface = %+v, want private address removed", public.Interface)
+	}
+}
+
+func TestAnonymizer_Result(t *testing.T) {
+	geo := &GeoInfo{City: "Frankfurt", Latitude: 50.1109, Longitude: 8.6821}
+	result := &TraceResult{
+		Target:     "example.com",
+		ResolvedIP: net.ParseIP("203.0.113.9"),
+		Hops: []Hop{
+			{Number: 1, IP: net.ParseIP("10.0.0.1"), Hostname: "gw.home", Responded: true},
+			{Number: 2, IP: net.ParseIP("203.0.113.9"), Responded: true, Geo: geo},
+		},
+		Via:         []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("198.51.100.1")},
+		Redirects:   []Redirect{{Router: net.ParseIP("10.0.0.1"), Gateway: net.ParseIP("10.0.0.2"), Count: 1}},
+		PathChanges: []PathChange{{Hop: 1, From: "10.0.0.1", To: "198.51.100.7"}},
+		Notes:       []string{redirectNote(Redirect{Router: net.ParseIP("10.0.0.1"), Gateway: net.ParseIP("198.51.100.1"), Count: 2})},
+		Meta: &Meta{
+			Hostname:  "laptop",
+			SourceIP:  net.ParseIP("10.0.0.23"),
+			Interface: "wlan0",
+			Version:   "1.0",
+		},
+	}
+	meta := result.Meta
+
+	a := NewAnonymizer(AnonymizeOptions{RoundCoordinates: true})
+	a.Result(result)
+	a.Result(result)
+
+	if result.Hops[0].Placeholder != "private-hop-1" || result.Hops[0].Hostname != "" {
+		t.Errorf("hop 1 = %+v", result.Hops[0])
+	}
+	if len(result.Via) != 1 || !result.Via[0].Equal(net.ParseIP("198.51.100.1")) {
+		t.Errorf("Via = %v, want only the public router", result.Via)
+	}
+	if len(result.Redirects) != 0 {
+		t.Errorf("Redirects = %v, want private ones removed", result.Redirects)
+	}
+	if c := result.PathChanges[0]; c.From != "private-hop-1" || c.To != "198.51.100.7" {
+		t.Errorf("PathChanges = %+v, want the private router replaced", result.PathChanges)
+	}
+	if result.Meta.Hostname != "" || result.Meta.SourceIP != nil || result.Meta.Interface != "" || result.Meta.Version != "1.0" {
+		t.Errorf("Meta = %+v", result.Meta)
+	}
+	if meta.Hostname != "laptop" {
+		t.Error("Result changed the original Meta in place")
+	}
+
+	got := result.Hops[1].Geo
+	if got.Latitude != 50.1 || got.Longitude != 8.7 || got.City != "Frankfurt" {
+		t.Errorf("Geo = %+v, want coordinates rounded to one decimal", got)
+	}
+	if geo.Latitude != 50.1109 {
+		t.Error("Result changed the shared GeoInfo in place")
+	}
+
+	want := "ICMP redirect from private-hop-1 suggests gateway 198.51.100.1 (2 probes); routing was not changed"
+	if len(result.Notes) != 2 || result.Notes[0] != want {
+		t.Errorf("Notes = %q, want the redirect note as %q", result.Notes, want)
+	}
+	if !result.Anonymized() {
+		t.Errorf("Notes = %v, want an anonymization note", result.Notes)
+	}
+}
+
+func TestAnonymizer_PrivateTarget(t *testing.T) {
+	a := NewAnonymizer(AnonymizeOptions{})
+	if got := a.Target("192.168.7.7"); got != "private-hop-1" {
+		t.Errorf("Target(192.168.7.7) = %q", got)
+	}
+	if got := a.Target("example.com"); got != "example.com" {
+		t.Errorf("Target(example.com) = %q", got)
+	}
+
+	result := &TraceResult{
+		Target:     "nas.lan",
+		ResolvedIP: net.ParseIP("192.168.7.7"),
+		TargetPTR:  "nas.lan",
+		Hops:       []Hop{{Number: 1, IP: net.ParseIP("192.168.7.7"), Responded: true}},
+		AppProbe:   &AppProbe{Address: "192.168.7.7:443", Error: "connect: dial tcp 192.168.7.7:443: i/o timeout"},
+	}
+	appProbe := result.AppProbe
+	a.Result(result)
+	if result.Target != "private-hop-1" || result.ResolvedIP != nil || result.TargetPTR != "" {
+		t.Errorf("target = %q (%v, %q)", result.Target, result.ResolvedIP, result.TargetPTR)
+	}
+	if result.Hops[0].Placeholder != "private-hop-1" {
+		t.Errorf("destination hop placeholder = %q", result.Hops[0].Placeholder)
+	}
+	want := AppProbe{Address: "private-hop-1:443", Error: "connect: dial tcp private-hop-1:443: i/o timeout"}
+	if *result.AppProbe != want {
+		t.Errorf("AppProbe = %+v, want %+v", *result.AppProbe, want)
+	}
+	if appProbe.Address != "192.168.7.7:443" {
+		t.Error("Result changed the original AppProbe in place")
+	}
+}
@@ -0,0 +1,128 @@
+package trace
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// appProbeTLSPort is the destination port on which the application probe
+// also performs a TLS handshake.
+const appProbeTLSPort = 443
+
+// AppProbe is the result of connecting to the destination after a TCP
+// trace reached it (Config.AppProbe). Times are in milliseconds; a
+// failed step leaves its time and those of later steps zero.
+type AppProbe struct {
+	// Address is the destination address and port connected to
+	Address string `json:"address"`
+
+	// ServerName is the TLS server name (SNI) sent, taken from the target
+	// hostname (empty for IP targets or without TLS)
+	ServerName string `json:"server_name,omitempty"`
+
+	// SYNACKMs is the average RTT of the trace's SYN probes answered by
+	// the destination
+	SYNACKMs float64 `json:"synack_ms,omitempty"`
+
+	// ConnectMs is the time a full TCP connect took
+	ConnectMs float64 `json:"connect_ms,omitempty"`
+
+	// TLS is set when a TLS handshake was attempted (port 443)
+	TLS bool `json:"tls,omitempty"`
+
+	// TLSHandshakeMs is the time from the ClientHello to the end of the
+	// handshake, and TLSVersion the version negotiated
+	TLSHandshakeMs float64 `json:"tls_handshake_ms,omitempty"`
+	TLSVersion     string  `json:"tls_version,omitempty"`
+
+	// Error describes the step that failed (empty on success)
+	Error string `json:"error,omitempty"`
+}
+
+// appProbe connects to port on dest, and performs a TLS handshake with
+// serverName as SNI if useTLS is set. Each step is bounded by timeout.
+// Failures are recorded in the result, never returned. The certificate
+// is not verified: only the time the handshake takes is measured.
+func appProbe(ctx context.Context, dest net.IP, port int, serverName string, useTLS bool, source net.IP, timeout time.Duration) *AppProbe {
+	result := &AppProbe{
+		Address: net.JoinHostPort(dest.String(), strconv.Itoa(port)),
+		TLS:     useTLS,
+	}
+
+	dialer := net.Dialer{Timeout: timeout}
+	if source != nil {
+		dialer.LocalAddr = &net.TCPAddr{IP: source}
+	}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", result.Address)
+	if err != nil {
+		result.Error = "connect: " + err.Error()
+		return result
+	}
+	defer conn.Close()
+	result.ConnectMs = sinceMs(start)
+
+	if !useTLS {
+		return result
+	}
+
+	result.ServerName = serverName
+	tlsConn := tls.Client(conn, &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true, // timing only; the certificate is not judged
+	})
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start = time.Now()
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		result.Error = "TLS handshake: " + err.Error()
+		return result
+	}
+	result.TLSHandshakeMs = sinceMs(start)
+	result.TLSVersion = tls.VersionName(tlsConn.ConnectionState().Version)
+	return result
+}
+
+// appProbeServerName returns the TLS server name for target: the
+// hostname without a trailing dot, or "" for an IP address.
+func appProbeServerName(target string) string {
+	if ipLiteral(target) != nil {
+		return ""
+	}
+	return strings.TrimSuffix(target, ".")
+}
+
+// runAppProbe runs the application probe after a trace, or returns a
+// note explaining why it was skipped. The trace result is not changed
+// otherwise, whatever the outcome.
+func (t *Tracer) runAppProbe(ctx context.Context, result *TraceResult) string {
+	if t.config.LastHop > 0 {
+		return "application probe skipped for partial path"
+	}
+	if !result.Completed {
+		return "application probe skipped: destination not reached"
+	}
+
+	port := t.config.DestPort
+	if port == 0 {
+		port = 80
+	}
+	result.AppProbe = appProbe(ctx, result.ResolvedIP, port, appProbeServerName(result.Target),
+		port == appProbeTLSPort, t.config.SourceIP, t.config.Timeout)
+
+	// The trace's SYN probes to the destination measured the handshake's
+	// first round trip already
+	if n := len(result.Hops); n > 0 && result.Hops[n-1].Responded {
+		result.AppProbe.SYNACKMs = result.Hops[n-1].AvgRTT
+	}
+	return ""
+}
+
+// sinceMs returns the time since start in milliseconds.
+func sinceMs(start time.Time) float64 {
+	return float64(time.Since(start).Microseconds()) / 1000
+}
@@ -0,0 +1,227 @@
+package trace
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/probe/probetest"
+)
+
+// listenTCP starts a plain TCP server on loopback that accepts and
+// closes connections, and returns its port.
+func listenTCP(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on loopback: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+// closedPort returns a loopback port nothing listens on.
+func closedPort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on loopback: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	return port
+}
+
+func TestAppProbe_PlainTCP(t *testing.T) {
+	port := listenTCP(t)
+
+	got := appProbe(context.Background(), net.ParseIP("127.0.0.1"), port, "example.com", false, nil, time.Second)
+	if got.Error != "" || got.ConnectMs <= 0 {
+		t.Fatalf("appProbe() = %+v, want a timed connect", got)
+	}
+	if got.TLS || got.TLSHandshakeMs != 0 || got.ServerName != "" {
+		t.Errorf("appProbe() without TLS = %+v, want no TLS fields", got)
+	}
+}
+
+func TestAppProbe_TLS(t *testing.T) {
+	var mu sync.Mutex
+	var sni []string
+	server := httptest.NewUnstartedServer(http.NotFoundHandler())
+	server.TLS = &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			mu.Lock()
+			sni = append(sni, hello.ServerName)
+			mu.Unlock()
+			return nil, nil
+		},
+	}
+	server.Config.ErrorLog = log.New(io.Discard, "", 0) // probes hang up after the handshake
+	server.StartTLS()
+	defer server.Close()
+	port := server.Listener.Addr().(*net.TCPAddr).Port
+
+	// The test server's certificate is for example.com and 127.0.0.1
+	// only; verification is skipped, so any server name works
+	for _, name := range []string{"www.example.org", ""} {
+		got := appProbe(context.Background(), net.ParseIP("127.0.0.1"), port, name, true, nil, time.Second)
+		if got.Error != "" {
+			t.Fatalf("appProbe(%q) error = %s", name, got.Error)
+		}
+		if got.ConnectMs <= 0 || got.TLSHandshakeMs <= 0 || !strings.HasPrefix(got.TLSVersion, "TLS 1.") {
+			t.Errorf("appProbe(%q) = %+v, want connect and handshake times", name, got)
+		}
+		if got.ServerName != name {
+			t.Errorf("ServerName = %q, want %q", got.ServerName, name)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sni) != 2 || sni[0] != "www.example.org" || sni[1] != "" {
+		t.Errorf("server saw SNI %q, want the target hostname, then none for an IP target", sni)
+	}
+}
+
+func TestAppProbe_Failures(t *testing.T) {
+	t.Run("refused", func(t *testing.T) {
+		got := appProbe(context.Background(), net.ParseIP("127.0.0.1"), closedPort(t), "", false, nil, time.Second)
+		if !strings.HasPrefix(got.Error, "connect: ") || got.ConnectMs != 0 {
+			t.Errorf("appProbe() to a closed port = %+v, want a connect error", got)
+		}
+	})
+
+	t.Run("TLS to a plain TCP port", func(t *testing.T) {
+		got := appProbe(context.Background(), net.ParseIP("127.0.0.1"), listenTCP(t), "", true, nil, time.Second)
+		if !strings.HasPrefix(got.Error, "TLS handshake: ") || got.ConnectMs <= 0 || got.TLSHandshakeMs != 0 {
+			t.Errorf("appProbe() = %+v, want a timed connect and a handshake error", got)
+		}
+	})
+
+	t.Run("handshake timeout", func(t *testing.T) {
+		// The server accepts but never answers the ClientHello
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Skipf("cannot listen on loopback: %v", err)
+		}
+		defer ln.Close()
+		go func() {
+			conn, err := ln.Accept()
+			if err == nil {
+				time.Sleep(time.Second)
+				conn.Close()
+			}
+		}()
+
+		start := time.Now()
+		got := appProbe(context.Background(), net.ParseIP("127.0.0.1"), ln.Addr().(*net.TCPAddr).Port, "", true, nil, 100*time.Millisecond)
+		if !strings.HasPrefix(got.Error, "TLS handshake: ") {
+			t.Errorf("appProbe() = %+v, want a handshake error", got)
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("appProbe() took %v with a 100ms timeout", elapsed)
+		}
+	})
+}
+
+func TestAppProbeServerName(t *testing.T) {
+	tests := map[string]string{
+		"example.com":  "example.com",
+		"example.com.": "example.com",
+		"192.0.2.1":    "",
+		"2001:db8::1":  "",
+	}
+	for target, want := range tests {
+		if got := appProbeServerName(target); got != want {
+			t.Errorf("appProbeServerName(%q) = %q, want %q", target, got, want)
+		}
+	}
+}
+
+func TestTracer_AppProbe(t *testing.T) {
+	newTracer := func(t *testing.T, port int, reached bool) *Tracer {
+		t.Helper()
+		config := DefaultConfig()
+		config.ProbeMethod = ProbeTCP
+		config.Sequential = true
+		config.EnableEnrichment = false
+		config.MaxHops = 3
+		config.DestPort = port
+		config.AppProbe = true
+
+		prober := probetest.NewScriptedProber().Hop(1, "192.0.2.1", 1, 1, 1)
+		if reached {
+			prober.Hop(2, "127.0.0.1", 4, 5, 6)
+		}
+		tracer, err := NewWithProber(config, prober)
+		if err != nil {
+			t.Fatalf("NewWithProber() error = %v", err)
+		}
+		t.Cleanup(func() { tracer.Close() })
+		return tracer
+	}
+
+	t.Run("reached", func(t *testing.T) {
+		result, err := newTracer(t, listenTCP(t), true).Trace(context.Background(), "127.0.0.1")
+		if err != nil {
+			t.Fatalf("Trace() error = %v", err)
+		}
+		ap := result.AppProbe
+		if ap == nil || ap.Error != "" || ap.ConnectMs <= 0 || ap.TLS {
+			t.Fatalf("AppProbe = %+v, want a plain TCP connect", ap)
+		}
+		if ap.SYNACKMs != 5 {
+			t.Errorf("SYNACKMs = %v, want the destination hop's average 5", ap.SYNACKMs)
+		}
+	})
+
+	t.Run("connect fails", func(t *testing.T) {
+		result, err := newTracer(t, closedPort(t), true).Trace(context.Background(), "127.0.0.1")
+		if err != nil {
+			t.Fatalf("Trace() error = %v, want the trace to succeed", err)
+		}
+		if !result.Completed || result.AppProbe == nil || result.AppProbe.Error == "" {
+			t.Errorf("Completed = %v, AppProbe = %+v, want a completed trace with the connect error", result.Completed, result.AppProbe)
+		}
+	})
+
+	t.Run("not reached", func(t *testing.T) {
+		result, err := newTracer(t, listenTCP(t), false).Trace(context.Background(), "127.0.0.1")
+		if err != nil {
+			t.Fatalf("Trace() error = %v", err)
+		}
+		if result.AppProbe != nil {
+			t.Errorf("AppProbe = %+v for an unreached destination", result.AppProbe)
+		}
+		if !containsNote(result.Notes, "application probe skipped") {
+			t.Errorf("Notes = %v, want the skipped application probe", result.Notes)
+		}
+	})
+}
+
+// containsNote reports whether a note starts with prefix.
+func containsNote(notes []string, prefix string) bool {
+	for _, note := range notes {
+		if strings.HasPrefix(note, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package trace
+
+import "net"
+
+// ASContribution attributes part of the path latency to one autonomous
+// system: the RTT added between the last hop of the previous AS and the
+// last hop of this one.
+type ASContribution struct {
+	// ASN is the autonomous system number
+	ASN int `json:"asn"`
+
+	// Org is the AS organization name (if available)
+	Org string `json:"org,omitempty"`
+
+	// FirstHop and LastHop are the hop numbers at which the AS was seen
+	FirstHop int `json:"first_hop"`
+	LastHop  int `json:"last_hop"`
+
+	// RTTMs is the average RTT at the AS's last responding hop
+	RTTMs float64 `json:"rtt_ms"`
+
+	// DeltaMs is the latency added by this AS
+	DeltaMs float64 `json:"delta_ms"`
+
+	// Clamped is true when the RTT was lower than at the previous AS
+	// (e.g. ICMP deprioritized on a router) and DeltaMs was clamped to 0
+	Clamped bool `json:"clamped,omitempty"`
+}
+
+// CalculatePerAS attributes latency to each AS traversed, in path order.
+//
+// Hops without ASN data (private addresses, timeouts, failed lookups) are
+// skipped: they neither split an AS into two segments nor contribute on
+// their own, so latency across a gap is attributed to the next AS seen.
+// The first AS is measured from the source, so the deltas add up to the
+// RTT at the last attributed hop. An AS that reappears after another one
+// starts a new segment.
+func CalculatePerAS(hops []Hop) []ASContribution {
+	var contributions []ASContribution
+
+	for _, hop := range hops {
+		if !hop.Responded || hop.ASN == nil || hop.ASN.Number == 0 || hop.AvgRTT <= 0 {
+			continue
+		}
+
+		n := len(contributions)
+		if n > 0 && contributions[n-1].ASN == hop.ASN.Number {
+			contributions[n-1].LastHop = hop.Number
+			contributions[n-1].RTTMs = hop.AvgRTT
+			continue
+		}
+
+		contributions = append(contributions, ASContribution{
+			ASN:      hop.ASN.Number,
+			Org:      hop.ASN.Org,
+			FirstHop: hop.Number,
+			LastHop:  hop.Number,
+			RTTMs:    hop.AvgRTT,
+		})
+	}
+
+	var prevRTT float64
+	for i := range contributions {
+		c := &contributions[i]
+		c.DeltaMs = c.RTTMs - prevRTT
+		if c.DeltaMs < 0 {
+			c.DeltaMs = 0
+			c.Clamped = true
+		}
+		prevRTT = c.RTTMs
+	}
+
+	return contributions
+}
+
+// TransitHop is the last hop before the destination's autonomous system,
+// e.g. the last transit router in front of a CDN or anycast edge.
+type TransitHop struct {
+	// Number is the hop number
+	Number int `json:"hop"`
+
+	// IP is the hop's address
+	IP net.IP `json:"ip"`
+
+	// RTTMs is the hop's average RTT
+	RTTMs float64 `json:"rtt_ms"`
+
+	// ASN and Org identify the hop's autonomous system
+	ASN int    `json:"asn"`
+	Org string `json:"org,omitempty"`
+}
+
+// LastTransitHop returns the last responding hop whose AS differs from
+// the destination's, or nil if the destination was not reached, has no
+// ASN data, or no earlier hop is in another AS.
+//
+// The path is scanned backwards from the destination, so if the
+// destination AS also appears earlier on the path (non-contiguously), the
+// hop just before its final segment is returned. Hops without ASN data
+// are skipped.
+func LastTransitHop(hops []Hop, dest net.IP) *TransitHop {
+	if len(hops) == 0 {
+		return nil
+	}
+	last := hops[len(hops)-1]
+	if !last.IsDestination(dest) || last.ASN == nil || last.ASN.Number == 0 {
+		return nil
+	}
+	destASN := last.ASN.Number
+
+	for i := len(hops) - 2; i >= 0; i-- {
+		hop := hops[i]
+		if !hop.Responded || hop.ASN == nil || hop.ASN.Number == 0 || hop.ASN.Number == destASN {
+			continue
+		}
+		return &TransitHop{
+			Number: hop.Number,
+			IP:     hop.IP,
+			RTTMs:  hop.AvgRTT,
+			ASN:    hop.ASN.Number,
+			Org:    hop.ASN.Org,
+		}
+	}
+	return nil
+}
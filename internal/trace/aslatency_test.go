@@ -0,0 +1,185 @@
+package trace
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"testing"
+)
+
+// asHop builds a responding hop with ASN data (asn 0 = no ASN).
+func asHop(number, asn int, rtt float64) Hop {
+	hop := Hop{Number: number, AvgRTT: rtt, Responded: true}
+	if asn != 0 {
+		hop.ASN = &ASNInfo{Number: asn, Org: "AS-ORG"}
+	}
+	return hop
+}
+
+func TestCalculatePerAS(t *testing.T) {
+	type want struct {
+		asn     int
+		first   int
+		last    int
+		delta   float64
+		clamped bool
+	}
+
+	tests := []struct {
+		name string
+		hops []Hop
+		want []want
+	}{
+		{
+			name: "no ASN data",
+			hops: []Hop{asHop(1, 0, 1), asHop(2, 0, 5)},
+			want: nil,
+		},
+		{
+			name: "simple path",
+			hops: []Hop{
+				asHop(1, 0, 1),
+				asHop(2, 64500, 8),
+				asHop(3, 64500, 10),
+				asHop(4, 1299, 70),
+				asHop(5, 15169, 75),
+			},
+			want: []want{
+				{64500, 2, 3, 10, false},
+				{1299, 4, 4, 60, false},
+				{15169, 5, 5, 5, false},
+			},
+		},
+		{
+			name: "missing ASN gap",
+			hops: []Hop{
+				asHop(1, 64500, 10),
+				asHop(2, 0, 30),                  // lookup failed
+				{Number: 3, RTTs: []float64{-1}}, // timeout
+				asHop(4, 1299, 50),
+			},
+			want: []want{
+				{64500, 1, 1, 10, false},
+				{1299, 4, 4, 40, false},
+			},
+		},
+		{
+			name: "gap inside one AS",
+			hops: []Hop{
+				asHop(1, 1299, 20),
+				{Number: 2, RTTs: []float64{-1}},
+				asHop(3, 1299, 25),
+			},
+			want: []want{
+				{1299, 1, 3, 25, false},
+			},
+		},
+		{
+			name: "RTT inversion",
+			hops: []Hop{
+				asHop(1, 64500, 10),
+				asHop(2, 1299, 80),
+				asHop(3, 15169, 60),
+			},
+			want: []want{
+				{64500, 1, 1, 10, false},
+				{1299, 2, 2, 70, false},
+				{15169, 3, 3, 0, true},
+			},
+		},
+		{
+			name: "AS reappears",
+			hops: []Hop{
+				asHop(1, 64500, 5),
+				asHop(2, 1299, 20),
+				asHop(3, 64500, 22),
+			},
+			want: []want{
+				{64500, 1, 1, 5, false},
+				{1299, 2, 2, 15, false},
+				{64500, 3, 3, 2, false},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CalculatePerAS(tt.hops)
+			if len(got) != len(tt.want) {
+				t.Fatalf("len(PerAS) = %d, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i, w := range tt.want {
+				c := got[i]
+				if c.ASN != w.asn || c.FirstHop != w.first || c.LastHop != w.last {
+					t.Errorf("[%d] = AS%d hops %d-%d, want AS%d hops %d-%d",
+						i, c.ASN, c.FirstHop, c.LastHop, w.asn, w.first, w.last)
+				}
+				if math.Abs(c.DeltaMs-w.delta) > 0.001 {
+					t.Errorf("[%d] DeltaMs = %.3f, want %.3f", i, c.DeltaMs, w.delta)
+				}
+				if c.Clamped != w.clamped {
+					t.Errorf("[%d] Clamped = %v, want %v", i, c.Clamped, w.clamped)
+				}
+			}
+		})
+	}
+}
+
+func TestLastTransitHop(t *testing.T) {
+	dest := net.ParseIP("203.0.113.50")
+
+	// path builds hops numbered from 1 with the given ASNs (0 = no ASN
+	// data, -1 = timeout); the last hop is the destination.
+	path := func(asns ...int) []Hop {
+		hops := make([]Hop, len(asns))
+		for i, asn := range asns {
+			if asn < 0 {
+				hops[i] = Hop{Number: i + 1, RTTs: []float64{-1}}
+				continue
+			}
+			hops[i] = asHop(i+1, asn, float64(10*(i+1)))
+			hops[i].IP = net.ParseIP(fmt.Sprintf("192.0.2.%d", i+1))
+		}
+		if n := len(hops); n > 0 && asns[n-1] >= 0 {
+			hops[n-1].IP = dest
+		}
+		return hops
+	}
+
+	tests := []struct {
+		name    string
+		hops    []Hop
+		wantHop int // 0 = nil
+		wantASN int
+	}{
+		{"simple", path(0, 64500, 64500, 64501, 13335, 13335), 4, 64501},
+		{"adjacent to destination", path(64500, 64501, 13335), 2, 64501},
+		{"destination AS non-contiguous", path(13335, 64500, 64501, 13335, 13335), 3, 64501},
+		{"gaps in destination AS", path(64500, 64501, -1, 0, 13335), 2, 64501},
+		{"timeouts before destination", path(64500, -1, -1, 13335), 1, 64500},
+		{"only destination AS", path(13335, 13335, 13335), 0, 0},
+		{"no enrichment", path(0, 0, 0), 0, 0},
+		{"destination without ASN", path(64500, 64501, 0), 0, 0},
+		{"destination not reached", path(64500, 64501, -1), 0, 0},
+		{"empty", nil, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := LastTransitHop(tt.hops, dest)
+			if tt.wantHop == 0 {
+				if got != nil {
+					t.Errorf("LastTransitHop() = %+v, want nil", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatalf("LastTransitHop() = nil, want hop %d", tt.wantHop)
+			}
+			want := tt.hops[tt.wantHop-1]
+			if got.Number != tt.wantHop || got.ASN != tt.wantASN || !got.IP.Equal(want.IP) || got.RTTMs != want.AvgRTT || got.Org != "AS-ORG" {
+				t.Errorf("LastTransitHop() = %+v, want hop %d AS%d %v %.1f ms", got, tt.wantHop, tt.wantASN, want.IP, want.AvgRTT)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package trace
+
+import "sync/atomic"
+
+// packetBudget caps the probe packets one trace may send. It is shared by
+// every goroutine sending probes, so concurrent workers, retransmissions
+// and destination probes all draw from the same count.
+type packetBudget struct {
+	limit     int64 // 0 = unlimited
+	sent      atomic.Int64
+	exhausted atomic.Bool
+}
+
+// newPacketBudget returns a budget of limit packets (0 = unlimited).
+func newPacketBudget(limit int) *packetBudget {
+	return &packetBudget{limit: int64(limit)}
+}
+
+// take reserves a packet and reports whether it may be sent. The first
+// refusal marks the budget exhausted; no more than limit reservations
+// ever succeed, however many goroutines race for them.
+func (b *packetBudget) take() bool {
+	if b == nil || b.limit <= 0 {
+		return true
+	}
+	if b.sent.Add(1) > b.limit {
+		b.exhausted.Store(true)
+		return false
+	}
+	return true
+}
+
+// isExhausted reports whether a probe was refused for lack of budget.
+func (b *packetBudget) isExhausted() bool {
+	return b != nil && b.exhausted.Load()
+}
@@ -0,0 +1,200 @@
+package trace
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/probe"
+)
+
+func TestPacketBudget_Take(t *testing.T) {
+	var unlimited *packetBudget
+	if !unlimited.take() || unlimited.isExhausted() {
+		t.Error("nil budget should allow every packet")
+	}
+	if b := newPacketBudget(0); !b.take() || b.isExhausted() {
+		t.Error("zero budget should allow every packet")
+	}
+
+	// 20 goroutines race for 50 packets
+	b := newPacketBudget(50)
+	var taken atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if b.take() {
+					taken.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := taken.Load(); got != 50 {
+		t.Errorf("took %d packets, want 50", got)
+	}
+	if !b.isExhausted() {
+		t.Error("budget should be exhausted")
+	}
+}
+
+// sentProbes returns the number of probes the prober was asked to send.
+func (p *funcProber) sentProbes() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for _, calls := range p.calls {
+		n += calls
+	}
+	return n
+}
+
+func TestTracer_MaxPackets(t *testing.T) {
+	dest := net.ParseIP("192.0.2.9")
+
+	// Routers answer every probe; the destination is at hop 10
+	reply := func(ttl, call int) (*probe.Result, error) {
+		ip := net.IPv4(10, 0, 0, byte(ttl))
+		if ttl == 10 {
+			ip = dest
+		}
+		return &probe.Result{ResponseIP: ip, RTT: time.Millisecond}, nil
+	}
+
+	tests := []struct {
+		name       string
+		maxPackets int
+		verifyDest int
+		sequential bool
+		parallel   bool
+		fn         func(ttl, call int) (*probe.Result, error)
+
+		wantSent    int
+		wantHops    int // 0 = not checked
+		wantStopped string
+	}{
+		{
+			name:       "unlimited",
+			sequential: true,
+			fn:         reply,
+			wantSent:   30,
+			wantHops:   10,
+		},
+		{
+			name:       "large enough",
+			maxPackets: 30,
+			sequential: true,
+			fn:         reply,
+			wantSent:   30,
+			wantHops:   10,
+		},
+		{
+			name:        "sequential",
+			maxPackets:  7,
+			sequential:  true,
+			fn:          reply,
+			wantSent:    7,
+			wantHops:    4,
+			wantStopped: StopPacketBudget,
+		},
+		{
+			name:        "parallel queries",
+			maxPackets:  7,
+			sequential:  true,
+			parallel:    true,
+			fn:          reply,
+			wantSent:    7,
+			wantHops:    4,
+			wantStopped: StopPacketBudget,
+		},
+		{
+			name:       "retransmissions",
+			maxPackets: 5,
+			sequential: true,
+			fn: func(ttl, call int) (*probe.Result, error) {
+				return nil, probe.ErrTimeout
+			},
+			wantSent:    5,
+			wantHops:    3,
+			wantStopped: StopPacketBudget,
+		},
+		{
+			name:        "destination verification",
+			maxPackets:  33,
+			verifyDest:  5,
+			sequential:  true,
+			fn:          reply,
+			wantSent:    33,
+			wantHops:    10,
+			wantStopped: StopPacketBudget,
+		},
+		{
+			name:       "concurrent",
+			maxPackets: 10,
+			fn: func(ttl, call int) (*probe.Result, error) {
+				return &probe.Result{ResponseIP: net.IPv4(10, 0, 0, byte(ttl)), RTT: time.Millisecond}, nil
+			},
+			wantSent:    10,
+			wantStopped: StopPacketBudget,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prober := newFuncProber(tt.fn)
+
+			config := DefaultConfig()
+			config.ProbeMethod = ProbeUDP
+			config.Timeout = 100 * time.Millisecond
+			config.Sequential = tt.sequential
+			config.ParallelQueries = tt.parallel
+			config.MaxConcurrency = 8
+			config.MaxPackets = tt.maxPackets
+			config.VerifyDest = tt.verifyDest
+			config.EnableEnrichment = false
+			tracer := &Tracer{config: config, prober: prober}
+
+			result, err := tracer.Trace(context.Background(), dest.String())
+			if err != nil {
+				t.Fatalf("Trace() error = %v", err)
+			}
+
+			if got := prober.sentProbes(); got != tt.wantSent {
+				t.Errorf("sent %d probes, want %d", got, tt.wantSent)
+			}
+			if tt.wantHops > 0 && len(result.Hops) != tt.wantHops {
+				t.Errorf("got %d hops, want %d", len(result.Hops), tt.wantHops)
+			}
+			if result.StoppedReason != tt.wantStopped {
+				t.Errorf("StoppedReason = %q, want %q", result.StoppedReason, tt.wantStopped)
+			}
+
+			// Every sent probe is accounted for as a sample, and only the
+			// hops without samples are unprobed
+			samples := 0
+			for i, hop := range result.Hops {
+				if hop.Number != config.FirstHop+i {
+					t.Errorf("hop %d is numbered %d", i+1, hop.Number)
+				}
+				samples += len(hop.RTTs)
+				if hop.Unprobed != (len(hop.RTTs) == 0) {
+					t.Errorf("hop %d: Unprobed = %v with %d samples", hop.Number, hop.Unprobed, len(hop.RTTs))
+				}
+			}
+			samples += result.Summary.DestinationProbes
+			if samples != tt.wantSent {
+				t.Errorf("result holds %d samples, want %d", samples, tt.wantSent)
+			}
+			if tt.wantStopped != "" && !result.Completed && !result.Hops[len(result.Hops)-1].Unprobed {
+				t.Error("last hop should be unprobed")
+			}
+		})
+	}
+}
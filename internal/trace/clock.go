@@ -0,0 +1,79 @@
+package trace
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/probe"
+)
+
+// clockStepThreshold is how far the wall clock may move against the
+// monotonic clock while a probe is outstanding before the probe's sample
+// is discarded. Ordinary NTP slewing stays far below it.
+const clockStepThreshold = 500 * time.Millisecond
+
+// clockReading is a simultaneous reading of the wall and monotonic clocks.
+type clockReading struct {
+	wall time.Time     // wall-clock time, without a monotonic reading
+	mono time.Duration // monotonic time since a fixed origin
+}
+
+// monoOrigin is the origin of monotonic clock readings.
+var monoOrigin = time.Now()
+
+// systemClock reads the system clocks.
+func systemClock() clockReading {
+	now := time.Now()
+	return clockReading{wall: now.Round(0), mono: now.Sub(monoOrigin)}
+}
+
+// stepSince returns how far the wall clock moved against the monotonic
+// clock between earlier and r.
+func (r clockReading) stepSince(earlier clockReading) time.Duration {
+	step := r.wall.Sub(earlier.wall) - (r.mono - earlier.mono)
+	if step < 0 {
+		step = -step
+	}
+	return step
+}
+
+// readClock reads the tracer's clock, which tests replace to simulate
+// clock steps.
+func (t *Tracer) readClock() clockReading {
+	if t.clock != nil {
+		return t.clock()
+	}
+	return systemClock()
+}
+
+// sendRetryDelay is how long a probe that failed to send with a transient
+// error waits before it is sent once more.
+const sendRetryDelay = 50 * time.Millisecond
+
+// sendProbe sends a single probe, or fails with ErrPacketBudget once the
+// trace's packet budget is used up. RTTs come from the prober's monotonic
+// send time and survive a wall-clock step, but a sample taken across one
+// is discarded with ErrClockStep so that no sample mixes timings from
+// both sides of the step.
+func (t *Tracer) sendProbe(ctx context.Context, dest net.IP, ttl int) (*probe.Result, error) {
+	if !t.budget.take() {
+		return nil, ErrPacketBudget
+	}
+	before := t.readClock()
+	result, err := t.prober.Probe(ctx, dest, ttl)
+	if se := probe.AsSendError(err); se != nil && se.Transient() {
+		// Nothing was sent, so the retry needs no budget
+		select {
+		case <-time.After(sendRetryDelay):
+			result, err = t.prober.Probe(ctx, dest, ttl)
+		case <-ctx.Done():
+		}
+	}
+	t.probeCompleted()
+	if t.readClock().stepSince(before) > clockStepThreshold {
+		t.clockSteps.Add(1)
+		return nil, ErrClockStep
+	}
+	return result, err
+}
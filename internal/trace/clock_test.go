@@ -0,0 +1,115 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/probe"
+)
+
+// fakeClock advances both clocks by a millisecond per reading; step moves
+// the wall clock alone, as an NTP correction would.
+type fakeClock struct {
+	mu   sync.Mutex
+	wall time.Time
+	mono time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{wall: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) read() clockReading {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.wall = c.wall.Add(time.Millisecond)
+	c.mono += time.Millisecond
+	return clockReading{wall: c.wall, mono: c.mono}
+}
+
+func (c *fakeClock) step(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.wall = c.wall.Add(d)
+}
+
+func TestClockReading_StepSince(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	before := clockReading{wall: start, mono: time.Second}
+
+	tests := []struct {
+		name  string
+		after clockReading
+		want  time.Duration
+	}{
+		{"steady", clockReading{wall: start.Add(20 * time.Millisecond), mono: time.Second + 20*time.Millisecond}, 0},
+		{"forward", clockReading{wall: start.Add(time.Hour), mono: time.Second + 20*time.Millisecond}, time.Hour - 20*time.Millisecond},
+		{"backward", clockReading{wall: start.Add(-2 * time.Second), mono: time.Second}, 2 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := tt.after.stepSince(before); got != tt.want {
+			t.Errorf("%s: stepSince = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// The system clock does not step between two immediate readings
+	if step := systemClock().stepSince(systemClock()); step > clockStepThreshold {
+		t.Errorf("system clock stepped by %v between readings", step)
+	}
+}
+
+func TestTracer_ClockStep(t *testing.T) {
+	dest := net.ParseIP("192.0.2.9")
+
+	for _, parallel := range []bool{false, true} {
+		clock := newFakeClock()
+
+		// The wall clock jumps back an hour while the second probe of
+		// hop 2 is outstanding
+		prober := newFuncProber(func(ttl, call int) (*probe.Result, error) {
+			if ttl == 2 && call == 2 {
+				clock.step(-time.Hour)
+			}
+			ip := net.ParseIP("10.0.0.1")
+			if ttl == 2 {
+				ip = dest
+			}
+			return &probe.Result{ResponseIP: ip, RTT: 5 * time.Millisecond}, nil
+		})
+
+		config := DefaultConfig()
+		config.ProbeMethod = ProbeUDP
+		config.Sequential = true
+		config.ParallelQueries = parallel
+		config.EnableEnrichment = false
+		tracer := &Tracer{config: config, prober: prober, clock: clock.read}
+
+		result, err := tracer.Trace(context.Background(), dest.String())
+		if err != nil {
+			t.Fatalf("parallel=%v: Trace() error = %v", parallel, err)
+		}
+
+		if got := len(result.Hops[0].RTTs); got != 3 {
+			t.Errorf("parallel=%v: hop 1 has %d samples, want 3", parallel, got)
+		}
+		// Parallel probes of hop 2 outstanding at the step are dropped too
+		want := 2
+		hop := result.Hops[1]
+		if parallel && len(hop.RTTs) < want {
+			want = len(hop.RTTs)
+		}
+		if len(hop.RTTs) != want || hop.LossPercent != 0 || (want > 0 && hop.AvgRTT != 5) {
+			t.Errorf("parallel=%v: hop 2 RTTs %v loss %.1f, want the step sample dropped without loss",
+				parallel, hop.RTTs, hop.LossPercent)
+		}
+		note := fmt.Sprintf("clock step detected: %d samples discarded", 3-want)
+		if !strings.Contains(strings.Join(result.Notes, "\n"), note) {
+			t.Errorf("parallel=%v: notes = %q, want %q", parallel, result.Notes, note)
+		}
+	}
+}
@@ -21,14 +21,7 @@ func (t *Tracer) traceConcurrent(ctx context.Context, dest net.IP) ([]Hop, error
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	// Calculate concurrency limit
-	concurrency := t.config.MaxConcurrency
-	if concurrency <= 0 {
-		concurrency = 30
-	}
-	if concurrency > t.config.MaxHops {
-		concurrency = t.config.MaxHops
-	}
+	concurrency := t.effectiveConcurrency()
 
 	// Create channels
 	jobs := make(chan int, t.config.MaxHops)
@@ -40,13 +33,15 @@ func (t *Tracer) traceConcurrent(ctx context.Context, dest net.IP) ([]Hop, error
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
+			t.goroutines.Add(1)
+			defer t.goroutines.Add(-1)
 			t.worker(ctx, dest, jobs, results)
 		}()
 	}
 
 	// Submit jobs for all TTLs
 	go func() {
-		for ttl := t.config.FirstHop; ttl <= t.config.MaxHops; ttl++ {
+		for ttl := t.config.FirstHop; ttl <= t.config.lastTTL(); ttl++ {
 			select {
 			case <-ctx.Done():
 				close(jobs)
@@ -66,18 +61,51 @@ func (t *Tracer) traceConcurrent(ctx context.Context, dest net.IP) ([]Hop, error
 	// Collect results
 	hopMap := make(map[int]Hop)
 	destinationReached := false
-	destinationTTL := t.config.MaxHops + 1
+	destinationTTL := t.config.lastTTL() + 1
+
+	// Hops are streamed in TTL order, as in sequential mode
+	var orderer *hopOrderer
+	if t.config.OnHop != nil {
+		orderer = newHopOrderer(t.config.FirstHop, t.config.lastTTL(), func(hop *Hop) {
+			t.streamHop(ctx, hop)
+			hopMap[hop.Number] = *hop
+		})
+		orderer.holdDest = t.config.destTopUp() > 0
+	}
 
 	for result := range results {
 		hopMap[result.ttl] = result.hop
 
 		// Check if we reached the destination
-		if result.hop.Responded && result.hop.IP != nil && result.hop.IP.Equal(dest) {
+		reached := result.hop.Responded && result.hop.IP != nil && result.hop.IP.Equal(dest)
+		if reached {
 			destinationReached = true
 			if result.ttl < destinationTTL {
 				destinationTTL = result.ttl
 			}
 		}
+		if orderer != nil {
+			orderer.add(result.ttl, result.hop, reached)
+		}
+	}
+
+	// Only the lowest TTL the destination answered at is kept, so its
+	// extra probes wait until every hop is in
+	if destinationReached {
+		hop := hopMap[destinationTTL]
+		t.topUpDestination(ctx, dest, &hop)
+		hopMap[destinationTTL] = hop
+	}
+
+	if t.budget.isExhausted() {
+		t.markUnprobed(hopMap)
+	}
+
+	if t.closed.Load() {
+		return nil, ErrTracerClosed
+	}
+	if orderer != nil {
+		orderer.flush(hopMap)
 	}
 
 	// Build ordered hop list
@@ -86,7 +114,33 @@ func (t *Tracer) traceConcurrent(ctx context.Context, dest net.IP) ([]Hop, error
 	return hops, nil
 }
 
-// worker processes probe jobs from the jobs channel.
+// effectiveConcurrency returns the number of probes kept in flight in
+// concurrent mode. Each worker probes one TTL and sends that hop's probes
+// back to back, so every worker has exactly one probe outstanding and the
+// worker count is the in-flight probe count whatever ProbeCount is. It is
+// clamped to the number of TTLs to probe, since extra workers would idle.
+func (t *Tracer) effectiveConcurrency() int {
+	concurrency := t.config.MaxConcurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	if concurrency > MaxConcurrencyLimit {
+		concurrency = MaxConcurrencyLimit
+	}
+
+	ttls := t.config.lastTTL() - t.config.FirstHop + 1
+	if concurrency > ttls {
+		concurrency = ttls
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return concurrency
+}
+
+// worker processes probe jobs from the jobs channel. Once the packet
+// budget is exhausted it takes no more jobs; probes already in flight on
+// other workers are left to finish.
 func (t *Tracer) worker(ctx context.Context, dest net.IP, jobs <-chan int, results chan<- hopResult) {
 	for ttl := range jobs {
 		select {
@@ -94,12 +148,41 @@ func (t *Tracer) worker(ctx context.Context, dest net.IP, jobs <-chan int, resul
 			return
 		default:
 		}
+		if t.budget.isExhausted() || t.closed.Load() {
+			return
+		}
 
 		hop := t.probeHop(ctx, dest, ttl)
 		results <- hopResult{ttl: ttl, hop: hop}
 	}
 }
 
+// markUnprobed fills in the TTLs the packet budget left unprobed. Workers
+// take TTLs in no fixed order, so gaps below the highest probed TTL are
+// marked, plus the TTL after it to show where the trace stopped.
+func (t *Tracer) markUnprobed(hopMap map[int]Hop) {
+	last := t.config.FirstHop - 1
+	for ttl, hop := range hopMap {
+		if !hop.Unprobed && ttl > last {
+			last = ttl
+		}
+	}
+	if last < t.config.lastTTL() {
+		last++
+	}
+
+	for ttl := t.config.FirstHop; ttl <= last; ttl++ {
+		if _, ok := hopMap[ttl]; !ok {
+			hopMap[ttl] = Hop{Number: ttl, RTTs: []float64{}, Unprobed: true}
+		}
+	}
+	for ttl := range hopMap {
+		if ttl > last {
+			delete(hopMap, ttl)
+		}
+	}
+}
+
 // buildHopList builds an ordered list of hops from the result map.
 func (t *Tracer) buildHopList(hopMap map[int]Hop, destinationReached bool, destinationTTL int) []Hop {
 	// Get sorted TTL values
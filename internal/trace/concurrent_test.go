@@ -3,45 +3,58 @@ package trace
 import (
 	"context"
 	"net"
+	"slices"
+	"strconv"
 	"testing"
 	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/probe/probetest"
 )
 
-func TestTraceConcurrent_Localhost(t *testing.T) {
-	if !canCreateRawSocket() {
-		t.Skip("Skipping: requires elevated privileges")
-	}
+// scriptedPath scripts a five-hop path to 203.0.113.5 with a silent
+// third hop.
+func scriptedPath() *probetest.ScriptedProber {
+	return probetest.NewScriptedProber().
+		Hop(1, "192.168.1.1").
+		Hop(2, "198.51.100.2").
+		TimeoutHop(3).
+		Hop(4, "198.51.100.4").
+		Hop(5, "203.0.113.5")
+}
 
+func TestTraceConcurrent(t *testing.T) {
 	config := DefaultConfig()
-	config.MaxHops = 5
+	config.MaxHops = 8
 	config.ProbeCount = 1
-	config.Timeout = 2 * time.Second
+	config.Timeout = time.Second
 	config.Sequential = false // Concurrent mode
+	config.EnableEnrichment = false
 
-	tracer, err := New(config)
+	prober := scriptedPath()
+	tracer, err := NewWithProber(config, prober)
 	if err != nil {
-		t.Fatalf("New() error = %v", err)
+		t.Fatalf("NewWithProber() error = %v", err)
 	}
 	defer tracer.Close()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
-	result, err := tracer.Trace(ctx, "127.0.0.1")
+	result, err := tracer.Trace(ctx, "203.0.113.5")
 	if err != nil {
 		t.Fatalf("Trace() error = %v", err)
 	}
 
-	if result.Target != "127.0.0.1" {
-		t.Errorf("Target = %q, want %q", result.Target, "127.0.0.1")
+	if result.Target != "203.0.113.5" {
+		t.Errorf("Target = %q, want %q", result.Target, "203.0.113.5")
 	}
 
 	if !result.Completed {
-		t.Error("Trace to localhost should complete")
+		t.Error("Trace should complete")
 	}
 
-	if len(result.Hops) == 0 {
-		t.Error("Trace should have at least one hop")
+	if len(result.Hops) != 5 {
+		t.Errorf("len(Hops) = %d, want 5", len(result.Hops))
 	}
 
 	// Verify hops are in order
@@ -51,6 +64,72 @@ func TestTraceConcurrent_Localhost(t *testing.T) {
 			t.Errorf("Hop[%d].Number = %d, want %d", i, hop.Number, expectedNum)
 		}
 	}
+	if result.Hops[2].Responded {
+		t.Error("Hop 3 should not respond")
+	}
+
+	tracer.Close()
+	if !prober.Closed() {
+		t.Error("Close() should close the prober")
+	}
+}
+
+// TestTraceConcurrent_OnHopOrder completes hops in scrambled order and
+// checks that OnHop still sees them in TTL order, ending at the
+// destination although later TTLs answer first.
+func TestTraceConcurrent_OnHopOrder(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxHops = 10
+	config.MaxConcurrency = 10
+	config.ProbeMethod = ProbeUDP
+	config.ProbeCount = 1
+	config.Timeout = time.Second
+	config.Sequential = false
+	config.EnableEnrichment = false
+
+	const dest = "203.0.113.6"
+	delays := map[int]time.Duration{1: 120, 2: 20, 3: 80, 4: 0, 5: 60, 6: 40}
+	prober := probetest.NewScriptedProber()
+	for ttl, delay := range delays {
+		ip := "198.51.100." + strconv.Itoa(ttl)
+		switch ttl {
+		case 3:
+			ip = "" // times out
+		case 6:
+			ip = dest
+		}
+		prober.Reply(ttl, probetest.AnyAttempt, probetest.Reply{IP: ip, RTT: time.Millisecond, Delay: delay * time.Millisecond})
+	}
+	for ttl := 7; ttl <= config.MaxHops; ttl++ {
+		prober.Reply(ttl, probetest.AnyAttempt, probetest.Reply{IP: dest, RTT: time.Millisecond})
+	}
+
+	var streamed []int
+	config.OnHop = func(hop *Hop) {
+		streamed = append(streamed, hop.Number)
+	}
+
+	tracer, err := NewWithProber(config, prober)
+	if err != nil {
+		t.Fatalf("NewWithProber() error = %v", err)
+	}
+	defer tracer.Close()
+
+	result, err := tracer.Trace(context.Background(), dest)
+	if err != nil {
+		t.Fatalf("Trace() error = %v", err)
+	}
+	if result.Mode != ModeConcurrent {
+		t.Fatalf("Mode = %q, want concurrent", result.Mode)
+	}
+
+	want := []int{1, 2, 3, 4, 5, 6}
+	if !slices.Equal(streamed, want) {
+		t.Errorf("OnHop saw hops %v, want %v", streamed, want)
+	}
+	if len(result.Hops) != len(want) {
+		t.Errorf("len(Hops) = %d, want %d", len(result.Hops), len(want))
+	}
 }
 
 func TestBuildHopList(t *testing.T) {
@@ -139,77 +218,72 @@ func TestBuildHopList(t *testing.T) {
 }
 
 func TestConcurrentVsSequential(t *testing.T) {
-	if !canCreateRawSocket() {
-		t.Skip("Skipping: requires elevated privileges")
-	}
+	target := "203.0.113.5"
 
-	target := "127.0.0.1"
+	run := func(sequential bool) (*TraceResult, time.Duration) {
+		t.Helper()
 
-	// Test sequential
-	seqConfig := DefaultConfig()
-	seqConfig.MaxHops = 3
-	seqConfig.ProbeCount = 1
-	seqConfig.Sequential = true
-
-	seqTracer, err := New(seqConfig)
-	if err != nil {
-		t.Fatalf("New(sequential) error = %v", err)
-	}
+		config := DefaultConfig()
+		config.MaxHops = 8
+		config.ProbeCount = 1
+		config.Timeout = time.Second
+		config.Sequential = sequential
+		config.EnableEnrichment = false
 
-	ctx := context.Background()
-	seqStart := time.Now()
-	seqResult, err := seqTracer.Trace(ctx, target)
-	seqDuration := time.Since(seqStart)
-	seqTracer.Close()
-
-	if err != nil {
-		t.Fatalf("Sequential Trace() error = %v", err)
-	}
-
-	// Test concurrent
-	conConfig := DefaultConfig()
-	conConfig.MaxHops = 3
-	conConfig.ProbeCount = 1
-	conConfig.Sequential = false
+		tracer, err := NewWithProber(config, scriptedPath())
+		if err != nil {
+			t.Fatalf("NewWithProber(sequential=%v) error = %v", sequential, err)
+		}
+		defer tracer.Close()
 
-	conTracer, err := New(conConfig)
-	if err != nil {
-		t.Fatalf("New(concurrent) error = %v", err)
+		start := time.Now()
+		result, err := tracer.Trace(context.Background(), target)
+		if err != nil {
+			t.Fatalf("Trace(sequential=%v) error = %v", sequential, err)
+		}
+		return result, time.Since(start)
 	}
 
-	conStart := time.Now()
-	conResult, err := conTracer.Trace(ctx, target)
-	conDuration := time.Since(conStart)
-	conTracer.Close()
-
-	if err != nil {
-		t.Fatalf("Concurrent Trace() error = %v", err)
-	}
+	seqResult, seqDuration := run(true)
+	conResult, conDuration := run(false)
 
 	// Results should be equivalent
 	if len(seqResult.Hops) != len(conResult.Hops) {
-		t.Errorf("Different hop counts: sequential=%d, concurrent=%d",
+		t.Fatalf("Different hop counts: sequential=%d, concurrent=%d",
 			len(seqResult.Hops), len(conResult.Hops))
 	}
+	for i := range seqResult.Hops {
+		seq, con := seqResult.Hops[i], conResult.Hops[i]
+		if seq.Number != con.Number || !seq.IP.Equal(con.IP) || seq.Responded != con.Responded {
+			t.Errorf("hop %d: sequential = %d %v, concurrent = %d %v",
+				i+1, seq.Number, seq.IP, con.Number, con.IP)
+		}
+	}
+	if seqResult.Completed != conResult.Completed {
+		t.Errorf("Completed: sequential=%v, concurrent=%v", seqResult.Completed, conResult.Completed)
+	}
 
 	t.Logf("Sequential: %d hops in %v", len(seqResult.Hops), seqDuration)
 	t.Logf("Concurrent: %d hops in %v", len(conResult.Hops), conDuration)
 }
 
 func TestConcurrentContextCancellation(t *testing.T) {
-	if !canCreateRawSocket() {
-		t.Skip("Skipping: requires elevated privileges")
-	}
-
 	config := DefaultConfig()
 	config.MaxHops = 30
 	config.ProbeCount = 3
 	config.Timeout = 5 * time.Second
 	config.Sequential = false
+	config.EnableEnrichment = false
 
-	tracer, err := New(config)
+	// Every probe hangs until the trace is cancelled
+	prober := probetest.NewScriptedProber()
+	for ttl := 1; ttl <= config.MaxHops; ttl++ {
+		prober.Reply(ttl, probetest.AnyAttempt, probetest.Reply{Delay: config.Timeout})
+	}
+
+	tracer, err := NewWithProber(config, prober)
 	if err != nil {
-		t.Fatalf("New() error = %v", err)
+		t.Fatalf("NewWithProber() error = %v", err)
 	}
 	defer tracer.Close()
 
@@ -218,7 +292,7 @@ func TestConcurrentContextCancellation(t *testing.T) {
 	defer cancel()
 
 	start := time.Now()
-	_, err = tracer.Trace(ctx, "192.0.2.1") // TEST-NET, won't respond
+	_, _ = tracer.Trace(ctx, "192.0.2.1") // TEST-NET, won't respond
 	duration := time.Since(start)
 
 	// Should complete quickly due to cancellation
@@ -226,5 +300,3 @@ func TestConcurrentContextCancellation(t *testing.T) {
 		t.Errorf("Trace took %v, expected quick cancellation", duration)
 	}
 }
-
-// canCreateRawSocket is defined in tracer_test.go
@@ -1,8 +1,14 @@
 package trace
 
 import (
+	"fmt"
 	"net"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/enrich"
+	"github.com/KilimcininKorOglu/poros/internal/probe"
 )
 
 // ProbeMethod represents the type of probe to use.
@@ -35,6 +41,57 @@ func (p ProbeMethod) String() string {
 	}
 }
 
+// ProbeMethods lists every probe method.
+var ProbeMethods = []ProbeMethod{ProbeICMP, ProbeUDP, ProbeTCP, ProbeParis}
+
+// ParseProbeMethod parses a probe method name as returned by
+// ProbeMethod.String, ignoring case and surrounding spaces.
+func ParseProbeMethod(s string) (ProbeMethod, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	for _, m := range ProbeMethods {
+		if m.String() == name {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("%w %q (want icmp, udp, tcp or paris)", ErrUnknownProbeMethod, s)
+}
+
+// Transport returns the packet type the method sends. Paris traceroute
+// sends UDP probes with a constant flow identifier.
+func (p ProbeMethod) Transport() probe.Method {
+	switch p {
+	case ProbeICMP:
+		return probe.MethodICMP
+	case ProbeTCP:
+		return probe.MethodTCP
+	default:
+		return probe.MethodUDP
+	}
+}
+
+// Concurrency limits for concurrent mode.
+const (
+	// DefaultConcurrency is the default number of probes in flight
+	DefaultConcurrency = 30
+	// MaxConcurrencyLimit is the highest accepted MaxConcurrency
+	MaxConcurrencyLimit = 512
+)
+
+// Ranges of the hop and probe counts. The config file is checked against
+// them too, so both validators agree.
+const (
+	// MaxHopsLimit is the highest accepted MaxHops and FirstHop
+	MaxHopsLimit = 255
+	// MaxProbeCount is the highest accepted ProbeCount
+	MaxProbeCount = 10
+)
+
+// MaxVerifyDest is the highest accepted VerifyDest.
+const MaxVerifyDest = 100
+
+// MaxDestProbeCount is the highest accepted DestProbeCount.
+const MaxDestProbeCount = 100
+
 // Config holds the configuration for a trace operation.
 type Config struct {
 	// Probe settings
@@ -42,6 +99,7 @@ type Config struct {
 	ProbeCount  int           // Number of probes per hop (default: 3)
 	MaxHops     int           // Maximum TTL/hops (default: 30)
 	FirstHop    int           // Starting TTL (default: 1)
+	LastHop     int           // Final TTL for a partial path (0 = trace to the destination)
 	Timeout     time.Duration // Per-probe timeout (default: 3s)
 
 	// Network settings
@@ -51,25 +109,127 @@ type Config struct {
 	IPv4      bool   // Force IPv4
 	IPv6      bool   // Force IPv6
 
+	// Zone is the IPv6 zone (interface name) of a link-local destination,
+	// e.g. "eth0" for fe80::1%eth0 (default: Interface)
+	Zone string
+
+	// Packet identifiers, for matching probes in packet captures
+	ICMPID   int // ICMP Echo identifier (0 = process ID)
+	SeqStart int // Sequence number of the first probe (0 = 1)
+	FlowID   int // Paris flow identifier (0 = random)
+
+	// Tags are free-form key=value labels recorded in the result metadata
+	Tags map[string]string
+
+	// Via loose source routes IPv4 probes through these routers, in order
+	// (ICMP and UDP probes only, at most probe.MaxViaHops)
+	Via []net.IP
+
+	// NAT64Prefix overrides NAT64 prefix discovery (nil = discover via DNS64)
+	NAT64Prefix *net.IPNet
+
+	// DNS resolution
+	ResolvePolicy ResolvePolicy // How often to re-resolve hostnames across traces
+	Resolver      Resolver      // Custom resolver (nil = system resolver)
+	PTRResolver   PTRResolver   // Destination PTR resolver (nil = system resolver)
+
 	// Mode settings
 	Sequential     bool // Use sequential mode instead of concurrent
-	MaxConcurrency int  // Maximum concurrent probes (default: 30)
+	MaxConcurrency int  // Maximum probes in flight (default: 30, max: 512)
 	Paris          bool // Use Paris traceroute algorithm
 
+	// ParallelQueries sends the ProbeCount probes of a hop at once in
+	// sequential mode, so a silent hop costs one timeout instead of
+	// ProbeCount. Not used for ICMP probes (shared socket).
+	ParallelQueries bool
+
+	// SkipPrivatePrefix fast-forwards through leading hops that answer from
+	// private or CGNAT address space (e.g. VPN tunnels), with one probe
+	// each; a silent hop ends it. Forces sequential mode.
+	SkipPrivatePrefix bool
+
+	// RTTOutlier controls how outlying RTT samples enter each hop's
+	// statistics (default: OutlierNone); Hop.RTTs keeps the raw samples
+	RTTOutlier OutlierPolicy
+
 	// Rate limiting
 	PacketsPerSecond int // Rate limit (0 = unlimited)
 
+	// MaxPackets caps the probe packets sent per trace, including
+	// retransmissions and destination probes (0 = unlimited). Hops left
+	// when it runs out are marked unprobed.
+	MaxPackets int
+
+	// DestProbeCount is the number of probes for the hop where the
+	// destination answers, when higher than ProbeCount (0 = ProbeCount,
+	// max 100). The hop is topped up once the destination is detected, so
+	// intermediate hops stay fast while the endpoint gets enough samples
+	// for meaningful loss and jitter.
+	DestProbeCount int
+
+	// VerifyDest sends this many extra probes straight to the destination
+	// after the trace to measure end-host loss (0 = disabled, max 100)
+	VerifyDest int
+
+	// AppProbe connects to the destination port after a TCP trace reaches
+	// the destination, with a TLS handshake on port 443, and records the
+	// times in TraceResult.AppProbe (TCP probes only)
+	AppProbe bool
+
 	// Enrichment settings
 	EnableEnrichment bool // Enable any enrichment
 	EnableRDNS       bool // Enable reverse DNS lookup
 	EnableASN        bool // Enable ASN lookup
 	EnableGeoIP      bool // Enable GeoIP lookup
 
+	// VerifyRDNS forward-confirms hop hostnames, setting
+	// Hop.HostnameVerified
+	VerifyRDNS bool
+
+	// Enrichment lookup timeouts and entries per provider cache
+	// (0 = provider default)
+	RDNSTimeout     time.Duration
+	ASNTimeout      time.Duration
+	GeoIPTimeout    time.Duration
+	EnrichCacheSize int
+
+	// ip-api.com endpoint prefix and pro key ("" = free HTTP endpoint, or
+	// the pro HTTPS endpoint when a key is set)
+	IPAPIURL string
+	IPAPIKey string
+
+	// HTTPClient creates the HTTP clients of online enrichment providers,
+	// for a proxy and the User-Agent header (nil = direct connections or
+	// the environment's proxy)
+	HTTPClient *enrich.HTTPClientFactory
+
+	// Version is recorded in result metadata (set from build info by the CLI)
+	Version string
+
 	// MaxMind database (optional, for offline/faster lookups)
 	MaxMindDB interface{} // *enrich.MaxMindDB - use interface to avoid import cycle
 
-	// Callback for real-time hop updates (streaming output)
-	OnHop func(hop *Hop) // Called after each hop is probed
+	// Callback for real-time hop updates (streaming output). It is called
+	// with each hop once probed, in ascending TTL order in both modes: in
+	// concurrent mode a hop is held until the hops before it complete.
+	OnHop func(hop *Hop)
+
+	// OnProgress is called whenever the trace's Progress changes, from the
+	// probing goroutines; it must return quickly
+	OnProgress func(Progress)
+
+	// Callback for the collapsed private prefix (only with SkipPrivatePrefix)
+	OnSkip func(skipped *SkippedHops) // Called once the private prefix ends
+
+	// OnRound is called by TraceLoop after each round
+	OnRound func(Round)
+
+	// Registry lists the tracer while it is open, for runtime diagnostics
+	// (optional)
+	Registry *Registry
+
+	// Pause holds back probing while paused (nil = never paused)
+	Pause *PauseGate
 }
 
 // DefaultConfig returns a Config with sensible defaults.
@@ -81,7 +241,7 @@ func DefaultConfig() *Config {
 		FirstHop:         1,
 		Timeout:          3 * time.Second,
 		DestPort:         33434, // Standard traceroute UDP port
-		MaxConcurrency:   30,
+		MaxConcurrency:   DefaultConcurrency,
 		EnableEnrichment: true,
 		EnableRDNS:       true,
 		EnableASN:        true,
@@ -91,10 +251,10 @@ func DefaultConfig() *Config {
 
 // Validate checks if the configuration is valid.
 func (c *Config) Validate() error {
-	if c.MaxHops < 1 || c.MaxHops > 255 {
+	if c.MaxHops < 1 || c.MaxHops > MaxHopsLimit {
 		return ErrInvalidMaxHops
 	}
-	if c.ProbeCount < 1 || c.ProbeCount > 10 {
+	if c.ProbeCount < 1 || c.ProbeCount > MaxProbeCount {
 		return ErrInvalidProbeCount
 	}
 	if c.Timeout < 100*time.Millisecond {
@@ -103,5 +263,98 @@ func (c *Config) Validate() error {
 	if c.FirstHop < 1 || c.FirstHop > c.MaxHops {
 		return ErrInvalidFirstHop
 	}
+	if c.LastHop != 0 && (c.LastHop < c.FirstHop || c.LastHop > c.MaxHops) {
+		return ErrInvalidLastHop
+	}
+	if c.DestPort < 0 || c.DestPort > 65535 {
+		return ErrInvalidPort
+	}
+	if c.MaxConcurrency < 0 || c.MaxConcurrency > MaxConcurrencyLimit {
+		return ErrInvalidConcurrency
+	}
+	if c.PacketsPerSecond < 0 {
+		return ErrInvalidRate
+	}
+	if c.MaxPackets < 0 {
+		return ErrInvalidMaxPackets
+	}
+	if c.RDNSTimeout < 0 || c.ASNTimeout < 0 || c.GeoIPTimeout < 0 || c.EnrichCacheSize < 0 {
+		return ErrInvalidEnrichment
+	}
+	if c.IPAPIURL != "" && enrich.ValidateIPAPIURL(c.IPAPIURL) != nil {
+		return ErrInvalidIPAPIURL
+	}
+	for _, id := range []int{c.ICMPID, c.SeqStart, c.FlowID} {
+		if id < 0 || id > 0xffff {
+			return ErrInvalidPacketID
+		}
+	}
+	if c.DestProbeCount < 0 || c.DestProbeCount > MaxDestProbeCount {
+		return ErrInvalidDestProbeCount
+	}
+	if c.VerifyDest < 0 || c.VerifyDest > MaxVerifyDest {
+		return ErrInvalidVerifyDest
+	}
+	if c.AppProbe && c.ProbeMethod != ProbeTCP {
+		return ErrInvalidAppProbe
+	}
+	for key := range c.Tags {
+		if !validTagKey(key) {
+			return ErrInvalidTag
+		}
+	}
+	if c.IPv4 && c.IPv6 {
+		return ErrIPVersionConflict
+	}
+	if len(c.Via) > probe.MaxViaHops {
+		return ErrTooManyVia
+	}
+	if len(c.Via) > 0 {
+		if c.IPv6 || (c.ProbeMethod != ProbeICMP && c.ProbeMethod != ProbeUDP) {
+			return ErrInvalidVia
+		}
+		for _, ip := range c.Via {
+			if ip.To4() == nil {
+				return ErrInvalidVia
+			}
+		}
+	}
+	if c.SourceIP != nil {
+		if len(c.SourceIP) != net.IPv4len && len(c.SourceIP) != net.IPv6len {
+			return ErrInvalidSourceIP
+		}
+		isV4 := c.SourceIP.To4() != nil
+		if (c.IPv4 && !isV4) || (c.IPv6 && isV4) {
+			return ErrSourceIPFamily
+		}
+	}
 	return nil
 }
+
+// ParsePacketID parses a 16-bit packet identifier given in decimal or with
+// a 0x prefix in hex, e.g. "48879" or "0xBEEF".
+func ParsePacketID(s string) (int, error) {
+	v, err := strconv.ParseUint(s, 0, 64)
+	if err != nil || v > 0xffff {
+		return 0, fmt.Errorf("invalid value %q: %w", s, ErrInvalidPacketID)
+	}
+	return int(v), nil
+}
+
+// zone returns the IPv6 zone of link-local destinations: Zone, or the
+// interface probes are sent from.
+func (c *Config) zone() string {
+	if c.Zone != "" {
+		return c.Zone
+	}
+	return c.Interface
+}
+
+// lastTTL returns the highest TTL to probe: LastHop for a partial path,
+// MaxHops otherwise.
+func (c *Config) lastTTL() int {
+	if c.LastHop > 0 {
+		return c.LastHop
+	}
+	return c.MaxHops
+}
@@ -0,0 +1,52 @@
+package trace
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// destTopUp returns the probes sent to the destination hop on top of
+// ProbeCount.
+func (c *Config) destTopUp() int {
+	return max(c.DestProbeCount-c.ProbeCount, 0)
+}
+
+// topUpDestination probes the hop where the destination answered until
+// it has DestProbeCount samples, and merges them into hop.
+func (t *Tracer) topUpDestination(ctx context.Context, dest net.IP, hop *Hop) {
+	if t.config.destTopUp() == 0 {
+		return
+	}
+	if extra := t.config.DestProbeCount - len(hop.RTTs); extra > 0 {
+		hop.addSamples(t.probeHopN(ctx, dest, hop.Number, extra), t.config.RTTOutlier)
+	}
+}
+
+// addSamples appends the probes of more, a further round of probes at
+// the same TTL, and recalculates the statistics under policy. The hop
+// keeps its address.
+func (h *Hop) addSamples(more Hop, policy OutlierPolicy) {
+	if h.SentAt != nil || more.SentAt != nil {
+		h.SentAt = append(sentTimes(h.SentAt, len(h.RTTs)), sentTimes(more.SentAt, len(more.RTTs))...)
+	}
+	h.RTTs = append(h.RTTs, more.RTTs...)
+	h.WeakMatches += more.WeakMatches
+	if more.ResponseKind != "" {
+		h.ResponseKind = more.ResponseKind
+	}
+	h.SendErrors += more.SendErrors
+	if more.SendError != "" {
+		h.SendError = more.SendError
+	}
+	h.UpdateStatsWith(policy)
+}
+
+// sentTimes returns the send times of n probes, zero if they were not
+// recorded.
+func sentTimes(sentAt []time.Time, n int) []time.Time {
+	if len(sentAt) == n {
+		return sentAt
+	}
+	return make([]time.Time, n)
+}
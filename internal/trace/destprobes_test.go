@@ -0,0 +1,151 @@
+package trace
+
+import (
+	"context"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/probe/probetest"
+)
+
+func TestTracer_DestProbeCount(t *testing.T) {
+	modes := []struct {
+		name                 string
+		sequential, parallel bool
+	}{
+		{name: "sequential", sequential: true},
+		{name: "parallel queries", sequential: true, parallel: true},
+		{name: "concurrent"},
+	}
+
+	for _, mode := range modes {
+		t.Run(mode.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.ProbeMethod = ProbeUDP
+			config.Sequential = mode.sequential
+			config.ParallelQueries = mode.parallel
+			config.MaxHops = 6
+			config.ProbeCount = 1
+			config.DestProbeCount = 10
+			config.EnableEnrichment = false
+
+			var streamed []Hop
+			config.OnHop = func(hop *Hop) { streamed = append(streamed, *hop) }
+
+			// Hop 3 is the destination and loses 2 of its 10 probes. Its
+			// first reply is late, so in concurrent mode the destination
+			// answers at hop 4 first.
+			prober := probetest.NewScriptedProber().
+				Hop(1, "192.0.2.1").
+				Hop(2, "192.0.2.2").
+				Hop(3, "8.8.8.8", 5, 5, -1, 5, 5, -1, 5, 5, 5, 5).
+				Reply(3, 0, probetest.Reply{IP: "8.8.8.8", RTT: 5 * time.Millisecond, Delay: 20 * time.Millisecond})
+			for ttl := 4; ttl <= config.MaxHops; ttl++ {
+				prober.Hop(ttl, "8.8.8.8")
+			}
+
+			tracer, err := NewWithProber(config, prober)
+			if err != nil {
+				t.Fatalf("NewWithProber() error = %v", err)
+			}
+			defer tracer.Close()
+
+			result, err := tracer.Trace(context.Background(), "8.8.8.8")
+			if err != nil {
+				t.Fatalf("Trace() error = %v", err)
+			}
+
+			for ttl, want := range map[int]int{1: 1, 2: 1, 3: 10} {
+				if got := prober.Probes(ttl); got != want {
+					t.Errorf("TTL %d probed %d times, want %d", ttl, got, want)
+				}
+			}
+			if got := prober.Probes(4); got > 1 {
+				t.Errorf("TTL 4 probed %d times, want at most 1 (not the destination hop)", got)
+			}
+
+			if len(result.Hops) != 3 {
+				t.Fatalf("got %d hops, want 3", len(result.Hops))
+			}
+			dest := result.Hops[2]
+			if len(dest.RTTs) != 10 || dest.LossPercent != 20 || dest.AvgRTT != 5 {
+				t.Errorf("destination hop has %d samples, %.0f%% loss, avg %.1f ms; want 10, 20%%, 5 ms",
+					len(dest.RTTs), dest.LossPercent, dest.AvgRTT)
+			}
+			if len(dest.SentAt) != len(dest.RTTs) {
+				t.Errorf("destination hop has %d send times for %d samples", len(dest.SentAt), len(dest.RTTs))
+			}
+			if want := 20.0 / 3; math.Abs(result.Summary.PacketLossPercent-want) > 1e-9 {
+				t.Errorf("PacketLossPercent = %.2f, want %.2f (each hop counts once)", result.Summary.PacketLossPercent, want)
+			}
+			if result.Meta == nil || result.Meta.DestProbes != 10 {
+				t.Error("Meta should record the destination hop probe count")
+			}
+
+			// The destination is streamed once, with all its samples
+			var numbers []int
+			for _, hop := range streamed {
+				numbers = append(numbers, hop.Number)
+			}
+			if !slices.Equal(numbers, []int{1, 2, 3}) || len(streamed[2].RTTs) != 10 {
+				t.Errorf("streamed hops %v, destination with %d samples; want [1 2 3] with 10",
+					numbers, len(streamed[len(streamed)-1].RTTs))
+			}
+
+			if p := tracer.Progress(); p.Planned != p.Completed {
+				t.Errorf("progress %d/%d after the trace", p.Completed, p.Planned)
+			}
+		})
+	}
+}
+
+func TestTracer_DestProbeCountNotHigher(t *testing.T) {
+	config := DefaultConfig()
+	config.ProbeMethod = ProbeUDP
+	config.Sequential = true
+	config.ProbeCount = 3
+	config.DestProbeCount = 2
+	config.EnableEnrichment = false
+
+	prober := probetest.NewScriptedProber().Hop(1, "192.0.2.1").Hop(2, "8.8.8.8")
+	tracer, err := NewWithProber(config, prober)
+	if err != nil {
+		t.Fatalf("NewWithProber() error = %v", err)
+	}
+	defer tracer.Close()
+
+	result, err := tracer.Trace(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("Trace() error = %v", err)
+	}
+	if got := prober.Probes(2); got != 3 {
+		t.Errorf("destination probed %d times, want 3 (never fewer than --queries)", got)
+	}
+	if result.Meta.DestProbes != 0 {
+		t.Errorf("Meta.DestProbes = %d, want 0 when unused", result.Meta.DestProbes)
+	}
+}
+
+func TestHop_AddSamples(t *testing.T) {
+	sent := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	hop := Hop{Number: 3, RTTs: []float64{4, -1}, SendErrors: 1, SendError: "ENETUNREACH", ResponseKind: "tcp-synack"}
+	hop.addSamples(Hop{RTTs: []float64{6, 8}, SentAt: []time.Time{sent, sent}, WeakMatches: 1, ResponseKind: "tcp-rst"}, OutlierNone)
+
+	if !slices.Equal(hop.RTTs, []float64{4, -1, 6, 8}) {
+		t.Errorf("RTTs = %v", hop.RTTs)
+	}
+	if len(hop.SentAt) != 4 || !hop.SentAt[0].IsZero() || !hop.SentAt[2].Equal(sent) {
+		t.Errorf("SentAt = %v, want zero times for the first round", hop.SentAt)
+	}
+	if hop.AvgRTT != 6 || hop.LossPercent != 25 || hop.LastRTT != 8 {
+		t.Errorf("avg/loss/last = %.0f/%.0f/%.0f, want 6/25/8", hop.AvgRTT, hop.LossPercent, hop.LastRTT)
+	}
+	if hop.WeakMatches != 1 || hop.SendErrors != 1 || hop.SendError != "ENETUNREACH" {
+		t.Errorf("WeakMatches/SendErrors/SendError = %d/%d/%q", hop.WeakMatches, hop.SendErrors, hop.SendError)
+	}
+	if hop.ResponseKind != "tcp-rst" {
+		t.Errorf("ResponseKind = %q, want the later round's", hop.ResponseKind)
+	}
+}
@@ -0,0 +1,70 @@
+package trace
+
+import "sort"
+
+// HopDiff compares one hop of a trace against the same hop of a baseline
+// trace of the same target.
+type HopDiff struct {
+	// Number is the hop number (TTL)
+	Number int
+
+	// Current and Baseline are the hop in each trace; either is nil when
+	// that trace has no hop with this number
+	Current  *Hop
+	Baseline *Hop
+
+	// IPChanged is set when both hops responded from different addresses
+	IPChanged bool
+
+	// AvgRTTDelta and LossDelta are current minus baseline, set when both
+	// hops responded
+	AvgRTTDelta float64
+	LossDelta   float64
+}
+
+// Compared reports whether both traces have a responding hop here, so the
+// deltas are meaningful.
+func (d HopDiff) Compared() bool {
+	return d.Current != nil && d.Baseline != nil && d.Current.Responded && d.Baseline.Responded
+}
+
+// DiffHops matches the hops of current and baseline by hop number and
+// returns one HopDiff per hop number found in either trace, in order. The
+// traces may have different lengths.
+func DiffHops(baseline, current *TraceResult) []HopDiff {
+	byNumber := make(map[int]*HopDiff)
+	var numbers []int
+	diffFor := func(n int) *HopDiff {
+		d, ok := byNumber[n]
+		if !ok {
+			d = &HopDiff{Number: n}
+			byNumber[n] = d
+			numbers = append(numbers, n)
+		}
+		return d
+	}
+
+	if current != nil {
+		for i := range current.Hops {
+			diffFor(current.Hops[i].Number).Current = &current.Hops[i]
+		}
+	}
+	if baseline != nil {
+		for i := range baseline.Hops {
+			diffFor(baseline.Hops[i].Number).Baseline = &baseline.Hops[i]
+		}
+	}
+
+	sort.Ints(numbers)
+	diffs := make([]HopDiff, 0, len(numbers))
+	for _, n := range numbers {
+		d := byNumber[n]
+		if d.Compared() {
+			d.IPChanged = d.Current.IP != nil && d.Baseline.IP != nil && !d.Current.IP.Equal(d.Baseline.IP)
+			d.AvgRTTDelta = d.Current.AvgRTT - d.Baseline.AvgRTT
+			d.LossDelta = d.Current.LossPercent - d.Baseline.LossPercent
+		}
+		diffs = append(diffs, *d)
+	}
+	return diffs
+}
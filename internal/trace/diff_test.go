@@ -0,0 +1,47 @@
+package trace
+
+import (
+	"math"
+	"net"
+	"testing"
+)
+
+func TestDiffHops(t *testing.T) {
+	baseline := &TraceResult{Hops: []Hop{
+		{Number: 1, IP: net.ParseIP("192.0.2.1"), AvgRTT: 1, Responded: true},
+		{Number: 2, IP: net.ParseIP("192.0.2.2"), AvgRTT: 10, LossPercent: 0, Responded: true},
+		{Number: 3, IP: net.ParseIP("192.0.2.3"), AvgRTT: 20, Responded: true},
+		{Number: 4, IP: net.ParseIP("192.0.2.4"), AvgRTT: 30, Responded: true},
+	}}
+	current := &TraceResult{Hops: []Hop{
+		{Number: 1, IP: net.ParseIP("192.0.2.1"), AvgRTT: 1.5, Responded: true},
+		{Number: 2, IP: net.ParseIP("198.51.100.2"), AvgRTT: 8, LossPercent: 50, Responded: true},
+		{Number: 3, LossPercent: 100},
+	}}
+
+	diffs := DiffHops(baseline, current)
+	if len(diffs) != 4 {
+		t.Fatalf("DiffHops() returned %d hops, want 4", len(diffs))
+	}
+
+	if d := diffs[0]; d.IPChanged || math.Abs(d.AvgRTTDelta-0.5) > 1e-9 {
+		t.Errorf("hop 1 = %+v, want same IP and +0.5 ms", d)
+	}
+	if d := diffs[1]; !d.IPChanged || d.AvgRTTDelta != -2 || d.LossDelta != 50 {
+		t.Errorf("hop 2 = %+v, want changed IP, -2 ms, +50%% loss", d)
+	}
+	if d := diffs[2]; d.Compared() || d.IPChanged || d.AvgRTTDelta != 0 {
+		t.Errorf("hop 3 = %+v, want no comparison for a silent hop", d)
+	}
+	if d := diffs[3]; d.Number != 4 || d.Current != nil || d.Baseline == nil {
+		t.Errorf("hop 4 = %+v, want baseline only", d)
+	}
+
+	// The other way round, hop 4 is only in the current trace
+	if d := DiffHops(current, baseline)[3]; d.Current == nil || d.Baseline != nil {
+		t.Errorf("reversed hop 4 = %+v, want current only", d)
+	}
+	if diffs := DiffHops(nil, current); len(diffs) != 3 || diffs[0].Baseline != nil {
+		t.Errorf("DiffHops(nil, current) = %+v, want current hops only", diffs)
+	}
+}
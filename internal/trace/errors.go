@@ -16,6 +16,83 @@ var (
 	// ErrInvalidFirstHop indicates first hop is invalid
 	ErrInvalidFirstHop = errors.New("first hop must be between 1 and max hops")
 
+	// ErrInvalidLastHop indicates last hop is outside first hop..max hops
+	ErrInvalidLastHop = errors.New("last hop must be between first hop and max hops")
+
+	// ErrUnknownProbeMethod indicates a probe method name that is not
+	// recognized
+	ErrUnknownProbeMethod = errors.New("unknown probe method")
+
+	// ErrUnknownOutlierPolicy indicates an RTT outlier policy name that is
+	// not recognized
+	ErrUnknownOutlierPolicy = errors.New("unknown RTT outlier policy")
+
+	// ErrUnknownResolvePolicy indicates a DNS resolve policy name that is
+	// not recognized
+	ErrUnknownResolvePolicy = errors.New("unknown resolve policy")
+
+	// ErrInvalidPort indicates the destination port is out of valid range
+	ErrInvalidPort = errors.New("destination port must be between 0 and 65535")
+
+	// ErrInvalidPacketID indicates an ICMP identifier, sequence start or
+	// flow ID that does not fit in 16 bits
+	ErrInvalidPacketID = errors.New("ICMP identifier, sequence start and flow ID must be between 0 and 65535")
+
+	// ErrInvalidTag indicates a malformed or duplicate result tag
+	ErrInvalidTag = errors.New("invalid tag")
+
+	// ErrInvalidConcurrency indicates a concurrency limit out of range
+	ErrInvalidConcurrency = errors.New("max concurrency must be between 1 and 512 (0 = default)")
+
+	// ErrInvalidRate indicates a negative packet rate
+	ErrInvalidRate = errors.New("packets per second must be 0 (unlimited) or greater")
+
+	// ErrInvalidMaxPackets indicates a negative packet budget
+	ErrInvalidMaxPackets = errors.New("max packets must be 0 (unlimited) or greater")
+
+	// ErrInvalidEnrichment indicates a negative enrichment timeout or
+	// cache size
+	ErrInvalidEnrichment = errors.New("enrichment timeouts and cache size must be 0 (default) or greater")
+
+	// ErrInvalidIPAPIURL indicates an unusable GeoIP endpoint URL
+	ErrInvalidIPAPIURL = errors.New("GeoIP endpoint URL must be an http or https URL without query")
+
+	// ErrInvalidDestProbeCount indicates an out-of-range probe count for
+	// the destination hop
+	ErrInvalidDestProbeCount = errors.New("destination hop probe count must be between 0 and 100")
+
+	// ErrInvalidVerifyDest indicates an out-of-range destination probe count
+	ErrInvalidVerifyDest = errors.New("destination verification probes must be between 0 and 100")
+
+	// ErrInvalidAppProbe indicates an application probe without TCP probes
+	ErrInvalidAppProbe = errors.New("application probe requires TCP probes")
+
+	// ErrIPVersionConflict indicates IPv4 and IPv6 were both forced
+	ErrIPVersionConflict = errors.New("IPv4 and IPv6 cannot both be forced")
+
+	// ErrSourceIPFamily indicates the source IP does not match the forced
+	// address family
+	ErrSourceIPFamily = errors.New("source IP must match the forced address family")
+
+	// ErrInvalidSourceIP indicates the source IP is not a valid address
+	ErrInvalidSourceIP = errors.New("source IP must be a valid IPv4 or IPv6 address")
+
+	// ErrTooManyVia indicates a loose source route with more than
+	// probe.MaxViaHops routers
+	ErrTooManyVia = errors.New("loose source route allows at most 8 via routers")
+
+	// ErrInvalidVia indicates a loose source route that is not IPv4, or a
+	// probe method that cannot carry it
+	ErrInvalidVia = errors.New("loose source route needs IPv4 via routers and ICMP or UDP probes")
+
+	// ErrInvalidTarget indicates a target that is not a valid host name or
+	// IP address
+	ErrInvalidTarget = errors.New("invalid target")
+
+	// ErrNeedZone indicates a link-local IPv6 target without a zone to
+	// send it from, or with a zone other than Config.Zone
+	ErrNeedZone = errors.New("link-local IPv6 target needs a zone, e.g. fe80::1%eth0")
+
 	// ErrTargetResolution indicates the target could not be resolved
 	ErrTargetResolution = errors.New("could not resolve target hostname")
 
@@ -24,4 +101,16 @@ var (
 
 	// ErrTraceIncomplete indicates the trace did not reach the destination
 	ErrTraceIncomplete = errors.New("trace did not reach destination")
+
+	// ErrClockStep indicates the wall clock stepped while a probe was
+	// outstanding, so its sample was discarded
+	ErrClockStep = errors.New("system clock stepped during probe")
+
+	// ErrPacketBudget indicates a probe was not sent because the trace
+	// used up its Config.MaxPackets budget
+	ErrPacketBudget = errors.New("packet budget exhausted")
+
+	// ErrTracerClosed indicates a trace on a closed Tracer, or one stopped
+	// because the Tracer was closed while it ran
+	ErrTracerClosed = errors.New("tracer closed")
 )
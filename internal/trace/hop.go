@@ -2,8 +2,11 @@
 package trace
 
 import (
+	"fmt"
 	"net"
 	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/probe"
 )
 
 // Hop represents a single hop in the trace path.
@@ -14,19 +17,37 @@ type Hop struct {
 	// IP is the IP address of the responding router/host
 	IP net.IP `json:"ip,omitempty"`
 
+	// Placeholder stands in for IP and Hostname of a responding hop in
+	// an anonymized result, e.g. "private-hop-1"
+	Placeholder string `json:"placeholder,omitempty"`
+
 	// Hostname is the reverse DNS name (if resolved)
 	Hostname string `json:"hostname,omitempty"`
 
+	// HostnameVerified reports whether Hostname resolves back to IP, if
+	// it was checked (Config.VerifyRDNS). A false value flags a spoofed
+	// or stale PTR record; the hostname is kept.
+	HostnameVerified *bool `json:"hostname_verified,omitempty"`
+
 	// ASN contains Autonomous System information
 	ASN *ASNInfo `json:"asn,omitempty"`
 
 	// Geo contains geographic information
 	Geo *GeoInfo `json:"geo,omitempty"`
 
+	// Interface identifies the router interface, if the hop reported it
+	// in an ICMP extension (RFC 5837)
+	Interface *InterfaceInfo `json:"interface,omitempty"`
+
 	// RTTs contains individual round-trip times in milliseconds
 	// A value of -1 indicates a timeout
 	RTTs []float64 `json:"rtts"`
 
+	// SentAt holds the send time of each probe, in the order of RTTs.
+	// It is zero for probes without a reply and nil if no probe was
+	// answered.
+	SentAt []time.Time `json:"sent_at,omitempty"`
+
 	// AvgRTT is the average RTT in milliseconds
 	AvgRTT float64 `json:"avg_rtt"`
 
@@ -39,11 +60,72 @@ type Hop struct {
 	// Jitter is the difference between max and min RTT
 	Jitter float64 `json:"jitter"`
 
+	// LastRTT is the most recent sample in milliseconds
+	// A value of -1 indicates the last probe timed out
+	LastRTT float64 `json:"last_rtt"`
+
 	// LossPercent is the packet loss percentage (0-100)
 	LossPercent float64 `json:"loss_percent"`
 
+	// Sent and Received count the probes sent to the hop and answered
+	// over every round of TraceLoop, where RTTs keeps only the last
+	// DefaultRecentSamples; StdDev is the standard deviation of the
+	// answered RTTs and EWMA their exponentially weighted moving average.
+	// They are zero for a single trace.
+	Sent     int     `json:"sent,omitempty"`
+	Received int     `json:"received,omitempty"`
+	StdDev   float64 `json:"stddev,omitempty"`
+	EWMA     float64 `json:"ewma,omitempty"`
+
+	// Outliers counts the RTT samples Config.RTTOutlier clipped or
+	// dropped from the statistics above; RTTs keeps them as measured
+	Outliers int `json:"outliers,omitempty"`
+
 	// Responded indicates if at least one probe got a response
 	Responded bool `json:"responded"`
+
+	// ResponseKind is the kind of the last reply from the hop, one of
+	// the probe.Response constants (e.g. "icmp-ttl-exceeded", "tcp-rst")
+	ResponseKind string `json:"response_kind,omitempty"`
+
+	// SourceRouteRejected is set when the hop refused the probes' loose
+	// source route instead of forwarding them
+	SourceRouteRejected bool `json:"source_route_rejected,omitempty"`
+
+	// Unprobed is set when no probe was sent to the hop because the
+	// trace's packet budget ran out
+	Unprobed bool `json:"unprobed,omitempty"`
+
+	// SendErrors counts probes that could not be sent, even after a
+	// retry; they are recorded as lost (-1) in RTTs. SendError names the
+	// last send error, e.g. "ENOBUFS".
+	SendErrors int    `json:"send_errors,omitempty"`
+	SendError  string `json:"send_error,omitempty"`
+
+	// WeakMatches counts replies whose ICMP quote was too short to tell
+	// probes apart, matched only by protocol and destination. They may
+	// belong to another probe.
+	WeakMatches int `json:"weak_matches,omitempty"`
+}
+
+// SendFailed reports whether every probe of the hop failed to send.
+func (h *Hop) SendFailed() bool {
+	return h.SendErrors > 0 && h.SendErrors == len(h.RTTs)
+}
+
+// Address returns the responder's address, or its placeholder in an
+// anonymized result ("" if the hop did not respond).
+func (h *Hop) Address() string {
+	if h.IP != nil {
+		return h.IP.String()
+	}
+	return h.Placeholder
+}
+
+// HostnameMismatch reports whether the hop's hostname failed forward
+// confirmation: it does not resolve back to the hop's address.
+func (h *Hop) HostnameMismatch() bool {
+	return h.Hostname != "" && h.HostnameVerified != nil && !*h.HostnameVerified
 }
 
 // ASNInfo contains Autonomous System Number information.
@@ -56,6 +138,47 @@ type ASNInfo struct {
 
 	// Country is the country code (optional)
 	Country string `json:"country,omitempty"`
+
+	// Prefix is the announced prefix covering the hop address (optional)
+	Prefix string `json:"prefix,omitempty"`
+
+	// AltNumbers lists additional origin ASNs for multi-origin prefixes (optional)
+	AltNumbers []int `json:"alt_numbers,omitempty"`
+
+	// Source is the enrichment provider that supplied the data (e.g., "cymru")
+	Source string `json:"source,omitempty"`
+}
+
+// InterfaceInfo identifies the interface a router reported for a probe.
+type InterfaceInfo struct {
+	// Role is the interface's role: incoming, sub-ip, outgoing or next-hop
+	Role string `json:"role"`
+
+	// Index is the ifIndex (if reported)
+	Index int `json:"ifindex,omitempty"`
+
+	// Name is the interface name (if reported)
+	Name string `json:"name,omitempty"`
+
+	// MTU is the interface MTU (if reported)
+	MTU int `json:"mtu,omitempty"`
+
+	// IP is the interface address (if reported)
+	IP net.IP `json:"ip,omitempty"`
+}
+
+// Label returns the interface name, or the ifIndex if no name was given.
+func (i *InterfaceInfo) Label() string {
+	if i.Name != "" {
+		return i.Name
+	}
+	if i.Index > 0 {
+		return fmt.Sprintf("ifIndex %d", i.Index)
+	}
+	if i.IP != nil {
+		return i.IP.String()
+	}
+	return ""
 }
 
 // GeoInfo contains geographic location information.
@@ -69,11 +192,32 @@ type GeoInfo struct {
 	// City is the city name (if available)
 	City string `json:"city,omitempty"`
 
+	// Region is the state or province (if available), e.g. "Virginia"
+	Region string `json:"region,omitempty"`
+
+	// RegionCode is the region's code, e.g. "VA"
+	RegionCode string `json:"region_code,omitempty"`
+
 	// Latitude is the geographic latitude
 	Latitude float64 `json:"latitude,omitempty"`
 
 	// Longitude is the geographic longitude
 	Longitude float64 `json:"longitude,omitempty"`
+
+	// ISP is the network operator (if available)
+	ISP string `json:"isp,omitempty"`
+
+	// Hosting is true for data center / cloud provider addresses
+	Hosting bool `json:"hosting,omitempty"`
+
+	// Proxy is true for known proxy, VPN or Tor exit addresses
+	Proxy bool `json:"proxy,omitempty"`
+
+	// Mobile is true for mobile carrier addresses
+	Mobile bool `json:"mobile,omitempty"`
+
+	// Source is the enrichment provider that supplied the data (e.g., "ip-api")
+	Source string `json:"source,omitempty"`
 }
 
 // TraceResult contains the complete result of a trace operation.
@@ -81,25 +225,197 @@ type TraceResult struct {
 	// Target is the original target (hostname or IP)
 	Target string `json:"target"`
 
+	// Aliases are the configured alias names that resolved to Target,
+	// in the order they were followed (optional)
+	Aliases []string `json:"aliases,omitempty"`
+
 	// ResolvedIP is the resolved IP address of the target
 	ResolvedIP net.IP `json:"resolved_ip"`
 
+	// TargetPTR is the PTR name of ResolvedIP, looked up even when hop
+	// hostnames are not shown (empty if none or rDNS is disabled)
+	TargetPTR string `json:"target_ptr,omitempty"`
+
+	// TranslatedVia describes an address translation used to reach an
+	// IPv4 target, e.g. "NAT64 64:ff9b::/96" (optional)
+	TranslatedVia string `json:"translated_via,omitempty"`
+
 	// Timestamp is when the trace was performed
 	Timestamp time.Time `json:"timestamp"`
 
 	// ProbeMethod is the probe method used (icmp, udp, tcp)
 	ProbeMethod string `json:"probe_method"`
 
+	// Mode is how hops were probed: "sequential" or "concurrent"
+	Mode string `json:"mode,omitempty"`
+
+	// Concurrency is the number of probes kept in flight (concurrent mode only)
+	Concurrency int `json:"concurrency,omitempty"`
+
+	// ProbeSocket is the kind of socket responses were received on:
+	// "raw" or "dgram" (empty if the prober does not report it)
+	ProbeSocket string `json:"probe_socket,omitempty"`
+
+	// Notes records decisions the tracer made on its own, such as forcing
+	// sequential mode, so saved results can be explained later
+	Notes []string `json:"notes,omitempty"`
+
+	// Redirects lists the ICMP Redirects routers sent for the probes
+	// (optional); they are reported only and never followed
+	Redirects []Redirect `json:"redirects,omitempty"`
+
+	// ProbeStats counts the packets sent and received during the trace
+	ProbeStats *probe.Stats `json:"probe_stats,omitempty"`
+
+	// EnrichRetry counts the hops whose ASN or GeoIP lookup failed and
+	// was retried at the end of the trace (optional)
+	EnrichRetry *EnrichRetry `json:"enrich_retry,omitempty"`
+
 	// Hops contains all the hops in the trace
 	Hops []Hop `json:"hops"`
 
+	// Skipped describes leading private hops that were fast-forwarded (optional)
+	Skipped *SkippedHops `json:"skipped,omitempty"`
+
+	// Via lists the routers probes were loose source routed through, so
+	// hops reflect the source-routed path (optional)
+	Via []net.IP `json:"via,omitempty"`
+
 	// Completed indicates if the trace reached the destination
 	Completed bool `json:"completed"`
 
+	// StoppedReason explains why a trace stopped early, e.g. StopLastHop
+	// for a partial path (empty otherwise)
+	StoppedReason string `json:"stopped_reason,omitempty"`
+
+	// Rounds is the number of rounds accumulated by TraceLoop (0 for a
+	// single trace)
+	Rounds int `json:"rounds,omitempty"`
+
+	// PathChanges lists the hops TraceLoop saw answer from a new
+	// address, oldest first (optional)
+	PathChanges []PathChange `json:"path_changes,omitempty"`
+
 	// Summary contains aggregate statistics
 	Summary Summary `json:"summary"`
+
+	// AppProbe holds the connect and TLS handshake times measured after
+	// the trace (optional, Config.AppProbe)
+	AppProbe *AppProbe `json:"app_probe,omitempty"`
+
+	// Meta describes the host and parameters that produced the trace (optional)
+	Meta *Meta `json:"meta,omitempty"`
+}
+
+// Meta records where and how a trace was run, so results collected from
+// many machines can be told apart afterwards. All fields are optional.
+type Meta struct {
+	// Version is the poros version that produced the result
+	Version string `json:"version,omitempty"`
+
+	// Hostname is the local host name
+	Hostname string `json:"hostname,omitempty"`
+
+	// OS and Arch describe the local platform (e.g., "linux", "amd64")
+	OS   string `json:"os,omitempty"`
+	Arch string `json:"arch,omitempty"`
+
+	// SourceIP is the local address used to reach the target
+	SourceIP net.IP `json:"source_ip,omitempty"`
+
+	// Interface is the local network interface holding SourceIP
+	Interface string `json:"interface,omitempty"`
+
+	// ResolvePolicy is the active DNS resolve policy for hostname targets
+	ResolvePolicy string `json:"resolve_policy,omitempty"`
+
+	// PinnedIP is the destination address pinned by the resolve policy
+	PinnedIP net.IP `json:"pinned_ip,omitempty"`
+
+	// RTTOutlier is the outlier policy of the hop statistics, if not none
+	RTTOutlier string `json:"rtt_outlier,omitempty"`
+
+	// Probe parameters
+	ProbeCount int     `json:"probe_count,omitempty"`
+	DestProbes int     `json:"dest_probe_count,omitempty"`
+	MaxHops    int     `json:"max_hops,omitempty"`
+	FirstHop   int     `json:"first_hop,omitempty"`
+	LastHop    int     `json:"last_hop,omitempty"`
+	TimeoutMs  float64 `json:"timeout_ms,omitempty"`
+	DestPort   int     `json:"dest_port,omitempty"`
+
+	// Packet identifiers carried by the probes, for matching a capture
+	ICMPID   int `json:"icmp_id,omitempty"`
+	SeqStart int `json:"seq_start,omitempty"`
+	FlowID   int `json:"flow_id,omitempty"`
+
+	// Enrichment maps each enabled provider (rdns, asn, geoip) to
+	// "online" or "offline" depending on the data source used
+	Enrichment map[string]string `json:"enrichment,omitempty"`
+
+	// Tags are the user-supplied key=value labels for the run
+	Tags map[string]string `json:"tags,omitempty"`
 }
 
+// Redirect is an ICMP Redirect received during a trace, once per router
+// and suggested gateway.
+type Redirect struct {
+	// Router is the address that sent the redirect, usually the first hop
+	Router net.IP `json:"router"`
+
+	// Gateway is the first hop the router suggested instead
+	Gateway net.IP `json:"gateway"`
+
+	// Count is the number of redirects received with this router and gateway
+	Count int `json:"count"`
+}
+
+// SkippedHops describes a run of leading hops that answered from private or
+// CGNAT address space and were probed only once before full probing began.
+type SkippedHops struct {
+	// FirstHop is the first skipped hop number
+	FirstHop int `json:"first_hop"`
+
+	// LastHop is the last skipped hop number
+	LastHop int `json:"last_hop"`
+
+	// RTT is the round-trip time to the last skipped hop in milliseconds
+	RTT float64 `json:"rtt_ms"`
+}
+
+// Count returns the number of skipped hops.
+func (s *SkippedHops) Count() int {
+	if s == nil {
+		return 0
+	}
+	return s.LastHop - s.FirstHop + 1
+}
+
+// EnrichRetry records the second pass of ASN and GeoIP lookups for
+// public hop addresses the first pass left without data.
+type EnrichRetry struct {
+	// Retried is the number of addresses looked up again
+	Retried int `json:"retried"`
+
+	// Recovered is the number of them that gained ASN or GeoIP data
+	Recovered int `json:"recovered"`
+}
+
+// Reasons reported in TraceResult.StoppedReason.
+const (
+	// StopLastHop means a partial path ended at Config.LastHop
+	StopLastHop = "last_hop"
+
+	// StopPacketBudget means the trace used up Config.MaxPackets
+	StopPacketBudget = "packet_budget"
+)
+
+// Trace modes reported in TraceResult.Mode.
+const (
+	ModeSequential = "sequential"
+	ModeConcurrent = "concurrent"
+)
+
 // Summary contains aggregate statistics for a trace.
 type Summary struct {
 	// TotalHops is the number of hops in the trace
@@ -110,6 +426,25 @@ type Summary struct {
 
 	// PacketLossPercent is the average packet loss across all hops
 	PacketLossPercent float64 `json:"packet_loss_percent"`
+
+	// PerAS attributes latency to each AS along the path (requires ASN data)
+	PerAS []ASContribution `json:"per_as,omitempty"`
+
+	// LastTransitHop is the last hop before the destination's AS
+	// (requires ASN data)
+	LastTransitHop *TransitHop `json:"last_transit_hop,omitempty"`
+
+	// DestinationProbes is the number of extra end-host probes sent after
+	// the trace (Config.VerifyDest); the two fields below are only set
+	// when it is non-zero
+	DestinationProbes int `json:"destination_probes,omitempty"`
+
+	// DestinationLossPercent is the loss of the end-host probes, measured
+	// separately from the path so router ICMP deprioritization is excluded
+	DestinationLossPercent float64 `json:"destination_loss_percent,omitempty"`
+
+	// DestinationAvgRTT is the average RTT of the end-host probes in ms
+	DestinationAvgRTT float64 `json:"destination_avg_rtt,omitempty"`
 }
 
 // IsDestination checks if this hop is the final destination.
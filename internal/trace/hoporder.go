@@ -0,0 +1,74 @@
+package trace
+
+// hopOrderer releases the hops of a concurrent trace in ascending TTL
+// order. Workers complete hops out of order; a completed hop is held
+// until every hop before it has been released, then it and the run of
+// held hops after it are released together. It holds at most one hop per
+// TTL of the trace, and none beyond the destination once that is known.
+type hopOrderer struct {
+	next    int // TTL released next
+	last    int // highest TTL to release
+	pending map[int]Hop
+	release func(hop *Hop)
+
+	// holdDest keeps the destination's hop until flush, for hops that
+	// are completed once every other hop is in
+	holdDest bool
+	reached  bool
+}
+
+// newHopOrderer returns an orderer for the TTLs first to last that calls
+// release with each hop in turn.
+func newHopOrderer(first, last int, release func(hop *Hop)) *hopOrderer {
+	return &hopOrderer{
+		next:    first,
+		last:    last,
+		pending: make(map[int]Hop),
+		release: release,
+	}
+}
+
+// add records a completed hop and releases every hop it unblocks.
+// reached marks a reply from the destination: no later TTL is released,
+// since the trace ends there.
+func (o *hopOrderer) add(ttl int, hop Hop, reached bool) {
+	if ttl < o.next || ttl > o.last {
+		return
+	}
+	if reached {
+		o.reached = true
+		o.last = ttl
+		for held := range o.pending {
+			if held > ttl {
+				delete(o.pending, held)
+			}
+		}
+	}
+	o.pending[ttl] = hop
+
+	for {
+		if o.holdDest && o.reached && o.next == o.last {
+			return
+		}
+		hop, ok := o.pending[o.next]
+		if !ok {
+			return
+		}
+		delete(o.pending, o.next)
+		o.next++
+		o.release(&hop)
+	}
+}
+
+// flush releases the hops still blocked once the trace has ended, taking
+// the final hop of each TTL from hopMap: gaps are hops the trace gave up
+// on, which hopMap holds as unprobed or lacks if probing was cancelled.
+func (o *hopOrderer) flush(hopMap map[int]Hop) {
+	for ttl := o.next; ttl <= o.last; ttl++ {
+		if hop, ok := hopMap[ttl]; ok {
+			o.release(&hop)
+		}
+	}
+	o.next = o.last + 1
+	clear(o.pending)
+}
@@ -0,0 +1,104 @@
+package trace
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestHopOrderer(t *testing.T) {
+	tests := []struct {
+		name    string
+		order   []int // TTLs in completion order
+		reached int   // TTL answered by the destination (0 = none)
+		final   []int // TTLs in the final hop map (nil = those added)
+		want    []int // TTLs released, in order
+		held    int   // hops still held before flush
+		hold    bool  // hold the destination's hop until flush
+	}{
+		{
+			name:  "in order",
+			order: []int{1, 2, 3, 4},
+			want:  []int{1, 2, 3, 4},
+		},
+		{
+			name:  "scrambled",
+			order: []int{4, 2, 3, 1},
+			want:  []int{1, 2, 3, 4},
+		},
+		{
+			name:    "hops after the destination dropped",
+			order:   []int{5, 4, 2, 3, 1},
+			reached: 3,
+			want:    []int{1, 2, 3},
+		},
+		{
+			name:    "destination drops held hops",
+			order:   []int{5, 4, 3, 2, 1},
+			reached: 3,
+			want:    []int{1, 2, 3},
+		},
+		{
+			name:  "gap flushed at the end",
+			order: []int{1, 3, 4},
+			final: []int{1, 2, 3, 4},
+			want:  []int{1, 2, 3, 4},
+			held:  2,
+		},
+		{
+			name:  "gap left by cancellation",
+			order: []int{1, 3, 4},
+			want:  []int{1, 3, 4},
+			held:  2,
+		},
+		{
+			name:    "destination held until flush",
+			order:   []int{1, 2, 4, 3},
+			reached: 3,
+			want:    []int{1, 2, 3},
+			held:    1,
+			hold:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var released []int
+			o := newHopOrderer(1, 8, func(hop *Hop) {
+				released = append(released, hop.Number)
+			})
+			o.holdDest = tt.hold
+
+			hopMap := make(map[int]Hop)
+			for _, ttl := range tt.order {
+				hop := Hop{Number: ttl, Responded: true}
+				hopMap[ttl] = hop
+				o.add(ttl, hop, ttl == tt.reached)
+			}
+			if len(o.pending) != tt.held {
+				t.Errorf("%d hops held, want %d", len(o.pending), tt.held)
+			}
+
+			if tt.final != nil {
+				for _, ttl := range tt.final {
+					if _, ok := hopMap[ttl]; !ok {
+						hopMap[ttl] = Hop{Number: ttl, Unprobed: true}
+					}
+				}
+			}
+			o.flush(hopMap)
+			if !slices.Equal(released, tt.want) {
+				t.Errorf("released %v, want %v", released, tt.want)
+			}
+			if len(o.pending) != 0 {
+				t.Errorf("%d hops held after flush", len(o.pending))
+			}
+
+			// Nothing is released twice
+			o.add(2, Hop{Number: 2}, false)
+			o.flush(hopMap)
+			if !slices.Equal(released, tt.want) {
+				t.Errorf("released %v after flush, want %v", released, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package trace
+
+import (
+	"context"
+	"net"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/probe"
+)
+
+// DefaultLoopInterval is the pause between the rounds of TraceLoop.
+const DefaultLoopInterval = time.Second
+
+// Round is passed to Config.OnRound after each round of TraceLoop.
+type Round struct {
+	// Number counts the rounds from 1
+	Number int
+
+	// Result is the trace of this round alone
+	Result *TraceResult
+
+	// Total accumulates every round so far; it is what TraceLoop
+	// returns once stopped
+	Total *TraceResult
+}
+
+// TraceLoop traces target in rounds, like mtr, until ctx is done, with
+// interval between the end of one round and the start of the next. Each
+// round re-probes every hop, and the hops of the total result accumulate
+// their samples over all rounds: Sent, Received, StdDev and EWMA count
+// every probe, the statistics cover every reply, and RTTs keeps the last
+// DefaultRecentSamples. A hop shows the address it last answered from:
+// when it answers from a new one, the change is added to PathChanges and
+// the hop's statistics start afresh for its new router, as in the TUI
+// history. The total ignores Config.RTTOutlier.
+//
+// Once stopped it returns the total, leaving out a round ctx interrupted.
+// It fails if a round fails, or if ctx is done before the first round
+// completes.
+func (t *Tracer) TraceLoop(ctx context.Context, target string, interval time.Duration) (*TraceResult, error) {
+	loop := &loopStats{hops: make(map[int]*loopHop)}
+	var total *TraceResult
+	for n := 1; ; n++ {
+		result, err := t.Trace(ctx, target)
+		if err != nil {
+			if total != nil && ctx.Err() != nil {
+				return total, nil
+			}
+			return nil, err
+		}
+		total = loop.add(result)
+		total.Rounds = n
+		if t.config.OnRound != nil {
+			t.config.OnRound(Round{Number: n, Result: result, Total: total})
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return total, nil
+		case <-timer.C:
+		}
+	}
+}
+
+// loopStats accumulates the rounds of TraceLoop.
+type loopStats struct {
+	start   time.Time // of the first round
+	hops    map[int]*loopHop
+	stats   probe.Stats
+	notes   []string
+	changes []PathChange
+}
+
+type loopHop struct {
+	hop   Hop // latest identity (IP, hostname, enrichment)
+	stats *RTTStats
+
+	weakMatches int
+	sendErrors  int
+}
+
+// add merges one round into the statistics and returns the total so far.
+// The total is timestamped with the start of the first round and takes
+// the metadata and enrichment of the latest.
+func (l *loopStats) add(result *TraceResult) *TraceResult {
+	if l.start.IsZero() {
+		l.start = result.Timestamp
+	}
+	for _, hop := range result.Hops {
+		entry, ok := l.hops[hop.Number]
+		if !ok {
+			entry = &loopHop{hop: Hop{Number: hop.Number}, stats: NewRTTStats(0)}
+			l.hops[hop.Number] = entry
+		}
+		if change, ok := DetectPathChange(&entry.hop, &hop, result.Timestamp); ok {
+			l.changes = append(l.changes, change)
+			*entry = loopHop{hop: entry.hop, stats: NewRTTStats(0)}
+		}
+		for _, rtt := range hop.RTTs {
+			entry.stats.Add(rtt)
+		}
+		entry.weakMatches += hop.WeakMatches
+		entry.sendErrors += hop.SendErrors
+		if hop.Responded {
+			kind, sendError := entry.hop.ResponseKind, entry.hop.SendError
+			entry.hop = hop
+			if entry.hop.ResponseKind == "" {
+				entry.hop.ResponseKind = kind
+			}
+			if entry.hop.SendError == "" {
+				entry.hop.SendError = sendError
+			}
+		} else if hop.SendError != "" {
+			entry.hop.SendError = hop.SendError
+		}
+	}
+	if result.ProbeStats != nil {
+		l.stats = l.stats.Add(*result.ProbeStats)
+		l.stats.Method = result.ProbeStats.Method
+	}
+	for _, note := range result.Notes {
+		if !slices.Contains(l.notes, note) {
+			l.notes = append(l.notes, note)
+		}
+	}
+
+	total := *result
+	total.Timestamp = l.start
+	total.Hops = l.totalHops(result.ResolvedIP)
+	total.Completed = len(total.Hops) > 0 && total.Hops[len(total.Hops)-1].IsDestination(result.ResolvedIP)
+	if total.Completed && total.StoppedReason == StopLastHop {
+		total.StoppedReason = ""
+	}
+	total.Summary = Summarize(total.Hops)
+	total.Summary.LastTransitHop = LastTransitHop(total.Hops, result.ResolvedIP)
+	total.Summary.TotalHops += result.Skipped.Count()
+	stats := l.stats
+	total.ProbeStats = &stats
+	total.Notes = append([]string(nil), l.notes...)
+	total.PathChanges = slices.Clone(l.changes)
+	return &total
+}
+
+// totalHops returns the accumulated hops in order, up to the first one
+// the destination answered from: a round in which the destination was
+// silent probes hops beyond it that are not on the path.
+func (l *loopStats) totalHops(dest net.IP) []Hop {
+	numbers := make([]int, 0, len(l.hops))
+	for number := range l.hops {
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+
+	hops := make([]Hop, 0, len(numbers))
+	for _, number := range numbers {
+		entry := l.hops[number]
+		s := entry.stats
+
+		hop := entry.hop
+		hop.RTTs = s.Recent()
+		hop.SentAt = nil
+		hop.AvgRTT = s.Mean()
+		hop.MinRTT = s.Min()
+		hop.MaxRTT = s.Max()
+		hop.Jitter = s.Max() - s.Min()
+		hop.LastRTT = s.Last()
+		hop.LossPercent = s.LossPercent()
+		hop.Outliers = 0
+		hop.Sent = int(s.Sent())
+		hop.Received = int(s.Count())
+		hop.StdDev = s.StdDev()
+		hop.EWMA = s.EWMA()
+		hop.Responded = s.Count() > 0
+		hop.Unprobed = s.Sent() == 0
+		hop.WeakMatches = entry.weakMatches
+		hop.SendErrors = entry.sendErrors
+		hops = append(hops, hop)
+
+		if hop.IsDestination(dest) {
+			break
+		}
+	}
+	return hops
+}
@@ -0,0 +1,149 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/probe/probetest"
+)
+
+func TestTracer_TraceLoop(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxHops = 6
+	config.ProbeCount = 1
+	config.Timeout = time.Second
+	config.EnableEnrichment = false
+
+	// One probe per hop and round: hop 2 is lost in round 2, and the
+	// destination is silent in round 2, which then probes up to MaxHops
+	prober := probetest.NewScriptedProber().
+		Hop(1, "192.168.1.1", 1, 1, 1).
+		Hop(2, "198.51.100.2", 10, -1, 30).
+		Hop(3, "203.0.113.5", 5, -1, 7)
+	tracer, err := NewWithProber(config, prober)
+	if err != nil {
+		t.Fatalf("NewWithProber() error = %v", err)
+	}
+	defer tracer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var rounds []Round
+	config.OnRound = func(round Round) {
+		rounds = append(rounds, round)
+		if round.Number == 3 {
+			cancel()
+		}
+	}
+
+	total, err := tracer.TraceLoop(ctx, "203.0.113.5", time.Millisecond)
+	if err != nil {
+		t.Fatalf("TraceLoop() error = %v", err)
+	}
+	if len(rounds) != 3 || total != rounds[2].Total || total.Rounds != 3 {
+		t.Fatalf("got %d rounds, Rounds = %d; want 3 and the last total returned", len(rounds), total.Rounds)
+	}
+	if len(rounds[1].Result.Hops) != 6 || rounds[1].Result.Completed {
+		t.Errorf("round 2 alone should probe to MaxHops without completing")
+	}
+
+	// Hops past the destination, probed only in round 2, are left out
+	if len(total.Hops) != 3 || !total.Completed {
+		t.Fatalf("total has %d hops, completed %v; want 3 and completed", len(total.Hops), total.Completed)
+	}
+	hop := total.Hops[1]
+	if hop.Sent != 3 || hop.Received != 2 || math.Abs(hop.LossPercent-100.0/3) > 1e-9 {
+		t.Errorf("hop 2 sent/received/loss = %d/%d/%.1f, want 3/2/33.3", hop.Sent, hop.Received, hop.LossPercent)
+	}
+	if hop.AvgRTT != 20 || hop.MinRTT != 10 || hop.MaxRTT != 30 || hop.LastRTT != 30 || hop.StdDev != 10 {
+		t.Errorf("hop 2 avg/best/worst/last/stddev = %.0f/%.0f/%.0f/%.0f/%.0f, want 20/10/30/30/10",
+			hop.AvgRTT, hop.MinRTT, hop.MaxRTT, hop.LastRTT, hop.StdDev)
+	}
+	if hop.EWMA != 12.5 {
+		t.Errorf("hop 2 EWMA = %v, want 12.5", hop.EWMA)
+	}
+	if len(hop.RTTs) != 3 || hop.RTTs[1] != -1 {
+		t.Errorf("hop 2 RTTs = %v, want the samples of every round", hop.RTTs)
+	}
+	if dest := total.Hops[2]; !dest.Responded || dest.Sent != 3 || dest.Received != 2 {
+		t.Errorf("destination = %+v, want 2 of 3 probes answered", dest)
+	}
+	if total.ProbeStats == nil || total.ProbeStats.Sent != 3+6+3 || total.ProbeStats.Method != "scripted" {
+		t.Errorf("ProbeStats = %+v, want the probes of all rounds", total.ProbeStats)
+	}
+	if !total.Timestamp.Equal(rounds[0].Result.Timestamp) {
+		t.Errorf("Timestamp = %v, want the start of the first round", total.Timestamp)
+	}
+}
+
+func TestTracer_TraceLoopPathChange(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxHops = 3
+	config.ProbeCount = 1
+	config.EnableEnrichment = false
+
+	// Hop 2 times out in round 2, which is not a change, and answers
+	// from another router in round 3
+	prober := probetest.NewScriptedProber().
+		Hop(1, "192.168.1.1", 1, 1, 1).
+		Hop(2, "62.115.1.1", 10, -1).
+		Reply(2, 2, probetest.Reply{IP: "154.54.1.1", RTT: 20 * time.Millisecond}).
+		Hop(3, "203.0.113.5", 30, 30, 30)
+	tracer, err := NewWithProber(config, prober)
+	if err != nil {
+		t.Fatalf("NewWithProber() error = %v", err)
+	}
+	defer tracer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var rounds []Round
+	config.OnRound = func(round Round) {
+		rounds = append(rounds, round)
+		if round.Number == 3 {
+			cancel()
+		}
+	}
+	total, err := tracer.TraceLoop(ctx, "203.0.113.5", time.Millisecond)
+	if err != nil {
+		t.Fatalf("TraceLoop() error = %v", err)
+	}
+
+	if len(rounds[1].Total.PathChanges) != 0 {
+		t.Errorf("round 2 PathChanges = %+v, want none for a timeout", rounds[1].Total.PathChanges)
+	}
+	want := PathChange{Time: rounds[2].Result.Timestamp, Hop: 2, From: "62.115.1.1", To: "154.54.1.1"}
+	if len(total.PathChanges) != 1 || total.PathChanges[0] != want {
+		t.Fatalf("PathChanges = %+v, want [%+v]", total.PathChanges, want)
+	}
+	// The changed hop starts afresh; the others keep accumulating
+	if hop := total.Hops[1]; !hop.IP.Equal(net.ParseIP("154.54.1.1")) || hop.Sent != 1 || hop.AvgRTT != 20 || hop.LossPercent != 0 {
+		t.Errorf("hop 2 = %s sent %d avg %.0f loss %.0f%%, want 154.54.1.1 sent 1 avg 20 loss 0%%",
+			hop.IP, hop.Sent, hop.AvgRTT, hop.LossPercent)
+	}
+	if hop := total.Hops[0]; hop.Sent != 3 {
+		t.Errorf("hop 1 sent %d, want 3", hop.Sent)
+	}
+}
+
+func TestTracer_TraceLoopStoppedEarly(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxHops = 3
+	config.ProbeCount = 1
+	config.EnableEnrichment = false
+	tracer, err := NewWithProber(config, probetest.NewScriptedProber().Hop(1, "203.0.113.5"))
+	if err != nil {
+		t.Fatalf("NewWithProber() error = %v", err)
+	}
+	defer tracer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := tracer.TraceLoop(ctx, "203.0.113.5", time.Millisecond); !errors.Is(err, context.Canceled) {
+		t.Errorf("TraceLoop() before the first round = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,118 @@
+package trace
+
+import (
+	"net"
+	"os"
+	"runtime"
+)
+
+// buildMeta collects metadata about the local host and trace parameters.
+// Lookups that fail simply leave the corresponding field empty.
+func (t *Tracer) buildMeta(target string, dest net.IP) *Meta {
+	meta := &Meta{
+		Version:    t.config.Version,
+		OS:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+		SourceIP:   t.config.SourceIP,
+		Interface:  t.config.Interface,
+		ProbeCount: t.config.ProbeCount,
+		MaxHops:    t.config.MaxHops,
+		FirstHop:   t.config.FirstHop,
+		LastHop:    t.config.LastHop,
+		TimeoutMs:  float64(t.config.Timeout.Microseconds()) / 1000.0,
+		Tags:       t.config.Tags,
+	}
+
+	if ipLiteral(target) == nil && t.config.ResolvePolicy != ResolveEveryCycle {
+		meta.ResolvePolicy = t.config.ResolvePolicy.String()
+		meta.PinnedIP = t.lookupPinned(target)
+	}
+
+	if t.config.RTTOutlier != OutlierNone {
+		meta.RTTOutlier = t.config.RTTOutlier.String()
+	}
+
+	if t.config.destTopUp() > 0 {
+		meta.DestProbes = t.config.DestProbeCount
+	}
+
+	if t.config.ProbeMethod != ProbeICMP {
+		meta.DestPort = t.config.DestPort
+	}
+
+	// Record the identifiers actually used, including generated defaults
+	meta.SeqStart = t.config.SeqStart
+	if meta.SeqStart == 0 {
+		meta.SeqStart = 1
+	}
+	if p, ok := t.prober.(interface{ Identifier() uint16 }); ok {
+		meta.ICMPID = int(p.Identifier())
+	}
+	if p, ok := t.prober.(interface{ FlowID() uint16 }); ok {
+		meta.FlowID = int(p.FlowID())
+	}
+
+	if hostname, err := os.Hostname(); err == nil {
+		meta.Hostname = hostname
+	}
+
+	if meta.SourceIP == nil {
+		meta.SourceIP = localAddrFor(dest)
+	}
+	if meta.Interface == "" {
+		meta.Interface = interfaceFor(meta.SourceIP)
+	}
+
+	if t.enricher != nil {
+		if providers := t.enricher.Providers(); len(providers) > 0 {
+			meta.Enrichment = providers
+		}
+	}
+
+	return meta
+}
+
+// localAddrFor returns the local address the kernel would use to reach dest.
+// Connecting a UDP socket selects a route without sending any packets.
+func localAddrFor(dest net.IP) net.IP {
+	if dest == nil {
+		return nil
+	}
+
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: dest, Port: 33434})
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+
+	if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+		return addr.IP
+	}
+	return nil
+}
+
+// interfaceFor returns the name of the local interface that holds ip.
+func interfaceFor(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+				return iface.Name
+			}
+		}
+	}
+
+	return ""
+}
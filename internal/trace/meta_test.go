@@ -0,0 +1,93 @@
+package trace
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"testing"
+)
+
+func TestTracer_ResultMeta(t *testing.T) {
+	prober := newScriptedProber("127.0.0.1", map[int]string{
+		1: "127.0.0.1",
+	})
+
+	config := DefaultConfig()
+	config.Sequential = true
+	config.EnableEnrichment = false
+	config.Version = "1.2.3"
+
+	tracer := &Tracer{config: config, prober: prober}
+	result, err := tracer.Trace(context.Background(), "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Trace() error = %v", err)
+	}
+
+	meta := result.Meta
+	if meta == nil {
+		t.Fatal("Meta should be set")
+	}
+	if meta.Version != "1.2.3" {
+		t.Errorf("Version = %q, want %q", meta.Version, "1.2.3")
+	}
+	if meta.OS != runtime.GOOS || meta.Arch != runtime.GOARCH {
+		t.Errorf("OS/Arch = %s/%s, want %s/%s", meta.OS, meta.Arch, runtime.GOOS, runtime.GOARCH)
+	}
+	if meta.ProbeCount != 3 || meta.MaxHops != 30 || meta.FirstHop != 1 {
+		t.Errorf("probe parameters = %d/%d/%d, want 3/30/1", meta.ProbeCount, meta.MaxHops, meta.FirstHop)
+	}
+	if meta.TimeoutMs != 3000 {
+		t.Errorf("TimeoutMs = %v, want 3000", meta.TimeoutMs)
+	}
+	if meta.DestPort != 0 {
+		t.Errorf("DestPort = %d, want 0 for ICMP", meta.DestPort)
+	}
+	if !meta.SourceIP.Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("SourceIP = %v, want 127.0.0.1", meta.SourceIP)
+	}
+	if meta.Enrichment != nil {
+		t.Errorf("Enrichment = %v, want nil when disabled", meta.Enrichment)
+	}
+}
+
+func TestTracer_ResultMeta_ExplicitSource(t *testing.T) {
+	config := DefaultConfig()
+	config.SourceIP = net.ParseIP("192.0.2.10")
+	config.Interface = "tun0"
+
+	tracer := &Tracer{config: config}
+	meta := tracer.buildMeta("8.8.8.8", net.ParseIP("8.8.8.8"))
+
+	if !meta.SourceIP.Equal(config.SourceIP) {
+		t.Errorf("SourceIP = %v, want %v", meta.SourceIP, config.SourceIP)
+	}
+	if meta.Interface != "tun0" {
+		t.Errorf("Interface = %q, want %q", meta.Interface, "tun0")
+	}
+}
+
+// idProber reports fixed packet identifiers.
+type idProber struct {
+	*scriptedProber
+}
+
+func (idProber) Identifier() uint16 { return 0xBEEF }
+func (idProber) FlowID() uint16     { return 0x1234 }
+
+func TestTracer_ResultMeta_PacketIDs(t *testing.T) {
+	config := DefaultConfig()
+	config.SeqStart = 100
+
+	tracer := &Tracer{config: config, prober: idProber{newScriptedProber("127.0.0.1", nil)}}
+	meta := tracer.buildMeta("127.0.0.1", net.ParseIP("127.0.0.1"))
+
+	if meta.ICMPID != 0xBEEF || meta.FlowID != 0x1234 || meta.SeqStart != 100 {
+		t.Errorf("ICMPID/FlowID/SeqStart = %#x/%#x/%d, want 0xbeef/0x1234/100", meta.ICMPID, meta.FlowID, meta.SeqStart)
+	}
+
+	tracer = &Tracer{config: DefaultConfig()}
+	meta = tracer.buildMeta("127.0.0.1", net.ParseIP("127.0.0.1"))
+	if meta.ICMPID != 0 || meta.FlowID != 0 || meta.SeqStart != 1 {
+		t.Errorf("defaults = %d/%d/%d, want 0/0/1", meta.ICMPID, meta.FlowID, meta.SeqStart)
+	}
+}
@@ -0,0 +1,184 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MultiResult combines traces of one target run with different probe
+// methods, to spot hops that filter some kinds of probes.
+type MultiResult struct {
+	// Target is the traced host
+	Target string
+
+	// Methods are the probe methods in the order they were run, and
+	// Results[i] is the trace run with Methods[i]
+	Methods []ProbeMethod
+	Results []*TraceResult
+}
+
+// MultiHop is one hop number across the traces of a MultiResult.
+type MultiHop struct {
+	// Number is the hop number (TTL)
+	Number int
+
+	// Hops[i] is the hop in Results[i], nil if that trace has no hop
+	// with this number, e.g. because it reached the target sooner
+	Hops []*Hop
+}
+
+// Filtered reports whether the hop answered some probe methods but not
+// others, which suggests filtering by protocol. Traces without the hop
+// are not counted.
+func (h MultiHop) Filtered() bool {
+	responded, silent := false, false
+	for _, hop := range h.Hops {
+		if hop == nil || hop.Unprobed {
+			continue
+		}
+		if hop.Responded {
+			responded = true
+		} else {
+			silent = true
+		}
+	}
+	return responded && silent
+}
+
+// Hops aligns the hops of all traces by hop number, in order.
+func (r *MultiResult) Hops() []MultiHop {
+	byNumber := make(map[int]*MultiHop)
+	var numbers []int
+	for i, result := range r.Results {
+		for j := range result.Hops {
+			hop := &result.Hops[j]
+			mh, ok := byNumber[hop.Number]
+			if !ok {
+				mh = &MultiHop{Number: hop.Number, Hops: make([]*Hop, len(r.Results))}
+				byNumber[hop.Number] = mh
+				numbers = append(numbers, hop.Number)
+			}
+			mh.Hops[i] = hop
+		}
+	}
+
+	sort.Ints(numbers)
+	hops := make([]MultiHop, 0, len(numbers))
+	for _, n := range numbers {
+		hops = append(hops, *byNumber[n])
+	}
+	return hops
+}
+
+// FilteredHops returns the numbers of the hops that answered some probe
+// methods but not others.
+func (r *MultiResult) FilteredHops() []int {
+	var filtered []int
+	for _, hop := range r.Hops() {
+		if hop.Filtered() {
+			filtered = append(filtered, hop.Number)
+		}
+	}
+	return filtered
+}
+
+// ParseProbeMethods parses a comma-separated list of at least two
+// distinct probe methods, e.g. "icmp,udp,tcp".
+func ParseProbeMethods(s string) ([]ProbeMethod, error) {
+	var methods []ProbeMethod
+	seen := make(map[ProbeMethod]bool)
+	for _, name := range strings.Split(s, ",") {
+		m, err := ParseProbeMethod(name)
+		if err != nil {
+			return nil, err
+		}
+		if seen[m] {
+			return nil, fmt.Errorf("probe method %s listed twice", m)
+		}
+		seen[m] = true
+		methods = append(methods, m)
+	}
+	if len(methods) < 2 {
+		return nil, fmt.Errorf("need at least two probe methods to compare, got %q", s)
+	}
+	return methods, nil
+}
+
+// TraceMethods traces target once per method, one after another so the
+// traces do not compete for the path, and combines the results. The
+// tracer's own prober serves its configured method; probers for the
+// other methods are opened for their trace and closed after it. All
+// traces share the tracer's enrichment caches and pinned addresses.
+func (t *Tracer) TraceMethods(ctx context.Context, target string, methods []ProbeMethod) (*MultiResult, error) {
+	if t.closed.Load() {
+		return nil, WithStage(StageSocket, target, ErrTracerClosed)
+	}
+	base := t.config
+	defer func() { t.config = base }()
+
+	multi := &MultiResult{Target: target}
+	for _, method := range methods {
+		config := *base
+		config.ProbeMethod = method
+		config.Paris = method == ProbeParis
+		if err := config.Validate(); err != nil {
+			return nil, WithStage(StageConfig, target, fmt.Errorf("%s: %w", method, err))
+		}
+		t.config = &config
+
+		restore, err := t.useProberFor(&config, base)
+		if err != nil {
+			return nil, WithStage(StageSocket, target, fmt.Errorf("%s: %w", method, err))
+		}
+		result, err := t.Trace(ctx, target)
+		restore()
+		if err != nil {
+			return nil, fmt.Errorf("%s trace: %w", method, err)
+		}
+
+		multi.Methods = append(multi.Methods, method)
+		multi.Results = append(multi.Results, result)
+	}
+	return multi, nil
+}
+
+// useProberFor switches to a prober for config's method unless the
+// tracer's own prober, created for base, already is one. It returns a
+// function that switches back and closes the prober used instead.
+func (t *Tracer) useProberFor(config, base *Config) (func(), error) {
+	if config.ProbeMethod == base.ProbeMethod {
+		return func() {}, nil
+	}
+
+	open := t.openProber
+	if open == nil {
+		open = newProber
+	}
+	t.stateMu.Lock()
+	ipv6 := t.prober6
+	t.stateMu.Unlock()
+	prober, err := open(config, ipv6)
+	if err != nil {
+		return nil, err
+	}
+
+	t.stateMu.Lock()
+	own, own6 := t.prober, t.prober6
+	t.prober = prober
+	t.stateMu.Unlock()
+
+	return func() {
+		t.stateMu.Lock()
+		used := t.prober
+		t.prober, t.prober6 = own, own6
+		closed := t.closed.Load()
+		t.stateMu.Unlock()
+		used.Close()
+		// Close released the prober in use, not the tracer's own
+		if closed {
+			own.Close()
+		}
+	}, nil
+}
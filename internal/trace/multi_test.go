@@ -0,0 +1,147 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/probe"
+	"github.com/KilimcininKorOglu/poros/internal/probe/probetest"
+)
+
+func TestParseProbeMethods(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []ProbeMethod
+		wantErr bool
+	}{
+		{"icmp,udp,tcp", []ProbeMethod{ProbeICMP, ProbeUDP, ProbeTCP}, false},
+		{"udp, icmp", []ProbeMethod{ProbeUDP, ProbeICMP}, false},
+		{"icmp", nil, true},
+		{"icmp,icmp", nil, true},
+		{"icmp,gre", nil, true},
+		{"", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseProbeMethods(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseProbeMethods(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseProbeMethods(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+// methodPaths scripts a path to 203.0.113.5 per probe method: hop 2
+// answers only UDP, and the TCP trace is answered by the destination one
+// hop early, as a firewall answering for it would.
+func methodPaths() map[ProbeMethod]*probetest.ScriptedProber {
+	return map[ProbeMethod]*probetest.ScriptedProber{
+		ProbeICMP: probetest.NewScriptedProber().
+			Hop(1, "192.168.1.1").
+			TimeoutHop(2).
+			Hop(3, "198.51.100.3").
+			Hop(4, "203.0.113.5"),
+		ProbeUDP: probetest.NewScriptedProber().
+			Hop(1, "192.168.1.1").
+			Hop(2, "198.51.100.2").
+			Hop(3, "198.51.100.3").
+			Hop(4, "203.0.113.5"),
+		ProbeTCP: probetest.NewScriptedProber().
+			Hop(1, "192.168.1.1").
+			TimeoutHop(2).
+			Hop(3, "203.0.113.5"),
+	}
+}
+
+func TestTracer_TraceMethods(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxHops = 8
+	config.ProbeCount = 1
+	config.Timeout = time.Second
+	config.EnableEnrichment = false
+
+	paths := methodPaths()
+	tracer, err := NewWithProber(config, paths[ProbeICMP])
+	if err != nil {
+		t.Fatalf("NewWithProber() error = %v", err)
+	}
+	defer tracer.Close()
+
+	var opened []ProbeMethod
+	tracer.openProber = func(config *Config, ipv6 bool) (probe.Prober, error) {
+		opened = append(opened, config.ProbeMethod)
+		return paths[config.ProbeMethod], nil
+	}
+
+	methods := []ProbeMethod{ProbeICMP, ProbeUDP, ProbeTCP}
+	multi, err := tracer.TraceMethods(context.Background(), "203.0.113.5", methods)
+	if err != nil {
+		t.Fatalf("TraceMethods() error = %v", err)
+	}
+
+	// The tracer's own prober serves ICMP
+	if want := []ProbeMethod{ProbeUDP, ProbeTCP}; !reflect.DeepEqual(opened, want) {
+		t.Errorf("opened probers for %v, want %v", opened, want)
+	}
+	if !paths[ProbeUDP].Closed() || !paths[ProbeTCP].Closed() {
+		t.Error("probers opened for other methods should be closed after their trace")
+	}
+	if paths[ProbeICMP].Closed() {
+		t.Error("the tracer's own prober should stay open")
+	}
+	if tracer.config != config {
+		t.Error("TraceMethods should restore the tracer's config")
+	}
+
+	if !reflect.DeepEqual(multi.Methods, methods) {
+		t.Errorf("Methods = %v, want %v", multi.Methods, methods)
+	}
+	for i, result := range multi.Results {
+		if !result.Completed {
+			t.Errorf("%s trace should complete", methods[i])
+		}
+	}
+
+	hops := multi.Hops()
+	if len(hops) != 4 {
+		t.Fatalf("len(Hops()) = %d, want 4", len(hops))
+	}
+	if hops[3].Hops[2] != nil {
+		t.Error("hop 4 should be missing from the TCP trace")
+	}
+	if got := multi.FilteredHops(); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("FilteredHops() = %v, want [2]", got)
+	}
+}
+
+func TestTracer_TraceMethodsError(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxHops = 4
+	config.ProbeCount = 1
+	config.EnableEnrichment = false
+
+	tracer, err := NewWithProber(config, methodPaths()[ProbeICMP])
+	if err != nil {
+		t.Fatalf("NewWithProber() error = %v", err)
+	}
+	defer tracer.Close()
+
+	errDenied := errors.New("permission denied")
+	tracer.openProber = func(*Config, bool) (probe.Prober, error) {
+		return nil, errDenied
+	}
+
+	_, err = tracer.TraceMethods(context.Background(), "203.0.113.5", []ProbeMethod{ProbeICMP, ProbeTCP})
+	if !errors.Is(err, errDenied) {
+		t.Fatalf("TraceMethods() error = %v, want %v", err, errDenied)
+	}
+	if ErrorStage(err) != StageSocket {
+		t.Errorf("ErrorStage(err) = %v, want %v", ErrorStage(err), StageSocket)
+	}
+}
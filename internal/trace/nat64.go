@@ -0,0 +1,189 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// WellKnownNAT64Prefix is the RFC 6052 well-known prefix 64:ff9b::/96.
+var WellKnownNAT64Prefix = &net.IPNet{
+	IP:   net.ParseIP("64:ff9b::"),
+	Mask: net.CIDRMask(96, 128),
+}
+
+// ipv4OnlyName is resolved to discover the NAT64 prefix (RFC 7050). It
+// only has A records, so any AAAA answer was synthesized by DNS64.
+const ipv4OnlyName = "ipv4only.arpa"
+
+// ipv4OnlyAddrs are the well-known A records of ipv4only.arpa.
+var ipv4OnlyAddrs = []net.IP{
+	net.IPv4(192, 0, 0, 170).To4(),
+	net.IPv4(192, 0, 0, 171).To4(),
+}
+
+// ipv4Reachable reports whether the host has an IPv4 route. Connecting a
+// UDP socket sends no packets; it only performs the route lookup.
+var ipv4Reachable = func() bool {
+	conn, err := net.Dial("udp4", "192.0.2.1:9")
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// hasIPv4Route reports whether the host has an IPv4 route, checking once
+// per Tracer.
+func (t *Tracer) hasIPv4Route() bool {
+	t.ipv4Once.Do(func() { t.ipv4Route = ipv4Reachable() })
+	return t.ipv4Route
+}
+
+// ParseNAT64Prefix parses a NAT64 prefix in CIDR notation. The prefix
+// length must be one of those allowed by RFC 6052.
+func ParseNAT64Prefix(s string) (*net.IPNet, error) {
+	_, prefix, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NAT64 prefix %q: %w", s, err)
+	}
+	if prefix.IP.To4() != nil {
+		return nil, fmt.Errorf("invalid NAT64 prefix %q: must be an IPv6 prefix", s)
+	}
+	ones, _ := prefix.Mask.Size()
+	if !validNAT64Length(ones) {
+		return nil, fmt.Errorf("invalid NAT64 prefix %q: length must be 32, 40, 48, 56, 64 or 96", s)
+	}
+	return prefix, nil
+}
+
+// validNAT64Length reports whether n is an RFC 6052 prefix length.
+func validNAT64Length(n int) bool {
+	switch n {
+	case 32, 40, 48, 56, 64, 96:
+		return true
+	}
+	return false
+}
+
+// SynthesizeNAT64 embeds an IPv4 address in a NAT64 prefix following
+// RFC 6052 section 2.2. Bits 64-71 (the "u" octet) are always zero, so
+// for prefixes shorter than /96 the IPv4 address is split around it.
+func SynthesizeNAT64(prefix *net.IPNet, v4 net.IP) (net.IP, error) {
+	addr := v4.To4()
+	if addr == nil {
+		return nil, fmt.Errorf("%s is not an IPv4 address", v4)
+	}
+	ones, bits := prefix.Mask.Size()
+	if bits != 128 || !validNAT64Length(ones) {
+		return nil, fmt.Errorf("invalid NAT64 prefix %s", prefix)
+	}
+
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, prefix.IP.To16()[:ones/8])
+
+	pos := ones / 8
+	for _, b := range addr {
+		if pos == 8 {
+			pos++ // skip the u octet
+		}
+		ip[pos] = b
+		pos++
+	}
+	return ip, nil
+}
+
+// extractNAT64Prefix finds the prefix in a synthesized address whose
+// embedded IPv4 address is known, trying each RFC 6052 length.
+func extractNAT64Prefix(addr, v4 net.IP) *net.IPNet {
+	for _, ones := range []int{96, 64, 56, 48, 40, 32} {
+		prefix := &net.IPNet{IP: addr.Mask(net.CIDRMask(ones, 128)), Mask: net.CIDRMask(ones, 128)}
+		if synth, err := SynthesizeNAT64(prefix, v4); err == nil && synth.Equal(addr) {
+			return prefix
+		}
+	}
+	return nil
+}
+
+// discoverNAT64Prefix resolves ipv4only.arpa over DNS64 and derives the
+// NAT64 prefix from the synthesized AAAA answer.
+func (t *Tracer) discoverNAT64Prefix(ctx context.Context) (*net.IPNet, error) {
+	ips, _, err := t.resolver().Resolve(ctx, "ip6", ipv4OnlyName)
+	if err != nil {
+		return nil, fmt.Errorf("NAT64 discovery failed: %w", err)
+	}
+
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			continue
+		}
+		for _, v4 := range ipv4OnlyAddrs {
+			if prefix := extractNAT64Prefix(ip, v4); prefix != nil {
+				return prefix, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("NAT64 discovery failed: no DNS64-synthesized address for %s", ipv4OnlyName)
+}
+
+// nat64Prefix returns the configured or discovered NAT64 prefix. A
+// discovered prefix is cached for the Tracer's lifetime.
+func (t *Tracer) nat64Prefix(ctx context.Context) (*net.IPNet, error) {
+	if t.config.NAT64Prefix != nil {
+		return t.config.NAT64Prefix, nil
+	}
+
+	t.pinMu.Lock()
+	cached := t.nat64
+	t.pinMu.Unlock()
+	if cached != nil {
+		return cached, nil
+	}
+
+	prefix, err := t.discoverNAT64Prefix(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	t.pinMu.Lock()
+	t.nat64 = prefix
+	t.pinMu.Unlock()
+	return prefix, nil
+}
+
+// translateNAT64 maps an IPv4 target into the NAT64 prefix when it has to
+// be reached over IPv6: either IPv6 was forced or the host has no IPv4
+// route (464XLAT / IPv6-only networks). ok is false when no translation
+// applies.
+func (t *Tracer) translateNAT64(ctx context.Context, v4 net.IP) (ip net.IP, ok bool, err error) {
+	if !t.config.IPv6 && (t.config.IPv4 || t.hasIPv4Route()) {
+		return nil, false, nil
+	}
+
+	prefix, err := t.nat64Prefix(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	ip, err = SynthesizeNAT64(prefix, v4)
+	if err != nil {
+		return nil, false, err
+	}
+	return ip, true, nil
+}
+
+// translatedVia describes the NAT64 translation used to reach dest, or
+// returns an empty string if dest is not a NAT64 address.
+func (t *Tracer) translatedVia(dest net.IP) string {
+	prefix := t.config.NAT64Prefix
+	if prefix == nil {
+		t.pinMu.Lock()
+		prefix = t.nat64
+		t.pinMu.Unlock()
+	}
+
+	if prefix == nil || dest.To4() != nil || !prefix.Contains(dest) {
+		return ""
+	}
+	return "NAT64 " + prefix.String()
+}
@@ -0,0 +1,177 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// dns64Resolver answers ipv4only.arpa with a synthesized AAAA record.
+type dns64Resolver struct {
+	answers []net.IP
+	err     error
+	calls   int
+}
+
+func (r *dns64Resolver) Resolve(ctx context.Context, network, host string) ([]net.IP, time.Duration, error) {
+	r.calls++
+	if host != ipv4OnlyName || network != "ip6" {
+		return nil, 0, errors.New("unexpected query")
+	}
+	return r.answers, 0, r.err
+}
+
+// withIPv4Reachable overrides IPv4 route detection for a test.
+func withIPv4Reachable(t *testing.T, reachable bool) {
+	orig := ipv4Reachable
+	ipv4Reachable = func() bool { return reachable }
+	t.Cleanup(func() { ipv4Reachable = orig })
+}
+
+func TestSynthesizeNAT64(t *testing.T) {
+	// RFC 6052 section 2.4 examples for 192.0.2.33
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{"2001:db8::/32", "2001:db8:c000:221::"},
+		{"2001:db8:100::/40", "2001:db8:1c0:2:21::"},
+		{"2001:db8:122::/48", "2001:db8:122:c000:2:2100::"},
+		{"2001:db8:122:300::/56", "2001:db8:122:3c0:0:221::"},
+		{"2001:db8:122:344::/64", "2001:db8:122:344:c0:2:2100:0"},
+		{"2001:db8:122:344::/96", "2001:db8:122:344::192.0.2.33"},
+		{"64:ff9b::/96", "64:ff9b::192.0.2.33"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			prefix, err := ParseNAT64Prefix(tt.prefix)
+			if err != nil {
+				t.Fatalf("ParseNAT64Prefix() error = %v", err)
+			}
+			got, err := SynthesizeNAT64(prefix, net.ParseIP("192.0.2.33"))
+			if err != nil {
+				t.Fatalf("SynthesizeNAT64() error = %v", err)
+			}
+			if !got.Equal(net.ParseIP(tt.want)) {
+				t.Errorf("SynthesizeNAT64() = %s, want %s", got, tt.want)
+			}
+
+			if back := extractNAT64Prefix(got, net.ParseIP("192.0.2.33")); back == nil || back.String() != prefix.String() {
+				t.Errorf("extractNAT64Prefix() = %v, want %s", back, prefix)
+			}
+		})
+	}
+}
+
+func TestParseNAT64Prefix_Invalid(t *testing.T) {
+	for _, s := range []string{"", "64:ff9b::", "64:ff9b::/80", "192.0.2.0/24"} {
+		if _, err := ParseNAT64Prefix(s); err == nil {
+			t.Errorf("ParseNAT64Prefix(%q) should fail", s)
+		}
+	}
+}
+
+func TestTracer_NAT64Discovery(t *testing.T) {
+	withIPv4Reachable(t, false)
+
+	resolver := &dns64Resolver{answers: []net.IP{net.ParseIP("2001:db8:64::c000:aa")}}
+	config := DefaultConfig()
+	config.Resolver = resolver
+	tracer := &Tracer{config: config}
+
+	dest, err := tracer.resolveTarget(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("resolveTarget() error = %v", err)
+	}
+	if want := net.ParseIP("2001:db8:64::808:808"); !dest.Equal(want) {
+		t.Errorf("resolveTarget() = %s, want %s", dest, want)
+	}
+	if via := tracer.translatedVia(dest); via != "NAT64 2001:db8:64::/96" {
+		t.Errorf("translatedVia() = %q", via)
+	}
+
+	// The discovered prefix is reused
+	tracer.resolveTarget(context.Background(), "1.1.1.1")
+	if resolver.calls != 1 {
+		t.Errorf("discovery ran %d times, want 1", resolver.calls)
+	}
+}
+
+func TestTracer_NAT64(t *testing.T) {
+	override, _ := ParseNAT64Prefix("2001:db8:46::/96")
+
+	tests := []struct {
+		name      string
+		reachable bool
+		ipv6      bool
+		prefix    *net.IPNet
+		resolver  *dns64Resolver
+		want      string
+		wantErr   bool
+	}{
+		{
+			name:      "IPv4 reachable",
+			reachable: true,
+			resolver:  &dns64Resolver{answers: []net.IP{net.ParseIP("64:ff9b::c000:aa")}},
+			want:      "192.0.2.10",
+		},
+		{
+			name:     "IPv6-only host",
+			resolver: &dns64Resolver{answers: []net.IP{net.ParseIP("64:ff9b::c000:ab")}},
+			want:     "64:ff9b::c000:20a",
+		},
+		{
+			name:      "IPv6 forced",
+			reachable: true,
+			ipv6:      true,
+			resolver:  &dns64Resolver{answers: []net.IP{net.ParseIP("64:ff9b::c000:aa")}},
+			want:      "64:ff9b::c000:20a",
+		},
+		{
+			name:     "prefix override skips discovery",
+			prefix:   override,
+			resolver: &dns64Resolver{err: errors.New("no DNS64")},
+			want:     "2001:db8:46::c000:20a",
+		},
+		{
+			name:     "no DNS64 falls back to IPv4",
+			resolver: &dns64Resolver{err: errors.New("NXDOMAIN")},
+			want:     "192.0.2.10",
+		},
+		{
+			name:     "IPv6 forced without DNS64",
+			ipv6:     true,
+			resolver: &dns64Resolver{answers: []net.IP{net.ParseIP("192.0.0.170")}},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withIPv4Reachable(t, tt.reachable)
+
+			config := DefaultConfig()
+			config.IPv6 = tt.ipv6
+			config.NAT64Prefix = tt.prefix
+			config.Resolver = tt.resolver
+			tracer := &Tracer{config: config}
+
+			dest, err := tracer.resolveTarget(context.Background(), "192.0.2.10")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveTarget() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !dest.Equal(net.ParseIP(tt.want)) {
+				t.Errorf("resolveTarget() = %s, want %s", dest, tt.want)
+			}
+			if tt.prefix != nil && tt.resolver.calls != 0 {
+				t.Error("discovery should not run with a configured prefix")
+			}
+		})
+	}
+}
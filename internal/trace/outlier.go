@@ -0,0 +1,114 @@
+package trace
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// OutlierPolicy controls how outlying RTT samples, such as a SYN
+// retransmission or a reply delayed by a busy router CPU, enter a hop's
+// statistics. Hop.RTTs always keeps the raw samples.
+type OutlierPolicy int
+
+const (
+	// OutlierNone uses every sample as measured
+	OutlierNone OutlierPolicy = iota
+	// OutlierWinsorize clips samples above the hop's OutlierPercentile
+	// to that percentile
+	OutlierWinsorize
+	// OutlierDrop leaves out samples more than OutlierIQRFactor times the
+	// interquartile range below the first or above the third quartile
+	OutlierDrop
+)
+
+// Parameters of the outlier policies.
+const (
+	// OutlierPercentile is the percentile OutlierWinsorize clips at
+	OutlierPercentile = 95
+	// OutlierIQRFactor is the k of OutlierDrop's k×IQR fences
+	OutlierIQRFactor = 1.5
+	// minOutlierSamples is the fewest answered probes a policy is applied
+	// to; fewer say nothing about which of them is out of line
+	minOutlierSamples = 4
+)
+
+// String returns the string representation of the outlier policy.
+func (p OutlierPolicy) String() string {
+	switch p {
+	case OutlierNone:
+		return "none"
+	case OutlierWinsorize:
+		return "winsorize"
+	case OutlierDrop:
+		return "drop"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseOutlierPolicy parses an outlier policy name ("" = none).
+func ParseOutlierPolicy(s string) (OutlierPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "none":
+		return OutlierNone, nil
+	case "winsorize":
+		return OutlierWinsorize, nil
+	case "drop":
+		return OutlierDrop, nil
+	default:
+		return OutlierNone, fmt.Errorf("%w %q (want none, winsorize or drop)", ErrUnknownOutlierPolicy, s)
+	}
+}
+
+// samples returns the answered RTTs of rtts as they enter the statistics
+// under the policy, in probe order, and the number of them it clipped or
+// dropped. Timeouts (negative values) are left out.
+func (p OutlierPolicy) samples(rtts []float64) ([]float64, int) {
+	var valid []float64
+	for _, rtt := range rtts {
+		if rtt >= 0 {
+			valid = append(valid, rtt)
+		}
+	}
+	if p == OutlierNone || len(valid) < minOutlierSamples {
+		return valid, 0
+	}
+
+	sorted := slices.Sorted(slices.Values(valid))
+	affected := 0
+	switch p {
+	case OutlierWinsorize:
+		limit := percentile(sorted, OutlierPercentile)
+		for i, rtt := range valid {
+			if rtt > limit {
+				valid[i] = limit
+				affected++
+			}
+		}
+	case OutlierDrop:
+		q1, q3 := percentile(sorted, 25), percentile(sorted, 75)
+		fence := OutlierIQRFactor * (q3 - q1)
+		kept := valid[:0]
+		for _, rtt := range valid {
+			if rtt < q1-fence || rtt > q3+fence {
+				affected++
+				continue
+			}
+			kept = append(kept, rtt)
+		}
+		valid = kept
+	}
+	return valid, affected
+}
+
+// percentile returns the pth percentile (0-100) of sorted, interpolating
+// linearly between the two nearest samples.
+func percentile(sorted []float64, p float64) float64 {
+	pos := p / 100 * float64(len(sorted)-1)
+	i := int(pos)
+	if i >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[i] + (pos-float64(i))*(sorted[i+1]-sorted[i])
+}
@@ -0,0 +1,205 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/KilimcininKorOglu/poros/internal/probe/probetest"
+)
+
+func TestOutlierPolicy_Samples(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   OutlierPolicy
+		rtts     []float64
+		want     []float64
+		affected int
+	}{
+		{
+			name:   "none keeps a spike",
+			policy: OutlierNone,
+			rtts:   []float64{10, 12, 11, 13, 100},
+			want:   []float64{10, 12, 11, 13, 100},
+		},
+		{
+			name:     "winsorize clips at p95",
+			policy:   OutlierWinsorize,
+			rtts:     []float64{10, 12, 11, 13, 100},
+			want:     []float64{10, 12, 11, 13, 82.6}, // 13 + 0.8×(100-13)
+			affected: 1,
+		},
+		{
+			name:   "winsorize keeps spikes above 5% of samples",
+			policy: OutlierWinsorize,
+			rtts:   []float64{3000, 10, 3000, 10, 10, 10, 10, 10, 10, 10},
+			want:   []float64{3000, 10, 3000, 10, 10, 10, 10, 10, 10, 10},
+		},
+		{
+			name:     "drop beyond 1.5×IQR",
+			policy:   OutlierDrop,
+			rtts:     []float64{10, 12, 11, 13, 100},
+			want:     []float64{10, 12, 11, 13},
+			affected: 1,
+		},
+		{
+			name:     "drop both tails",
+			policy:   OutlierDrop,
+			rtts:     []float64{50, 1, 50, 50, 200, 50},
+			want:     []float64{50, 50, 50, 50},
+			affected: 2,
+		},
+		{
+			name:   "drop keeps a steady hop",
+			policy: OutlierDrop,
+			rtts:   []float64{10, 11, 12, 13, 14, 15},
+			want:   []float64{10, 11, 12, 13, 14, 15},
+		},
+		{
+			name:     "timeouts are left out",
+			policy:   OutlierDrop,
+			rtts:     []float64{-1, 10, 12, 11, -1, 13, 100},
+			want:     []float64{10, 12, 11, 13},
+			affected: 1,
+		},
+		{
+			name:   "too few samples",
+			policy: OutlierWinsorize,
+			rtts:   []float64{10, 11, 3000, -1},
+			want:   []float64{10, 11, 3000},
+		},
+		{
+			name:   "no replies",
+			policy: OutlierDrop,
+			rtts:   []float64{-1, -1, -1, -1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := slices.Clone(tt.rtts)
+			got, affected := tt.policy.samples(tt.rtts)
+			if !slices.EqualFunc(got, tt.want, approxEqual) {
+				t.Errorf("samples() = %v, want %v", got, tt.want)
+			}
+			if affected != tt.affected {
+				t.Errorf("affected = %d, want %d", affected, tt.affected)
+			}
+			if !slices.Equal(tt.rtts, raw) {
+				t.Errorf("samples() modified its input: %v, want %v", tt.rtts, raw)
+			}
+		})
+	}
+}
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []float64{10, 20, 30, 40, 50}
+	for p, want := range map[float64]float64{0: 10, 25: 20, 50: 30, 95: 48, 100: 50} {
+		if got := percentile(sorted, p); !approxEqual(got, want) {
+			t.Errorf("percentile(%v) = %v, want %v", p, got, want)
+		}
+	}
+	if got := percentile([]float64{7}, 95); got != 7 {
+		t.Errorf("percentile of one sample = %v, want 7", got)
+	}
+}
+
+func TestParseOutlierPolicy(t *testing.T) {
+	for _, p := range []OutlierPolicy{OutlierNone, OutlierWinsorize, OutlierDrop} {
+		got, err := ParseOutlierPolicy(" " + p.String() + " ")
+		if err != nil || got != p {
+			t.Errorf("ParseOutlierPolicy(%q) = %v, %v", p.String(), got, err)
+		}
+	}
+	if got, err := ParseOutlierPolicy(""); err != nil || got != OutlierNone {
+		t.Errorf("ParseOutlierPolicy(\"\") = %v, %v, want none", got, err)
+	}
+	if _, err := ParseOutlierPolicy("trim"); !errors.Is(err, ErrUnknownOutlierPolicy) {
+		t.Errorf("ParseOutlierPolicy(\"trim\") error = %v, want ErrUnknownOutlierPolicy", err)
+	}
+}
+
+func TestHop_UpdateStatsWith(t *testing.T) {
+	rtts := []float64{10, 12, -1, 11, 13, 100}
+	hop := Hop{RTTs: slices.Clone(rtts)}
+
+	hop.UpdateStatsWith(OutlierDrop)
+	if hop.AvgRTT != 11.5 || hop.MinRTT != 10 || hop.MaxRTT != 13 || hop.Jitter != 3 {
+		t.Errorf("avg/min/max/jitter = %v/%v/%v/%v, want 11.5/10/13/3", hop.AvgRTT, hop.MinRTT, hop.MaxRTT, hop.Jitter)
+	}
+	if hop.Outliers != 1 {
+		t.Errorf("Outliers = %d, want 1", hop.Outliers)
+	}
+	// The raw samples, the last one and the loss are unaffected
+	if !slices.Equal(hop.RTTs, rtts) || hop.LastRTT != 100 || !approxEqual(hop.LossPercent, 100.0/6) {
+		t.Errorf("RTTs/last/loss = %v/%v/%v, want raw samples, 100 and 16.7", hop.RTTs, hop.LastRTT, hop.LossPercent)
+	}
+
+	// Recalculating without a policy restores the raw statistics
+	hop.UpdateStats()
+	if hop.MaxRTT != 100 || hop.Outliers != 0 {
+		t.Errorf("max/outliers = %v/%d after UpdateStats, want 100/0", hop.MaxRTT, hop.Outliers)
+	}
+}
+
+func TestTracer_RTTOutlier(t *testing.T) {
+	for _, policy := range []OutlierPolicy{OutlierNone, OutlierWinsorize, OutlierDrop} {
+		t.Run(policy.String(), func(t *testing.T) {
+			config := DefaultConfig()
+			config.ProbeMethod = ProbeUDP
+			config.MaxHops = 3
+			config.ProbeCount = 5
+			config.RTTOutlier = policy
+			config.EnableEnrichment = false
+
+			// A 3 s retransmission artifact at hop 2
+			prober := probetest.NewScriptedProber().
+				Hop(1, "192.0.2.1", 1, 1, 1, 1, 1).
+				Hop(2, "192.0.2.2", 10, 3000, 11, 12, 13).
+				Hop(3, "8.8.8.8", 20, 20, 20, 20, 20)
+			tracer, err := NewWithProber(config, prober)
+			if err != nil {
+				t.Fatalf("NewWithProber() error = %v", err)
+			}
+			defer tracer.Close()
+
+			result, err := tracer.Trace(context.Background(), "8.8.8.8")
+			if err != nil {
+				t.Fatalf("Trace() error = %v", err)
+			}
+
+			hop := result.Hops[1]
+			if !slices.Equal(hop.RTTs, []float64{10, 3000, 11, 12, 13}) {
+				t.Errorf("RTTs = %v, want the raw samples", hop.RTTs)
+			}
+			wantMax, wantOutliers := map[OutlierPolicy]float64{
+				OutlierNone:      3000,
+				OutlierWinsorize: 13 + 0.8*(3000-13),
+				OutlierDrop:      13,
+			}[policy], 1
+			if policy == OutlierNone {
+				wantOutliers = 0
+			}
+			if !approxEqual(hop.MaxRTT, wantMax) || hop.Outliers != wantOutliers {
+				t.Errorf("max/outliers = %v/%d, want %v/%d", hop.MaxRTT, hop.Outliers, wantMax, wantOutliers)
+			}
+			if result.Hops[0].Outliers != 0 || result.Hops[2].Outliers != 0 {
+				t.Errorf("steady hops have outliers: %d, %d", result.Hops[0].Outliers, result.Hops[2].Outliers)
+			}
+
+			wantMeta := policy.String()
+			if policy == OutlierNone {
+				wantMeta = ""
+			}
+			if result.Meta.RTTOutlier != wantMeta {
+				t.Errorf("Meta.RTTOutlier = %q, want %q", result.Meta.RTTOutlier, wantMeta)
+			}
+		})
+	}
+}
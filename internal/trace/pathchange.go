@@ -0,0 +1,23 @@
+package trace
+
+import "time"
+
+// PathChange is a hop answering from a different address than it last
+// did, such as a route flipping between upstreams mid-session.
+type PathChange struct {
+	Time time.Time `json:"time"`
+	Hop  int       `json:"hop"`
+	From string    `json:"from"`
+	To   string    `json:"to"`
+}
+
+// DetectPathChange returns the change, timestamped at, of a hop that
+// last answered as last and now as hop. It reports false if either did
+// not answer or both answered from the same address: a timeout is not a
+// change.
+func DetectPathChange(last, hop *Hop, at time.Time) (PathChange, bool) {
+	if !hop.Responded || hop.IP == nil || last.IP == nil || last.IP.Equal(hop.IP) {
+		return PathChange{}, false
+	}
+	return PathChange{Time: at, Hop: hop.Number, From: last.IP.String(), To: hop.IP.String()}, true
+}
@@ -0,0 +1,83 @@
+package trace
+
+import (
+	"context"
+	"sync"
+)
+
+// PauseGate holds back probing while paused, e.g. while an interactive
+// user reads the display. Probes already sent are not affected and their
+// replies are still recorded; the next probe waits until Resume. A nil
+// PauseGate never pauses. It is safe for concurrent use.
+type PauseGate struct {
+	mu     sync.Mutex
+	paused bool
+	resume chan struct{} // closed on Resume
+}
+
+// NewPauseGate creates a gate in the running state.
+func NewPauseGate() *PauseGate {
+	return &PauseGate{}
+}
+
+// Pause stops further probes from being sent.
+func (g *PauseGate) Pause() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.paused {
+		g.paused = true
+		g.resume = make(chan struct{})
+	}
+}
+
+// Resume lets waiting probes continue.
+func (g *PauseGate) Resume() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.paused {
+		g.paused = false
+		close(g.resume)
+	}
+}
+
+// Toggle pauses a running gate or resumes a paused one, and reports
+// whether the gate is now paused.
+func (g *PauseGate) Toggle() bool {
+	if g.Paused() {
+		g.Resume()
+		return false
+	}
+	g.Pause()
+	return true
+}
+
+// Paused reports whether the gate is paused.
+func (g *PauseGate) Paused() bool {
+	if g == nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.paused
+}
+
+// Wait blocks while the gate is paused. It returns the context's error if
+// the context ends first.
+func (g *PauseGate) Wait(ctx context.Context) error {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	paused, resume := g.paused, g.resume
+	g.mu.Unlock()
+	if !paused {
+		return nil
+	}
+
+	select {
+	case <-resume:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,107 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/probe"
+)
+
+func TestPauseGate(t *testing.T) {
+	var nilGate *PauseGate
+	if nilGate.Paused() || nilGate.Wait(context.Background()) != nil {
+		t.Error("a nil gate should never pause")
+	}
+
+	g := NewPauseGate()
+	if !g.Toggle() || !g.Paused() {
+		t.Fatal("Toggle() should pause a running gate")
+	}
+	g.Pause() // pausing twice is harmless
+
+	done := make(chan error, 1)
+	go func() { done <- g.Wait(context.Background()) }()
+	select {
+	case <-done:
+		t.Fatal("Wait() returned while paused")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	if g.Toggle() {
+		t.Fatal("Toggle() should resume a paused gate")
+	}
+	g.Resume() // resuming twice is harmless
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Wait() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wait() did not return after Resume()")
+	}
+
+	g.Pause()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := g.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait() error = %v, want the context's error", err)
+	}
+}
+
+func TestTracer_Pause(t *testing.T) {
+	dest := net.ParseIP("8.8.8.8")
+	gate := NewPauseGate()
+
+	// The first probe pauses the trace; its own reply is still recorded
+	var sent atomic.Int32
+	prober := newFuncProber(func(ttl, call int) (*probe.Result, error) {
+		if sent.Add(1) == 1 {
+			gate.Pause()
+		}
+		ip := net.ParseIP("192.0.2.1")
+		if ttl == 2 {
+			ip = dest
+		}
+		return &probe.Result{ResponseIP: ip, RTT: time.Millisecond}, nil
+	})
+
+	config := DefaultConfig()
+	config.Sequential = true
+	config.EnableEnrichment = false
+	config.Pause = gate
+	tracer := &Tracer{config: config, prober: prober}
+
+	done := make(chan *TraceResult, 1)
+	go func() {
+		result, err := tracer.Trace(context.Background(), dest.String())
+		if err != nil {
+			t.Errorf("Trace() error = %v", err)
+		}
+		done <- result
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	if n := sent.Load(); n != 1 {
+		t.Fatalf("%d probes sent while paused, want 1", n)
+	}
+
+	gate.Resume()
+	select {
+	case result := <-done:
+		if result == nil || !result.Completed || len(result.Hops) != 2 {
+			t.Fatalf("Trace() = %+v, want completed in 2 hops", result)
+		}
+		if hop := result.Hops[0]; hop.LossPercent != 0 || len(hop.RTTs) != config.ProbeCount {
+			t.Errorf("hop 1 = %+v, want every probe answered after resuming", hop)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Trace() did not finish after Resume()")
+	}
+	if n := int(sent.Load()); n != 2*config.ProbeCount {
+		t.Errorf("%d probes sent, want %d", n, 2*config.ProbeCount)
+	}
+}
@@ -0,0 +1,142 @@
+package trace
+
+import "sync"
+
+// Phase names the step a trace is in, for progress reporting.
+type Phase string
+
+// Trace phases, in the order a trace goes through them. Formatting the
+// result happens after Trace returns and is left to the caller.
+const (
+	PhaseIdle      Phase = ""          // no trace running yet
+	PhaseResolving Phase = "resolving" // resolving the target
+	PhaseProbing   Phase = "probing"   // sending probes
+	PhaseEnriching Phase = "enriching" // rDNS, ASN and GeoIP lookups
+	PhaseDone      Phase = "done"      // Trace has returned
+)
+
+// Progress is a snapshot of a trace's progress.
+//
+// Planned is the number of probes the trace expects to send: every
+// probe of every TTL up to the last hop, plus the destination
+// verification probes. It only shrinks as the trace learns more, e.g.
+// when the destination answers early, the packet budget runs out or a
+// private prefix is skipped, and it never drops below Completed. The
+// percentage therefore never goes down and is 100 once probing ends.
+type Progress struct {
+	Phase     Phase `json:"phase"`
+	Planned   int   `json:"planned"`   // probes expected in total
+	Completed int   `json:"completed"` // probes answered or timed out
+}
+
+// Percent returns the share of planned probes completed, from 0 to 100.
+func (p Progress) Percent() float64 {
+	if p.Planned <= 0 {
+		if p.Phase == PhaseDone {
+			return 100
+		}
+		return 0
+	}
+	percent := float64(p.Completed) / float64(p.Planned) * 100
+	if percent > 100 {
+		percent = 100
+	}
+	return percent
+}
+
+// progressTracker records the progress of the running trace and reports
+// changes to Config.OnProgress. The zero value is ready to use.
+type progressTracker struct {
+	// notifyMu keeps callbacks in the order of the changes they report
+	notifyMu sync.Mutex
+	mu       sync.Mutex
+	p        Progress
+}
+
+// update applies fn to the progress and reports the result to onChange.
+func (pt *progressTracker) update(onChange func(Progress), fn func(p *Progress)) {
+	pt.notifyMu.Lock()
+	defer pt.notifyMu.Unlock()
+
+	pt.mu.Lock()
+	fn(&pt.p)
+	if pt.p.Planned < pt.p.Completed {
+		pt.p.Planned = pt.p.Completed
+	}
+	p := pt.p
+	pt.mu.Unlock()
+
+	if onChange != nil {
+		onChange(p)
+	}
+}
+
+func (pt *progressTracker) snapshot() Progress {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	return pt.p
+}
+
+// Progress returns the progress of the running or last trace. It is safe
+// to call while a trace is running.
+func (t *Tracer) Progress() Progress {
+	return t.progress.snapshot()
+}
+
+// setPhase starts phase; starting PhaseResolving resets the counters for
+// a new trace, and PhaseDone shrinks the plan to the probes sent.
+func (t *Tracer) setPhase(phase Phase) {
+	t.progress.update(t.config.OnProgress, func(p *Progress) {
+		if phase == PhaseResolving {
+			*p = Progress{}
+		}
+		if phase == PhaseDone {
+			p.Planned = p.Completed
+		}
+		p.Phase = phase
+	})
+}
+
+// planProbes sets the probes planned for the whole trace.
+func (t *Tracer) planProbes(firstTTL int) {
+	t.progress.update(t.config.OnProgress, func(p *Progress) {
+		p.Planned = t.remainingProbes(firstTTL)
+	})
+}
+
+// replanProbes shrinks the plan to the probes sent so far plus remaining;
+// the plan never grows.
+func (t *Tracer) replanProbes(remaining int) {
+	t.progress.update(t.config.OnProgress, func(p *Progress) {
+		if planned := p.Completed + remaining; planned < p.Planned {
+			p.Planned = planned
+		}
+	})
+}
+
+// remainingProbes returns the probes still to send when probing starts
+// at nextTTL, including the destination hop's extra probes and
+// destination verification.
+func (t *Tracer) remainingProbes(nextTTL int) int {
+	probes := 0
+	if ttls := t.config.lastTTL() - nextTTL + 1; ttls > 0 {
+		probes = ttls*t.config.ProbeCount + t.config.destTopUp()
+	}
+	return probes + t.verifyProbes()
+}
+
+// verifyProbes returns the destination verification probes the trace
+// will send.
+func (t *Tracer) verifyProbes() int {
+	if t.config.LastHop > 0 {
+		return 0
+	}
+	return t.config.VerifyDest
+}
+
+// probeCompleted counts a probe that was answered or timed out.
+func (t *Tracer) probeCompleted() {
+	t.progress.update(t.config.OnProgress, func(p *Progress) {
+		p.Completed++
+	})
+}
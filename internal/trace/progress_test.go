@@ -0,0 +1,95 @@
+package trace
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestTracer_Progress(t *testing.T) {
+	tests := []struct {
+		name          string
+		sequential    bool
+		verifyDest    int
+		maxPackets    int
+		wantCompleted int
+	}{
+		{"sequential, early destination", true, 0, 0, 9},
+		{"sequential with verification", true, 5, 0, 14},
+		{"sequential, packet budget", true, 0, 4, 4},
+		{"concurrent", false, 0, 0, 30},
+		{"concurrent with verification", false, 5, 0, 35},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prober := newScriptedProber("8.8.8.8", map[int]string{
+				1: "192.0.2.1",
+				2: "",
+				3: "8.8.8.8",
+			})
+
+			var mu sync.Mutex
+			var updates []Progress
+			config := DefaultConfig()
+			config.ProbeMethod = ProbeUDP
+			config.MaxHops = 10
+			config.Sequential = tt.sequential
+			config.VerifyDest = tt.verifyDest
+			config.MaxPackets = tt.maxPackets
+			config.PacketsPerSecond = 1000
+			config.EnableEnrichment = false
+			config.OnProgress = func(p Progress) {
+				mu.Lock()
+				updates = append(updates, p)
+				mu.Unlock()
+			}
+			tracer := &Tracer{config: config, prober: prober}
+
+			if _, err := tracer.Trace(context.Background(), "8.8.8.8"); err != nil {
+				t.Fatalf("Trace() error = %v", err)
+			}
+
+			if len(updates) == 0 || updates[0].Phase != PhaseResolving {
+				t.Fatalf("first update = %+v, want the resolving phase", updates)
+			}
+			last := 0.0
+			for _, p := range updates {
+				if p.Completed > p.Planned {
+					t.Errorf("update %+v: completed exceeds planned", p)
+				}
+				if pct := p.Percent(); pct < last {
+					t.Errorf("update %+v: percent went down from %.1f to %.1f", p, last, pct)
+				} else {
+					last = pct
+				}
+			}
+
+			final := tracer.Progress()
+			if final != updates[len(updates)-1] {
+				t.Errorf("Progress() = %+v, want the last update %+v", final, updates[len(updates)-1])
+			}
+			if final.Phase != PhaseDone || final.Completed != tt.wantCompleted || final.Percent() != 100 {
+				t.Errorf("final progress = %+v (%.1f%%), want done with %d probes at 100%%",
+					final, final.Percent(), tt.wantCompleted)
+			}
+		})
+	}
+}
+
+func TestProgress_Percent(t *testing.T) {
+	tests := []struct {
+		p    Progress
+		want float64
+	}{
+		{Progress{}, 0},
+		{Progress{Phase: PhaseProbing, Planned: 90, Completed: 9}, 10},
+		{Progress{Phase: PhaseProbing, Planned: 4, Completed: 5}, 100},
+		{Progress{Phase: PhaseDone}, 100},
+	}
+	for _, tt := range tests {
+		if got := tt.p.Percent(); got != tt.want {
+			t.Errorf("%+v.Percent() = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+}
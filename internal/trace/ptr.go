@@ -0,0 +1,52 @@
+package trace
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+)
+
+// targetPTRTimeout bounds the reverse lookup of the destination, which
+// runs alongside the trace.
+const targetPTRTimeout = time.Second
+
+// PTRResolver looks up the PTR names of an address. *net.Resolver
+// implements it.
+type PTRResolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+}
+
+// ptrResolver returns the configured PTR resolver or the system resolver.
+func (t *Tracer) ptrResolver() PTRResolver {
+	if t.config.PTRResolver != nil {
+		return t.config.PTRResolver
+	}
+	return net.DefaultResolver
+}
+
+// lookupTargetPTR starts the reverse lookup of dest and returns a channel
+// delivering its PTR name, or "" if there is none, the lookup fails or
+// times out, or rDNS is disabled. A PTR repeating the target is dropped.
+func (t *Tracer) lookupTargetPTR(ctx context.Context, target string, dest net.IP) <-chan string {
+	ch := make(chan string, 1)
+	if !t.config.EnableEnrichment || !t.config.EnableRDNS {
+		ch <- ""
+		return ch
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, targetPTRTimeout)
+		defer cancel()
+
+		var name string
+		if names, err := t.ptrResolver().LookupAddr(ctx, dest.String()); err == nil && len(names) > 0 {
+			name = strings.TrimSuffix(names[0], ".")
+		}
+		if strings.EqualFold(name, strings.TrimSuffix(target, ".")) {
+			name = ""
+		}
+		ch <- name
+	}()
+	return ch
+}
@@ -0,0 +1,94 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/probe"
+)
+
+// fakePTRResolver answers reverse lookups from a fixed table; block makes
+// it wait for the lookup's deadline instead.
+type fakePTRResolver struct {
+	names map[string][]string
+	block bool
+	calls atomic.Int64
+}
+
+func (r *fakePTRResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	r.calls.Add(1)
+	if r.block {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if names, ok := r.names[addr]; ok {
+		return names, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: addr, IsNotFound: true}
+}
+
+func TestTracer_TargetPTR(t *testing.T) {
+	dest := net.ParseIP("192.0.2.9")
+	names := map[string][]string{"192.0.2.9": {"dns.example.", "alt.example."}}
+
+	tests := []struct {
+		name      string
+		target    string
+		resolver  *fakePTRResolver
+		noRDNS    bool
+		want      string
+		wantCalls int64
+	}{
+		{"IP literal", "192.0.2.9", &fakePTRResolver{names: names}, false, "dns.example", 1},
+		{"no PTR", "192.0.2.9", &fakePTRResolver{}, false, "", 1},
+		{"PTR repeats target", "dns.example", &fakePTRResolver{names: names}, false, "", 1},
+		{"timeout", "192.0.2.9", &fakePTRResolver{block: true}, false, "", 1},
+		{"rDNS disabled", "192.0.2.9", &fakePTRResolver{names: names}, true, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prober := newFuncProber(func(ttl, call int) (*probe.Result, error) {
+				return &probe.Result{ResponseIP: dest, RTT: time.Millisecond}, nil
+			})
+
+			config := DefaultConfig()
+			config.ProbeMethod = ProbeUDP
+			config.Sequential = true
+			config.EnableRDNS = !tt.noRDNS
+			config.Resolver = staticResolver{"dns.example": {dest}}
+			config.PTRResolver = tt.resolver
+			tracer := &Tracer{config: config, prober: prober}
+
+			start := time.Now()
+			result, err := tracer.Trace(context.Background(), tt.target)
+			if err != nil {
+				t.Fatalf("Trace() error = %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > targetPTRTimeout+time.Second {
+				t.Errorf("Trace() took %v, want the PTR lookup bounded by %v", elapsed, targetPTRTimeout)
+			}
+
+			if result.TargetPTR != tt.want {
+				t.Errorf("TargetPTR = %q, want %q", result.TargetPTR, tt.want)
+			}
+			if got := tt.resolver.calls.Load(); got != tt.wantCalls {
+				t.Errorf("PTR lookups = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+// staticResolver resolves host names from a fixed table.
+type staticResolver map[string][]net.IP
+
+func (r staticResolver) Resolve(ctx context.Context, network, host string) ([]net.IP, time.Duration, error) {
+	if ips, ok := r[host]; ok {
+		return ips, 0, nil
+	}
+	return nil, 0, errors.New("no such host")
+}
@@ -0,0 +1,52 @@
+package trace
+
+import (
+	"fmt"
+
+	"github.com/KilimcininKorOglu/poros/internal/probe"
+)
+
+// redirectCount returns how many redirects the prober has recorded, so a
+// trace reports only those received while it ran.
+func (t *Tracer) redirectCount() int {
+	if rr, ok := t.prober.(probe.RedirectReporter); ok {
+		return len(rr.Redirects())
+	}
+	return 0
+}
+
+// redirectsSince returns the redirects recorded after the first base,
+// merged per router and suggested gateway in the order first seen.
+func (t *Tracer) redirectsSince(base int) []Redirect {
+	rr, ok := t.prober.(probe.RedirectReporter)
+	if !ok {
+		return nil
+	}
+	recorded := rr.Redirects()
+	if base > len(recorded) {
+		return nil
+	}
+
+	var redirects []Redirect
+	index := make(map[string]int)
+	for _, r := range recorded[base:] {
+		key := r.Router.String() + ">" + r.Gateway.String()
+		if i, ok := index[key]; ok {
+			redirects[i].Count++
+			continue
+		}
+		index[key] = len(redirects)
+		redirects = append(redirects, Redirect{Router: r.Router, Gateway: r.Gateway, Count: 1})
+	}
+	return redirects
+}
+
+// redirectNote describes a redirect for TraceResult.Notes.
+func redirectNote(r Redirect) string {
+	probes := "1 probe"
+	if r.Count != 1 {
+		probes = fmt.Sprintf("%d probes", r.Count)
+	}
+	return fmt.Sprintf("ICMP redirect from %s suggests gateway %s (%s); routing was not changed",
+		r.Router, r.Gateway, probes)
+}
@@ -0,0 +1,79 @@
+package trace
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/probe"
+)
+
+// redirectProber is a funcProber whose first-hop router redirects every
+// probe.
+type redirectProber struct {
+	*funcProber
+	probe.RedirectLog
+}
+
+func TestTracer_Redirects(t *testing.T) {
+	router := net.ParseIP("192.168.1.1")
+	gateway := net.ParseIP("192.168.1.254")
+	dest := net.ParseIP("8.8.8.8")
+
+	prober := &redirectProber{}
+	prober.funcProber = newFuncProber(func(ttl, call int) (*probe.Result, error) {
+		prober.RecordRedirect(probe.Redirect{Router: router, Gateway: gateway, Code: 1})
+		if ttl == 1 {
+			return &probe.Result{ResponseIP: router, RTT: time.Millisecond, TTLExpired: true}, nil
+		}
+		return &probe.Result{ResponseIP: dest, RTT: 2 * time.Millisecond, Reached: true}, nil
+	})
+
+	// A redirect left over from an earlier trace is not reported again
+	prober.RecordRedirect(probe.Redirect{Router: router, Gateway: net.ParseIP("192.168.1.253")})
+
+	config := DefaultConfig()
+	config.ProbeMethod = ProbeUDP
+	config.Sequential = true
+	config.EnableEnrichment = false
+	tracer := &Tracer{config: config, prober: prober}
+
+	result, err := tracer.Trace(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("Trace() error = %v", err)
+	}
+
+	if len(result.Redirects) != 1 {
+		t.Fatalf("Redirects = %+v, want one entry", result.Redirects)
+	}
+	r := result.Redirects[0]
+	if !r.Router.Equal(router) || !r.Gateway.Equal(gateway) || r.Count != 2*config.ProbeCount {
+		t.Errorf("Redirects[0] = %+v, want %s -> %s for %d probes", r, router, gateway, 2*config.ProbeCount)
+	}
+
+	var noted bool
+	for _, note := range result.Notes {
+		noted = noted || strings.Contains(note, "ICMP redirect from 192.168.1.1 suggests gateway 192.168.1.254")
+	}
+	if !noted {
+		t.Errorf("Notes = %q, want the redirect", result.Notes)
+	}
+}
+
+func TestTracer_NoRedirects(t *testing.T) {
+	config := DefaultConfig()
+	config.ProbeMethod = ProbeUDP
+	config.Sequential = true
+	config.EnableEnrichment = false
+	tracer := &Tracer{config: config, prober: newScriptedProber("8.8.8.8", map[int]string{1: "8.8.8.8"})}
+
+	result, err := tracer.Trace(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("Trace() error = %v", err)
+	}
+	if result.Redirects != nil {
+		t.Errorf("Redirects = %+v for a prober that does not report them", result.Redirects)
+	}
+}
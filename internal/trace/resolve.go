@@ -0,0 +1,335 @@
+package trace
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ResolvePolicy controls how often a Tracer re-resolves a hostname target
+// when it is reused for repeated traces.
+type ResolvePolicy int
+
+const (
+	// ResolveEveryCycle resolves the target on every trace
+	ResolveEveryCycle ResolvePolicy = iota
+	// ResolveOnce pins the first resolved address for the Tracer's lifetime
+	ResolveOnce
+	// ResolveTTL re-resolves once the DNS TTL of the last answer expires
+	ResolveTTL
+)
+
+// String returns the string representation of the resolve policy.
+func (p ResolvePolicy) String() string {
+	switch p {
+	case ResolveEveryCycle:
+		return "every"
+	case ResolveOnce:
+		return "once"
+	case ResolveTTL:
+		return "ttl"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseResolvePolicy parses a resolve policy name.
+func ParseResolvePolicy(s string) (ResolvePolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "every", "every-cycle":
+		return ResolveEveryCycle, nil
+	case "once":
+		return ResolveOnce, nil
+	case "ttl":
+		return ResolveTTL, nil
+	default:
+		return ResolveEveryCycle, fmt.Errorf("%w %q (want every, once or ttl)", ErrUnknownResolvePolicy, s)
+	}
+}
+
+// Resolver looks up the addresses of a host together with the DNS TTL
+// of the answer. A zero TTL means the TTL is unknown.
+type Resolver interface {
+	Resolve(ctx context.Context, network, host string) ([]net.IP, time.Duration, error)
+}
+
+// pinnedAddr is a resolved address kept across traces.
+type pinnedAddr struct {
+	ip      net.IP
+	expires time.Time // zero = never (ResolveOnce)
+}
+
+// goResolver looks up addresses with the Go resolver, which does not
+// report the answer's TTL.
+type goResolver struct{}
+
+// Resolve implements Resolver.
+func (goResolver) Resolve(ctx context.Context, network, host string) ([]net.IP, time.Duration, error) {
+	ips, err := net.DefaultResolver.LookupIP(ctx, network, host)
+	return ips, 0, err
+}
+
+// systemResolver queries the configured nameservers directly so the
+// answer's TTL is available, falling back to the Go resolver if that fails.
+type systemResolver struct{}
+
+// Resolve implements Resolver.
+func (systemResolver) Resolve(ctx context.Context, network, host string) ([]net.IP, time.Duration, error) {
+	if ips, ttl, err := queryWithTTL(ctx, network, host); err == nil && len(ips) > 0 {
+		return ips, ttl, nil
+	}
+
+	ips, err := net.DefaultResolver.LookupIP(ctx, network, host)
+	return ips, 0, err
+}
+
+// queryWithTTL sends raw A/AAAA queries to the nameservers in
+// /etc/resolv.conf, in order until one answers, and returns the addresses
+// with the lowest answer TTL. A name with fewer dots than ndots is left
+// to the Go resolver, which tries the search domains first.
+func queryWithTTL(ctx context.Context, network, host string) ([]net.IP, time.Duration, error) {
+	conf, err := systemResolvConf()
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(conf.nameservers) == 0 {
+		return nil, 0, fmt.Errorf("no nameserver in /etc/resolv.conf")
+	}
+	if !strings.HasSuffix(host, ".") && strings.Count(host, ".") < conf.ndots {
+		return nil, 0, fmt.Errorf("%s needs the search domains", host)
+	}
+
+	var types []dnsmessage.Type
+	switch network {
+	case "ip4":
+		types = []dnsmessage.Type{dnsmessage.TypeA}
+	case "ip6":
+		types = []dnsmessage.Type{dnsmessage.TypeAAAA}
+	default:
+		types = []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA}
+	}
+
+	for _, server := range conf.nameservers {
+		if ctx.Err() != nil {
+			return nil, 0, ctx.Err()
+		}
+		if ips, ttl := queryServer(ctx, server, host, types); len(ips) > 0 {
+			return ips, ttl, nil
+		}
+	}
+	return nil, 0, fmt.Errorf("no answers for %s from %s", host, strings.Join(conf.nameservers, ", "))
+}
+
+// queryServer queries server for each record type of host and returns
+// the addresses answered with their lowest TTL.
+func queryServer(ctx context.Context, server, host string, types []dnsmessage.Type) ([]net.IP, time.Duration) {
+	var ips []net.IP
+	var minTTL time.Duration
+	for _, qtype := range types {
+		answers, ttl, err := exchangeDNS(ctx, server, host, qtype)
+		if err != nil {
+			continue
+		}
+		ips = append(ips, answers...)
+		if len(answers) > 0 && (minTTL == 0 || ttl < minTTL) {
+			minTTL = ttl
+		}
+	}
+	return ips, minTTL
+}
+
+// exchangeDNS performs a single UDP DNS query.
+func exchangeDNS(ctx context.Context, server, host string, qtype dnsmessage.Type) ([]net.IP, time.Duration, error) {
+	name, err := dnsmessage.NewName(dnsFQDN(host))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	id := uint16(rand.IntN(1 << 16))
+	query := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{
+			{Name: name, Type: qtype, Class: dnsmessage.ClassINET},
+		},
+	}
+	packet, err := query.Pack()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", net.JoinHostPort(server, "53"))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(deadline) {
+		deadline = dl
+	}
+	conn.SetDeadline(deadline)
+
+	if _, err := conn.Write(packet); err != nil {
+		return nil, 0, err
+	}
+
+	buf := make([]byte, 1500)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return parseDNSAnswer(buf[:n], id)
+}
+
+// parseDNSAnswer extracts A/AAAA records and the lowest TTL from a response.
+func parseDNSAnswer(packet []byte, id uint16) ([]net.IP, time.Duration, error) {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(packet); err != nil {
+		return nil, 0, err
+	}
+	if msg.Header.ID != id {
+		return nil, 0, fmt.Errorf("DNS response ID mismatch")
+	}
+	if msg.Header.RCode != dnsmessage.RCodeSuccess {
+		return nil, 0, fmt.Errorf("DNS error: %s", msg.Header.RCode)
+	}
+
+	var ips []net.IP
+	var minTTL uint32
+	for _, rr := range msg.Answers {
+		var ip net.IP
+		switch body := rr.Body.(type) {
+		case *dnsmessage.AResource:
+			ip = net.IP(body.A[:])
+		case *dnsmessage.AAAAResource:
+			ip = net.IP(body.AAAA[:])
+		default:
+			continue
+		}
+		ips = append(ips, ip)
+		if len(ips) == 1 || rr.Header.TTL < minTTL {
+			minTTL = rr.Header.TTL
+		}
+	}
+
+	return ips, time.Duration(minTTL) * time.Second, nil
+}
+
+// resolvConf is the part of resolv.conf that queryWithTTL follows.
+type resolvConf struct {
+	nameservers []string
+	ndots       int
+}
+
+// systemResolvConf reads /etc/resolv.conf.
+func systemResolvConf() (resolvConf, error) {
+	f, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return resolvConf{}, err
+	}
+	defer f.Close()
+	return parseResolvConf(f), nil
+}
+
+// parseResolvConf parses the nameservers, in order, and the ndots option
+// (default 1) of a resolv.conf file.
+func parseResolvConf(r io.Reader) resolvConf {
+	conf := resolvConf{ndots: 1}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "nameserver":
+			conf.nameservers = append(conf.nameservers, fields[1])
+		case "options":
+			for _, option := range fields[1:] {
+				if value, ok := strings.CutPrefix(option, "ndots:"); ok {
+					if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+						conf.ndots = min(n, 15)
+					}
+				}
+			}
+		}
+	}
+	return conf
+}
+
+// dnsFQDN appends the trailing dot required by dnsmessage.
+func dnsFQDN(host string) string {
+	if strings.HasSuffix(host, ".") {
+		return host
+	}
+	return host + "."
+}
+
+// lookupPinned returns the pinned address for target if it is still valid
+// under the configured policy.
+func (t *Tracer) lookupPinned(target string) net.IP {
+	if t.config.ResolvePolicy == ResolveEveryCycle {
+		return nil
+	}
+
+	t.pinMu.Lock()
+	defer t.pinMu.Unlock()
+
+	p, ok := t.pinned[target]
+	if !ok {
+		return nil
+	}
+	if !p.expires.IsZero() && !time.Now().Before(p.expires) {
+		delete(t.pinned, target)
+		return nil
+	}
+	return p.ip
+}
+
+// pin stores the resolved address for target according to the policy.
+// Under ResolveTTL an unknown (zero) TTL is not pinned.
+func (t *Tracer) pin(target string, ip net.IP, ttl time.Duration) {
+	var expires time.Time
+	switch t.config.ResolvePolicy {
+	case ResolveOnce:
+	case ResolveTTL:
+		if ttl <= 0 {
+			return
+		}
+		expires = time.Now().Add(ttl)
+	default:
+		return
+	}
+
+	t.pinMu.Lock()
+	defer t.pinMu.Unlock()
+
+	if t.pinned == nil {
+		t.pinned = make(map[string]pinnedAddr)
+	}
+	t.pinned[target] = pinnedAddr{ip: ip, expires: expires}
+}
+
+// resolver returns the configured resolver. Without one, only ResolveTTL
+// queries the nameserver directly for the TTL; the other policies use the
+// Go resolver, which honours the system's full resolver configuration.
+func (t *Tracer) resolver() Resolver {
+	if t.config.Resolver != nil {
+		return t.config.Resolver
+	}
+	if t.config.ResolvePolicy == ResolveTTL {
+		return systemResolver{}
+	}
+	return goResolver{}
+}
@@ -0,0 +1,221 @@
+package trace
+
+import (
+	"context"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// rotatingResolver returns a different address on every lookup,
+// like an anycast/CDN name served round-robin.
+type rotatingResolver struct {
+	answers []string
+	ttl     time.Duration
+	calls   int
+}
+
+func (r *rotatingResolver) Resolve(ctx context.Context, network, host string) ([]net.IP, time.Duration, error) {
+	ip := net.ParseIP(r.answers[r.calls%len(r.answers)])
+	r.calls++
+	return []net.IP{ip}, r.ttl, nil
+}
+
+func TestParseResolvePolicy(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    ResolvePolicy
+		wantErr bool
+	}{
+		{"", ResolveEveryCycle, false},
+		{"every", ResolveEveryCycle, false},
+		{"every-cycle", ResolveEveryCycle, false},
+		{"once", ResolveOnce, false},
+		{"TTL", ResolveTTL, false},
+		{"sometimes", ResolveEveryCycle, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseResolvePolicy(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseResolvePolicy(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseResolvePolicy(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTracer_ResolvePolicy(t *testing.T) {
+	answers := []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}
+
+	tests := []struct {
+		name   string
+		policy ResolvePolicy
+		ttl    time.Duration
+		want   []string
+	}{
+		{"every cycle", ResolveEveryCycle, time.Hour, []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}},
+		{"once", ResolveOnce, 0, []string{"192.0.2.1", "192.0.2.1", "192.0.2.1"}},
+		{"ttl not expired", ResolveTTL, time.Hour, []string{"192.0.2.1", "192.0.2.1", "192.0.2.1"}},
+		{"ttl unknown", ResolveTTL, 0, []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := &rotatingResolver{answers: answers, ttl: tt.ttl}
+			config := DefaultConfig()
+			config.ResolvePolicy = tt.policy
+			config.Resolver = resolver
+			tracer := &Tracer{config: config}
+
+			for i, want := range tt.want {
+				ip, err := tracer.resolveTarget(context.Background(), "cdn.example")
+				if err != nil {
+					t.Fatalf("resolveTarget() error = %v", err)
+				}
+				if ip.String() != want {
+					t.Errorf("cycle %d: resolved %s, want %s", i+1, ip, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTracer_ResolvePolicy_TTLExpiry(t *testing.T) {
+	resolver := &rotatingResolver{answers: []string{"192.0.2.1", "192.0.2.2"}, ttl: 20 * time.Millisecond}
+	config := DefaultConfig()
+	config.ResolvePolicy = ResolveTTL
+	config.Resolver = resolver
+	tracer := &Tracer{config: config}
+
+	ctx := context.Background()
+	first, _ := tracer.resolveTarget(ctx, "cdn.example")
+	pinned, _ := tracer.resolveTarget(ctx, "cdn.example")
+	if !first.Equal(pinned) {
+		t.Errorf("address changed before TTL expiry: %s -> %s", first, pinned)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	renewed, _ := tracer.resolveTarget(ctx, "cdn.example")
+	if renewed.Equal(first) {
+		t.Error("address should be re-resolved after TTL expiry")
+	}
+	if resolver.calls != 2 {
+		t.Errorf("resolver called %d times, want 2", resolver.calls)
+	}
+}
+
+func TestTracer_ResolvePolicy_Meta(t *testing.T) {
+	config := DefaultConfig()
+	config.ResolvePolicy = ResolveOnce
+	config.Resolver = &rotatingResolver{answers: []string{"192.0.2.7"}}
+	tracer := &Tracer{config: config}
+
+	dest, err := tracer.resolveTarget(context.Background(), "cdn.example")
+	if err != nil {
+		t.Fatalf("resolveTarget() error = %v", err)
+	}
+
+	meta := tracer.buildMeta("cdn.example", dest)
+	if meta.ResolvePolicy != "once" {
+		t.Errorf("ResolvePolicy = %q, want %q", meta.ResolvePolicy, "once")
+	}
+	if !meta.PinnedIP.Equal(dest) {
+		t.Errorf("PinnedIP = %v, want %v", meta.PinnedIP, dest)
+	}
+
+	if literal := tracer.buildMeta("192.0.2.7", dest); literal.ResolvePolicy != "" {
+		t.Error("IP literal targets should not report a resolve policy")
+	}
+
+	tracer.config.ResolvePolicy = ResolveEveryCycle
+	if every := tracer.buildMeta("cdn.example", dest); every.ResolvePolicy != "" {
+		t.Errorf("default policy should not be reported, got %q", every.ResolvePolicy)
+	}
+}
+
+func TestTracer_Resolver(t *testing.T) {
+	tests := []struct {
+		policy ResolvePolicy
+		want   Resolver
+	}{
+		{ResolveEveryCycle, goResolver{}},
+		{ResolveOnce, goResolver{}},
+		{ResolveTTL, systemResolver{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			config := DefaultConfig()
+			config.ResolvePolicy = tt.policy
+			tracer := &Tracer{config: config}
+			if got := tracer.resolver(); got != tt.want {
+				t.Errorf("resolver() = %T, want %T", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDNSAnswer(t *testing.T) {
+	name := dnsmessage.MustNewName("cdn.example.")
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: 42, Response: true},
+		Answers: []dnsmessage.Resource{
+			{
+				Header: dnsmessage.ResourceHeader{Name: name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 300},
+				Body:   &dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}},
+			},
+			{
+				Header: dnsmessage.ResourceHeader{Name: name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+				Body:   &dnsmessage.AResource{A: [4]byte{192, 0, 2, 2}},
+			},
+		},
+	}
+	packet, err := msg.Pack()
+	if err != nil {
+		t.Fatalf("Pack() error = %v", err)
+	}
+
+	ips, ttl, err := parseDNSAnswer(packet, 42)
+	if err != nil {
+		t.Fatalf("parseDNSAnswer() error = %v", err)
+	}
+	if len(ips) != 2 {
+		t.Errorf("len(ips) = %d, want 2", len(ips))
+	}
+	if ttl != 60*time.Second {
+		t.Errorf("ttl = %v, want lowest answer TTL 60s", ttl)
+	}
+
+	if _, _, err := parseDNSAnswer(packet, 43); err == nil {
+		t.Error("parseDNSAnswer() should reject mismatched IDs")
+	}
+}
+
+func TestParseResolvConf(t *testing.T) {
+	conf := parseResolvConf(strings.NewReader(`# generated
+search example.com
+nameserver 10.0.0.1
+nameserver fd00::53
+options edns0 ndots:3
+nameserver
+`))
+	if want := []string{"10.0.0.1", "fd00::53"}; !slices.Equal(conf.nameservers, want) {
+		t.Errorf("nameservers = %v, want %v", conf.nameservers, want)
+	}
+	if conf.ndots != 3 {
+		t.Errorf("ndots = %d, want 3", conf.ndots)
+	}
+
+	if conf := parseResolvConf(strings.NewReader("nameserver 10.0.0.1\n")); conf.ndots != 1 {
+		t.Errorf("default ndots = %d, want 1", conf.ndots)
+	}
+}
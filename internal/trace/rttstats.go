@@ -0,0 +1,126 @@
+package trace
+
+import "math"
+
+// DefaultRecentSamples is the number of raw RTT samples kept per hop for
+// the TUI sparkline and the hops of TraceLoop.
+const DefaultRecentSamples = 60
+
+// ewmaAlpha weights the newest sample in the smoothed RTT.
+const ewmaAlpha = 0.125
+
+// RTTStats accumulates RTT samples for one hop in constant memory. The
+// aggregates cover every sample seen; only the last few raw samples are
+// retained, in a fixed-size ring buffer.
+type RTTStats struct {
+	sent  uint64
+	count uint64 // replies
+	sum   float64
+	sumSq float64
+	min   float64
+	max   float64
+	ewma  float64
+	last  float64
+
+	ring []float64
+	next int
+	full bool
+}
+
+// NewRTTStats creates RTTStats keeping the last k raw samples
+// (DefaultRecentSamples if k <= 0).
+func NewRTTStats(k int) *RTTStats {
+	if k <= 0 {
+		k = DefaultRecentSamples
+	}
+	return &RTTStats{ring: make([]float64, k)}
+}
+
+// Add records one probe. A negative RTT is a lost probe: it counts towards
+// loss and is kept in the ring so the sparkline shows the gap.
+func (s *RTTStats) Add(rtt float64) {
+	s.sent++
+
+	s.ring[s.next] = rtt
+	s.next++
+	if s.next == len(s.ring) {
+		s.next = 0
+		s.full = true
+	}
+
+	s.last = rtt
+	if rtt < 0 {
+		return
+	}
+
+	if s.count == 0 {
+		s.min, s.max, s.ewma = rtt, rtt, rtt
+	} else {
+		s.min = math.Min(s.min, rtt)
+		s.max = math.Max(s.max, rtt)
+		s.ewma += ewmaAlpha * (rtt - s.ewma)
+	}
+	s.count++
+	s.sum += rtt
+	s.sumSq += rtt * rtt
+}
+
+// Sent returns the number of probes recorded.
+func (s *RTTStats) Sent() uint64 { return s.sent }
+
+// Count returns the number of probes that got a reply.
+func (s *RTTStats) Count() uint64 { return s.count }
+
+// Min returns the lowest RTT seen, or 0 without replies.
+func (s *RTTStats) Min() float64 { return s.min }
+
+// Max returns the highest RTT seen, or 0 without replies.
+func (s *RTTStats) Max() float64 { return s.max }
+
+// EWMA returns the exponentially weighted moving average RTT.
+func (s *RTTStats) EWMA() float64 { return s.ewma }
+
+// Last returns the most recent sample: -1 if that probe was lost, 0 if
+// nothing was recorded yet.
+func (s *RTTStats) Last() float64 { return s.last }
+
+// Mean returns the average RTT over all replies.
+func (s *RTTStats) Mean() float64 {
+	if s.count == 0 {
+		return 0
+	}
+	return s.sum / float64(s.count)
+}
+
+// StdDev returns the population standard deviation of all replies.
+func (s *RTTStats) StdDev() float64 {
+	if s.count == 0 {
+		return 0
+	}
+	mean := s.Mean()
+	variance := s.sumSq/float64(s.count) - mean*mean
+	if variance < 0 {
+		// Rounding error on near-constant samples
+		return 0
+	}
+	return math.Sqrt(variance)
+}
+
+// LossPercent returns the percentage of probes without a reply.
+func (s *RTTStats) LossPercent() float64 {
+	if s.sent == 0 {
+		return 0
+	}
+	return float64(s.sent-s.count) / float64(s.sent) * 100
+}
+
+// Recent returns the retained raw samples, oldest first. Lost probes
+// are -1.
+func (s *RTTStats) Recent() []float64 {
+	if !s.full {
+		return append([]float64(nil), s.ring[:s.next]...)
+	}
+	recent := make([]float64, 0, len(s.ring))
+	recent = append(recent, s.ring[s.next:]...)
+	return append(recent, s.ring[:s.next]...)
+}
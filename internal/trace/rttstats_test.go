@@ -0,0 +1,37 @@
+package trace
+
+import "testing"
+
+func TestRTTStats_Recent(t *testing.T) {
+	s := NewRTTStats(3)
+	if len(s.Recent()) != 0 {
+		t.Error("new stats should have no samples")
+	}
+
+	for _, rtt := range []float64{1, 2, -1, 4, 5} {
+		s.Add(rtt)
+	}
+	got := s.Recent()
+	want := []float64{-1, 4, 5}
+	for i := range want {
+		if len(got) != len(want) || got[i] != want[i] {
+			t.Fatalf("Recent() = %v, want %v", got, want)
+		}
+	}
+	if s.Sent() != 5 || s.Count() != 4 || s.LossPercent() != 20 {
+		t.Errorf("sent/received/loss = %d/%d/%.0f, want 5/4/20", s.Sent(), s.Count(), s.LossPercent())
+	}
+}
+
+func TestRTTStats_ConstantMemory(t *testing.T) {
+	s := NewRTTStats(32)
+	for i := 0; i < 100000; i++ {
+		s.Add(float64(i % 50))
+	}
+	if len(s.ring) != 32 || cap(s.ring) != 32 {
+		t.Errorf("ring buffer grew to len %d cap %d, want 32", len(s.ring), cap(s.ring))
+	}
+	if s.Sent() != 100000 || s.Min() != 0 || s.Max() != 49 {
+		t.Errorf("sent/min/max = %d/%.0f/%.0f, want 100000/0/49", s.Sent(), s.Min(), s.Max())
+	}
+}
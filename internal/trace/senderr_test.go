@@ -0,0 +1,114 @@
+package trace
+
+import (
+	"context"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/probe"
+)
+
+func sendErr(errno syscall.Errno) error {
+	return &probe.SendError{Err: os.NewSyscallError("sendto", errno)}
+}
+
+func TestTracer_SendErrors(t *testing.T) {
+	dest := net.ParseIP("8.8.8.8")
+	hop1 := &probe.Result{ResponseIP: net.ParseIP("192.0.2.1"), RTT: time.Millisecond, TTLExpired: true}
+	reached := &probe.Result{ResponseIP: dest, RTT: 5 * time.Millisecond, Reached: true}
+
+	tests := []struct {
+		name      string
+		fn        func(call int) (*probe.Result, error) // answers TTL 2
+		calls     int                                   // TTL 2 probes sent
+		responded bool
+		errors    int
+		errno     string
+	}{
+		{
+			name: "transient retried",
+			fn: func(call int) (*probe.Result, error) {
+				if call == 1 {
+					return nil, sendErr(syscall.ENOBUFS)
+				}
+				return reached, nil
+			},
+			calls: 4, responded: true,
+		},
+		{
+			name:  "transient persists",
+			fn:    func(int) (*probe.Result, error) { return nil, sendErr(syscall.ENETUNREACH) },
+			calls: 6, errors: 3, errno: "ENETUNREACH",
+		},
+		{
+			name:  "permanent",
+			fn:    func(int) (*probe.Result, error) { return nil, sendErr(syscall.EACCES) },
+			calls: 3, errors: 3, errno: "EACCES",
+		},
+		{
+			name: "partial",
+			fn: func(call int) (*probe.Result, error) {
+				if call == 2 {
+					return nil, sendErr(syscall.EMSGSIZE)
+				}
+				return reached, nil
+			},
+			calls: 3, responded: true, errors: 1, errno: "EMSGSIZE",
+		},
+		{
+			name:  "timeouts",
+			fn:    func(int) (*probe.Result, error) { return nil, probe.ErrTimeout },
+			calls: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		for _, parallel := range []bool{false, true} {
+			prober := newFuncProber(func(ttl, call int) (*probe.Result, error) {
+				if ttl == 1 {
+					return hop1, nil
+				}
+				if ttl == 2 {
+					return tt.fn(call)
+				}
+				return nil, probe.ErrTimeout
+			})
+
+			config := DefaultConfig()
+			config.ProbeMethod = ProbeUDP
+			config.Sequential = true
+			config.ParallelQueries = parallel
+			config.MaxHops = 2
+			config.Timeout = 100 * time.Millisecond
+			config.EnableEnrichment = false
+			tracer := &Tracer{config: config, prober: prober}
+
+			result, err := tracer.Trace(context.Background(), dest.String())
+			if err != nil {
+				t.Fatalf("%s: Trace() error = %v", tt.name, err)
+			}
+
+			hop := result.Hops[1]
+			if got := prober.calls[2]; got != tt.calls {
+				t.Errorf("%s (parallel=%v): %d probes sent, want %d", tt.name, parallel, got, tt.calls)
+			}
+			if hop.Responded != tt.responded || hop.SendErrors != tt.errors || hop.SendError != tt.errno {
+				t.Errorf("%s (parallel=%v): responded/send errors = %v/%d (%q), want %v/%d (%q)", tt.name, parallel,
+					hop.Responded, hop.SendErrors, hop.SendError, tt.responded, tt.errors, tt.errno)
+			}
+
+			noted := false
+			for _, note := range result.Notes {
+				noted = noted || strings.Contains(note, "hop 2: all 3 probes failed to send ("+tt.errno+")")
+			}
+			if want := tt.errors == 3; noted != want || hop.SendFailed() != want {
+				t.Errorf("%s (parallel=%v): send failure noted = %v, SendFailed() = %v, want %v; notes %v",
+					tt.name, parallel, noted, hop.SendFailed(), want, result.Notes)
+			}
+		}
+	}
+}
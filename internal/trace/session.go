@@ -0,0 +1,377 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/enrich"
+	"github.com/KilimcininKorOglu/poros/internal/probe"
+)
+
+// DefaultSessionTraces is the number of traces a Session runs at once
+// unless configured otherwise.
+const DefaultSessionTraces = 8
+
+// Session errors.
+var (
+	// ErrSessionFull indicates a Session already runs as many traces as
+	// it allows
+	ErrSessionFull = errors.New("too many traces running")
+
+	// ErrSessionClosed indicates Start was called after Close
+	ErrSessionClosed = errors.New("session closed")
+)
+
+// Session runs traces in the background for programs that embed poros,
+// such as daemons, and reports on them through callbacks instead of a
+// blocking Trace call.
+//
+// Each trace reports its progress and hops as it runs, and its rounds if
+// started with StartLoop, and then exactly one of OnComplete or OnError,
+// which is always its last callback. The
+// callbacks of one trace never run concurrently, and none run once
+// Cancel for that trace has returned. Callbacks may start traces but must
+// not call Cancel or Close; to stop a trace from its own callbacks,
+// cancel the context passed to Start.
+//
+// Traces share one enricher, and with it the enrichment caches. Each
+// running trace holds a Tracer, whose prober is kept open and reused by
+// later traces.
+type Session struct {
+	config   *Config
+	max      int
+	enricher *enrich.Enricher
+
+	// openProber creates the probers of new tracers (nil = newProber)
+	openProber func(config *Config, ipv6 bool) (probe.Prober, error)
+
+	mu        sync.Mutex
+	callbacks sessionCallbacks
+	idle      []*sessionTracer       // tracers between traces
+	runs      map[string]*sessionRun // by ID, until the last callback
+	running   int                    // traces holding a tracer
+	nextID    uint64
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+// sessionCallbacks are the callbacks registered on a Session. Each trace
+// uses those registered when it started.
+type sessionCallbacks struct {
+	onHop      func(id string, hop *Hop)
+	onProgress func(id string, p Progress)
+	onRound    func(id string, round Round)
+	onComplete func(id string, result *TraceResult)
+	onError    func(id string, err error)
+}
+
+// sessionTracer is a tracer of a Session. Its config callbacks report to
+// the trace it is running.
+type sessionTracer struct {
+	tracer *Tracer
+	mu     sync.Mutex
+	run    *sessionRun
+}
+
+// current returns the trace the tracer is running, or nil.
+func (st *sessionTracer) current() *sessionRun {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.run
+}
+
+// setCurrent sets the trace the tracer is running.
+func (st *sessionTracer) setCurrent(run *sessionRun) {
+	st.mu.Lock()
+	st.run = run
+	st.mu.Unlock()
+}
+
+// sessionRun is a trace started by a Session.
+type sessionRun struct {
+	id        string
+	callbacks sessionCallbacks
+	cancel    context.CancelFunc
+
+	// mu is held while a callback runs, so stop can wait it out
+	mu      sync.Mutex
+	stopped bool
+}
+
+// fire calls fn unless the trace was cancelled.
+func (r *sessionRun) fire(fn func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.stopped {
+		fn()
+	}
+}
+
+// stop cancels the trace and returns once no callback is running; no
+// callback runs afterwards.
+func (r *sessionRun) stop() {
+	r.cancel()
+	r.mu.Lock()
+	r.stopped = true
+	r.mu.Unlock()
+}
+
+// NewSession creates a Session that traces with config and runs at most
+// maxTraces traces at once (0 = DefaultSessionTraces). The config's
+// OnHop, OnProgress and OnRound callbacks are replaced by the session's.
+func NewSession(config *Config, maxTraces int) (*Session, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, WithStage(StageConfig, "", err)
+	}
+	if maxTraces <= 0 {
+		maxTraces = DefaultSessionTraces
+	}
+
+	return &Session{
+		config:   config,
+		max:      maxTraces,
+		enricher: newEnricher(config),
+		runs:     make(map[string]*sessionRun),
+	}, nil
+}
+
+// OnHop registers fn to be called with each hop of a trace as it is
+// probed.
+func (s *Session) OnHop(fn func(id string, hop *Hop)) {
+	s.mu.Lock()
+	s.callbacks.onHop = fn
+	s.mu.Unlock()
+}
+
+// OnProgress registers fn to be called whenever the progress of a trace
+// changes.
+func (s *Session) OnProgress(fn func(id string, p Progress)) {
+	s.mu.Lock()
+	s.callbacks.onProgress = fn
+	s.mu.Unlock()
+}
+
+// OnRound registers fn to be called after each round of a trace started
+// with StartLoop.
+func (s *Session) OnRound(fn func(id string, round Round)) {
+	s.mu.Lock()
+	s.callbacks.onRound = fn
+	s.mu.Unlock()
+}
+
+// OnComplete registers fn to be called with the result of each trace
+// that succeeds.
+func (s *Session) OnComplete(fn func(id string, result *TraceResult)) {
+	s.mu.Lock()
+	s.callbacks.onComplete = fn
+	s.mu.Unlock()
+}
+
+// OnError registers fn to be called with the error of each trace that
+// fails, including traces whose Start context is cancelled.
+func (s *Session) OnError(fn func(id string, err error)) {
+	s.mu.Lock()
+	s.callbacks.onError = fn
+	s.mu.Unlock()
+}
+
+// Start starts tracing target in the background and returns the trace's
+// ID, which is passed to its callbacks. It fails with ErrSessionFull if
+// the session is running as many traces as it allows.
+func (s *Session) Start(ctx context.Context, target string) (string, error) {
+	return s.start(ctx, target, 0)
+}
+
+// StartLoop is like Start, but traces target in rounds with interval
+// between them, as TraceLoop does (DefaultLoopInterval if interval <= 0),
+// until ctx is done. OnRound is called after each round, and OnComplete
+// with the total of the rounds once stopped.
+func (s *Session) StartLoop(ctx context.Context, target string, interval time.Duration) (string, error) {
+	if interval <= 0 {
+		interval = DefaultLoopInterval
+	}
+	return s.start(ctx, target, interval)
+}
+
+// start starts a trace, in rounds if interval is positive.
+func (s *Session) start(ctx context.Context, target string, interval time.Duration) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return "", ErrSessionClosed
+	}
+	if s.running >= s.max {
+		return "", ErrSessionFull
+	}
+	st, err := s.tracer()
+	if err != nil {
+		return "", WithStage(StageSocket, target, err)
+	}
+
+	s.nextID++
+	run := &sessionRun{
+		id:        strconv.FormatUint(s.nextID, 10),
+		callbacks: s.callbacks,
+	}
+	ctx, run.cancel = context.WithCancel(ctx)
+	s.runs[run.id] = run
+	s.running++
+	st.setCurrent(run)
+
+	s.wg.Add(1)
+	go s.trace(ctx, st, run, target, interval)
+	return run.id, nil
+}
+
+// tracer returns an idle tracer, or opens a new one. s.mu must be held.
+func (s *Session) tracer() (*sessionTracer, error) {
+	if n := len(s.idle); n > 0 {
+		st := s.idle[n-1]
+		s.idle = s.idle[:n-1]
+		return st, nil
+	}
+
+	openProber := s.openProber
+	if openProber == nil {
+		openProber = newProber
+	}
+	prober, err := openProber(s.config, s.config.IPv6)
+	if err != nil {
+		return nil, err
+	}
+
+	st := &sessionTracer{}
+	config := *s.config
+	config.OnHop = func(hop *Hop) {
+		if run := st.current(); run != nil && run.callbacks.onHop != nil {
+			run.fire(func() { run.callbacks.onHop(run.id, hop) })
+		}
+	}
+	config.OnProgress = func(p Progress) {
+		if run := st.current(); run != nil && run.callbacks.onProgress != nil {
+			run.fire(func() { run.callbacks.onProgress(run.id, p) })
+		}
+	}
+	config.OnRound = func(round Round) {
+		if run := st.current(); run != nil && run.callbacks.onRound != nil {
+			run.fire(func() { run.callbacks.onRound(run.id, round) })
+		}
+	}
+	st.tracer = newTracerWith(&config, prober, s.config.IPv6, s.enricher)
+	st.tracer.sharedEnricher = true
+	st.tracer.openProber = s.openProber
+	return st, nil
+}
+
+// trace runs a trace started by start and reports its outcome. The
+// tracer is released before the last callback, so it may start another
+// trace.
+func (s *Session) trace(ctx context.Context, st *sessionTracer, run *sessionRun, target string, interval time.Duration) {
+	defer s.wg.Done()
+
+	var result *TraceResult
+	var err error
+	if interval > 0 {
+		result, err = st.tracer.TraceLoop(ctx, target, interval)
+	} else {
+		result, err = st.tracer.Trace(ctx, target)
+	}
+	st.setCurrent(nil)
+	run.cancel()
+
+	s.mu.Lock()
+	s.running--
+	if s.closed {
+		st.tracer.Close()
+	} else {
+		s.idle = append(s.idle, st)
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		if run.callbacks.onError != nil {
+			run.fire(func() { run.callbacks.onError(run.id, err) })
+		}
+	} else if run.callbacks.onComplete != nil {
+		run.fire(func() { run.callbacks.onComplete(run.id, result) })
+	}
+
+	s.mu.Lock()
+	delete(s.runs, run.id)
+	s.mu.Unlock()
+}
+
+// Cancel stops the trace with the given ID. Once it returns, none of the
+// trace's callbacks run, not even OnError. It reports whether the trace
+// was still running or reporting its outcome.
+func (s *Session) Cancel(id string) bool {
+	s.mu.Lock()
+	run := s.runs[id]
+	s.mu.Unlock()
+
+	if run == nil {
+		return false
+	}
+	run.stop()
+	return true
+}
+
+// Running returns the IDs of the traces that have not finished reporting.
+func (s *Session) Running() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]string, 0, len(s.runs))
+	for id := range s.runs {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Close cancels the running traces, waits for them to stop and releases
+// the tracers and the enricher. Start fails with ErrSessionClosed
+// afterwards; closing again is a no-op.
+func (s *Session) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	idle := s.idle
+	s.idle = nil
+	runs := make([]*sessionRun, 0, len(s.runs))
+	for _, run := range s.runs {
+		runs = append(runs, run)
+	}
+	s.mu.Unlock()
+
+	for _, run := range runs {
+		run.stop()
+	}
+	s.wg.Wait()
+
+	var errs []error
+	for _, st := range idle {
+		if err := st.tracer.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if s.enricher != nil {
+		if err := s.enricher.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
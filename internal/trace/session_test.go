@@ -0,0 +1,420 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/probe"
+	"github.com/KilimcininKorOglu/poros/internal/probe/probetest"
+)
+
+// sessionEvents records the callbacks of a Session in order.
+type sessionEvents struct {
+	mu     sync.Mutex
+	events []string
+	done   chan string // receives the ID of each trace that finished
+}
+
+func (e *sessionEvents) add(id, event string) {
+	e.mu.Lock()
+	e.events = append(e.events, id+" "+event)
+	e.mu.Unlock()
+}
+
+// of returns the events recorded for the trace with the given ID.
+func (e *sessionEvents) of(id string) []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var events []string
+	for _, event := range e.events {
+		if len(event) > len(id) && event[:len(id)+1] == id+" " {
+			events = append(events, event[len(id)+1:])
+		}
+	}
+	return events
+}
+
+// count returns the number of events recorded.
+func (e *sessionEvents) count() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.events)
+}
+
+// wait returns the ID of the next trace to finish.
+func (e *sessionEvents) wait(t *testing.T) string {
+	t.Helper()
+	select {
+	case id := <-e.done:
+		return id
+	case <-time.After(5 * time.Second):
+		t.Fatal("no trace finished within 5s")
+		return ""
+	}
+}
+
+// newTestSession creates a Session whose tracers probe through probers
+// returned by open, with every callback recorded.
+func newTestSession(t *testing.T, maxTraces int, open func() probe.Prober) (*Session, *sessionEvents) {
+	t.Helper()
+	config := DefaultConfig()
+	config.ProbeMethod = ProbeUDP
+	config.Sequential = true
+	config.EnableEnrichment = false
+	config.MaxHops = 5
+
+	session, err := NewSession(config, maxTraces)
+	if err != nil {
+		t.Fatalf("NewSession() error = %v", err)
+	}
+	session.openProber = func(*Config, bool) (probe.Prober, error) { return open(), nil }
+	t.Cleanup(func() { session.Close() })
+
+	events := &sessionEvents{done: make(chan string, 16)}
+	session.OnHop(func(id string, hop *Hop) {
+		events.add(id, fmt.Sprintf("hop %d", hop.Number))
+	})
+	session.OnProgress(func(id string, p Progress) {
+		events.add(id, "progress "+string(p.Phase))
+	})
+	session.OnComplete(func(id string, result *TraceResult) {
+		events.add(id, fmt.Sprintf("complete %d hops", len(result.Hops)))
+		events.done <- id
+	})
+	session.OnError(func(id string, err error) {
+		events.add(id, "error "+string(ErrorStage(err)))
+		events.done <- id
+	})
+	return session, events
+}
+
+// pathProber answers with a three-hop path to 203.0.113.9.
+func pathProber() probe.Prober {
+	return probetest.NewScriptedProber().
+		Hop(1, "192.0.2.1", 1, 1, 1).
+		Hop(2, "198.51.100.1", 5, 5, 5).
+		Hop(3, "203.0.113.9", 9, 9, 9)
+}
+
+// hangingProber blocks every probe until it is cancelled or closed.
+func hangingProber() *probetest.ScriptedProber {
+	prober := probetest.NewScriptedProber()
+	for ttl := 1; ttl <= 5; ttl++ {
+		prober.Reply(ttl, probetest.AnyAttempt, probetest.Reply{Delay: time.Hour})
+	}
+	return prober
+}
+
+func TestSession_Lifecycle(t *testing.T) {
+	session, events := newTestSession(t, 0, pathProber)
+
+	id, err := session.Start(context.Background(), "203.0.113.9")
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if got := events.wait(t); got != id {
+		t.Fatalf("finished trace %q, want %q", got, id)
+	}
+
+	got := events.of(id)
+	if len(got) == 0 || got[0] != "progress resolving" {
+		t.Fatalf("events = %v, want progress resolving first", got)
+	}
+	if last := got[len(got)-1]; last != "complete 3 hops" {
+		t.Errorf("last event = %q, want complete 3 hops", last)
+	}
+
+	// Hops in order, between the first progress and completion, and the
+	// done phase before completion
+	var hops []string
+	done := -1
+	for i, event := range got {
+		switch {
+		case len(event) > 4 && event[:4] == "hop ":
+			hops = append(hops, event)
+		case event == "progress done":
+			done = i
+		}
+	}
+	if want := []string{"hop 1", "hop 2", "hop 3"}; fmt.Sprint(hops) != fmt.Sprint(want) {
+		t.Errorf("hop events = %v, want %v", hops, want)
+	}
+	if done != len(got)-2 {
+		t.Errorf("progress done at event %d of %v, want just before completion", done, got)
+	}
+
+	if running := session.Running(); len(running) != 0 {
+		t.Errorf("Running() = %v after the trace finished", running)
+	}
+}
+
+func TestSession_Error(t *testing.T) {
+	session, events := newTestSession(t, 0, pathProber)
+
+	id, err := session.Start(context.Background(), "no such host.invalid")
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	events.wait(t)
+
+	got := events.of(id)
+	if last := got[len(got)-1]; last != "error resolve" {
+		t.Errorf("last event = %q of %v, want a resolve error", last, got)
+	}
+	for _, event := range got {
+		if len(event) > 8 && event[:8] == "complete" {
+			t.Errorf("failed trace completed: %v", got)
+		}
+	}
+}
+
+func TestSession_ContextCancelled(t *testing.T) {
+	session, events := newTestSession(t, 0, func() probe.Prober { return hangingProber() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	id, err := session.Start(ctx, "203.0.113.9")
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	cancel()
+	events.wait(t)
+
+	got := events.of(id)
+	if last := got[len(got)-1]; last != "error trace" {
+		t.Errorf("last event = %q of %v, want the trace error of a cancelled context", last, got)
+	}
+}
+
+func TestSession_StartLoop(t *testing.T) {
+	session, events := newTestSession(t, 0, pathProber)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var total *TraceResult
+	session.OnRound(func(id string, round Round) {
+		events.add(id, fmt.Sprintf("round %d", round.Number))
+		if round.Number == 2 {
+			cancel()
+		}
+	})
+	session.OnComplete(func(id string, result *TraceResult) {
+		total = result
+		events.add(id, "complete")
+		events.done <- id
+	})
+
+	id, err := session.StartLoop(ctx, "203.0.113.9", time.Millisecond)
+	if err != nil {
+		t.Fatalf("StartLoop() error = %v", err)
+	}
+	events.wait(t)
+
+	var rounds []string
+	for _, event := range events.of(id) {
+		if len(event) > 6 && event[:6] == "round " || event == "complete" {
+			rounds = append(rounds, event)
+		}
+	}
+	if want := []string{"round 1", "round 2", "complete"}; fmt.Sprint(rounds) != fmt.Sprint(want) {
+		t.Errorf("events = %v, want %v", rounds, want)
+	}
+	if total == nil || total.Rounds != 2 || total.Hops[1].Sent != 6 {
+		t.Errorf("OnComplete result = %+v, want the total of 2 rounds", total)
+	}
+}
+
+func TestSession_Cancel(t *testing.T) {
+	var prober *probetest.ScriptedProber
+	session, events := newTestSession(t, 0, func() probe.Prober {
+		prober = hangingProber()
+		return prober
+	})
+
+	id, err := session.Start(context.Background(), "203.0.113.9")
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	for prober.Probes(1) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if !session.Cancel(id) {
+		t.Fatal("Cancel() = false for a running trace")
+	}
+	seen := events.count()
+
+	// Closing waits for the trace to stop; nothing may be reported in
+	// the meantime, not even the cancellation error
+	if err := session.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if n := events.count(); n != seen {
+		t.Errorf("%d callbacks after Cancel returned: %v", n-seen, events.of(id)[seen:])
+	}
+	if !prober.Closed() {
+		t.Error("Close() should close the session's probers")
+	}
+
+	if session.Cancel(id) {
+		t.Error("Cancel() = true for a trace that has stopped")
+	}
+	if session.Cancel("unknown") {
+		t.Error("Cancel() = true for an unknown ID")
+	}
+}
+
+// TestSession_CancelDuringCallbacks cancels a trace while its callbacks
+// are running; run with -race. Cancel must wait for the callback in
+// progress, and no callback may start after it returns.
+func TestSession_CancelDuringCallbacks(t *testing.T) {
+	session, events := newTestSession(t, 0, pathProber)
+
+	var mu sync.Mutex
+	cancelled := false
+	late := 0
+	inHop := make(chan string, 1)
+	session.OnHop(func(id string, hop *Hop) {
+		mu.Lock()
+		if cancelled {
+			late++
+		}
+		mu.Unlock()
+		if hop.Number == 1 {
+			inHop <- id
+			time.Sleep(20 * time.Millisecond)
+		}
+	})
+
+	if _, err := session.Start(context.Background(), "203.0.113.9"); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	id := <-inHop
+	session.Cancel(id)
+	mu.Lock()
+	cancelled = true
+	mu.Unlock()
+	seen := events.count()
+
+	session.Close()
+	if late > 0 {
+		t.Errorf("%d hop callbacks ran after Cancel returned", late)
+	}
+	if n := events.count(); n != seen {
+		t.Errorf("%d callbacks after Cancel returned: %v", n-seen, events.of(id)[seen:])
+	}
+}
+
+func TestSession_Bounded(t *testing.T) {
+	var mu sync.Mutex
+	var probers []*probetest.ScriptedProber
+	session, events := newTestSession(t, 2, func() probe.Prober {
+		mu.Lock()
+		defer mu.Unlock()
+		prober := hangingProber()
+		probers = append(probers, prober)
+		return prober
+	})
+
+	first, err := session.Start(context.Background(), "203.0.113.9")
+	if err != nil {
+		t.Fatalf("Start() #1 error = %v", err)
+	}
+	if _, err := session.Start(context.Background(), "203.0.113.9"); err != nil {
+		t.Fatalf("Start() #2 error = %v", err)
+	}
+	if _, err := session.Start(context.Background(), "203.0.113.9"); !errors.Is(err, ErrSessionFull) {
+		t.Fatalf("Start() #3 error = %v, want ErrSessionFull", err)
+	}
+	if running := session.Running(); len(running) != 2 {
+		t.Errorf("Running() = %v, want 2 traces", running)
+	}
+
+	// A cancelled trace frees its slot once it stops, and its tracer is
+	// reused rather than opening another prober
+	session.Cancel(first)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, err := session.Start(context.Background(), "203.0.113.9")
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrSessionFull) || time.Now().After(deadline) {
+			t.Fatalf("Start() after Cancel error = %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	mu.Lock()
+	opened := len(probers)
+	mu.Unlock()
+	if opened != 2 {
+		t.Errorf("opened %d probers for 3 traces with 2 slots, want 2", opened)
+	}
+
+	session.Close()
+	select {
+	case id := <-events.done:
+		t.Errorf("trace %s reported after Close", id)
+	default:
+	}
+	for i, prober := range probers {
+		if !prober.Closed() {
+			t.Errorf("prober %d not closed by Close", i)
+		}
+	}
+}
+
+func TestSession_StartFromCallback(t *testing.T) {
+	session, events := newTestSession(t, 1, pathProber)
+
+	// A watch loop restarts the trace when it completes; the finished
+	// trace has released its slot by then
+	restarted := make(chan error, 1)
+	var once sync.Once
+	session.OnComplete(func(id string, result *TraceResult) {
+		once.Do(func() {
+			_, err := session.Start(context.Background(), "203.0.113.9")
+			restarted <- err
+		})
+		events.done <- id
+	})
+
+	first, err := session.Start(context.Background(), "203.0.113.9")
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if err := <-restarted; err != nil {
+		t.Fatalf("Start() from OnComplete error = %v", err)
+	}
+	if got := events.wait(t); got != first {
+		t.Errorf("first finished trace = %q, want %q", got, first)
+	}
+	if second := events.wait(t); second == first {
+		t.Errorf("restarted trace reused ID %q", second)
+	}
+}
+
+func TestSession_Close(t *testing.T) {
+	session, _ := newTestSession(t, 0, pathProber)
+
+	for i := range 2 {
+		if err := session.Close(); err != nil {
+			t.Errorf("Close() #%d error = %v", i+1, err)
+		}
+	}
+	if _, err := session.Start(context.Background(), "203.0.113.9"); !errors.Is(err, ErrSessionClosed) {
+		t.Errorf("Start() after Close error = %v, want ErrSessionClosed", err)
+	}
+}
+
+func TestNewSession_InvalidConfig(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxHops = 0
+	if _, err := NewSession(config, 0); !errors.Is(err, ErrInvalidMaxHops) || ErrorStage(err) != StageConfig {
+		t.Errorf("NewSession() error = %v, want ErrInvalidMaxHops at the config stage", err)
+	}
+}
@@ -0,0 +1,87 @@
+package trace
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/probe"
+)
+
+// maxSkipProbeTimeout bounds the single probe sent to each hop while
+// fast-forwarding through a private prefix. Tunnel and CPE hops are close,
+// so there is no point waiting the full probe timeout for them.
+const maxSkipProbeTimeout = time.Second
+
+// cgnatNet is the shared address space used by carrier-grade NAT (RFC 6598).
+var cgnatNet = &net.IPNet{
+	IP:   net.IPv4(100, 64, 0, 0),
+	Mask: net.CIDRMask(10, 32),
+}
+
+// skipEnd is the probe that ended a skip of the private prefix. It is the
+// first sample of the hop it probed, which then gets one probe less.
+type skipEnd struct {
+	ttl    int
+	result *probe.Result // nil for a timeout
+}
+
+// skipPrivatePrefix sends a single short probe to each leading hop and stops
+// at the first hop that is silent, public, or the destination itself.
+// It returns nil if not even the first hop could be skipped, and the probe
+// that stopped it unless that failed to send. A silent hop counts as lost
+// after the short timeout.
+func (t *Tracer) skipPrivatePrefix(ctx context.Context, dest net.IP) (*SkippedHops, *skipEnd) {
+	timeout := t.config.Timeout
+	if timeout > maxSkipProbeTimeout {
+		timeout = maxSkipProbeTimeout
+	}
+
+	var skipped *SkippedHops
+	var end *skipEnd
+
+	for ttl := t.config.FirstHop; ttl <= t.config.lastTTL(); ttl++ {
+		if ctx.Err() != nil || t.config.Pause.Wait(ctx) != nil || !t.budget.take() {
+			break
+		}
+
+		probeCtx, cancel := context.WithTimeout(ctx, timeout)
+		result, err := t.prober.Probe(probeCtx, dest, ttl)
+		cancel()
+
+		if err != nil || result == nil || result.ResponseIP == nil {
+			if probe.IsTimeout(err) && ctx.Err() == nil {
+				end = &skipEnd{ttl: ttl}
+			}
+			break
+		}
+		if result.ResponseIP.Equal(dest) || !isPrivateOrCGNAT(result.ResponseIP) {
+			end = &skipEnd{ttl: ttl, result: result}
+			break
+		}
+
+		if skipped == nil {
+			skipped = &SkippedHops{FirstHop: ttl}
+		}
+		skipped.LastHop = ttl
+		skipped.RTT = float64(result.RTT.Microseconds()) / 1000.0
+	}
+
+	if skipped != nil && t.config.OnSkip != nil {
+		t.config.OnSkip(skipped)
+	}
+
+	return skipped, end
+}
+
+// isPrivateOrCGNAT reports whether ip belongs to RFC 1918, RFC 4193,
+// link-local, or CGNAT address space.
+func isPrivateOrCGNAT(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	if ip.IsPrivate() || ip.IsLinkLocalUnicast() {
+		return true
+	}
+	return cgnatNet.Contains(ip)
+}
@@ -0,0 +1,222 @@
+package trace
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/probe"
+)
+
+// scriptedProber answers each TTL with a fixed responder and counts probes.
+// It is safe for concurrent use.
+type scriptedProber struct {
+	probe.Counters
+
+	dest       net.IP
+	responders map[int]string // TTL -> responder IP ("" = timeout)
+
+	mu     sync.Mutex
+	probes map[int]int
+}
+
+func newScriptedProber(dest string, responders map[int]string) *scriptedProber {
+	return &scriptedProber{
+		dest:       net.ParseIP(dest),
+		responders: responders,
+		probes:     make(map[int]int),
+	}
+}
+
+func (p *scriptedProber) Probe(ctx context.Context, dest net.IP, ttl int) (*probe.Result, error) {
+	p.mu.Lock()
+	p.probes[ttl]++
+	p.mu.Unlock()
+	p.CountSent()
+
+	responder, ok := p.responders[ttl]
+	if !ok || responder == "" {
+		p.CountTimeout()
+		return nil, probe.ErrTimeout
+	}
+	p.CountReceived()
+
+	ip := net.ParseIP(responder)
+	return &probe.Result{
+		ResponseIP: ip,
+		RTT:        time.Duration(ttl) * time.Millisecond,
+		Reached:    ip.Equal(dest),
+		TTLExpired: !ip.Equal(dest),
+	}, nil
+}
+
+func (p *scriptedProber) Name() string       { return "scripted" }
+func (p *scriptedProber) RequiresRoot() bool { return false }
+func (p *scriptedProber) Close() error       { return nil }
+
+func TestTracer_SkipPrivatePrefix(t *testing.T) {
+	prober := newScriptedProber("8.8.8.8", map[int]string{
+		1: "192.168.1.1",
+		2: "10.10.0.1",
+		3: "100.64.12.1",
+		4: "203.0.113.1",
+		5: "8.8.8.8",
+	})
+
+	config := DefaultConfig()
+	config.SkipPrivatePrefix = true
+	config.EnableEnrichment = false
+
+	var callback *SkippedHops
+	config.OnSkip = func(skipped *SkippedHops) {
+		callback = skipped
+	}
+
+	tracer := &Tracer{config: config, prober: prober}
+	result, err := tracer.Trace(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("Trace() error = %v", err)
+	}
+
+	if result.Skipped == nil {
+		t.Fatal("Skipped should be set")
+	}
+	if result.Skipped.FirstHop != 1 || result.Skipped.LastHop != 3 {
+		t.Errorf("Skipped = %d-%d, want 1-3", result.Skipped.FirstHop, result.Skipped.LastHop)
+	}
+	if result.Skipped.RTT != 3 {
+		t.Errorf("Skipped.RTT = %v, want 3", result.Skipped.RTT)
+	}
+	if callback != result.Skipped {
+		t.Error("OnSkip should receive the recorded skipped range")
+	}
+
+	// Skipped hops get a single probe, the rest full probing; the probe
+	// that ended the skip is hop 4's first sample
+	for ttl := 1; ttl <= 3; ttl++ {
+		if prober.probes[ttl] != 1 {
+			t.Errorf("hop %d probed %d times, want 1", ttl, prober.probes[ttl])
+		}
+	}
+	if prober.probes[4] != config.ProbeCount {
+		t.Errorf("hop 4 probed %d times, want %d", prober.probes[4], config.ProbeCount)
+	}
+
+	if len(result.Hops) != 2 || result.Hops[0].Number != 4 {
+		t.Fatalf("Hops = %+v, want hops 4 and 5", result.Hops)
+	}
+	if hop := result.Hops[0]; len(hop.RTTs) != config.ProbeCount || hop.LossPercent != 0 {
+		t.Errorf("hop 4 RTTs = %v, want %d answered samples", hop.RTTs, config.ProbeCount)
+	}
+	if !result.Completed {
+		t.Error("Trace should complete")
+	}
+	if result.Summary.TotalHops != 5 {
+		t.Errorf("TotalHops = %d, want 5", result.Summary.TotalHops)
+	}
+}
+
+func TestTracer_SkipPrivatePrefix_StopsOnTimeout(t *testing.T) {
+	prober := newScriptedProber("8.8.8.8", map[int]string{
+		1: "10.0.0.1",
+		2: "",
+		3: "8.8.8.8",
+	})
+
+	config := DefaultConfig()
+	config.SkipPrivatePrefix = true
+	config.EnableEnrichment = false
+
+	tracer := &Tracer{config: config, prober: prober}
+	result, err := tracer.Trace(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("Trace() error = %v", err)
+	}
+
+	if result.Skipped == nil || result.Skipped.LastHop != 1 {
+		t.Fatalf("Skipped = %+v, want hop 1 only", result.Skipped)
+	}
+	if result.Hops[0].Number != 2 {
+		t.Errorf("first probed hop = %d, want 2", result.Hops[0].Number)
+	}
+	if n := prober.probes[2]; n != config.ProbeCount || len(result.Hops[0].RTTs) != n {
+		t.Errorf("silent hop 2 probed %d times with RTTs %v, want %d probes, the skip's included",
+			n, result.Hops[0].RTTs, config.ProbeCount)
+	}
+}
+
+func TestTracer_SkipPrivatePrefix_Disabled(t *testing.T) {
+	prober := newScriptedProber("8.8.8.8", map[int]string{
+		1: "10.0.0.1",
+		2: "8.8.8.8",
+	})
+
+	config := DefaultConfig()
+	config.Sequential = true
+	config.EnableEnrichment = false
+
+	tracer := &Tracer{config: config, prober: prober}
+	result, err := tracer.Trace(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("Trace() error = %v", err)
+	}
+
+	if result.Skipped != nil {
+		t.Errorf("Skipped = %+v, want nil", result.Skipped)
+	}
+	if len(result.Hops) != 2 {
+		t.Errorf("len(Hops) = %d, want 2", len(result.Hops))
+	}
+}
+
+func TestTracer_SkipPrivatePrefix_PrivateDestination(t *testing.T) {
+	prober := newScriptedProber("10.0.0.2", map[int]string{
+		1: "10.0.0.1",
+		2: "10.0.0.2",
+	})
+
+	config := DefaultConfig()
+	config.SkipPrivatePrefix = true
+	config.EnableEnrichment = false
+
+	tracer := &Tracer{config: config, prober: prober}
+	result, err := tracer.Trace(context.Background(), "10.0.0.2")
+	if err != nil {
+		t.Fatalf("Trace() error = %v", err)
+	}
+
+	if result.Skipped == nil || result.Skipped.LastHop != 1 {
+		t.Fatalf("Skipped = %+v, want hop 1 only", result.Skipped)
+	}
+	if !result.Completed {
+		t.Error("Trace to a private destination should still complete")
+	}
+}
+
+func TestIsPrivateOrCGNAT(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.0.1", true},
+		{"100.64.0.1", true},
+		{"100.127.255.254", true},
+		{"169.254.1.1", true},
+		{"fd00::1", true},
+		{"100.128.0.1", false},
+		{"8.8.8.8", false},
+		{"2001:4860:4860::8888", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := isPrivateOrCGNAT(net.ParseIP(tt.ip)); got != tt.want {
+				t.Errorf("isPrivateOrCGNAT(%s) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package trace
+
+import "errors"
+
+// Stage names the step of a run that failed, for machine-readable errors.
+type Stage string
+
+// Failure stages, in the order a run goes through them.
+const (
+	StageConfig  Stage = "config"  // invalid flags or configuration
+	StageResolve Stage = "resolve" // the target could not be resolved
+	StageSocket  Stage = "socket"  // a probe socket could not be opened
+	StageTrace   Stage = "trace"   // probing failed or was interrupted
+	StageOutput  Stage = "output"  // results could not be written
+)
+
+// ExitCode returns the process exit status for a failure at this stage.
+func (s Stage) ExitCode() int {
+	switch s {
+	case StageConfig:
+		return 2
+	case StageResolve:
+		return 3
+	case StageSocket:
+		return 4
+	case StageOutput:
+		return 5
+	default:
+		return 1
+	}
+}
+
+// StageError is an error annotated with the stage that failed and the
+// target being traced.
+type StageError struct {
+	Stage  Stage
+	Target string
+	Err    error
+}
+
+func (e *StageError) Error() string { return e.Err.Error() }
+
+func (e *StageError) Unwrap() error { return e.Err }
+
+// WithStage annotates err with stage and target. It returns nil for a nil
+// error. Errors that already carry a stage keep it, so the innermost
+// classification wins; only a missing target is filled in.
+func WithStage(stage Stage, target string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var se *StageError
+	if errors.As(err, &se) {
+		if se.Target == "" && target != "" {
+			return &StageError{Stage: se.Stage, Target: target, Err: err}
+		}
+		return err
+	}
+	return &StageError{Stage: stage, Target: target, Err: err}
+}
+
+// ErrorStage returns the stage err was annotated with, or StageTrace for
+// errors without one.
+func ErrorStage(err error) Stage {
+	var se *StageError
+	if errors.As(err, &se) {
+		return se.Stage
+	}
+	return StageTrace
+}
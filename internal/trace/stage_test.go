@@ -0,0 +1,97 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/KilimcininKorOglu/poros/internal/probe"
+)
+
+func TestWithStage(t *testing.T) {
+	if WithStage(StageConfig, "host", nil) != nil {
+		t.Error("WithStage(nil) should return nil")
+	}
+
+	base := errors.New("boom")
+	err := WithStage(StageSocket, "", base)
+	if got := ErrorStage(err); got != StageSocket {
+		t.Errorf("ErrorStage = %q, want %q", got, StageSocket)
+	}
+	if !errors.Is(err, base) {
+		t.Error("StageError should unwrap to the original error")
+	}
+
+	// The innermost stage wins; a missing target is filled in
+	outer := WithStage(StageConfig, "example.com", err)
+	var se *StageError
+	if !errors.As(outer, &se) {
+		t.Fatal("expected a StageError")
+	}
+	if se.Stage != StageSocket || se.Target != "example.com" {
+		t.Errorf("got stage %q target %q, want socket/example.com", se.Stage, se.Target)
+	}
+
+	if got := ErrorStage(base); got != StageTrace {
+		t.Errorf("unclassified errors should report %q, got %q", StageTrace, got)
+	}
+}
+
+func TestStage_ExitCode(t *testing.T) {
+	codes := map[Stage]int{
+		StageConfig:  2,
+		StageResolve: 3,
+		StageSocket:  4,
+		StageTrace:   1,
+		StageOutput:  5,
+	}
+	for stage, want := range codes {
+		if got := stage.ExitCode(); got != want {
+			t.Errorf("%s.ExitCode() = %d, want %d", stage, got, want)
+		}
+	}
+}
+
+func TestTracer_ErrorStages(t *testing.T) {
+	t.Run("config", func(t *testing.T) {
+		config := DefaultConfig()
+		config.MaxHops = 0
+		_, err := New(config)
+		if got := ErrorStage(err); err == nil || got != StageConfig {
+			t.Errorf("New with invalid config: stage %q (err %v), want %q", got, err, StageConfig)
+		}
+	})
+
+	t.Run("resolve", func(t *testing.T) {
+		config := DefaultConfig()
+		config.IPv4 = true
+		config.EnableEnrichment = false
+		tracer := &Tracer{config: config, prober: newScriptedProber("192.0.2.1", nil)}
+
+		_, err := tracer.Trace(context.Background(), "2001:db8::1")
+		var se *StageError
+		if !errors.As(err, &se) || se.Stage != StageResolve {
+			t.Fatalf("Trace of an IPv6 literal with IPv4 forced: err %v, want resolve stage", err)
+		}
+		if se.Target != "2001:db8::1" {
+			t.Errorf("Target = %q, want the traced target", se.Target)
+		}
+	})
+
+	t.Run("trace", func(t *testing.T) {
+		config := DefaultConfig()
+		config.EnableEnrichment = false
+		config.Sequential = true
+		prober := newFuncProber(func(ttl, call int) (*probe.Result, error) {
+			return nil, errors.New("unreachable")
+		})
+		tracer := &Tracer{config: config, prober: prober}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := tracer.Trace(ctx, "192.0.2.1")
+		if got := ErrorStage(err); err == nil || got != StageTrace {
+			t.Errorf("cancelled Trace: stage %q (err %v), want %q", got, err, StageTrace)
+		}
+	})
+}
@@ -0,0 +1,121 @@
+package trace
+
+import (
+	"sync"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/enrich"
+	"github.com/KilimcininKorOglu/poros/internal/probe"
+)
+
+// Registry lists the open tracers of a process so their state can be
+// inspected at runtime, e.g. by a diagnostics endpoint. Tracers created
+// with Config.Registry set add themselves and are removed on Close.
+type Registry struct {
+	mu      sync.Mutex
+	tracers []*Tracer
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) add(t *Tracer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tracers = append(r.tracers, t)
+}
+
+func (r *Registry) remove(t *Tracer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, tracer := range r.tracers {
+		if tracer == t {
+			r.tracers = append(r.tracers[:i], r.tracers[i+1:]...)
+			return
+		}
+	}
+}
+
+// States returns the state of every open tracer, oldest first.
+func (r *Registry) States() []TracerState {
+	r.mu.Lock()
+	tracers := append([]*Tracer(nil), r.tracers...)
+	r.mu.Unlock()
+
+	states := make([]TracerState, len(tracers))
+	for i, t := range tracers {
+		states[i] = t.State()
+	}
+	return states
+}
+
+// TracerState is a snapshot of a tracer for diagnostics.
+type TracerState struct {
+	// Method is the probe method name
+	Method string `json:"method"`
+
+	// Active is the trace in progress, if any
+	Active *ActiveTrace `json:"active,omitempty"`
+
+	// Traces is the number of traces started by the tracer
+	Traces int64 `json:"traces"`
+
+	// Probes holds the prober's cumulative packet counters
+	Probes probe.Stats `json:"probes"`
+
+	// Goroutines is the number of probing goroutines running, i.e.
+	// concurrent-mode workers and parallel per-hop probes
+	Goroutines int64 `json:"goroutines"`
+
+	// Caches holds the enrichment cache stats by cache name
+	Caches map[string]enrich.CacheStats `json:"caches,omitempty"`
+}
+
+// ActiveTrace describes a trace in progress.
+type ActiveTrace struct {
+	Target  string    `json:"target"`
+	Started time.Time `json:"started"`
+}
+
+// State returns a snapshot of the tracer. It is safe to call while a
+// trace is running.
+func (t *Tracer) State() TracerState {
+	t.stateMu.Lock()
+	prober := t.prober
+	var active *ActiveTrace
+	if t.active != nil {
+		a := *t.active
+		active = &a
+	}
+	traces := t.traces
+	t.stateMu.Unlock()
+
+	state := TracerState{
+		Active:     active,
+		Traces:     traces,
+		Goroutines: t.goroutines.Load(),
+	}
+	if prober != nil {
+		state.Method = prober.Name()
+		state.Probes = prober.Stats().Add(t.counters.Stats())
+	}
+	if t.enricher != nil {
+		state.Caches = t.enricher.CacheStats()
+	}
+	return state
+}
+
+// setActive records target as the trace in progress; an empty target
+// clears it.
+func (t *Tracer) setActive(target string) {
+	t.stateMu.Lock()
+	defer t.stateMu.Unlock()
+	if target == "" {
+		t.active = nil
+		return
+	}
+	t.active = &ActiveTrace{Target: target, Started: time.Now()}
+	t.traces++
+}
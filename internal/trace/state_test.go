@@ -0,0 +1,110 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/probe"
+)
+
+func TestRegistry_StatesDuringTraces(t *testing.T) {
+	registry := NewRegistry()
+	dest := net.ParseIP("8.8.8.8")
+
+	// Each prober holds its hops long enough for States to see them
+	newTracer := func(sequential bool) *Tracer {
+		prober := newFuncProber(func(ttl, call int) (*probe.Result, error) {
+			time.Sleep(time.Millisecond)
+			if ttl < 4 {
+				return &probe.Result{ResponseIP: net.IPv4(10, 0, 0, byte(ttl)), RTT: time.Millisecond, TTLExpired: true}, nil
+			}
+			return &probe.Result{ResponseIP: dest, RTT: time.Millisecond, Reached: true}, nil
+		})
+
+		config := DefaultConfig()
+		config.ProbeMethod = ProbeUDP
+		config.Sequential = sequential
+		config.ParallelQueries = sequential
+		config.EnableEnrichment = false
+		config.Registry = registry
+		tracer := &Tracer{config: config, prober: prober}
+		registry.add(tracer)
+		return tracer
+	}
+	tracers := []*Tracer{newTracer(true), newTracer(false), newTracer(true)}
+
+	var wg sync.WaitGroup
+	for _, tracer := range tracers {
+		wg.Add(1)
+		go func(tracer *Tracer) {
+			defer wg.Done()
+			for i := 0; i < 3; i++ {
+				if _, err := tracer.Trace(context.Background(), "8.8.8.8"); err != nil {
+					t.Errorf("Trace() error = %v", err)
+				}
+			}
+		}(tracer)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		if _, err := json.Marshal(registry.States()); err != nil {
+			t.Fatalf("json.Marshal(States()) error = %v", err)
+		}
+	}
+
+	states := registry.States()
+	if len(states) != len(tracers) {
+		t.Fatalf("States() has %d tracers, want %d", len(states), len(tracers))
+	}
+	for i, state := range states {
+		if state.Active != nil || state.Traces != 3 || state.Goroutines != 0 || state.Method != "func" {
+			t.Errorf("tracer %d state = %+v, want 3 finished traces", i, state)
+		}
+	}
+
+	tracers[1].Close()
+	if n := len(registry.States()); n != 2 {
+		t.Errorf("States() has %d tracers after Close, want 2", n)
+	}
+}
+
+func TestTracer_StateActive(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	prober := newFuncProber(func(ttl, call int) (*probe.Result, error) {
+		if ttl == 1 && call == 1 {
+			close(started)
+			<-release
+		}
+		return &probe.Result{ResponseIP: net.ParseIP("8.8.8.8"), RTT: time.Millisecond, Reached: true}, nil
+	})
+
+	config := DefaultConfig()
+	config.ProbeMethod = ProbeUDP
+	config.Sequential = true
+	config.EnableEnrichment = false
+	tracer := &Tracer{config: config, prober: prober}
+
+	go tracer.Trace(context.Background(), "8.8.8.8")
+	<-started
+	state := tracer.State()
+	close(release)
+
+	if state.Active == nil || state.Active.Target != "8.8.8.8" || state.Active.Started.IsZero() || state.Traces != 1 {
+		t.Errorf("State() during a trace = %+v, want the active trace", state)
+	}
+}
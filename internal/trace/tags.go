@@ -0,0 +1,72 @@
+package trace
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ParseTags parses repeated key=value tag specifications, e.g.
+// "site=fra1". Keys must be unique and consist of letters, digits and
+// underscores, not starting with a digit, so they can be used as metric
+// labels; values may be empty.
+func ParseTags(specs []string) (map[string]string, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	tags := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		key, value, ok := strings.Cut(spec, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !validTagKey(key) {
+			return nil, fmt.Errorf("%w: %q (want key=value)", ErrInvalidTag, spec)
+		}
+		if _, dup := tags[key]; dup {
+			return nil, fmt.Errorf("%w: duplicate key %q", ErrInvalidTag, key)
+		}
+		tags[key] = value
+	}
+	return tags, nil
+}
+
+// MergeTags returns the default tags overlaid with tags; a key given in
+// both takes its value from tags. It returns nil when both are empty.
+func MergeTags(defaults, tags map[string]string) map[string]string {
+	if len(defaults) == 0 && len(tags) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(defaults)+len(tags))
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range tags {
+		merged[k] = v
+	}
+	return merged
+}
+
+// TagKeys returns the keys of tags in sorted order.
+func TagKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// validTagKey reports whether key is a valid tag key.
+func validTagKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i, c := range key {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package trace
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"none", nil, nil, false},
+		{"single", []string{"site=fra1"}, map[string]string{"site": "fra1"}, false},
+		{"several", []string{"site=fra1", "ticket=NET-1234", "circuit_id=c=7"},
+			map[string]string{"site": "fra1", "ticket": "NET-1234", "circuit_id": "c=7"}, false},
+		{"empty value", []string{"note="}, map[string]string{"note": ""}, false},
+		{"missing value", []string{"site"}, nil, true},
+		{"empty key", []string{"=fra1"}, nil, true},
+		{"key with dash", []string{"circuit-id=7"}, nil, true},
+		{"key starting with digit", []string{"1site=x"}, nil, true},
+		{"duplicate key", []string{"site=fra1", "site=ams2"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTags(tt.specs)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidTag) {
+					t.Errorf("ParseTags(%q) error = %v, want ErrInvalidTag", tt.specs, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTags(%q) error = %v", tt.specs, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTags(%q) = %v, want %v", tt.specs, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeTags(t *testing.T) {
+	defaults := map[string]string{"site": "fra1", "team": "neteng"}
+	got := MergeTags(defaults, map[string]string{"site": "ams2", "ticket": "NET-1"})
+	want := map[string]string{"site": "ams2", "team": "neteng", "ticket": "NET-1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MergeTags() = %v, want %v", got, want)
+	}
+	if defaults["site"] != "fra1" {
+		t.Error("MergeTags() modified the defaults")
+	}
+	if got := MergeTags(nil, nil); got != nil {
+		t.Errorf("MergeTags(nil, nil) = %v, want nil", got)
+	}
+	if keys := TagKeys(want); !reflect.DeepEqual(keys, []string{"site", "team", "ticket"}) {
+		t.Errorf("TagKeys() = %v", keys)
+	}
+}
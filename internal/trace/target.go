@@ -0,0 +1,200 @@
+package trace
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits on DNS names (RFC 1035), applied to the ASCII form.
+const (
+	maxLabelLength = 63
+	maxNameLength  = 253
+)
+
+// labelSeparators maps the dot variants IDNA treats as label separators.
+var labelSeparators = strings.NewReplacer("。", ".", "．", ".", "｡", ".")
+
+// NormalizeTarget returns the canonical form of a target as typed or
+// pasted by a user, so equal targets compare and cache equal:
+//
+//   - surrounding whitespace and a single trailing dot are removed
+//   - IP literals are lowercased; an IPv6 zone ID keeps its case
+//   - host names are lowercased and internationalized labels are
+//     converted to their ASCII (punycode) form, e.g. münchen.example
+//     becomes xn--mnchen-3ya.example
+//
+// Only lowercasing is applied before the punycode conversion, not the full
+// UTS #46 mapping.
+func NormalizeTarget(target string) (string, error) {
+	s := strings.TrimSpace(target)
+	s = strings.TrimSuffix(labelSeparators.Replace(s), ".")
+	if s == "" {
+		return "", fmt.Errorf("%w: empty target", ErrInvalidTarget)
+	}
+
+	addr, zone, hasZone := strings.Cut(s, "%")
+	if net.ParseIP(addr) != nil {
+		if hasZone {
+			if zone == "" {
+				return "", fmt.Errorf("%w %q: empty zone", ErrInvalidTarget, target)
+			}
+			return strings.ToLower(addr) + "%" + zone, nil
+		}
+		return strings.ToLower(addr), nil
+	}
+
+	labels := strings.Split(strings.ToLower(s), ".")
+	for i, label := range labels {
+		if label == "" {
+			return "", fmt.Errorf("%w %q: empty label", ErrInvalidTarget, target)
+		}
+		if utf8.RuneCountInString(label) > maxLabelLength {
+			return "", fmt.Errorf("%w %q: label longer than %d characters", ErrInvalidTarget, target, maxLabelLength)
+		}
+		if !isASCII(label) {
+			label = "xn--" + punycode(label)
+		}
+		if len(label) > maxLabelLength {
+			return "", fmt.Errorf("%w %q: label longer than %d characters", ErrInvalidTarget, target, maxLabelLength)
+		}
+		labels[i] = label
+	}
+
+	name := strings.Join(labels, ".")
+	if len(name) > maxNameLength {
+		return "", fmt.Errorf("%w %q: name longer than %d characters", ErrInvalidTarget, target, maxNameLength)
+	}
+	return name, nil
+}
+
+// ipLiteral parses target as an IP address, ignoring an IPv6 zone such
+// as the "%eth0" of fe80::1%eth0. It returns nil for a host name.
+func ipLiteral(target string) net.IP {
+	addr, _, _ := strings.Cut(target, "%")
+	return net.ParseIP(addr)
+}
+
+// checkZone checks the zone of an IP literal target against the zone
+// probes are sent with: a link-local IPv6 address needs one, and other
+// addresses take none.
+func (t *Tracer) checkZone(target string, ip net.IP) error {
+	_, zone, hasZone := strings.Cut(target, "%")
+	linkLocal := ip.To4() == nil && ip.IsLinkLocalUnicast()
+	switch {
+	case hasZone && !linkLocal:
+		return fmt.Errorf("%w %q: a zone is only valid for link-local IPv6 addresses", ErrInvalidTarget, target)
+	case !linkLocal:
+		return nil
+	case hasZone && zone != t.config.zone():
+		return fmt.Errorf("%w: %s does not match zone %q of the probes", ErrNeedZone, target, t.config.zone())
+	case t.config.zone() == "":
+		return fmt.Errorf("%w (or --interface)", ErrNeedZone)
+	}
+	return nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// Punycode parameters (RFC 3492, section 5).
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+)
+
+// punycode encodes a label as in RFC 3492, without the "xn--" prefix.
+// Labels are at most maxLabelLength runes, so the counters cannot
+// overflow.
+func punycode(label string) string {
+	runes := []rune(label)
+
+	var out strings.Builder
+	for _, r := range runes {
+		if r < punyInitialN {
+			out.WriteRune(r)
+		}
+	}
+	basic := out.Len()
+	if basic > 0 {
+		out.WriteByte('-')
+	}
+
+	n, delta, bias := rune(punyInitialN), 0, punyInitialBias
+	for handled := basic; handled < len(runes); {
+		// Next code point to insert is the smallest one not yet handled
+		m := rune(utf8.MaxRune)
+		for _, r := range runes {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+		delta += int(m-n) * (handled + 1)
+		n = m
+
+		for _, r := range runes {
+			if r < n {
+				delta++
+			}
+			if r != n {
+				continue
+			}
+			q := delta
+			for k := punyBase; ; k += punyBase {
+				t := k - bias
+				if t < punyTMin {
+					t = punyTMin
+				} else if t > punyTMax {
+					t = punyTMax
+				}
+				if q < t {
+					break
+				}
+				out.WriteByte(punyDigit(t + (q-t)%(punyBase-t)))
+				q = (q - t) / (punyBase - t)
+			}
+			out.WriteByte(punyDigit(q))
+			bias = punyAdapt(delta, handled+1, handled == basic)
+			delta = 0
+			handled++
+		}
+		delta++
+		n++
+	}
+	return out.String()
+}
+
+func punyAdapt(delta, points int, first bool) int {
+	if first {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / points
+
+	k := 0
+	for delta > ((punyBase-punyTMin)*punyTMax)/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
+
+func punyDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
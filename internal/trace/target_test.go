@@ -0,0 +1,141 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTarget(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		// Host names
+		{"example.com", "example.com"},
+		{"  Example.COM  ", "example.com"},
+		{"example.com.", "example.com"},
+		{"\texample.com.\n", "example.com"},
+		{"localhost", "localhost"},
+
+		// IP literals
+		{"8.8.8.8", "8.8.8.8"},
+		{" 8.8.8.8 ", "8.8.8.8"},
+		{"2001:DB8::1", "2001:db8::1"},
+		{"2001:Db8:0:0:0:0:0:1", "2001:db8:0:0:0:0:0:1"},
+		{"::FFFF:192.0.2.1", "::ffff:192.0.2.1"},
+		{"FE80::1%Eth0", "fe80::1%Eth0"},
+		{"fe80::ABCD%en0", "fe80::abcd%en0"},
+
+		// Internationalized names
+		{"münchen.example", "xn--mnchen-3ya.example"},
+		{"MÜNCHEN.example", "xn--mnchen-3ya.example"},
+		{"bücher.example.", "xn--bcher-kva.example"},
+		{"пример.рф", "xn--e1afmkfd.xn--p1ai"},
+		{"日本語。jp", "xn--wgv71a119e.jp"},
+		{"例え．テスト", "xn--r8jz45g.xn--zckzah"},
+		{"xn--mnchen-3ya.example", "xn--mnchen-3ya.example"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := NormalizeTarget(tt.input)
+			if err != nil {
+				t.Fatalf("NormalizeTarget(%q) error = %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeTarget(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+
+			// Normalizing is idempotent
+			again, err := NormalizeTarget(got)
+			if err != nil || again != got {
+				t.Errorf("NormalizeTarget(%q) = %q, %v; want unchanged", got, again, err)
+			}
+		})
+	}
+}
+
+func TestNormalizeTarget_Invalid(t *testing.T) {
+	tests := []string{
+		"",
+		"   ",
+		".",
+		"example..com",
+		".example.com",
+		"example.com..",
+		"fe80::1%",
+		strings.Repeat("a", 64) + ".com",
+		strings.Repeat("ü", 60) + ".com",
+		strings.Repeat("a.", 127) + "com",
+	}
+
+	for _, input := range tests {
+		if got, err := NormalizeTarget(input); !errors.Is(err, ErrInvalidTarget) {
+			t.Errorf("NormalizeTarget(%q) = %q, %v; want ErrInvalidTarget", input, got, err)
+		}
+	}
+}
+
+func TestPunycode(t *testing.T) {
+	// Samples from RFC 3492, section 7.1
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"ليهمابتكلموشعربي؟", "egbpdaj6bu4bxfgehfvwxn"},
+		{"他们为什么不说中文", "ihqwcrb4cv8a8dqg056pqjye"},
+		{"Pročprostěnemluvíčesky", "Proprostnemluvesky-uyb24dma41a"},
+		{"למההםפשוטלאמדבריםעברית", "4dbcagdahymbxekheh6e0a7fei0b"},
+		{"3年B組金八先生", "3B-ww4c5e180e575a65lsy2b"},
+		{"安室奈美恵-with-SUPER-MONKEYS", "-with-SUPER-MONKEYS-pc58ag80a8qai00g7n9n"},
+		{"ü", "tda"},
+	}
+
+	for _, tt := range tests {
+		if got := punycode(tt.input); got != tt.want {
+			t.Errorf("punycode(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestTracer_ResolveZone(t *testing.T) {
+	tests := []struct {
+		name      string
+		target    string
+		zone      string
+		iface     string
+		want      string
+		wantErr   error
+		errSubstr string
+	}{
+		{name: "zone from target", target: "fe80::1%eth0", zone: "eth0", want: "fe80::1"},
+		{name: "zone from interface", target: "fe80::1", iface: "eth0", want: "fe80::1"},
+		{name: "zone needed", target: "fe80::1", wantErr: ErrNeedZone, errSubstr: "--interface"},
+		{name: "zone mismatch", target: "fe80::1%eth0", iface: "eth1", wantErr: ErrNeedZone, errSubstr: `zone "eth1"`},
+		{name: "zone on global address", target: "2001:db8::1%eth0", zone: "eth0", wantErr: ErrInvalidTarget},
+		{name: "global address", target: "2001:db8::1", want: "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.Zone = tt.zone
+			config.Interface = tt.iface
+			tracer := &Tracer{config: config}
+
+			ip, err := tracer.resolveTarget(context.Background(), tt.target)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) || !strings.Contains(err.Error(), tt.errSubstr) {
+					t.Errorf("resolveTarget(%q) error = %v, want %v with %q", tt.target, err, tt.wantErr, tt.errSubstr)
+				}
+				return
+			}
+			if err != nil || !ip.Equal(net.ParseIP(tt.want)) {
+				t.Errorf("resolveTarget(%q) = %v, %v, want %s", tt.target, ip, err, tt.want)
+			}
+		})
+	}
+}
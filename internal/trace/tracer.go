@@ -3,8 +3,12 @@ package trace
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/KilimcininKorOglu/poros/internal/enrich"
@@ -15,7 +19,54 @@ import (
 type Tracer struct {
 	config   *Config
 	prober   probe.Prober
+	prober6  bool // prober was created for IPv6; guarded by stateMu
 	enricher *enrich.Enricher
+	// anyFamily is set when the prober serves both address families and
+	// is never replaced for a destination of the other one
+	anyFamily bool
+	// sharedEnricher is set when the enricher belongs to a Session, which
+	// closes it instead of the tracer
+	sharedEnricher bool
+
+	// openProber creates the probers of TraceMethods (nil = newProber)
+	openProber func(config *Config, ipv6 bool) (probe.Prober, error)
+
+	// counters records tracer-level probe events such as retransmissions
+	counters probe.Counters
+
+	// clock reads wall and monotonic time (nil = system clock);
+	// clockSteps counts samples discarded because the clock stepped
+	clock      func() clockReading
+	clockSteps atomic.Int64
+
+	// budget counts the packets of the running trace against MaxPackets
+	budget *packetBudget
+
+	// progress tracks probes planned and completed for Progress
+	progress progressTracker
+
+	// Runtime state for diagnostics. stateMu guards the trace in
+	// progress, the trace count and replacing the prober and prober6.
+	stateMu    sync.Mutex
+	active     *ActiveTrace
+	traces     int64
+	goroutines atomic.Int64
+
+	// Addresses pinned across traces by the resolve policy, and the
+	// discovered NAT64 prefix
+	pinMu  sync.Mutex
+	pinned map[string]pinnedAddr
+	nat64  *net.IPNet
+
+	// ipv4Route caches whether the host has an IPv4 route
+	ipv4Once  sync.Once
+	ipv4Route bool
+
+	// Close releases the prober and enricher once; closed stops the
+	// trace in progress and fails later ones
+	closeOnce sync.Once
+	closed    atomic.Bool
+	closeErr  error
 }
 
 // New creates a new Tracer with the given configuration.
@@ -25,42 +76,134 @@ func New(config *Config) (*Tracer, error) {
 	}
 
 	if err := config.Validate(); err != nil {
-		return nil, err
+		return nil, WithStage(StageConfig, "", err)
 	}
 
 	// Create the appropriate prober based on configuration
+	prober, err := newProber(config, config.IPv6)
+	if err != nil {
+		return nil, WithStage(StageSocket, "", err)
+	}
+
+	return newTracer(config, prober, config.IPv6), nil
+}
+
+// NewWithProber creates a Tracer that sends its probes through prober
+// instead of opening sockets, e.g. a probetest.ScriptedProber in tests.
+// The prober is used for both address families and closed by Close.
+func NewWithProber(config *Config, prober probe.Prober) (*Tracer, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, WithStage(StageConfig, "", err)
+	}
+
+	tracer := newTracer(config, prober, true)
+	tracer.anyFamily = true
+	return tracer, nil
+}
+
+// newTracer creates a Tracer around prober, which was created for IPv6
+// if prober6 is set.
+func newTracer(config *Config, prober probe.Prober, prober6 bool) *Tracer {
+	return newTracerWith(config, prober, prober6, newEnricher(config))
+}
+
+// newTracerWith creates a Tracer that enriches hops with enricher (nil =
+// no enrichment).
+func newTracerWith(config *Config, prober probe.Prober, prober6 bool, enricher *enrich.Enricher) *Tracer {
+	tracer := &Tracer{
+		config:   config,
+		prober:   prober,
+		prober6:  prober6,
+		enricher: enricher,
+	}
+	if config.Registry != nil {
+		config.Registry.add(tracer)
+	}
+	return tracer
+}
+
+// newEnricher creates the enricher configured by config, or returns nil
+// if enrichment is disabled.
+func newEnricher(config *Config) *enrich.Enricher {
+	if !config.EnableEnrichment {
+		return nil
+	}
+
+	enricherConfig := enrich.EnricherConfig{
+		EnableRDNS:   config.EnableRDNS,
+		EnableASN:    config.EnableASN,
+		EnableGeoIP:  config.EnableGeoIP,
+		VerifyRDNS:   config.VerifyRDNS,
+		RDNSTimeout:  int(config.RDNSTimeout / time.Millisecond),
+		ASNTimeout:   int(config.ASNTimeout / time.Millisecond),
+		GeoIPTimeout: int(config.GeoIPTimeout / time.Millisecond),
+		CacheSize:    config.EnrichCacheSize,
+		IPAPIURL:     config.IPAPIURL,
+		IPAPIKey:     config.IPAPIKey,
+		HTTPClient:   config.HTTPClient,
+	}
+	if enricherConfig.HTTPClient == nil {
+		enricherConfig.HTTPClient = &enrich.HTTPClientFactory{UserAgent: enrich.UserAgent(config.Version)}
+	}
+
+	// Use MaxMind if provided
+	if maxmindDB, ok := config.MaxMindDB.(*enrich.MaxMindDB); ok {
+		return enrich.NewEnricherWithMaxMind(enricherConfig, maxmindDB)
+	}
+	return enrich.NewEnricher(enricherConfig)
+}
+
+// newProber creates the prober for the configured probe method and
+// address family.
+func newProber(config *Config, ipv6 bool) (probe.Prober, error) {
 	var prober probe.Prober
 	var err error
 
+	var zone string
+	if ipv6 {
+		zone = config.zone()
+	}
+
 	switch config.ProbeMethod {
 	case ProbeICMP:
 		prober, err = probe.NewICMPProber(probe.ICMPProberConfig{
-			Timeout: config.Timeout,
-			IPv6:    config.IPv6,
+			Timeout:    config.Timeout,
+			IPv6:       ipv6,
+			Identifier: uint16(config.ICMPID),
+			SeqStart:   uint16(config.SeqStart),
+			Via:        config.Via,
+			Zone:       zone,
 		})
 	case ProbeUDP:
 		prober, err = probe.NewUDPProber(probe.UDPProberConfig{
 			Timeout:  config.Timeout,
 			BasePort: config.DestPort,
-			IPv6:     config.IPv6,
+			IPv6:     ipv6,
+			SeqStart: uint16(config.SeqStart),
+			Via:      config.Via,
+			Zone:     zone,
 		})
 	case ProbeTCP:
 		prober, err = probe.NewTCPProber(probe.TCPProberConfig{
-			Timeout: config.Timeout,
-			Port:    config.DestPort,
-			IPv6:    config.IPv6,
+			Timeout:  config.Timeout,
+			Port:     config.DestPort,
+			IPv6:     ipv6,
+			SeqStart: uint16(config.SeqStart),
+			Zone:     zone,
 		})
 	case ProbeParis:
-		// Paris traceroute - determine underlying method
-		var method probe.Method
-		if config.Paris {
-			method = probe.MethodUDP // Default Paris uses UDP
-		}
 		prober, err = probe.NewParisProber(probe.ParisProberConfig{
-			Timeout: config.Timeout,
-			Method:  method,
-			Port:    config.DestPort,
-			IPv6:    config.IPv6,
+			Timeout:  config.Timeout,
+			Method:   config.ProbeMethod.Transport(),
+			Port:     config.DestPort,
+			IPv6:     ipv6,
+			FlowID:   uint16(config.FlowID),
+			SeqStart: uint16(config.SeqStart),
+			Zone:     zone,
 		})
 	default:
 		return nil, fmt.Errorf("unknown probe method: %v", config.ProbeMethod)
@@ -69,43 +212,106 @@ func New(config *Config) (*Tracer, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create prober: %w", err)
 	}
+	return prober, nil
+}
 
-	// Create enricher if enabled
-	var enricher *enrich.Enricher
-	if config.EnableEnrichment {
-		enricherConfig := enrich.EnricherConfig{
-			EnableRDNS:  config.EnableRDNS,
-			EnableASN:   config.EnableASN,
-			EnableGeoIP: config.EnableGeoIP,
-		}
+// CheckMethod opens the sockets a trace with method and the default
+// configuration would use, for IPv6 or IPv4, and closes them again. It
+// returns the kind of socket responses arrive on (see probe.SocketRaw).
+func CheckMethod(method ProbeMethod, ipv6 bool) (string, error) {
+	config := DefaultConfig()
+	config.ProbeMethod = method
+	config.Paris = method == ProbeParis
+	prober, err := newProber(config, ipv6)
+	if err != nil {
+		return "", err
+	}
+	defer prober.Close()
 
-		// Use MaxMind if provided
-		if config.MaxMindDB != nil {
-			if maxmindDB, ok := config.MaxMindDB.(*enrich.MaxMindDB); ok {
-				enricher = enrich.NewEnricherWithMaxMind(enricherConfig, maxmindDB)
-			} else {
-				enricher = enrich.NewEnricher(enricherConfig)
-			}
-		} else {
-			enricher = enrich.NewEnricher(enricherConfig)
-		}
+	if sr, ok := prober.(probe.SocketReporter); ok {
+		return sr.SocketKind(), nil
 	}
+	return "", nil
+}
 
-	return &Tracer{
-		config:   config,
-		prober:   prober,
-		enricher: enricher,
-	}, nil
+// useFamilyProber replaces the prober with one for the address family of
+// dest unless it already is one: an IPv6 prober for IPv6 destinations
+// reached without -6 (IPv6-only hostnames, NAT64), and an IPv4 prober
+// again for a later IPv4 destination. It reports whether it switched to
+// an IPv6 prober.
+func (t *Tracer) useFamilyProber(dest net.IP) (bool, error) {
+	ipv6 := dest.To4() == nil
+	t.stateMu.Lock()
+	current := t.prober6
+	t.stateMu.Unlock()
+	if t.anyFamily || current == ipv6 {
+		return false, nil
+	}
+
+	open := t.openProber
+	if open == nil {
+		open = newProber
+	}
+	prober, err := open(t.config, ipv6)
+	if err != nil {
+		return false, err
+	}
+	t.stateMu.Lock()
+	if t.closed.Load() {
+		t.stateMu.Unlock()
+		prober.Close()
+		return false, ErrTracerClosed
+	}
+	old := t.prober
+	t.prober, t.prober6 = prober, ipv6
+	t.stateMu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+	return ipv6, nil
 }
 
 // Trace performs a traceroute to the specified target.
 func (t *Tracer) Trace(ctx context.Context, target string) (*TraceResult, error) {
+	if t.closed.Load() {
+		return nil, WithStage(StageSocket, target, ErrTracerClosed)
+	}
+	t.setActive(target)
+	defer t.setActive("")
+	t.setPhase(PhaseResolving)
+	defer t.setPhase(PhaseDone)
+
 	// Resolve target to IP
 	dest, err := t.resolveTarget(ctx, target)
 	if err != nil {
-		return nil, err
+		return nil, WithStage(StageResolve, target, err)
+	}
+
+	// Decisions the user did not ask for are recorded in the result
+	var notes []string
+
+	if len(t.config.Via) > 0 && dest.To4() == nil {
+		return nil, WithStage(StageResolve, target, fmt.Errorf("%s resolved to IPv6 address %s: %w", target, dest, ErrInvalidVia))
+	}
+
+	switched, err := t.useFamilyProber(dest)
+	if err != nil {
+		return nil, WithStage(StageSocket, target, err)
+	}
+	if switched {
+		notes = append(notes, "switched to an IPv6 prober for IPv6 destination "+dest.String())
 	}
 
+	targetPTR := t.lookupTargetPTR(ctx, target, dest)
+
+	// Prober counters are cumulative; only this trace's share is reported
+	baseStats := t.probeStats()
+	baseSteps := t.clockSteps.Load()
+	baseRedirects := t.redirectCount()
+	t.budget = newPacketBudget(t.config.MaxPackets)
+	t.setPhase(PhaseProbing)
+	t.planProbes(t.config.FirstHop)
+
 	// Perform the trace
 	// Note: ICMP concurrent mode has issues with shared socket on Windows,
 	// so we use sequential mode for ICMP by default unless explicitly requested
@@ -115,20 +321,62 @@ func (t *Tracer) Trace(ctx context.Context, target string) (*TraceResult, error)
 		// ICMP concurrent mode is problematic on Windows due to shared socket
 		// responses getting mixed up between goroutines
 		useConcurrent = false
+		notes = append(notes, "concurrent mode disabled for ICMP probes (shared socket)")
+	}
+
+	// Skipping a private prefix relies on probing hops in order
+	if t.config.SkipPrivatePrefix && useConcurrent {
+		useConcurrent = false
+		notes = append(notes, "sequential mode forced by --skip-private-prefix")
 	}
-	
+
+	parallel := t.config.ParallelQueries && !useConcurrent
+	if parallel && t.config.ProbeMethod == ProbeICMP {
+		parallel = false
+		notes = append(notes, "parallel queries disabled for ICMP probes (shared socket)")
+	}
+
+	var skipped *SkippedHops
 	if useConcurrent {
 		hops, err = t.traceConcurrent(ctx, dest)
+		t.replanProbes(t.verifyProbes())
 	} else {
-		hops, err = t.traceSequential(ctx, dest)
+		firstTTL := t.config.FirstHop
+		var end *skipEnd
+		if t.config.SkipPrivatePrefix {
+			skipped, end = t.skipPrivatePrefix(ctx, dest)
+			if skipped != nil {
+				firstTTL = skipped.LastHop + 1
+				remaining := t.remainingProbes(firstTTL)
+				if end != nil {
+					remaining--
+				}
+				t.replanProbes(remaining)
+			}
+		}
+		hops, err = t.traceSequential(ctx, dest, firstTTL, parallel, end)
 	}
 
 	if err != nil {
-		return nil, err
+		return nil, WithStage(StageTrace, target, err)
 	}
 
+	// Measure end-host loss separately from the path. A partial path
+	// never probes the destination.
+	var destRTTs []float64
+	if t.config.VerifyDest > 0 {
+		if t.config.LastHop > 0 {
+			notes = append(notes, "destination verification skipped for partial path")
+		} else if !t.budget.isExhausted() {
+			destRTTs = t.verifyDestination(ctx, dest)
+		}
+	}
+	t.replanProbes(0)
+
 	// Enrich hops with rDNS, ASN, GeoIP
+	var enrichRetry *EnrichRetry
 	if t.enricher != nil {
+		t.setPhase(PhaseEnriching)
 		// Collect IPs from hops
 		ips := make([]net.IP, 0, len(hops))
 		for _, hop := range hops {
@@ -137,50 +385,137 @@ func (t *Tracer) Trace(ctx context.Context, target string) (*TraceResult, error)
 			}
 		}
 
-		// Get enrichment results
+		// Get enrichment results, and give lookups that failed (rate
+		// limits, lost DNS answers) a second chance
 		enrichResults := t.enricher.EnrichIPs(ctx, ips)
+		if retried, recovered := t.enricher.RetryMissing(ctx, enrichResults, enrich.DefaultRetryDelay); retried > 0 {
+			enrichRetry = &EnrichRetry{Retried: retried, Recovered: recovered}
+		}
 
 		// Apply results to hops
 		for i := range hops {
 			if hops[i].IP != nil {
-				if result := enrichResults[hops[i].IP.String()]; result != nil {
-					hops[i].Hostname = result.Hostname
-					if result.ASN != nil {
-						hops[i].ASN = &ASNInfo{
-							Number:  result.ASN.Number,
-							Org:     result.ASN.Org,
-							Country: result.ASN.Country,
-						}
-					}
-					if result.Geo != nil {
-						hops[i].Geo = &GeoInfo{
-							Country:     result.Geo.Country,
-							CountryCode: result.Geo.CountryCode,
-							City:        result.Geo.City,
-							Latitude:    result.Geo.Latitude,
-							Longitude:   result.Geo.Longitude,
-						}
-					}
-				}
+				applyEnrichment(&hops[i], enrichResults[hops[i].IP.String()])
 			}
 		}
 	}
 
 	// Build and return the result
-	return t.buildResult(target, dest, hops), nil
+	result := t.buildResult(target, dest, hops, skipped)
+	result.TargetPTR = <-targetPTR
+	result.EnrichRetry = enrichRetry
+	applyDestinationStats(&result.Summary, destRTTs, t.config.RTTOutlier)
+	if t.config.AppProbe {
+		if note := t.runAppProbe(ctx, result); note != "" {
+			notes = append(notes, note)
+		}
+	}
+	result.Mode = ModeSequential
+	if useConcurrent {
+		result.Mode = ModeConcurrent
+		result.Concurrency = t.effectiveConcurrency()
+	}
+	if sr, ok := t.prober.(probe.SocketReporter); ok {
+		result.ProbeSocket = sr.SocketKind()
+		if result.ProbeSocket == probe.SocketDgram {
+			notes = append(notes, "raw socket unavailable, using unprivileged ICMP datagram socket")
+		}
+	}
+	if n := t.clockSteps.Load() - baseSteps; n > 0 {
+		notes = append(notes, fmt.Sprintf("clock step detected: %d samples discarded", n))
+	}
+	result.Redirects = t.redirectsSince(baseRedirects)
+	for _, r := range result.Redirects {
+		notes = append(notes, redirectNote(r))
+	}
+	for _, hop := range result.Hops {
+		if hop.SendFailed() {
+			notes = append(notes, fmt.Sprintf("hop %d: all %d probes failed to send (%s)", hop.Number, hop.SendErrors, hop.SendError))
+		}
+		if hop.WeakMatches > 0 {
+			notes = append(notes, weakMatchNote(hop))
+		}
+	}
+	if t.budget.isExhausted() {
+		result.StoppedReason = StopPacketBudget
+		notes = append(notes, fmt.Sprintf("packet budget of %d exhausted", t.config.MaxPackets))
+	}
+	result.Notes = notes
+
+	stats := t.probeStats().Sub(baseStats)
+	stats.Method = t.prober.Name()
+	result.ProbeStats = &stats
+
+	return result, nil
+}
+
+// applyEnrichment copies enrichment data onto a hop.
+func applyEnrichment(hop *Hop, result *enrich.EnrichmentResult) {
+	if result == nil {
+		return
+	}
+
+	hop.Hostname = result.Hostname
+	hop.HostnameVerified = result.HostnameVerified
+	if result.ASN != nil {
+		hop.ASN = &ASNInfo{
+			Number:     result.ASN.Number,
+			Org:        result.ASN.Org,
+			Country:    result.ASN.Country,
+			Prefix:     result.ASN.Prefix,
+			AltNumbers: result.ASN.AltNumbers,
+			Source:     result.ASN.Source,
+		}
+	}
+	if result.Geo != nil {
+		hop.Geo = &GeoInfo{
+			Country:     result.Geo.Country,
+			CountryCode: result.Geo.CountryCode,
+			City:        result.Geo.City,
+			Region:      result.Geo.Region,
+			RegionCode:  result.Geo.RegionCode,
+			Latitude:    result.Geo.Latitude,
+			Longitude:   result.Geo.Longitude,
+			ISP:         result.Geo.ISP,
+			Hosting:     result.Geo.Hosting,
+			Proxy:       result.Geo.Proxy,
+			Mobile:      result.Geo.Mobile,
+			Source:      result.Geo.Source,
+		}
+	}
 }
 
-// Close releases resources held by the tracer.
+// Close releases resources held by the tracer. It may be called more
+// than once, and while a trace runs, which then stops with
+// ErrTracerClosed; later traces fail with it.
 func (t *Tracer) Close() error {
+	t.closeOnce.Do(func() {
+		t.closed.Store(true)
+		t.closeErr = t.release()
+	})
+	return t.closeErr
+}
+
+// release removes the tracer from its registry and closes the prober and
+// enricher. A trace in progress fails its next probe with a closed
+// socket and stops.
+func (t *Tracer) release() error {
 	var errs []error
 
-	if t.prober != nil {
-		if err := t.prober.Close(); err != nil {
+	if t.config.Registry != nil {
+		t.config.Registry.remove(t)
+	}
+
+	t.stateMu.Lock()
+	prober := t.prober
+	t.stateMu.Unlock()
+	if prober != nil {
+		if err := prober.Close(); err != nil {
 			errs = append(errs, err)
 		}
 	}
 
-	if t.enricher != nil {
+	if t.enricher != nil && !t.sharedEnricher {
 		if err := t.enricher.Close(); err != nil {
 			errs = append(errs, err)
 		}
@@ -195,17 +530,32 @@ func (t *Tracer) Close() error {
 // resolveTarget resolves a hostname or IP string to a net.IP.
 func (t *Tracer) resolveTarget(ctx context.Context, target string) (net.IP, error) {
 	// Check if target is already an IP address
-	if ip := net.ParseIP(target); ip != nil {
+	if ip := ipLiteral(target); ip != nil {
 		// Apply IPv4/IPv6 preference
 		if t.config.IPv4 && ip.To4() == nil {
 			return nil, fmt.Errorf("%s is an IPv6 address but IPv4 was requested", target)
 		}
-		if t.config.IPv6 && ip.To4() != nil {
-			return nil, fmt.Errorf("%s is an IPv4 address but IPv6 was requested", target)
+		if err := t.checkZone(target, ip); err != nil {
+			return nil, err
+		}
+
+		// IPv4 literals are reached through NAT64 on IPv6-only hosts
+		if ip.To4() != nil {
+			synth, ok, err := t.translateNAT64(ctx, ip)
+			if ok {
+				return synth, nil
+			}
+			if t.config.IPv6 {
+				return nil, fmt.Errorf("%s is an IPv4 address but IPv6 was requested: %w", target, err)
+			}
 		}
 		return ip, nil
 	}
 
+	if ip := t.lookupPinned(target); ip != nil {
+		return ip, nil
+	}
+
 	// Resolve hostname
 	var network string
 	switch {
@@ -217,7 +567,7 @@ func (t *Tracer) resolveTarget(ctx context.Context, target string) (net.IP, erro
 		network = "ip" // Any
 	}
 
-	ips, err := net.DefaultResolver.LookupIP(ctx, network, target)
+	ips, ttl, err := t.resolver().Resolve(ctx, network, target)
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve %s: %w", target, err)
 	}
@@ -226,103 +576,287 @@ func (t *Tracer) resolveTarget(ctx context.Context, target string) (net.IP, erro
 		return nil, fmt.Errorf("no IP addresses found for %s", target)
 	}
 
-	// Prefer IPv4 unless IPv6 is explicitly requested
-	if !t.config.IPv6 {
+	ip := preferredAddress(ips, t.config.IPv6)
+	t.pin(target, ip, ttl)
+
+	return ip, nil
+}
+
+// preferredAddress picks the address to trace, preferring IPv4 unless
+// IPv6 is explicitly requested.
+func preferredAddress(ips []net.IP, wantIPv6 bool) net.IP {
+	if !wantIPv6 {
 		for _, ip := range ips {
 			if ip.To4() != nil {
-				return ip, nil
+				return ip
 			}
 		}
 	}
-
-	return ips[0], nil
+	return ips[0]
 }
 
-// traceSequential performs a sequential traceroute.
-func (t *Tracer) traceSequential(ctx context.Context, dest net.IP) ([]Hop, error) {
+// traceSequential performs a sequential traceroute starting at firstTTL.
+// With parallel set, the probes of each hop are sent at once.
+func (t *Tracer) traceSequential(ctx context.Context, dest net.IP, firstTTL int, parallel bool, end *skipEnd) ([]Hop, error) {
 	hops := make([]Hop, 0, t.config.MaxHops)
 
-	for ttl := t.config.FirstHop; ttl <= t.config.MaxHops; ttl++ {
+	for ttl := firstTTL; ttl <= t.config.lastTTL(); ttl++ {
 		select {
 		case <-ctx.Done():
 			return hops, ctx.Err()
 		default:
 		}
+		if t.closed.Load() {
+			return hops, ErrTracerClosed
+		}
 
-		hop := t.probeHop(ctx, dest, ttl)
-		
-		// Enrich this hop immediately if enricher is available
-		if t.enricher != nil && hop.IP != nil {
-			enrichResults := t.enricher.EnrichIPs(ctx, []net.IP{hop.IP})
-			if result := enrichResults[hop.IP.String()]; result != nil {
-				hop.Hostname = result.Hostname
-				if result.ASN != nil {
-					hop.ASN = &ASNInfo{
-						Number:  result.ASN.Number,
-						Org:     result.ASN.Org,
-						Country: result.ASN.Country,
-					}
-				}
-				if result.Geo != nil {
-					hop.Geo = &GeoInfo{
-						Country:     result.Geo.Country,
-						CountryCode: result.Geo.CountryCode,
-						City:        result.Geo.City,
-						Latitude:    result.Geo.Latitude,
-						Longitude:   result.Geo.Longitude,
-					}
-				}
-			}
+		// The probe that ended a skip of the private prefix is the first
+		// sample of its hop
+		var hop Hop
+		switch {
+		case end != nil && end.ttl == ttl:
+			hop = t.probeHopN(ctx, dest, ttl, t.config.ProbeCount-1, end.result)
+		case parallel:
+			hop = t.probeHopParallel(ctx, dest, ttl)
+		default:
+			hop = t.probeHop(ctx, dest, ttl)
 		}
-		
-		// Call OnHop callback for real-time output
-		if t.config.OnHop != nil {
-			t.config.OnHop(&hop)
+
+		// The destination hop gets its extra probes before it is shown
+		reached := hop.Responded && hop.IP != nil && hop.IP.Equal(dest)
+		if reached {
+			t.topUpDestination(ctx, dest, &hop)
 		}
-		
+		t.streamHop(ctx, &hop)
 		hops = append(hops, hop)
 
-		// Check if we've reached the destination
-		if hop.Responded && hop.IP != nil && hop.IP.Equal(dest) {
+		if reached {
+			t.replanProbes(t.verifyProbes())
 			break
 		}
+		// A hop the packet budget left unprobed ends the trace
+		if hop.Unprobed {
+			t.replanProbes(0)
+			break
+		}
+		t.replanProbes(t.remainingProbes(ttl + 1))
 	}
 
 	return hops, nil
 }
 
+// streamHop enriches a hop as soon as it is probed, if an enricher is
+// available, and passes it to the OnHop callback for real-time output.
+func (t *Tracer) streamHop(ctx context.Context, hop *Hop) {
+	if t.enricher != nil && hop.IP != nil {
+		enrichResults := t.enricher.EnrichIPs(ctx, []net.IP{hop.IP})
+		applyEnrichment(hop, enrichResults[hop.IP.String()])
+	}
+	if t.config.OnHop != nil {
+		t.config.OnHop(hop)
+	}
+}
+
+// probeStats returns the prober's counters combined with the tracer's own.
+func (t *Tracer) probeStats() probe.Stats {
+	return t.prober.Stats().Add(t.counters.Stats())
+}
+
 // probeHop sends multiple probes for a single hop and aggregates the results.
 func (t *Tracer) probeHop(ctx context.Context, dest net.IP, ttl int) Hop {
-	hop := Hop{
-		Number: ttl,
-		RTTs:   make([]float64, 0, t.config.ProbeCount),
-	}
+	return t.probeHopN(ctx, dest, ttl, t.config.ProbeCount)
+}
 
-	var lastIP net.IP
-	successCount := 0
+// probeHopN sends count probes one at a time for a single hop and
+// aggregates their results after sent, the results of probes already
+// sent to it (nil for a timeout).
+func (t *Tracer) probeHopN(ctx context.Context, dest net.IP, ttl, count int, sent ...*probe.Result) Hop {
+	results := make([]*probe.Result, 0, len(sent)+count)
+	results = append(results, sent...)
+	timedOut := len(sent) > 0 && sent[len(sent)-1] == nil
+	var sendErrs sendErrors
 
-	for i := 0; i < t.config.ProbeCount; i++ {
+	for i := 0; i < count; i++ {
 		select {
 		case <-ctx.Done():
 			break
 		default:
 		}
 
-		result, err := t.prober.Probe(ctx, dest, ttl)
+		if err := t.config.Pause.Wait(ctx); err != nil {
+			results = append(results, nil)
+			continue
+		}
+		result, err := t.sendProbe(ctx, dest, ttl)
+		if errors.Is(err, ErrPacketBudget) || errors.Is(err, probe.ErrSocketClosed) {
+			break
+		}
+		if timedOut {
+			t.counters.CountRetransmission()
+		}
+		if errors.Is(err, ErrClockStep) {
+			continue
+		}
+		if sendErrs.add(err) {
+			// Never sent - recorded as -1, but not as a timeout
+			results = append(results, nil)
+			continue
+		}
 		if err != nil {
-			// Timeout or error - record as -1
+			// Timeout or error - recorded as -1
+			results = append(results, nil)
+			timedOut = true
+			continue
+		}
+		timedOut = false
+		results = append(results, result)
+	}
+
+	hop := newHop(ttl, results, t.config.RTTOutlier)
+	hop.Unprobed = len(results) == 0 && t.budget.isExhausted()
+	sendErrs.apply(&hop)
+	return hop
+}
+
+// probeHopParallel sends all probes for a hop at once and waits for them
+// under a shared deadline, so a silent hop costs a single timeout. Replies
+// are recorded in the order of their sequence numbers.
+func (t *Tracer) probeHopParallel(ctx context.Context, dest net.IP, ttl int) Hop {
+	// Wait before starting the shared deadline
+	t.config.Pause.Wait(ctx)
+	ctx, cancel := context.WithTimeout(ctx, t.config.Timeout)
+	defer cancel()
+
+	results := make([]*probe.Result, t.config.ProbeCount)
+	discarded := make([]bool, t.config.ProbeCount)
+	errs := make([]error, t.config.ProbeCount)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			t.goroutines.Add(1)
+			defer t.goroutines.Add(-1)
+			result, err := t.sendProbe(ctx, dest, ttl)
+			if err == nil {
+				results[i] = result
+			}
+			errs[i] = err
+			discarded[i] = errors.Is(err, ErrClockStep) || errors.Is(err, ErrPacketBudget)
+		}(i)
+	}
+	wg.Wait()
+
+	// Samples taken across a clock step and probes the budget refused are
+	// dropped, not counted as lost
+	kept := results[:0]
+	var sendErrs sendErrors
+	for i, result := range results {
+		if !discarded[i] {
+			kept = append(kept, result)
+			sendErrs.add(errs[i])
+		}
+	}
+	results = kept
+
+	// Probes may have been sent in any order; put the replies back in
+	// sequence order, leaving lost probes where they are
+	var replies []*probe.Result
+	for _, result := range results {
+		if result != nil {
+			replies = append(replies, result)
+		}
+	}
+	sort.SliceStable(replies, func(i, j int) bool { return replies[i].Seq < replies[j].Seq })
+	for i := range results {
+		if results[i] != nil {
+			results[i], replies = replies[0], replies[1:]
+		}
+	}
+
+	hop := newHop(ttl, results, t.config.RTTOutlier)
+	hop.Unprobed = len(results) == 0 && t.budget.isExhausted()
+	sendErrs.apply(&hop)
+	return hop
+}
+
+// weakMatchNote explains that replies of hop were matched by a truncated
+// ICMP quote and may be misattributed.
+func weakMatchNote(hop Hop) string {
+	return fmt.Sprintf("hop %d: %d of %d replies matched only by protocol and destination (truncated ICMP quote) and may belong to another probe",
+		hop.Number, hop.WeakMatches, len(hop.RTTs))
+}
+
+// sendErrors counts the probes of a hop that could not be sent.
+type sendErrors struct {
+	count int
+	last  string
+}
+
+// add counts err if it is a send error and reports whether it was one.
+func (s *sendErrors) add(err error) bool {
+	se := probe.AsSendError(err)
+	if se == nil {
+		return false
+	}
+	s.count++
+	s.last = se.Errno()
+	return true
+}
+
+// apply records the send errors on hop.
+func (s *sendErrors) apply(hop *Hop) {
+	hop.SendErrors = s.count
+	hop.SendError = s.last
+}
+
+// newHop aggregates the probe results for a hop, with statistics under
+// policy; nil results are probes without a reply.
+func newHop(ttl int, results []*probe.Result, policy OutlierPolicy) Hop {
+	hop := Hop{
+		Number: ttl,
+		RTTs:   make([]float64, 0, len(results)),
+	}
+
+	var lastIP net.IP
+	sentAt := make([]time.Time, len(results))
+	timed := false
+	for i, result := range results {
+		if result == nil {
 			hop.RTTs = append(hop.RTTs, -1)
 			continue
 		}
 
-		// Record successful probe
 		rtt := float64(result.RTT.Microseconds()) / 1000.0 // Convert to ms
 		hop.RTTs = append(hop.RTTs, rtt)
-		successCount++
+		sentAt[i] = result.SentAt
+		timed = timed || !result.SentAt.IsZero()
 
 		if result.ResponseIP != nil {
 			lastIP = result.ResponseIP
 		}
+		if result.ResponseKind != "" {
+			hop.ResponseKind = result.ResponseKind
+		}
+		if result.SourceRouteRejected {
+			hop.SourceRouteRejected = true
+		}
+		if result.MatchQuality == probe.MatchWeak {
+			hop.WeakMatches++
+		}
+		if result.Interface != nil {
+			hop.Interface = &InterfaceInfo{
+				Role:  result.Interface.Role,
+				Index: result.Interface.Index,
+				Name:  result.Interface.Name,
+				MTU:   result.Interface.MTU,
+				IP:    result.Interface.IP,
+			}
+		}
+	}
+
+	if timed {
+		hop.SentAt = sentAt
 	}
 
 	// Set hop IP if we got any response
@@ -331,21 +865,22 @@ func (t *Tracer) probeHop(ctx context.Context, dest net.IP, ttl int) Hop {
 		hop.Responded = true
 	}
 
-	// Calculate statistics
-	hop.AvgRTT, hop.MinRTT, hop.MaxRTT, hop.Jitter = calculateRTTStats(hop.RTTs)
-	hop.LossPercent = calculateLossPercent(hop.RTTs)
+	hop.UpdateStatsWith(policy)
 
 	return hop
 }
 
 // buildResult creates a TraceResult from the collected hops.
-func (t *Tracer) buildResult(target string, dest net.IP, hops []Hop) *TraceResult {
+// skipped may be nil if no private prefix was fast-forwarded.
+func (t *Tracer) buildResult(target string, dest net.IP, hops []Hop, skipped *SkippedHops) *TraceResult {
 	result := &TraceResult{
 		Target:      target,
 		ResolvedIP:  dest,
 		Timestamp:   time.Now(),
 		ProbeMethod: t.prober.Name(),
 		Hops:        hops,
+		Skipped:     skipped,
+		Via:         t.config.Via,
 		Completed:   false,
 	}
 
@@ -356,15 +891,24 @@ func (t *Tracer) buildResult(target string, dest net.IP, hops []Hop) *TraceResul
 			result.Completed = true
 		}
 	}
+	if !result.Completed && t.config.LastHop > 0 {
+		result.StoppedReason = StopLastHop
+	}
 
 	// Calculate summary statistics
-	result.Summary = t.calculateSummary(hops)
+	result.Summary = Summarize(hops)
+	result.Summary.LastTransitHop = LastTransitHop(hops, dest)
+	result.Summary.TotalHops += skipped.Count()
+
+	result.Meta = t.buildMeta(target, dest)
+	result.TranslatedVia = t.translatedVia(dest)
 
 	return result
 }
 
-// calculateSummary calculates aggregate statistics for the trace.
-func (t *Tracer) calculateSummary(hops []Hop) Summary {
+// Summarize calculates aggregate statistics for a trace over hops. The
+// caller adds what depends on the destination, such as LastTransitHop.
+func Summarize(hops []Hop) Summary {
 	summary := Summary{
 		TotalHops: len(hops),
 	}
@@ -373,6 +917,9 @@ func (t *Tracer) calculateSummary(hops []Hop) Summary {
 	var totalLoss float64
 	respondingHops := 0
 
+	// Each hop's loss counts the same whatever its probe count, so the
+	// extra probes of the destination hop (DestProbeCount) do not
+	// outweigh the path
 	for _, hop := range hops {
 		if hop.AvgRTT > 0 {
 			totalRTT += hop.AvgRTT
@@ -393,9 +940,29 @@ func (t *Tracer) calculateSummary(hops []Hop) Summary {
 		}
 	}
 
+	summary.PerAS = CalculatePerAS(hops)
+
 	return summary
 }
 
+// UpdateStats recalculates the hop's RTT statistics and loss from RTTs.
+func (h *Hop) UpdateStats() {
+	h.UpdateStatsWith(OutlierNone)
+}
+
+// UpdateStatsWith is UpdateStats with outlying RTT samples handled by
+// policy. LastRTT and the loss are taken from every sample, and Outliers
+// counts the samples the policy clipped or dropped.
+func (h *Hop) UpdateStatsWith(policy OutlierPolicy) {
+	if n := len(h.RTTs); n > 0 {
+		h.LastRTT = h.RTTs[n-1]
+	}
+	samples, outliers := policy.samples(h.RTTs)
+	h.AvgRTT, h.MinRTT, h.MaxRTT, h.Jitter = calculateRTTStats(samples)
+	h.Outliers = outliers
+	h.LossPercent = calculateLossPercent(h.RTTs)
+}
+
 // calculateRTTStats calculates RTT statistics from a slice of RTT values.
 // Negative values are treated as timeouts and excluded from calculations.
 func calculateRTTStats(rtts []float64) (avg, min, max, jitter float64) {
@@ -2,11 +2,18 @@ package trace
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net"
 	"os"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/enrich"
+	"github.com/KilimcininKorOglu/poros/internal/probe"
+	"github.com/KilimcininKorOglu/poros/internal/probe/probetest"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -75,6 +82,138 @@ func TestConfig_Validate(t *testing.T) {
 			config:  Config{MaxHops: 30, ProbeCount: 3, Timeout: time.Second, FirstHop: 31},
 			wantErr: ErrInvalidFirstHop,
 		},
+		{
+			name:    "invalid dest port (negative)",
+			config:  Config{MaxHops: 30, ProbeCount: 3, Timeout: time.Second, FirstHop: 1, DestPort: -1},
+			wantErr: ErrInvalidPort,
+		},
+		{
+			name:    "invalid dest port (>65535)",
+			config:  Config{MaxHops: 30, ProbeCount: 3, Timeout: time.Second, FirstHop: 1, DestPort: 65536},
+			wantErr: ErrInvalidPort,
+		},
+		{
+			name:    "valid partial path",
+			config:  Config{MaxHops: 30, ProbeCount: 3, Timeout: time.Second, FirstHop: 5, LastHop: 9},
+			wantErr: nil,
+		},
+		{
+			name:    "last hop before first hop",
+			config:  Config{MaxHops: 30, ProbeCount: 3, Timeout: time.Second, FirstHop: 5, LastHop: 4},
+			wantErr: ErrInvalidLastHop,
+		},
+		{
+			name:    "last hop beyond max hops",
+			config:  Config{MaxHops: 30, ProbeCount: 3, Timeout: time.Second, FirstHop: 1, LastHop: 31},
+			wantErr: ErrInvalidLastHop,
+		},
+		{
+			name:    "invalid max concurrency (negative)",
+			config:  Config{MaxHops: 30, ProbeCount: 3, Timeout: time.Second, FirstHop: 1, MaxConcurrency: -1},
+			wantErr: ErrInvalidConcurrency,
+		},
+		{
+			name:    "invalid max concurrency (>512)",
+			config:  Config{MaxHops: 30, ProbeCount: 3, Timeout: time.Second, FirstHop: 1, MaxConcurrency: 513},
+			wantErr: ErrInvalidConcurrency,
+		},
+		{
+			name:    "max concurrency at limit",
+			config:  Config{MaxHops: 30, ProbeCount: 3, Timeout: time.Second, FirstHop: 1, MaxConcurrency: 512},
+			wantErr: nil,
+		},
+		{
+			name:    "invalid verify dest (>100)",
+			config:  Config{MaxHops: 30, ProbeCount: 3, Timeout: time.Second, FirstHop: 1, VerifyDest: 101},
+			wantErr: ErrInvalidVerifyDest,
+		},
+		{
+			name:    "invalid dest probe count (>100)",
+			config:  Config{MaxHops: 30, ProbeCount: 3, Timeout: time.Second, FirstHop: 1, DestProbeCount: 101},
+			wantErr: ErrInvalidDestProbeCount,
+		},
+		{
+			name:    "app probe without TCP",
+			config:  Config{MaxHops: 30, ProbeCount: 3, Timeout: time.Second, FirstHop: 1, ProbeMethod: ProbeUDP, AppProbe: true},
+			wantErr: ErrInvalidAppProbe,
+		},
+		{
+			name:    "invalid ICMP identifier (>65535)",
+			config:  Config{MaxHops: 30, ProbeCount: 3, Timeout: time.Second, FirstHop: 1, ICMPID: 70000},
+			wantErr: ErrInvalidPacketID,
+		},
+		{
+			name:    "invalid sequence start (negative)",
+			config:  Config{MaxHops: 30, ProbeCount: 3, Timeout: time.Second, FirstHop: 1, SeqStart: -1},
+			wantErr: ErrInvalidPacketID,
+		},
+		{
+			name:    "invalid tag key",
+			config:  Config{MaxHops: 30, ProbeCount: 3, Timeout: time.Second, FirstHop: 1, Tags: map[string]string{"circuit-id": "x"}},
+			wantErr: ErrInvalidTag,
+		},
+		{
+			name: "too many via routers",
+			config: Config{MaxHops: 30, ProbeCount: 3, Timeout: time.Second, FirstHop: 1,
+				Via: make([]net.IP, probe.MaxViaHops+1)},
+			wantErr: ErrTooManyVia,
+		},
+		{
+			name:    "IPv6 via router",
+			config:  Config{MaxHops: 30, ProbeCount: 3, Timeout: time.Second, FirstHop: 1, Via: []net.IP{net.ParseIP("2001:db8::1")}},
+			wantErr: ErrInvalidVia,
+		},
+		{
+			name: "via with TCP probes",
+			config: Config{MaxHops: 30, ProbeCount: 3, Timeout: time.Second, FirstHop: 1, ProbeMethod: ProbeTCP,
+				Via: []net.IP{net.ParseIP("10.0.0.5")}},
+			wantErr: ErrInvalidVia,
+		},
+		{
+			name:    "valid via router",
+			config:  Config{MaxHops: 30, ProbeCount: 3, Timeout: time.Second, FirstHop: 1, Via: []net.IP{net.ParseIP("10.0.0.5")}},
+			wantErr: nil,
+		},
+		{
+			name:    "invalid packets per second (negative)",
+			config:  Config{MaxHops: 30, ProbeCount: 3, Timeout: time.Second, FirstHop: 1, PacketsPerSecond: -5},
+			wantErr: ErrInvalidRate,
+		},
+		{
+			name:    "invalid max packets (negative)",
+			config:  Config{MaxHops: 30, ProbeCount: 3, Timeout: time.Second, FirstHop: 1, MaxPackets: -1},
+			wantErr: ErrInvalidMaxPackets,
+		},
+		{
+			name:    "invalid enrichment timeout (negative)",
+			config:  Config{MaxHops: 30, ProbeCount: 3, Timeout: time.Second, FirstHop: 1, ASNTimeout: -time.Second},
+			wantErr: ErrInvalidEnrichment,
+		},
+		{
+			name:    "invalid GeoIP endpoint URL",
+			config:  Config{MaxHops: 30, ProbeCount: 3, Timeout: time.Second, FirstHop: 1, IPAPIURL: "ftp://geo.example.net/"},
+			wantErr: ErrInvalidIPAPIURL,
+		},
+		{
+			name:    "both IPv4 and IPv6 forced",
+			config:  Config{MaxHops: 30, ProbeCount: 3, Timeout: time.Second, FirstHop: 1, IPv4: true, IPv6: true},
+			wantErr: ErrIPVersionConflict,
+		},
+		{
+			name:    "invalid source IP",
+			config:  Config{MaxHops: 30, ProbeCount: 3, Timeout: time.Second, FirstHop: 1, SourceIP: net.IP{10, 0, 0}},
+			wantErr: ErrInvalidSourceIP,
+		},
+		{
+			name:    "IPv6 source with IPv4 forced",
+			config:  Config{MaxHops: 30, ProbeCount: 3, Timeout: time.Second, FirstHop: 1, IPv4: true, SourceIP: net.ParseIP("2001:db8::1")},
+			wantErr: ErrSourceIPFamily,
+		},
+		{
+			name:    "valid IPv4 source with IPv4 forced",
+			config:  Config{MaxHops: 30, ProbeCount: 3, Timeout: time.Second, FirstHop: 1, IPv4: true, SourceIP: net.ParseIP("192.0.2.1")},
+			wantErr: nil,
+		},
 	}
 
 	for _, tt := range tests {
@@ -87,6 +226,72 @@ func TestConfig_Validate(t *testing.T) {
 	}
 }
 
+func TestParseProbeMethod(t *testing.T) {
+	for _, m := range ProbeMethods {
+		got, err := ParseProbeMethod(m.String())
+		if err != nil || got != m {
+			t.Errorf("ParseProbeMethod(%q) = %v, %v; want %v", m.String(), got, err, m)
+		}
+	}
+
+	if got, err := ParseProbeMethod(" TCP "); err != nil || got != ProbeTCP {
+		t.Errorf("ParseProbeMethod(\" TCP \") = %v, %v; want tcp", got, err)
+	}
+	for _, name := range []string{"", "tpc", "unknown"} {
+		if _, err := ParseProbeMethod(name); !errors.Is(err, ErrUnknownProbeMethod) {
+			t.Errorf("ParseProbeMethod(%q) error = %v, want ErrUnknownProbeMethod", name, err)
+		}
+	}
+}
+
+func TestProbeMethod_Transport(t *testing.T) {
+	tests := map[ProbeMethod]probe.Method{
+		ProbeICMP:  probe.MethodICMP,
+		ProbeUDP:   probe.MethodUDP,
+		ProbeTCP:   probe.MethodTCP,
+		ProbeParis: probe.MethodUDP,
+	}
+	for m, want := range tests {
+		if got := m.Transport(); got != want {
+			t.Errorf("%v.Transport() = %v, want %v", m, got, want)
+		}
+	}
+}
+
+func TestNewHop_SentAt(t *testing.T) {
+	sent := time.Now()
+	hop := newHop(3, []*probe.Result{
+		{ResponseIP: net.ParseIP("192.0.2.1"), RTT: time.Millisecond, SentAt: sent},
+		nil,
+		{ResponseIP: net.ParseIP("192.0.2.1"), RTT: 2 * time.Millisecond, SentAt: sent.Add(time.Second)},
+	}, OutlierNone)
+	if len(hop.SentAt) != len(hop.RTTs) {
+		t.Fatalf("SentAt = %v, want one per RTT %v", hop.SentAt, hop.RTTs)
+	}
+	if !hop.SentAt[0].Equal(sent) || !hop.SentAt[1].IsZero() || !hop.SentAt[2].Equal(sent.Add(time.Second)) {
+		t.Errorf("SentAt = %v", hop.SentAt)
+	}
+
+	if hop := newHop(4, []*probe.Result{nil, nil}, OutlierNone); hop.SentAt != nil {
+		t.Errorf("SentAt of a silent hop = %v, want nil", hop.SentAt)
+	}
+}
+
+func TestNewHop_ResponseKind(t *testing.T) {
+	hop := newHop(5, []*probe.Result{
+		{ResponseIP: net.ParseIP("192.0.2.9"), RTT: time.Millisecond, Reached: true, ResponseKind: probe.ResponseSynAck},
+		nil,
+		{ResponseIP: net.ParseIP("192.0.2.9"), RTT: time.Millisecond, Reached: true, ResponseKind: probe.ResponseRST},
+	}, OutlierNone)
+	if hop.ResponseKind != probe.ResponseRST {
+		t.Errorf("ResponseKind = %q, want the last reply's %q", hop.ResponseKind, probe.ResponseRST)
+	}
+
+	if hop := newHop(6, []*probe.Result{nil}, OutlierNone); hop.ResponseKind != "" {
+		t.Errorf("ResponseKind of a silent hop = %q, want none", hop.ResponseKind)
+	}
+}
+
 func TestCalculateRTTStats(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -310,3 +515,563 @@ func canCreateRawSocket() bool {
 	}
 	return os.Getuid() == 0
 }
+
+func TestTracer_ModeAndNotes(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    ProbeMethod
+		sequence  bool
+		skip      bool
+		wantNotes int
+	}{
+		{"icmp forced sequential", ProbeICMP, false, false, 1},
+		{"skip private prefix", ProbeUDP, false, true, 1},
+		{"explicit sequential", ProbeUDP, true, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.ProbeMethod = tt.method
+			config.Sequential = tt.sequence
+			config.SkipPrivatePrefix = tt.skip
+			config.EnableEnrichment = false
+
+			prober := newScriptedProber("8.8.8.8", map[int]string{1: "8.8.8.8"})
+			tracer := &Tracer{config: config, prober: prober}
+
+			result, err := tracer.Trace(context.Background(), "8.8.8.8")
+			if err != nil {
+				t.Fatalf("Trace() error = %v", err)
+			}
+			if result.Mode != ModeSequential {
+				t.Errorf("Mode = %q, want %q", result.Mode, ModeSequential)
+			}
+			if len(result.Notes) != tt.wantNotes {
+				t.Errorf("Notes = %v, want %d note(s)", result.Notes, tt.wantNotes)
+			}
+			if result.ProbeSocket != "" {
+				t.Errorf("ProbeSocket = %q, want empty for probers that do not report it", result.ProbeSocket)
+			}
+		})
+	}
+}
+
+func TestTracer_FamilyProber(t *testing.T) {
+	calls := 0
+	orig := ipv4Reachable
+	ipv4Reachable = func() bool { calls++; return true }
+	t.Cleanup(func() { ipv4Reachable = orig })
+
+	config := DefaultConfig()
+	config.MaxHops = 2
+	config.ProbeCount = 1
+	config.Sequential = true
+	config.EnableEnrichment = false
+
+	dest := map[bool]string{false: "192.0.2.1", true: "2001:db8::1"}
+	own := probetest.NewScriptedProber().Hop(1, dest[false])
+	var opened []*probetest.ScriptedProber
+	var families []bool
+	tracer := newTracerWith(config, own, false, nil)
+	tracer.openProber = func(config *Config, ipv6 bool) (probe.Prober, error) {
+		prober := probetest.NewScriptedProber().Hop(1, dest[ipv6])
+		opened = append(opened, prober)
+		families = append(families, ipv6)
+		return prober, nil
+	}
+	defer tracer.Close()
+
+	steps := []struct {
+		target    string
+		wantNotes int
+		wantOpen  []bool
+	}{
+		{"192.0.2.1", 0, nil},
+		{"2001:db8::1", 1, []bool{true}},
+		{"2001:db8::1", 0, []bool{true}},
+		{"192.0.2.1", 0, []bool{true, false}},
+		{"192.0.2.1", 0, []bool{true, false}},
+	}
+	for i, step := range steps {
+		result, err := tracer.Trace(context.Background(), step.target)
+		if err != nil {
+			t.Fatalf("trace %d to %s: error = %v", i+1, step.target, err)
+		}
+		if !result.Completed {
+			t.Errorf("trace %d to %s should complete", i+1, step.target)
+		}
+		if len(result.Notes) != step.wantNotes {
+			t.Errorf("trace %d to %s: Notes = %v, want %d note(s)", i+1, step.target, result.Notes, step.wantNotes)
+		}
+		if fmt.Sprint(families) != fmt.Sprint(step.wantOpen) {
+			t.Errorf("trace %d to %s: opened probers for IPv6 %v, want %v", i+1, step.target, families, step.wantOpen)
+		}
+	}
+
+	if !own.Closed() || !opened[0].Closed() {
+		t.Error("probers of the other family should be closed when replaced")
+	}
+	if calls != 1 {
+		t.Errorf("IPv4 route checked %d times, want once per tracer", calls)
+	}
+}
+
+func TestTracer_ProbeStats(t *testing.T) {
+	config := DefaultConfig()
+	config.ProbeMethod = ProbeICMP
+	config.ProbeCount = 3
+	config.EnableEnrichment = false
+
+	prober := newScriptedProber("8.8.8.8", map[int]string{
+		1: "192.168.1.1",
+		2: "", // silent hop
+		3: "8.8.8.8",
+	})
+	tracer := &Tracer{config: config, prober: prober}
+
+	for run := 1; run <= 2; run++ {
+		result, err := tracer.Trace(context.Background(), "8.8.8.8")
+		if err != nil {
+			t.Fatalf("Trace() error = %v", err)
+		}
+
+		stats := result.ProbeStats
+		if stats == nil {
+			t.Fatal("ProbeStats should be set")
+		}
+		if stats.Method != "scripted" {
+			t.Errorf("Method = %q, want scripted", stats.Method)
+		}
+		// 3 hops x 3 probes; the silent hop times out three times and
+		// its second and third probes are retransmissions
+		if stats.Sent != 9 || stats.Received != 6 || stats.Timeouts != 3 {
+			t.Errorf("run %d: sent/received/timeouts = %d/%d/%d, want 9/6/3",
+				run, stats.Sent, stats.Received, stats.Timeouts)
+		}
+		if stats.Retransmissions != 2 {
+			t.Errorf("run %d: Retransmissions = %d, want 2", run, stats.Retransmissions)
+		}
+		if stats.TotalDiscarded() != 0 || stats.SocketErrors != 0 {
+			t.Errorf("run %d: unexpected discards or socket errors: %+v", run, stats)
+		}
+	}
+}
+
+func TestTracer_EffectiveConcurrency(t *testing.T) {
+	tests := []struct {
+		name        string
+		concurrency int
+		maxHops     int
+		firstHop    int
+		probeCount  int
+		want        int
+	}{
+		{"default", 0, 30, 1, 3, DefaultConcurrency},
+		{"explicit", 8, 30, 1, 3, 8},
+		{"clamped to TTL count", 64, 30, 1, 3, 30},
+		{"clamped to remaining TTLs", 64, 30, 21, 3, 10},
+		{"independent of probe count", 8, 30, 1, 10, 8},
+		{"above limit", 1000, 255, 1, 3, 255},
+		{"single TTL", 30, 5, 5, 3, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				MaxConcurrency: tt.concurrency,
+				MaxHops:        tt.maxHops,
+				FirstHop:       tt.firstHop,
+				ProbeCount:     tt.probeCount,
+			}
+			tracer := &Tracer{config: config}
+			if got := tracer.effectiveConcurrency(); got != tt.want {
+				t.Errorf("effectiveConcurrency() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTracer_ConcurrencyInResult(t *testing.T) {
+	config := DefaultConfig()
+	config.ProbeMethod = ProbeUDP
+	config.MaxConcurrency = 4
+	config.EnableEnrichment = false
+
+	prober := newScriptedProber("8.8.8.8", map[int]string{1: "8.8.8.8"})
+	tracer := &Tracer{config: config, prober: prober}
+
+	result, err := tracer.Trace(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("Trace() error = %v", err)
+	}
+	if result.Mode != ModeConcurrent || result.Concurrency != 4 {
+		t.Errorf("Mode/Concurrency = %s/%d, want concurrent/4", result.Mode, result.Concurrency)
+	}
+}
+
+func TestTracer_LastHop(t *testing.T) {
+	for _, sequential := range []bool{true, false} {
+		name := "concurrent"
+		if sequential {
+			name = "sequential"
+		}
+		t.Run(name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.ProbeMethod = ProbeUDP
+			config.Sequential = sequential
+			config.FirstHop = 5
+			config.LastHop = 9
+			config.ProbeCount = 2
+			config.EnableEnrichment = false
+
+			responders := make(map[int]string)
+			for ttl := 1; ttl <= 12; ttl++ {
+				responders[ttl] = fmt.Sprintf("203.0.113.%d", ttl)
+			}
+			responders[12] = "8.8.8.8"
+			prober := newScriptedProber("8.8.8.8", responders)
+			tracer := &Tracer{config: config, prober: prober}
+
+			result, err := tracer.Trace(context.Background(), "8.8.8.8")
+			if err != nil {
+				t.Fatalf("Trace() error = %v", err)
+			}
+
+			for ttl := 1; ttl <= 12; ttl++ {
+				want := 0
+				if ttl >= 5 && ttl <= 9 {
+					want = 2
+				}
+				if got := prober.probes[ttl]; got != want {
+					t.Errorf("TTL %d probed %d times, want %d", ttl, got, want)
+				}
+			}
+			if len(result.Hops) != 5 || result.Hops[0].Number != 5 || result.Hops[4].Number != 9 {
+				t.Errorf("hops = %d, want hops 5-9", len(result.Hops))
+			}
+			if result.Completed || result.StoppedReason != StopLastHop {
+				t.Errorf("Completed/StoppedReason = %v/%q, want false/%q",
+					result.Completed, result.StoppedReason, StopLastHop)
+			}
+			if result.Meta == nil || result.Meta.LastHop != 9 {
+				t.Error("Meta should record the last hop")
+			}
+		})
+	}
+}
+
+func TestTracer_LastRTT(t *testing.T) {
+	dest := net.ParseIP("8.8.8.8")
+	reply := &probe.Result{ResponseIP: net.ParseIP("192.0.2.1"), RTT: 4 * time.Millisecond, TTLExpired: true}
+
+	tests := []struct {
+		name    string
+		replies []bool // per probe: true = reply, false = timeout
+		want    float64
+	}{
+		{"all replies", []bool{true, true, true}, 4},
+		{"last timed out", []bool{true, true, false}, -1},
+		{"reply after timeout", []bool{false, false, true}, 4},
+		{"no replies", []bool{false, false, false}, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prober := newFuncProber(func(ttl, call int) (*probe.Result, error) {
+				if tt.replies[call-1] {
+					return reply, nil
+				}
+				return nil, probe.ErrTimeout
+			})
+			config := DefaultConfig()
+			config.ProbeCount = len(tt.replies)
+			tracer := &Tracer{config: config, prober: prober}
+
+			hop := tracer.probeHop(context.Background(), dest, 1)
+			if hop.LastRTT != tt.want {
+				t.Errorf("LastRTT = %v, want %v (RTTs %v)", hop.LastRTT, tt.want, hop.RTTs)
+			}
+		})
+	}
+}
+
+func TestTracer_InterfaceInfo(t *testing.T) {
+	prober := newFuncProber(func(ttl, call int) (*probe.Result, error) {
+		result := &probe.Result{ResponseIP: net.ParseIP("192.0.2.1"), RTT: time.Millisecond, TTLExpired: true}
+		if call == 2 {
+			result.Interface = &probe.InterfaceInfo{Role: probe.InterfaceRoleIncoming, Index: 517, Name: "xe-0/0/1", MTU: 9000}
+		}
+		return result, nil
+	})
+	tracer := &Tracer{config: DefaultConfig(), prober: prober}
+
+	hop := tracer.probeHop(context.Background(), net.ParseIP("8.8.8.8"), 1)
+	if hop.Interface == nil {
+		t.Fatal("Interface should be set when any probe reported one")
+	}
+	if hop.Interface.Name != "xe-0/0/1" || hop.Interface.Index != 517 || hop.Interface.MTU != 9000 {
+		t.Errorf("Interface = %+v", hop.Interface)
+	}
+	if hop.Interface.Label() != "xe-0/0/1" {
+		t.Errorf("Label() = %q", hop.Interface.Label())
+	}
+	if got := (&InterfaceInfo{Index: 12}).Label(); got != "ifIndex 12" {
+		t.Errorf("Label() without name = %q", got)
+	}
+}
+
+func TestParsePacketID(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int
+		wantErr bool
+	}{
+		{"0xBEEF", 0xBEEF, false},
+		{"100", 100, false},
+		{"0", 0, false},
+		{"65535", 65535, false},
+		{"65536", 0, true},
+		{"-1", 0, true},
+		{"beef", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParsePacketID(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePacketID(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidPacketID) {
+				t.Errorf("ParsePacketID(%q) error = %v, want ErrInvalidPacketID", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParsePacketID(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTracer_ParallelQueries(t *testing.T) {
+	dest := net.ParseIP("8.8.8.8")
+	const delay = 100 * time.Millisecond
+
+	// Later probes answer sooner, so replies arrive out of sequence order.
+	// Hop 2 never answers. The RTT encodes the sequence number.
+	newProber := func() *funcProber {
+		return newFuncProber(func(ttl, call int) (*probe.Result, error) {
+			if ttl == 2 {
+				time.Sleep(delay)
+				return nil, probe.ErrTimeout
+			}
+			time.Sleep(delay - time.Duration(call)*20*time.Millisecond)
+			ip := net.ParseIP(fmt.Sprintf("192.0.2.%d", ttl))
+			if ttl == 3 {
+				ip = dest
+			}
+			return &probe.Result{ResponseIP: ip, RTT: time.Duration(call) * time.Millisecond, Seq: uint32(call)}, nil
+		})
+	}
+
+	run := func(parallel bool) (*TraceResult, time.Duration) {
+		config := DefaultConfig()
+		config.ProbeMethod = ProbeUDP
+		config.Sequential = true
+		config.ParallelQueries = parallel
+		config.EnableEnrichment = false
+		config.Timeout = delay
+
+		tracer := &Tracer{config: config, prober: newProber()}
+		start := time.Now()
+		result, err := tracer.Trace(context.Background(), dest.String())
+		if err != nil {
+			t.Fatalf("Trace() error = %v", err)
+		}
+		return result, time.Since(start)
+	}
+
+	serial, serialElapsed := run(false)
+	result, elapsed := run(true)
+
+	// Three hops cost about three timeouts instead of nine
+	if elapsed >= serialElapsed/2 {
+		t.Errorf("parallel trace took %v, sequential %v; want less than half", elapsed, serialElapsed)
+	}
+	if elapsed >= 6*delay {
+		t.Errorf("parallel trace took %v, want about %v", elapsed, 3*delay)
+	}
+
+	if len(result.Hops) != 3 || !result.Completed {
+		t.Fatalf("got %d hops (completed %v), want 3 completed", len(result.Hops), result.Completed)
+	}
+	for _, hop := range []Hop{result.Hops[0], result.Hops[2]} {
+		if want := []float64{1, 2, 3}; fmt.Sprint(hop.RTTs) != fmt.Sprint(want) {
+			t.Errorf("hop %d RTTs = %v, want %v in sequence order", hop.Number, hop.RTTs, want)
+		}
+		if hop.LastRTT != 3 {
+			t.Errorf("hop %d LastRTT = %v, want 3", hop.Number, hop.LastRTT)
+		}
+	}
+	if hop := result.Hops[1]; hop.Responded || hop.LossPercent != 100 || len(hop.RTTs) != 3 {
+		t.Errorf("silent hop = %+v, want 3 lost probes", hop)
+	}
+
+	// Both modes agree on the samples
+	if fmt.Sprint(serial.Hops[0].RTTs) != fmt.Sprint(result.Hops[0].RTTs) {
+		t.Errorf("sequential RTTs = %v, parallel %v", serial.Hops[0].RTTs, result.Hops[0].RTTs)
+	}
+}
+
+func TestTracer_ParallelQueriesICMP(t *testing.T) {
+	prober := newScriptedProber("127.0.0.1", map[int]string{1: "127.0.0.1"})
+
+	config := DefaultConfig()
+	config.Sequential = true
+	config.ParallelQueries = true
+	config.EnableEnrichment = false
+
+	tracer := &Tracer{config: config, prober: prober}
+	result, err := tracer.Trace(context.Background(), "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Trace() error = %v", err)
+	}
+
+	found := false
+	for _, note := range result.Notes {
+		if strings.Contains(note, "parallel queries disabled") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Notes = %v, want parallel queries disabled for ICMP", result.Notes)
+	}
+}
+
+func TestTracer_WeakMatches(t *testing.T) {
+	dest := net.ParseIP("8.8.8.8")
+	prober := newFuncProber(func(ttl, call int) (*probe.Result, error) {
+		if ttl == 1 {
+			quality := probe.MatchFull
+			if call == 2 {
+				quality = probe.MatchWeak // router quoted only the IP header
+			}
+			return &probe.Result{ResponseIP: net.ParseIP("192.0.2.1"), RTT: time.Millisecond,
+				TTLExpired: true, MatchQuality: quality, QuoteLen: 20}, nil
+		}
+		return &probe.Result{ResponseIP: dest, RTT: 5 * time.Millisecond, Reached: true, MatchQuality: probe.MatchFull}, nil
+	})
+
+	config := DefaultConfig()
+	config.Sequential = true
+	config.EnableEnrichment = false
+	tracer := &Tracer{config: config, prober: prober}
+
+	result, err := tracer.Trace(context.Background(), dest.String())
+	if err != nil {
+		t.Fatalf("Trace() error = %v", err)
+	}
+
+	if got := result.Hops[0].WeakMatches; got != 1 {
+		t.Errorf("hop 1 WeakMatches = %d, want 1", got)
+	}
+	if got := result.Hops[1].WeakMatches; got != 0 {
+		t.Errorf("hop 2 WeakMatches = %d, want 0", got)
+	}
+
+	want := "hop 1: 1 of 3 replies matched only by protocol and destination"
+	noted := false
+	for _, note := range result.Notes {
+		noted = noted || strings.HasPrefix(note, want)
+	}
+	if !noted {
+		t.Errorf("Notes = %v, want one starting with %q", result.Notes, want)
+	}
+}
+
+func TestTracer_Close(t *testing.T) {
+	for _, sequential := range []bool{true, false} {
+		name := "concurrent"
+		if sequential {
+			name = "sequential"
+		}
+		t.Run(name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.ProbeMethod = ProbeUDP
+			config.Sequential = sequential
+			config.EnableEnrichment = false
+
+			// Every probe hangs until the prober is closed
+			prober := probetest.NewScriptedProber()
+			for ttl := 1; ttl <= config.MaxHops; ttl++ {
+				prober.Reply(ttl, probetest.AnyAttempt, probetest.Reply{Delay: time.Hour})
+			}
+			tracer, err := NewWithProber(config, prober)
+			if err != nil {
+				t.Fatalf("NewWithProber() error = %v", err)
+			}
+
+			done := make(chan error, 1)
+			go func() {
+				_, err := tracer.Trace(context.Background(), "203.0.113.9")
+				done <- err
+			}()
+			for prober.Probes(1) == 0 {
+				time.Sleep(time.Millisecond)
+			}
+
+			for i := range 2 {
+				if err := tracer.Close(); err != nil {
+					t.Errorf("Close() #%d error = %v", i+1, err)
+				}
+			}
+			if !prober.Closed() {
+				t.Error("Close() should close the prober")
+			}
+
+			select {
+			case err := <-done:
+				if !errors.Is(err, ErrTracerClosed) || ErrorStage(err) != StageTrace {
+					t.Errorf("running Trace() error = %v, want ErrTracerClosed at the trace stage", err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("Trace() did not return within 1s of Close")
+			}
+
+			_, err = tracer.Trace(context.Background(), "203.0.113.9")
+			if !errors.Is(err, ErrTracerClosed) || ErrorStage(err) != StageSocket {
+				t.Errorf("Trace() after Close error = %v, want ErrTracerClosed at the socket stage", err)
+			}
+			_, err = tracer.TraceMethods(context.Background(), "203.0.113.9", []ProbeMethod{ProbeUDP, ProbeTCP})
+			if !errors.Is(err, ErrTracerClosed) {
+				t.Errorf("TraceMethods() after Close error = %v, want ErrTracerClosed", err)
+			}
+		})
+	}
+}
+
+func TestApplyEnrichment(t *testing.T) {
+	var hop Hop
+	applyEnrichment(&hop, &enrich.EnrichmentResult{
+		Hostname: "ae1.example.net",
+		ASN:      &enrich.ASNInfo{Number: 64500, Org: "EXAMPLE", Country: "US", Source: enrich.SourceCymru},
+		Geo: &enrich.GeoInfo{Country: "United States", CountryCode: "US", City: "Ashburn",
+			Region: "Virginia", RegionCode: "VA", Latitude: 39.04, Longitude: -77.49,
+			ISP: "Example", Hosting: true, Source: enrich.SourceMaxMind},
+	})
+
+	if hop.Hostname != "ae1.example.net" || hop.ASN == nil || hop.ASN.Number != 64500 || hop.ASN.Source != enrich.SourceCymru {
+		t.Errorf("hostname/ASN = %q/%+v", hop.Hostname, hop.ASN)
+	}
+	want := GeoInfo{Country: "United States", CountryCode: "US", City: "Ashburn",
+		Region: "Virginia", RegionCode: "VA", Latitude: 39.04, Longitude: -77.49,
+		ISP: "Example", Hosting: true, Source: enrich.SourceMaxMind}
+	if hop.Geo == nil || *hop.Geo != want {
+		t.Errorf("Geo = %+v, want %+v", hop.Geo, want)
+	}
+
+	// No result leaves the hop alone
+	applyEnrichment(&hop, nil)
+	if hop.Geo == nil {
+		t.Error("applyEnrichment(nil) should keep the hop's data")
+	}
+}
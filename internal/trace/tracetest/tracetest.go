@@ -0,0 +1,122 @@
+// Package tracetest builds realistic trace results for tests of code that
+// consumes them, such as formatters, without running a trace.
+package tracetest
+
+import (
+	"net"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+)
+
+// Timestamp is the default time of built results, so output is stable.
+var Timestamp = time.Date(2025, 12, 18, 12, 0, 0, 0, time.UTC)
+
+// ResultBuilder builds a trace.TraceResult hop by hop, e.g.
+//
+//	tracetest.Result("example.com").
+//		Hop(1, "192.168.1.1", 1.2, 1.3).
+//		TimeoutHop(2).
+//		Hop(3, "93.184.216.34", 10.1, -1, 10.4).
+//		Build()
+//
+// Hop statistics and the summary are calculated as a trace would.
+type ResultBuilder struct {
+	result    trace.TraceResult
+	completed *bool
+}
+
+// Result starts a result for target, traced with ICMP. If target is an
+// address it is also the resolved destination.
+func Result(target string) *ResultBuilder {
+	return &ResultBuilder{result: trace.TraceResult{
+		Target:      target,
+		ResolvedIP:  net.ParseIP(target),
+		Timestamp:   Timestamp,
+		ProbeMethod: "icmp",
+	}}
+}
+
+// Resolved sets the address target resolved to.
+func (b *ResultBuilder) Resolved(ip string) *ResultBuilder {
+	b.result.ResolvedIP = net.ParseIP(ip)
+	return b
+}
+
+// Method sets the probe method name, e.g. "udp".
+func (b *ResultBuilder) Method(method string) *ResultBuilder {
+	b.result.ProbeMethod = method
+	return b
+}
+
+// At sets the time the trace started.
+func (b *ResultBuilder) At(t time.Time) *ResultBuilder {
+	b.result.Timestamp = t
+	return b
+}
+
+// Completed overrides whether the trace is reported as having reached
+// its destination.
+func (b *ResultBuilder) Completed(completed bool) *ResultBuilder {
+	b.completed = &completed
+	return b
+}
+
+// Hop adds hop number answered by ip with the given RTTs in milliseconds,
+// one per probe; a negative RTT is a lost probe.
+func (b *ResultBuilder) Hop(number int, ip string, rtts ...float64) *ResultBuilder {
+	hop := trace.Hop{
+		Number:    number,
+		IP:        net.ParseIP(ip),
+		RTTs:      append([]float64(nil), rtts...),
+		Responded: true,
+	}
+	hop.UpdateStats()
+	b.result.Hops = append(b.result.Hops, hop)
+	return b
+}
+
+// TimeoutHop adds hop number with three lost probes.
+func (b *ResultBuilder) TimeoutHop(number int) *ResultBuilder {
+	hop := trace.Hop{Number: number, RTTs: []float64{-1, -1, -1}}
+	hop.UpdateStats()
+	b.result.Hops = append(b.result.Hops, hop)
+	return b
+}
+
+// Hostname sets the hostname of the last hop added.
+func (b *ResultBuilder) Hostname(name string) *ResultBuilder {
+	return b.With(func(hop *trace.Hop) { hop.Hostname = name })
+}
+
+// ASN sets the autonomous system of the last hop added.
+func (b *ResultBuilder) ASN(number int, org string) *ResultBuilder {
+	return b.With(func(hop *trace.Hop) {
+		hop.ASN = &trace.ASNInfo{Number: number, Org: org}
+	})
+}
+
+// With changes the last hop added, for fields without a builder method.
+func (b *ResultBuilder) With(change func(hop *trace.Hop)) *ResultBuilder {
+	if n := len(b.result.Hops); n > 0 {
+		change(&b.result.Hops[n-1])
+	}
+	return b
+}
+
+// Build returns the result. Unless set with Completed, it is completed if
+// the last hop is the resolved destination.
+func (b *ResultBuilder) Build() *trace.TraceResult {
+	result := b.result
+	result.Hops = append([]trace.Hop(nil), b.result.Hops...)
+
+	if n := len(result.Hops); n > 0 && result.ResolvedIP != nil {
+		result.Completed = result.Hops[n-1].IP.Equal(result.ResolvedIP)
+	}
+	if b.completed != nil {
+		result.Completed = *b.completed
+	}
+	result.Summary = trace.Summarize(result.Hops)
+	result.Summary.LastTransitHop = trace.LastTransitHop(result.Hops, result.ResolvedIP)
+	return &result
+}
@@ -0,0 +1,55 @@
+package tracetest
+
+import (
+	"math"
+	"testing"
+)
+
+func TestResultBuilder(t *testing.T) {
+	result := Result("203.0.113.9").
+		Hop(1, "192.168.1.1", 1, 3).Hostname("router.local").
+		TimeoutHop(2).
+		Hop(3, "198.51.100.3", 10, -1).ASN(64500, "Example").
+		Hop(4, "203.0.113.9", 20, 22).ASN(64501, "Destination").
+		Build()
+
+	if !result.Completed {
+		t.Error("Completed = false, want true")
+	}
+	if len(result.Hops) != 4 || result.Summary.TotalHops != 4 {
+		t.Fatalf("hops = %d, TotalHops = %d, want 4", len(result.Hops), result.Summary.TotalHops)
+	}
+
+	first := result.Hops[0]
+	if first.AvgRTT != 2 || first.MinRTT != 1 || first.MaxRTT != 3 || first.Jitter != 2 || first.LastRTT != 3 {
+		t.Errorf("hop 1 stats = %+v", first)
+	}
+	if first.Hostname != "router.local" {
+		t.Errorf("hop 1 Hostname = %q", first.Hostname)
+	}
+	if hop := result.Hops[1]; hop.Responded || hop.LossPercent != 100 {
+		t.Errorf("hop 2 = %+v, want timeout", hop)
+	}
+	if hop := result.Hops[2]; hop.LossPercent != 50 || hop.ASN == nil || hop.ASN.Number != 64500 {
+		t.Errorf("hop 3 = %+v, want 50%% loss in AS64500", hop)
+	}
+
+	if result.Summary.TotalTimeMs != 21 {
+		t.Errorf("TotalTimeMs = %v, want 21", result.Summary.TotalTimeMs)
+	}
+	if loss := result.Summary.PacketLossPercent; math.Abs(loss-37.5) > 1e-9 {
+		t.Errorf("PacketLossPercent = %v, want 37.5", loss)
+	}
+	if transit := result.Summary.LastTransitHop; transit == nil || transit.Number != 3 {
+		t.Errorf("LastTransitHop = %+v, want hop 3", transit)
+	}
+}
+
+func TestResultBuilder_Completed(t *testing.T) {
+	if Result("example.com").Resolved("203.0.113.9").Hop(1, "192.168.1.1", 1).Build().Completed {
+		t.Error("Completed = true for a trace that did not reach the destination")
+	}
+	if !Result("example.com").TimeoutHop(1).Completed(true).Build().Completed {
+		t.Error("Completed(true) not applied")
+	}
+}
@@ -0,0 +1,72 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// verifyDestTTL is the TTL of end-host probes. It is raised to MaxHops for
+// longer paths so the probes never expire before the destination.
+const verifyDestTTL = 64
+
+// verifyDestInterval spaces end-host probes when no rate limit is set, so a
+// burst does not trip the destination's ICMP rate limiting.
+const verifyDestInterval = 100 * time.Millisecond
+
+// verifyDestination sends VerifyDest extra probes straight to the
+// destination, one at a time, and returns their RTTs in milliseconds
+// (-1 = lost). Path loss measured at high TTLs mixes in loss at every
+// router; these probes only measure the end host.
+func (t *Tracer) verifyDestination(ctx context.Context, dest net.IP) []float64 {
+	ttl := verifyDestTTL
+	if t.config.MaxHops > ttl {
+		ttl = t.config.MaxHops
+	}
+
+	interval := verifyDestInterval
+	if t.config.PacketsPerSecond > 0 {
+		interval = time.Second / time.Duration(t.config.PacketsPerSecond)
+	}
+
+	rtts := make([]float64, 0, t.config.VerifyDest)
+	for i := 0; i < t.config.VerifyDest; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return rtts
+			case <-time.After(interval):
+			}
+		}
+
+		if err := t.config.Pause.Wait(ctx); err != nil {
+			return rtts
+		}
+		result, err := t.sendProbe(ctx, dest, ttl)
+		if errors.Is(err, ErrPacketBudget) {
+			return rtts
+		}
+		if errors.Is(err, ErrClockStep) {
+			continue
+		}
+		if err != nil || result == nil || !result.ResponseIP.Equal(dest) {
+			rtts = append(rtts, -1)
+			continue
+		}
+		rtts = append(rtts, float64(result.RTT.Microseconds())/1000.0)
+	}
+	return rtts
+}
+
+// applyDestinationStats records the end-host probe results in summary,
+// averaging the RTTs under policy.
+func applyDestinationStats(summary *Summary, rtts []float64, policy OutlierPolicy) {
+	if len(rtts) == 0 {
+		return
+	}
+	summary.DestinationProbes = len(rtts)
+	summary.DestinationLossPercent = calculateLossPercent(rtts)
+	samples, _ := policy.samples(rtts)
+	summary.DestinationAvgRTT, _, _, _ = calculateRTTStats(samples)
+}
@@ -0,0 +1,119 @@
+package trace
+
+import (
+	"context"
+	"math"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/probe"
+)
+
+// funcProber answers probes with fn, passing the per-TTL call number.
+type funcProber struct {
+	probe.Counters
+
+	mu    sync.Mutex
+	calls map[int]int
+	fn    func(ttl, call int) (*probe.Result, error)
+}
+
+func newFuncProber(fn func(ttl, call int) (*probe.Result, error)) *funcProber {
+	return &funcProber{calls: make(map[int]int), fn: fn}
+}
+
+func (p *funcProber) Probe(ctx context.Context, dest net.IP, ttl int) (*probe.Result, error) {
+	p.mu.Lock()
+	p.calls[ttl]++
+	call := p.calls[ttl]
+	p.mu.Unlock()
+	return p.fn(ttl, call)
+}
+
+func (p *funcProber) Name() string       { return "func" }
+func (p *funcProber) RequiresRoot() bool { return false }
+func (p *funcProber) Close() error       { return nil }
+
+func TestTracer_VerifyDest(t *testing.T) {
+	dest := net.ParseIP("8.8.8.8")
+
+	// The destination deprioritizes replies to expiring-TTL probes (two
+	// of three lost on the path) but answers direct probes, losing only
+	// the fourth of ten.
+	prober := newFuncProber(func(ttl, call int) (*probe.Result, error) {
+		switch {
+		case ttl == 1:
+			return &probe.Result{ResponseIP: net.ParseIP("192.0.2.1"), RTT: time.Millisecond, TTLExpired: true}, nil
+		case ttl == 2 && call == 1:
+			return &probe.Result{ResponseIP: dest, RTT: 20 * time.Millisecond, Reached: true}, nil
+		case ttl == 2:
+			return nil, probe.ErrTimeout
+		case ttl == verifyDestTTL && call != 4:
+			return &probe.Result{ResponseIP: dest, RTT: 10 * time.Millisecond, Reached: true}, nil
+		}
+		return nil, probe.ErrTimeout
+	})
+
+	for _, sequential := range []bool{true, false} {
+		prober.calls = make(map[int]int)
+
+		config := DefaultConfig()
+		config.ProbeMethod = ProbeUDP
+		config.Sequential = sequential
+		config.VerifyDest = 10
+		config.PacketsPerSecond = 1000
+		config.EnableEnrichment = false
+		tracer := &Tracer{config: config, prober: prober}
+
+		result, err := tracer.Trace(context.Background(), "8.8.8.8")
+		if err != nil {
+			t.Fatalf("Trace() error = %v", err)
+		}
+
+		if got := prober.calls[verifyDestTTL]; got != 10 {
+			t.Errorf("sequential=%v: %d end-host probes sent, want 10", sequential, got)
+		}
+		last := result.Hops[len(result.Hops)-1]
+		if math.Abs(last.LossPercent-66.67) > 0.01 {
+			t.Errorf("sequential=%v: path loss at destination = %.2f, want 66.67", sequential, last.LossPercent)
+		}
+
+		s := result.Summary
+		if s.DestinationProbes != 10 || s.DestinationLossPercent != 10 || s.DestinationAvgRTT != 10 {
+			t.Errorf("sequential=%v: destination probes/loss/avg = %d/%.1f/%.2f, want 10/10.0/10.00",
+				sequential, s.DestinationProbes, s.DestinationLossPercent, s.DestinationAvgRTT)
+		}
+	}
+}
+
+func TestTracer_VerifyDestDisabled(t *testing.T) {
+	config := DefaultConfig()
+	config.ProbeMethod = ProbeUDP
+	config.Sequential = true
+	config.EnableEnrichment = false
+
+	prober := newScriptedProber("8.8.8.8", map[int]string{1: "8.8.8.8"})
+	tracer := &Tracer{config: config, prober: prober}
+
+	result, err := tracer.Trace(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("Trace() error = %v", err)
+	}
+	if prober.probes[verifyDestTTL] != 0 || result.Summary.DestinationProbes != 0 {
+		t.Error("no end-host probes should be sent without VerifyDest")
+	}
+
+	// A partial path never probes the destination
+	config.VerifyDest = 5
+	config.MaxHops = 5
+	config.LastHop = 3
+	result, err = tracer.Trace(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("Trace() error = %v", err)
+	}
+	if prober.probes[verifyDestTTL] != 0 || result.Summary.DestinationProbes != 0 {
+		t.Error("partial-path traces should skip destination verification")
+	}
+}
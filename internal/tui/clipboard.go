@@ -0,0 +1,93 @@
+package tui
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/KilimcininKorOglu/poros/internal/output"
+)
+
+// maxClipboardBytes is the most text sent in one OSC 52 sequence. Its
+// base64 encoding stays below the 100000-byte limit common to terminals
+// and multiplexers.
+const maxClipboardBytes = 74994
+
+// statusDuration is how long a transient status message is shown.
+const statusDuration = 2 * time.Second
+
+// osc52 returns the OSC 52 escape sequence that asks the terminal to put
+// text on the system clipboard. It works over SSH, as the local terminal
+// does the copying.
+func osc52(text string) string {
+	return "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+}
+
+// clipboardText limits text to maxClipboardBytes, cutting at the last
+// full line that fits, and reports whether it was truncated.
+func clipboardText(text string) (string, bool) {
+	if len(text) <= maxClipboardBytes {
+		return text, false
+	}
+	cut := text[:maxClipboardBytes]
+	if i := strings.LastIndexByte(cut, '\n'); i > 0 {
+		cut = cut[:i+1]
+	}
+	return cut, true
+}
+
+// StatusMsg shows a transient message in the footer.
+type StatusMsg struct {
+	Text string
+}
+
+// clearStatusMsg clears the status message it was scheduled for.
+type clearStatusMsg struct {
+	seq int
+}
+
+// copyCmd returns a command that copies text to the clipboard and reports
+// the outcome as a StatusMsg.
+func (m Model) copyCmd(what, text string) tea.Cmd {
+	out := m.clipboard
+	return func() tea.Msg {
+		if text == "" {
+			return StatusMsg{Text: "Nothing to copy"}
+		}
+		text, truncated := clipboardText(text)
+		if _, err := io.WriteString(out, osc52(text)); err != nil {
+			return StatusMsg{Text: fmt.Sprintf("Copy failed: %v", err)}
+		}
+		if truncated {
+			return StatusMsg{Text: fmt.Sprintf("Copied %s (truncated to %d KB)", what, maxClipboardBytes/1024)}
+		}
+		return StatusMsg{Text: "Copied " + what}
+	}
+}
+
+// tableText renders the hops shown as plain traceroute text, as printed
+// without --tui.
+func (m Model) tableText() string {
+	display := m.display
+	display.Colors = false
+	formatter := output.NewTextFormatter(display)
+
+	if m.result != nil && m.pathView == 0 {
+		data, err := formatter.Format(m.result)
+		if err == nil {
+			return string(data)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(formatter.FormatHeader(m.target, m.config.MaxHops))
+	hops := m.shownHops()
+	for i := range hops {
+		b.WriteString(formatter.FormatHop(&hops[i]))
+	}
+	return b.String()
+}
@@ -0,0 +1,115 @@
+package tui
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/KilimcininKorOglu/poros/internal/output"
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+)
+
+func TestOSC52(t *testing.T) {
+	if got, want := osc52("192.0.2.1"), "\x1b]52;c;MTkyLjAuMi4x\a"; got != want {
+		t.Errorf("osc52() = %q, want %q", got, want)
+	}
+
+	// Multi-byte text round-trips through the base64 payload
+	text := "hop 1  ağ-geçidi.örnek  1.23 ms\n"
+	seq := osc52(text)
+	payload := strings.TrimSuffix(strings.TrimPrefix(seq, "\x1b]52;c;"), "\a")
+	decoded, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil || string(decoded) != text {
+		t.Errorf("payload decodes to %q, %v; want %q", decoded, err, text)
+	}
+}
+
+func TestClipboardText(t *testing.T) {
+	if text, truncated := clipboardText("short\n"); text != "short\n" || truncated {
+		t.Errorf("clipboardText(short) = %q, %v", text, truncated)
+	}
+
+	line := strings.Repeat("x", 99) + "\n"
+	long := strings.Repeat(line, maxClipboardBytes/len(line)+10)
+	text, truncated := clipboardText(long)
+	if !truncated || len(text) > maxClipboardBytes || !strings.HasSuffix(text, "\n") {
+		t.Errorf("clipboardText(long) = %d bytes, truncated %v; want whole lines within %d", len(text), truncated, maxClipboardBytes)
+	}
+	if len(base64.StdEncoding.EncodeToString([]byte(text))) >= 100000 {
+		t.Error("encoded payload should stay below 100000 bytes")
+	}
+}
+
+func TestModel_Copy(t *testing.T) {
+	m, err := New("example.com", trace.DefaultConfig(), output.Config{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	var term bytes.Buffer
+	m.clipboard = &term
+
+	model := *m
+	for _, hop := range []trace.Hop{
+		{Number: 1, IP: net.ParseIP("192.0.2.1"), Hostname: "gw.example.net", RTTs: []float64{1.5}, AvgRTT: 1.5, Responded: true},
+		{Number: 2, IP: net.ParseIP("198.51.100.7"), Hostname: "core.example.net", RTTs: []float64{7.25}, AvgRTT: 7.25, Responded: true},
+	} {
+		next, _ := model.Update(HopMsg{Hop: hop})
+		model = next.(Model)
+	}
+
+	// press runs a key through Update and the command it returns
+	press := func(key tea.KeyMsg) string {
+		term.Reset()
+		next, cmd := model.Update(key)
+		model = next.(Model)
+		if cmd == nil {
+			t.Fatalf("%q returned no command", key.String())
+		}
+		if status, ok := cmd().(StatusMsg); ok {
+			next, _ = model.Update(status)
+			model = next.(Model)
+		}
+		payload := strings.TrimSuffix(strings.TrimPrefix(term.String(), "\x1b]52;c;"), "\a")
+		decoded, _ := base64.StdEncoding.DecodeString(payload)
+		return string(decoded)
+	}
+	runes := func(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+
+	if got := press(runes("y")); got != "192.0.2.1" {
+		t.Errorf("y copied %q, want the first hop's IP", got)
+	}
+	if model.status != "Copied IP" || !strings.Contains(model.View(), "Copied IP") {
+		t.Errorf("status = %q, want a copied message", model.status)
+	}
+
+	next, _ := model.Update(tea.KeyMsg{Type: tea.KeyDown})
+	model = next.(Model)
+	if got := press(runes("Y")); got != "core.example.net" {
+		t.Errorf("Y copied %q, want the selected hop's hostname", got)
+	}
+
+	table := press(runes("c"))
+	for _, want := range []string{"traceroute to example.com", "192.0.2.1", "core.example.net", "7.25 ms"} {
+		if !strings.Contains(table, want) {
+			t.Errorf("copied table should contain %q:\n%s", want, table)
+		}
+	}
+	if strings.Contains(table, "\x1b[") {
+		t.Error("copied table should be plain text")
+	}
+
+	// The status clears itself, unless a newer one replaced it
+	seq := model.statusSeq
+	next, _ = model.Update(clearStatusMsg{seq: seq - 1})
+	if next.(Model).status == "" {
+		t.Error("a stale clear should not remove the newer status")
+	}
+	next, _ = model.Update(clearStatusMsg{seq: seq})
+	if next.(Model).status != "" {
+		t.Error("status should clear after its timeout")
+	}
+}
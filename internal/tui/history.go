@@ -0,0 +1,173 @@
+package tui
+
+import (
+	"sort"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+)
+
+// DefaultPathSnapshots is the number of superseded paths a History keeps
+// viewable after the route changes.
+const DefaultPathSnapshots = 5
+
+// PathSnapshot is the path as it stood just before a change, with its
+// statistics frozen at that moment.
+type PathSnapshot struct {
+	Changes []trace.PathChange // the changes that superseded it
+	Hops    []trace.Hop
+}
+
+// Time returns when the path was superseded.
+func (p PathSnapshot) Time() time.Time {
+	return p.Changes[0].Time
+}
+
+// History keeps per-hop RTT statistics across repeated traces in constant
+// memory per hop, however long the session runs. When the path changes it
+// keeps the last few superseded paths (see AddTrace).
+type History struct {
+	recent int
+	hops   map[int]*hopHistory
+
+	maxSnapshots int
+	snapshots    []PathSnapshot // oldest first
+
+	// now returns the current time; replaced in tests
+	now func() time.Time
+}
+
+type hopHistory struct {
+	hop   trace.Hop // latest identity (IP, hostname, enrichment)
+	stats *trace.RTTStats
+}
+
+// NewHistory creates a History keeping the last recent raw samples per
+// hop (trace.DefaultRecentSamples if recent <= 0).
+func NewHistory(recent int) *History {
+	if recent <= 0 {
+		recent = trace.DefaultRecentSamples
+	}
+	return &History{
+		recent:       recent,
+		hops:         make(map[int]*hopHistory),
+		maxSnapshots: DefaultPathSnapshots,
+		now:          time.Now,
+	}
+}
+
+// SetMaxSnapshots sets the number of superseded paths kept, dropping the
+// oldest beyond it. Zero keeps none; AddTrace still reports changes.
+func (h *History) SetMaxSnapshots(n int) {
+	h.maxSnapshots = max(n, 0)
+	h.trimSnapshots()
+}
+
+// AddTrace merges one cycle's hops into the history. A hop that answers
+// from a different address than it last did is a path change: the path
+// as it stood is kept as a snapshot, and the changed hop's statistics
+// start afresh for its new router. Other hops keep accumulating. It
+// returns the changes seen in this cycle.
+func (h *History) AddTrace(hops []trace.Hop) []trace.PathChange {
+	var changes []trace.PathChange
+	now := h.now()
+	for _, hop := range hops {
+		entry, ok := h.hops[hop.Number]
+		if !ok {
+			continue
+		}
+		if change, ok := trace.DetectPathChange(&entry.hop, &hop, now); ok {
+			changes = append(changes, change)
+		}
+	}
+
+	if len(changes) > 0 {
+		h.snapshots = append(h.snapshots, PathSnapshot{Changes: changes, Hops: h.Hops()})
+		h.trimSnapshots()
+		for _, change := range changes {
+			h.hops[change.Hop].stats = trace.NewRTTStats(h.recent)
+		}
+	}
+
+	for _, hop := range hops {
+		h.Add(hop)
+	}
+	return changes
+}
+
+func (h *History) trimSnapshots() {
+	if over := len(h.snapshots) - h.maxSnapshots; over > 0 {
+		h.snapshots = append(h.snapshots[:0], h.snapshots[over:]...)
+	}
+}
+
+// Snapshots returns the superseded paths kept, most recent first.
+func (h *History) Snapshots() []PathSnapshot {
+	snapshots := make([]PathSnapshot, len(h.snapshots))
+	for i, snapshot := range h.snapshots {
+		snapshots[len(snapshots)-1-i] = snapshot
+	}
+	return snapshots
+}
+
+// Add merges one cycle's hop into the history.
+func (h *History) Add(hop trace.Hop) {
+	entry, ok := h.hops[hop.Number]
+	if !ok {
+		entry = &hopHistory{stats: trace.NewRTTStats(h.recent)}
+		h.hops[hop.Number] = entry
+	}
+
+	for _, rtt := range hop.RTTs {
+		entry.stats.Add(rtt)
+	}
+	if hop.Responded {
+		hop.RTTs = nil
+		entry.hop = hop
+	} else if entry.hop.Number == 0 {
+		entry.hop.Number = hop.Number
+	}
+}
+
+// Stats returns the statistics for a hop, or nil if it was never seen.
+func (h *History) Stats(number int) *trace.RTTStats {
+	if entry, ok := h.hops[number]; ok {
+		return entry.stats
+	}
+	return nil
+}
+
+// Hops returns the aggregated hops in order. RTTs holds only the retained
+// recent samples; the other statistics cover the whole session.
+func (h *History) Hops() []trace.Hop {
+	hops := make([]trace.Hop, 0, len(h.hops))
+	for _, number := range h.numbers() {
+		entry := h.hops[number]
+		s := entry.stats
+
+		hop := entry.hop
+		hop.RTTs = s.Recent()
+		hop.AvgRTT = s.Mean()
+		hop.MinRTT = s.Min()
+		hop.MaxRTT = s.Max()
+		hop.Jitter = s.Max() - s.Min()
+		hop.LastRTT = s.Last()
+		hop.LossPercent = s.LossPercent()
+		hop.Sent = int(s.Sent())
+		hop.Received = int(s.Count())
+		hop.StdDev = s.StdDev()
+		hop.EWMA = s.EWMA()
+		hop.Responded = s.Count() > 0
+		hops = append(hops, hop)
+	}
+	return hops
+}
+
+func (h *History) numbers() []int {
+	numbers := make([]int, 0, len(h.hops))
+	for number := range h.hops {
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+	return numbers
+}
@@ -0,0 +1,198 @@
+package tui
+
+import (
+	"math"
+	"math/rand"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/trace"
+)
+
+// TestHistory_LongSession simulates an overnight session: 100k probes at
+// one hop, one cycle per second on a fake clock.
+func TestHistory_LongSession(t *testing.T) {
+	const (
+		samples = 100000
+		recent  = 32
+	)
+
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	history := NewHistory(recent)
+	history.now = func() time.Time { return clock }
+
+	rng := rand.New(rand.NewSource(1))
+	var all []float64 // reference copy; the history itself must not grow
+	var replies, ewma float64
+	minRTT, maxRTT := math.Inf(1), math.Inf(-1)
+
+	for i := 0; i < samples/3; i++ {
+		rtts := make([]float64, 3)
+		for j := range rtts {
+			rtts[j] = -1
+			if rng.Intn(20) != 0 {
+				rtts[j] = 10 + rng.Float64()*40
+			}
+		}
+		clock = clock.Add(time.Second)
+		history.Add(trace.Hop{Number: 4, IP: net.ParseIP("192.0.2.4"), RTTs: rtts, Responded: true})
+
+		for _, rtt := range rtts {
+			all = append(all, rtt)
+			if rtt < 0 {
+				continue
+			}
+			if replies == 0 {
+				ewma = rtt
+			} else {
+				ewma += 0.125 * (rtt - ewma) // the EWMA weight of trace.RTTStats
+			}
+			replies++
+			minRTT = math.Min(minRTT, rtt)
+			maxRTT = math.Max(maxRTT, rtt)
+		}
+	}
+
+	s := history.Stats(4)
+	if len(s.Recent()) != recent {
+		t.Errorf("kept %d recent samples, want %d", len(s.Recent()), recent)
+	}
+
+	// Reference computation over every sample
+	var sum float64
+	for _, rtt := range all {
+		if rtt >= 0 {
+			sum += rtt
+		}
+	}
+	mean := sum / replies
+	var sqDiff float64
+	for _, rtt := range all {
+		if rtt >= 0 {
+			sqDiff += (rtt - mean) * (rtt - mean)
+		}
+	}
+
+	if s.Sent() != uint64(len(all)) || s.Count() != uint64(replies) {
+		t.Errorf("sent/received = %d/%d, want %d/%.0f", s.Sent(), s.Count(), len(all), replies)
+	}
+	checks := []struct {
+		name      string
+		got, want float64
+	}{
+		{"mean", s.Mean(), mean},
+		{"stddev", s.StdDev(), math.Sqrt(sqDiff / replies)},
+		{"min", s.Min(), minRTT},
+		{"max", s.Max(), maxRTT},
+		{"ewma", s.EWMA(), ewma},
+		{"loss", s.LossPercent(), (float64(len(all)) - replies) / float64(len(all)) * 100},
+	}
+	for _, c := range checks {
+		if math.Abs(c.got-c.want) > 1e-6 {
+			t.Errorf("%s = %.9f, want %.9f", c.name, c.got, c.want)
+		}
+	}
+
+	got := s.Recent()
+	want := all[len(all)-recent:]
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Recent()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	hops := history.Hops()
+	if len(hops) != 1 || len(hops[0].RTTs) != recent || hops[0].AvgRTT != s.Mean() {
+		t.Errorf("Hops() = %+v", hops)
+	}
+
+	if allocs := testing.AllocsPerRun(1000, func() { s.Add(12.5) }); allocs != 0 {
+		t.Errorf("Add allocates %.0f times per sample, want 0", allocs)
+	}
+}
+
+func TestHistory_UnresponsiveHop(t *testing.T) {
+	history := NewHistory(0)
+	history.Add(trace.Hop{Number: 2, RTTs: []float64{-1, -1, -1}})
+
+	hops := history.Hops()
+	if len(hops) != 1 || hops[0].Number != 2 || hops[0].Responded || hops[0].LossPercent != 100 {
+		t.Errorf("Hops() = %+v, want hop 2 with 100%% loss", hops)
+	}
+	if history.Stats(3) != nil {
+		t.Error("Stats() should be nil for unseen hops")
+	}
+}
+
+// cycle returns one trace's hops answering from the given addresses, with
+// a constant RTT.
+func cycle(rtt float64, addrs ...string) []trace.Hop {
+	hops := make([]trace.Hop, len(addrs))
+	for i, addr := range addrs {
+		hops[i] = trace.Hop{Number: i + 1, IP: net.ParseIP(addr), RTTs: []float64{rtt}, Responded: true}
+		if addr == "" {
+			hops[i] = trace.Hop{Number: i + 1, RTTs: []float64{-1}}
+		}
+	}
+	return hops
+}
+
+func TestHistory_PathChange(t *testing.T) {
+	clock := time.Date(2024, 1, 1, 14, 32, 11, 0, time.UTC)
+	history := NewHistory(0)
+	history.now = func() time.Time { return clock }
+
+	for range 3 {
+		if changes := history.AddTrace(cycle(10, "10.0.0.1", "62.115.1.1", "8.8.8.8")); changes != nil {
+			t.Fatalf("stable path reported changes %v", changes)
+		}
+	}
+	// A timeout is not a change
+	if changes := history.AddTrace(cycle(10, "10.0.0.1", "", "8.8.8.8")); changes != nil {
+		t.Fatalf("timeout reported changes %v", changes)
+	}
+
+	clock = clock.Add(time.Minute)
+	changes := history.AddTrace(cycle(20, "10.0.0.1", "154.54.1.1", "8.8.8.8"))
+	want := trace.PathChange{Time: clock, Hop: 2, From: "62.115.1.1", To: "154.54.1.1"}
+	if len(changes) != 1 || changes[0] != want {
+		t.Fatalf("AddTrace() = %v, want [%v]", changes, want)
+	}
+
+	// The superseded path keeps the stats it had
+	snapshots := history.Snapshots()
+	if len(snapshots) != 1 || !snapshots[0].Time().Equal(clock) {
+		t.Fatalf("Snapshots() = %+v, want one taken at %v", snapshots, clock)
+	}
+	old := snapshots[0].Hops[1]
+	if !old.IP.Equal(net.ParseIP("62.115.1.1")) || old.AvgRTT != 10 || old.LossPercent != 25 {
+		t.Errorf("snapshot hop 2 = %s avg %.0f loss %.0f%%, want 62.115.1.1 avg 10 loss 25%%",
+			old.IP, old.AvgRTT, old.LossPercent)
+	}
+
+	// The changed hop starts afresh; the others keep accumulating
+	hops := history.Hops()
+	if !hops[1].IP.Equal(net.ParseIP("154.54.1.1")) || hops[1].AvgRTT != 20 || hops[1].LossPercent != 0 {
+		t.Errorf("current hop 2 = %s avg %.0f loss %.0f%%, want 154.54.1.1 avg 20 loss 0%%",
+			hops[1].IP, hops[1].AvgRTT, hops[1].LossPercent)
+	}
+	if s := history.Stats(1); s.Sent() != 5 || s.Mean() != 12 {
+		t.Errorf("hop 1 sent %d avg %.0f, want 5 and 12", s.Sent(), s.Mean())
+	}
+
+	// Only the last few paths are kept, but every change is reported
+	history.SetMaxSnapshots(2)
+	for i := range 3 {
+		clock = clock.Add(time.Minute)
+		history.AddTrace(cycle(10, "10.0.0.1", "10.1.0."+string(rune('1'+i)), "8.8.8.8"))
+	}
+	snapshots = history.Snapshots()
+	if len(snapshots) != 2 || !snapshots[0].Time().Equal(clock) || snapshots[1].Changes[0].To != "10.1.0.2" {
+		t.Errorf("Snapshots() = %+v, want the 2 most recent, newest first", snapshots)
+	}
+
+	if hops := history.Hops(); len(hops) != 3 {
+		t.Errorf("Hops() has %d hops, want 3", len(hops))
+	}
+}
@@ -4,13 +4,17 @@ package tui
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 
+	"github.com/KilimcininKorOglu/poros/internal/output"
 	"github.com/KilimcininKorOglu/poros/internal/trace"
 )
 
@@ -26,18 +30,37 @@ const (
 // Model is the Bubble Tea model for the traceroute TUI.
 type Model struct {
 	// Configuration
-	target string
-	config *trace.Config
-	width  int
+	target  string
+	config  *trace.Config
+	display output.Config
+	width   int
 	height int
 
 	// State
 	state     State
 	hops      []trace.Hop
+	result    *trace.TraceResult // set once the trace completes
+	selected  int                // index of the selected hop
 	err       error
 	elapsed   time.Duration
 	startTime time.Time
 
+	// Probing is held back while paused; the elapsed timer keeps running
+	paused bool
+	pause  *trace.PauseGate
+
+	// Paths across repeated traces; pathView selects the path shown,
+	// 0 for the current one and n for the nth most recent superseded one
+	history  *History
+	pathView int
+
+	// Transient footer message; statusSeq identifies the latest one
+	status    string
+	statusSeq int
+
+	// Terminal output receiving OSC 52 clipboard sequences
+	clipboard io.Writer
+
 	// UI components
 	spinner spinner.Model
 
@@ -46,6 +69,20 @@ type Model struct {
 
 	// Channel for hop updates
 	hopChan chan trace.Hop
+
+	// With --watch the path is traced in rounds until the model is
+	// closed, interval apart; hops then hold the total of all rounds
+	interval  time.Duration
+	rounds    int
+	roundChan chan trace.Round
+
+	// ctx stops the background trace; cancel is called by Close, as
+	// the program does not wait for the trace when the user quits
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// Latest trace progress, stored by the tracer and shown on each tick
+	progress *atomic.Pointer[trace.Progress]
 }
 
 // HopMsg is sent when a new hop is discovered.
@@ -58,6 +95,11 @@ type CompleteMsg struct {
 	Result *trace.TraceResult
 }
 
+// RoundMsg is sent when a --watch round completes.
+type RoundMsg struct {
+	Round trace.Round
+}
+
 // ErrorMsg is sent when an error occurs.
 type ErrorMsg struct {
 	Err error
@@ -66,15 +108,18 @@ type ErrorMsg struct {
 // TickMsg is sent to update elapsed time.
 type TickMsg time.Time
 
-// New creates a new TUI model.
-func New(target string, config *trace.Config) (*Model, error) {
+// New creates a new TUI model. The display config supplies the
+// RTT thresholds used for coloring.
+func New(target string, config *trace.Config, display output.Config) (*Model, error) {
 	s := spinner.New()
 	s.Spinner = spinner.Dot
 	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+	ctx, cancel := context.WithCancel(context.Background())
 
 	m := &Model{
 		target:    target,
 		config:    config,
+		display:   display,
 		state:     StateRunning,
 		hops:      make([]trace.Hop, 0),
 		spinner:   s,
@@ -83,11 +128,31 @@ func New(target string, config *trace.Config) (*Model, error) {
 		height:    24,
 		startTime: time.Now(),
 		hopChan:   make(chan trace.Hop, 100),
+		pause:     trace.NewPauseGate(),
+		history:   NewHistory(0),
+		clipboard: os.Stdout,
+		progress:  new(atomic.Pointer[trace.Progress]),
+		ctx:       ctx,
+		cancel:    cancel,
 	}
+	config.Pause = m.pause
 
 	return m, nil
 }
 
+// Watch makes the model trace the path in rounds, interval apart, until
+// it is closed, like mtr. It must be called before the program starts.
+func (m *Model) Watch(interval time.Duration) {
+	m.interval = interval
+	m.roundChan = make(chan trace.Round, 1)
+}
+
+// Result returns the trace shown: the completed trace, the total of the
+// --watch rounds so far, or nil.
+func (m Model) Result() *trace.TraceResult {
+	return m.result
+}
+
 // Init implements tea.Model.
 func (m Model) Init() tea.Cmd {
 	return tea.Batch(
@@ -95,6 +160,7 @@ func (m Model) Init() tea.Cmd {
 		m.runTrace(),
 		m.tickCmd(),
 		m.waitForHop(),
+		m.waitForRound(),
 	)
 }
 
@@ -105,6 +171,49 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		switch msg.String() {
 		case "q", "ctrl+c", "esc":
 			return m, tea.Quit
+		case " ":
+			// Replies to probes already in flight are still recorded
+			if m.state == StateRunning {
+				m.paused = m.pause.Toggle()
+			}
+		case "up", "k":
+			if m.selected > 0 {
+				m.selected--
+			}
+		case "down", "j":
+			if m.selected < len(m.shownHops())-1 {
+				m.selected++
+			}
+		case "y":
+			if hop := m.selectedHop(); hop != nil && hop.IP != nil {
+				return m, m.copyCmd("IP", hop.IP.String())
+			}
+			return m, m.copyCmd("IP", "")
+		case "Y":
+			if hop := m.selectedHop(); hop != nil {
+				return m, m.copyCmd("hostname", hop.Hostname)
+			}
+			return m, m.copyCmd("hostname", "")
+		case "c":
+			return m, m.copyCmd("table", m.tableText())
+		case "p":
+			if n := len(m.history.snapshots); n > 0 {
+				m.pathView = (m.pathView + 1) % (n + 1)
+				m.selected = min(m.selected, max(len(m.shownHops())-1, 0))
+			}
+		}
+
+	case StatusMsg:
+		m.statusSeq++
+		m.status = msg.Text
+		seq := m.statusSeq
+		return m, tea.Tick(statusDuration, func(time.Time) tea.Msg {
+			return clearStatusMsg{seq: seq}
+		})
+
+	case clearStatusMsg:
+		if msg.seq == m.statusSeq {
+			m.status = ""
 		}
 
 	case tea.WindowSizeMsg:
@@ -123,13 +232,34 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 	case HopMsg:
-		m.hops = append(m.hops, msg.Hop)
+		// Past the first --watch round, hops already shown keep their
+		// totals until the round completes
+		if n := len(m.hops); n == 0 || m.rounds == 0 || msg.Hop.Number > m.hops[n-1].Number {
+			m.hops = append(m.hops, msg.Hop)
+		}
 		// Continue waiting for more hops
 		return m, m.waitForHop()
 
+	case RoundMsg:
+		m.rounds = msg.Round.Number
+		m.result = msg.Round.Total
+		m.hops = msg.Round.Total.Hops
+		m.selected = min(m.selected, max(len(m.shownHops())-1, 0))
+		if len(m.history.AddTrace(msg.Round.Result.Hops)) > 0 {
+			m.pathView = 0
+		}
+		return m, m.waitForRound()
+
 	case CompleteMsg:
 		m.state = StateComplete
+		m.result = msg.Result
+		m.paused = false
+		m.pause.Resume()
 		// Don't replace hops - they've been added via HopMsg
+		// A new snapshot shifts the older ones: show the current path
+		if msg.Result != nil && len(m.history.AddTrace(msg.Result.Hops)) > 0 {
+			m.pathView = 0
+		}
 
 	case ErrorMsg:
 		m.state = StateError
@@ -148,8 +278,24 @@ func (m Model) View() string {
 	b.WriteString(m.renderHeader())
 	b.WriteString("\n\n")
 
-	// Hop table
-	b.WriteString(m.renderHops())
+	// Latest path change, and which path is shown
+	if banner := m.renderPathBanner(); banner != "" {
+		b.WriteString(banner)
+		b.WriteString("\n\n")
+	}
+
+	// Hop table, or a card for a destination at hop 1
+	if hop := m.directHop(); hop != nil && m.pathView == 0 {
+		b.WriteString(m.renderDirect(hop))
+	} else {
+		b.WriteString(m.renderHops())
+	}
+
+	// Enrichment of the selected hop
+	if detail := m.renderHopDetail(); detail != "" {
+		b.WriteString("\n\n")
+		b.WriteString(detail)
+	}
 
 	// Footer
 	b.WriteString("\n")
@@ -163,12 +309,22 @@ func (m Model) renderHeader() string {
 	title := m.styles.Title.Render("Poros Traceroute")
 
 	var status string
-	switch m.state {
-	case StateRunning:
-		status = m.spinner.View() + " Tracing..."
-	case StateComplete:
+	switch {
+	case m.state == StateRunning && m.paused:
+		status = m.styles.Subtle.Render(fmt.Sprintf("⏸ %s (paused)", m.elapsed.Truncate(100*time.Millisecond)))
+	case m.state == StateRunning && m.interval > 0:
+		status = m.spinner.View() + fmt.Sprintf(" Watching, round %d... %s", m.rounds+1, m.elapsed.Truncate(100*time.Millisecond))
+		if progress := m.progressText(); progress != "" {
+			status += " " + m.styles.Subtle.Render(progress)
+		}
+	case m.state == StateRunning:
+		status = m.spinner.View() + fmt.Sprintf(" Tracing... %s", m.elapsed.Truncate(100*time.Millisecond))
+		if progress := m.progressText(); progress != "" {
+			status += " " + m.styles.Subtle.Render(progress)
+		}
+	case m.state == StateComplete:
 		status = m.styles.Success.Render("✓ Complete")
-	case StateError:
+	case m.state == StateError:
 		status = m.styles.Error.Render("✗ Error")
 	}
 
@@ -181,15 +337,74 @@ func (m Model) renderHeader() string {
 	)
 }
 
+// progressText returns the trace progress, e.g. "42% probing", or an
+// empty string before probing starts.
+func (m Model) progressText() string {
+	if m.progress == nil {
+		return ""
+	}
+	p := m.progress.Load()
+	if p == nil || p.Phase == trace.PhaseIdle || p.Phase == trace.PhaseResolving {
+		return ""
+	}
+	return fmt.Sprintf("%.0f%% %s", p.Percent(), p.Phase)
+}
+
+// shownHops returns the hops of the path being viewed: the current one,
+// or a superseded one selected with p.
+func (m Model) shownHops() []trace.Hop {
+	if m.pathView > 0 {
+		snapshots := m.history.Snapshots()
+		return snapshots[m.pathView-1].Hops
+	}
+	return m.hops
+}
+
+// renderPathBanner renders the latest path change, e.g. "Path changed at
+// 14:32:11, hop 6: 62.115.1.1 → 154.54.1.1", and while a superseded path
+// is shown, which one. It returns an empty string if the path never
+// changed.
+func (m Model) renderPathBanner() string {
+	snapshots := m.history.Snapshots()
+	if len(snapshots) == 0 {
+		return ""
+	}
+
+	banner := m.styles.Warning.Render(describeChanges(snapshots[0].Changes))
+	if m.pathView == 0 {
+		hint := fmt.Sprintf("Press p to view previous paths (%d kept)", len(snapshots))
+		return banner + "\n" + m.styles.Subtle.Render(hint)
+	}
+	view := fmt.Sprintf("Viewing path before %s (%d of %d), frozen at that moment; press p for the next",
+		snapshots[m.pathView-1].Time().Format(time.TimeOnly), m.pathView, len(snapshots))
+	return banner + "\n" + m.styles.Header.Render(view)
+}
+
+// describeChanges describes the changes of one cycle, e.g. "Path changed
+// at 14:32:11, hop 6: 62.115.1.1 → 154.54.1.1".
+func describeChanges(changes []trace.PathChange) string {
+	hops := make([]string, len(changes))
+	for i, change := range changes {
+		hops[i] = fmt.Sprintf("hop %d: %s → %s", change.Hop, change.From, change.To)
+	}
+	return fmt.Sprintf("Path changed at %s, %s",
+		changes[0].Time.Format(time.TimeOnly), strings.Join(hops, ", "))
+}
+
 // renderHops renders the hop table.
 func (m Model) renderHops() string {
-	if len(m.hops) == 0 {
+	hops := m.shownHops()
+	if len(hops) == 0 {
 		return m.styles.Subtle.Render("Waiting for responses...")
 	}
 
 	// Calculate hostname column width based on terminal width
-	// Fixed columns: Hop(4) + IP(16) + Avg(9) + Min(8) + Max(8) + spaces(12) = 57
-	hostnameWidth := m.width - 57
+	// Fixed columns: Hop(4) + IP(16) + Last(8) + Avg(9) + Min(8) + Max(8) + Loss(5) + spaces(16) = 74,
+	// and with --watch Snt(5) + StDev(8) + spaces(4)
+	hostnameWidth := m.width - 74
+	if m.interval > 0 {
+		hostnameWidth -= 17
+	}
 	if hostnameWidth < 20 {
 		hostnameWidth = 20
 	}
@@ -199,45 +414,105 @@ func (m Model) renderHops() string {
 
 	var rows []string
 
-	// Header row - use fixed width columns
-	header := fmt.Sprintf("%-4s  %-16s  %-*s  %9s  %8s  %8s",
-		"Hop", "IP", hostnameWidth, "Hostname", "Avg", "Min", "Max")
+	// Header row - use fixed width columns, after the selection cursor
+	header := fmt.Sprintf("  %-4s  %-16s  %-*s  %8s  %9s  %8s  %8s  %5s",
+		"Hop", "IP", hostnameWidth, "Hostname", "Last", "Avg", "Min", "Max", "Loss")
+	totalWidth := 2 + 4 + 2 + 16 + 2 + hostnameWidth + 2 + 8 + 2 + 9 + 2 + 8 + 2 + 8 + 2 + 5
+	if m.interval > 0 {
+		header += fmt.Sprintf("  %5s  %8s", "Snt", "StDev")
+		totalWidth += 2 + 5 + 2 + 8
+	}
 	rows = append(rows, m.styles.Header.Render(header))
 
 	// Separator - match total width
-	totalWidth := 4 + 2 + 16 + 2 + hostnameWidth + 2 + 9 + 2 + 8 + 2 + 8
 	rows = append(rows, m.styles.Subtle.Render(strings.Repeat("─", totalWidth)))
 
 	// Hop rows
-	for _, hop := range m.hops {
-		rows = append(rows, m.renderHopRow(hop, hostnameWidth))
+	for i, hop := range hops {
+		cursor := "  "
+		if i == m.selected {
+			cursor = m.styles.HopNum.Render("›") + " "
+		}
+		rows = append(rows, cursor+m.renderHopRow(hop, hostnameWidth))
 	}
 
 	return strings.Join(rows, "\n")
 }
 
+// directHop returns the hop of a completed trace that reached its
+// destination at hop 1 (see output.DirectHop), or nil.
+func (m Model) directHop() *trace.Hop {
+	if m.state != StateComplete || m.result == nil {
+		return nil
+	}
+	return output.DirectHop(m.result)
+}
+
+// renderDirect renders a trace that reached its destination at hop 1 as
+// a compact card instead of a one-row table.
+func (m Model) renderDirect(hop *trace.Hop) string {
+	address := m.styles.IP.Render(hop.Address())
+	if hop.Hostname != "" && !m.display.NoHostname {
+		address += "  " + m.styles.Hostname.Render(hostnameLabel(hop))
+	}
+	lines := []string{
+		m.colorizeRTT(m.display.FormatDirect(hop), hop.AvgRTT),
+		address,
+	}
+	return m.styles.Box.Render(strings.Join(lines, "\n"))
+}
+
+// hostnameLabel returns the hostname of hop, marked "?" if it failed
+// forward confirmation, as the text output shows it.
+func hostnameLabel(hop *trace.Hop) string {
+	if hop.HostnameMismatch() {
+		return hop.Hostname + "?"
+	}
+	return hop.Hostname
+}
+
 // renderHopRow renders a single hop row.
 func (m Model) renderHopRow(hop trace.Hop, hostnameWidth int) string {
 	// Format values with fixed widths FIRST, then apply colors
 	hopNum := fmt.Sprintf("%-4d", hop.Number)
 	
-	var ip, hostname, avg, min, max string
-	var avgRTT float64
+	var ip, hostname, last, avg, min, max, loss string
+	var lastRTT, avgRTT float64
 
 	if !hop.Responded {
 		ip = fmt.Sprintf("%-16s", "*")
 		hostname = fmt.Sprintf("%-*s", hostnameWidth, "*")
+		last = fmt.Sprintf("%8s", "*")
 		avg = fmt.Sprintf("%9s", "*")
 		min = fmt.Sprintf("%8s", "*")
 		max = fmt.Sprintf("%8s", "*")
+		loss = fmt.Sprintf("%5s", "*")
 	} else {
-		if hop.IP != nil {
-			ip = fmt.Sprintf("%-16s", truncate(hop.IP.String(), 16))
+		if addr := hop.Address(); addr != "" {
+			ip = fmt.Sprintf("%-16s", truncate(addr, 16))
 		} else {
 			ip = fmt.Sprintf("%-16s", "*")
 		}
-		// Show full hostname up to hostnameWidth
-		hostname = fmt.Sprintf("%-*s", hostnameWidth, truncate(hop.Hostname, hostnameWidth))
+		// Show full hostname up to hostnameWidth, followed by the
+		// interface name when the router reported one (RFC 5837)
+		name := hostnameLabel(&hop)
+		if m.display.NoHostname {
+			name = ""
+		}
+		if hop.Interface != nil {
+			name = strings.TrimSpace(name + " [" + hop.Interface.Label() + "]")
+		}
+		hostname = fmt.Sprintf("%-*s", hostnameWidth, truncate(name, hostnameWidth))
+
+		// A timed-out last probe shows as "*"
+		if hop.LastRTT > 0 {
+			lastRTT = hop.LastRTT
+			last = fmt.Sprintf("%8.2f", hop.LastRTT)
+		} else if hop.LastRTT < 0 {
+			last = fmt.Sprintf("%8s", "*")
+		} else {
+			last = fmt.Sprintf("%8s", "-")
+		}
 
 		if hop.AvgRTT > 0 {
 			avgRTT = hop.AvgRTT
@@ -249,17 +524,67 @@ func (m Model) renderHopRow(hop trace.Hop, hostnameWidth int) string {
 			min = fmt.Sprintf("%8s", "-")
 			max = fmt.Sprintf("%8s", "-")
 		}
+		loss = fmt.Sprintf("%4.0f%%", hop.LossPercent)
 	}
 
 	// Now apply colors to pre-formatted strings
-	return fmt.Sprintf("%s  %s  %s  %s  %s  %s",
+	row := fmt.Sprintf("%s  %s  %s  %s  %s  %s  %s  %s",
 		m.styles.HopNum.Render(hopNum),
 		m.styles.IP.Render(ip),
 		m.styles.Hostname.Render(hostname),
+		m.colorizeRTT(last, lastRTT),
 		m.colorizeRTT(avg, avgRTT),
 		m.styles.Subtle.Render(min),
 		m.styles.Subtle.Render(max),
+		m.colorizeLoss(loss, hop),
 	)
+	if m.interval > 0 {
+		row += "  " + m.styles.Subtle.Render(watchColumns(hop))
+	}
+	return row
+}
+
+// watchColumns returns the Snt and StDev cells of a --watch hop row.
+// Hops of a round in progress have no totals yet.
+func watchColumns(hop trace.Hop) string {
+	sent, stddev := "-", "-"
+	if hop.Sent > 0 {
+		sent = fmt.Sprintf("%d", hop.Sent)
+	}
+	if hop.Received > 1 {
+		stddev = fmt.Sprintf("%.2f", hop.StdDev)
+	}
+	return fmt.Sprintf("%5s  %8s", sent, stddev)
+}
+
+// renderHopDetail renders the ASN and location of the selected hop with
+// the enrichment source of each, or an empty string if it has neither.
+func (m Model) renderHopDetail() string {
+	hop := m.selectedHop()
+	if hop == nil {
+		return ""
+	}
+
+	var parts []string
+	if hop.ASN != nil && !m.display.NoASN {
+		asn := fmt.Sprintf("AS%d %s", hop.ASN.Number, hop.ASN.Org)
+		parts = append(parts, m.styles.ASN.Render(withSource(strings.TrimSpace(asn), hop.ASN.Source)))
+	}
+	if location := m.display.Location(hop.Geo); location != "" && !m.display.NoGeoIP {
+		parts = append(parts, m.styles.GeoIP.Render(withSource(location, hop.Geo.Source)))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Hop %d: %s", hop.Number, strings.Join(parts, " | "))
+}
+
+// withSource appends the enrichment source to s, e.g. "AS15169 (cymru)".
+func withSource(s, source string) string {
+	if source == "" {
+		return s
+	}
+	return s + " (" + source + ")"
 }
 
 // colorizeRTT applies color based on latency.
@@ -268,28 +593,66 @@ func (m Model) colorizeRTT(s string, rtt float64) string {
 		return m.styles.Subtle.Render(s)
 	}
 
-	switch {
-	case rtt < 50:
-		return m.styles.RTTLow.Render(s)
-	case rtt < 150:
+	switch m.display.ClassifyRTT(rtt) {
+	case output.LevelWarn:
 		return m.styles.RTTMed.Render(s)
-	default:
+	case output.LevelCrit:
 		return m.styles.RTTHigh.Render(s)
+	default:
+		return m.styles.RTTLow.Render(s)
 	}
 }
 
+// colorizeLoss applies color based on packet loss.
+func (m Model) colorizeLoss(s string, hop trace.Hop) string {
+	if !hop.Responded {
+		return m.styles.Subtle.Render(s)
+	}
+
+	switch m.display.ClassifyLoss(hop.LossPercent) {
+	case output.LevelWarn:
+		return m.styles.LossWarn.Render(s)
+	case output.LevelCrit:
+		return m.styles.LossCrit.Render(s)
+	default:
+		return m.styles.Subtle.Render(s)
+	}
+}
+
+// selectedHop returns the selected hop, or nil before any hop arrived.
+func (m Model) selectedHop() *trace.Hop {
+	hops := m.shownHops()
+	if m.selected < 0 || m.selected >= len(hops) {
+		return nil
+	}
+	return &hops[m.selected]
+}
+
 // renderFooter renders the footer section.
 func (m Model) renderFooter() string {
 	var parts []string
+	if m.status != "" {
+		parts = append(parts, m.styles.Success.Render(m.status))
+	}
 
-	if m.state == StateComplete {
+	if m.state == StateComplete && m.directHop() == nil {
 		parts = append(parts, fmt.Sprintf("Hops: %d", len(m.hops)))
 		if len(m.hops) > 0 && m.hops[len(m.hops)-1].AvgRTT > 0 {
 			parts = append(parts, fmt.Sprintf("Total: %.2f ms", m.hops[len(m.hops)-1].AvgRTT))
 		}
 	}
 
-	parts = append(parts, "Press 'q' to quit")
+	if m.rounds > 0 {
+		parts = append(parts, fmt.Sprintf("Rounds: %d", m.rounds))
+	}
+
+	switch {
+	case m.state == StateRunning && m.paused:
+		parts = append(parts, "Press space to resume")
+	case m.state == StateRunning:
+		parts = append(parts, "Press space to pause")
+	}
+	parts = append(parts, "y/Y copy IP/hostname, c copy table", "Press 'q' to quit")
 
 	return m.styles.Subtle.Render(strings.Join(parts, " | "))
 }
@@ -297,9 +660,20 @@ func (m Model) renderFooter() string {
 // runTrace runs the traceroute in the background.
 func (m Model) runTrace() tea.Cmd {
 	return func() tea.Msg {
-		// Set up OnHop callback to stream hops to channel
+		// Set up OnHop callback to stream hops to channel, until the
+		// model is closed
 		m.config.OnHop = func(hop *trace.Hop) {
-			m.hopChan <- *hop
+			select {
+			case m.hopChan <- *hop:
+			case <-m.ctx.Done():
+			}
+		}
+		m.config.OnProgress = func(p trace.Progress) {
+			m.progress.Store(&p)
+		}
+
+		if m.interval > 0 {
+			return m.watchRounds()
 		}
 
 		// Create tracer with callback
@@ -309,8 +683,7 @@ func (m Model) runTrace() tea.Cmd {
 		}
 		defer tracer.Close()
 
-		ctx := context.Background()
-		result, err := tracer.Trace(ctx, m.target)
+		result, err := tracer.Trace(m.ctx, m.target)
 		if err != nil {
 			return ErrorMsg{Err: err}
 		}
@@ -318,14 +691,65 @@ func (m Model) runTrace() tea.Cmd {
 	}
 }
 
+// watchRounds traces in --watch rounds on a trace.Session, sending the
+// hops and rounds to their channels, until the model is closed.
+func (m Model) watchRounds() tea.Msg {
+	session, err := trace.NewSession(m.config, 1)
+	if err != nil {
+		return ErrorMsg{Err: err}
+	}
+	defer session.Close()
+
+	onHop, onProgress, onRound := m.config.OnHop, m.config.OnProgress, m.config.OnRound
+	session.OnHop(func(_ string, hop *trace.Hop) { onHop(hop) })
+	session.OnProgress(func(_ string, p trace.Progress) { onProgress(p) })
+	session.OnRound(func(_ string, round trace.Round) {
+		if onRound != nil {
+			onRound(round)
+		}
+		select {
+		case m.roundChan <- round:
+		case <-m.ctx.Done():
+		}
+	})
+	done := make(chan error, 1)
+	session.OnComplete(func(string, *trace.TraceResult) { done <- nil })
+	session.OnError(func(_ string, err error) { done <- err })
+
+	// Runs until the model is closed, when the program is gone
+	if _, err := session.StartLoop(m.ctx, m.target, m.interval); err != nil {
+		return ErrorMsg{Err: err}
+	}
+	if err := <-done; err != nil && m.ctx.Err() == nil {
+		return ErrorMsg{Err: err}
+	}
+	return nil
+}
+
+// waitForRound waits for a --watch round from the channel.
+func (m Model) waitForRound() tea.Cmd {
+	if m.roundChan == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		select {
+		case round := <-m.roundChan:
+			return RoundMsg{Round: round}
+		case <-m.ctx.Done():
+			return nil
+		}
+	}
+}
+
 // waitForHop waits for a hop from the channel.
 func (m Model) waitForHop() tea.Cmd {
 	return func() tea.Msg {
-		hop, ok := <-m.hopChan
-		if !ok {
+		select {
+		case hop := <-m.hopChan:
+			return HopMsg{Hop: hop}
+		case <-m.ctx.Done():
 			return nil
 		}
-		return HopMsg{Hop: hop}
 	}
 }
 
@@ -336,10 +760,11 @@ func (m Model) tickCmd() tea.Cmd {
 	})
 }
 
-// Close releases resources.
+// Close stops the background trace. The hop channel is left open: the
+// trace may still be sending to it until it notices.
 func (m *Model) Close() error {
-	if m.hopChan != nil {
-		close(m.hopChan)
+	if m.cancel != nil {
+		m.cancel()
 	}
 	return nil
 }
@@ -22,9 +22,13 @@ type Styles struct {
 	Timeout  lipgloss.Style
 
 	// RTT styles (color-coded by latency)
-	RTTLow  lipgloss.Style // < 50ms
-	RTTMed  lipgloss.Style // 50-150ms
-	RTTHigh lipgloss.Style // > 150ms
+	RTTLow  lipgloss.Style // below warning threshold
+	RTTMed  lipgloss.Style // between warning and critical thresholds
+	RTTHigh lipgloss.Style // above critical threshold
+
+	// Loss styles (color-coded by packet loss)
+	LossWarn lipgloss.Style // above warning threshold
+	LossCrit lipgloss.Style // above critical threshold
 
 	// Enrichment styles
 	ASN    lipgloss.Style
@@ -90,6 +94,14 @@ func DefaultStyles() Styles {
 		RTTHigh: lipgloss.NewStyle().
 			Foreground(lipgloss.Color("196")), // Red
 
+		// Loss styles
+		LossWarn: lipgloss.NewStyle().
+			Foreground(lipgloss.Color("226")), // Yellow
+
+		LossCrit: lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("196")), // Red
+
 		// Enrichment styles
 		ASN: lipgloss.NewStyle().
 			Foreground(lipgloss.Color("141")), // Purple
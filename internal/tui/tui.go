@@ -2,33 +2,69 @@ package tui
 
 import (
 	"fmt"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
 
+	"github.com/KilimcininKorOglu/poros/internal/output"
+	"github.com/KilimcininKorOglu/poros/internal/term"
 	"github.com/KilimcininKorOglu/poros/internal/trace"
 )
 
-// Run starts the TUI with the given target and configuration.
-func Run(target string, config *trace.Config) error {
-	model, err := New(target, config)
+// Run starts the TUI with the given target and configuration. A positive
+// watch interval traces the path in rounds until the user quits, and the
+// total of the rounds completed is returned; otherwise the result is nil.
+func Run(target string, config *trace.Config, display output.Config, watch time.Duration) (*trace.TraceResult, error) {
+	model, err := New(target, config, display)
 	if err != nil {
-		return fmt.Errorf("failed to create TUI model: %w", err)
+		return nil, fmt.Errorf("failed to create TUI model: %w", err)
 	}
 	defer model.Close()
+	if watch > 0 {
+		model.Watch(watch)
+	}
+
+	// Colors and the initial size follow the same decisions as the
+	// other output, instead of lipgloss detecting them on its own
+	caps := term.Capabilities()
+	lipgloss.SetColorProfile(colorProfile(caps.Stdout.Colors))
+	if caps.Width > 0 {
+		model.width, model.height = caps.Width, caps.Height
+	}
 
 	p := tea.NewProgram(model, tea.WithAltScreen())
 	
 	finalModel, err := p.Run()
 	if err != nil {
-		return fmt.Errorf("TUI error: %w", err)
+		return nil, fmt.Errorf("TUI error: %w", err)
 	}
 
 	// Check if there was an error during the trace
-	if m, ok := finalModel.(Model); ok {
-		if m.state == StateError && m.err != nil {
-			return m.err
-		}
+	m, ok := finalModel.(Model)
+	if !ok {
+		return nil, nil
+	}
+	if m.state == StateError && m.err != nil {
+		return nil, m.err
 	}
+	if watch > 0 {
+		return m.Result(), nil
+	}
+	return nil, nil
+}
 
-	return nil
+// colorProfile returns the lipgloss color profile for a color level.
+func colorProfile(level term.ColorLevel) termenv.Profile {
+	switch level {
+	case term.ColorTrueColor:
+		return termenv.TrueColor
+	case term.Color256:
+		return termenv.ANSI256
+	case term.Color16:
+		return termenv.ANSI
+	default:
+		return termenv.Ascii
+	}
 }
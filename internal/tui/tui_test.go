@@ -1,8 +1,15 @@
 package tui
 
 import (
+	"net"
+	"strings"
 	"testing"
+	"time"
 
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/KilimcininKorOglu/poros/internal/output"
 	"github.com/KilimcininKorOglu/poros/internal/trace"
 )
 
@@ -94,7 +101,7 @@ func TestModelRenderHopRow(t *testing.T) {
 		MaxRTT:    12.3,
 	}
 
-	row := model.renderHopRow(hop)
+	row := model.renderHopRow(hop, 30)
 	if row == "" {
 		t.Error("renderHopRow should return non-empty string")
 	}
@@ -105,7 +112,7 @@ func TestModelRenderHopRow(t *testing.T) {
 		Responded: false,
 	}
 
-	row2 := model.renderHopRow(hopTimeout)
+	row2 := model.renderHopRow(hopTimeout, 30)
 	if row2 == "" {
 		t.Error("renderHopRow should handle timeout hops")
 	}
@@ -136,3 +143,339 @@ func TestColorizeRTT(t *testing.T) {
 		})
 	}
 }
+
+func TestColorizeRTT_Thresholds(t *testing.T) {
+	styles := DefaultStyles()
+	styles.RTTLow = lipgloss.NewStyle().SetString("low")
+	styles.RTTMed = lipgloss.NewStyle().SetString("med")
+	styles.RTTHigh = lipgloss.NewStyle().SetString("high")
+
+	model := &Model{
+		styles:  styles,
+		display: output.Config{RTTWarnMs: 2, RTTCritMs: 10},
+	}
+
+	tests := []struct {
+		rtt  float64
+		want lipgloss.Style
+	}{
+		{1, styles.RTTLow},
+		{5, styles.RTTMed},
+		{25, styles.RTTHigh},
+	}
+
+	for _, tt := range tests {
+		got := model.colorizeRTT("x", tt.rtt)
+		if want := tt.want.Render("x"); got != want {
+			t.Errorf("colorizeRTT(%v) = %q, want %q", tt.rtt, got, want)
+		}
+	}
+}
+
+func TestColorizeLoss(t *testing.T) {
+	styles := DefaultStyles()
+	styles.Subtle = lipgloss.NewStyle().SetString("ok")
+	styles.LossWarn = lipgloss.NewStyle().SetString("warn")
+	styles.LossCrit = lipgloss.NewStyle().SetString("crit")
+
+	model := &Model{
+		styles:  styles,
+		display: output.Config{LossCritPercent: 10},
+	}
+
+	tests := []struct {
+		name string
+		hop  trace.Hop
+		want lipgloss.Style
+	}{
+		{"no loss", trace.Hop{Responded: true}, styles.Subtle},
+		{"some loss", trace.Hop{Responded: true, LossPercent: 5}, styles.LossWarn},
+		{"heavy loss", trace.Hop{Responded: true, LossPercent: 50}, styles.LossCrit},
+		{"timeout", trace.Hop{LossPercent: 100}, styles.Subtle},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := model.colorizeLoss("x", tt.hop)
+			if want := tt.want.Render("x"); got != want {
+				t.Errorf("colorizeLoss() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestModelRenderHopRow_LastRTT(t *testing.T) {
+	model := &Model{
+		config: trace.DefaultConfig(),
+		styles: MinimalTheme(),
+	}
+
+	hop := trace.Hop{Number: 3, IP: net.ParseIP("192.0.2.3"), Responded: true, AvgRTT: 10.5, MinRTT: 8.2, MaxRTT: 12.3, LastRTT: 9.75}
+	if row := model.renderHopRow(hop, 20); !strings.Contains(row, "9.75") {
+		t.Errorf("row should show the last RTT, got %q", row)
+	}
+
+	hop.LastRTT = -1
+	row := model.renderHopRow(hop, 20)
+	fields := strings.Fields(row)
+	// Hostname is empty, so the last RTT is the third field
+	if len(fields) < 4 || fields[2] != "*" {
+		t.Errorf("a timed-out last probe should show *, got %q", row)
+	}
+}
+
+func TestModelRenderHopRow_Interface(t *testing.T) {
+	model := &Model{
+		config: trace.DefaultConfig(),
+		styles: MinimalTheme(),
+	}
+
+	hop := trace.Hop{
+		Number:    2,
+		IP:        net.ParseIP("192.0.2.2"),
+		Hostname:  "core1.example.net",
+		Responded: true,
+		AvgRTT:    5,
+		Interface: &trace.InterfaceInfo{Role: "incoming", Name: "xe-0/0/1"},
+	}
+	if row := model.renderHopRow(hop, 40); !strings.Contains(row, "core1.example.net [xe-0/0/1]") {
+		t.Errorf("row should show the interface after the hostname, got %q", row)
+	}
+}
+
+func TestModelRenderHopDetail(t *testing.T) {
+	model := &Model{
+		config: trace.DefaultConfig(),
+		styles: MinimalTheme(),
+	}
+	if detail := model.renderHopDetail(); detail != "" {
+		t.Errorf("renderHopDetail() without hops = %q, want empty", detail)
+	}
+
+	model.hops = []trace.Hop{{
+		Number:    3,
+		IP:        net.ParseIP("8.8.8.8"),
+		Responded: true,
+		ASN:       &trace.ASNInfo{Number: 15169, Org: "GOOGLE", Source: "maxmind"},
+		Geo:       &trace.GeoInfo{Country: "United States", CountryCode: "US", City: "Mountain View", Source: "ip-api"},
+	}}
+	detail := model.renderHopDetail()
+	for _, want := range []string{"Hop 3", "AS15169 GOOGLE (maxmind)", "Mountain View, US (ip-api)"} {
+		if !strings.Contains(detail, want) {
+			t.Errorf("renderHopDetail() = %q, want it to contain %q", detail, want)
+		}
+	}
+
+	model.display.CountryNames = true
+	if detail := model.renderHopDetail(); !strings.Contains(detail, "Mountain View, United States (ip-api)") {
+		t.Errorf("renderHopDetail() with CountryNames = %q, want the country name", detail)
+	}
+}
+
+func TestModel_ProgressText(t *testing.T) {
+	model, err := New("example.com", trace.DefaultConfig(), output.DefaultConfig())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if text := model.progressText(); text != "" {
+		t.Errorf("progressText() before the trace = %q, want empty", text)
+	}
+
+	model.progress.Store(&trace.Progress{Phase: trace.PhaseProbing, Planned: 90, Completed: 45})
+	if text := model.progressText(); text != "50% probing" {
+		t.Errorf("progressText() = %q, want %q", text, "50% probing")
+	}
+	if view := model.View(); !strings.Contains(view, "50% probing") {
+		t.Errorf("View() should show the progress, got:\n%s", view)
+	}
+}
+
+func TestModel_PauseResume(t *testing.T) {
+	config := trace.DefaultConfig()
+	m, err := New("example.com", config, output.Config{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if config.Pause == nil {
+		t.Fatal("New() should share its pause gate with the tracer config")
+	}
+
+	space := tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}}
+	update := func(model Model, msg tea.Msg) Model {
+		next, _ := model.Update(msg)
+		return next.(Model)
+	}
+
+	model := update(*m, space)
+	if !model.paused || !config.Pause.Paused() {
+		t.Fatal("space should pause probing")
+	}
+
+	// The timer keeps running while paused
+	model.startTime = time.Now().Add(-3 * time.Second)
+	model = update(model, TickMsg(time.Now()))
+	if model.elapsed < 3*time.Second {
+		t.Errorf("elapsed = %v, want the timer to keep running", model.elapsed)
+	}
+	if view := model.View(); !strings.Contains(view, "(paused)") || !strings.Contains(view, "space to resume") {
+		t.Errorf("paused view should say so:\n%s", view)
+	}
+
+	// Late replies are still shown
+	model = update(model, HopMsg{Hop: trace.Hop{Number: 1, Responded: true}})
+	if len(model.hops) != 1 {
+		t.Error("hops arriving while paused should be recorded")
+	}
+
+	model = update(model, space)
+	if model.paused || config.Pause.Paused() {
+		t.Fatal("space should resume probing")
+	}
+	if strings.Contains(model.View(), "(paused)") {
+		t.Error("resumed view should not say paused")
+	}
+
+	// Completing clears the pause; space does nothing afterwards
+	model = update(model, space)
+	model = update(model, CompleteMsg{})
+	if model.paused || config.Pause.Paused() {
+		t.Error("completing the trace should clear the pause")
+	}
+	model = update(model, space)
+	if model.paused || config.Pause.Paused() {
+		t.Error("space should not pause a completed trace")
+	}
+}
+
+func TestModel_DirectlyConnected(t *testing.T) {
+	m, err := New("192.168.1.1", trace.DefaultConfig(), output.Config{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	hop := trace.Hop{Number: 1, IP: net.ParseIP("192.168.1.1"), Hostname: "router.lan",
+		RTTs: []float64{0.3, 0.4, 0.5}, Responded: true}
+	hop.UpdateStats()
+
+	next, _ := m.Update(HopMsg{Hop: hop})
+	model := next.(Model)
+	if view := model.View(); !strings.Contains(view, "Hostname") {
+		t.Errorf("running trace should show the hop table:\n%s", view)
+	}
+
+	result := &trace.TraceResult{Target: "192.168.1.1", Completed: true, Hops: []trace.Hop{hop}}
+	next, _ = model.Update(CompleteMsg{Result: result})
+	view := next.(Model).View()
+	if !strings.Contains(view, "Destination is 1 hop away (directly connected), RTT 0.300/0.400/0.500 ms") ||
+		!strings.Contains(view, "router.lan") {
+		t.Errorf("completed view should show the direct card:\n%s", view)
+	}
+	if strings.Contains(view, "Hostname") || strings.Contains(view, "Hops: 1") {
+		t.Errorf("completed view should not show a one-row table:\n%s", view)
+	}
+}
+
+func TestModel_PathChange(t *testing.T) {
+	m, err := New("8.8.8.8", trace.DefaultConfig(), output.Config{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	clock := time.Date(2024, 1, 1, 14, 32, 11, 0, time.UTC)
+	m.history.now = func() time.Time { return clock }
+
+	p := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'p'}}
+	update := func(model Model, msg tea.Msg) Model {
+		next, _ := model.Update(msg)
+		return next.(Model)
+	}
+	complete := func(model Model, hop2 string) Model {
+		hops := cycle(10, "10.0.0.1", hop2, "8.8.8.8")
+		for i := range hops {
+			hops[i].UpdateStats()
+		}
+		model.hops = hops
+		return update(model, CompleteMsg{Result: &trace.TraceResult{Target: "8.8.8.8", Completed: true, Hops: hops}})
+	}
+
+	model := complete(*m, "62.115.1.1")
+	if view := model.View(); strings.Contains(view, "Path changed") {
+		t.Errorf("unchanged path should show no banner:\n%s", view)
+	}
+	if model = update(model, p); model.pathView != 0 {
+		t.Error("p should do nothing before the path changes")
+	}
+
+	model = complete(model, "154.54.1.1")
+	view := model.View()
+	if !strings.Contains(view, "Path changed at 14:32:11, hop 2: 62.115.1.1 → 154.54.1.1") {
+		t.Errorf("view should show the path change banner:\n%s", view)
+	}
+	if !strings.Contains(view, "154.54.1.1  ") || strings.Contains(view, "Viewing path") {
+		t.Errorf("view should show the current path:\n%s", view)
+	}
+
+	model = update(model, p)
+	view = model.View()
+	if !strings.Contains(view, "Viewing path before 14:32:11 (1 of 1)") || !strings.Contains(view, "62.115.1.1  ") {
+		t.Errorf("p should show the superseded path:\n%s", view)
+	}
+	if hop := model.selectedHop(); hop == nil || !hop.IP.Equal(net.ParseIP("10.0.0.1")) {
+		t.Errorf("selected hop = %v, want hop 1 of the snapshot", hop)
+	}
+
+	model = update(model, p)
+	if view := model.View(); strings.Contains(view, "Viewing path") || !strings.Contains(view, "154.54.1.1  ") {
+		t.Errorf("p should cycle back to the current path:\n%s", view)
+	}
+
+	// A further change returns the view to the current path
+	model = update(model, p)
+	model = complete(model, "10.9.9.9")
+	if model.pathView != 0 || len(model.history.Snapshots()) != 2 {
+		t.Errorf("pathView = %d with %d snapshots, want 0 and 2", model.pathView, len(model.history.Snapshots()))
+	}
+}
+
+func TestModel_WatchRounds(t *testing.T) {
+	m, err := New("8.8.8.8", trace.DefaultConfig(), output.Config{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	m.Watch(time.Second)
+	update := func(model Model, msg tea.Msg) Model {
+		next, _ := model.Update(msg)
+		return next.(Model)
+	}
+
+	// The first round streams its hops
+	model := update(*m, HopMsg{Hop: cycle(10, "10.0.0.1")[0]})
+	if len(model.hops) != 1 {
+		t.Fatalf("hops = %d, want the streamed hop", len(model.hops))
+	}
+
+	round := func(model Model, number int) Model {
+		result := &trace.TraceResult{Hops: cycle(10, "10.0.0.1", "8.8.8.8")}
+		total := &trace.TraceResult{Rounds: number, Hops: cycle(10, "10.0.0.1", "8.8.8.8")}
+		for i := range total.Hops {
+			total.Hops[i].Sent, total.Hops[i].Received, total.Hops[i].StdDev = 2*number, 2*number, 1.5
+		}
+		return update(model, RoundMsg{Round: trace.Round{Number: number, Result: result, Total: total}})
+	}
+	model = round(model, 1)
+	if model.state != StateRunning || model.Result() == nil || len(model.hops) != 2 {
+		t.Fatalf("state %v, %d hops after round 1; want running with the total", model.state, len(model.hops))
+	}
+
+	// Later rounds keep the totals of hops already shown
+	model = update(model, HopMsg{Hop: trace.Hop{Number: 1, RTTs: []float64{-1}}})
+	if len(model.hops) != 2 || !model.hops[0].Responded {
+		t.Errorf("hop of round 2 replaced the total: %+v", model.hops)
+	}
+
+	model = round(model, 2)
+	view := model.View()
+	for _, want := range []string{"Snt", "StDev", "Watching, round 3", "Rounds: 2", "1.50"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("watch view should contain %q:\n%s", want, view)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package update
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ReplaceExecutable replaces the executable at path with binary. The new
+// binary is written to a temporary file next to it, with the same mode,
+// and renamed over it, so path holds either the old or the new binary,
+// never a partial one. On Windows, where a running executable cannot be
+// replaced, the old one is moved aside to path+".old" first and removed
+// by the next update (see RemoveOld).
+func ReplaceExecutable(path string, binary []byte) error {
+	if len(binary) == 0 {
+		return errors.New("refusing to install an empty binary")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".new-*")
+	if err != nil {
+		return fmt.Errorf("cannot write next to %s: %w", path, err)
+	}
+	tmpPath := tmp.Name()
+	ok := false
+	defer func() {
+		if !ok {
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmp.Write(binary); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	if err := swapFile(tmpPath, path); err != nil {
+		return err
+	}
+	ok = true
+	return nil
+}
+
+// oldPath returns where the replaced executable is kept on Windows.
+func oldPath(path string) string {
+	return path + ".old"
+}
+
+// RemoveOld removes the executable a previous update on Windows moved
+// aside. It is a no-op elsewhere and if there is none.
+func RemoveOld(path string) {
+	os.Remove(oldPath(path))
+}
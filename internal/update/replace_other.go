@@ -0,0 +1,11 @@
+//go:build !windows
+
+package update
+
+import "os"
+
+// swapFile renames newPath over path. The rename is atomic, and the
+// running process keeps executing the old, now unlinked, file.
+func swapFile(newPath, path string) error {
+	return os.Rename(newPath, path)
+}
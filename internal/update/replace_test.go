@@ -0,0 +1,60 @@
+package update
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestReplaceExecutable(t *testing.T) {
+	exe := tempExecutable(t, "old binary")
+	if err := ReplaceExecutable(exe, []byte("new binary")); err != nil {
+		t.Fatalf("ReplaceExecutable() error = %v", err)
+	}
+
+	got, _ := os.ReadFile(exe)
+	if string(got) != "new binary" {
+		t.Errorf("executable holds %q, want the new binary", got)
+	}
+	if info, _ := os.Stat(exe); runtime.GOOS != "windows" && info.Mode().Perm() != 0755 {
+		t.Errorf("mode = %v, want the old binary's 0755", info.Mode().Perm())
+	}
+
+	// Only the executable, and on Windows the old one, are left
+	entries, _ := os.ReadDir(filepath.Dir(exe))
+	for _, e := range entries {
+		if e.Name() != "poros" && e.Name() != "poros.old" {
+			t.Errorf("left behind %s", e.Name())
+		}
+	}
+	RemoveOld(exe)
+	if _, err := os.Stat(oldPath(exe)); !os.IsNotExist(err) {
+		t.Errorf("RemoveOld() left %s", oldPath(exe))
+	}
+}
+
+func TestReplaceExecutable_Failure(t *testing.T) {
+	exe := tempExecutable(t, "old binary")
+	if err := ReplaceExecutable(exe, nil); err == nil {
+		t.Error("ReplaceExecutable() should refuse an empty binary")
+	}
+	if err := ReplaceExecutable(filepath.Join(t.TempDir(), "missing"), []byte("new")); err == nil {
+		t.Error("ReplaceExecutable() should fail for a missing executable")
+	}
+
+	if runtime.GOOS != "windows" && os.Geteuid() != 0 {
+		// A read-only directory cannot take the temporary file
+		dir := filepath.Dir(exe)
+		os.Chmod(dir, 0555)
+		defer os.Chmod(dir, 0755)
+		if err := ReplaceExecutable(exe, []byte("new binary")); err == nil {
+			t.Error("ReplaceExecutable() should fail in a read-only directory")
+		}
+	}
+
+	got, _ := os.ReadFile(exe)
+	if string(got) != "old binary" {
+		t.Errorf("failed replacements left %q in place of the old binary", got)
+	}
+}
@@ -0,0 +1,28 @@
+//go:build windows
+
+package update
+
+import (
+	"fmt"
+	"os"
+)
+
+// swapFile moves newPath to path. Windows does not let a running
+// executable be overwritten or deleted, but it can be renamed: the old
+// binary moves to path+".old", and is moved back if the new one cannot
+// take its place.
+func swapFile(newPath, path string) error {
+	old := oldPath(path)
+	os.Remove(old) // left by an earlier update
+
+	if err := os.Rename(path, old); err != nil {
+		return fmt.Errorf("cannot move %s aside: %w", path, err)
+	}
+	if err := os.Rename(newPath, path); err != nil {
+		if restoreErr := os.Rename(old, path); restoreErr != nil {
+			return fmt.Errorf("%w; restoring %s from %s failed: %v", err, path, old, restoreErr)
+		}
+		return err
+	}
+	return nil
+}
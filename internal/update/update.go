@@ -0,0 +1,241 @@
+// Package update replaces the running poros binary with the latest
+// release published on GitHub: it finds the release, downloads the
+// archive for this platform, verifies it against the release's checksum
+// file and swaps the executable in place.
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KilimcininKorOglu/poros/internal/enrich"
+)
+
+// Repo is the GitHub repository releases are published to.
+const Repo = "KilimcininKorOglu/poros"
+
+// DefaultAPIURL is the GitHub REST API endpoint.
+const DefaultAPIURL = "https://api.github.com"
+
+// ChecksumsAsset is the release asset listing the SHA-256 sum of every
+// archive, in the format of sha256sum.
+const ChecksumsAsset = "checksums.txt"
+
+// Limits on requests to GitHub. Archives are a few MB; the size limit
+// only stops a misbehaving server from filling the disk.
+const (
+	apiTimeout      = 30 * time.Second
+	downloadTimeout = 5 * time.Minute
+	maxDownloadSize = 100 << 20
+)
+
+// ErrNoAsset indicates a release without an archive for this platform.
+var ErrNoAsset = errors.New("release has no archive for this platform")
+
+// Release is a published release with its downloadable assets.
+type Release struct {
+	Version string  `json:"tag_name"` // e.g. "v1.4.0"
+	Assets  []Asset `json:"assets"`
+}
+
+// Asset is a file attached to a release.
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// Asset returns the asset called name, or nil if the release has none.
+func (r *Release) Asset(name string) *Asset {
+	for i := range r.Assets {
+		if r.Assets[i].Name == name {
+			return &r.Assets[i]
+		}
+	}
+	return nil
+}
+
+// Client talks to the GitHub releases API.
+type Client struct {
+	// APIURL is the API endpoint (empty = DefaultAPIURL)
+	APIURL string
+
+	// HTTPClient carries the requests, with the proxy settings of the
+	// config file (nil = direct connections or the environment's proxy)
+	HTTPClient *enrich.HTTPClientFactory
+}
+
+// Latest returns the latest published release.
+func (c *Client) Latest(ctx context.Context) (*Release, error) {
+	base := c.APIURL
+	if base == "" {
+		base = DefaultAPIURL
+	}
+	url := strings.TrimSuffix(base, "/") + "/repos/" + Repo + "/releases/latest"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := c.HTTPClient.Client(apiTimeout).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query releases: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to query releases: HTTP %d", resp.StatusCode)
+	}
+
+	var release Release
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxDownloadSize)).Decode(&release); err != nil {
+		return nil, fmt.Errorf("failed to read release: %w", err)
+	}
+	if release.Version == "" {
+		return nil, errors.New("failed to read release: no tag name")
+	}
+	return &release, nil
+}
+
+// Download fetches an asset into memory.
+func (c *Client) Download(ctx context.Context, asset *Asset) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, asset.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.HTTPClient.Client(downloadTimeout).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", asset.Name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: HTTP %d", asset.Name, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDownloadSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", asset.Name, err)
+	}
+	if len(data) > maxDownloadSize {
+		return nil, fmt.Errorf("failed to download %s: larger than %d MB", asset.Name, maxDownloadSize>>20)
+	}
+	return data, nil
+}
+
+// BinaryName returns the name of the executable in the release archive
+// for a platform, e.g. "poros-linux-amd64" or "poros-windows-amd64.exe".
+func BinaryName(goos, goarch string) string {
+	name := "poros-" + goos + "-" + goarch
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// AssetName returns the name of the release archive for a platform, e.g.
+// "poros-linux-amd64.tar.gz" or "poros-windows-amd64.zip".
+func AssetName(goos, goarch string) string {
+	if goos == "windows" {
+		return "poros-" + goos + "-" + goarch + ".zip"
+	}
+	return "poros-" + goos + "-" + goarch + ".tar.gz"
+}
+
+// Newer reports whether version latest is newer than current. Versions
+// are compared as vMAJOR.MINOR.PATCH, and a release outranks its
+// pre-releases ("v1.2.0-rc1"). A current version that is not a release,
+// such as "dev" or a git describe string, is older than any release.
+func Newer(latest, current string) bool {
+	l, ok := parseVersion(latest)
+	if !ok {
+		return false
+	}
+	c, ok := parseVersion(current)
+	if !ok {
+		return true
+	}
+	for i := range 3 {
+		if l.parts[i] != c.parts[i] {
+			return l.parts[i] > c.parts[i]
+		}
+	}
+	switch {
+	case l.pre == c.pre:
+		return false
+	case l.pre == "":
+		return true
+	case c.pre == "":
+		return false
+	}
+	return l.pre > c.pre
+}
+
+// version is a parsed vMAJOR.MINOR.PATCH[-PRE] version.
+type version struct {
+	parts [3]int
+	pre   string
+}
+
+// parseVersion parses a release version, with or without the leading
+// "v". It rejects build metadata and git describe suffixes such as
+// "v1.2.0-3-gabcdef0" or "-dirty", which are not releases.
+func parseVersion(s string) (version, bool) {
+	var v version
+	s = strings.TrimPrefix(s, "v")
+	s, v.pre, _ = strings.Cut(s, "-")
+	if strings.Contains(v.pre, "-g") || strings.HasSuffix(v.pre, "dirty") || strings.Contains(s, "+") {
+		return v, false
+	}
+	fields := strings.Split(s, ".")
+	if len(fields) != 3 {
+		return v, false
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v.parts[i] = n
+	}
+	return v, true
+}
+
+// Install downloads the archive of release for goos/goarch, verifies it
+// against the release's checksum file and replaces the executable at exe
+// with the binary inside. exe is left untouched if any step fails.
+func (c *Client) Install(ctx context.Context, release *Release, goos, goarch, exe string) error {
+	archive := release.Asset(AssetName(goos, goarch))
+	if archive == nil {
+		return fmt.Errorf("%w: %s has no %s", ErrNoAsset, release.Version, AssetName(goos, goarch))
+	}
+	sums := release.Asset(ChecksumsAsset)
+	if sums == nil {
+		return fmt.Errorf("%w: %s has no %s", ErrNoChecksum, release.Version, ChecksumsAsset)
+	}
+
+	checksums, err := c.Download(ctx, sums)
+	if err != nil {
+		return err
+	}
+	data, err := c.Download(ctx, archive)
+	if err != nil {
+		return err
+	}
+	if err := VerifyChecksum(archive.Name, data, checksums); err != nil {
+		return err
+	}
+
+	binary, err := ExtractBinary(archive.Name, data, BinaryName(goos, goarch))
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %w", archive.Name, err)
+	}
+	return ReplaceExecutable(exe, binary)
+}
@@ -0,0 +1,214 @@
+package update
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		latest, current string
+		want            bool
+	}{
+		{"v1.2.0", "v1.1.9", true},
+		{"v1.10.0", "v1.9.0", true},
+		{"v2.0.0", "1.9.9", true},
+		{"v1.2.0", "v1.2.0", false},
+		{"v1.1.0", "v1.2.0", false},
+		{"v1.2.0", "v1.2.0-rc1", true},
+		{"v1.2.0-rc2", "v1.2.0-rc1", true},
+		{"v1.2.0-rc1", "v1.2.0", false},
+		{"v1.2.0", "dev", true},
+		{"v1.2.0", "v1.2.0-3-gabcdef0", true},
+		{"v1.2.0", "v1.2.0-dirty", true},
+		{"nightly", "v1.0.0", false},
+	}
+	for _, tt := range tests {
+		if got := Newer(tt.latest, tt.current); got != tt.want {
+			t.Errorf("Newer(%q, %q) = %v, want %v", tt.latest, tt.current, got, tt.want)
+		}
+	}
+}
+
+func TestAssetName(t *testing.T) {
+	if got := AssetName("linux", "arm64"); got != "poros-linux-arm64.tar.gz" {
+		t.Errorf("AssetName(linux, arm64) = %q", got)
+	}
+	if got := AssetName("windows", "amd64"); got != "poros-windows-amd64.zip" {
+		t.Errorf("AssetName(windows, amd64) = %q", got)
+	}
+	if got := BinaryName("windows", "amd64"); got != "poros-windows-amd64.exe" {
+		t.Errorf("BinaryName(windows, amd64) = %q", got)
+	}
+}
+
+// releaseServer serves a GitHub API with one release holding the assets
+// in files.
+type releaseServer struct {
+	*httptest.Server
+	version string
+	files   map[string][]byte
+}
+
+func newReleaseServer(t *testing.T, version string, files map[string][]byte) *releaseServer {
+	t.Helper()
+	rs := &releaseServer{version: version, files: files}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/"+Repo+"/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		release := Release{Version: rs.version}
+		for name := range rs.files {
+			release.Assets = append(release.Assets, Asset{Name: name, URL: rs.URL + "/download/" + name})
+		}
+		json.NewEncoder(w).Encode(release)
+	})
+	mux.HandleFunc("/download/", func(w http.ResponseWriter, r *http.Request) {
+		data, ok := rs.files[filepath.Base(r.URL.Path)]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	})
+	rs.Server = httptest.NewServer(mux)
+	t.Cleanup(rs.Close)
+	return rs
+}
+
+func tarGz(t *testing.T, name string, content []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg})
+	tw.Write(content)
+	tw.Close()
+	gzw.Close()
+	return buf.Bytes()
+}
+
+func zipFile(t *testing.T, name string, content []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create(name)
+	w.Write(content)
+	zw.Close()
+	return buf.Bytes()
+}
+
+func checksumLine(name string, data []byte) string {
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), name)
+}
+
+// tempExecutable writes an executable with content to a temporary
+// directory.
+func tempExecutable(t *testing.T, content string) string {
+	t.Helper()
+	exe := filepath.Join(t.TempDir(), "poros")
+	if err := os.WriteFile(exe, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return exe
+}
+
+func TestClient_Install(t *testing.T) {
+	archive := tarGz(t, "poros-linux-amd64", []byte("new binary"))
+	other := zipFile(t, "poros-windows-amd64.exe", []byte("windows binary"))
+
+	tests := []struct {
+		name    string
+		files   map[string][]byte
+		wantErr error // nil = installed
+	}{
+		{
+			name: "installed",
+			files: map[string][]byte{
+				"poros-linux-amd64.tar.gz": archive,
+				"poros-windows-amd64.zip":  other,
+				ChecksumsAsset:             []byte(checksumLine("poros-windows-amd64.zip", other) + checksumLine("poros-linux-amd64.tar.gz", archive)),
+			},
+		},
+		{
+			name: "checksum mismatch",
+			files: map[string][]byte{
+				"poros-linux-amd64.tar.gz": archive,
+				ChecksumsAsset:             []byte(checksumLine("poros-linux-amd64.tar.gz", []byte("tampered"))),
+			},
+			wantErr: ErrChecksumMismatch,
+		},
+		{
+			name: "no checksum line",
+			files: map[string][]byte{
+				"poros-linux-amd64.tar.gz": archive,
+				ChecksumsAsset:             []byte(checksumLine("poros-windows-amd64.zip", other)),
+			},
+			wantErr: ErrNoChecksum,
+		},
+		{
+			name:    "no checksum file",
+			files:   map[string][]byte{"poros-linux-amd64.tar.gz": archive},
+			wantErr: ErrNoChecksum,
+		},
+		{
+			name:    "no archive for the platform",
+			files:   map[string][]byte{"poros-windows-amd64.zip": other},
+			wantErr: ErrNoAsset,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newReleaseServer(t, "v1.4.0", tt.files)
+			client := &Client{APIURL: server.URL}
+			exe := tempExecutable(t, "old binary")
+
+			release, err := client.Latest(context.Background())
+			if err != nil {
+				t.Fatalf("Latest() error = %v", err)
+			}
+			if release.Version != "v1.4.0" {
+				t.Errorf("Latest() version = %q", release.Version)
+			}
+
+			err = client.Install(context.Background(), release, "linux", "amd64", exe)
+			got, _ := os.ReadFile(exe)
+			if tt.wantErr == nil {
+				if err != nil || string(got) != "new binary" {
+					t.Fatalf("Install() = %v, executable holds %q", err, got)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Install() error = %v, want %v", err, tt.wantErr)
+			}
+			if string(got) != "old binary" {
+				t.Errorf("failed Install() left %q in place of the old binary", got)
+			}
+		})
+	}
+}
+
+func TestClient_LatestHTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	if _, err := (&Client{APIURL: server.URL}).Latest(context.Background()); err == nil {
+		t.Error("Latest() should fail on HTTP 403")
+	}
+}
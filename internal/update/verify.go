@@ -0,0 +1,98 @@
+package update
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// Errors of checksum verification.
+var (
+	ErrNoChecksum       = errors.New("no checksum published for asset")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+)
+
+// VerifyChecksum checks data, the asset called name, against its line in
+// checksums, a file in the format of sha256sum: "<hex sum>  <name>",
+// with an optional "*" before binary-mode names.
+func VerifyChecksum(name string, data, checksums []byte) error {
+	want := ""
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			want = strings.ToLower(fields[0])
+			break
+		}
+	}
+	if want == "" {
+		return fmt.Errorf("%w %s", ErrNoChecksum, name)
+	}
+
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return fmt.Errorf("%w for %s: got %s, want %s", ErrChecksumMismatch, name, got, want)
+	}
+	return nil
+}
+
+// ExtractBinary returns the file called binary from a release archive,
+// a .zip or a .tar.gz as named by archiveName. Directories in the
+// archive are ignored, so the file may sit in a subdirectory.
+func ExtractBinary(archiveName string, data []byte, binary string) ([]byte, error) {
+	if strings.HasSuffix(archiveName, ".zip") {
+		return extractZip(data, binary)
+	}
+	return extractTarGz(data, binary)
+}
+
+func extractTarGz(data []byte, binary string) ([]byte, error) {
+	gzr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer gzr.Close()
+
+	tr := tar.NewReader(gzr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag == tar.TypeReg && path.Base(header.Name) == binary {
+			return io.ReadAll(io.LimitReader(tr, maxDownloadSize))
+		}
+	}
+	return nil, fmt.Errorf("no %s in archive", binary)
+}
+
+func extractZip(data []byte, binary string) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || path.Base(f.Name) != binary {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(io.LimitReader(rc, maxDownloadSize))
+	}
+	return nil, fmt.Errorf("no %s in archive", binary)
+}
@@ -0,0 +1,57 @@
+package update
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestVerifyChecksum(t *testing.T) {
+	data := []byte("archive")
+	sums := []byte("0000000000000000000000000000000000000000000000000000000000000000  other.zip\n" +
+		checksumLine("poros-linux-amd64.tar.gz", data))
+
+	if err := VerifyChecksum("poros-linux-amd64.tar.gz", data, sums); err != nil {
+		t.Errorf("VerifyChecksum() = %v", err)
+	}
+	if err := VerifyChecksum("poros-linux-amd64.tar.gz", []byte("changed"), sums); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("VerifyChecksum() of changed data = %v, want ErrChecksumMismatch", err)
+	}
+	if err := VerifyChecksum("poros-darwin-arm64.tar.gz", data, sums); !errors.Is(err, ErrNoChecksum) {
+		t.Errorf("VerifyChecksum() of an unlisted asset = %v, want ErrNoChecksum", err)
+	}
+
+	// Binary-mode lines mark the name with "*"
+	binary := []byte(checksumLine("*poros-linux-amd64.tar.gz", data))
+	if err := VerifyChecksum("poros-linux-amd64.tar.gz", data, binary); err != nil {
+		t.Errorf("VerifyChecksum() of a binary-mode line = %v", err)
+	}
+}
+
+func TestExtractBinary(t *testing.T) {
+	tests := []struct {
+		name    string
+		archive string
+		data    []byte
+		binary  string
+		want    string
+	}{
+		{"tar.gz", "poros-linux-amd64.tar.gz", tarGz(t, "poros-linux-amd64", []byte("elf")), "poros-linux-amd64", "elf"},
+		{"tar.gz in a directory", "poros-darwin-arm64.tar.gz", tarGz(t, "dist/poros-darwin-arm64", []byte("macho")), "poros-darwin-arm64", "macho"},
+		{"zip", "poros-windows-amd64.zip", zipFile(t, "poros-windows-amd64.exe", []byte("pe")), "poros-windows-amd64.exe", "pe"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExtractBinary(tt.archive, tt.data, tt.binary)
+			if err != nil || string(got) != tt.want {
+				t.Errorf("ExtractBinary() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := ExtractBinary("poros-linux-amd64.tar.gz", tarGz(t, "README.md", []byte("docs")), "poros-linux-amd64"); err == nil {
+		t.Error("ExtractBinary() should fail without the binary")
+	}
+	if _, err := ExtractBinary("poros-linux-amd64.tar.gz", []byte("not gzip"), "poros-linux-amd64"); err == nil {
+		t.Error("ExtractBinary() should fail on a corrupt archive")
+	}
+}